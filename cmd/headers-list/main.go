@@ -14,11 +14,13 @@ import (
 	"log"
 	"os"
 	"sort"
+	"strings"
 
 	"periph.io/x/periph"
 	"periph.io/x/periph/conn/gpio"
 	"periph.io/x/periph/conn/pin"
 	"periph.io/x/periph/conn/pin/pinreg"
+	"periph.io/x/periph/host/board"
 )
 
 func printFailures(state *periph.State) {
@@ -146,6 +148,7 @@ func printHardware(showFunctions bool, all map[string][][]pin.Pin) {
 func mainImpl() error {
 	showFunctions := flag.Bool("f", false, "show all alternate functions")
 	verbose := flag.Bool("v", false, "verbose mode")
+	boards := flag.String("board", "", "comma separated list of JSON board description files to load")
 	flag.Parse()
 	if !*verbose {
 		log.SetOutput(ioutil.Discard)
@@ -154,6 +157,14 @@ func mainImpl() error {
 	if flag.NArg() != 0 {
 		return errors.New("unexpected argument, try -help")
 	}
+	for _, f := range strings.Split(*boards, ",") {
+		if f == "" {
+			continue
+		}
+		if err := board.AddFile(f); err != nil {
+			return err
+		}
+	}
 
 	state, err := hostInit()
 	if err != nil {
@@ -11,6 +11,6 @@ import (
 	"periph.io/x/periph/host"
 )
 
-func hostInit() (*periph.State, error) {
-	return host.Init()
+func hostInit(opts *periph.Opts) (*periph.State, error) {
+	return host.InitWithOpts(opts)
 }
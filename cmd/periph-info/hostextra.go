@@ -7,10 +7,11 @@
 package main
 
 import (
-	"periph.io/x/extra/hostextra"
+	// Register the additional drivers.
+	_ "periph.io/x/extra/hostextra"
 	"periph.io/x/periph"
 )
 
-func hostInit() (*periph.State, error) {
-	return hostextra.Init()
+func hostInit(opts *periph.Opts) (*periph.State, error) {
+	return periph.InitWithOpts(opts)
 }
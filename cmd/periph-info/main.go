@@ -12,6 +12,8 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"periph.io/x/periph"
 )
@@ -38,8 +40,49 @@ func printDrivers(drivers []periph.DriverFailure) {
 	}
 }
 
+func printStages(state *periph.State) {
+	if len(state.Stages) == 0 {
+		fmt.Print("  <none>\n")
+		return
+	}
+	for i, s := range state.Stages {
+		fmt.Printf("- %d:", i+1)
+		for _, n := range s {
+			if d, ok := state.Durations[n]; ok {
+				fmt.Printf(" %s (%s)", n, roundDuration(d))
+			} else {
+				fmt.Printf(" %s", n)
+			}
+		}
+		fmt.Printf("\n")
+	}
+}
+
+// roundDuration rounds d to make it more readable.
+func roundDuration(d time.Duration) time.Duration {
+	if d > time.Millisecond {
+		return (d + 50*time.Microsecond) / (100 * time.Microsecond) * (100 * time.Microsecond)
+	}
+	return (d + 500*time.Nanosecond) / time.Microsecond * time.Microsecond
+}
+
+// splitList splits a comma separated list of driver names.
+func splitList(s string) []string {
+	var out []string
+	for _, n := range strings.Split(s, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
 func mainImpl() error {
 	verbose := flag.Bool("v", false, "verbose mode")
+	include := flag.String("include", "", "comma separated list of drivers to initialize, with their prerequisites; defaults to all")
+	exclude := flag.String("exclude", "", "comma separated list of drivers to not initialize")
+	dryRun := flag.Bool("dry-run", false, "print the initialization stages without initializing the drivers")
+	stages := flag.Bool("stages", false, "print the initialization stages and the time each driver took")
 	flag.Parse()
 	if !*verbose {
 		log.SetOutput(ioutil.Discard)
@@ -49,10 +92,22 @@ func mainImpl() error {
 		return errors.New("unexpected argument, try -help")
 	}
 
-	state, err := hostInit()
+	opts := periph.Opts{
+		Include: splitList(*include),
+		Exclude: splitList(*exclude),
+		DryRun:  *dryRun,
+	}
+	state, err := hostInit(&opts)
 	if err != nil {
 		return err
 	}
+	if *dryRun {
+		fmt.Printf("Drivers initialization stages:\n")
+		printStages(state)
+		fmt.Printf("Drivers left out:\n")
+		printDrivers(state.Skipped)
+		return nil
+	}
 
 	fmt.Printf("Drivers loaded and their dependencies, if any:\n")
 	if len(state.Loaded) == 0 {
@@ -89,6 +144,11 @@ func mainImpl() error {
 	printDrivers(state.Skipped)
 	fmt.Printf("Drivers failed to load and the error:\n")
 	printDrivers(state.Failed)
+	if *stages {
+		fmt.Printf("Drivers initialization stages and duration:\n")
+		printStages(state)
+	}
+	fmt.Printf("Initialization took %s\n", roundDuration(state.Duration))
 	return err
 }
 
@@ -0,0 +1,151 @@
+// Copyright 2016 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package analog defines analog pins, both DAC and ADC.
+//
+// Readings are reported both as the raw value of the converter and as an
+// electric potential, computed from the reference voltage the driver was
+// configured with.
+//
+// Use https://periph.io/x/periph/conn/analog/analogreg to look up pins by
+// name.
+package analog
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/pin"
+)
+
+// Sample is one analog sample.
+type Sample struct {
+	// V is the electric potential.
+	V physic.ElectricPotential
+	// Raw is the raw value as read or written by the converter.
+	Raw int32
+}
+
+func (s Sample) String() string {
+	return fmt.Sprintf("%s(%d)", s.V, s.Raw)
+}
+
+// FromRaw returns the Sample for a raw value, where max is the raw value that
+// corresponds to the reference voltage vref.
+func FromRaw(raw, max int32, vref physic.ElectricPotential) Sample {
+	// vref * raw overflows int64 above ~4.3V for a 32 bits raw value, so
+	// divide first and scale the remainder separately. Both terms have the same
+	// sign, so the result is truncated the same way as the exact product.
+	r := physic.ElectricPotential(raw)
+	m := physic.ElectricPotential(max)
+	return Sample{V: vref/m*r + vref%m*r/m, Raw: raw}
+}
+
+// PinADC is an analog-to-digital-conversion input.
+type PinADC interface {
+	pin.Pin
+	// Range returns the maximum supported range [min, max] of the values.
+	Range() (Sample, Sample)
+	// Read returns the current pin level.
+	Read() (Sample, error)
+}
+
+// PinADCContinuous is a PinADC that can natively sample at a fixed rate, for
+// example via the continuous conversion mode of the ADC.
+//
+// Use ReadContinuous() to benefit from it when available.
+type PinADCContinuous interface {
+	PinADC
+	// ReadContinuous reads len(b) samples at the sampling rate f into b.
+	ReadContinuous(f physic.Frequency, b []Sample) error
+}
+
+// PinDAC is an digital-to-analog-conversion output.
+type PinDAC interface {
+	pin.Pin
+	// Range returns the maximum supported range [min, max] of the values.
+	Range() (Sample, Sample)
+	// Out sets the output to the closest attainable electric potential.
+	Out(v physic.ElectricPotential) error
+}
+
+// ReadContinuous reads len(b) samples from p at the sampling rate f into b.
+//
+// It uses the native implementation when p implements PinADCContinuous.
+// Otherwise p.Read() is called at each tick, so the effective sampling rate is
+// limited by the latency of Read() and the precision of the OS scheduler.
+func ReadContinuous(p PinADC, f physic.Frequency, b []Sample) error {
+	// f.Duration() truncates to 0 above 1GHz.
+	if f <= 0 || f.Duration() == 0 {
+		return fmt.Errorf("analog: invalid sampling rate %s", f)
+	}
+	if c, ok := p.(PinADCContinuous); ok {
+		return c.ReadContinuous(f, b)
+	}
+	if len(b) == 0 {
+		return nil
+	}
+	t := time.NewTicker(f.Duration())
+	defer t.Stop()
+	for i := range b {
+		if i != 0 {
+			<-t.C
+		}
+		var err error
+		if b[i], err = p.Read(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// INVALID implements both PinADC and PinDAC and fails on all access.
+var INVALID invalidPin
+
+//
+
+// errInvalidPin is returned when trying to use INVALID.
+var errInvalidPin = errors.New("analog: invalid pin")
+
+// invalidPin implements PinADC and PinDAC for compatibility but fails on all
+// access.
+type invalidPin struct {
+}
+
+func (invalidPin) String() string {
+	return "INVALID"
+}
+
+func (invalidPin) Halt() error {
+	return nil
+}
+
+func (invalidPin) Number() int {
+	return -1
+}
+
+func (invalidPin) Name() string {
+	return "INVALID"
+}
+
+func (invalidPin) Function() string {
+	return ""
+}
+
+func (invalidPin) Range() (Sample, Sample) {
+	return Sample{}, Sample{}
+}
+
+func (invalidPin) Read() (Sample, error) {
+	return Sample{}, errInvalidPin
+}
+
+func (invalidPin) Out(v physic.ElectricPotential) error {
+	return errInvalidPin
+}
+
+var _ PinADC = INVALID
+var _ PinDAC = INVALID
@@ -0,0 +1,102 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analog_test
+
+import (
+	"errors"
+	"testing"
+
+	"periph.io/x/periph/conn/analog"
+	"periph.io/x/periph/conn/analog/analogtest"
+	"periph.io/x/periph/conn/physic"
+)
+
+func TestFromRaw(t *testing.T) {
+	s := analog.FromRaw(1023, 1023, 3300*physic.MilliVolt)
+	if s.V != 3300*physic.MilliVolt || s.Raw != 1023 {
+		t.Fatal(s)
+	}
+	s = analog.FromRaw(-16384, 32767, 4096*physic.MilliVolt)
+	if s.V != -2048062501 {
+		t.Fatal(s.V)
+	}
+	if str := analog.FromRaw(512, 1024, physic.Volt).String(); str != "500mV(512)" {
+		t.Fatal(str)
+	}
+	// vref * raw doesn't fit in int64.
+	s = analog.FromRaw(1<<30, 1<<31-1, 100*physic.Volt)
+	if s.V != 50000000023 {
+		t.Fatal(s.V)
+	}
+	s = analog.FromRaw(-1<<30, 1<<31-1, 100*physic.Volt)
+	if s.V != -50000000023 {
+		t.Fatal(s.V)
+	}
+}
+
+func TestReadContinuous(t *testing.T) {
+	a := &analogtest.ADC{Samples: []analog.Sample{{Raw: 1}, {Raw: 2}, {Raw: 3}}}
+	b := make([]analog.Sample, 4)
+	if err := analog.ReadContinuous(a, physic.KiloHertz, b); err != nil {
+		t.Fatal(err)
+	}
+	if b[0].Raw != 1 || b[1].Raw != 2 || b[2].Raw != 3 || b[3].Raw != 3 {
+		t.Fatal(b)
+	}
+
+	// Hide ReadContinuous() to force polling.
+	a = &analogtest.ADC{Samples: []analog.Sample{{Raw: 1}, {Raw: 2}, {Raw: 3}}}
+	p := struct{ analog.PinADC }{a}
+	if err := analog.ReadContinuous(p, physic.KiloHertz, b); err != nil {
+		t.Fatal(err)
+	}
+	if b[0].Raw != 1 || b[1].Raw != 2 || b[2].Raw != 3 || b[3].Raw != 3 || a.Count != 4 {
+		t.Fatal(b)
+	}
+	if err := analog.ReadContinuous(p, physic.KiloHertz, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := analog.ReadContinuous(p, 0, b); err == nil {
+		t.Fatal("invalid frequency")
+	}
+	if err := analog.ReadContinuous(p, -physic.Hertz, b); err == nil {
+		t.Fatal("invalid frequency")
+	}
+	if err := analog.ReadContinuous(p, 2*physic.GigaHertz, b); err == nil {
+		t.Fatal("period is too small")
+	}
+	a.Err = errors.New("injected")
+	if err := analog.ReadContinuous(p, physic.KiloHertz, b); err == nil {
+		t.Fatal("Read failed")
+	}
+}
+
+func TestINVALID(t *testing.T) {
+	p := analog.INVALID
+	if s := p.String(); s != "INVALID" {
+		t.Fatal(s)
+	}
+	if s := p.Name(); s != "INVALID" {
+		t.Fatal(s)
+	}
+	if n := p.Number(); n != -1 {
+		t.Fatal(n)
+	}
+	if f := p.Function(); f != "" {
+		t.Fatal(f)
+	}
+	if err := p.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if min, max := p.Range(); min != max {
+		t.Fatal(min, max)
+	}
+	if _, err := p.Read(); err == nil {
+		t.Fatal("invalid pin")
+	}
+	if err := p.Out(physic.Volt); err == nil {
+		t.Fatal("invalid pin")
+	}
+}
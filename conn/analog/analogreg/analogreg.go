@@ -0,0 +1,125 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package analogreg defines a registry for the known analog pins.
+package analogreg
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+
+	"periph.io/x/periph/conn/analog"
+	"periph.io/x/periph/conn/pin"
+)
+
+// ByName returns an analog pin from its name.
+//
+// The returned pin implements analog.PinADC, analog.PinDAC or both.
+//
+// Returns nil if the pin is not present.
+func ByName(name string) pin.Pin {
+	mu.Lock()
+	defer mu.Unlock()
+	return byName[name]
+}
+
+// ADC returns an analog input from its name.
+//
+// Returns nil if the pin is not present or is not an input.
+func ADC(name string) analog.PinADC {
+	p, _ := ByName(name).(analog.PinADC)
+	return p
+}
+
+// DAC returns an analog output from its name.
+//
+// Returns nil if the pin is not present or is not an output.
+func DAC(name string) analog.PinDAC {
+	p, _ := ByName(name).(analog.PinDAC)
+	return p
+}
+
+// All returns all the analog pins available on this host.
+//
+// The list is guaranteed to be in order of name.
+func All() []pin.Pin {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]pin.Pin, 0, len(byName))
+	for _, p := range byName {
+		out = insertPinByName(out, p)
+	}
+	return out
+}
+
+// Register registers an analog pin.
+//
+// The pin must implement analog.PinADC, analog.PinDAC or both. Registering
+// the same pin name twice is an error.
+func Register(p pin.Pin) error {
+	name := p.Name()
+	if len(name) == 0 {
+		return errors.New("analogreg: can't register a pin with no name")
+	}
+	_, isADC := p.(analog.PinADC)
+	_, isDAC := p.(analog.PinDAC)
+	if !isADC && !isDAC {
+		return errors.New("analogreg: can't register pin " + strconv.Quote(name) + ", it is neither an ADC nor a DAC")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if orig, ok := byName[name]; ok {
+		return errors.New("analogreg: can't register pin " + strconv.Quote(name) + " twice; already registered as " + strconv.Quote(orig.String()))
+	}
+	byName[name] = p
+	return nil
+}
+
+// Unregister removes a previously registered analog pin.
+//
+// This can happen when an ADC is exposed via an USB device and the device is
+// unplugged.
+func Unregister(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := byName[name]; ok {
+		delete(byName, name)
+		return nil
+	}
+	return errors.New("analogreg: can't unregister unknown pin name " + strconv.Quote(name))
+}
+
+//
+
+var (
+	mu     sync.Mutex
+	byName = map[string]pin.Pin{}
+)
+
+// insertPinByName inserts pin p into list l while keeping l ordered by name.
+func insertPinByName(l []pin.Pin, p pin.Pin) []pin.Pin {
+	n := p.Name()
+	i := search(len(l), func(i int) bool { return n < l[i].Name() })
+	l = append(l, nil)
+	copy(l[i+1:], l[i:])
+	l[i] = p
+	return l
+}
+
+// search implements the same algorithm as sort.Search().
+//
+// It was extracted to to not depend on sort, which depends on reflect.
+func search(n int, f func(int) bool) int {
+	lo := 0
+	for hi := n; lo < hi; {
+		if i := int(uint(lo+hi) >> 1); !f(i) {
+			lo = i + 1
+		} else {
+			hi = i
+		}
+	}
+	return lo
+}
@@ -0,0 +1,69 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analogreg
+
+import (
+	"testing"
+
+	"periph.io/x/periph/conn/analog/analogtest"
+	"periph.io/x/periph/conn/pin"
+)
+
+func TestRegister(t *testing.T) {
+	defer reset()
+	a := &analogtest.ADC{N: "AIN1", Num: 1}
+	d := &analogtest.DAC{N: "AOUT0", Num: 0}
+	a0 := &analogtest.ADC{N: "AIN0", Num: 0}
+	for _, p := range []pin.Pin{a, d, a0} {
+		if err := Register(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Register(a); err == nil {
+		t.Fatal("registered twice")
+	}
+	if err := Register(&analogtest.ADC{}); err == nil {
+		t.Fatal("no name")
+	}
+	if err := Register(pin.V3_3); err == nil {
+		t.Fatal("not analog")
+	}
+	if p := ByName("AIN1"); p != a {
+		t.Fatal(p)
+	}
+	if p := ADC("AIN1"); p != a {
+		t.Fatal(p)
+	}
+	if p := ADC("AOUT0"); p != nil {
+		t.Fatal(p)
+	}
+	if p := DAC("AOUT0"); p != d {
+		t.Fatal(p)
+	}
+	if p := DAC("FOO"); p != nil {
+		t.Fatal(p)
+	}
+	all := All()
+	if len(all) != 3 || all[0] != a0 || all[1] != a || all[2] != d {
+		t.Fatal(all)
+	}
+	if err := Unregister("AIN1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unregister("AIN1"); err == nil {
+		t.Fatal("unregistered twice")
+	}
+	if p := ByName("AIN1"); p != nil {
+		t.Fatal(p)
+	}
+}
+
+//
+
+func reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	byName = map[string]pin.Pin{}
+}
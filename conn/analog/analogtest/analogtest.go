@@ -0,0 +1,178 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package analogtest is meant to be used to test drivers using fake analog
+// pins.
+package analogtest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"periph.io/x/periph/conn/analog"
+	"periph.io/x/periph/conn/physic"
+)
+
+// ADC implements analog.PinADC and analog.PinADCContinuous.
+//
+// Modify its members to simulate hardware events.
+type ADC struct {
+	// These should be immutable.
+	N   string
+	Num int
+	Fn  string
+
+	// Grab the Mutex before accessing the following members.
+	sync.Mutex
+	Min, Max analog.Sample
+	// Samples are returned in order by Read(). Once exhausted, the last one is
+	// returned repeatedly.
+	Samples []analog.Sample
+	// Count is the number of samples read.
+	Count int
+	// Err is returned by Read() when set.
+	Err error
+}
+
+// String implements conn.Resource.
+func (a *ADC) String() string {
+	return fmt.Sprintf("%s(%d)", a.N, a.Num)
+}
+
+// Halt implements conn.Resource.
+//
+// It has no effect.
+func (a *ADC) Halt() error {
+	return nil
+}
+
+// Name implements pin.Pin.
+func (a *ADC) Name() string {
+	return a.N
+}
+
+// Number implements pin.Pin.
+func (a *ADC) Number() int {
+	return a.Num
+}
+
+// Function implements pin.Pin.
+func (a *ADC) Function() string {
+	return a.Fn
+}
+
+// Range implements analog.PinADC.
+func (a *ADC) Range() (analog.Sample, analog.Sample) {
+	a.Lock()
+	defer a.Unlock()
+	return a.Min, a.Max
+}
+
+// Read implements analog.PinADC.
+func (a *ADC) Read() (analog.Sample, error) {
+	a.Lock()
+	defer a.Unlock()
+	return a.read()
+}
+
+// ReadContinuous implements analog.PinADCContinuous.
+//
+// It returns immediately, ignoring the sampling rate.
+func (a *ADC) ReadContinuous(f physic.Frequency, b []analog.Sample) error {
+	a.Lock()
+	defer a.Unlock()
+	for i := range b {
+		var err error
+		if b[i], err = a.read(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *ADC) read() (analog.Sample, error) {
+	if a.Err != nil {
+		return analog.Sample{}, a.Err
+	}
+	if len(a.Samples) == 0 {
+		return analog.Sample{}, errors.New("analogtest: no sample")
+	}
+	i := a.Count
+	if i >= len(a.Samples) {
+		i = len(a.Samples) - 1
+	}
+	a.Count++
+	return a.Samples[i], nil
+}
+
+// DAC implements analog.PinDAC.
+//
+// It records the values written to it.
+type DAC struct {
+	// These should be immutable.
+	N   string
+	Num int
+	Fn  string
+
+	// Grab the Mutex before accessing the following members.
+	sync.Mutex
+	Min, Max analog.Sample
+	// Outs are the values written by Out().
+	Outs []physic.ElectricPotential
+	// Err is returned by Out() when set.
+	Err error
+}
+
+// String implements conn.Resource.
+func (d *DAC) String() string {
+	return fmt.Sprintf("%s(%d)", d.N, d.Num)
+}
+
+// Halt implements conn.Resource.
+//
+// It has no effect.
+func (d *DAC) Halt() error {
+	return nil
+}
+
+// Name implements pin.Pin.
+func (d *DAC) Name() string {
+	return d.N
+}
+
+// Number implements pin.Pin.
+func (d *DAC) Number() int {
+	return d.Num
+}
+
+// Function implements pin.Pin.
+func (d *DAC) Function() string {
+	return d.Fn
+}
+
+// Range implements analog.PinDAC.
+func (d *DAC) Range() (analog.Sample, analog.Sample) {
+	d.Lock()
+	defer d.Unlock()
+	return d.Min, d.Max
+}
+
+// Out implements analog.PinDAC.
+func (d *DAC) Out(v physic.ElectricPotential) error {
+	d.Lock()
+	defer d.Unlock()
+	if d.Err != nil {
+		return d.Err
+	}
+	if v < d.Min.V || v > d.Max.V {
+		return fmt.Errorf("analogtest: %s is out of range [%s, %s]", v, d.Min.V, d.Max.V)
+	}
+	d.Outs = append(d.Outs, v)
+	return nil
+}
+
+var _ analog.PinADC = &ADC{}
+var _ analog.PinADCContinuous = &ADC{}
+var _ analog.PinDAC = &DAC{}
@@ -0,0 +1,85 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analogtest
+
+import (
+	"errors"
+	"testing"
+
+	"periph.io/x/periph/conn/analog"
+	"periph.io/x/periph/conn/physic"
+)
+
+func TestADC(t *testing.T) {
+	a := ADC{N: "AIN0", Num: 3, Fn: "ADC", Max: analog.Sample{V: physic.Volt, Raw: 1023}}
+	if s := a.String(); s != "AIN0(3)" {
+		t.Fatal(s)
+	}
+	if s := a.Name(); s != "AIN0" {
+		t.Fatal(s)
+	}
+	if n := a.Number(); n != 3 {
+		t.Fatal(n)
+	}
+	if f := a.Function(); f != "ADC" {
+		t.Fatal(f)
+	}
+	if err := a.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if min, max := a.Range(); min.Raw != 0 || max.Raw != 1023 {
+		t.Fatal(min, max)
+	}
+	if _, err := a.Read(); err == nil {
+		t.Fatal("no sample")
+	}
+	a.Samples = []analog.Sample{{Raw: 1}, {Raw: 2}}
+	if s, err := a.Read(); err != nil || s.Raw != 1 {
+		t.Fatal(s, err)
+	}
+	b := make([]analog.Sample, 2)
+	if err := a.ReadContinuous(physic.Hertz, b); err != nil || b[0].Raw != 2 || b[1].Raw != 2 {
+		t.Fatal(b, err)
+	}
+	a.Err = errors.New("injected")
+	if err := a.ReadContinuous(physic.Hertz, b); err == nil {
+		t.Fatal("injected")
+	}
+}
+
+func TestDAC(t *testing.T) {
+	d := DAC{N: "AOUT0", Num: 1, Fn: "DAC", Max: analog.Sample{V: 5 * physic.Volt, Raw: 255}}
+	if s := d.String(); s != "AOUT0(1)" {
+		t.Fatal(s)
+	}
+	if s := d.Name(); s != "AOUT0" {
+		t.Fatal(s)
+	}
+	if n := d.Number(); n != 1 {
+		t.Fatal(n)
+	}
+	if f := d.Function(); f != "DAC" {
+		t.Fatal(f)
+	}
+	if err := d.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if min, max := d.Range(); min.Raw != 0 || max.Raw != 255 {
+		t.Fatal(min, max)
+	}
+	if err := d.Out(physic.Volt); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Out(6 * physic.Volt); err == nil {
+		t.Fatal("out of range")
+	}
+	if len(d.Outs) != 1 || d.Outs[0] != physic.Volt {
+		t.Fatal(d.Outs)
+	}
+	d.Err = errors.New("injected")
+	if err := d.Out(physic.Volt); err == nil {
+		t.Fatal("injected")
+	}
+}
@@ -101,3 +101,17 @@ type Limits interface {
 	// Returns 0 if undefined.
 	MaxTxSize() int
 }
+
+// RemovedError is returned by a connection or a port when the device backing
+// it was removed, for example when an USB adapter was unplugged.
+//
+// The handle cannot be used anymore and should be closed. The device can be
+// opened again via its registry once it reappears.
+type RemovedError struct {
+	// Name is the name of the device that was removed, e.g. "/dev/i2c-3".
+	Name string
+}
+
+func (r *RemovedError) Error() string {
+	return r.Name + ": device was removed"
+}
@@ -13,3 +13,10 @@ func TestDuplex(t *testing.T) {
 		t.Fatal()
 	}
 }
+
+func TestRemovedError(t *testing.T) {
+	var err error = &RemovedError{Name: "/dev/ttyUSB0"}
+	if s := err.Error(); s != "/dev/ttyUSB0: device was removed" {
+		t.Fatal(s)
+	}
+}
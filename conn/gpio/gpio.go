@@ -235,6 +235,42 @@ type PinIO interface {
 	PWM(duty Duty, f physic.Frequency) error
 }
 
+// EdgeEvent is an edge detected on an input pin.
+type EdgeEvent struct {
+	// T is the time at which the edge was detected.
+	T time.Time
+	// L is the level of the pin right after the edge.
+	L Level
+}
+
+// PinEdgeEvents is an optional interface implemented by input pins that can
+// report when each edge occurred and the level it led to.
+//
+// This is useful for protocols where the timing between edges carry the
+// information, like infrared receivers, rotary encoders or DHT22-style sensors.
+//
+// The edges are accumulated in a bounded queue. When the queue is full, the
+// oldest edge is discarded and counted as an overflow.
+type PinEdgeEvents interface {
+	// WaitForEdgeEvent waits for the next edge or immediately returns the oldest
+	// queued edge.
+	//
+	// Only waits for the kind of edge as specified in a previous In() call.
+	// Behavior is undefined if In() with a value other than NoEdge wasn't called
+	// before.
+	//
+	// Returns false if the timeout occurred.
+	//
+	// An edge consumed by WaitForEdgeEvent() is not returned by WaitForEdge()
+	// and vice versa.
+	//
+	// Specify -1 to effectively disable timeout.
+	WaitForEdgeEvent(timeout time.Duration) (EdgeEvent, bool)
+	// EdgeOverflows returns the number of edges discarded because the queue was
+	// full since the last In() call.
+	EdgeOverflows() int
+}
+
 // INVALID implements PinIO and fails on all access.
 var INVALID PinIO
 
@@ -30,7 +30,8 @@ type Pin struct {
 	sync.Mutex
 	L         gpio.Level // Used for both input and output
 	P         gpio.Pull
-	EdgesChan chan gpio.Level  // Use it to fake edges
+	EdgesChan chan gpio.Level  // Use it or Edge() to fake edges
+	Overflows int              // Returned by EdgeOverflows(); updated by Edge()
 	D         gpio.Duty        // PWM duty
 	F         physic.Frequency // PWM period
 }
@@ -90,6 +91,7 @@ func (p *Pin) In(pull gpio.Pull, edge gpio.Edge) error {
 	if edge != gpio.NoEdge && p.EdgesChan == nil {
 		return errors.New("gpiotest: please set p.EdgesChan first")
 	}
+	p.Overflows = 0
 	// Flush any buffered edges.
 	for {
 		select {
@@ -109,16 +111,58 @@ func (p *Pin) Read() gpio.Level {
 
 // WaitForEdge implements gpio.PinIn.
 func (p *Pin) WaitForEdge(timeout time.Duration) bool {
+	_, ok := p.WaitForEdgeEvent(timeout)
+	return ok
+}
+
+// WaitForEdgeEvent implements gpio.PinEdgeEvents.
+//
+// The edge is timestamped when it is read from EdgesChan.
+func (p *Pin) WaitForEdgeEvent(timeout time.Duration) (gpio.EdgeEvent, bool) {
+	var l gpio.Level
 	if timeout == -1 {
-		_ = p.Out(<-p.EdgesChan)
-		return true
+		l = <-p.EdgesChan
+	} else {
+		select {
+		case <-time.After(timeout):
+			return gpio.EdgeEvent{}, false
+		case l = <-p.EdgesChan:
+		}
 	}
-	select {
-	case <-time.After(timeout):
-		return false
-	case l := <-p.EdgesChan:
-		_ = p.Out(l)
-		return true
+	_ = p.Out(l)
+	return gpio.EdgeEvent{T: time.Now(), L: l}, true
+}
+
+// EdgeOverflows implements gpio.PinEdgeEvents.
+func (p *Pin) EdgeOverflows() int {
+	p.Lock()
+	defer p.Unlock()
+	return p.Overflows
+}
+
+// Edge fakes an edge to level l.
+//
+// EdgesChan is used as the bounded queue: when it is full, the oldest edge is
+// discarded and counted in Overflows.
+func (p *Pin) Edge(l gpio.Level) {
+	p.Lock()
+	defer p.Unlock()
+	for {
+		select {
+		case p.EdgesChan <- l:
+			return
+		default:
+		}
+		if cap(p.EdgesChan) == 0 {
+			// Nobody is waiting for it.
+			p.Overflows++
+			return
+		}
+		select {
+		case <-p.EdgesChan:
+			p.Overflows++
+		default:
+		}
 	}
 }
 
@@ -193,4 +237,5 @@ func (p *LogPinIO) PWM(duty gpio.Duty, f physic.Frequency) error {
 }
 
 var _ gpio.PinIO = &Pin{}
+var _ gpio.PinEdgeEvents = &Pin{}
 var _ pin.PinFunc = &Pin{}
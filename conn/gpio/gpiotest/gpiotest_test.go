@@ -97,6 +97,47 @@ func TestPin_edge(t *testing.T) {
 	}
 }
 
+func TestPin_edgeEvent(t *testing.T) {
+	p := &Pin{N: "GPIO1", Num: 1, Fn: "I2C1_SDA", EdgesChan: make(chan gpio.Level, 2)}
+	if err := p.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	p.EdgesChan <- gpio.High
+	p.EdgesChan <- gpio.Low
+	e, ok := p.WaitForEdgeEvent(-1)
+	if !ok || e.L != gpio.High || e.T.Before(start) {
+		t.Fatal(e, ok)
+	}
+	e, ok = p.WaitForEdgeEvent(time.Minute)
+	if !ok || e.L != gpio.Low || p.Read() != gpio.Low {
+		t.Fatal(e, ok)
+	}
+	if _, ok := p.WaitForEdgeEvent(time.Millisecond); ok {
+		t.Fatal("no more edge")
+	}
+	p.Edge(gpio.High)
+	p.Edge(gpio.Low)
+	p.Edge(gpio.High)
+	p.Edge(gpio.Low)
+	if o := p.EdgeOverflows(); o != 2 {
+		t.Fatal(o)
+	}
+	// The oldest edges were discarded.
+	if e, ok = p.WaitForEdgeEvent(time.Minute); !ok || e.L != gpio.High {
+		t.Fatal(e, ok)
+	}
+	if e, ok = p.WaitForEdgeEvent(time.Minute); !ok || e.L != gpio.Low {
+		t.Fatal(e, ok)
+	}
+	if err := p.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	if o := p.EdgeOverflows(); o != 0 {
+		t.Fatal(o)
+	}
+}
+
 func TestPin_fail(t *testing.T) {
 	p := &Pin{N: "GPIO1", Num: 1, Fn: "I2C1_SDA"}
 	if err := p.In(gpio.Float, gpio.BothEdges); err == nil {
@@ -69,6 +69,42 @@ type Pins interface {
 	SDA() gpio.PinIO
 }
 
+// RegisterImage is a 256 bytes register image that the host shares with the
+// controllers on the bus while acting as a target, also known as slave, with
+// notification of the changes they make.
+//
+// The controllers access the image like an EEPROM: the first byte written
+// sets the register address, the following bytes are written starting at this
+// address and reads continue from the current address. The address wraps
+// around at 256.
+//
+// The controller transactions themselves are not reported, only the changes
+// to the image. The methods are called sequentially and must return quickly.
+type RegisterImage interface {
+	// OnChange is called after a controller modified the image. w[0] is the
+	// address of the first modified register, followed by its new content.
+	// Each contiguous range of modified registers is reported separately.
+	OnChange(w []byte)
+	// Image returns the content of the image starting at address 0. Registers
+	// past the returned data read as 0xFF.
+	//
+	// It is called when the image is shared and after each OnChange() call.
+	Image() []byte
+}
+
+// RegisterTarget is an I²C bus on which the host can act as a target,
+// exposing a RegisterImage to the other controllers on the bus.
+//
+// This is useful to emulate a register based device for another
+// microcontroller on the bus.
+type RegisterTarget interface {
+	String() string
+	// ShareImage starts exposing img at the specified address.
+	//
+	// Call Close() on the returned object to stop responding.
+	ShareImage(addr uint16, img RegisterImage) (io.Closer, error)
+}
+
 // Dev is a device on a I²C bus.
 //
 // It implements conn.Conn.
@@ -95,9 +95,7 @@ func All() []*Ref {
 	defer mu.Unlock()
 	out := make([]*Ref, 0, len(byName))
 	for _, v := range byName {
-		r := &Ref{Name: v.Name, Aliases: make([]string, len(v.Aliases)), Number: v.Number, Open: v.Open}
-		copy(r.Aliases, v.Aliases)
-		out = insertRef(out, r)
+		out = insertRef(out, copyRef(v))
 	}
 	return out
 }
@@ -140,6 +138,13 @@ func Register(name string, aliases []string, number int, o Opener) error {
 		}
 	}
 
+	var added *Ref
+	defer func() {
+		// Called after mu is unlocked.
+		if added != nil {
+			notify(Event{Ref: added})
+		}
+	}()
 	mu.Lock()
 	defer mu.Unlock()
 	if _, ok := byName[name]; ok {
@@ -171,6 +176,7 @@ func Register(name string, aliases []string, number int, o Opener) error {
 	for _, alias := range aliases {
 		byAlias[alias] = r
 	}
+	added = copyRef(r)
 	return nil
 }
 
@@ -179,6 +185,13 @@ func Register(name string, aliases []string, number int, o Opener) error {
 // This can happen when an I²C bus is exposed via an USB device and the device
 // is unplugged.
 func Unregister(name string) error {
+	var removed *Ref
+	defer func() {
+		// Called after mu is unlocked.
+		if removed != nil {
+			notify(Event{Ref: removed, Removed: true})
+		}
+	}()
 	mu.Lock()
 	defer mu.Unlock()
 	r := byName[name]
@@ -190,9 +203,43 @@ func Unregister(name string) error {
 	for _, alias := range r.Aliases {
 		delete(byAlias, alias)
 	}
+	removed = copyRef(r)
 	return nil
 }
 
+// Event is a registration or an unregistration of a bus.
+type Event struct {
+	// Ref is a copy of the reference to the bus.
+	Ref *Ref
+	// Removed is true when the bus was unregistered.
+	Removed bool
+}
+
+// Subscribe registers a callback that is called after each bus registration
+// and unregistration, for example when an I²C adapter is plugged or unplugged.
+//
+// The callback is called synchronously in the goroutine calling Register() or
+// Unregister(), without the registry lock held, so it can use the registry.
+//
+// It returns a function to unsubscribe.
+func Subscribe(f func(e Event)) func() {
+	mu.Lock()
+	defer mu.Unlock()
+	lastID++
+	id := lastID
+	subscribers = append(subscribers, subscriber{id, f})
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for i := range subscribers {
+			if subscribers[i].id == id {
+				subscribers = append(subscribers[:i], subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
 //
 
 var (
@@ -201,8 +248,36 @@ var (
 	// Caches
 	byNumber = map[int]*Ref{}
 	byAlias  = map[string]*Ref{}
+	// Subscribers to the changes.
+	subscribers []subscriber
+	lastID      int
 )
 
+type subscriber struct {
+	id int
+	f  func(e Event)
+}
+
+// notify calls the subscribers with e.
+//
+// mu must not be held.
+func notify(e Event) {
+	mu.Lock()
+	l := make([]subscriber, len(subscribers))
+	copy(l, subscribers)
+	mu.Unlock()
+	for _, s := range l {
+		s.f(e)
+	}
+}
+
+// copyRef returns a copy of r, so it can be safely handed out.
+func copyRef(r *Ref) *Ref {
+	c := &Ref{Name: r.Name, Aliases: make([]string, len(r.Aliases)), Number: r.Number, Open: r.Open}
+	copy(c.Aliases, r.Aliases)
+	return c
+}
+
 // getDefault returns the Ref that should be used as the default bus.
 func getDefault() *Ref {
 	var o *Ref
@@ -143,6 +143,37 @@ func TestUnregister(t *testing.T) {
 	}
 }
 
+func TestSubscribe(t *testing.T) {
+	defer reset()
+	var events []Event
+	unsubscribe := Subscribe(func(e Event) {
+		// The registry can be used from the callback.
+		if l := All(); len(l) != 1 && !e.Removed {
+			t.Fatal(l)
+		}
+		events = append(events, e)
+	})
+	if err := Register("a", []string{"b"}, 0, fakeBuser); err != nil {
+		t.Fatal(err)
+	}
+	if Register("a", nil, -1, fakeBuser) == nil {
+		t.Fatal("registering twice")
+	}
+	if err := Unregister("a"); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Removed || events[0].Ref.Name != "a" || events[0].Ref.Aliases[0] != "b" || !events[1].Removed || events[1].Ref.Name != "a" {
+		t.Fatal(events)
+	}
+	unsubscribe()
+	if err := Register("a", nil, 0, fakeBuser); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatal(events)
+	}
+}
+
 //
 
 func fakeBuser() (i2c.BusCloser, error) {
@@ -155,6 +186,7 @@ func reset() {
 	byName = map[string]*Ref{}
 	byNumber = map[int]*Ref{}
 	byAlias = map[string]*Ref{}
+	subscribers = nil
 }
 
 type fakeBus struct {
@@ -7,6 +7,7 @@ package i2ctest
 
 import (
 	"bytes"
+	"io"
 	"sync"
 
 	"periph.io/x/periph/conn/conntest"
@@ -155,6 +156,133 @@ func (p *Playback) SDA() gpio.PinIO {
 	return p.SDAPin
 }
 
+// RegisterTarget implements i2c.RegisterTarget and i2c.Bus to emulate devices.
+//
+// Transactions done with Tx() are applied to the images shared with
+// ShareImage(), as if another controller on the bus did them, the same way
+// host/sysfs does. This permits testing a device driver against an emulated
+// device.
+type RegisterTarget struct {
+	sync.Mutex
+	Images map[uint16]i2c.RegisterImage
+
+	mem map[uint16]*targetMem
+}
+
+func (t *RegisterTarget) String() string {
+	return "target"
+}
+
+// ShareImage implements i2c.RegisterTarget.
+func (t *RegisterTarget) ShareImage(addr uint16, h i2c.RegisterImage) (io.Closer, error) {
+	t.Lock()
+	defer t.Unlock()
+	if _, ok := t.Images[addr]; ok {
+		return nil, conntest.Errorf("i2ctest: address %d is already used", addr)
+	}
+	if t.Images == nil {
+		t.Images = map[uint16]i2c.RegisterImage{}
+	}
+	t.Images[addr] = h
+	t.memory(addr, h)
+	return &listener{t: t, addr: addr}, nil
+}
+
+// Tx implements i2c.Bus.
+//
+// The first byte of w sets the register address and the following ones are
+// written to the registers. OnChange() is called with the modified ranges,
+// then Image() to refresh the registers. r is read from the registers,
+// starting at the current address.
+func (t *RegisterTarget) Tx(addr uint16, w, r []byte) error {
+	t.Lock()
+	defer t.Unlock()
+	h := t.Images[addr]
+	if h == nil {
+		return conntest.Errorf("i2ctest: no target at address %d", addr)
+	}
+	m := t.memory(addr, h)
+	if len(w) != 0 {
+		m.ptr = w[0]
+		old := m.regs
+		for _, b := range w[1:] {
+			m.regs[m.ptr] = b
+			m.ptr++
+		}
+		if m.regs != old {
+			for i := 0; i < len(m.regs); {
+				if m.regs[i] == old[i] {
+					i++
+					continue
+				}
+				j := i + 1
+				for ; j < len(m.regs) && m.regs[j] != old[j]; j++ {
+				}
+				h.OnChange(append([]byte{byte(i)}, m.regs[i:j]...))
+				i = j
+			}
+			m.load(h)
+		}
+	}
+	for i := range r {
+		r[i] = m.regs[m.ptr]
+		m.ptr++
+	}
+	return nil
+}
+
+// SetSpeed implements i2c.Bus.
+func (t *RegisterTarget) SetSpeed(f physic.Frequency) error {
+	return nil
+}
+
+// memory returns the registers of the target at addr, initializing them from
+// h if needed.
+//
+// The lock must be held.
+func (t *RegisterTarget) memory(addr uint16, h i2c.RegisterImage) *targetMem {
+	m := t.mem[addr]
+	if m == nil {
+		if t.mem == nil {
+			t.mem = map[uint16]*targetMem{}
+		}
+		m = &targetMem{}
+		m.load(h)
+		t.mem[addr] = m
+	}
+	return m
+}
+
+// targetMem is the register file of an emulated target.
+type targetMem struct {
+	regs [256]byte
+	ptr  byte // current register address; wraps around
+}
+
+func (m *targetMem) load(h i2c.RegisterImage) {
+	for i := range m.regs {
+		m.regs[i] = 0xFF
+	}
+	copy(m.regs[:], h.Image())
+}
+
+// listener is returned by RegisterTarget.ShareImage().
+type listener struct {
+	t    *RegisterTarget
+	addr uint16
+}
+
+func (l *listener) Close() error {
+	l.t.Lock()
+	defer l.t.Unlock()
+	if _, ok := l.t.Images[l.addr]; !ok {
+		return conntest.Errorf("i2ctest: address %d is not used", l.addr)
+	}
+	delete(l.t.Images, l.addr)
+	delete(l.t.mem, l.addr)
+	return nil
+}
+
 //
 
 // errorf is the internal implementation that optionally panic.
@@ -172,3 +300,5 @@ var _ i2c.Bus = &Record{}
 var _ i2c.Pins = &Record{}
 var _ i2c.Bus = &Playback{}
 var _ i2c.Pins = &Playback{}
+var _ i2c.Bus = &RegisterTarget{}
+var _ i2c.RegisterTarget = &RegisterTarget{}
@@ -5,11 +5,14 @@
 package i2ctest
 
 import (
+	"bytes"
 	"testing"
 
 	"periph.io/x/periph/conn/conntest"
 	"periph.io/x/periph/conn/gpio"
 	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/physic"
 )
 
 func TestRecord_empty(t *testing.T) {
@@ -165,3 +168,83 @@ func TestRecord_Playback(t *testing.T) {
 		t.Fatal("Playback.Ops is empty")
 	}
 }
+
+func TestRegisterTarget(t *testing.T) {
+	tg := RegisterTarget{}
+	if s := tg.String(); s != "target" {
+		t.Fatal(s)
+	}
+	if err := tg.SetSpeed(physic.KiloHertz); err != nil {
+		t.Fatal(err)
+	}
+	if tg.Tx(0x20, []byte{1}, nil) == nil {
+		t.Fatal("no target")
+	}
+	h := &registers{regs: []byte{10, 11, 12}}
+	l, err := tg.ShareImage(0x20, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tg.ShareImage(0x20, h); err == nil {
+		t.Fatal("address already used")
+	}
+	// Use the emulated device through i2c.Dev like a device driver would.
+	d := i2c.Dev{Bus: &tg, Addr: 0x20}
+	if err := d.Tx([]byte{1, 42}, nil); err != nil {
+		t.Fatal(err)
+	}
+	r := make([]byte, 4)
+	if err := d.Tx([]byte{1}, r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r, []byte{42, 12, 0xFF, 0xFF}) {
+		t.Fatal(r)
+	}
+	if len(h.writes) != 1 || !bytes.Equal(h.writes[0], []byte{1, 42}) {
+		t.Fatal(h.writes)
+	}
+	// Reads continue from the current address and wrap around.
+	r = make([]byte, 251)
+	if err := d.Tx(nil, r); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Tx(nil, r[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r[:2], []byte{10, 42}) {
+		t.Fatal(r[:2])
+	}
+	// Writes that do not modify the registers are not reported.
+	if err := d.Tx([]byte{0, 10, 42, 7}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.writes) != 2 || !bytes.Equal(h.writes[1], []byte{2, 7}) {
+		t.Fatal(h.writes)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if l.Close() == nil {
+		t.Fatal("already closed")
+	}
+	if tg.Tx(0x20, []byte{1}, nil) == nil {
+		t.Fatal("no target")
+	}
+}
+
+//
+
+// registers emulates a register based device.
+type registers struct {
+	regs   []byte
+	writes [][]byte
+}
+
+func (r *registers) OnChange(w []byte) {
+	r.writes = append(r.writes, w)
+	copy(r.regs[w[0]:], w[1:])
+}
+
+func (r *registers) Image() []byte {
+	return r.regs
+}
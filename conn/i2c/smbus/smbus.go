@@ -0,0 +1,405 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package smbus implements the SMBus protocol over an I²C bus.
+//
+// SMBus is a subset of I²C that defines a set of transactions commonly used
+// to read and write registers, plus optional packet error checking (PEC).
+//
+// When the bus implements Native and supports the transaction, for example
+// the Linux sysfs driver, the transaction is done natively. Otherwise, it is
+// emulated with i2c.Bus.Tx().
+//
+// See http://smbus.org/specs/ for more information.
+package smbus
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"periph.io/x/periph/conn/i2c"
+)
+
+// BlockMax is the maximum data length of a block transaction.
+const BlockMax = 32
+
+// Protocol is a type of SMBus transaction.
+//
+// The values match the Linux kernel's I2C_SMBUS_xxx sizes.
+type Protocol uint32
+
+// Supported transactions.
+const (
+	Quick         Protocol = 0 // Only the R/W bit is sent.
+	Byte          Protocol = 1 // Send or receive a single byte.
+	ByteData      Protocol = 2 // Read or write a 8 bits register.
+	WordData      Protocol = 3 // Read or write a 16 bits register.
+	ProcCall      Protocol = 4 // Write 16 bits then read 16 bits.
+	BlockData     Protocol = 5 // Read or write a length prefixed block.
+	BlockProcCall Protocol = 7 // Write a block then read a block.
+)
+
+func (p Protocol) String() string {
+	switch p {
+	case Quick:
+		return "Quick"
+	case Byte:
+		return "Byte"
+	case ByteData:
+		return "ByteData"
+	case WordData:
+		return "WordData"
+	case ProcCall:
+		return "ProcCall"
+	case BlockData:
+		return "BlockData"
+	case BlockProcCall:
+		return "BlockProcCall"
+	default:
+		return "Protocol(" + strconv.Itoa(int(p)) + ")"
+	}
+}
+
+// Func is a bitmask of SMBus functions supported natively by a bus.
+//
+// The values match the Linux kernel's I2C_FUNC_xxx flags.
+type Func uint32
+
+// Functions.
+const (
+	FuncPEC            Func = 0x00000008
+	FuncBlockProcCall  Func = 0x00008000
+	FuncQuick          Func = 0x00010000
+	FuncReadByte       Func = 0x00020000
+	FuncWriteByte      Func = 0x00040000
+	FuncReadByteData   Func = 0x00080000
+	FuncWriteByteData  Func = 0x00100000
+	FuncReadWordData   Func = 0x00200000
+	FuncWriteWordData  Func = 0x00400000
+	FuncProcCall       Func = 0x00800000
+	FuncReadBlockData  Func = 0x01000000
+	FuncWriteBlockData Func = 0x02000000
+)
+
+func (f Func) String() string {
+	var out []string
+	for _, n := range funcNames {
+		if f&n.f != 0 {
+			out = append(out, n.name)
+			f &^= n.f
+		}
+	}
+	if f != 0 {
+		out = append(out, "0x"+strconv.FormatUint(uint64(f), 16))
+	}
+	return strings.Join(out, "|")
+}
+
+// Native is an I²C bus that can natively do SMBus transactions.
+type Native interface {
+	i2c.Bus
+	// SMBusFunc returns the SMBus functions natively supported.
+	SMBusFunc() Func
+	// SMBusXfer does a native SMBus transaction at the specified device
+	// address.
+	//
+	// For Quick, read is the bit sent and data is unused. For a Byte write, cmd
+	// is the byte sent. Otherwise data is read from or written to depending on
+	// p:
+	//
+	// - Byte and ByteData use data[0].
+	//
+	// - WordData and ProcCall use data[0:2] as little endian.
+	//
+	// - BlockData and BlockProcCall use data[0] as the length, followed by the
+	// data.
+	SMBusXfer(addr uint16, read bool, cmd byte, p Protocol, pec bool, data *[BlockMax + 2]byte) error
+}
+
+// Dev is a device on a SMBus.
+//
+// It implements conn.Conn via the embedded i2c.Dev.
+type Dev struct {
+	i2c.Dev
+	// PEC enables packet error checking. A CRC-8 of the whole transaction,
+	// including the addresses, is appended to each transaction.
+	PEC bool
+}
+
+// Quick sends the R/W bit only. It is sometimes used to turn a device on or
+// off.
+//
+// It is not supported by emulation.
+func (d *Dev) Quick(bit bool) error {
+	var data [BlockMax + 2]byte
+	return d.xfer(bit, 0, Quick, &data)
+}
+
+// ReceiveByte reads a single byte without sending a command.
+func (d *Dev) ReceiveByte() (byte, error) {
+	var data [BlockMax + 2]byte
+	err := d.xfer(true, 0, Byte, &data)
+	return data[0], err
+}
+
+// SendByte sends a single byte.
+func (d *Dev) SendByte(b byte) error {
+	var data [BlockMax + 2]byte
+	return d.xfer(false, b, Byte, &data)
+}
+
+// ReadByteData reads the 8 bits register cmd.
+func (d *Dev) ReadByteData(cmd byte) (byte, error) {
+	var data [BlockMax + 2]byte
+	err := d.xfer(true, cmd, ByteData, &data)
+	return data[0], err
+}
+
+// WriteByteData writes v to the 8 bits register cmd.
+func (d *Dev) WriteByteData(cmd, v byte) error {
+	data := [BlockMax + 2]byte{v}
+	return d.xfer(false, cmd, ByteData, &data)
+}
+
+// ReadWordData reads the 16 bits register cmd.
+//
+// The word is sent as little endian on the wire.
+func (d *Dev) ReadWordData(cmd byte) (uint16, error) {
+	var data [BlockMax + 2]byte
+	err := d.xfer(true, cmd, WordData, &data)
+	return uint16(data[0]) | uint16(data[1])<<8, err
+}
+
+// WriteWordData writes v to the 16 bits register cmd.
+func (d *Dev) WriteWordData(cmd byte, v uint16) error {
+	data := [BlockMax + 2]byte{byte(v), byte(v >> 8)}
+	return d.xfer(false, cmd, WordData, &data)
+}
+
+// ProcessCall writes v to cmd and reads back the 16 bits result.
+func (d *Dev) ProcessCall(cmd byte, v uint16) (uint16, error) {
+	data := [BlockMax + 2]byte{byte(v), byte(v >> 8)}
+	err := d.xfer(false, cmd, ProcCall, &data)
+	return uint16(data[0]) | uint16(data[1])<<8, err
+}
+
+// ReadBlockData reads a block of up to BlockMax bytes from cmd into r.
+//
+// It returns the number of bytes read, as reported by the device.
+func (d *Dev) ReadBlockData(cmd byte, r []byte) (int, error) {
+	var data [BlockMax + 2]byte
+	if err := d.xfer(true, cmd, BlockData, &data); err != nil {
+		return 0, err
+	}
+	return copyBlock(r, &data)
+}
+
+// WriteBlockData writes a block of up to BlockMax bytes to cmd.
+func (d *Dev) WriteBlockData(cmd byte, w []byte) error {
+	var data [BlockMax + 2]byte
+	if err := fillBlock(&data, w); err != nil {
+		return err
+	}
+	return d.xfer(false, cmd, BlockData, &data)
+}
+
+// BlockProcessCall writes a block of up to BlockMax bytes to cmd and reads
+// back a block into r.
+//
+// It returns the number of bytes read, as reported by the device.
+func (d *Dev) BlockProcessCall(cmd byte, w, r []byte) (int, error) {
+	var data [BlockMax + 2]byte
+	if err := fillBlock(&data, w); err != nil {
+		return 0, err
+	}
+	if err := d.xfer(false, cmd, BlockProcCall, &data); err != nil {
+		return 0, err
+	}
+	return copyBlock(r, &data)
+}
+
+// PEC returns the SMBus packet error code of b, which is a CRC-8 with the
+// polynomial x⁸+x²+x+1.
+//
+// b must include the address bytes as sent on the wire.
+func PEC(b []byte) byte {
+	var crc byte
+	for _, v := range b {
+		crc ^= v
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+//
+
+var funcNames = []struct {
+	f    Func
+	name string
+}{
+	{FuncPEC, "PEC"},
+	{FuncBlockProcCall, "BlockProcCall"},
+	{FuncQuick, "Quick"},
+	{FuncReadByte, "ReadByte"},
+	{FuncWriteByte, "WriteByte"},
+	{FuncReadByteData, "ReadByteData"},
+	{FuncWriteByteData, "WriteByteData"},
+	{FuncReadWordData, "ReadWordData"},
+	{FuncWriteWordData, "WriteWordData"},
+	{FuncProcCall, "ProcCall"},
+	{FuncReadBlockData, "ReadBlockData"},
+	{FuncWriteBlockData, "WriteBlockData"},
+}
+
+// function returns the function required to do the transaction natively.
+func (p Protocol) function(read bool) Func {
+	switch p {
+	case Quick:
+		return FuncQuick
+	case Byte:
+		if read {
+			return FuncReadByte
+		}
+		return FuncWriteByte
+	case ByteData:
+		if read {
+			return FuncReadByteData
+		}
+		return FuncWriteByteData
+	case WordData:
+		if read {
+			return FuncReadWordData
+		}
+		return FuncWriteWordData
+	case ProcCall:
+		return FuncProcCall
+	case BlockData:
+		if read {
+			return FuncReadBlockData
+		}
+		return FuncWriteBlockData
+	default:
+		return FuncBlockProcCall
+	}
+}
+
+func (d *Dev) xfer(read bool, cmd byte, p Protocol, data *[BlockMax + 2]byte) error {
+	if n, ok := d.Bus.(Native); ok {
+		f := p.function(read)
+		if d.PEC {
+			f |= FuncPEC
+		}
+		if n.SMBusFunc()&f == f {
+			return n.SMBusXfer(d.Addr, read, cmd, p, d.PEC, data)
+		}
+	}
+	return d.emulate(read, cmd, p, data)
+}
+
+// emulate does the transaction with i2c.Bus.Tx().
+func (d *Dev) emulate(read bool, cmd byte, p Protocol, data *[BlockMax + 2]byte) error {
+	var w []byte
+	l := 0 // Bytes to read, excluding PEC.
+	switch p {
+	case Quick:
+		return errors.New("smbus: quick command requires native support")
+	case Byte:
+		if read {
+			l = 1
+		} else {
+			w = []byte{cmd}
+		}
+	case ByteData:
+		w = []byte{cmd}
+		if read {
+			l = 1
+		} else {
+			w = append(w, data[0])
+		}
+	case WordData:
+		w = []byte{cmd}
+		if read {
+			l = 2
+		} else {
+			w = append(w, data[0], data[1])
+		}
+	case ProcCall:
+		w = []byte{cmd, data[0], data[1]}
+		l = 2
+	case BlockData:
+		w = []byte{cmd}
+		if read {
+			l = 1 + BlockMax
+		} else {
+			w = append(w, data[:1+data[0]]...)
+		}
+	case BlockProcCall:
+		w = append([]byte{cmd}, data[:1+data[0]]...)
+		l = 1 + BlockMax
+	default:
+		return fmt.Errorf("smbus: unknown protocol %s", p)
+	}
+	addr := byte(d.Addr << 1)
+	var r []byte
+	if l != 0 {
+		r = make([]byte, l, l+1)
+		if d.PEC {
+			r = r[:l+1]
+		}
+	} else if d.PEC {
+		w = append(w, PEC(append([]byte{addr}, w...)))
+	}
+	if err := d.Bus.Tx(d.Addr, w, r); err != nil {
+		return err
+	}
+	if l == 0 {
+		return nil
+	}
+	if p == BlockData || p == BlockProcCall {
+		if r[0] > BlockMax {
+			return fmt.Errorf("smbus: invalid block length %d", r[0])
+		}
+		l = 1 + int(r[0])
+	}
+	if d.PEC {
+		var h []byte
+		if len(w) != 0 {
+			h = append([]byte{addr}, w...)
+		}
+		h = append(h, addr|1)
+		if PEC(append(h, r[:l]...)) != r[l] {
+			return errors.New("smbus: PEC mismatch")
+		}
+	}
+	copy(data[:], r[:l])
+	return nil
+}
+
+func fillBlock(data *[BlockMax + 2]byte, w []byte) error {
+	if len(w) > BlockMax {
+		return fmt.Errorf("smbus: block must be at most %d bytes", BlockMax)
+	}
+	data[0] = byte(len(w))
+	copy(data[1:], w)
+	return nil
+}
+
+func copyBlock(r []byte, data *[BlockMax + 2]byte) (int, error) {
+	n := int(data[0])
+	if n > BlockMax {
+		return 0, fmt.Errorf("smbus: invalid block length %d", n)
+	}
+	if n > len(r) {
+		return copy(r, data[1:1+n]), fmt.Errorf("smbus: buffer too short; need %d bytes", n)
+	}
+	return copy(r, data[1:1+n]), nil
+}
@@ -0,0 +1,240 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/physic"
+)
+
+func TestProtocol_String(t *testing.T) {
+	if s := BlockProcCall.String(); s != "BlockProcCall" {
+		t.Fatal(s)
+	}
+	if s := Protocol(6).String(); s != "Protocol(6)" {
+		t.Fatal(s)
+	}
+}
+
+func TestFunc_String(t *testing.T) {
+	if s := (FuncPEC | FuncQuick | 1).String(); s != "PEC|Quick|0x1" {
+		t.Fatal(s)
+	}
+	if s := Func(0).String(); s != "" {
+		t.Fatal(s)
+	}
+}
+
+func TestPEC(t *testing.T) {
+	// Standard check value of CRC-8/SMBUS.
+	if v := PEC([]byte("123456789")); v != 0xF4 {
+		t.Fatalf("0x%x", v)
+	}
+}
+
+func TestDev_emulated(t *testing.T) {
+	b := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x10, W: []byte{0x42}},
+			{Addr: 0x10, R: []byte{0x43}},
+			{Addr: 0x10, W: []byte{0x01, 0x02}},
+			{Addr: 0x10, W: []byte{0x01}, R: []byte{0x03}},
+			{Addr: 0x10, W: []byte{0x01, 0x34, 0x12}},
+			{Addr: 0x10, W: []byte{0x01}, R: []byte{0x78, 0x56}},
+			{Addr: 0x10, W: []byte{0x01, 0x34, 0x12}, R: []byte{0x78, 0x56}},
+			{Addr: 0x10, W: []byte{0x01, 0x02, 0xAA, 0xBB}},
+			{Addr: 0x10, W: []byte{0x01}, R: append([]byte{0x03, 1, 2, 3}, make([]byte, BlockMax-3)...)},
+			{Addr: 0x10, W: []byte{0x01, 0x01, 0xAA}, R: append([]byte{0x02, 4, 5}, make([]byte, BlockMax-2)...)},
+		},
+	}
+	d := Dev{Dev: i2c.Dev{Bus: &b, Addr: 0x10}}
+	if err := d.Quick(true); err == nil {
+		t.Fatal("quick can't be emulated")
+	}
+	if err := d.SendByte(0x42); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.ReceiveByte(); err != nil || v != 0x43 {
+		t.Fatal(v, err)
+	}
+	if err := d.WriteByteData(0x01, 0x02); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.ReadByteData(0x01); err != nil || v != 0x03 {
+		t.Fatal(v, err)
+	}
+	if err := d.WriteWordData(0x01, 0x1234); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.ReadWordData(0x01); err != nil || v != 0x5678 {
+		t.Fatal(v, err)
+	}
+	if v, err := d.ProcessCall(0x01, 0x1234); err != nil || v != 0x5678 {
+		t.Fatal(v, err)
+	}
+	if err := d.WriteBlockData(0x01, []byte{0xAA, 0xBB}); err != nil {
+		t.Fatal(err)
+	}
+	r := make([]byte, BlockMax)
+	if n, err := d.ReadBlockData(0x01, r); err != nil || n != 3 || !bytes.Equal(r[:n], []byte{1, 2, 3}) {
+		t.Fatal(n, err)
+	}
+	if n, err := d.BlockProcessCall(0x01, []byte{0xAA}, r); err != nil || n != 2 || !bytes.Equal(r[:n], []byte{4, 5}) {
+		t.Fatal(n, err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDev_emulated_PEC(t *testing.T) {
+	// Address 0x10 is 0x20 for write and 0x21 for read on the wire.
+	pecW := PEC([]byte{0x20, 0x01, 0x02})
+	pecR := PEC([]byte{0x20, 0x01, 0x21, 0x03})
+	pecB := PEC([]byte{0x20, 0x01, 0x21, 0x01, 0x09})
+	b := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x10, W: []byte{0x01, 0x02, pecW}},
+			{Addr: 0x10, W: []byte{0x01}, R: []byte{0x03, pecR}},
+			{Addr: 0x10, W: []byte{0x01}, R: []byte{0x03, pecR + 1}},
+			{Addr: 0x10, W: []byte{0x01}, R: append([]byte{0x01, 0x09, pecB}, make([]byte, BlockMax-1)...)},
+		},
+	}
+	d := Dev{Dev: i2c.Dev{Bus: &b, Addr: 0x10}, PEC: true}
+	if err := d.WriteByteData(0x01, 0x02); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.ReadByteData(0x01); err != nil || v != 0x03 {
+		t.Fatal(v, err)
+	}
+	if _, err := d.ReadByteData(0x01); err == nil {
+		t.Fatal("PEC mismatch")
+	}
+	r := make([]byte, 1)
+	if n, err := d.ReadBlockData(0x01, r); err != nil || n != 1 || r[0] != 0x09 {
+		t.Fatal(n, err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDev_Err(t *testing.T) {
+	b := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x10, W: []byte{0x01}, R: append([]byte{BlockMax + 1}, make([]byte, BlockMax)...)},
+			{Addr: 0x10, W: []byte{0x01}, R: append([]byte{0x02, 1, 2}, make([]byte, BlockMax-2)...)},
+		},
+		DontPanic: true,
+	}
+	d := Dev{Dev: i2c.Dev{Bus: &b, Addr: 0x10}}
+	if err := d.WriteBlockData(0x01, make([]byte, BlockMax+1)); err == nil {
+		t.Fatal("block too long")
+	}
+	if _, err := d.BlockProcessCall(0x01, make([]byte, BlockMax+1), nil); err == nil {
+		t.Fatal("block too long")
+	}
+	if _, err := d.ReadBlockData(0x01, nil); err == nil {
+		t.Fatal("invalid length")
+	}
+	if n, err := d.ReadBlockData(0x01, make([]byte, 1)); err == nil || n != 1 {
+		t.Fatal("buffer too short")
+	}
+	if _, err := d.ReadByteData(0x01); err == nil {
+		t.Fatal("playback is empty")
+	}
+	if err := d.emulate(false, 0, Protocol(6), &[BlockMax + 2]byte{}); err == nil {
+		t.Fatal("unknown protocol")
+	}
+}
+
+func TestDev_native(t *testing.T) {
+	n := &native{fn: FuncQuick | FuncReadWordData | FuncWriteBlockData}
+	d := Dev{Dev: i2c.Dev{Bus: n, Addr: 0x10}}
+	if err := d.Quick(true); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.ReadWordData(0x01); err != nil || v != 0x5678 {
+		t.Fatal(v, err)
+	}
+	if err := d.WriteBlockData(0x02, []byte{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []nativeOp{
+		{read: true, p: Quick},
+		{read: true, cmd: 0x01, p: WordData},
+		{cmd: 0x02, p: BlockData, data: []byte{2, 1, 2}},
+	}
+	if len(n.ops) != len(expected) {
+		t.Fatal(n.ops)
+	}
+	for i := range expected {
+		if n.ops[i].read != expected[i].read || n.ops[i].cmd != expected[i].cmd || n.ops[i].p != expected[i].p || !bytes.Equal(n.ops[i].data, expected[i].data) {
+			t.Fatalf("#%d: %v != %v", i, n.ops[i], expected[i])
+		}
+	}
+
+	// Not supported natively, so it falls back to Tx().
+	if _, err := d.ReadByteData(0x01); err == nil {
+		t.Fatal("Tx failed")
+	}
+	// PEC is not supported natively.
+	d.PEC = true
+	if _, err := d.ReadWordData(0x01); err == nil {
+		t.Fatal("Tx failed")
+	}
+	if len(n.ops) != 3 {
+		t.Fatal(n.ops)
+	}
+}
+
+//
+
+type nativeOp struct {
+	read bool
+	cmd  byte
+	p    Protocol
+	data []byte
+}
+
+// native implements Native.
+type native struct {
+	fn  Func
+	ops []nativeOp
+}
+
+func (n *native) String() string {
+	return "native"
+}
+
+func (n *native) Tx(addr uint16, w, r []byte) error {
+	return errors.New("not implemented")
+}
+
+func (n *native) SetSpeed(f physic.Frequency) error {
+	return nil
+}
+
+func (n *native) SMBusFunc() Func {
+	return n.fn
+}
+
+func (n *native) SMBusXfer(addr uint16, read bool, cmd byte, p Protocol, pec bool, data *[BlockMax + 2]byte) error {
+	op := nativeOp{read: read, cmd: cmd, p: p}
+	if !read && p == BlockData {
+		op.data = append([]byte{}, data[:1+data[0]]...)
+	}
+	n.ops = append(n.ops, op)
+	if read && p == WordData {
+		data[0] = 0x78
+		data[1] = 0x56
+	}
+	return nil
+}
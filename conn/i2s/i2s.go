@@ -2,10 +2,149 @@
 // Use of this source code is governed under the Apache License, Version 2.0
 // that can be found in the LICENSE file.
 
-// Package i2s will eventually define the API to communicate with devices over
-// the I²S protocol.
+// Package i2s defines the API to communicate with devices over the I²S
+// protocol.
 //
-// The protocol is meant to transfer audio.
+// The protocol is meant to transfer audio, for example from a digital
+// microphone or to a DAC.
+//
+// As described in https://periph.io/x/periph/conn#hdr-Concepts, periph.io uses
+// the concepts of Bus, Port and Conn.
+//
+// In the package i2s, 'Bus' is not exposed, as the protocol is point-to-point.
+//
+// Use Port.Connect() converts the uninitialized Port into a Conn.
+//
+// Samples are exchanged as int32, right aligned and sign extended to 32 bits.
+// When multiple channels are used, the samples are interleaved: left, right,
+// left, right, etc.
 //
 // See https://en.wikipedia.org/wiki/I%C2%B2S for more information.
 package i2s
+
+import (
+	"io"
+	"strconv"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/physic"
+)
+
+// Mode determines which side generates the clocks.
+type Mode int
+
+const (
+	// Master means the host generates the SCK (bit clock) and WS (word select)
+	// signals.
+	Master Mode = 0
+	// Slave means the SCK and WS signals are inputs, generated by the device or
+	// another host.
+	Slave Mode = 1
+)
+
+func (m Mode) String() string {
+	switch m {
+	case Master:
+		return "Master"
+	case Slave:
+		return "Slave"
+	default:
+		return "Mode(" + strconv.Itoa(int(m)) + ")"
+	}
+}
+
+// Conn defines the interface a concrete I²S driver must implement.
+//
+// Tx() exchanges samples encoded as little endian, with each sample using
+// (bits+7)/8 bytes.
+type Conn interface {
+	conn.Conn
+	// TxSamples streams the samples in w to the output while reading samples
+	// from the input into r.
+	//
+	// Either w or r can be nil. When both are specified, they must have the same
+	// length. When multiple channels are used, the length must be a multiple of
+	// the number of channels.
+	//
+	// Calling TxSamples() repeatedly streams continuously. The implementation
+	// may return before the last samples of w are sent and buffer the samples
+	// received between calls, so that there is no gap as long as the next call
+	// happens soon enough.
+	TxSamples(w, r []int32) error
+}
+
+// Port is the interface to be provided to device drivers.
+//
+// The device driver, that is the driver for the peripheral connected over
+// this port, calls Connect() to retrieve a configured connection as Conn.
+type Port interface {
+	String() string
+	// Connect sets the communication parameters of the connection for use by a
+	// device.
+	//
+	// The device driver must call this function exactly once.
+	//
+	// f is the sample rate, that is the number of samples per second per
+	// channel, e.g. 44.1kHz or 48kHz. The bit clock runs at f*bits*2.
+	//
+	// bits is the word size of a sample, e.g. 16, 24 or 32.
+	//
+	// channels is the number of channels, 1 for mono or 2 for stereo. In mono,
+	// only the left channel is used.
+	//
+	// mode determines if the host generates the clocks.
+	Connect(f physic.Frequency, bits, channels int, mode Mode) (Conn, error)
+}
+
+// PortCloser is an I²S port that can be closed.
+//
+// This interface is meant to be handled by the application.
+type PortCloser interface {
+	io.Closer
+	Port
+}
+
+// Pins defines the pins that an I²S port interconnect is using on the host.
+//
+// It is expected that a implementer of Conn also implement Pins but this is
+// not a requirement.
+type Pins interface {
+	// SCK returns the bit clock pin. It is an output in Master mode and an
+	// input in Slave mode.
+	SCK() gpio.PinIO
+	// WS returns the word select pin. It is an output in Master mode and an
+	// input in Slave mode.
+	WS() gpio.PinIO
+	// IN returns the data in pin.
+	IN() gpio.PinIn
+	// OUT returns the data out pin.
+	OUT() gpio.PinOut
+}
+
+// Encode packs samples into b as used by Conn.Tx().
+//
+// b must have a length of len(s)*((bits+7)/8).
+func Encode(b []byte, s []int32, bits int) {
+	n := (bits + 7) / 8
+	for i, v := range s {
+		for j := 0; j < n; j++ {
+			b[i*n+j] = byte(v >> uint(8*j))
+		}
+	}
+}
+
+// Decode unpacks samples from b as used by Conn.Tx() and sign extends them.
+//
+// s must have a length of len(b)/((bits+7)/8).
+func Decode(s []int32, b []byte, bits int) {
+	n := (bits + 7) / 8
+	shift := uint(32 - bits)
+	for i := range s {
+		var v uint32
+		for j := 0; j < n; j++ {
+			v |= uint32(b[i*n+j]) << uint(8*j)
+		}
+		s[i] = int32(v<<shift) >> shift
+	}
+}
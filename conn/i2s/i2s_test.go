@@ -0,0 +1,48 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package i2s
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMode_String(t *testing.T) {
+	if s := Master.String(); s != "Master" {
+		t.Fatal(s)
+	}
+	if s := Slave.String(); s != "Slave" {
+		t.Fatal(s)
+	}
+	if s := Mode(2).String(); s != "Mode(2)" {
+		t.Fatal(s)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	data := []struct {
+		bits    int
+		samples []int32
+		encoded []byte
+	}{
+		{8, []int32{1, -1, 127, -128}, []byte{0x01, 0xFF, 0x7F, 0x80}},
+		{16, []int32{0x1234, -2}, []byte{0x34, 0x12, 0xFE, 0xFF}},
+		{24, []int32{0x123456, -0x800000}, []byte{0x56, 0x34, 0x12, 0x00, 0x00, 0x80}},
+		{32, []int32{-0x80000000, 1}, []byte{0x00, 0x00, 0x00, 0x80, 0x01, 0x00, 0x00, 0x00}},
+	}
+	for i, line := range data {
+		b := make([]byte, len(line.encoded))
+		Encode(b, line.samples, line.bits)
+		if !bytes.Equal(b, line.encoded) {
+			t.Fatalf("#%d: %#v != %#v", i, b, line.encoded)
+		}
+		s := make([]int32, len(line.samples))
+		Decode(s, b, line.bits)
+		if !reflect.DeepEqual(s, line.samples) {
+			t.Fatalf("#%d: %#v != %#v", i, s, line.samples)
+		}
+	}
+}
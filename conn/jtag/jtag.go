@@ -2,8 +2,393 @@
 // Use of this source code is governed under the Apache License, Version 2.0
 // that can be found in the LICENSE file.
 
-// Package jtag will eventually define the API to communicate with devices over
-// the JTAG protocol.
+// Package jtag defines the API to communicate with devices over the JTAG
+// protocol.
+//
+// A JTAG adapter only knows how to clock bits on TMS and TDI while sampling
+// TDO; it implements Adapter. TAP wraps an Adapter to track the state of the
+// Test Access Port controllers of the chain and to shift data into the
+// instruction (IR) and data (DR) registers.
+//
+// All the devices of a chain share TCK and TMS so their TAP controllers are
+// always in the same state. TDI and TDO are daisy chained.
+//
+// Bits are shifted least significant bit first, both in the wire order and in
+// the byte buffers; bit i of a buffer is b[i/8]>>(i%8)&1.
 //
 // See https://en.wikipedia.org/wiki/JTAG for background information.
 package jtag
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+// State is one of the 16 states of the TAP controller.
+type State uint8
+
+// Valid states, as described in IEEE 1149.1.
+const (
+	TestLogicReset State = iota
+	RunTestIdle
+	SelectDRScan
+	CaptureDR
+	ShiftDR
+	Exit1DR
+	PauseDR
+	Exit2DR
+	UpdateDR
+	SelectIRScan
+	CaptureIR
+	ShiftIR
+	Exit1IR
+	PauseIR
+	Exit2IR
+	UpdateIR
+)
+
+const stateName = "TestLogicResetRunTestIdleSelectDRScanCaptureDRShiftDRExit1DRPauseDRExit2DRUpdateDRSelectIRScanCaptureIRShiftIRExit1IRPauseIRExit2IRUpdateIR"
+
+var stateIndex = [...]uint8{0, 14, 25, 37, 46, 53, 60, 67, 74, 82, 94, 103, 110, 117, 124, 131, 139}
+
+func (s State) String() string {
+	if s >= State(len(stateIndex)-1) {
+		return "State(" + strconv.Itoa(int(s)) + ")"
+	}
+	return stateName[stateIndex[s]:stateIndex[s+1]]
+}
+
+// Next returns the state the TAP controller transitions to on the rising edge
+// of TCK with TMS set to tms.
+func (s State) Next(tms gpio.Level) State {
+	if s >= State(len(transitions)) {
+		return s
+	}
+	if tms {
+		return transitions[s][1]
+	}
+	return transitions[s][0]
+}
+
+// Path returns the shortest TMS sequence to go from state s to state dst.
+//
+// The result is empty when s == dst. Use Path(TestLogicReset) as a way to
+// reset a chain in an unknown state since 5 clocks with TMS high always lead
+// to TestLogicReset.
+func (s State) Path(dst State) []gpio.Level {
+	if s >= State(len(transitions)) || dst >= State(len(transitions)) {
+		return nil
+	}
+	if dst == TestLogicReset {
+		// This is the only path that works from an unknown state.
+		return []gpio.Level{gpio.High, gpio.High, gpio.High, gpio.High, gpio.High}
+	}
+	// Breadth first search; the graph is tiny.
+	var prev [len(transitions)]State
+	var tms [len(transitions)]gpio.Level
+	var seen [len(transitions)]bool
+	seen[s] = true
+	queue := []State{s}
+	for len(queue) != 0 && !seen[dst] {
+		c := queue[0]
+		queue = queue[1:]
+		for _, l := range []gpio.Level{gpio.Low, gpio.High} {
+			if n := c.Next(l); !seen[n] {
+				seen[n] = true
+				prev[n] = c
+				tms[n] = l
+				queue = append(queue, n)
+			}
+		}
+	}
+	var out []gpio.Level
+	for c := dst; c != s; c = prev[c] {
+		out = append(out, tms[c])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// Adapter is the interface a JTAG adapter driver must implement.
+//
+// It is intentionally low level; use TAP to navigate the state machine.
+type Adapter interface {
+	String() string
+	// Clock runs n TCK cycles. For each cycle i, TMS is set to bit i of tms and
+	// TDI to bit i of tdi, then TDO is sampled into bit i of tdo before the
+	// rising edge of TCK.
+	//
+	// tdi and tdo can be nil, in which case TDI is kept low and TDO is ignored.
+	// Otherwise, the buffers must be at least (n+7)/8 bytes.
+	Clock(tms, tdi, tdo []byte, n int) error
+}
+
+// Pins defines the pins that a JTAG adapter interconnect is using on the
+// host.
+//
+// It is expected that a implementer of Adapter also implement Pins but this
+// is not a requirement.
+type Pins interface {
+	// TCK returns the test clock pin.
+	TCK() gpio.PinOut
+	// TMS returns the test mode select pin.
+	TMS() gpio.PinOut
+	// TDI returns the test data input pin, which is an output for the host.
+	TDI() gpio.PinOut
+	// TDO returns the test data output pin, which is an input for the host.
+	TDO() gpio.PinIn
+}
+
+// IDCode is the 32 bits value loaded into the data register of a device
+// after a reset.
+//
+// It is 0 for devices that do not implement the IDCODE register, as they
+// select BYPASS instead.
+type IDCode uint32
+
+// Version returns the 4 bits device version.
+func (i IDCode) Version() uint8 {
+	return uint8(i >> 28)
+}
+
+// Part returns the 16 bits part number.
+func (i IDCode) Part() uint16 {
+	return uint16(i >> 12)
+}
+
+// Manufacturer returns the 11 bits JEDEC manufacturer identity.
+//
+// The lower 7 bits are the identity code and the upper 4 bits the number of
+// continuation codes, i.e. the bank minus 1.
+func (i IDCode) Manufacturer() uint16 {
+	return uint16(i>>1) & 0x7FF
+}
+
+func (i IDCode) String() string {
+	if i == 0 {
+		return "BYPASS"
+	}
+	return fmt.Sprintf("0x%08X(version=%d, part=0x%04X, manufacturer=0x%03X)", uint32(i), i.Version(), i.Part(), i.Manufacturer())
+}
+
+// MaxDevices is the maximum number of devices Scan() looks for in a chain.
+const MaxDevices = 32
+
+// NewTAP returns a TAP that drives the chain via a.
+//
+// The chain is reset so the state is known.
+func NewTAP(a Adapter) (*TAP, error) {
+	t := &TAP{a: a}
+	if err := t.Reset(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// TAP drives the TAP controllers of a chain and tracks their state.
+//
+// It is safe for concurrent use.
+type TAP struct {
+	mu    sync.Mutex
+	a     Adapter
+	state State
+}
+
+func (t *TAP) String() string {
+	return t.a.String()
+}
+
+// State returns the current state of the TAP controllers.
+func (t *TAP) State() State {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state
+}
+
+// Reset moves the TAP controllers to TestLogicReset with 5 clocks with TMS
+// high, independently of their current state.
+//
+// It doesn't use TRST. After a reset, the devices select either their IDCODE
+// or BYPASS register as DR.
+func (t *TAP) Reset() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.move(t.state.Path(TestLogicReset), TestLogicReset)
+}
+
+// GoTo moves the TAP controllers to state s using the shortest path.
+func (t *TAP) GoTo(s State) error {
+	if s >= State(len(transitions)) {
+		return fmt.Errorf("jtag: invalid state %s", s)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.move(t.state.Path(s), s)
+}
+
+// Idle moves the TAP controllers to RunTestIdle and stays there for n clocks.
+//
+// This is used by devices that need clock cycles to execute an instruction.
+func (t *TAP) Idle(n int) error {
+	if n < 0 {
+		return errors.New("jtag: invalid number of clocks")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tms := t.state.Path(RunTestIdle)
+	tms = append(tms, make([]gpio.Level, n)...)
+	return t.move(tms, RunTestIdle)
+}
+
+// ShiftIR shifts bits from w into the instruction registers of the chain
+// while reading the bits shifted out into r, then ends in RunTestIdle.
+//
+// bits is the sum of the IR length of all the devices of the chain. The
+// first bits shifted end up in the device closest to TDO. Either w or r can
+// be nil; when w is nil, ones are shifted in, selecting BYPASS.
+func (t *TAP) ShiftIR(w, r []byte, bits int) error {
+	return t.shift(ShiftIR, w, r, bits)
+}
+
+// ShiftDR shifts bits from w into the data registers selected by the current
+// instructions while reading the bits shifted out into r, then ends in
+// RunTestIdle.
+//
+// Either w or r can be nil; when w is nil, zeros are shifted in.
+func (t *TAP) ShiftDR(w, r []byte, bits int) error {
+	return t.shift(ShiftDR, w, r, bits)
+}
+
+// Scan resets the chain and returns the IDCODE of each device, starting with
+// the one closest to TDO.
+//
+// Devices without an IDCODE register are returned as 0. Up to MaxDevices are
+// detected. An empty chain returns an error since it can't be distinguished
+// from TDO stuck high.
+func (t *TAP) Scan() ([]IDCode, error) {
+	if err := t.Reset(); err != nil {
+		return nil, err
+	}
+	// Shift ones in; once all the IDCODE have been read, the ones come out.
+	// 0xFFFFFFFF is not a valid IDCODE since bits 1~11 would be 0x7FF, which
+	// JEDEC reserves.
+	const bits = 32 * (MaxDevices + 1)
+	w := make([]byte, bits/8)
+	for i := range w {
+		w[i] = 0xFF
+	}
+	r := make([]byte, bits/8)
+	if err := t.ShiftDR(w, r, bits); err != nil {
+		return nil, err
+	}
+	var out []IDCode
+	for i := 0; i < bits; {
+		if r[i/8]&(1<<uint(i%8)) == 0 {
+			// The BYPASS register is 1 bit preloaded with 0.
+			out = append(out, 0)
+			i++
+			continue
+		}
+		if i+32 > bits {
+			break
+		}
+		var v uint32
+		for j := 0; j < 32; j++ {
+			if r[(i+j)/8]&(1<<uint((i+j)%8)) != 0 {
+				v |= 1 << uint(j)
+			}
+		}
+		if v == 0xFFFFFFFF {
+			if len(out) == 0 {
+				return nil, errors.New("jtag: no device found; is TDO stuck high?")
+			}
+			return out, nil
+		}
+		out = append(out, IDCode(v))
+		i += 32
+	}
+	return nil, fmt.Errorf("jtag: found more than %d devices; is TDO stuck low?", MaxDevices)
+}
+
+//
+
+// transitions is the TAP controller state machine, indexed by [state][TMS].
+var transitions = [...][2]State{
+	TestLogicReset: {RunTestIdle, TestLogicReset},
+	RunTestIdle:    {RunTestIdle, SelectDRScan},
+	SelectDRScan:   {CaptureDR, SelectIRScan},
+	CaptureDR:      {ShiftDR, Exit1DR},
+	ShiftDR:        {ShiftDR, Exit1DR},
+	Exit1DR:        {PauseDR, UpdateDR},
+	PauseDR:        {PauseDR, Exit2DR},
+	Exit2DR:        {ShiftDR, UpdateDR},
+	UpdateDR:       {RunTestIdle, SelectDRScan},
+	SelectIRScan:   {CaptureIR, TestLogicReset},
+	CaptureIR:      {ShiftIR, Exit1IR},
+	ShiftIR:        {ShiftIR, Exit1IR},
+	Exit1IR:        {PauseIR, UpdateIR},
+	PauseIR:        {PauseIR, Exit2IR},
+	Exit2IR:        {ShiftIR, UpdateIR},
+	UpdateIR:       {RunTestIdle, SelectDRScan},
+}
+
+// move clocks the TMS sequence and records the final state.
+func (t *TAP) move(tms []gpio.Level, end State) error {
+	if len(tms) == 0 {
+		return nil
+	}
+	if err := t.a.Clock(packLevels(tms), nil, nil, len(tms)); err != nil {
+		return err
+	}
+	t.state = end
+	return nil
+}
+
+// shift moves to state s, shifts the bits and moves to RunTestIdle.
+func (t *TAP) shift(s State, w, r []byte, bits int) error {
+	if bits <= 0 {
+		return errors.New("jtag: invalid number of bits")
+	}
+	l := (bits + 7) / 8
+	if (w != nil && len(w) < l) || (r != nil && len(r) < l) {
+		return fmt.Errorf("jtag: buffers must be at least %d bytes", l)
+	}
+	if w == nil && s == ShiftIR {
+		w = make([]byte, l)
+		for i := range w {
+			w[i] = 0xFF
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.move(t.state.Path(s), s); err != nil {
+		return err
+	}
+	// TMS is raised on the last bit to go to Exit1.
+	tms := make([]byte, l)
+	tms[(bits-1)/8] = 1 << uint((bits-1)%8)
+	if err := t.a.Clock(tms, w, r, bits); err != nil {
+		return err
+	}
+	t.state = s.Next(gpio.High)
+	return t.move(t.state.Path(RunTestIdle), RunTestIdle)
+}
+
+// packLevels packs levels as bits, LSB first.
+func packLevels(l []gpio.Level) []byte {
+	b := make([]byte, (len(l)+7)/8)
+	for i, v := range l {
+		if v {
+			b[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return b
+}
+
+var _ fmt.Stringer = &TAP{}
@@ -0,0 +1,172 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package jtag
+
+import (
+	"errors"
+	"testing"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+func TestState_String(t *testing.T) {
+	if s := TestLogicReset.String(); s != "TestLogicReset" {
+		t.Fatal(s)
+	}
+	if s := UpdateIR.String(); s != "UpdateIR" {
+		t.Fatal(s)
+	}
+	if s := ShiftDR.String(); s != "ShiftDR" {
+		t.Fatal(s)
+	}
+	if s := State(16).String(); s != "State(16)" {
+		t.Fatal(s)
+	}
+}
+
+func TestState_Next(t *testing.T) {
+	if s := State(16).Next(gpio.High); s != State(16) {
+		t.Fatal(s)
+	}
+	// Whatever the state, 5 clocks with TMS high lead to TestLogicReset.
+	for s := TestLogicReset; s <= UpdateIR; s++ {
+		c := s
+		for i := 0; i < 5; i++ {
+			c = c.Next(gpio.High)
+		}
+		if c != TestLogicReset {
+			t.Fatalf("%s: %s", s, c)
+		}
+	}
+}
+
+func TestState_Path(t *testing.T) {
+	for s := TestLogicReset; s <= UpdateIR; s++ {
+		for d := TestLogicReset; d <= UpdateIR; d++ {
+			c := s
+			p := s.Path(d)
+			for _, l := range p {
+				c = c.Next(l)
+			}
+			if c != d {
+				t.Fatalf("%s -> %s: %v ended in %s", s, d, p, c)
+			}
+			if len(p) > 8 {
+				t.Fatalf("%s -> %s: too long %v", s, d, p)
+			}
+		}
+	}
+	if p := RunTestIdle.Path(RunTestIdle); len(p) != 0 {
+		t.Fatal(p)
+	}
+	if p := RunTestIdle.Path(ShiftIR); len(p) != 4 {
+		t.Fatal(p)
+	}
+	if p := State(16).Path(RunTestIdle); p != nil {
+		t.Fatal(p)
+	}
+}
+
+func TestIDCode(t *testing.T) {
+	// ARM Cortex-M debug port.
+	i := IDCode(0x4BA00477)
+	if v := i.Version(); v != 4 {
+		t.Fatal(v)
+	}
+	if v := i.Part(); v != 0xBA00 {
+		t.Fatal(v)
+	}
+	if v := i.Manufacturer(); v != 0x23B {
+		t.Fatal(v)
+	}
+	if s := i.String(); s != "0x4BA00477(version=4, part=0xBA00, manufacturer=0x23B)" {
+		t.Fatal(s)
+	}
+	if s := IDCode(0).String(); s != "BYPASS" {
+		t.Fatal(s)
+	}
+}
+
+func TestTAP_Err(t *testing.T) {
+	a := &failAdapter{}
+	if _, err := NewTAP(a); err == nil {
+		t.Fatal("Clock failed")
+	}
+	a.ok = 1
+	tap, err := NewTAP(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := tap.String(); s != "fail" {
+		t.Fatal(s)
+	}
+	if err := tap.GoTo(State(16)); err == nil {
+		t.Fatal("invalid state")
+	}
+	if err := tap.GoTo(ShiftDR); err == nil {
+		t.Fatal("Clock failed")
+	}
+	if s := tap.State(); s != TestLogicReset {
+		t.Fatal(s)
+	}
+	if err := tap.Idle(-1); err == nil {
+		t.Fatal("invalid clocks")
+	}
+	if err := tap.ShiftDR(nil, nil, 0); err == nil {
+		t.Fatal("invalid bits")
+	}
+	if err := tap.ShiftIR(make([]byte, 1), nil, 9); err == nil {
+		t.Fatal("short buffer")
+	}
+	if err := tap.ShiftIR(nil, make([]byte, 1), 9); err == nil {
+		t.Fatal("short buffer")
+	}
+	if _, err := tap.Scan(); err == nil {
+		t.Fatal("Clock failed")
+	}
+}
+
+func TestTAP_Scan_Stuck(t *testing.T) {
+	// TDO stuck low looks like an infinite chain of BYPASS devices.
+	tap, err := NewTAP(&failAdapter{ok: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tap.Scan(); err == nil {
+		t.Fatal("too many devices")
+	}
+	// TDO stuck high looks like an empty chain.
+	if tap, err = NewTAP(&failAdapter{ok: -1, tdo: 0xFF}); err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := tap.Scan(); err == nil {
+		t.Fatal(ids)
+	}
+}
+
+//
+
+// failAdapter fails after ok successful calls; it never fails if ok is -1.
+type failAdapter struct {
+	ok  int
+	tdo byte
+}
+
+func (f *failAdapter) String() string {
+	return "fail"
+}
+
+func (f *failAdapter) Clock(tms, tdi, tdo []byte, n int) error {
+	if f.ok == 0 {
+		return errors.New("fail")
+	}
+	if f.ok > 0 {
+		f.ok--
+	}
+	for i := range tdo {
+		tdo[i] = f.tdo
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package jtagtest is meant to be used to test drivers over a simulated JTAG
+// chain.
+package jtagtest
+
+import (
+	"errors"
+	"sync"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/jtag"
+)
+
+// Register is a simulated data register.
+type Register struct {
+	Len   int    // Length in bits, between 1 and 64.
+	Value uint64 // Loaded on CaptureDR, updated on UpdateDR.
+}
+
+// Device is a simulated device on a chain.
+type Device struct {
+	// IRLen is the length of the instruction register in bits, between 2 and
+	// 32.
+	IRLen int
+	// IDCode is the value of the IDCODE register. If 0, the device doesn't
+	// implement IDCODE and selects BYPASS on reset.
+	IDCode jtag.IDCode
+	// IDCodeInstr is the instruction that selects the IDCODE register.
+	IDCodeInstr uint32
+	// Registers are the data registers selected by instructions. Instructions
+	// not listed here, IDCodeInstr excepted, select the 1 bit BYPASS register.
+	Registers map[uint32]*Register
+
+	// IR is the currently latched instruction.
+	IR uint32
+
+	ir  uint64 // IR shift register
+	dr  uint64 // DR shift register
+	rst bool   // IDCODE is selected since the last reset
+}
+
+// Chain implements jtag.Adapter and simulates the TAP controllers of a chain
+// of devices.
+//
+// Devices[0] is connected to TDI and the last device to TDO.
+type Chain struct {
+	sync.Mutex
+	Devices []*Device
+	// State is the state of the TAP controllers. It starts as
+	// jtag.TestLogicReset.
+	State jtag.State
+	// Clocks is the number of TCK cycles done.
+	Clocks int
+}
+
+func (c *Chain) String() string {
+	return "jtagtest"
+}
+
+// Clock implements jtag.Adapter.
+//
+// TDO reads high outside of the ShiftIR and ShiftDR states, as with a pull
+// up.
+func (c *Chain) Clock(tms, tdi, tdo []byte, n int) error {
+	l := (n + 7) / 8
+	if n < 0 || len(tms) < l || (tdi != nil && len(tdi) < l) || (tdo != nil && len(tdo) < l) {
+		return errors.New("jtagtest: buffers too short")
+	}
+	c.Lock()
+	defer c.Unlock()
+	for i := 0; i < n; i++ {
+		mask := byte(1) << uint(i%8)
+		in := tdi != nil && tdi[i/8]&mask != 0
+		out := c.tick(tms[i/8]&mask != 0, in)
+		if tdo != nil {
+			if out {
+				tdo[i/8] |= mask
+			} else {
+				tdo[i/8] &^= mask
+			}
+		}
+	}
+	return nil
+}
+
+//
+
+// tick runs the actions of the current state on the rising edge of TCK and
+// transitions to the next state. It returns the TDO level sampled before the
+// edge.
+func (c *Chain) tick(tms, tdi bool) bool {
+	c.Clocks++
+	out := true
+	switch c.State {
+	case jtag.TestLogicReset:
+		for _, d := range c.Devices {
+			d.reset()
+		}
+	case jtag.CaptureIR:
+		for _, d := range c.Devices {
+			// IEEE 1149.1 mandates the 2 LSB to be 01.
+			d.ir = 1
+		}
+	case jtag.ShiftIR:
+		out = tdi
+		for _, d := range c.Devices {
+			o := d.ir&1 != 0
+			d.ir >>= 1
+			if out {
+				d.ir |= 1 << uint(d.IRLen-1)
+			}
+			out = o
+		}
+	case jtag.UpdateIR:
+		for _, d := range c.Devices {
+			d.IR = uint32(d.ir)
+			d.rst = false
+		}
+	case jtag.CaptureDR:
+		for _, d := range c.Devices {
+			d.dr = 0
+			if d.idCodeSelected() {
+				d.dr = uint64(d.IDCode)
+			} else if r := d.Registers[d.IR]; r != nil {
+				d.dr = r.Value
+			}
+		}
+	case jtag.ShiftDR:
+		out = tdi
+		for _, d := range c.Devices {
+			o := d.dr&1 != 0
+			d.dr >>= 1
+			if out {
+				d.dr |= 1 << uint(d.drLen()-1)
+			}
+			out = o
+		}
+	case jtag.UpdateDR:
+		for _, d := range c.Devices {
+			if d.idCodeSelected() {
+				continue
+			}
+			if r := d.Registers[d.IR]; r != nil {
+				r.Value = d.dr
+			}
+		}
+	}
+	c.State = c.State.Next(gpio.Level(tms))
+	return out
+}
+
+func (d *Device) reset() {
+	d.IR = 0
+	for i := 0; i < d.IRLen; i++ {
+		d.IR |= 1 << uint(i)
+	}
+	d.rst = true
+}
+
+// idCodeSelected returns true if the IDCODE register is selected.
+func (d *Device) idCodeSelected() bool {
+	return d.IDCode != 0 && (d.rst || d.IR == d.IDCodeInstr)
+}
+
+// drLen returns the length of the selected data register, which is 1 for
+// BYPASS.
+func (d *Device) drLen() int {
+	if d.idCodeSelected() {
+		return 32
+	}
+	if r := d.Registers[d.IR]; r != nil {
+		return r.Len
+	}
+	return 1
+}
+
+var _ jtag.Adapter = &Chain{}
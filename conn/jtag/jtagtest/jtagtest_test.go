@@ -0,0 +1,134 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package jtagtest
+
+import (
+	"reflect"
+	"testing"
+
+	"periph.io/x/periph/conn/jtag"
+)
+
+func TestChain_Scan(t *testing.T) {
+	c := &Chain{
+		Devices: []*Device{
+			{IRLen: 4, IDCode: 0x4BA00477, IDCodeInstr: 0xE},
+			{IRLen: 5},
+			{IRLen: 5, IDCode: 0x06413041, IDCodeInstr: 0x1},
+		},
+		State: jtag.PauseDR,
+	}
+	tap, err := jtag.NewTAP(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.State != jtag.TestLogicReset || tap.State() != jtag.TestLogicReset {
+		t.Fatal(c.State, tap.State())
+	}
+	ids, err := tap.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The device closest to TDO comes first.
+	if expected := []jtag.IDCode{0x06413041, 0, 0x4BA00477}; !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("%v != %v", ids, expected)
+	}
+	if c.State != jtag.RunTestIdle || tap.State() != jtag.RunTestIdle {
+		t.Fatal(c.State, tap.State())
+	}
+}
+
+func TestChain_Scan_Empty(t *testing.T) {
+	tap, err := jtag.NewTAP(&Chain{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := tap.Scan(); err == nil {
+		t.Fatal(ids)
+	}
+}
+
+func TestChain_Shift(t *testing.T) {
+	r := &Register{Len: 12, Value: 0x123}
+	c := &Chain{
+		Devices: []*Device{
+			{IRLen: 4, IDCode: 0x4BA00477, IDCodeInstr: 0xE, Registers: map[uint32]*Register{0xA: r}},
+			{IRLen: 3},
+		},
+	}
+	tap, err := jtag.NewTAP(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first 3 bits go to the device closest to TDO, which gets BYPASS.
+	ir := []byte{0x7 | 0xA<<3}
+	read := make([]byte, 1)
+	if err := tap.ShiftIR(ir, read, 7); err != nil {
+		t.Fatal(err)
+	}
+	// Both devices captured 01.
+	if read[0] != 0x1|0x1<<3 {
+		t.Fatalf("0x%x", read[0])
+	}
+	if c.Devices[0].IR != 0xA || c.Devices[1].IR != 0x7 {
+		t.Fatal(c.Devices[0].IR, c.Devices[1].IR)
+	}
+	if c.State != jtag.RunTestIdle {
+		t.Fatal(c.State)
+	}
+
+	// 1 bit BYPASS followed by the 12 bits register.
+	w := []byte{0x78, 0x15}
+	read = make([]byte, 2)
+	if err := tap.ShiftDR(w, read, 13); err != nil {
+		t.Fatal(err)
+	}
+	if v := uint16(read[0]) | uint16(read[1])<<8; v != 0x123<<1 {
+		t.Fatalf("0x%x", v)
+	}
+	if r.Value != 0xABC {
+		t.Fatalf("0x%x", r.Value)
+	}
+
+	// Select IDCODE on the first device and BYPASS on the second.
+	if err := tap.ShiftIR([]byte{0x7 | 0xE<<3}, nil, 7); err != nil {
+		t.Fatal(err)
+	}
+	read = make([]byte, 5)
+	if err := tap.ShiftDR(nil, read, 33); err != nil {
+		t.Fatal(err)
+	}
+	v := uint64(read[0]) | uint64(read[1])<<8 | uint64(read[2])<<16 | uint64(read[3])<<24 | uint64(read[4])<<32
+	if v != 0x4BA00477<<1 {
+		t.Fatalf("0x%x", v)
+	}
+
+	before := c.Clocks
+	if err := tap.Idle(10); err != nil {
+		t.Fatal(err)
+	}
+	if c.Clocks-before != 10 {
+		t.Fatal(c.Clocks - before)
+	}
+	if err := tap.GoTo(jtag.PauseIR); err != nil {
+		t.Fatal(err)
+	}
+	if c.State != jtag.PauseIR || tap.State() != jtag.PauseIR {
+		t.Fatal(c.State, tap.State())
+	}
+}
+
+func TestChain_Clock_Err(t *testing.T) {
+	c := &Chain{}
+	if s := c.String(); s != "jtagtest" {
+		t.Fatal(s)
+	}
+	if err := c.Clock(nil, nil, nil, 1); err == nil {
+		t.Fatal("short buffer")
+	}
+	if err := c.Clock([]byte{0}, nil, nil, -1); err == nil {
+		t.Fatal("negative")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"log"
+	"os"
 
 	"periph.io/x/periph/conn/i2c"
 	"periph.io/x/periph/conn/i2c/i2creg"
@@ -92,3 +93,53 @@ func ExampleDev8_WriteStruct() {
 		log.Fatal(err)
 	}
 }
+
+func ExampleMap() {
+	// Make sure periph is initialized.
+	if _, err := host.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Open a connection, using I²C as an example:
+	b, err := i2creg.Open("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer b.Close()
+	c := &i2c.Dev{Bus: b, Addr: 0x68}
+
+	m, err := mmr.NewMap8(&mmr.Dev8{Conn: c, Order: binary.BigEndian}, []mmr.Register{
+		{Name: "WHO_AM_I", Addr: 0x75, Width: 8, Access: mmr.ReadOnly},
+		{
+			Name:  "PWR_MGMT_1",
+			Addr:  0x6B,
+			Width: 8,
+			Fields: []mmr.Field{
+				{Name: "CLKSEL", Shift: 0, Width: 3, Values: map[uint64]string{0: "Internal", 1: "Auto"}},
+				{Name: "SLEEP", Shift: 6, Width: 1},
+			},
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Buffer the modifications to write the register only once.
+	if err := m.SetCaching(true); err != nil {
+		log.Fatal(err)
+	}
+	if err := m.SetBool("PWR_MGMT_1", "SLEEP", false); err != nil {
+		log.Fatal(err)
+	}
+	if err := m.SetEnum("PWR_MGMT_1", "CLKSEL", "Auto"); err != nil {
+		log.Fatal(err)
+	}
+	if err := m.Flush(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Print all the registers.
+	if err := m.Dump(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -8,6 +8,9 @@
 // The protocol is defined two supported commands:
 //  - Write Address, Read Value
 //  - Write Address, Write Value
+//
+// Map builds on top of Dev8 and Dev16 to describe the registers and their
+// bitfields declaratively.
 package mmr
 
 import (
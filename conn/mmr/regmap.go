@@ -0,0 +1,531 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package mmr
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Access defines the access rights of a register or a field.
+type Access uint8
+
+// Valid Access values.
+const (
+	ReadWrite Access = 0
+	ReadOnly  Access = 1
+	WriteOnly Access = 2
+)
+
+func (a Access) String() string {
+	switch a {
+	case ReadWrite:
+		return "ReadWrite"
+	case ReadOnly:
+		return "ReadOnly"
+	case WriteOnly:
+		return "WriteOnly"
+	default:
+		return "Access(" + strconv.Itoa(int(a)) + ")"
+	}
+}
+
+// Field describes a bitfield in a register.
+type Field struct {
+	Name  string
+	Shift uint8 // Position of the least significant bit.
+	Width uint8 // Width in bits.
+	// Access further restricts the access rights of the register for this
+	// field.
+	Access Access
+	// Values optionally names the enumerated values of the field. It is used by
+	// GetEnum(), SetEnum() and Dump().
+	Values map[uint64]string
+}
+
+// Register describes a register and its fields.
+type Register struct {
+	Name   string
+	Addr   uint16
+	Width  uint8 // Width in bits; 8, 16, 32 or 64.
+	Access Access
+	// Reset is the value assumed for a WriteOnly register until it is written
+	// to. It is needed to update a field of a register that cannot be read.
+	Reset  uint64
+	Fields []Field
+}
+
+// NewMap8 returns a register map over a 8 bits address space.
+func NewMap8(d *Dev8, regs []Register) (*Map, error) {
+	for _, r := range regs {
+		if r.Addr > 0xFF {
+			return nil, fmt.Errorf("mmr: register %s address 0x%x doesn't fit 8 bits", r.Name, r.Addr)
+		}
+	}
+	m := &Map{
+		name: d.String,
+		read: func(addr uint16, width uint8) (uint64, error) {
+			switch width {
+			case 8:
+				v, err := d.ReadUint8(uint8(addr))
+				return uint64(v), err
+			case 16:
+				v, err := d.ReadUint16(uint8(addr))
+				return uint64(v), err
+			case 32:
+				v, err := d.ReadUint32(uint8(addr))
+				return uint64(v), err
+			default:
+				return d.ReadUint64(uint8(addr))
+			}
+		},
+		write: func(addr uint16, width uint8, v uint64) error {
+			switch width {
+			case 8:
+				return d.WriteUint8(uint8(addr), uint8(v))
+			case 16:
+				return d.WriteUint16(uint8(addr), uint16(v))
+			case 32:
+				return d.WriteUint32(uint8(addr), uint32(v))
+			default:
+				return d.WriteUint64(uint8(addr), v)
+			}
+		},
+	}
+	if err := m.init(regs); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewMap16 returns a register map over a 16 bits address space.
+func NewMap16(d *Dev16, regs []Register) (*Map, error) {
+	m := &Map{
+		name: d.String,
+		read: func(addr uint16, width uint8) (uint64, error) {
+			switch width {
+			case 8:
+				v, err := d.ReadUint8(addr)
+				return uint64(v), err
+			case 16:
+				v, err := d.ReadUint16(addr)
+				return uint64(v), err
+			case 32:
+				v, err := d.ReadUint32(addr)
+				return uint64(v), err
+			default:
+				return d.ReadUint64(addr)
+			}
+		},
+		write: func(addr uint16, width uint8, v uint64) error {
+			switch width {
+			case 8:
+				return d.WriteUint8(addr, uint8(v))
+			case 16:
+				return d.WriteUint16(addr, uint16(v))
+			case 32:
+				return d.WriteUint32(addr, uint32(v))
+			default:
+				return d.WriteUint64(addr, v)
+			}
+		},
+	}
+	if err := m.init(regs); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Map is a declarative register map.
+//
+// Registers and fields are accessed by name. Fields are updated with a
+// read-modify-write cycle of the register.
+//
+// When caching is enabled with SetCaching(true), a register is read from the
+// device only once and writes are deferred until Flush() is called. This
+// reduces the I/O when many fields of the same register are modified.
+//
+// It is safe for concurrent use.
+type Map struct {
+	name  func() string
+	read  func(addr uint16, width uint8) (uint64, error)
+	write func(addr uint16, width uint8, v uint64) error
+
+	mu      sync.Mutex
+	regs    []regState
+	byName  map[string]int
+	caching bool
+}
+
+func (m *Map) String() string {
+	return m.name()
+}
+
+// SetCaching enables or disables the cache.
+//
+// Disabling the cache flushes it first.
+func (m *Map) SetCaching(enable bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !enable {
+		if err := m.flush(); err != nil {
+			return err
+		}
+		m.invalidate()
+	}
+	m.caching = enable
+	return nil
+}
+
+// Flush writes the registers modified since the last flush to the device, in
+// address order.
+func (m *Map) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.flush()
+}
+
+// Invalidate discards the cache, including the pending writes.
+func (m *Map) Invalidate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.invalidate()
+}
+
+// ReadReg returns the value of a register.
+func (m *Map) ReadReg(reg string) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, err := m.reg(reg)
+	if err != nil {
+		return 0, err
+	}
+	if r.Access == WriteOnly {
+		return 0, fmt.Errorf("mmr: register %s is write only", reg)
+	}
+	return m.load(r)
+}
+
+// WriteReg writes the value of a register.
+func (m *Map) WriteReg(reg string, v uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, err := m.reg(reg)
+	if err != nil {
+		return err
+	}
+	if r.Access == ReadOnly {
+		return fmt.Errorf("mmr: register %s is read only", reg)
+	}
+	if v&^mask(r.Width) != 0 {
+		return fmt.Errorf("mmr: value 0x%x overflows register %s", v, reg)
+	}
+	return m.store(r, v)
+}
+
+// Get returns the value of a field.
+func (m *Map) Get(reg, field string) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, f, err := m.field(reg, field)
+	if err != nil {
+		return 0, err
+	}
+	if r.Access == WriteOnly || f.Access == WriteOnly {
+		return 0, fmt.Errorf("mmr: field %s.%s is write only", reg, field)
+	}
+	v, err := m.load(r)
+	if err != nil {
+		return 0, err
+	}
+	return v >> f.Shift & mask(f.Width), nil
+}
+
+// Set sets the value of a field.
+//
+// The other fields of the register are preserved.
+func (m *Map) Set(reg, field string, v uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, f, err := m.field(reg, field)
+	if err != nil {
+		return err
+	}
+	if r.Access == ReadOnly || f.Access == ReadOnly {
+		return fmt.Errorf("mmr: field %s.%s is read only", reg, field)
+	}
+	if v&^mask(f.Width) != 0 {
+		return fmt.Errorf("mmr: value 0x%x overflows field %s.%s", v, reg, field)
+	}
+	var old uint64
+	if r.Access == WriteOnly {
+		old = r.value
+	} else if old, err = m.load(r); err != nil {
+		return err
+	}
+	return m.store(r, old&^(mask(f.Width)<<f.Shift)|v<<f.Shift)
+}
+
+// GetBool returns the value of a 1 bit field.
+func (m *Map) GetBool(reg, field string) (bool, error) {
+	if err := m.checkWidth(reg, field); err != nil {
+		return false, err
+	}
+	v, err := m.Get(reg, field)
+	return v != 0, err
+}
+
+// SetBool sets the value of a 1 bit field.
+func (m *Map) SetBool(reg, field string, b bool) error {
+	if err := m.checkWidth(reg, field); err != nil {
+		return err
+	}
+	var v uint64
+	if b {
+		v = 1
+	}
+	return m.Set(reg, field, v)
+}
+
+// GetEnum returns the name of the value of a field, as defined in
+// Field.Values.
+func (m *Map) GetEnum(reg, field string) (string, error) {
+	v, err := m.Get(reg, field)
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	_, f, _ := m.field(reg, field)
+	m.mu.Unlock()
+	if s, ok := f.Values[v]; ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("mmr: field %s.%s has unknown value 0x%x", reg, field, v)
+}
+
+// SetEnum sets the value of a field by its name, as defined in Field.Values.
+func (m *Map) SetEnum(reg, field, value string) error {
+	m.mu.Lock()
+	_, f, err := m.field(reg, field)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for v, s := range f.Values {
+		if s == value {
+			return m.Set(reg, field, v)
+		}
+	}
+	return fmt.Errorf("mmr: field %s.%s has no value %q", reg, field, value)
+}
+
+// Dump writes a human readable description of all the registers and their
+// fields to w, in address order.
+//
+// The readable registers are read from the device, or from the cache when
+// enabled.
+func (m *Map) Dump(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l := 0
+	for i := range m.regs {
+		if n := len(m.regs[i].Name); n > l {
+			l = n
+		}
+		for _, f := range m.regs[i].Fields {
+			if n := len(f.Name) + 2; n > l {
+				l = n
+			}
+		}
+	}
+	for _, i := range m.order() {
+		r := &m.regs[i]
+		digits := int(r.Width / 4)
+		if r.Access == WriteOnly {
+			if _, err := fmt.Fprintf(w, "%-*s 0x%04X = (write only)\n", l, r.Name, r.Addr); err != nil {
+				return err
+			}
+			continue
+		}
+		v, err := m.load(r)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%-*s 0x%04X = 0x%0*X\n", l, r.Name, r.Addr, digits, v); err != nil {
+			return err
+		}
+		for _, f := range r.Fields {
+			bits := strconv.Itoa(int(f.Shift))
+			if f.Width > 1 {
+				bits = strconv.Itoa(int(f.Shift+f.Width-1)) + ":" + bits
+			}
+			if f.Access == WriteOnly {
+				if _, err := fmt.Fprintf(w, "  %-*s %-7s = (write only)\n", l-2, f.Name, "["+bits+"]"); err != nil {
+					return err
+				}
+				continue
+			}
+			fv := v >> f.Shift & mask(f.Width)
+			s := "0x" + strconv.FormatUint(fv, 16)
+			if n, ok := f.Values[fv]; ok {
+				s += " (" + n + ")"
+			}
+			if _, err := fmt.Fprintf(w, "  %-*s %-7s = %s\n", l-2, f.Name, "["+bits+"]", s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//
+
+// regState is a register and its cached value.
+type regState struct {
+	Register
+	valid bool   // value is the current value of the device.
+	dirty bool   // value must be written to the device.
+	value uint64 // Cached value.
+}
+
+func (m *Map) init(regs []Register) error {
+	m.regs = make([]regState, len(regs))
+	m.byName = make(map[string]int, len(regs))
+	for i, r := range regs {
+		if r.Name == "" {
+			return fmt.Errorf("mmr: register at 0x%x has no name", r.Addr)
+		}
+		if _, ok := m.byName[r.Name]; ok {
+			return fmt.Errorf("mmr: register %s is defined twice", r.Name)
+		}
+		if r.Width != 8 && r.Width != 16 && r.Width != 32 && r.Width != 64 {
+			return fmt.Errorf("mmr: register %s has invalid width %d", r.Name, r.Width)
+		}
+		if r.Access > WriteOnly {
+			return fmt.Errorf("mmr: register %s has invalid access %s", r.Name, r.Access)
+		}
+		names := map[string]bool{}
+		for _, f := range r.Fields {
+			if f.Name == "" || names[f.Name] {
+				return fmt.Errorf("mmr: register %s has an invalid field name %q", r.Name, f.Name)
+			}
+			names[f.Name] = true
+			if f.Width == 0 || int(f.Shift)+int(f.Width) > int(r.Width) {
+				return fmt.Errorf("mmr: field %s.%s doesn't fit the register", r.Name, f.Name)
+			}
+			if f.Access > WriteOnly || (f.Access != ReadWrite && r.Access != ReadWrite && f.Access != r.Access) {
+				return fmt.Errorf("mmr: field %s.%s has invalid access %s", r.Name, f.Name, f.Access)
+			}
+		}
+		m.byName[r.Name] = i
+		m.regs[i] = regState{Register: r, value: r.Reset}
+	}
+	return nil
+}
+
+func (m *Map) reg(name string) (*regState, error) {
+	i, ok := m.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("mmr: unknown register %s", name)
+	}
+	return &m.regs[i], nil
+}
+
+func (m *Map) field(reg, field string) (*regState, *Field, error) {
+	r, err := m.reg(reg)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range r.Fields {
+		if r.Fields[i].Name == field {
+			return r, &r.Fields[i], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("mmr: unknown field %s.%s", reg, field)
+}
+
+func (m *Map) checkWidth(reg, field string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, f, err := m.field(reg, field)
+	if err != nil {
+		return err
+	}
+	if f.Width != 1 {
+		return fmt.Errorf("mmr: field %s.%s is not 1 bit wide", reg, field)
+	}
+	return nil
+}
+
+// load returns the value of a readable register.
+func (m *Map) load(r *regState) (uint64, error) {
+	if m.caching && (r.valid || r.dirty) {
+		return r.value, nil
+	}
+	v, err := m.read(r.Addr, r.Width)
+	if err != nil {
+		return 0, err
+	}
+	r.value = v
+	r.valid = m.caching
+	return v, nil
+}
+
+// store writes a register or defers the write until flush.
+func (m *Map) store(r *regState, v uint64) error {
+	r.value = v
+	if m.caching {
+		r.dirty = true
+		return nil
+	}
+	return m.write(r.Addr, r.Width, v)
+}
+
+func (m *Map) flush() error {
+	for _, i := range m.order() {
+		r := &m.regs[i]
+		if !r.dirty {
+			continue
+		}
+		if err := m.write(r.Addr, r.Width, r.value); err != nil {
+			return err
+		}
+		r.dirty = false
+		r.valid = r.Access != WriteOnly
+	}
+	return nil
+}
+
+func (m *Map) invalidate() {
+	for i := range m.regs {
+		m.regs[i].valid = false
+		m.regs[i].dirty = false
+	}
+}
+
+// order returns the register indexes sorted by address.
+func (m *Map) order() []int {
+	out := make([]int, len(m.regs))
+	for i := range out {
+		out[i] = i
+	}
+	// Insertion sort; register maps are small and generally already sorted.
+	for i := 1; i < len(out); i++ {
+		for j := i; j > 0 && m.regs[out[j]].Addr < m.regs[out[j-1]].Addr; j-- {
+			out[j], out[j-1] = out[j-1], out[j]
+		}
+	}
+	return out
+}
+
+// mask returns a mask of the lower bits.
+func mask(bits uint8) uint64 {
+	if bits >= 64 {
+		return ^uint64(0)
+	}
+	return 1<<bits - 1
+}
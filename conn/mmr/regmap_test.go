@@ -0,0 +1,336 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package mmr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/conntest"
+)
+
+func TestAccess_String(t *testing.T) {
+	if s := WriteOnly.String(); s != "WriteOnly" {
+		t.Fatal(s)
+	}
+	if s := Access(3).String(); s != "Access(3)" {
+		t.Fatal(s)
+	}
+}
+
+func TestNewMap_Err(t *testing.T) {
+	d8 := &Dev8{Conn: &conntest.Discard{D: conn.Half}, Order: binary.BigEndian}
+	d16 := &Dev16{Conn: &conntest.Discard{D: conn.Half}, Order: binary.BigEndian}
+	data := [][]Register{
+		{{Name: "A", Addr: 0x100, Width: 8}},
+		{{Addr: 1, Width: 8}},
+		{{Name: "A", Addr: 1, Width: 8}, {Name: "A", Addr: 2, Width: 8}},
+		{{Name: "A", Addr: 1, Width: 12}},
+		{{Name: "A", Addr: 1, Width: 8, Access: 3}},
+		{{Name: "A", Addr: 1, Width: 8, Fields: []Field{{Shift: 0, Width: 1}}}},
+		{{Name: "A", Addr: 1, Width: 8, Fields: []Field{{Name: "F", Width: 1}, {Name: "F", Width: 1}}}},
+		{{Name: "A", Addr: 1, Width: 8, Fields: []Field{{Name: "F", Shift: 4, Width: 5}}}},
+		{{Name: "A", Addr: 1, Width: 8, Fields: []Field{{Name: "F", Width: 0}}}},
+		{{Name: "A", Addr: 1, Width: 8, Access: ReadOnly, Fields: []Field{{Name: "F", Width: 1, Access: WriteOnly}}}},
+	}
+	for i, regs := range data {
+		if _, err := NewMap8(d8, regs); err == nil {
+			t.Fatalf("#%d: expected failure", i)
+		}
+		if i == 0 {
+			continue
+		}
+		if _, err := NewMap16(d16, regs); err == nil {
+			t.Fatalf("#%d: expected failure", i)
+		}
+	}
+}
+
+func TestMap8(t *testing.T) {
+	c := &conntest.Playback{
+		Ops: []conntest.IO{
+			{W: []byte{0x75}, R: []byte{0x71}},
+			{W: []byte{0x6B}, R: []byte{0x41}},
+			{W: []byte{0x6B, 0x01}},
+			{W: []byte{0x6B}, R: []byte{0x01}},
+			{W: []byte{0x6B}, R: []byte{0x01}},
+			{W: []byte{0x6B, 0x03}},
+			{W: []byte{0x20, 0x12, 0x34}},
+			{W: []byte{0x6A, 0xC0}},
+			{W: []byte{0x6A, 0xC4}},
+		},
+		D: conn.Half,
+	}
+	m, err := NewMap8(&Dev8{Conn: c, Order: binary.BigEndian}, testRegs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := m.String(); s != "playback" {
+		t.Fatal(s)
+	}
+	if v, err := m.ReadReg("WHO_AM_I"); err != nil || v != 0x71 {
+		t.Fatal(v, err)
+	}
+	if err := m.SetBool("PWR_MGMT_1", "SLEEP", false); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := m.GetBool("PWR_MGMT_1", "SLEEP"); err != nil || b {
+		t.Fatal(b, err)
+	}
+	if err := m.SetEnum("PWR_MGMT_1", "CLKSEL", "PLL_Z"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteReg("OFFSET", 0x1234); err != nil {
+		t.Fatal(err)
+	}
+	// USER_CTRL is write only, so the Reset value is used.
+	if err := m.SetBool("USER_CTRL", "FIFO_EN", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetBool("USER_CTRL", "FIFO_RST", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMap8_Err(t *testing.T) {
+	c := &conntest.Playback{
+		Ops: []conntest.IO{
+			{W: []byte{0x6B}, R: []byte{0x08}},
+		},
+		D:         conn.Half,
+		DontPanic: true,
+	}
+	m, err := NewMap8(&Dev8{Conn: c, Order: binary.BigEndian}, testRegs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ReadReg("FOO"); err == nil {
+		t.Fatal("unknown register")
+	}
+	if _, err := m.ReadReg("USER_CTRL"); err == nil {
+		t.Fatal("write only")
+	}
+	if err := m.WriteReg("FOO", 0); err == nil {
+		t.Fatal("unknown register")
+	}
+	if err := m.WriteReg("WHO_AM_I", 0); err == nil {
+		t.Fatal("read only")
+	}
+	if err := m.WriteReg("PWR_MGMT_1", 0x100); err == nil {
+		t.Fatal("overflow")
+	}
+	if _, err := m.Get("PWR_MGMT_1", "FOO"); err == nil {
+		t.Fatal("unknown field")
+	}
+	if _, err := m.Get("FOO", "FOO"); err == nil {
+		t.Fatal("unknown register")
+	}
+	if _, err := m.Get("USER_CTRL", "FIFO_EN"); err == nil {
+		t.Fatal("write only")
+	}
+	if err := m.Set("PWR_MGMT_1", "FOO", 0); err == nil {
+		t.Fatal("unknown field")
+	}
+	if err := m.Set("WHO_AM_I", "ID", 0); err == nil {
+		t.Fatal("read only")
+	}
+	if err := m.Set("PWR_MGMT_1", "CLKSEL", 8); err == nil {
+		t.Fatal("overflow")
+	}
+	if _, err := m.GetBool("PWR_MGMT_1", "CLKSEL"); err == nil {
+		t.Fatal("not 1 bit")
+	}
+	if err := m.SetBool("PWR_MGMT_1", "CLKSEL", true); err == nil {
+		t.Fatal("not 1 bit")
+	}
+	if _, err := m.GetEnum("USER_CTRL", "FIFO_EN"); err == nil {
+		t.Fatal("write only")
+	}
+	if err := m.SetEnum("PWR_MGMT_1", "FOO", "PLL_Z"); err == nil {
+		t.Fatal("unknown field")
+	}
+	if err := m.SetEnum("PWR_MGMT_1", "CLKSEL", "FOO"); err == nil {
+		t.Fatal("unknown value")
+	}
+	// CLKSEL=0 isn't named.
+	if _, err := m.GetEnum("PWR_MGMT_1", "CLKSEL"); err == nil {
+		t.Fatal("unknown value")
+	}
+	// Playback is exhausted.
+	if _, err := m.ReadReg("WHO_AM_I"); err == nil {
+		t.Fatal("read failure")
+	}
+	if err := m.Set("PWR_MGMT_1", "CLKSEL", 1); err == nil {
+		t.Fatal("read failure")
+	}
+	if err := m.Dump(&bytes.Buffer{}); err == nil {
+		t.Fatal("read failure")
+	}
+}
+
+func TestMap16_cache(t *testing.T) {
+	c := &conntest.Playback{
+		Ops: []conntest.IO{
+			// Read once, then 2 fields modified with a single write.
+			{W: []byte{0x00, 0x6B}, R: []byte{0x40}},
+			{W: []byte{0x00, 0x20, 0x12, 0x34}},
+			{W: []byte{0x00, 0x6A, 0x84}},
+			{W: []byte{0x00, 0x6B, 0x01}},
+			// Cache disabled.
+			{W: []byte{0x00, 0x6B}, R: []byte{0x02}},
+		},
+		D: conn.Half,
+	}
+	m, err := NewMap16(&Dev16{Conn: c, Order: binary.BigEndian}, testRegs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetCaching(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetBool("PWR_MGMT_1", "SLEEP", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Set("PWR_MGMT_1", "CLKSEL", 1); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := m.Get("PWR_MGMT_1", "CLKSEL"); err != nil || v != 1 {
+		t.Fatal(v, err)
+	}
+	if err := m.SetBool("USER_CTRL", "FIFO_RST", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteReg("OFFSET", 0x1234); err != nil {
+		t.Fatal(err)
+	}
+	// Flushed in address order.
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// Cached.
+	if v, err := m.ReadReg("PWR_MGMT_1"); err != nil || v != 1 {
+		t.Fatal(v, err)
+	}
+	// Discarded.
+	if err := m.SetBool("PWR_MGMT_1", "SLEEP", true); err != nil {
+		t.Fatal(err)
+	}
+	m.Invalidate()
+	if err := m.SetCaching(false); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := m.ReadReg("PWR_MGMT_1"); err != nil || v != 2 {
+		t.Fatal(v, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMap_Dump(t *testing.T) {
+	c := &conntest.Playback{
+		Ops: []conntest.IO{
+			{W: []byte{0x20}, R: []byte{0xAB, 0xCD}},
+			{W: []byte{0x6B}, R: []byte{0x41}},
+			{W: []byte{0x75}, R: []byte{0x71}},
+		},
+		D: conn.Half,
+	}
+	m, err := NewMap8(&Dev8{Conn: c, Order: binary.BigEndian}, testRegs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bytes.Buffer{}
+	if err := m.Dump(&b); err != nil {
+		t.Fatal(err)
+	}
+	expected := "" +
+		"OFFSET     0x0020 = 0xABCD\n" +
+		"USER_CTRL  0x006A = (write only)\n" +
+		"PWR_MGMT_1 0x006B = 0x41\n" +
+		"  CLKSEL   [2:0]   = 0x1 (PLL_X)\n" +
+		"  SLEEP    [6]     = 0x1\n" +
+		"  RESET    [7]     = (write only)\n" +
+		"WHO_AM_I   0x0075 = 0x71\n" +
+		"  ID       [7:0]   = 0x71\n"
+	if s := b.String(); s != expected {
+		t.Fatalf("%q\n%s", s, s)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMap_Dump_Write_Err(t *testing.T) {
+	c := &conntest.Playback{
+		Ops: []conntest.IO{
+			{W: []byte{0x20}, R: []byte{0xAB, 0xCD}},
+			{W: []byte{0x6B}, R: []byte{0x41}},
+		},
+		D: conn.Half,
+	}
+	m, err := NewMap8(&Dev8{Conn: c, Order: binary.BigEndian}, testRegs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		if err := m.SetCaching(true); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Dump(&failAfter{n: i}); err == nil {
+			t.Fatalf("#%d: expected failure", i)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//
+
+var testRegs = []Register{
+	{Name: "WHO_AM_I", Addr: 0x75, Width: 8, Access: ReadOnly, Fields: []Field{{Name: "ID", Width: 8}}},
+	{
+		Name:  "PWR_MGMT_1",
+		Addr:  0x6B,
+		Width: 8,
+		Fields: []Field{
+			{Name: "CLKSEL", Shift: 0, Width: 3, Values: map[uint64]string{1: "PLL_X", 3: "PLL_Z"}},
+			{Name: "SLEEP", Shift: 6, Width: 1},
+			{Name: "RESET", Shift: 7, Width: 1, Access: WriteOnly},
+		},
+	},
+	{
+		Name:   "USER_CTRL",
+		Addr:   0x6A,
+		Width:  8,
+		Access: WriteOnly,
+		Reset:  0x80,
+		Fields: []Field{
+			{Name: "FIFO_RST", Shift: 2, Width: 1},
+			{Name: "FIFO_EN", Shift: 6, Width: 1},
+		},
+	},
+	{Name: "OFFSET", Addr: 0x20, Width: 16},
+}
+
+// failAfter fails after n writes.
+type failAfter struct {
+	n int
+}
+
+func (f *failAfter) Write(p []byte) (int, error) {
+	if f.n == 0 {
+		return 0, errors.New("injected")
+	}
+	f.n--
+	return len(p), nil
+}
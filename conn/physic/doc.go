@@ -6,4 +6,12 @@
 // units.
 //
 // This includes temperature, humidity, pressure, tension, current, etc.
+//
+// Each unit type implements flag.Value via Set() and
+// encoding.TextUnmarshaler via UnmarshalText(), so it can be used directly as
+// a command line flag or in a configuration file. The value is a decimal
+// number, followed by an optional S.I. prefix and then the unit, e.g. "2.2kΩ"
+// or "100mA". The accepted S.I. prefixes are "n", "u" or "µ", "m", "c", "h",
+// "k", "M", "G" and "T". The unit may be omitted. A value that doesn't fit in
+// the type returns an error.
 package physic
@@ -5,7 +5,9 @@
 package physic_test
 
 import (
+	"flag"
 	"fmt"
+	"log"
 	"time"
 
 	"periph.io/x/periph/conn/physic"
@@ -89,6 +91,28 @@ func ExampleFrequency_Duration() {
 	// 1µs
 }
 
+func ExampleFrequency_Set() {
+	var f physic.Frequency
+	if err := f.Set("400kHz"); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(f)
+	// Output:
+	// 400kHz
+}
+
+func ExampleFrequency_flag() {
+	hz := physic.MegaHertz
+	fs := flag.NewFlagSet("example", flag.ExitOnError)
+	fs.Var(&hz, "hz", "SPI port speed")
+	if err := fs.Parse([]string{"-hz", "1.5MHz"}); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(hz)
+	// Output:
+	// 1.500MHz
+}
+
 func ExamplePeriodToFrequency() {
 	fmt.Println(physic.PeriodToFrequency(time.Microsecond))
 	fmt.Println(physic.PeriodToFrequency(time.Minute))
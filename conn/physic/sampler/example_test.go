@@ -0,0 +1,71 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sampler_test
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"periph.io/x/periph/conn/onewire/onewirereg"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/physic/sampler"
+	"periph.io/x/periph/devices/ds18b20"
+	"periph.io/x/periph/host"
+	"periph.io/x/periph/host/sysfs"
+)
+
+func Example() {
+	// Make sure periph is initialized.
+	if _, err := host.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Use onewirereg 1-wire bus registry to find the first available 1-wire
+	// bus.
+	bus, err := onewirereg.Open("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer bus.Close()
+	addrs, err := bus.Search(false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var probes []physic.SenseEnv
+	for _, a := range addrs {
+		d, err := ds18b20.New(bus, a, 10)
+		if err != nil {
+			log.Fatal(err)
+		}
+		probes = append(probes, d)
+	}
+	var zones []physic.SenseEnv
+	for _, t := range sysfs.ThermalSensors {
+		zones = append(zones, t)
+	}
+
+	s, err := sampler.New(
+		// All the probes on the 1-wire bus convert at once.
+		sampler.Group{
+			Sensors:  probes,
+			Interval: 10 * time.Second,
+			Convert:  func() error { return ds18b20.ConvertAll(bus, 10) },
+		},
+		sampler.Group{Sensors: zones, Interval: time.Second},
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer s.Halt()
+	go func() {
+		for err := range s.Errors() {
+			log.Print(err)
+		}
+	}()
+	for smpl := range s.Samples() {
+		fmt.Printf("%s %s: %s\n", smpl.T.Format("15:04:05.000"), smpl.Sensor, smpl.Env.Temperature)
+	}
+}
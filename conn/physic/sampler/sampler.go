@@ -0,0 +1,293 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package sampler schedules reads of physic.SenseEnv sensors.
+//
+// Use New() to sample several sensors in lockstep, possibly at different
+// intervals per group of sensors, and receive timestamped samples and errors
+// through channels.
+//
+// Drivers that do not have a native continuous mode can implement
+// SenseContinuous() with Continuous.
+package sampler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+)
+
+// Sample is one reading of a sensor.
+type Sample struct {
+	// Sensor is the sensor that was read.
+	Sensor physic.SenseEnv
+	// Env is the value read. Only the fields supported by the sensor are set.
+	Env physic.Env
+	// T is the time at which the read completed.
+	T time.Time
+}
+
+func (s *Sample) String() string {
+	return fmt.Sprintf("%s: %s %s %s", s.Sensor, s.Env.Temperature, s.Env.Pressure, s.Env.Humidity)
+}
+
+// Error is a failed read.
+type Error struct {
+	// Sensor is the sensor that failed, or nil when Group.Convert failed.
+	Sensor physic.SenseEnv
+	// Err is the error returned by the sensor or by Group.Convert.
+	Err error
+	// T is the time at which the error occurred.
+	T time.Time
+}
+
+func (e *Error) Error() string {
+	if e.Sensor == nil {
+		return "sampler: convert: " + e.Err.Error()
+	}
+	return fmt.Sprintf("sampler: %s: %v", e.Sensor, e.Err)
+}
+
+// LastSenser is implemented by sensors which conversion can be triggered for
+// a whole bus at once, e.g. via ds18b20.ConvertAll(). SenseLast() returns the
+// result of the last conversion without starting a new one.
+type LastSenser interface {
+	physic.SenseEnv
+	SenseLast(e *physic.Env) error
+}
+
+// Group is a set of sensors sampled in lockstep.
+type Group struct {
+	// Sensors are read one after the other at each tick.
+	Sensors []physic.SenseEnv
+	// Interval is the time between two ticks. If reading the sensors takes
+	// longer than Interval, ticks are skipped.
+	Interval time.Duration
+	// Convert is optional. When set, it is called at each tick before reading
+	// the sensors, and the sensors implementing LastSenser are read with
+	// SenseLast() instead of Sense().
+	//
+	// This is meant for devices that block on conversion, e.g.
+	//   func() error { return ds18b20.ConvertAll(bus, 10) }
+	// converts all the DS18B20 on the bus once instead of once per device.
+	Convert func() error
+}
+
+// Sampler reads groups of sensors at their interval.
+//
+// Each group is read in its own goroutine, so a group blocked on conversion
+// doesn't delay the others.
+type Sampler struct {
+	samples chan Sample
+	errs    chan error
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	mu     sync.Mutex
+	halted bool
+}
+
+// New starts sampling the groups.
+//
+// The first reads happen right away. The channel returned by Samples() must
+// be drained to not stall the sampling. Call Halt() to stop.
+func New(groups ...Group) (*Sampler, error) {
+	if len(groups) == 0 {
+		return nil, errors.New("sampler: no group")
+	}
+	for i := range groups {
+		if len(groups[i].Sensors) == 0 {
+			return nil, fmt.Errorf("sampler: group %d has no sensor", i)
+		}
+		if groups[i].Interval <= 0 {
+			return nil, fmt.Errorf("sampler: group %d has invalid interval %s", i, groups[i].Interval)
+		}
+	}
+	s := &Sampler{
+		samples: make(chan Sample),
+		errs:    make(chan error, errBufferSize),
+		stop:    make(chan struct{}),
+	}
+	for i := range groups {
+		g := groups[i]
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.run(&g)
+		}()
+	}
+	return s, nil
+}
+
+// Samples returns the channel on which the samples are sent.
+//
+// It is closed by Halt().
+func (s *Sampler) Samples() <-chan Sample {
+	return s.samples
+}
+
+// Errors returns the channel on which the errors are sent, as *Error.
+//
+// Errors are dropped when the channel is not drained fast enough, so that a
+// failing sensor doesn't stall the sampling. It is closed by Halt().
+func (s *Sampler) Errors() <-chan error {
+	return s.errs
+}
+
+// Halt stops the sampling and closes the channels.
+//
+// It doesn't halt the sensors.
+func (s *Sampler) Halt() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.halted {
+		return nil
+	}
+	s.halted = true
+	close(s.stop)
+	s.wg.Wait()
+	close(s.samples)
+	close(s.errs)
+	return nil
+}
+
+// Continuous implements physic.SenseEnv.SenseContinuous() on top of
+// physic.SenseEnv.Sense() for drivers without a native continuous mode.
+//
+// Embed it in the device struct, call Start() from SenseContinuous() and
+// Stop() from Halt(). The zero value is ready to use.
+type Continuous struct {
+	mu   sync.Mutex
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Start starts reading s at interval and returns the channel on which the
+// values are sent.
+//
+// A previous continuous sensing is stopped first. The channel is closed on
+// Stop(). A failed read is logged and skipped.
+func (c *Continuous) Start(s physic.SenseEnv, interval time.Duration) (<-chan physic.Env, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("sampler: invalid interval %s", interval)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopLocked()
+	sensing := make(chan physic.Env)
+	stop := make(chan struct{})
+	c.stop = stop
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer close(sensing)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			// Do one initial sensing right away.
+			e := physic.Env{}
+			if err := s.Sense(&e); err != nil {
+				log.Printf("%s: failed to sense: %v", s, err)
+			} else {
+				select {
+				case sensing <- e:
+				case <-stop:
+					return
+				}
+			}
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	return sensing, nil
+}
+
+// Stop stops the continuous sensing started with Start(), if any, and waits
+// for the channel to be closed.
+func (c *Continuous) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopLocked()
+}
+
+//
+
+// errBufferSize is the number of errors buffered before they are dropped.
+const errBufferSize = 16
+
+// run reads the sensors of g at each tick until s.stop is closed.
+func (s *Sampler) run(g *Group) {
+	t := time.NewTicker(g.Interval)
+	defer t.Stop()
+	for {
+		if !s.round(g) {
+			return
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// round does one read of all the sensors of g. It returns false when the
+// sampling is stopped.
+func (s *Sampler) round(g *Group) bool {
+	useLast := false
+	if g.Convert != nil {
+		if err := g.Convert(); err != nil {
+			s.sendErr(&Error{Err: err, T: time.Now()})
+			return true
+		}
+		useLast = true
+	}
+	for _, sensor := range g.Sensors {
+		smpl := Sample{Sensor: sensor}
+		var err error
+		if l, ok := sensor.(LastSenser); ok && useLast {
+			err = l.SenseLast(&smpl.Env)
+		} else {
+			err = sensor.Sense(&smpl.Env)
+		}
+		smpl.T = time.Now()
+		if err != nil {
+			s.sendErr(&Error{Sensor: sensor, Err: err, T: smpl.T})
+			continue
+		}
+		select {
+		case s.samples <- smpl:
+		case <-s.stop:
+			return false
+		}
+	}
+	return true
+}
+
+// sendErr sends err unless the channel is full.
+func (s *Sampler) sendErr(err error) {
+	select {
+	case s.errs <- err:
+	default:
+	}
+}
+
+func (c *Continuous) stopLocked() {
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+		c.wg.Wait()
+	}
+}
+
+var _ fmt.Stringer = &Sample{}
+var _ error = &Error{}
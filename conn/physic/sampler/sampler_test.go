@@ -0,0 +1,264 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sampler
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+)
+
+func TestNew_errors(t *testing.T) {
+	if _, err := New(); err == nil {
+		t.Fatal("no group")
+	}
+	if _, err := New(Group{Interval: time.Second}); err == nil {
+		t.Fatal("no sensor")
+	}
+	if _, err := New(Group{Sensors: []physic.SenseEnv{&fakeSensor{}}}); err == nil {
+		t.Fatal("no interval")
+	}
+}
+
+func TestSampler(t *testing.T) {
+	a := &fakeSensor{name: "a", temp: physic.ZeroCelsius}
+	b := &fakeSensor{name: "b", temp: physic.ZeroCelsius + physic.Celsius}
+	start := time.Now()
+	s, err := New(Group{Sensors: []physic.SenseEnv{a, b}, Interval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The sensors are read in lockstep, in order.
+	for i := 0; i < 3; i++ {
+		for _, f := range []*fakeSensor{a, b} {
+			smpl := <-s.Samples()
+			if smpl.Sensor != f || smpl.Env.Temperature != f.temp {
+				t.Fatal(i, smpl.String())
+			}
+			if smpl.T.Before(start) {
+				t.Fatal("invalid timestamp")
+			}
+			start = smpl.T
+		}
+	}
+	if err := s.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-s.Samples(); ok {
+		t.Fatal("channel must be closed")
+	}
+	if _, ok := <-s.Errors(); ok {
+		t.Fatal("channel must be closed")
+	}
+}
+
+func TestSampler_Convert(t *testing.T) {
+	a := &fakeSensor{name: "a", temp: physic.ZeroCelsius}
+	l := &fakeLastSensor{fakeSensor{name: "l"}}
+	converted := 0
+	var mu sync.Mutex
+	g := Group{
+		Sensors:  []physic.SenseEnv{l, a},
+		Interval: time.Millisecond,
+		Convert: func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			converted++
+			if converted == 2 {
+				return errors.New("bus failure")
+			}
+			l.mu.Lock()
+			l.temp = physic.Temperature(converted) * physic.Kelvin
+			l.mu.Unlock()
+			return nil
+		},
+	}
+	s, err := New(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if smpl := <-s.Samples(); smpl.Sensor != l || smpl.Env.Temperature != physic.Kelvin {
+		t.Fatal(smpl.String())
+	}
+	if smpl := <-s.Samples(); smpl.Sensor != a {
+		t.Fatal(smpl.String())
+	}
+	// The second conversion failed so the round was skipped.
+	if smpl := <-s.Samples(); smpl.Sensor != l || smpl.Env.Temperature != 3*physic.Kelvin {
+		t.Fatal(smpl.String())
+	}
+	err = <-s.Errors()
+	if e, ok := err.(*Error); !ok || e.Sensor != nil || err.Error() != "sampler: convert: bus failure" {
+		t.Fatal(err)
+	}
+	if err := s.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	// Sense() is never called on the LastSenser.
+	if l.senseCalled {
+		t.Fatal("Sense() must not be called")
+	}
+}
+
+func TestSampler_errors(t *testing.T) {
+	a := &fakeSensor{name: "a", err: errors.New("oops")}
+	b := &fakeSensor{name: "b", temp: physic.ZeroCelsius}
+	s, err := New(Group{Sensors: []physic.SenseEnv{a, b}, Interval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The failure of a doesn't prevent b from being read.
+	if smpl := <-s.Samples(); smpl.Sensor != b {
+		t.Fatal(smpl.String())
+	}
+	err = <-s.Errors()
+	if e, ok := err.(*Error); !ok || e.Sensor != a || e.T.IsZero() || err.Error() != "sampler: a: oops" {
+		t.Fatal(err)
+	}
+	// Errors are dropped when not drained, without stalling the sampling.
+	for i := 0; i < 2*errBufferSize; i++ {
+		<-s.Samples()
+	}
+	if err := s.Halt(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSampler_groups(t *testing.T) {
+	fast := &fakeSensor{name: "fast"}
+	slow := &fakeSensor{name: "slow"}
+	s, err := New(
+		Group{Sensors: []physic.SenseEnv{fast}, Interval: time.Millisecond},
+		Group{Sensors: []physic.SenseEnv{slow}, Interval: time.Hour},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[physic.SenseEnv]int{}
+	for i := 0; i < 10; i++ {
+		counts[(<-s.Samples()).Sensor]++
+	}
+	if err := s.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if counts[slow] != 1 || counts[fast] != 9 {
+		t.Fatal(counts)
+	}
+}
+
+func TestContinuous(t *testing.T) {
+	f := &fakeSensor{name: "a", temp: physic.ZeroCelsius}
+	var c Continuous
+	if _, err := c.Start(f, 0); err == nil {
+		t.Fatal("invalid interval")
+	}
+	ch, err := c.Start(f, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if e := <-ch; e.Temperature != physic.ZeroCelsius {
+			t.Fatal(e.Temperature)
+		}
+	}
+	// Restarting stops the previous one.
+	ch2, err := c.Start(f, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-ch; ok {
+		t.Fatal("channel must be closed")
+	}
+	<-ch2
+	c.Stop()
+	c.Stop()
+	if _, ok := <-ch2; ok {
+		t.Fatal("channel must be closed")
+	}
+}
+
+func TestContinuous_error(t *testing.T) {
+	f := &fakeSensor{name: "a", err: errors.New("oops")}
+	var c Continuous
+	ch, err := c.Start(f, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The failed reads are skipped.
+	for {
+		f.mu.Lock()
+		called := f.senseCalled
+		if called {
+			f.err = nil
+			f.temp = physic.ZeroCelsius
+		}
+		f.mu.Unlock()
+		if called {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if e, ok := <-ch; !ok || e.Temperature != physic.ZeroCelsius {
+		t.Fatal(e, ok)
+	}
+	c.Stop()
+	if _, ok := <-ch; ok {
+		t.Fatal("channel must be closed")
+	}
+}
+
+//
+
+type fakeSensor struct {
+	name        string
+	mu          sync.Mutex
+	temp        physic.Temperature
+	err         error
+	senseCalled bool
+}
+
+func (f *fakeSensor) String() string {
+	return f.name
+}
+
+func (f *fakeSensor) Halt() error {
+	return nil
+}
+
+func (f *fakeSensor) Sense(e *physic.Env) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.senseCalled = true
+	if f.err != nil {
+		return f.err
+	}
+	e.Temperature = f.temp
+	return nil
+}
+
+func (f *fakeSensor) SenseContinuous(interval time.Duration) (<-chan physic.Env, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeSensor) Precision(e *physic.Env) {
+	e.Temperature = physic.Kelvin
+}
+
+type fakeLastSensor struct {
+	fakeSensor
+}
+
+func (f *fakeLastSensor) SenseLast(e *physic.Env) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e.Temperature = f.temp
+	return nil
+}
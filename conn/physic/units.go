@@ -5,7 +5,9 @@
 package physic
 
 import (
+	"errors"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -54,6 +56,36 @@ func (a Angle) String() string {
 	}
 }
 
+// Set sets the Angle to the value represented by s, in degree "°". S.I.
+// prefixes are not accepted since degree is not a S.I. unit.
+//
+// It implements flag.Value.
+func (a *Angle) Set(s string) error {
+	d, err := parseNumber(s, "°", false)
+	if err != nil {
+		return err
+	}
+	// Convert to nano degree first, then to nano radian in two steps to not
+	// overflow. Large values are only converted as integer degree.
+	var q, r int64
+	if n, err := dtoi(d, 9); err == nil {
+		q = n / 1000000000
+		r = n % 1000000000
+	} else if q, err = dtoi(d, 0); err != nil {
+		return err
+	}
+	if q > maxInt64/int64(Degree) || q < -maxInt64/int64(Degree) {
+		return errOverflow
+	}
+	*a = Angle(q*int64(Degree) + roundDiv(r*int64(Degree), 1000000000))
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (a *Angle) UnmarshalText(text []byte) error {
+	return a.Set(string(text))
+}
+
 const (
 	NanoRadian  Angle = 1
 	MicroRadian Angle = 1000 * NanoRadian
@@ -78,6 +110,23 @@ func (d Distance) String() string {
 	return nanoAsString(int64(d)) + "m"
 }
 
+// Set sets the Distance to the value represented by s, in "m".
+//
+// It implements flag.Value.
+func (d *Distance) Set(s string) error {
+	v, err := parseUnit(s, "m", 9)
+	if err != nil {
+		return err
+	}
+	*d = Distance(v)
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Distance) UnmarshalText(text []byte) error {
+	return d.Set(string(text))
+}
+
 const (
 	NanoMetre  Distance = 1
 	MicroMetre Distance = 1000 * NanoMetre
@@ -107,6 +156,23 @@ func (e ElectricCurrent) String() string {
 	return nanoAsString(int64(e)) + "A"
 }
 
+// Set sets the ElectricCurrent to the value represented by s, in "A".
+//
+// It implements flag.Value.
+func (e *ElectricCurrent) Set(s string) error {
+	v, err := parseUnit(s, "A", 9)
+	if err != nil {
+		return err
+	}
+	*e = ElectricCurrent(v)
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *ElectricCurrent) UnmarshalText(text []byte) error {
+	return e.Set(string(text))
+}
+
 const (
 	NanoAmpere  ElectricCurrent = 1
 	MicroAmpere ElectricCurrent = 1000 * NanoAmpere
@@ -125,6 +191,23 @@ func (e ElectricPotential) String() string {
 	return nanoAsString(int64(e)) + "V"
 }
 
+// Set sets the ElectricPotential to the value represented by s, in "V".
+//
+// It implements flag.Value.
+func (e *ElectricPotential) Set(s string) error {
+	v, err := parseUnit(s, "V", 9)
+	if err != nil {
+		return err
+	}
+	*e = ElectricPotential(v)
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *ElectricPotential) UnmarshalText(text []byte) error {
+	return e.Set(string(text))
+}
+
 const (
 	// Volt is W/A, kg⋅m²/s³/A.
 	NanoVolt  ElectricPotential = 1
@@ -145,6 +228,23 @@ func (e ElectricResistance) String() string {
 	return nanoAsString(int64(e)) + "Ω"
 }
 
+// Set sets the ElectricResistance to the value represented by s, in "Ω".
+//
+// It implements flag.Value.
+func (e *ElectricResistance) Set(s string) error {
+	v, err := parseUnit(s, "Ω", 9)
+	if err != nil {
+		return err
+	}
+	*e = ElectricResistance(v)
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *ElectricResistance) UnmarshalText(text []byte) error {
+	return e.Set(string(text))
+}
+
 const (
 	// Ohm is V/A, kg⋅m²/s³/A².
 	NanoOhm  ElectricResistance = 1
@@ -170,6 +270,23 @@ func (f Force) String() string {
 	return nanoAsString(int64(f)) + "N"
 }
 
+// Set sets the Force to the value represented by s, in "N".
+//
+// It implements flag.Value.
+func (f *Force) Set(s string) error {
+	v, err := parseUnit(s, "N", 9)
+	if err != nil {
+		return err
+	}
+	*f = Force(v)
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (f *Force) UnmarshalText(text []byte) error {
+	return f.Set(string(text))
+}
+
 const (
 	// Newton is kg⋅m/s².
 	NanoNewton  Force = 1
@@ -198,6 +315,23 @@ func (f Frequency) String() string {
 	return microAsString(int64(f)) + "Hz"
 }
 
+// Set sets the Frequency to the value represented by s, in "Hz".
+//
+// It implements flag.Value.
+func (f *Frequency) Set(s string) error {
+	v, err := parseUnit(s, "Hz", 6)
+	if err != nil {
+		return err
+	}
+	*f = Frequency(v)
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (f *Frequency) UnmarshalText(text []byte) error {
+	return f.Set(string(text))
+}
+
 // Duration returns the duration of one cycle at this frequency.
 func (f Frequency) Duration() time.Duration {
 	return time.Second * time.Duration(Hertz) / time.Duration(f)
@@ -230,6 +364,23 @@ func (m Mass) String() string {
 	return nanoAsString(int64(m)) + "g"
 }
 
+// Set sets the Mass to the value represented by s, in "g".
+//
+// It implements flag.Value.
+func (m *Mass) Set(s string) error {
+	v, err := parseUnit(s, "g", 9)
+	if err != nil {
+		return err
+	}
+	*m = Mass(v)
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (m *Mass) UnmarshalText(text []byte) error {
+	return m.Set(string(text))
+}
+
 const (
 	NanoGram  Mass = 1
 	MicroGram Mass = 1000 * NanoGram
@@ -261,6 +412,23 @@ func (p Pressure) String() string {
 	return nanoAsString(int64(p)) + "Pa"
 }
 
+// Set sets the Pressure to the value represented by s, in "Pa".
+//
+// It implements flag.Value.
+func (p *Pressure) Set(s string) error {
+	v, err := parseUnit(s, "Pa", 9)
+	if err != nil {
+		return err
+	}
+	*p = Pressure(v)
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (p *Pressure) UnmarshalText(text []byte) error {
+	return p.Set(string(text))
+}
+
 const (
 	// Pascal is N/m², kg/m/s².
 	NanoPascal  Pressure = 1
@@ -289,6 +457,31 @@ func (r RelativeHumidity) String() string {
 	return strconv.Itoa(int(r)/10) + "." + strconv.Itoa(frac) + "%rH"
 }
 
+// Set sets the RelativeHumidity to the value represented by s, in "%rH". S.I.
+// prefixes are not accepted.
+//
+// It implements flag.Value.
+func (r *RelativeHumidity) Set(s string) error {
+	d, err := parseNumber(s, "%rH", false)
+	if err != nil {
+		return err
+	}
+	v, err := dtoi(d, 5)
+	if err != nil {
+		return err
+	}
+	if v > 2147483647 || v < -2147483648 {
+		return errOverflow
+	}
+	*r = RelativeHumidity(v)
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (r *RelativeHumidity) UnmarshalText(text []byte) error {
+	return r.Set(string(text))
+}
+
 const (
 	TenthMicroRH RelativeHumidity = 1                 // 0.00001%rH
 	MicroRH      RelativeHumidity = 10 * TenthMicroRH // 0.0001%rH
@@ -307,6 +500,23 @@ func (s Speed) String() string {
 	return nanoAsString(int64(s)) + "m/s"
 }
 
+// Set sets the Speed to the value represented by s, in "m/s".
+//
+// It implements flag.Value.
+func (sp *Speed) Set(s string) error {
+	v, err := parseUnit(s, "m/s", 9)
+	if err != nil {
+		return err
+	}
+	*sp = Speed(v)
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (sp *Speed) UnmarshalText(text []byte) error {
+	return sp.Set(string(text))
+}
+
 const (
 	// MetrePerSecond is m/s.
 	NanoMetrePerSecond  Speed = 1
@@ -335,6 +545,46 @@ func (t Temperature) String() string {
 	return nanoAsString(int64(t-ZeroCelsius)) + "°C"
 }
 
+// Set sets the Temperature to the value represented by s, in "°C", "K" or
+// "°F". If the unit is omitted, it is assumed to be "°C", to match String().
+//
+// It implements flag.Value.
+func (t *Temperature) Set(s string) error {
+	s = strings.TrimSpace(s)
+	var v int64
+	var err error
+	switch {
+	case strings.HasSuffix(s, "K"):
+		v, err = parseUnit(s, "K", 9)
+	case strings.HasSuffix(s, "°F"):
+		if v, err = parseUnit(s, "°F", 9); err == nil {
+			// Convert to nano Kelvin as (F + 459.67) * 5 / 9 without overflowing.
+			if v > maxInt64-459670000000 {
+				return errOverflow
+			}
+			v += 459670000000
+			v = v/9*5 + roundDiv(v%9*5, 9)
+		}
+	default:
+		if v, err = parseUnit(s, "°C", 9); err == nil {
+			if v > maxInt64-int64(ZeroCelsius) {
+				return errOverflow
+			}
+			v += int64(ZeroCelsius)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	*t = Temperature(v)
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (t *Temperature) UnmarshalText(text []byte) error {
+	return t.Set(string(text))
+}
+
 const (
 	NanoKelvin  Temperature = 1
 	MicroKelvin Temperature = 1000 * NanoKelvin
@@ -470,3 +720,168 @@ func microAsString(v int64) string {
 	}
 	return sign + strconv.Itoa(base) + "." + prefixZeros(3, frac) + unit
 }
+
+const maxInt64 = 9223372036854775807
+
+var (
+	errOverflow      = errors.New("physic: value overflows int64")
+	errNoNumber      = errors.New("physic: no number found")
+	errTooManyDigits = errors.New("physic: too many digits")
+)
+
+// decimal is a decimal number as parsed from a string.
+//
+// Its value is base * 10^exp, negated if neg is true.
+type decimal struct {
+	neg  bool
+	base uint64
+	exp  int
+}
+
+// parseUnit parses s as a decimal number, an optional S.I. prefix and an
+// optional unit and returns the value in unit scaled by 10^scale.
+func parseUnit(s, unit string, scale int) (int64, error) {
+	d, err := parseNumber(s, unit, true)
+	if err != nil {
+		return 0, err
+	}
+	return dtoi(d, scale)
+}
+
+// parseNumber parses s as a decimal number followed by the optional unit.
+//
+// If prefix is true, a S.I. prefix is accepted between the number and the
+// unit. Spaces are accepted around the number and before the prefix.
+func parseNumber(s, unit string, prefix bool) (decimal, error) {
+	s = strings.TrimSpace(s)
+	d, n, err := atod(s)
+	if err != nil {
+		return d, errors.New(err.Error() + " in " + strconv.Quote(s))
+	}
+	rest := strings.TrimLeft(s[n:], " ")
+	rest = strings.TrimSuffix(rest, unit)
+	if rest == "" {
+		return d, nil
+	}
+	if prefix {
+		if e, ok := siPrefixes[rest]; ok {
+			d.exp += e
+			return d, nil
+		}
+	}
+	return d, errors.New("physic: unknown unit " + strconv.Quote(rest) + " in " + strconv.Quote(s) + "; expected " + strconv.Quote(unit))
+}
+
+// siPrefixes are the accepted S.I. prefixes with their power of 10.
+var siPrefixes = map[string]int{
+	"n": -9,
+	"u": -6,
+	"µ": -6,
+	"m": -3,
+	"c": -2,
+	"h": 2,
+	"k": 3,
+	"M": 6,
+	"G": 9,
+	"T": 12,
+}
+
+// atod parses the decimal number at the start of s and returns the number of
+// bytes consumed.
+func atod(s string) (decimal, int, error) {
+	var d decimal
+	i := 0
+	if i < len(s) && (s[i] == '-' || s[i] == '+') {
+		d.neg = s[i] == '-'
+		i++
+	}
+	digits := 0
+	dot := false
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '.' {
+			if dot {
+				break
+			}
+			dot = true
+			continue
+		}
+		if c < '0' || c > '9' {
+			break
+		}
+		digits++
+		if d.base == 0 && c == '0' {
+			// Leading zero.
+			if dot {
+				d.exp--
+			}
+			continue
+		}
+		if d.base > (1<<64-1)/10-9 {
+			// Too many significant digits; the remaining fractional digits are
+			// dropped.
+			if !dot {
+				return d, i, errTooManyDigits
+			}
+			continue
+		}
+		d.base = d.base*10 + uint64(c-'0')
+		if dot {
+			d.exp--
+		}
+	}
+	if digits == 0 {
+		return d, i, errNoNumber
+	}
+	return d, i, nil
+}
+
+// dtoi converts d scaled by 10^scale into an int64, rounding to the nearest
+// integer.
+func dtoi(d decimal, scale int) (int64, error) {
+	max := uint64(maxInt64)
+	if d.neg {
+		max++
+	}
+	v := d.base
+	e := d.exp + scale
+	for ; e > 0 && v != 0; e-- {
+		if v > max/10 {
+			return 0, errOverflow
+		}
+		v *= 10
+	}
+	if e < 0 {
+		if e < -19 {
+			v = 0
+		} else {
+			p := uint64(1)
+			for ; e < 0; e++ {
+				p *= 10
+			}
+			r := v % p
+			v /= p
+			if r >= p-r {
+				v++
+			}
+		}
+	}
+	if v > max {
+		return 0, errOverflow
+	}
+	if d.neg {
+		if v == max {
+			return -maxInt64 - 1, nil
+		}
+		return -int64(v), nil
+	}
+	return int64(v), nil
+}
+
+// roundDiv returns n/d rounded to the nearest integer.
+func roundDiv(n, d int64) int64 {
+	if n < 0 {
+		return -((-n + d/2) / d)
+	}
+	return (n + d/2) / d
+}
@@ -291,3 +291,266 @@ func BenchmarkCelsiusFloatg(b *testing.B) {
 		buf.Reset()
 	}
 }
+
+func TestAngle_Set(t *testing.T) {
+	data := []struct {
+		in       string
+		expected Angle
+	}{
+		{"0", 0},
+		{"1°", Degree},
+		{"-1°", -Degree},
+		{"180°", 180 * Degree},
+		{"57.296°", 1000003876 * NanoRadian},
+		{"0.001°", 17453 * NanoRadian},
+		{"528460276054°", 9223372036831345822 * NanoRadian},
+	}
+	for i, line := range data {
+		var a Angle
+		if err := a.Set(line.in); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if a != line.expected {
+			t.Fatalf("#%d: Set(%q) = %d != %d", i, line.in, int64(a), int64(line.expected))
+		}
+	}
+	var a Angle
+	for _, in := range []string{"", "°", "1k°", "1rad", "600000000000°", "-600000000000°"} {
+		if err := a.Set(in); err == nil {
+			t.Fatalf("Set(%q) should have failed", in)
+		}
+	}
+}
+
+func TestDistance_Set(t *testing.T) {
+	data := []struct {
+		in       string
+		expected Distance
+	}{
+		{"1m", Metre},
+		{"1", Metre},
+		{"1mm", MilliMetre},
+		{"2.54cm", Inch},
+		{"1.609km", 1609 * Metre},
+		{"1 km", KiloMetre},
+		{" 10nm ", 10 * NanoMetre},
+		{"1.5nm", 2 * NanoMetre},
+		{"1.4nm", NanoMetre},
+		{"-1.5nm", -2 * NanoMetre},
+		{"0.0000000001m", 0},
+		{"-9.223372036854775808Gm", -9223372036854775807 - 1},
+		{"9.223372036854775807Gm", 9223372036854775807},
+		{"+3µm", 3 * MicroMetre},
+		{"3um", 3 * MicroMetre},
+		{"00001.000m", Metre},
+		{".5m", 500 * MilliMetre},
+	}
+	for i, line := range data {
+		var d Distance
+		if err := d.Set(line.in); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if d != line.expected {
+			t.Fatalf("#%d: Set(%q) = %d != %d", i, line.in, int64(d), int64(line.expected))
+		}
+	}
+}
+
+func TestDistance_Set_error(t *testing.T) {
+	data := []string{
+		"",
+		"m",
+		"-",
+		".",
+		"1x",
+		"1kmm",
+		"1mV",
+		"9.3Gm",
+		"-9.3Gm",
+		"10000000000000000000000000m",
+		"1Tm",
+	}
+	for _, in := range data {
+		var d Distance
+		if err := d.Set(in); err == nil {
+			t.Fatalf("Set(%q) should have failed; got %d", in, int64(d))
+		}
+	}
+}
+
+func TestElectricPotential_Set(t *testing.T) {
+	var e ElectricPotential
+	if err := e.Set("3.3V"); err != nil || e != 3300*MilliVolt {
+		t.Fatal(e, err)
+	}
+}
+
+func TestFrequency_Set(t *testing.T) {
+	data := []struct {
+		in       string
+		expected Frequency
+	}{
+		{"100kHz", 100 * KiloHertz},
+		{"400k", 400 * KiloHertz},
+		{"400000", 400 * KiloHertz},
+		{"1.5MHz", 1500 * KiloHertz},
+		{"1mHz", MilliHertz},
+		{"1uHz", MicroHertz},
+		{"9.2THz", 9200 * GigaHertz},
+	}
+	for i, line := range data {
+		var f Frequency
+		if err := f.Set(line.in); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if f != line.expected {
+			t.Fatalf("#%d: Set(%q) = %d != %d", i, line.in, int64(f), int64(line.expected))
+		}
+	}
+	var f Frequency
+	if err := f.Set("10THz"); err == nil {
+		t.Fatal("overflow")
+	}
+	if err := f.Set("1nHz"); err != nil || f != 0 {
+		t.Fatal(f, err)
+	}
+}
+
+func TestPressure_Set(t *testing.T) {
+	var p Pressure
+	if err := p.Set("1013hPa"); err != nil || p != 101300*Pascal {
+		t.Fatal(p, err)
+	}
+	if err := p.UnmarshalText([]byte("1kPa")); err != nil || p != KiloPascal {
+		t.Fatal(p, err)
+	}
+}
+
+func TestRelativeHumidity_Set(t *testing.T) {
+	var r RelativeHumidity
+	if err := r.Set("50.6%rH"); err != nil || r != 506000*MicroRH {
+		t.Fatal(r, err)
+	}
+	if err := r.Set("-50.1"); err != nil || r != -501000*MicroRH {
+		t.Fatal(r, err)
+	}
+	if err := r.Set("50k%rH"); err == nil {
+		t.Fatal("prefix is not supported")
+	}
+	if err := r.Set("100000%rH"); err == nil {
+		t.Fatal("overflow")
+	}
+}
+
+func TestTemperature_Set(t *testing.T) {
+	data := []struct {
+		in       string
+		expected Temperature
+	}{
+		{"0°C", ZeroCelsius},
+		{"25.5°C", ZeroCelsius + 25500*MilliCelsius},
+		{"25.5", ZeroCelsius + 25500*MilliCelsius},
+		{"-273.150°C", 0},
+		{"0K", 0},
+		{"1mK", MilliKelvin},
+		{"32°F", ZeroCelsius},
+		{"212°F", ZeroCelsius + 100*Celsius},
+		{"-459.67°F", 0},
+	}
+	for i, line := range data {
+		var v Temperature
+		if err := v.Set(line.in); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if v != line.expected {
+			t.Fatalf("#%d: Set(%q) = %d != %d", i, line.in, int64(v), int64(line.expected))
+		}
+	}
+	var v Temperature
+	for _, in := range []string{"°C", "9.223372G°C", "9.223372G°F", "1xK"} {
+		if err := v.Set(in); err == nil {
+			t.Fatalf("Set(%q) should have failed", in)
+		}
+	}
+}
+
+// TestRoundTrip ensures that parsing the output of String() returns a value
+// that is formatted the same way.
+func TestRoundTrip(t *testing.T) {
+	values := []int64{
+		0, 1, -1, 999, 1000, 1001, 123456, -123456, 1000000, 1234567, 999999999,
+		1000000000, 1609344000000, 273150000000, -273150000000, 1234567890123456,
+		9223372036854775807, -9223372036854775807,
+	}
+	for _, v := range values {
+		units := []interface {
+			String() string
+			Set(string) error
+		}{
+			new(Distance), new(ElectricCurrent), new(ElectricPotential),
+			new(ElectricResistance), new(Force), new(Frequency), new(Mass),
+			new(Pressure), new(Speed), new(Temperature),
+		}
+		for _, u := range units {
+			s := fmt.Sprint(setRaw(u, v))
+			if err := u.Set(s); err != nil {
+				t.Fatalf("%T(%d): Set(%q): %v", u, v, s, err)
+			}
+			if s2 := u.String(); s2 != s {
+				t.Fatalf("%T(%d): %q != %q", u, v, s, s2)
+			}
+		}
+	}
+	// Angle.String() uses a fixed number of digits.
+	for _, v := range []Angle{0, Degree, -Degree / 2, 10 * Degree, 100 * Degree, 1000 * Degree, Pi, Radian, -Radian} {
+		s := v.String()
+		if err := v.Set(s); err != nil {
+			t.Fatal(err)
+		}
+		if s2 := v.String(); s2 != s {
+			t.Fatalf("%q != %q", s, s2)
+		}
+	}
+	for _, v := range []int32{0, 1000 * int32(MicroRH), 506000 * int32(MicroRH), -501000 * int32(MicroRH), 1000 * int32(PercentRH)} {
+		r := RelativeHumidity(v)
+		s := r.String()
+		if err := r.Set(s); err != nil {
+			t.Fatal(err)
+		}
+		if s2 := r.String(); s2 != s {
+			t.Fatalf("%q != %q", s, s2)
+		}
+	}
+}
+
+func setRaw(u interface{}, v int64) fmt.Stringer {
+	switch u := u.(type) {
+	case *Angle:
+		*u = Angle(v)
+	case *Distance:
+		*u = Distance(v)
+	case *ElectricCurrent:
+		*u = ElectricCurrent(v)
+	case *ElectricPotential:
+		*u = ElectricPotential(v)
+	case *ElectricResistance:
+		*u = ElectricResistance(v)
+	case *Force:
+		*u = Force(v)
+	case *Frequency:
+		*u = Frequency(v)
+	case *Mass:
+		*u = Mass(v)
+	case *Pressure:
+		*u = Pressure(v)
+	case *Speed:
+		*u = Speed(v)
+	case *Temperature:
+		// Keep it positive, to not overflow when converting to Celsius.
+		if v < 0 {
+			v = -v
+		}
+		*u = Temperature(v)
+	}
+	return u.(fmt.Stringer)
+}
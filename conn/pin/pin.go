@@ -28,7 +28,7 @@ var (
 )
 
 // Pin is the minimal common interface shared between gpio.PinIO and
-// analog.PinIO.
+// analog.PinADC.
 type Pin interface {
 	conn.Resource
 	// Name returns the name of the pin.
@@ -103,9 +103,7 @@ func All() []*Ref {
 	defer mu.Unlock()
 	out := make([]*Ref, 0, len(byName))
 	for _, v := range byName {
-		r := &Ref{Name: v.Name, Aliases: make([]string, len(v.Aliases)), Number: v.Number, Open: v.Open}
-		copy(r.Aliases, v.Aliases)
-		out = insertRef(out, r)
+		out = insertRef(out, copyRef(v))
 	}
 	return out
 }
@@ -150,6 +148,13 @@ func Register(name string, aliases []string, number int, o Opener) error {
 		}
 	}
 
+	var added *Ref
+	defer func() {
+		// Called after mu is unlocked.
+		if added != nil {
+			notify(Event{Ref: added})
+		}
+	}()
 	mu.Lock()
 	defer mu.Unlock()
 	if _, ok := byName[name]; ok {
@@ -181,6 +186,7 @@ func Register(name string, aliases []string, number int, o Opener) error {
 	for _, alias := range aliases {
 		byAlias[alias] = r
 	}
+	added = copyRef(r)
 	return nil
 }
 
@@ -189,6 +195,13 @@ func Register(name string, aliases []string, number int, o Opener) error {
 // This can happen when a SPI port is exposed via an USB device and the device
 // is unplugged.
 func Unregister(name string) error {
+	var removed *Ref
+	defer func() {
+		// Called after mu is unlocked.
+		if removed != nil {
+			notify(Event{Ref: removed, Removed: true})
+		}
+	}()
 	mu.Lock()
 	defer mu.Unlock()
 	r := byName[name]
@@ -200,9 +213,43 @@ func Unregister(name string) error {
 	for _, alias := range r.Aliases {
 		delete(byAlias, alias)
 	}
+	removed = copyRef(r)
 	return nil
 }
 
+// Event is a registration or an unregistration of a port.
+type Event struct {
+	// Ref is a copy of the reference to the port.
+	Ref *Ref
+	// Removed is true when the port was unregistered.
+	Removed bool
+}
+
+// Subscribe registers a callback that is called after each port registration
+// and unregistration, for example when a SPI adapter is plugged or unplugged.
+//
+// The callback is called synchronously in the goroutine calling Register() or
+// Unregister(), without the registry lock held, so it can use the registry.
+//
+// It returns a function to unsubscribe.
+func Subscribe(f func(e Event)) func() {
+	mu.Lock()
+	defer mu.Unlock()
+	lastID++
+	id := lastID
+	subscribers = append(subscribers, subscriber{id, f})
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for i := range subscribers {
+			if subscribers[i].id == id {
+				subscribers = append(subscribers[:i], subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
 //
 
 var (
@@ -211,8 +258,36 @@ var (
 	// Caches
 	byNumber = map[int]*Ref{}
 	byAlias  = map[string]*Ref{}
+	// Subscribers to the changes.
+	subscribers []subscriber
+	lastID      int
 )
 
+type subscriber struct {
+	id int
+	f  func(e Event)
+}
+
+// notify calls the subscribers with e.
+//
+// mu must not be held.
+func notify(e Event) {
+	mu.Lock()
+	l := make([]subscriber, len(subscribers))
+	copy(l, subscribers)
+	mu.Unlock()
+	for _, s := range l {
+		s.f(e)
+	}
+}
+
+// copyRef returns a copy of r, so it can be safely handed out.
+func copyRef(r *Ref) *Ref {
+	c := &Ref{Name: r.Name, Aliases: make([]string, len(r.Aliases)), Number: r.Number, Open: r.Open}
+	copy(c.Aliases, r.Aliases)
+	return c
+}
+
 // getDefault returns the Ref that should be used as the default port.
 func getDefault() *Ref {
 	var o *Ref
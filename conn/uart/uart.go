@@ -2,8 +2,225 @@
 // Use of this source code is governed under the Apache License, Version 2.0
 // that can be found in the LICENSE file.
 
-// Package uart will eventually define the API to communicate with devices over
-// the UART protocol.
+// Package uart defines the API to communicate with devices over the UART
+// protocol.
+//
+// As described in https://periph.io/x/periph/conn#hdr-Concepts, periph.io uses
+// the concepts of Bus, Port and Conn.
+//
+// In the package uart, 'Bus' is not exposed, as the protocol is primarily
+// point-to-point.
+//
+// Use Port.Connect() converts the uninitialized Port into a Conn.
+//
+// UART users usually talk in term of bauds. One baud is one symbol per second,
+// so it is expressed as physic.Hertz; 115200 bauds is 115200*physic.Hertz.
 //
 // See https://en.wikipedia.org/wiki/UART for more information.
 package uart
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/physic"
+)
+
+// Flow determines the data flow to use, if any.
+type Flow uint32
+
+const (
+	// NoFlow specifies that no flow control is used.
+	NoFlow Flow = 0x10000
+	// XOnXOff specifies XOn/XOff flow control, also called Software flow control.
+	//
+	// See https://en.wikipedia.org/wiki/Software_flow_control for more
+	// information.
+	XOnXOff Flow = 0x20000
+	// RTSCTS specifies RTS/CTS flow control. This uses RTS and CTS lines for
+	// flow control, also called Hardware flow control. This enables more
+	// reliable communication. The lines are driven Low when they are ready to
+	// receive more data.
+	RTSCTS Flow = 0x40000
+
+	mask Flow = 0xFFFF0000
+)
+
+// MakeXOnXOffFlow returns an initialized Flow to enable software based flow
+// control.
+func MakeXOnXOffFlow(xon, xoff byte) Flow {
+	return XOnXOff | Flow(xon)<<8 | Flow(xoff)
+}
+
+func (f Flow) String() string {
+	switch f {
+	case NoFlow:
+		return "None"
+	case RTSCTS:
+		return "RTS/CTS"
+	default:
+		if f&mask == XOnXOff {
+			return "XOn(" + string(rune(byte(f>>8))) + ")/XOff(" + string(rune(byte(f))) + ")"
+		}
+		return "Flow(" + strconv.FormatUint(uint64(f), 16) + ")"
+	}
+}
+
+// Parity determines the parity bit when transmitting, if any.
+type Parity byte
+
+const (
+	// NoParity means no parity bit.
+	NoParity Parity = 'N'
+	// Odd means 1 when sum is odd.
+	Odd Parity = 'O'
+	// Even means 1 when sum is even.
+	Even Parity = 'E'
+	// Mark means always 1.
+	Mark Parity = 'M'
+	// Space means always 0.
+	Space Parity = 'S'
+)
+
+// Stop determines what stop bit to use.
+type Stop int8
+
+const (
+	// One is 1 stop bit.
+	One Stop = 1
+	// OneHalf is 1.5 stop bits.
+	OneHalf Stop = 15
+	// Two is 2 stop bits.
+	Two Stop = 2
+)
+
+// ModemLine is a bitmask of the modem control lines.
+//
+// DTR and RTS are outputs, the other lines are inputs.
+type ModemLine uint16
+
+// Modem control lines as reported by Conn.ModemLines().
+const (
+	LineDTR ModemLine = 1 << iota // Data terminal ready (output)
+	LineRTS                       // Request to send (output)
+	LineCTS                       // Clear to send
+	LineDSR                       // Data set ready
+	LineDCD                       // Data carrier detect
+	LineRI                        // Ring indicator
+
+	// LineOutputs is the set of lines that can be changed with
+	// Conn.SetModemLines().
+	LineOutputs = LineDTR | LineRTS
+)
+
+func (m ModemLine) String() string {
+	if m == 0 {
+		return "0"
+	}
+	s := ""
+	for i, n := range modemLineNames {
+		if m&(1<<uint(i)) != 0 {
+			if len(s) != 0 {
+				s += "|"
+			}
+			s += n
+		}
+	}
+	if m &^= 1<<uint(len(modemLineNames)) - 1; m != 0 {
+		if len(s) != 0 {
+			s += "|"
+		}
+		s += "0x" + strconv.FormatUint(uint64(m), 16)
+	}
+	return s
+}
+
+// Conn defines the interface a concrete UART driver must implement.
+//
+// Read() returns as soon as at least one byte is available. Tx() writes w
+// then reads until r is filled.
+type Conn interface {
+	conn.Conn
+	io.ReadWriter
+	// SetReadTimeout sets the maximum duration Read() and Tx() wait for data.
+	//
+	// When the timeout expires before any byte is received, Read() returns 0
+	// bytes and no error, and Tx() returns an error. 0 means to wait
+	// indefinitely, which is the default.
+	SetReadTimeout(d time.Duration) error
+	// SendBreak holds the TX line low for at least d.
+	//
+	// A break is used by some protocols, like DMX512 or LIN, to signal the
+	// beginning of a frame.
+	SendBreak(d time.Duration) error
+	// ModemLines returns the current state of the modem control lines.
+	ModemLines() (ModemLine, error)
+	// SetModemLines sets the output modem lines in LineOutputs to the state
+	// specified in l; a set bit asserts the line.
+	//
+	// It is an error to specify lines outside of LineOutputs.
+	SetModemLines(l ModemLine) error
+}
+
+// Port is the interface to be provided to device drivers.
+//
+// The device driver, that is the driver for the peripheral connected over
+// this port, calls Connect() to retrieve a configured connection as Conn.
+type Port interface {
+	String() string
+	// Connect sets the communication parameters of the connection for use by a
+	// device.
+	//
+	// The device driver must call this function exactly once.
+	//
+	// f must specify the maximum rated speed by the device's spec. For example
+	// if a device is known to not work at over 115200 bauds, it should specify
+	// 115200Hz.
+	//
+	// The lowest speed between the port speed and the device speed is selected.
+	//
+	// There's rarely a reason to use anything else than One stop bit and 8 bits
+	// per character.
+	Connect(f physic.Frequency, stopBit Stop, parity Parity, flow Flow, bits int) (Conn, error)
+}
+
+// PortCloser is a UART port that can be closed.
+//
+// This interface is meant to be handled by the application.
+type PortCloser interface {
+	io.Closer
+	Port
+	// LimitSpeed sets the maximum port speed.
+	//
+	// It lets an application use a device at a lower speed than the maximum
+	// speed as rated by the device driver. This is useful for example when the
+	// wires are long or the connection is of poor quality, and you want to try
+	// to run at lower speed like 19200 bauds.
+	//
+	// This function can be called multiple times and resets the previous value.
+	// 0 is not a valid value for f. The lowest speed between the port speed and
+	// the device speed is selected.
+	LimitSpeed(f physic.Frequency) error
+}
+
+// Pins defines the pins that an UART bus interconnect is using on the host.
+//
+// It is expected that a implementer of Conn also implement Pins but this is
+// not a requirement.
+type Pins interface {
+	// RX returns the receive pin.
+	RX() gpio.PinIn
+	// TX returns the transmit pin.
+	TX() gpio.PinOut
+	// RTS returns the request to send pin, if present.
+	RTS() gpio.PinOut
+	// CTS returns the clear to send pin, if present.
+	CTS() gpio.PinIn
+}
+
+//
+
+var modemLineNames = []string{"DTR", "RTS", "CTS", "DSR", "DCD", "RI"}
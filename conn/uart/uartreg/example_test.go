@@ -9,10 +9,11 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"periph.io/x/periph/conn/physic"
-	"periph.io/x/periph/experimental/conn/uart"
-	"periph.io/x/periph/experimental/conn/uart/uartreg"
+	"periph.io/x/periph/conn/uart"
+	"periph.io/x/periph/conn/uart/uartreg"
 	"periph.io/x/periph/host"
 )
 
@@ -36,9 +37,19 @@ func Example() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	if err := c.Tx([]byte("cmd"), nil); err != nil {
+	if _, err := c.Write([]byte("cmd\n")); err != nil {
 		log.Fatal(err)
 	}
+	// Wait at most one second for the reply.
+	if err := c.SetReadTimeout(time.Second); err != nil {
+		log.Fatal(err)
+	}
+	b := make([]byte, 16)
+	n, err := c.Read(b)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%q\n", b[:n])
 }
 
 func ExampleAll() {
@@ -80,8 +91,14 @@ func ExampleOpen() {
 		log.Fatal(err)
 	}
 
-	// On linux, the following calls will likely open the same bus.
-	_, _ = uartreg.Open("/dev/ttyUSB0")
-	_, _ = uartreg.Open("UART0")
+	// On Linux, the following calls will likely open the same port.
+	_, _ = uartreg.Open("/dev/ttyAMA0")
+	_, _ = uartreg.Open("ttyAMA0")
 	_, _ = uartreg.Open("0")
+
+	// Opens the first default UART port found:
+	_, _ = uartreg.Open("")
+
+	// Wondering what to do with the opened uart.PortCloser? Look at the
+	// package's example above.
 }
@@ -0,0 +1,331 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package uartreg defines the UART registry for UART ports discovered on the
+// host.
+//
+// UART ports discovered on the host are automatically registered in the UART
+// registry by host.Init().
+package uartreg
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"periph.io/x/periph/conn/uart"
+)
+
+// Opener opens an handle to a port.
+//
+// It is provided by the actual port driver.
+type Opener func() (uart.PortCloser, error)
+
+// Ref references an UART port.
+//
+// It is returned by All() to enumerate all registered ports.
+type Ref struct {
+	// Name of the port.
+	//
+	// It must not be a sole number. It must be unique across the host.
+	Name string
+	// Aliases are the alternative names that can be used to reference this port.
+	Aliases []string
+	// Number of the port or -1 if the port doesn't have any "native" number.
+	//
+	// Ports provided by the CPU normally have a 0 based number. Ports provided
+	// via an addon (like over USB) generally are not numbered.
+	Number int
+	// Open is the factory to open an handle to this UART port.
+	Open Opener
+}
+
+// Open opens an UART port by its name, an alias or its number and returns an
+// handle to it.
+//
+// Specify the empty string "" to get the first available port. This is the
+// recommended default value unless an application knows the exact port to use.
+//
+// Each port can register multiple aliases, each leading to the same port
+// handle.
+//
+// "Port number" is a generic concept that is highly dependent on the platform
+// and OS. On some platform, the first port may have the number 0, 1 or as high
+// as 32766. Port numbers are not necessarily continuous and may not start at
+// 0.
+//
+// When the UART port is provided by an off board plug and play bus like USB via
+// a FT232R USB device, there can be no associated number.
+func Open(name string) (uart.PortCloser, error) {
+	var r *Ref
+	var err error
+	func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(byName) == 0 {
+			err = errors.New("uartreg: no port found; did you forget to call Init()?")
+			return
+		}
+		if len(name) == 0 {
+			r = getDefault()
+			return
+		}
+		// Try by name, by alias, by number.
+		if r = byName[name]; r == nil {
+			if r = byAlias[name]; r == nil {
+				if i, err2 := strconv.Atoi(name); err2 == nil {
+					r = byNumber[i]
+				}
+			}
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, errors.New("uartreg: can't open unknown port: " + strconv.Quote(name))
+	}
+	return r.Open()
+}
+
+// All returns a copy of all the registered references to all know UART ports
+// available on this host.
+//
+// The list is sorted by the port name.
+func All() []*Ref {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]*Ref, 0, len(byName))
+	for _, v := range byName {
+		out = insertRef(out, copyRef(v))
+	}
+	return out
+}
+
+// Register registers an UART port.
+//
+// Registering the same port name twice is an error, e.g. o.Name(). o.Number()
+// can be -1 to signify that the port doesn't have an inherent "port number". A
+// good example is a port provided over a FT232R device connected on an USB bus.
+// In this case, the port name should be created from the serial number of the
+// device for unique identification.
+func Register(name string, aliases []string, number int, o Opener) error {
+	if len(name) == 0 {
+		return errors.New("uartreg: can't register a port with no name")
+	}
+	if o == nil {
+		return errors.New("uartreg: can't register port " + strconv.Quote(name) + " with nil Opener")
+	}
+	if number < -1 {
+		return errors.New("uartreg: can't register port " + strconv.Quote(name) + " with invalid port number " + strconv.Itoa(number))
+	}
+	if _, err := strconv.Atoi(name); err == nil {
+		return errors.New("uartreg: can't register port " + strconv.Quote(name) + " with name being only a number")
+	}
+	if strings.Contains(name, ":") {
+		return errors.New("uartreg: can't register port " + strconv.Quote(name) + " with name containing ':'")
+	}
+	for _, alias := range aliases {
+		if len(alias) == 0 {
+			return errors.New("uartreg: can't register port " + strconv.Quote(name) + " with an empty alias")
+		}
+		if name == alias {
+			return errors.New("uartreg: can't register port " + strconv.Quote(name) + " with an alias the same as the port name")
+		}
+		if _, err := strconv.Atoi(alias); err == nil {
+			return errors.New("uartreg: can't register port " + strconv.Quote(name) + " with an alias that is a number: " + strconv.Quote(alias))
+		}
+		if strings.Contains(alias, ":") {
+			return errors.New("uartreg: can't register port " + strconv.Quote(name) + " with an alias containing ':': " + strconv.Quote(alias))
+		}
+	}
+
+	var added *Ref
+	defer func() {
+		// Called after mu is unlocked.
+		if added != nil {
+			notify(Event{Ref: added})
+		}
+	}()
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := byName[name]; ok {
+		return errors.New("uartreg: can't register port " + strconv.Quote(name) + " twice")
+	}
+	if _, ok := byAlias[name]; ok {
+		return errors.New("uartreg: can't register port " + strconv.Quote(name) + " twice; it is already an alias")
+	}
+	if number != -1 {
+		if _, ok := byNumber[number]; ok {
+			return errors.New("uartreg: can't register port " + strconv.Quote(name) + "; port number " + strconv.Itoa(number) + " is already registered")
+		}
+	}
+	for _, alias := range aliases {
+		if _, ok := byName[alias]; ok {
+			return errors.New("uartreg: can't register port " + strconv.Quote(name) + " twice; alias " + strconv.Quote(alias) + " is already a port")
+		}
+		if _, ok := byAlias[alias]; ok {
+			return errors.New("uartreg: can't register port " + strconv.Quote(name) + " twice; alias " + strconv.Quote(alias) + " is already an alias")
+		}
+	}
+
+	r := &Ref{Name: name, Aliases: make([]string, len(aliases)), Number: number, Open: o}
+	copy(r.Aliases, aliases)
+	byName[name] = r
+	if number != -1 {
+		byNumber[number] = r
+	}
+	for _, alias := range aliases {
+		byAlias[alias] = r
+	}
+	added = copyRef(r)
+	return nil
+}
+
+// Unregister removes a previously registered UART port.
+//
+// This can happen when an UART port is exposed via an USB device and the device
+// is unplugged.
+func Unregister(name string) error {
+	var removed *Ref
+	defer func() {
+		// Called after mu is unlocked.
+		if removed != nil {
+			notify(Event{Ref: removed, Removed: true})
+		}
+	}()
+	mu.Lock()
+	defer mu.Unlock()
+	r := byName[name]
+	if r == nil {
+		return errors.New("uartreg: can't unregister unknown port name " + strconv.Quote(name))
+	}
+	delete(byName, name)
+	delete(byNumber, r.Number)
+	for _, alias := range r.Aliases {
+		delete(byAlias, alias)
+	}
+	removed = copyRef(r)
+	return nil
+}
+
+// Event is a registration or an unregistration of a port.
+type Event struct {
+	// Ref is a copy of the reference to the port.
+	Ref *Ref
+	// Removed is true when the port was unregistered.
+	Removed bool
+}
+
+// Subscribe registers a callback that is called after each port registration
+// and unregistration, for example when a UART adapter is plugged or unplugged.
+//
+// The callback is called synchronously in the goroutine calling Register() or
+// Unregister(), without the registry lock held, so it can use the registry.
+//
+// It returns a function to unsubscribe.
+func Subscribe(f func(e Event)) func() {
+	mu.Lock()
+	defer mu.Unlock()
+	lastID++
+	id := lastID
+	subscribers = append(subscribers, subscriber{id, f})
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for i := range subscribers {
+			if subscribers[i].id == id {
+				subscribers = append(subscribers[:i], subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+//
+
+var (
+	mu     sync.Mutex
+	byName = map[string]*Ref{}
+	// Caches
+	byNumber = map[int]*Ref{}
+	byAlias  = map[string]*Ref{}
+	// Subscribers to the changes.
+	subscribers []subscriber
+	lastID      int
+)
+
+type subscriber struct {
+	id int
+	f  func(e Event)
+}
+
+// notify calls the subscribers with e.
+//
+// mu must not be held.
+func notify(e Event) {
+	mu.Lock()
+	l := make([]subscriber, len(subscribers))
+	copy(l, subscribers)
+	mu.Unlock()
+	for _, s := range l {
+		s.f(e)
+	}
+}
+
+// copyRef returns a copy of r, so it can be safely handed out.
+func copyRef(r *Ref) *Ref {
+	c := &Ref{Name: r.Name, Aliases: make([]string, len(r.Aliases)), Number: r.Number, Open: r.Open}
+	copy(c.Aliases, r.Aliases)
+	return c
+}
+
+// getDefault returns the Ref that should be used as the default port.
+func getDefault() *Ref {
+	var o *Ref
+	if len(byNumber) == 0 {
+		// Fallback to use byName using a lexical sort.
+		name := ""
+		for n, o2 := range byName {
+			if len(name) == 0 || n < name {
+				o = o2
+				name = n
+			}
+		}
+		return o
+	}
+	number := int((^uint(0)) >> 1)
+	for n, o2 := range byNumber {
+		if number > n {
+			number = n
+			o = o2
+		}
+	}
+	return o
+}
+
+func insertRef(l []*Ref, r *Ref) []*Ref {
+	n := r.Name
+	i := search(len(l), func(i int) bool { return l[i].Name > n })
+	l = append(l, nil)
+	copy(l[i+1:], l[i:])
+	l[i] = r
+	return l
+}
+
+// search implements the same algorithm as sort.Search().
+//
+// It was extracted to to not depend on sort, which depends on reflect.
+func search(n int, f func(int) bool) int {
+	lo := 0
+	for hi := n; lo < hi; {
+		if i := int(uint(lo+hi) >> 1); !f(i) {
+			lo = i + 1
+		} else {
+			hi = i
+		}
+	}
+	return lo
+}
@@ -11,6 +11,7 @@ import (
 	"periph.io/x/periph/conn"
 	"periph.io/x/periph/conn/onewire"
 	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/physic/sampler"
 )
 
 // ConvertAll performs a conversion on all DS18B20 devices on the bus.
@@ -79,6 +80,7 @@ func New(o onewire.Bus, addr onewire.Address, resolutionBits int) (*Dev, error)
 type Dev struct {
 	onewire    onewire.Dev // device on 1-wire bus
 	resolution int         // resolution in bits (9..12)
+	c          sampler.Continuous
 }
 
 func (d *Dev) String() string {
@@ -86,7 +88,10 @@ func (d *Dev) String() string {
 }
 
 // Halt implements conn.Resource.
+//
+// It stops the continuous sensing initiated by SenseContinuous().
 func (d *Dev) Halt() error {
+	d.c.Stop()
 	return nil
 }
 
@@ -105,9 +110,24 @@ func (d *Dev) Sense(e *physic.Env) error {
 }
 
 // SenseContinuous implements physic.SenseEnv.
-func (d *Dev) SenseContinuous(time.Duration) (<-chan physic.Env, error) {
-	// TODO(maruel): Manually poll in a loop via time.NewTicker.
-	return nil, errors.New("ds18b20: not implemented")
+//
+// Each reading does a conversion of this device only. To sample several
+// devices on the same bus, use a sampler.Group with ConvertAll as Convert.
+func (d *Dev) SenseContinuous(interval time.Duration) (<-chan physic.Env, error) {
+	return d.c.Start(d, interval)
+}
+
+// SenseLast implements sampler.LastSenser.
+//
+// It reads the temperature resulting from the last conversion, as triggered
+// by ConvertAll.
+func (d *Dev) SenseLast(e *physic.Env) error {
+	t, err := d.LastTemp()
+	if err != nil {
+		return err
+	}
+	e.Temperature = t
+	return nil
 }
 
 // Precision implements physic.SenseEnv.
@@ -184,3 +204,4 @@ var sleep = time.Sleep
 
 var _ conn.Resource = &Dev{}
 var _ physic.SenseEnv = &Dev{}
+var _ sampler.LastSenser = &Dev{}
@@ -84,6 +84,56 @@ func TestSense(t *testing.T) {
 	}
 }
 
+func TestSenseLast_SenseContinuous(t *testing.T) {
+	readScratchpad := onewiretest.IO{
+		W: []uint8{0x55, 0x28, 0xac, 0x41, 0xe, 0x7, 0x0, 0x0, 0x74, 0xbe},
+		R: []uint8{0xe0, 0x1, 0x0, 0x0, 0x3f, 0xff, 0x10, 0x10, 0x3f},
+	}
+	ops := []onewiretest.IO{
+		// Match ROM + Read Scratchpad (init)
+		readScratchpad,
+		// Match ROM + Read Scratchpad (SenseLast)
+		readScratchpad,
+		// Match ROM + Convert
+		{
+			W:    []uint8{0x55, 0x28, 0xac, 0x41, 0xe, 0x7, 0x0, 0x0, 0x74, 0x44},
+			Pull: true,
+		},
+		// Match ROM + Read Scratchpad (SenseContinuous)
+		readScratchpad,
+	}
+	var addr onewire.Address = 0x740000070e41ac28
+	bus := onewiretest.Playback{Ops: ops}
+	dev, err := New(&bus, addr, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := 30*physic.Celsius + physic.ZeroCelsius
+	e := physic.Env{}
+	if err := dev.SenseLast(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Temperature != expected {
+		t.Fatal(e.Temperature)
+	}
+	c, err := dev.SenseContinuous(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := <-c; e.Temperature != expected {
+		t.Fatal(e.Temperature)
+	}
+	if err := dev.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-c; ok {
+		t.Fatal("channel must be closed")
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TestConvertAll tests a temperature conversion on all ds18b20 using
 // recorded bus transactions.
 func TestConvertAll(t *testing.T) {
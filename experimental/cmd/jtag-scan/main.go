@@ -0,0 +1,86 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// jtag-scan lists the devices on a JTAG chain by reading their IDCODE.
+//
+// The chain is accessed by bit-banging 4 GPIO pins.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/jtag"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/experimental/devices/bitbang"
+	"periph.io/x/periph/host"
+)
+
+func mainImpl() error {
+	tckName := flag.String("tck", "", "TCK pin")
+	tmsName := flag.String("tms", "", "TMS pin")
+	tdiName := flag.String("tdi", "", "TDI pin")
+	tdoName := flag.String("tdo", "", "TDO pin")
+	var hz physic.Frequency
+	flag.Var(&hz, "hz", "maximum TCK frequency, e.g. 100kHz")
+	verbose := flag.Bool("v", false, "verbose mode")
+	flag.Parse()
+	if !*verbose {
+		log.SetOutput(ioutil.Discard)
+	}
+	log.SetFlags(log.Lmicroseconds)
+	if flag.NArg() != 0 {
+		return errors.New("unexpected argument, try -help")
+	}
+
+	if _, err := host.Init(); err != nil {
+		return err
+	}
+	var pins [4]gpio.PinIO
+	for i, n := range []*string{tckName, tmsName, tdiName, tdoName} {
+		f := [...]string{"-tck", "-tms", "-tdi", "-tdo"}[i]
+		if *n == "" {
+			return fmt.Errorf("specify the pin with %s", f)
+		}
+		if pins[i] = gpioreg.ByName(*n); pins[i] == nil {
+			return fmt.Errorf("invalid pin %q for %s", *n, f)
+		}
+	}
+	a, err := bitbang.NewJTAG(pins[0], pins[1], pins[2], pins[3])
+	if err != nil {
+		return err
+	}
+	if hz != 0 {
+		if err := a.LimitSpeed(hz); err != nil {
+			return err
+		}
+	}
+	log.Printf("Using %s", a)
+	t, err := jtag.NewTAP(a)
+	if err != nil {
+		return err
+	}
+	ids, err := t.Scan()
+	if err != nil {
+		return err
+	}
+	// The first device found is the closest to TDO.
+	for i, id := range ids {
+		fmt.Printf("%d: %s\n", i, id)
+	}
+	return nil
+}
+
+func main() {
+	if err := mainImpl(); err != nil {
+		fmt.Fprintf(os.Stderr, "jtag-scan: %s.\n", err)
+		os.Exit(1)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"periph.io/x/periph/conn/gpio/gpioreg"
 	"periph.io/x/periph/host"
 
+	"periph.io/x/periph/experimental/conn/imu/fusion"
 	"periph.io/x/periph/experimental/devices/mpu9250"
 	"periph.io/x/periph/experimental/devices/mpu9250/accelerometer"
 )
@@ -21,6 +22,7 @@ import (
 var (
 	accRes      = flag.String("accRes", "2", "Acceleration resolution (2, 4, 8, 16G)")
 	continuous  = flag.Bool("cont", false, "Continuous read")
+	orientation = flag.Bool("orientation", false, "Track the orientation continuously")
 	sensitivity int
 )
 
@@ -94,6 +96,27 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if *orientation {
+		if err := dev.EnableMagnetometer(); err != nil {
+			log.Fatal(err)
+		}
+		const interval = 10 * time.Millisecond
+		c, err := dev.SenseContinuous(interval)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer dev.Halt()
+		f := fusion.NewMadgwick(0.1)
+		for i := 0; ; i++ {
+			s := <-c
+			f.Update(&s, interval)
+			if i%50 == 0 {
+				roll, pitch, yaw := f.Orientation().Euler()
+				fmt.Printf("Roll: %s, Pitch: %s, Yaw: %s\n", roll, pitch, yaw)
+			}
+		}
+	}
+
 	if *continuous {
 		for {
 			x := MustInt16(dev.GetAccelerationX())
@@ -27,7 +27,6 @@ import (
 	"periph.io/x/periph/conn/display"
 	"periph.io/x/periph/conn/gpio/gpioreg"
 	"periph.io/x/periph/conn/gpio/gpiostream"
-	"periph.io/x/periph/conn/physic"
 	"periph.io/x/periph/experimental/devices/nrzled"
 	"periph.io/x/periph/host"
 )
@@ -121,7 +120,8 @@ func mainImpl() error {
 	pin := flag.String("p", "", "GPIO pin to use")
 
 	numPixels := flag.Int("n", nrzled.DefaultOpts.NumPixels, "number of pixels on the strip")
-	hz := flag.Int("s", int(nrzled.DefaultOpts.Freq/physic.Hertz), "speed in Hz")
+	hz := nrzled.DefaultOpts.Freq
+	flag.Var(&hz, "s", "speed")
 	channels := flag.Int("channels", nrzled.DefaultOpts.Channels, "number of color channels, use 4 for RGBW")
 	color := flag.String("color", "208020", "hex encoded color to show")
 	imgName := flag.String("img", "", "image to load")
@@ -151,7 +151,7 @@ func mainImpl() error {
 	}
 	opts := nrzled.DefaultOpts
 	opts.NumPixels = *numPixels
-	opts.Freq = physic.Frequency(*hz) * physic.Hertz
+	opts.Freq = hz
 	opts.Channels = *channels
 	disp, err := nrzled.New(s, &opts)
 	if err != nil {
@@ -0,0 +1,313 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package fusion implements orientation estimation filters fusing the
+// samples of an imu.IMU.
+//
+// Both filters track the orientation of the sensor frame relative to the
+// earth frame as a quaternion. When the magnetic field is not available, or
+// is zero, the yaw is only integrated from the angular velocity and will
+// drift.
+//
+// References
+//
+// Sebastian O.H. Madgwick, An efficient orientation filter for inertial and
+// inertial/magnetic sensor arrays, 2010.
+// http://x-io.co.uk/res/doc/madgwick_internal_report.pdf
+//
+// Robert Mahony, Tarek Hamel, Jean-Michel Pflimlin, Nonlinear Complementary
+// Filters on the Special Orthogonal Group, 2008.
+// https://hal.archives-ouvertes.fr/hal-00488376/document
+package fusion
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/experimental/conn/imu"
+)
+
+// Quaternion is a rotation.
+type Quaternion struct {
+	W, X, Y, Z float64
+}
+
+// Identity is the quaternion of no rotation.
+var Identity = Quaternion{W: 1}
+
+func (q Quaternion) String() string {
+	return fmt.Sprintf("(%g, %g, %g, %g)", q.W, q.X, q.Y, q.Z)
+}
+
+// Euler returns the rotation as Tait-Bryan angles, applied in the yaw, pitch
+// then roll order around the Z, Y then X axes.
+//
+// Pitch is within [-90°, 90°], roll and yaw are within [-180°, 180°].
+func (q Quaternion) Euler() (roll, pitch, yaw physic.Angle) {
+	r := math.Atan2(2*(q.W*q.X+q.Y*q.Z), 1-2*(q.X*q.X+q.Y*q.Y))
+	s := 2 * (q.W*q.Y - q.Z*q.X)
+	if s > 1 {
+		s = 1
+	} else if s < -1 {
+		s = -1
+	}
+	p := math.Asin(s)
+	y := math.Atan2(2*(q.W*q.Z+q.X*q.Y), 1-2*(q.Y*q.Y+q.Z*q.Z))
+	return toAngle(r), toAngle(p), toAngle(y)
+}
+
+// Filter is an orientation estimation filter.
+type Filter interface {
+	// Update integrates a sample that was measured dt after the previous one.
+	Update(s *imu.Sample, dt time.Duration)
+	// Orientation returns the current estimate.
+	Orientation() Quaternion
+}
+
+// Madgwick is the gradient descent orientation filter from Sebastian
+// Madgwick.
+type Madgwick struct {
+	// Beta is the gain of the correction from the accelerometer and the
+	// magnetometer; it is the expected gyroscope measurement error in rad/s.
+	// A higher value converges faster but is noisier.
+	Beta float64
+
+	q Quaternion
+}
+
+// NewMadgwick returns a Madgwick filter starting at the Identity orientation.
+//
+// 0.1 is a typical value for beta.
+func NewMadgwick(beta float64) *Madgwick {
+	return &Madgwick{Beta: beta, q: Identity}
+}
+
+// Orientation implements Filter.
+func (m *Madgwick) Orientation() Quaternion {
+	return m.q
+}
+
+// Update implements Filter.
+func (m *Madgwick) Update(s *imu.Sample, dt time.Duration) {
+	gx, gy, gz := gyro(s)
+	ax, ay, az := accel(s)
+	mx, my, mz := mag(s)
+	q0, q1, q2, q3 := m.q.W, m.q.X, m.q.Y, m.q.Z
+
+	// Rate of change of the quaternion from the gyroscope.
+	qDot0 := 0.5 * (-q1*gx - q2*gy - q3*gz)
+	qDot1 := 0.5 * (q0*gx + q2*gz - q3*gy)
+	qDot2 := 0.5 * (q0*gy - q1*gz + q3*gx)
+	qDot3 := 0.5 * (q0*gz + q1*gy - q2*gx)
+
+	if ax, ay, az, ok := normalize(ax, ay, az); ok {
+		var s0, s1, s2, s3 float64
+		if mx, my, mz, ok := normalize(mx, my, mz); ok {
+			// Gradient of the objective function for gravity and the earth's
+			// magnetic field.
+			_2q0mx := 2 * q0 * mx
+			_2q0my := 2 * q0 * my
+			_2q0mz := 2 * q0 * mz
+			_2q1mx := 2 * q1 * mx
+			_2q0 := 2 * q0
+			_2q1 := 2 * q1
+			_2q2 := 2 * q2
+			_2q3 := 2 * q3
+			_2q0q2 := 2 * q0 * q2
+			_2q2q3 := 2 * q2 * q3
+			q0q0 := q0 * q0
+			q0q1 := q0 * q1
+			q0q2 := q0 * q2
+			q0q3 := q0 * q3
+			q1q1 := q1 * q1
+			q1q2 := q1 * q2
+			q1q3 := q1 * q3
+			q2q2 := q2 * q2
+			q2q3 := q2 * q3
+			q3q3 := q3 * q3
+
+			// Reference direction of the earth's magnetic field.
+			hx := mx*q0q0 - _2q0my*q3 + _2q0mz*q2 + mx*q1q1 + _2q1*my*q2 + _2q1*mz*q3 - mx*q2q2 - mx*q3q3
+			hy := _2q0mx*q3 + my*q0q0 - _2q0mz*q1 + _2q1mx*q2 - my*q1q1 + my*q2q2 + _2q2*mz*q3 - my*q3q3
+			_2bx := math.Sqrt(hx*hx + hy*hy)
+			_2bz := -_2q0mx*q2 + _2q0my*q1 + mz*q0q0 + _2q1mx*q3 - mz*q1q1 + _2q2*my*q3 - mz*q2q2 + mz*q3q3
+			_4bx := 2 * _2bx
+			_4bz := 2 * _2bz
+
+			fx := _2bx*(0.5-q2q2-q3q3) + _2bz*(q1q3-q0q2) - mx
+			fy := _2bx*(q1q2-q0q3) + _2bz*(q0q1+q2q3) - my
+			fz := _2bx*(q0q2+q1q3) + _2bz*(0.5-q1q1-q2q2) - mz
+			gax := 2*q1q3 - _2q0q2 - ax
+			gay := 2*q0q1 + _2q2q3 - ay
+			gaz := 1 - 2*q1q1 - 2*q2q2 - az
+			s0 = -_2q2*gax + _2q1*gay - _2bz*q2*fx + (-_2bx*q3+_2bz*q1)*fy + _2bx*q2*fz
+			s1 = _2q3*gax + _2q0*gay - 4*q1*gaz + _2bz*q3*fx + (_2bx*q2+_2bz*q0)*fy + (_2bx*q3-_4bz*q1)*fz
+			s2 = -_2q0*gax + _2q3*gay - 4*q2*gaz + (-_4bx*q2-_2bz*q0)*fx + (_2bx*q1+_2bz*q3)*fy + (_2bx*q0-_4bz*q2)*fz
+			s3 = _2q1*gax + _2q2*gay + (-_4bx*q3+_2bz*q1)*fx + (-_2bx*q0+_2bz*q2)*fy + _2bx*q1*fz
+		} else {
+			// Gradient of the objective function for gravity only.
+			_2q0 := 2 * q0
+			_2q1 := 2 * q1
+			_2q2 := 2 * q2
+			_2q3 := 2 * q3
+			_4q0 := 4 * q0
+			_4q1 := 4 * q1
+			_4q2 := 4 * q2
+			_8q1 := 8 * q1
+			_8q2 := 8 * q2
+			q0q0 := q0 * q0
+			q1q1 := q1 * q1
+			q2q2 := q2 * q2
+			q3q3 := q3 * q3
+			s0 = _4q0*q2q2 + _2q2*ax + _4q0*q1q1 - _2q1*ay
+			s1 = _4q1*q3q3 - _2q3*ax + 4*q0q0*q1 - _2q0*ay - _4q1 + _8q1*q1q1 + _8q1*q2q2 + _4q1*az
+			s2 = 4*q0q0*q2 + _2q0*ax + _4q2*q3q3 - _2q3*ay - _4q2 + _8q2*q1q1 + _8q2*q2q2 + _4q2*az
+			s3 = 4*q1q1*q3 - _2q1*ax + 4*q2q2*q3 - _2q2*ay
+		}
+		if n := math.Sqrt(s0*s0 + s1*s1 + s2*s2 + s3*s3); n != 0 {
+			qDot0 -= m.Beta * s0 / n
+			qDot1 -= m.Beta * s1 / n
+			qDot2 -= m.Beta * s2 / n
+			qDot3 -= m.Beta * s3 / n
+		}
+	}
+
+	t := dt.Seconds()
+	m.q = normalizeQ(Quaternion{q0 + qDot0*t, q1 + qDot1*t, q2 + qDot2*t, q3 + qDot3*t})
+}
+
+// Mahony is the nonlinear complementary filter from Robert Mahony.
+type Mahony struct {
+	// Kp is the proportional gain of the correction from the accelerometer and
+	// the magnetometer.
+	Kp float64
+	// Ki is the integral gain of the correction, which compensates for the
+	// gyroscope bias. Use 0 to disable.
+	Ki float64
+
+	q        Quaternion
+	integral [3]float64
+}
+
+// NewMahony returns a Mahony filter starting at the Identity orientation.
+//
+// 0.5 and 0 are typical values for kp and ki.
+func NewMahony(kp, ki float64) *Mahony {
+	return &Mahony{Kp: kp, Ki: ki, q: Identity}
+}
+
+// Orientation implements Filter.
+func (m *Mahony) Orientation() Quaternion {
+	return m.q
+}
+
+// Update implements Filter.
+func (m *Mahony) Update(s *imu.Sample, dt time.Duration) {
+	gx, gy, gz := gyro(s)
+	ax, ay, az := accel(s)
+	mx, my, mz := mag(s)
+	q0, q1, q2, q3 := m.q.W, m.q.X, m.q.Y, m.q.Z
+	t := dt.Seconds()
+
+	if ax, ay, az, ok := normalize(ax, ay, az); ok {
+		q0q0 := q0 * q0
+		q0q1 := q0 * q1
+		q0q2 := q0 * q2
+		q0q3 := q0 * q3
+		q1q1 := q1 * q1
+		q1q2 := q1 * q2
+		q1q3 := q1 * q3
+		q2q2 := q2 * q2
+		q2q3 := q2 * q3
+		q3q3 := q3 * q3
+
+		// Estimated direction of gravity; the error is the cross product with
+		// the measured direction.
+		halfvx := q1q3 - q0q2
+		halfvy := q0q1 + q2q3
+		halfvz := q0q0 - 0.5 + q3q3
+		halfex := ay*halfvz - az*halfvy
+		halfey := az*halfvx - ax*halfvz
+		halfez := ax*halfvy - ay*halfvx
+
+		if mx, my, mz, ok := normalize(mx, my, mz); ok {
+			// Reference direction of the earth's magnetic field.
+			hx := 2 * (mx*(0.5-q2q2-q3q3) + my*(q1q2-q0q3) + mz*(q1q3+q0q2))
+			hy := 2 * (mx*(q1q2+q0q3) + my*(0.5-q1q1-q3q3) + mz*(q2q3-q0q1))
+			bx := math.Sqrt(hx*hx + hy*hy)
+			bz := 2 * (mx*(q1q3-q0q2) + my*(q2q3+q0q1) + mz*(0.5-q1q1-q2q2))
+			halfwx := bx*(0.5-q2q2-q3q3) + bz*(q1q3-q0q2)
+			halfwy := bx*(q1q2-q0q3) + bz*(q0q1+q2q3)
+			halfwz := bx*(q0q2+q1q3) + bz*(0.5-q1q1-q2q2)
+			halfex += my*halfwz - mz*halfwy
+			halfey += mz*halfwx - mx*halfwz
+			halfez += mx*halfwy - my*halfwx
+		}
+
+		if m.Ki > 0 {
+			m.integral[0] += 2 * m.Ki * halfex * t
+			m.integral[1] += 2 * m.Ki * halfey * t
+			m.integral[2] += 2 * m.Ki * halfez * t
+			gx += m.integral[0]
+			gy += m.integral[1]
+			gz += m.integral[2]
+		} else {
+			m.integral = [3]float64{}
+		}
+		gx += 2 * m.Kp * halfex
+		gy += 2 * m.Kp * halfey
+		gz += 2 * m.Kp * halfez
+	}
+
+	gx *= 0.5 * t
+	gy *= 0.5 * t
+	gz *= 0.5 * t
+	m.q = normalizeQ(Quaternion{
+		q0 - q1*gx - q2*gy - q3*gz,
+		q1 + q0*gx + q2*gz - q3*gy,
+		q2 + q0*gy - q1*gz + q3*gx,
+		q3 + q0*gz + q1*gy - q2*gx,
+	})
+}
+
+//
+
+func toAngle(rad float64) physic.Angle {
+	return physic.Angle(math.Floor(rad*float64(physic.Radian) + 0.5))
+}
+
+// gyro returns the angular velocity in rad/s.
+func gyro(s *imu.Sample) (float64, float64, float64) {
+	r := float64(imu.RadianPerSecond)
+	return float64(s.AngularVelocity[0]) / r, float64(s.AngularVelocity[1]) / r, float64(s.AngularVelocity[2]) / r
+}
+
+// accel returns the acceleration; only its direction is used.
+func accel(s *imu.Sample) (float64, float64, float64) {
+	return float64(s.Acceleration[0]), float64(s.Acceleration[1]), float64(s.Acceleration[2])
+}
+
+// mag returns the magnetic field; only its direction is used.
+func mag(s *imu.Sample) (float64, float64, float64) {
+	return float64(s.MagneticField[0]), float64(s.MagneticField[1]), float64(s.MagneticField[2])
+}
+
+// normalize returns the unit vector, or false if the vector is zero.
+func normalize(x, y, z float64) (float64, float64, float64, bool) {
+	n := math.Sqrt(x*x + y*y + z*z)
+	if n == 0 {
+		return 0, 0, 0, false
+	}
+	return x / n, y / n, z / n, true
+}
+
+func normalizeQ(q Quaternion) Quaternion {
+	n := math.Sqrt(q.W*q.W + q.X*q.X + q.Y*q.Y + q.Z*q.Z)
+	return Quaternion{q.W / n, q.X / n, q.Y / n, q.Z / n}
+}
+
+var _ Filter = &Madgwick{}
+var _ Filter = &Mahony{}
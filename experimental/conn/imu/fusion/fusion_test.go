@@ -0,0 +1,104 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package fusion
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/experimental/conn/imu"
+)
+
+func TestQuaternion_Euler(t *testing.T) {
+	s := math.Sqrt(0.5)
+	data := []struct {
+		q                Quaternion
+		roll, pitch, yaw physic.Angle
+	}{
+		{Identity, 0, 0, 0},
+		{Quaternion{s, s, 0, 0}, 90 * physic.Degree, 0, 0},
+		{Quaternion{math.Cos(math.Pi / 8), 0, math.Sin(math.Pi / 8), 0}, 0, 45 * physic.Degree, 0},
+		{Quaternion{s, 0, 0, -s}, 0, 0, -90 * physic.Degree},
+	}
+	for i, line := range data {
+		r, p, y := line.q.Euler()
+		if !near(r, line.roll) || !near(p, line.pitch) || !near(y, line.yaw) {
+			t.Fatalf("#%d: %s: %s %s %s", i, line.q, r, p, y)
+		}
+	}
+	if s := Identity.String(); s != "(1, 0, 0, 0)" {
+		t.Fatal(s)
+	}
+}
+
+func TestFilter_still(t *testing.T) {
+	for _, f := range []Filter{NewMadgwick(0.1), NewMahony(0.5, 0.1)} {
+		s := imu.Sample{Acceleration: [3]imu.Acceleration{0, 0, imu.EarthGravity}}
+		for i := 0; i < 100; i++ {
+			f.Update(&s, 10*time.Millisecond)
+		}
+		if q := f.Orientation(); q != Identity {
+			t.Fatalf("%T: %s", f, q)
+		}
+	}
+}
+
+func TestFilter_gyro(t *testing.T) {
+	// The correction from gravity doesn't affect the yaw.
+	for _, f := range []Filter{NewMadgwick(0.1), NewMahony(0.5, 0)} {
+		s := imu.Sample{
+			Acceleration:    [3]imu.Acceleration{0, 0, imu.EarthGravity},
+			AngularVelocity: [3]imu.AngularVelocity{0, 0, 90 * imu.DegreePerSecond},
+		}
+		for i := 0; i < 100; i++ {
+			f.Update(&s, 10*time.Millisecond)
+		}
+		r, p, y := f.Orientation().Euler()
+		if !near(r, 0) || !near(p, 0) || !near(y, 90*physic.Degree) {
+			t.Fatalf("%T: %s %s %s", f, r, p, y)
+		}
+	}
+}
+
+func TestFilter_gravity(t *testing.T) {
+	// Rolled by 90°, the Y axis points up.
+	for _, f := range []Filter{NewMadgwick(0.5), NewMahony(2, 0)} {
+		s := imu.Sample{Acceleration: [3]imu.Acceleration{0, imu.EarthGravity, 0}}
+		for i := 0; i < 1000; i++ {
+			f.Update(&s, 10*time.Millisecond)
+		}
+		r, p, _ := f.Orientation().Euler()
+		if !near(r, 90*physic.Degree) || !near(p, 0) {
+			t.Fatalf("%T: %s %s", f, r, p)
+		}
+	}
+}
+
+func TestFilter_magnetometer(t *testing.T) {
+	// Level and heading 30° from the magnetic north.
+	for _, f := range []Filter{NewMadgwick(0.5), NewMahony(2, 0)} {
+		s := imu.Sample{
+			Acceleration:  [3]imu.Acceleration{0, 0, imu.EarthGravity},
+			MagneticField: [3]imu.MagneticFluxDensity{17320 * imu.NanoTesla, -10000 * imu.NanoTesla, -40000 * imu.NanoTesla},
+		}
+		for i := 0; i < 3000; i++ {
+			f.Update(&s, 10*time.Millisecond)
+		}
+		r, p, y := f.Orientation().Euler()
+		if !near(r, 0) || !near(p, 0) || !near(y, 30*physic.Degree) {
+			t.Fatalf("%T: %s %s %s", f, r, p, y)
+		}
+	}
+}
+
+//
+
+// near returns true if a is within 0.5° of b.
+func near(a, b physic.Angle) bool {
+	d := a - b
+	return d < physic.Degree/2 && d > -physic.Degree/2
+}
@@ -0,0 +1,157 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package imu defines the interface of an inertial measurement unit and the
+// units it reports.
+//
+// The units are stored as fixed point integers in the same way as in package
+// physic.
+//
+// The axes follow the right hand rule. The sensor frame is the one of the
+// device's accelerometer; a driver is responsible to realign the other
+// sensors on it.
+package imu
+
+import (
+	"strconv"
+	"time"
+
+	"periph.io/x/periph/conn"
+)
+
+// Sample is a measurement of the three sensors of an IMU on the X, Y and Z
+// axes.
+type Sample struct {
+	Acceleration    [3]Acceleration
+	AngularVelocity [3]AngularVelocity
+	MagneticField   [3]MagneticFluxDensity
+}
+
+// IMU represents an inertial measurement unit.
+type IMU interface {
+	conn.Resource
+
+	// Sense returns the value read from the sensors. Unsupported metrics are
+	// not modified.
+	Sense(s *Sample) error
+	// SenseContinuous initiates a continuous sensing at the specified interval.
+	//
+	// It is important to call Halt() once done with the sensing, which will
+	// turn the device off and will close the channel.
+	SenseContinuous(interval time.Duration) (<-chan Sample, error)
+	// Precision returns this sensor's precision.
+	//
+	// The Sample values are set to the number of bits that are significant
+	// for each items that this sensor can measure.
+	Precision(s *Sample)
+}
+
+// Acceleration is a measurement of acceleration stored as an int64 micro
+// metre per second².
+//
+// The highest representable value is 9.2Tm/s².
+type Acceleration int64
+
+// String returns the acceleration formatted as a string in m/s².
+func (a Acceleration) String() string {
+	return microAsString(int64(a)) + "m/s²"
+}
+
+// Well known Acceleration constants.
+const (
+	MicroMetrePerSecond2 Acceleration = 1
+	MilliMetrePerSecond2 Acceleration = 1000 * MicroMetrePerSecond2
+	MetrePerSecond2      Acceleration = 1000 * MilliMetrePerSecond2
+
+	// EarthGravity is the standard gravity, g₀.
+	EarthGravity Acceleration = 9806650 * MicroMetrePerSecond2
+)
+
+// AngularVelocity is a measurement of rotation speed stored as an int64 nano
+// radian per second.
+//
+// The highest representable value is 9.2Grad/s.
+type AngularVelocity int64
+
+// String returns the angular velocity formatted as a string in rad/s.
+func (a AngularVelocity) String() string {
+	return nanoAsString(int64(a)) + "rad/s"
+}
+
+// Well known AngularVelocity constants.
+const (
+	NanoRadianPerSecond  AngularVelocity = 1
+	MicroRadianPerSecond AngularVelocity = 1000 * NanoRadianPerSecond
+	MilliRadianPerSecond AngularVelocity = 1000 * MicroRadianPerSecond
+	RadianPerSecond      AngularVelocity = 1000 * MilliRadianPerSecond
+
+	DegreePerSecond AngularVelocity = 17453293 * NanoRadianPerSecond
+)
+
+// MagneticFluxDensity is a measurement of magnetic field stored as an int64
+// nano tesla.
+//
+// The highest representable value is 9.2GT.
+type MagneticFluxDensity int64
+
+// String returns the magnetic flux density formatted as a string in tesla.
+func (m MagneticFluxDensity) String() string {
+	return nanoAsString(int64(m)) + "T"
+}
+
+// Well known MagneticFluxDensity constants.
+const (
+	NanoTesla  MagneticFluxDensity = 1
+	MicroTesla MagneticFluxDensity = 1000 * NanoTesla
+	MilliTesla MagneticFluxDensity = 1000 * MicroTesla
+	Tesla      MagneticFluxDensity = 1000 * MilliTesla
+)
+
+//
+
+// nanoAsString converts a value stored in nano units in a string with the
+// predefined prefix.
+func nanoAsString(v int64) string {
+	return prefixed(v, []string{"n", "µ", "m", "", "k", "M", "G"})
+}
+
+// microAsString converts a value stored in micro units in a string with the
+// predefined prefix.
+func microAsString(v int64) string {
+	return prefixed(v, []string{"µ", "m", "", "k", "M", "G", "T"})
+}
+
+// prefixed formats v with 3 decimals of the largest prefix that keeps the
+// integral part non zero.
+func prefixed(v int64, prefixes []string) string {
+	if v == 0 {
+		return "0"
+	}
+	sign := ""
+	if v < 0 {
+		if v == -9223372036854775808 {
+			v++
+		}
+		sign = "-"
+		v = -v
+	}
+	i := 0
+	div := int64(1)
+	for ; i < len(prefixes)-1 && v/div >= 1000; i++ {
+		div *= 1000
+	}
+	base := strconv.FormatInt(v/div, 10)
+	if i == 0 {
+		return sign + base + prefixes[0]
+	}
+	frac := v % div / (div / 1000)
+	if frac == 0 {
+		return sign + base + prefixes[i]
+	}
+	f := strconv.FormatInt(frac, 10)
+	for len(f) < 3 {
+		f = "0" + f
+	}
+	return sign + base + "." + f + prefixes[i]
+}
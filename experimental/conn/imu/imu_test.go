@@ -0,0 +1,58 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package imu
+
+import "testing"
+
+func TestAcceleration_String(t *testing.T) {
+	data := []struct {
+		in       Acceleration
+		expected string
+	}{
+		{0, "0m/s²"},
+		{MicroMetrePerSecond2, "1µm/s²"},
+		{-MilliMetrePerSecond2, "-1mm/s²"},
+		{EarthGravity, "9.806m/s²"},
+		{-9223372036854775807 - 1, "-9.223Tm/s²"},
+	}
+	for i, line := range data {
+		if s := line.in.String(); s != line.expected {
+			t.Fatalf("#%d: %s != %s", i, s, line.expected)
+		}
+	}
+}
+
+func TestAngularVelocity_String(t *testing.T) {
+	data := []struct {
+		in       AngularVelocity
+		expected string
+	}{
+		{NanoRadianPerSecond, "1nrad/s"},
+		{DegreePerSecond, "17.453mrad/s"},
+		{RadianPerSecond, "1rad/s"},
+		{-1500 * MilliRadianPerSecond, "-1.500rad/s"},
+	}
+	for i, line := range data {
+		if s := line.in.String(); s != line.expected {
+			t.Fatalf("#%d: %s != %s", i, s, line.expected)
+		}
+	}
+}
+
+func TestMagneticFluxDensity_String(t *testing.T) {
+	data := []struct {
+		in       MagneticFluxDensity
+		expected string
+	}{
+		{150 * NanoTesla, "150nT"},
+		{48 * MicroTesla, "48µT"},
+		{Tesla + 10*MilliTesla, "1.010T"},
+	}
+	for i, line := range data {
+		if s := line.in.String(); s != line.expected {
+			t.Fatalf("#%d: %s != %s", i, s, line.expected)
+		}
+	}
+}
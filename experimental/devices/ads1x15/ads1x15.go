@@ -0,0 +1,466 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package ads1x15
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/analog"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/mmr"
+	"periph.io/x/periph/conn/physic"
+)
+
+// Channel is an input of the multiplexer.
+//
+// The values match the MUX field of the config register.
+type Channel uint8
+
+// Valid Channel values.
+const (
+	Channel0Minus1 Channel = 0 // Differential AIN0 - AIN1
+	Channel0Minus3 Channel = 1 // Differential AIN0 - AIN3
+	Channel1Minus3 Channel = 2 // Differential AIN1 - AIN3
+	Channel2Minus3 Channel = 3 // Differential AIN2 - AIN3
+	Channel0       Channel = 4 // Single-ended AIN0
+	Channel1       Channel = 5 // Single-ended AIN1
+	Channel2       Channel = 6 // Single-ended AIN2
+	Channel3       Channel = 7 // Single-ended AIN3
+)
+
+func (c Channel) String() string {
+	if c >= Channel(len(channelNames)) {
+		return fmt.Sprintf("Channel(%d)", c)
+	}
+	return channelNames[c]
+}
+
+// Comparator configures the comparator driving the ALERT/RDY pin.
+//
+// The thresholds are compared to the conversion results of the pin the
+// comparator was set on.
+type Comparator struct {
+	// Low and High are the thresholds. In traditional mode, ALERT/RDY asserts
+	// when a conversion exceeds High and deasserts when one goes below Low. In
+	// window mode, it asserts when a conversion is outside [Low, High].
+	Low, High physic.ElectricPotential
+	// Window selects the window mode instead of the traditional mode.
+	Window bool
+	// ActiveHigh sets ALERT/RDY as active high instead of active low.
+	ActiveHigh bool
+	// Latching keeps ALERT/RDY asserted until the conversion register is read.
+	Latching bool
+	// Queue is the number of consecutive conversions past the thresholds
+	// before ALERT/RDY asserts. It must be 1, 2 or 4.
+	Queue int
+}
+
+// Opts holds the configuration options.
+type Opts struct {
+	// I2CAddress is the address on the bus, between 0x48 and 0x4B depending on
+	// the wiring of the ADDR pin.
+	I2CAddress uint16
+	// Ready is the optional GPIO connected to the ALERT/RDY pin. When set,
+	// ReadContinuous() waits for the conversion ready pulse instead of
+	// polling, unless a Comparator is in use.
+	Ready gpio.PinIn
+}
+
+// DefaultOpts is the recommended default options.
+var DefaultOpts = Opts{
+	I2CAddress: 0x48,
+}
+
+// NewADS1015 returns an object that communicates over I²C to an ADS1015.
+func NewADS1015(b i2c.Bus, opts *Opts) (*Dev, error) {
+	return newDev(b, opts, "ADS1015", 4, rates1015[:])
+}
+
+// NewADS1115 returns an object that communicates over I²C to an ADS1115.
+func NewADS1115(b i2c.Bus, opts *Opts) (*Dev, error) {
+	return newDev(b, opts, "ADS1115", 0, rates1115[:])
+}
+
+// Dev is a handle to an ADS1015 or ADS1115.
+type Dev struct {
+	c     mmr.Dev8
+	name  string
+	shift uint
+	rates []physic.Frequency
+	ready gpio.PinIn
+
+	mu sync.Mutex
+}
+
+func (d *Dev) String() string {
+	return fmt.Sprintf("%s{%s}", d.name, d.c.Conn)
+}
+
+// Halt implements conn.Resource.
+//
+// It puts the device in power-down state.
+func (d *Dev) Halt() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.c.WriteUint16(regConfig, cfgPowerDown)
+}
+
+// PinForChannel returns a pin reading the input c.
+//
+// fsr is the highest absolute electric potential to be measured; the
+// smallest full scale range of the programmable gain amplifier covering it is
+// used. It is not possible to measure beyond VDD+0.3V whatever the range.
+//
+// f is the minimum data rate; the smallest one supported by the chip at or
+// above f is used. A higher data rate shortens the conversion time at the
+// cost of more noise.
+func (d *Dev) PinForChannel(c Channel, fsr physic.ElectricPotential, f physic.Frequency) (*Pin, error) {
+	if c > Channel3 {
+		return nil, fmt.Errorf("ads1x15: invalid channel %d", c)
+	}
+	pga := -1
+	for i := len(fullScales) - 1; i >= 0; i-- {
+		if fullScales[i] >= fsr {
+			pga = i
+			break
+		}
+	}
+	if pga == -1 || fsr <= 0 {
+		return nil, fmt.Errorf("ads1x15: invalid full scale range %s; maximum is %s", fsr, fullScales[0])
+	}
+	dr, err := d.dataRate(f)
+	if err != nil {
+		return nil, err
+	}
+	return &Pin{
+		d:    d,
+		c:    c,
+		pga:  uint16(pga),
+		dr:   uint16(dr),
+		fsr:  fullScales[pga],
+		rate: d.rates[dr],
+		comp: cfgCompQueDisable,
+	}, nil
+}
+
+// Pin is an input of the ADC.
+//
+// It implements analog.PinADCContinuous.
+type Pin struct {
+	d    *Dev
+	c    Channel
+	pga  uint16
+	dr   uint16
+	fsr  physic.ElectricPotential
+	rate physic.Frequency
+	comp uint16
+}
+
+// String implements conn.Resource.
+func (p *Pin) String() string {
+	return p.Name()
+}
+
+// Halt implements conn.Resource.
+//
+// It puts the device in power-down state.
+func (p *Pin) Halt() error {
+	return p.d.Halt()
+}
+
+// Name implements pin.Pin.
+func (p *Pin) Name() string {
+	return p.d.name + "_" + p.c.String()
+}
+
+// Number implements pin.Pin.
+func (p *Pin) Number() int {
+	return int(p.c)
+}
+
+// Function implements pin.Pin.
+func (p *Pin) Function() string {
+	return "ADC"
+}
+
+// Range implements analog.PinADC.
+func (p *Pin) Range() (analog.Sample, analog.Sample) {
+	max := int32(0x8000) >> p.d.shift
+	return analog.FromRaw(-max, max, p.fsr), analog.FromRaw(max-1, max, p.fsr)
+}
+
+// Read implements analog.PinADC.
+//
+// It runs a single-shot conversion and waits for its completion.
+func (p *Pin) Read() (analog.Sample, error) {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	if err := p.d.c.WriteUint16(regConfig, cfgOS|p.config()); err != nil {
+		return analog.Sample{}, err
+	}
+	doSleep(p.rate.Duration() + 100*time.Microsecond)
+	for i := 0; ; i++ {
+		v, err := p.d.c.ReadUint16(regConfig)
+		if err != nil {
+			return analog.Sample{}, err
+		}
+		if v&cfgOS != 0 {
+			break
+		}
+		if i == 10 {
+			return analog.Sample{}, errors.New("ads1x15: timed out waiting for conversion")
+		}
+		doSleep(p.rate.Duration() / 10)
+	}
+	return p.readConversion()
+}
+
+// ReadContinuous implements analog.PinADCContinuous.
+//
+// It uses the continuous conversion mode, with the data rate set to the
+// smallest one supported at or above f, and puts the device in power-down
+// state afterward.
+//
+// When Opts.Ready was specified and no Comparator is set, the samples are
+// read on each conversion ready pulse so they are taken at the data rate of
+// the chip. Otherwise the conversion register is read at the rate f.
+func (p *Pin) ReadContinuous(f physic.Frequency, b []analog.Sample) error {
+	if f <= 0 {
+		return errors.New("ads1x15: invalid sampling rate")
+	}
+	dr, err := p.d.dataRate(f)
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		return nil
+	}
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	useReady := p.d.ready != nil && p.comp == cfgCompQueDisable
+	cfg := p.config()&^(cfgModeSingle|cfgDRMask|cfgCompQueDisable) | uint16(dr)<<cfgDRShift
+	if useReady {
+		// Setting the MSB of Hi_thresh to 1 and the one of Lo_thresh to 0
+		// turns ALERT/RDY into a conversion ready signal.
+		if err := p.d.c.WriteUint16(regHiThresh, 0x8000); err != nil {
+			return err
+		}
+		if err := p.d.c.WriteUint16(regLoThresh, 0x0000); err != nil {
+			return err
+		}
+	} else {
+		cfg |= p.comp
+	}
+	if err := p.d.c.WriteUint16(regConfig, cfg); err != nil {
+		return err
+	}
+	err = p.readContinuous(f, p.d.rates[dr], useReady, b)
+	if err2 := p.d.c.WriteUint16(regConfig, cfgPowerDown); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// SetComparator enables the comparator on the conversion results of this
+// pin.
+//
+// Passing nil disables the comparator. The comparator is only active while
+// conversions are run on this pin. The threshold registers are shared by all
+// the pins of the device, and are overwritten by ReadContinuous() when
+// Opts.Ready is used.
+func (p *Pin) SetComparator(c *Comparator) error {
+	if c == nil {
+		p.comp = cfgCompQueDisable
+		return nil
+	}
+	var comp uint16
+	switch c.Queue {
+	case 1:
+	case 2:
+		comp = 1
+	case 4:
+		comp = 2
+	default:
+		return fmt.Errorf("ads1x15: invalid comparator queue %d; must be 1, 2 or 4", c.Queue)
+	}
+	if c.Low > c.High {
+		return errors.New("ads1x15: comparator Low threshold must not be above High")
+	}
+	if c.Window {
+		comp |= cfgCompWindow
+	}
+	if c.ActiveHigh {
+		comp |= cfgCompActiveHigh
+	}
+	if c.Latching {
+		comp |= cfgCompLatch
+	}
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	if err := p.d.c.WriteUint16(regLoThresh, p.toRegister(c.Low)); err != nil {
+		return err
+	}
+	if err := p.d.c.WriteUint16(regHiThresh, p.toRegister(c.High)); err != nil {
+		return err
+	}
+	p.comp = comp
+	return nil
+}
+
+//
+
+const (
+	regConversion uint8 = 0x00
+	regLoThresh   uint8 = 0x02
+	regHiThresh   uint8 = 0x03
+	regConfig     uint8 = 0x01
+
+	cfgOS             uint16 = 1 << 15 // Start a single conversion; reads 1 when idle
+	cfgMuxShift              = 12
+	cfgPGAShift              = 9
+	cfgModeSingle     uint16 = 1 << 8 // Single-shot or power-down mode
+	cfgDRShift               = 5
+	cfgDRMask         uint16 = 7 << cfgDRShift
+	cfgCompWindow     uint16 = 1 << 4
+	cfgCompActiveHigh uint16 = 1 << 3
+	cfgCompLatch      uint16 = 1 << 2
+	cfgCompQueDisable uint16 = 3
+	// cfgPowerDown is the reset value without starting a conversion.
+	cfgPowerDown uint16 = 0x0583
+)
+
+var channelNames = [...]string{"AIN0-AIN1", "AIN0-AIN3", "AIN1-AIN3", "AIN2-AIN3", "AIN0", "AIN1", "AIN2", "AIN3"}
+
+// fullScales is indexed by the PGA field; values 5 to 7 are all 0.256V.
+var fullScales = [...]physic.ElectricPotential{
+	6144 * physic.MilliVolt,
+	4096 * physic.MilliVolt,
+	2048 * physic.MilliVolt,
+	1024 * physic.MilliVolt,
+	512 * physic.MilliVolt,
+	256 * physic.MilliVolt,
+}
+
+// rates1015 and rates1115 are indexed by the DR field. The last value of
+// the ADS1015 table is also 3300Hz, so it is omitted.
+var rates1015 = [...]physic.Frequency{
+	128 * physic.Hertz,
+	250 * physic.Hertz,
+	490 * physic.Hertz,
+	920 * physic.Hertz,
+	1600 * physic.Hertz,
+	2400 * physic.Hertz,
+	3300 * physic.Hertz,
+}
+
+var rates1115 = [...]physic.Frequency{
+	8 * physic.Hertz,
+	16 * physic.Hertz,
+	32 * physic.Hertz,
+	64 * physic.Hertz,
+	128 * physic.Hertz,
+	250 * physic.Hertz,
+	475 * physic.Hertz,
+	860 * physic.Hertz,
+}
+
+func newDev(b i2c.Bus, opts *Opts, name string, shift uint, rates []physic.Frequency) (*Dev, error) {
+	if opts.I2CAddress < 0x48 || opts.I2CAddress > 0x4B {
+		return nil, fmt.Errorf("ads1x15: invalid I²C address 0x%X; must be between 0x48 and 0x4B", opts.I2CAddress)
+	}
+	d := &Dev{
+		c:     mmr.Dev8{Conn: &i2c.Dev{Bus: b, Addr: opts.I2CAddress}, Order: binary.BigEndian},
+		name:  name,
+		shift: shift,
+		rates: rates,
+		ready: opts.Ready,
+	}
+	// There is no identification register; make sure something answers.
+	if _, err := d.c.ReadUint16(regConfig); err != nil {
+		return nil, fmt.Errorf("ads1x15: failed to read config: %v", err)
+	}
+	if d.ready != nil {
+		if err := d.ready.In(gpio.PullUp, gpio.FallingEdge); err != nil {
+			return nil, fmt.Errorf("ads1x15: %v", err)
+		}
+	}
+	return d, nil
+}
+
+// dataRate returns the index of the smallest data rate at or above f.
+func (d *Dev) dataRate(f physic.Frequency) (int, error) {
+	for i, r := range d.rates {
+		if r >= f {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("ads1x15: invalid data rate %s; maximum is %s", f, d.rates[len(d.rates)-1])
+}
+
+// config returns the config register value for a single-shot conversion on
+// this pin, without the OS bit.
+func (p *Pin) config() uint16 {
+	return uint16(p.c)<<cfgMuxShift | p.pga<<cfgPGAShift | cfgModeSingle | p.dr<<cfgDRShift | p.comp
+}
+
+func (p *Pin) readContinuous(f, rate physic.Frequency, useReady bool, b []analog.Sample) error {
+	if useReady {
+		timeout := 2*rate.Duration() + 100*time.Millisecond
+		for i := range b {
+			if !p.d.ready.WaitForEdge(timeout) {
+				return errors.New("ads1x15: timed out waiting for ALERT/RDY")
+			}
+			var err error
+			if b[i], err = p.readConversion(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// Wait for the first conversion to complete.
+	doSleep(rate.Duration() + 100*time.Microsecond)
+	t := time.NewTicker(f.Duration())
+	defer t.Stop()
+	for i := range b {
+		if i != 0 {
+			<-t.C
+		}
+		var err error
+		if b[i], err = p.readConversion(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Pin) readConversion() (analog.Sample, error) {
+	v, err := p.d.c.ReadUint16(regConversion)
+	if err != nil {
+		return analog.Sample{}, err
+	}
+	return analog.FromRaw(int32(int16(v))>>p.d.shift, int32(0x8000)>>p.d.shift, p.fsr), nil
+}
+
+// toRegister converts an electric potential to a threshold register value,
+// clamped to the range of the pin.
+func (p *Pin) toRegister(v physic.ElectricPotential) uint16 {
+	max := int64(0x8000) >> p.d.shift
+	raw := int64(v) * max / int64(p.fsr)
+	if raw >= max {
+		raw = max - 1
+	} else if raw < -max {
+		raw = -max
+	}
+	return uint16(int16(raw << p.d.shift))
+}
+
+var doSleep = time.Sleep
+
+var _ analog.PinADCContinuous = &Pin{}
@@ -0,0 +1,327 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package ads1x15
+
+import (
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/analog"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/physic"
+)
+
+func TestNewADS1115_fail(t *testing.T) {
+	bus := i2ctest.Playback{DontPanic: true}
+	if _, err := NewADS1115(&bus, &DefaultOpts); err == nil {
+		t.Fatal("read failed")
+	}
+	if _, err := NewADS1115(&bus, &Opts{I2CAddress: 0x20}); err == nil {
+		t.Fatal("invalid address")
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestADS1115_Read(t *testing.T) {
+	bus := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x48, W: []byte{0x01}, R: []byte{0x85, 0x83}},
+			// Single-shot on AIN0, ±4.096V, 860SPS.
+			{Addr: 0x48, W: []byte{0x01, 0xC3, 0xE3}},
+			// Still converting.
+			{Addr: 0x48, W: []byte{0x01}, R: []byte{0x43, 0xE3}},
+			{Addr: 0x48, W: []byte{0x01}, R: []byte{0xC3, 0xE3}},
+			{Addr: 0x48, W: []byte{0x00}, R: []byte{0x40, 0x00}},
+			// Halt.
+			{Addr: 0x48, W: []byte{0x01, 0x05, 0x83}},
+		},
+	}
+	d, err := NewADS1115(&bus, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := d.String(); s != "ADS1115{playback(72)}" {
+		t.Fatal(s)
+	}
+	p, err := d.PinForChannel(Channel0, 3300*physic.MilliVolt, 800*physic.Hertz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := p.String(); s != "ADS1115_AIN0" {
+		t.Fatal(s)
+	}
+	if n := p.Number(); n != 4 {
+		t.Fatal(n)
+	}
+	if f := p.Function(); f != "ADC" {
+		t.Fatal(f)
+	}
+	min, max := p.Range()
+	if min.V != -4096*physic.MilliVolt || min.Raw != -32768 || max.Raw != 32767 {
+		t.Fatal(min, max)
+	}
+	s, err := p.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (analog.Sample{V: 2048 * physic.MilliVolt, Raw: 16384}); s != expected {
+		t.Fatal(s)
+	}
+	if err := p.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestADS1015_Read_differential(t *testing.T) {
+	bus := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x49, W: []byte{0x01}, R: []byte{0x85, 0x83}},
+			// Single-shot on AIN0-AIN1, ±0.256V, 1600SPS.
+			{Addr: 0x49, W: []byte{0x01, 0x8B, 0x83}},
+			{Addr: 0x49, W: []byte{0x01}, R: []byte{0x8B, 0x83}},
+			{Addr: 0x49, W: []byte{0x00}, R: []byte{0x7F, 0xF0}},
+			{Addr: 0x49, W: []byte{0x01, 0x8B, 0x83}},
+			{Addr: 0x49, W: []byte{0x01}, R: []byte{0x8B, 0x83}},
+			{Addr: 0x49, W: []byte{0x00}, R: []byte{0x80, 0x00}},
+		},
+	}
+	d, err := NewADS1015(&bus, &Opts{I2CAddress: 0x49})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := d.PinForChannel(Channel0Minus1, 200*physic.MilliVolt, physic.KiloHertz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := p.String(); s != "ADS1015_AIN0-AIN1" {
+		t.Fatal(s)
+	}
+	if _, max := p.Range(); max.Raw != 2047 {
+		t.Fatal(max)
+	}
+	s, err := p.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (analog.Sample{V: 255875 * physic.MicroVolt, Raw: 2047}); s != expected {
+		t.Fatal(s)
+	}
+	if s, err = p.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (analog.Sample{V: -256 * physic.MilliVolt, Raw: -2048}); s != expected {
+		t.Fatal(s)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestADS1115_Read_timeout(t *testing.T) {
+	ops := []i2ctest.IO{
+		{Addr: 0x48, W: []byte{0x01}, R: []byte{0x85, 0x83}},
+		{Addr: 0x48, W: []byte{0x01, 0xC3, 0xE3}},
+	}
+	for i := 0; i < 11; i++ {
+		ops = append(ops, i2ctest.IO{Addr: 0x48, W: []byte{0x01}, R: []byte{0x43, 0xE3}})
+	}
+	bus := i2ctest.Playback{Ops: ops}
+	d, err := NewADS1115(&bus, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := d.PinForChannel(Channel0, 4*physic.Volt, 860*physic.Hertz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Read(); err == nil || err.Error() != "ads1x15: timed out waiting for conversion" {
+		t.Fatal(err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestADS1115_PinForChannel_fail(t *testing.T) {
+	bus := i2ctest.Playback{
+		Ops: []i2ctest.IO{{Addr: 0x48, W: []byte{0x01}, R: []byte{0x85, 0x83}}},
+	}
+	d, err := NewADS1115(&bus, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.PinForChannel(Channel(8), physic.Volt, physic.Hertz); err == nil {
+		t.Fatal("invalid channel")
+	}
+	if _, err := d.PinForChannel(Channel0, 7*physic.Volt, physic.Hertz); err == nil {
+		t.Fatal("invalid range")
+	}
+	if _, err := d.PinForChannel(Channel0, 0, physic.Hertz); err == nil {
+		t.Fatal("invalid range")
+	}
+	if _, err := d.PinForChannel(Channel0, physic.Volt, physic.KiloHertz); err == nil {
+		t.Fatal("invalid data rate")
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestADS1115_SetComparator(t *testing.T) {
+	bus := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x48, W: []byte{0x01}, R: []byte{0x85, 0x83}},
+			// Thresholds 1V and 2V at ±4.096V.
+			{Addr: 0x48, W: []byte{0x02, 0x1F, 0x40}},
+			{Addr: 0x48, W: []byte{0x03, 0x3E, 0x80}},
+			// Window, latching, queue of 2.
+			{Addr: 0x48, W: []byte{0x01, 0xC3, 0xF5}},
+			{Addr: 0x48, W: []byte{0x01}, R: []byte{0xC3, 0xF5}},
+			{Addr: 0x48, W: []byte{0x00}, R: []byte{0x40, 0x00}},
+			// Disabled.
+			{Addr: 0x48, W: []byte{0x01, 0xC3, 0xE3}},
+			{Addr: 0x48, W: []byte{0x01}, R: []byte{0xC3, 0xE3}},
+			{Addr: 0x48, W: []byte{0x00}, R: []byte{0x40, 0x00}},
+		},
+	}
+	d, err := NewADS1115(&bus, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := d.PinForChannel(Channel0, 4*physic.Volt, 860*physic.Hertz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetComparator(&Comparator{Low: physic.Volt, High: 2 * physic.Volt, Queue: 3}); err == nil {
+		t.Fatal("invalid queue")
+	}
+	if err := p.SetComparator(&Comparator{Low: 2 * physic.Volt, High: physic.Volt, Queue: 1}); err == nil {
+		t.Fatal("invalid thresholds")
+	}
+	c := Comparator{Low: physic.Volt, High: 2 * physic.Volt, Window: true, Latching: true, Queue: 2}
+	if err := p.SetComparator(&c); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetComparator(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestADS1115_ReadContinuous(t *testing.T) {
+	bus := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x48, W: []byte{0x01}, R: []byte{0x85, 0x83}},
+			// Continuous on AIN0, ±4.096V, 860SPS.
+			{Addr: 0x48, W: []byte{0x01, 0x42, 0xE3}},
+			{Addr: 0x48, W: []byte{0x00}, R: []byte{0x40, 0x00}},
+			{Addr: 0x48, W: []byte{0x00}, R: []byte{0x20, 0x00}},
+			{Addr: 0x48, W: []byte{0x01, 0x05, 0x83}},
+		},
+	}
+	d, err := NewADS1115(&bus, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := d.PinForChannel(Channel0, 4*physic.Volt, 8*physic.Hertz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ReadContinuous(0, nil); err == nil {
+		t.Fatal("invalid rate")
+	}
+	if err := p.ReadContinuous(physic.MegaHertz, nil); err == nil {
+		t.Fatal("invalid rate")
+	}
+	if err := p.ReadContinuous(physic.KiloHertz/2, nil); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]analog.Sample, 2)
+	if err := analog.ReadContinuous(p, 860*physic.Hertz, b); err != nil {
+		t.Fatal(err)
+	}
+	if b[0].Raw != 16384 || b[1].V != 1024*physic.MilliVolt {
+		t.Fatal(b)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestADS1115_ReadContinuous_ready(t *testing.T) {
+	rdy := gpiotest.Pin{N: "RDY", EdgesChan: make(chan gpio.Level, 2)}
+	bus := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x48, W: []byte{0x01}, R: []byte{0x85, 0x83}},
+			{Addr: 0x48, W: []byte{0x03, 0x80, 0x00}},
+			{Addr: 0x48, W: []byte{0x02, 0x00, 0x00}},
+			// Continuous on AIN3, ±6.144V, 475SPS, comparator asserting after one
+			// conversion.
+			{Addr: 0x48, W: []byte{0x01, 0x70, 0xC0}},
+			{Addr: 0x48, W: []byte{0x00}, R: []byte{0x40, 0x00}},
+			{Addr: 0x48, W: []byte{0x00}, R: []byte{0x20, 0x00}},
+			{Addr: 0x48, W: []byte{0x01, 0x05, 0x83}},
+			// Timeout.
+			{Addr: 0x48, W: []byte{0x03, 0x80, 0x00}},
+			{Addr: 0x48, W: []byte{0x02, 0x00, 0x00}},
+			{Addr: 0x48, W: []byte{0x01, 0x70, 0xC0}},
+			{Addr: 0x48, W: []byte{0x01, 0x05, 0x83}},
+		},
+	}
+	d, err := NewADS1115(&bus, &Opts{I2CAddress: 0x48, Ready: &rdy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rdy.P != gpio.PullUp {
+		t.Fatal(rdy.P)
+	}
+	p, err := d.PinForChannel(Channel3, 5*physic.Volt, 8*physic.Hertz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdy.EdgesChan <- gpio.Low
+	rdy.EdgesChan <- gpio.Low
+	b := make([]analog.Sample, 2)
+	if err := p.ReadContinuous(400*physic.Hertz, b); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (analog.Sample{V: 3072 * physic.MilliVolt, Raw: 16384}); b[0] != expected {
+		t.Fatal(b[0])
+	}
+	if err := p.ReadContinuous(400*physic.Hertz, b); err == nil || err.Error() != "ads1x15: timed out waiting for ALERT/RDY" {
+		t.Fatal(err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChannel_String(t *testing.T) {
+	if s := Channel2Minus3.String(); s != "AIN2-AIN3" {
+		t.Fatal(s)
+	}
+	if s := Channel(8).String(); s != "Channel(8)" {
+		t.Fatal(s)
+	}
+}
+
+func init() {
+	doSleep = func(time.Duration) {}
+}
@@ -0,0 +1,19 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package ads1x15 controls a TI ADS1015 (12 bits) or ADS1115 (16 bits)
+// analog-to-digital converter over I²C.
+//
+// Each input, either single-ended or differential, is exposed as an
+// analog.PinADC. The chip has a single converter multiplexed across its
+// inputs, so conversions on different pins are serialized.
+//
+// Datasheets
+//
+// ADS1015:
+// http://www.ti.com/lit/ds/symlink/ads1015.pdf
+//
+// ADS1115:
+// http://www.ti.com/lit/ds/symlink/ads1115.pdf
+package ads1x15
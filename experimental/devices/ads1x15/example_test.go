@@ -0,0 +1,46 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package ads1x15_test
+
+import (
+	"fmt"
+	"log"
+
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/experimental/devices/ads1x15"
+	"periph.io/x/periph/host"
+)
+
+func Example() {
+	// Make sure periph is initialized.
+	if _, err := host.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Use i2creg I²C bus registry to find the first available I²C bus.
+	b, err := i2creg.Open("")
+	if err != nil {
+		log.Fatalf("failed to open I²C: %v", err)
+	}
+	defer b.Close()
+
+	d, err := ads1x15.NewADS1115(b, &ads1x15.DefaultOpts)
+	if err != nil {
+		log.Fatalf("failed to initialize ads1115: %v", err)
+	}
+	defer d.Halt()
+
+	// Measure up to 5V on AIN0 at 128 samples per second or faster.
+	p, err := d.PinForChannel(ads1x15.Channel0, 5*physic.Volt, 128*physic.Hertz)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s, err := p.Read()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s: %s\n", p, s)
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package bitbang
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/jtag"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/host/cpu"
+)
+
+// NewJTAG returns a jtag.Adapter that clocks the TAP over 4 pins.
+//
+// TDO is pulled up, as an absent device would then read as ones.
+//
+// Use jtag.NewTAP() to use it.
+func NewJTAG(tck, tms, tdi gpio.PinOut, tdo gpio.PinIn) (*JTAG, error) {
+	j := &JTAG{tck: tck, tms: tms, tdi: tdi, tdo: tdo}
+	if err := tck.Out(gpio.Low); err != nil {
+		return nil, fmt.Errorf("bitbang-jtag: failed to idle TCK: %v", err)
+	}
+	if err := tms.Out(gpio.High); err != nil {
+		return nil, fmt.Errorf("bitbang-jtag: failed to set TMS: %v", err)
+	}
+	if err := tdi.Out(gpio.High); err != nil {
+		return nil, fmt.Errorf("bitbang-jtag: failed to set TDI: %v", err)
+	}
+	if err := tdo.In(gpio.PullUp, gpio.NoEdge); err != nil {
+		return nil, fmt.Errorf("bitbang-jtag: failed to initialize TDO: %v", err)
+	}
+	return j, nil
+}
+
+// JTAG represents a JTAG adapter implemented as bit-banging on 4 GPIO pins.
+type JTAG struct {
+	// Immutable.
+	tck gpio.PinOut
+	tms gpio.PinOut
+	tdi gpio.PinOut
+	tdo gpio.PinIn
+
+	// Mutable.
+	mu        sync.Mutex
+	halfCycle time.Duration
+}
+
+func (j *JTAG) String() string {
+	return fmt.Sprintf("bitbang/jtag(%s, %s, %s, %s)", j.tck, j.tms, j.tdi, j.tdo)
+}
+
+// LimitSpeed sets the maximum TCK frequency.
+//
+// By default, TCK runs as fast as the GPIO pins can be toggled.
+func (j *JTAG) LimitSpeed(f physic.Frequency) error {
+	if f <= 0 {
+		return errors.New("bitbang-jtag: invalid frequency")
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.halfCycle = f.Duration() / 2
+	return nil
+}
+
+// Clock implements jtag.Adapter.
+//
+// TMS and TDI are set while TCK is low, TDO is sampled then TCK is raised.
+// Devices change TDO on the falling edge of TCK.
+func (j *JTAG) Clock(tms, tdi, tdo []byte, n int) error {
+	l := (n + 7) / 8
+	if n < 0 || len(tms) < l || (tdi != nil && len(tdi) < l) || (tdo != nil && len(tdo) < l) {
+		return errors.New("bitbang-jtag: buffers too short")
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i := 0; i < n; i++ {
+		mask := byte(1) << uint(i%8)
+		if err := j.tms.Out(tms[i/8]&mask != 0); err != nil {
+			return fmt.Errorf("bitbang-jtag: failed to set TMS: %v", err)
+		}
+		if err := j.tdi.Out(tdi != nil && tdi[i/8]&mask != 0); err != nil {
+			return fmt.Errorf("bitbang-jtag: failed to set TDI: %v", err)
+		}
+		j.sleepHalfCycle()
+		if tdo != nil {
+			if j.tdo.Read() {
+				tdo[i/8] |= mask
+			} else {
+				tdo[i/8] &^= mask
+			}
+		}
+		if err := j.tck.Out(gpio.High); err != nil {
+			return fmt.Errorf("bitbang-jtag: failed to raise TCK: %v", err)
+		}
+		j.sleepHalfCycle()
+		if err := j.tck.Out(gpio.Low); err != nil {
+			return fmt.Errorf("bitbang-jtag: failed to lower TCK: %v", err)
+		}
+	}
+	return nil
+}
+
+// TCK implements jtag.Pins.
+func (j *JTAG) TCK() gpio.PinOut {
+	return j.tck
+}
+
+// TMS implements jtag.Pins.
+func (j *JTAG) TMS() gpio.PinOut {
+	return j.tms
+}
+
+// TDI implements jtag.Pins.
+func (j *JTAG) TDI() gpio.PinOut {
+	return j.tdi
+}
+
+// TDO implements jtag.Pins.
+func (j *JTAG) TDO() gpio.PinIn {
+	return j.tdo
+}
+
+//
+
+// sleep does a busy loop to act as fast as possible.
+func (j *JTAG) sleepHalfCycle() {
+	cpu.Nanospin(j.halfCycle)
+}
+
+var _ jtag.Adapter = &JTAG{}
+var _ jtag.Pins = &JTAG{}
@@ -0,0 +1,406 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package dht
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiostream"
+	"periph.io/x/periph/conn/physic"
+)
+
+// Model is the sensor model.
+type Model int
+
+// Supported models.
+const (
+	DHT11 Model = 11
+	DHT22 Model = 22
+	// AM2302 is the DHT22 in a wired package.
+	AM2302 = DHT22
+)
+
+func (m Model) String() string {
+	switch m {
+	case DHT11:
+		return "DHT11"
+	case DHT22:
+		return "DHT22"
+	default:
+		return fmt.Sprintf("Model(%d)", int(m))
+	}
+}
+
+// Opts holds the configuration options.
+type Opts struct {
+	// Model is the sensor model.
+	Model Model
+	// Retries is the number of additional reads done when a read fails, which
+	// happens from time to time with this kind of sensor.
+	Retries int
+}
+
+// DefaultOpts is the recommended default options.
+var DefaultOpts = Opts{
+	Model:   DHT22,
+	Retries: 3,
+}
+
+// New returns an object that communicates with a DHT11 or DHT22 connected on
+// the data pin p.
+//
+// The pulses are sampled with gpiostream.PinIn when the pin implements it,
+// otherwise the timestamps of the edges are used when the pin implements
+// gpio.PinEdgeEvents. As a last resort, the edges are timed with
+// WaitForEdge().
+//
+// Only gpiostream.PinIn and kernel timestamps, like the ones of
+// sysfs.LinePin, are precise enough to reliably tell a 26µs pulse from a 70µs
+// one. With edges timestamped in userspace, many reads fail and are retried
+// as specified with Opts.Retries.
+func New(p gpio.PinIO, opts *Opts) (*Dev, error) {
+	d := &Dev{p: p, opts: *opts}
+	switch opts.Model {
+	case DHT11:
+		d.start = 20 * time.Millisecond
+		d.interval = time.Second
+	case DHT22:
+		d.start = 2 * time.Millisecond
+		d.interval = 2 * time.Second
+	default:
+		return nil, fmt.Errorf("dht: unsupported model %s", opts.Model)
+	}
+	if opts.Retries < 0 {
+		return nil, errors.New("dht: Retries must be positive")
+	}
+	// Idle state of the bus.
+	if err := p.In(gpio.PullUp, gpio.NoEdge); err != nil {
+		return nil, fmt.Errorf("dht: %v", err)
+	}
+	return d, nil
+}
+
+// Dev is a handle to a DHT11 or DHT22.
+type Dev struct {
+	p        gpio.PinIO
+	opts     Opts
+	start    time.Duration
+	interval time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func (d *Dev) String() string {
+	return fmt.Sprintf("%s{%s}", d.opts.Model, d.p)
+}
+
+// Sense implements physic.SenseEnv.
+//
+// It waits for the minimum interval between two reads of the sensor to have
+// elapsed. Pressure is not supported.
+func (d *Dev) Sense(e *physic.Env) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.sense(e)
+}
+
+// SenseContinuous implements physic.SenseEnv.
+//
+// The interval must be at least 1s for a DHT11 and 2s for a DHT22. A read
+// that still fails after Opts.Retries is logged and skipped.
+//
+// The application must call Halt() to stop the sensing when done.
+func (d *Dev) SenseContinuous(interval time.Duration) (<-chan physic.Env, error) {
+	if interval < d.interval {
+		return nil, fmt.Errorf("dht: interval must be at least %s for %s", d.interval, d.opts.Model)
+	}
+	d.halt()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	sensing := make(chan physic.Env)
+	stop := make(chan struct{})
+	d.stop = stop
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer close(sensing)
+		d.sensingContinuous(interval, sensing, stop)
+	}()
+	return sensing, nil
+}
+
+// Precision implements physic.SenseEnv.
+func (d *Dev) Precision(e *physic.Env) {
+	if d.opts.Model == DHT11 {
+		e.Temperature = physic.Kelvin
+		e.Humidity = physic.PercentRH
+		return
+	}
+	e.Temperature = 100 * physic.MilliKelvin
+	e.Humidity = physic.MilliRH
+}
+
+// Halt stops the continuous sensing initiated by SenseContinuous().
+func (d *Dev) Halt() error {
+	d.halt()
+	return nil
+}
+
+//
+
+// bitThreshold is the duration of the high pulse above which a bit is 1.
+//
+// A 0 is 26~28µs and a 1 is 70µs.
+const bitThreshold = 48 * time.Microsecond
+
+// captureFreq is the sampling rate when using gpiostream.PinIn.
+//
+// At 200kHz, which is the resolution supported by bcm283x, each sample is 5µs.
+const captureFreq = 200 * physic.KiloHertz
+
+// captureLen is the number of bytes to capture a whole transmission, which
+// lasts at most 5.2ms; 200 bytes at 200kHz is 8ms.
+const captureLen = 200
+
+// maxEdges is the number of edges of a whole transmission: the response low
+// and high, the 40 bits and the final release of the bus.
+const maxEdges = 2 + 2*40 + 2
+
+// edgeTimeout is the maximum time between two edges of a transmission.
+const edgeTimeout = 10 * time.Millisecond
+
+// edge is a transition of the data line at t since the start of the
+// capture.
+type edge struct {
+	t time.Duration
+	l gpio.Level
+}
+
+var (
+	doSleep = time.Sleep
+	timeNow = time.Now
+)
+
+func (d *Dev) halt() {
+	d.mu.Lock()
+	stop := d.stop
+	d.stop = nil
+	d.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		d.wg.Wait()
+	}
+}
+
+// sense reads the sensor, retrying on failure.
+//
+// d.mu must be held.
+func (d *Dev) sense(e *physic.Env) error {
+	var err error
+	for i := 0; i <= d.opts.Retries; i++ {
+		// The sensor doesn't respond when read too often.
+		if w := d.interval - time.Since(d.last); !d.last.IsZero() && w > 0 {
+			doSleep(w)
+		}
+		var b [5]byte
+		if b, err = d.read(); err == nil {
+			d.convert(b, e)
+			return nil
+		}
+	}
+	return err
+}
+
+// read does one transaction with the sensor and returns the 5 bytes sent,
+// with the checksum validated.
+func (d *Dev) read() ([5]byte, error) {
+	// The host signals the start by pulling the line low, then releases it;
+	// the sensor responds by pulling it low for 80µs then high for 80µs, and
+	// then sends 40 bits. Each bit is 50µs low followed by a high pulse whose
+	// duration determines its value.
+	defer func() {
+		d.last = time.Now()
+	}()
+	if err := d.p.Out(gpio.Low); err != nil {
+		return [5]byte{}, fmt.Errorf("dht: %v", err)
+	}
+	doSleep(d.start)
+	var edges []edge
+	var err error
+	if s, ok := d.p.(gpiostream.PinIn); ok {
+		edges, err = d.capture(s)
+	} else if p, ok := d.p.(gpio.PinEdgeEvents); ok {
+		edges, err = d.watchEvents(p)
+	} else {
+		edges, err = d.watch()
+	}
+	if err != nil {
+		return [5]byte{}, err
+	}
+	return decode(pulses(edges))
+}
+
+// capture samples the line with gpiostream.PinIn.
+//
+// StreamIn() releases the line by setting the pin as input.
+func (d *Dev) capture(s gpiostream.PinIn) ([]edge, error) {
+	b := gpiostream.BitStream{Freq: captureFreq, LSBF: true, Bits: make([]byte, captureLen)}
+	if err := s.StreamIn(gpio.PullUp, &b); err != nil {
+		return nil, fmt.Errorf("dht: %v", err)
+	}
+	return edgesFromBits(&b), nil
+}
+
+// watchEvents collects the edges timestamped by the pin.
+//
+// In() releases the line by setting the pin as input.
+func (d *Dev) watchEvents(p gpio.PinEdgeEvents) ([]edge, error) {
+	if err := d.p.In(gpio.PullUp, gpio.BothEdges); err != nil {
+		return nil, fmt.Errorf("dht: %v", err)
+	}
+	var edges []edge
+	var t0 time.Time
+	for len(edges) < maxEdges {
+		e, ok := p.WaitForEdgeEvent(edgeTimeout)
+		if !ok {
+			break
+		}
+		if len(edges) == 0 {
+			t0 = e.T
+		}
+		edges = append(edges, edge{e.T.Sub(t0), e.L})
+	}
+	overflows := p.EdgeOverflows()
+	if err := d.p.In(gpio.PullUp, gpio.NoEdge); err != nil {
+		return nil, fmt.Errorf("dht: %v", err)
+	}
+	if overflows != 0 {
+		return nil, fmt.Errorf("dht: %d edges were lost", overflows)
+	}
+	return edges, nil
+}
+
+// watch times the edges as they are returned by WaitForEdge().
+//
+// In() releases the line by setting the pin as input.
+func (d *Dev) watch() ([]edge, error) {
+	if err := d.p.In(gpio.PullUp, gpio.BothEdges); err != nil {
+		return nil, fmt.Errorf("dht: %v", err)
+	}
+	var edges []edge
+	start := timeNow()
+	for len(edges) < maxEdges && d.p.WaitForEdge(edgeTimeout) {
+		edges = append(edges, edge{timeNow().Sub(start), d.p.Read()})
+	}
+	if err := d.p.In(gpio.PullUp, gpio.NoEdge); err != nil {
+		return nil, fmt.Errorf("dht: %v", err)
+	}
+	return edges, nil
+}
+
+func (d *Dev) convert(b [5]byte, e *physic.Env) {
+	if d.opts.Model == DHT11 {
+		// Integral and decimal parts. The sign is the MSB of the decimal part of
+		// the temperature.
+		e.Humidity = physic.RelativeHumidity(b[0])*physic.PercentRH + physic.RelativeHumidity(b[1]%10)*physic.MilliRH
+		t := physic.Temperature(b[2])*physic.Kelvin + physic.Temperature((b[3]&0x7F)%10)*100*physic.MilliKelvin
+		if b[3]&0x80 != 0 {
+			t = -t
+		}
+		e.Temperature = physic.ZeroCelsius + t
+		return
+	}
+	// Tenths of %rH and tenths of °C, with the sign as the MSB.
+	e.Humidity = physic.RelativeHumidity(uint16(b[0])<<8|uint16(b[1])) * physic.MilliRH
+	t := physic.Temperature(uint16(b[2]&0x7F)<<8|uint16(b[3])) * 100 * physic.MilliKelvin
+	if b[2]&0x80 != 0 {
+		t = -t
+	}
+	e.Temperature = physic.ZeroCelsius + t
+}
+
+func (d *Dev) sensingContinuous(interval time.Duration, sensing chan<- physic.Env, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		// Do one initial sensing right away.
+		e := physic.Env{}
+		d.mu.Lock()
+		err := d.sense(&e)
+		d.mu.Unlock()
+		if err != nil {
+			log.Printf("%s: failed to sense: %v", d, err)
+		} else {
+			select {
+			case sensing <- e:
+			case <-stop:
+				return
+			}
+		}
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// edgesFromBits returns the transitions in a LSB-first capture.
+func edgesFromBits(b *gpiostream.BitStream) []edge {
+	period := b.Freq.Duration()
+	var edges []edge
+	prev := b.Bits[0]&1 != 0
+	for i := 1; i < 8*len(b.Bits); i++ {
+		v := b.Bits[i/8]&(1<<uint(i%8)) != 0
+		if v != prev {
+			edges = append(edges, edge{time.Duration(i) * period, gpio.Level(v)})
+			prev = v
+		}
+	}
+	return edges
+}
+
+// pulses returns the duration of each high pulse.
+func pulses(edges []edge) []time.Duration {
+	var out []time.Duration
+	for i := 1; i < len(edges); i++ {
+		if edges[i-1].l == gpio.High && edges[i].l == gpio.Low {
+			out = append(out, edges[i].t-edges[i-1].t)
+		}
+	}
+	return out
+}
+
+// decode converts the high pulses into the 5 bytes sent by the sensor.
+//
+// The last 40 pulses are the data bits, MSB first. They must be preceded by
+// the 80µs response pulse.
+func decode(p []time.Duration) ([5]byte, error) {
+	var b [5]byte
+	if len(p) < 41 {
+		return b, fmt.Errorf("dht: got %d pulses, expected 41", len(p))
+	}
+	p = p[len(p)-40:]
+	for i, d := range p {
+		if d > bitThreshold {
+			b[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	if s := b[0] + b[1] + b[2] + b[3]; s != b[4] {
+		return b, fmt.Errorf("dht: invalid checksum 0x%02X, expected 0x%02X", b[4], s)
+	}
+	return b, nil
+}
+
+var _ physic.SenseEnv = &Dev{}
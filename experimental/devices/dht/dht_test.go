@@ -0,0 +1,353 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package dht
+
+import (
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiostream"
+	"periph.io/x/periph/conn/gpio/gpiostream/gpiostreamtest"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/physic"
+)
+
+func TestDHT22_stream(t *testing.T) {
+	p := streamPin{Pin: gpiotest.Pin{N: "GPIO4"}}
+	p.Ops = []gpiostreamtest.InOp{
+		// 65.2%rH, 23.5°C.
+		{Pull: gpio.PullUp, BitStream: capture([]byte{0x02, 0x8C, 0x00, 0xEB, 0x79})},
+		// 40.0%rH, -10.1°C.
+		{Pull: gpio.PullUp, BitStream: capture([]byte{0x01, 0x90, 0x80, 0x65, 0x76})},
+	}
+	d, err := New(&p, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := d.String(); s != "DHT22{GPIO4(0)}" {
+		t.Fatal(s)
+	}
+	e := physic.Env{}
+	if err := d.Sense(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Humidity != 652*physic.MilliRH || e.Temperature != physic.ZeroCelsius+23500*physic.MilliKelvin {
+		t.Fatal(e)
+	}
+	if err := d.Sense(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Humidity != 400*physic.MilliRH || e.Temperature != physic.ZeroCelsius-10100*physic.MilliKelvin {
+		t.Fatal(e)
+	}
+	d.Precision(&e)
+	if e.Humidity != physic.MilliRH || e.Temperature != 100*physic.MilliKelvin {
+		t.Fatal(e)
+	}
+	if err := d.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PinIn.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDHT22_retries(t *testing.T) {
+	p := streamPin{Pin: gpiotest.Pin{N: "GPIO4"}}
+	p.Ops = []gpiostreamtest.InOp{
+		// Bad checksum.
+		{Pull: gpio.PullUp, BitStream: capture([]byte{0x02, 0x8C, 0x00, 0xEB, 0x78})},
+		// No response.
+		{Pull: gpio.PullUp, BitStream: gpiostream.BitStream{Freq: captureFreq, LSBF: true, Bits: make([]byte, captureLen)}},
+		{Pull: gpio.PullUp, BitStream: capture([]byte{0x02, 0x8C, 0x00, 0xEB, 0x79})},
+		// Retries exhausted.
+		{Pull: gpio.PullUp, BitStream: capture([]byte{0x02, 0x8C, 0x00, 0xEB, 0x78})},
+		{Pull: gpio.PullUp, BitStream: capture([]byte{0x02, 0x8C, 0x00, 0xEB, 0x78})},
+	}
+	d, err := New(&p, &Opts{Model: AM2302, Retries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := physic.Env{}
+	if err := d.Sense(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Humidity != 652*physic.MilliRH {
+		t.Fatal(e)
+	}
+	d.opts.Retries = 1
+	if err := d.Sense(&e); err == nil {
+		t.Fatal("invalid checksum")
+	}
+	if err := p.PinIn.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDHT11_events(t *testing.T) {
+	// 45%rH, 22.0°C.
+	p := eventsPin{Pin: gpiotest.Pin{N: "GPIO17", EdgesChan: make(chan gpio.Level)}, events: events([]byte{0x2D, 0x00, 0x16, 0x00, 0x43})}
+	d, err := New(&p, &Opts{Model: DHT11})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := physic.Env{}
+	if err := d.Sense(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Humidity != 45*physic.PercentRH || e.Temperature != physic.ZeroCelsius+22*physic.Kelvin {
+		t.Fatal(e)
+	}
+	if p.P != gpio.PullUp {
+		t.Fatal(p.P)
+	}
+	d.Precision(&e)
+	if e.Humidity != physic.PercentRH || e.Temperature != physic.Kelvin {
+		t.Fatal(e)
+	}
+	// Lost edges.
+	p.events = events([]byte{0x2D, 0x00, 0x16, 0x00, 0x43})
+	p.overflows = 1
+	if err := d.Sense(&e); err == nil {
+		t.Fatal("edges were lost")
+	}
+}
+
+func TestDHT22_trace(t *testing.T) {
+	// Kernel timestamps in ns of the edges of a 60.7%rH, 24.2°C transmission,
+	// alternating falling and rising edges, with the pulse widths spread as
+	// the sensor does within the datasheet tolerances.
+	trace := []time.Duration{
+		5123456789, 5123537441, 5123623205, 5123672676, 5123700144, 5123747935,
+		5123770121, 5123825900, 5123848442, 5123901433, 5123923383, 5123978696,
+		5124003213, 5124050827, 5124073235, 5124127339, 5124198764, 5124246908,
+		5124271851, 5124320337, 5124348292, 5124396260, 5124471033, 5124520061,
+		5124544718, 5124592731, 5124665458, 5124718957, 5124787363, 5124837985,
+		5124906366, 5124955547, 5125025919, 5125079786, 5125148967, 5125204825,
+		5125227754, 5125279808, 5125303769, 5125352457, 5125376535, 5125429636,
+		5125452232, 5125508206, 5125530234, 5125578210, 5125602584, 5125657717,
+		5125687428, 5125741433, 5125767579, 5125822207, 5125895003, 5125949427,
+		5126020389, 5126072300, 5126142335, 5126192280, 5126266006, 5126317005,
+		5126339346, 5126391265, 5126420869, 5126475980, 5126551149, 5126603776,
+		5126632129, 5126683846, 5126706045, 5126754979, 5126827172, 5126881022,
+		5126904724, 5126957328, 5127026573, 5127081584, 5127109493, 5127157135,
+		5127179406, 5127231546, 5127302332, 5127355069, 5127427938, 5127480006,
+	}
+	p := eventsPin{Pin: gpiotest.Pin{N: "GPIO17", EdgesChan: make(chan gpio.Level)}}
+	for i, ts := range trace {
+		p.events = append(p.events, gpio.EdgeEvent{T: time.Unix(0, int64(ts)), L: i&1 != 0})
+	}
+	d, err := New(&p, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := physic.Env{}
+	if err := d.Sense(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Humidity != 607*physic.MilliRH || e.Temperature != physic.ZeroCelsius+24200*physic.MilliKelvin {
+		t.Fatal(e)
+	}
+}
+
+func TestDHT22_edges(t *testing.T) {
+	// 65.2%rH, 23.5°C.
+	p := edgePin{
+		PinIO: &gpiotest.Pin{N: "GPIO4", EdgesChan: make(chan gpio.Level)},
+		segs:  transmission([]byte{0x02, 0x8C, 0x00, 0xEB, 0x79}),
+	}
+	defer func() {
+		timeNow = time.Now
+	}()
+	timeNow = func() time.Time {
+		return p.now
+	}
+	d, err := New(&p, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := physic.Env{}
+	if err := d.Sense(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Humidity != 652*physic.MilliRH || e.Temperature != physic.ZeroCelsius+23500*physic.MilliKelvin {
+		t.Fatal(e)
+	}
+	// No edge at all.
+	if err := d.Sense(&e); err == nil {
+		t.Fatal("no response")
+	}
+}
+
+func TestSenseContinuous(t *testing.T) {
+	p := streamPin{Pin: gpiotest.Pin{N: "GPIO4"}}
+	p.Ops = []gpiostreamtest.InOp{
+		{Pull: gpio.PullUp, BitStream: capture([]byte{0x02, 0x8C, 0x00, 0xEB, 0x79})},
+	}
+	d, err := New(&p, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.SenseContinuous(time.Second); err == nil {
+		t.Fatal("interval is too short")
+	}
+	c, err := d.SenseContinuous(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := <-c; e.Humidity != 652*physic.MilliRH {
+		t.Fatal(e)
+	}
+	if err := d.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-c; ok {
+		t.Fatal("channel must be closed")
+	}
+	if err := p.PinIn.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNew_fail(t *testing.T) {
+	p := gpiotest.Pin{N: "GPIO4"}
+	if _, err := New(&p, &Opts{Model: 23}); err == nil {
+		t.Fatal("invalid model")
+	}
+	if _, err := New(&p, &Opts{Model: DHT22, Retries: -1}); err == nil {
+		t.Fatal("invalid retries")
+	}
+}
+
+func TestModel_String(t *testing.T) {
+	if s := AM2302.String(); s != "DHT22" {
+		t.Fatal(s)
+	}
+	if s := Model(1).String(); s != "Model(1)" {
+		t.Fatal(s)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	if _, err := decode(make([]time.Duration, 40)); err == nil {
+		t.Fatal("missing response pulse")
+	}
+}
+
+//
+
+// streamPin is a fake pin supporting gpiostream.PinIn.
+type streamPin struct {
+	gpiotest.Pin
+	gpiostreamtest.PinIn
+}
+
+func (p *streamPin) String() string {
+	return p.Pin.String()
+}
+
+func (p *streamPin) Halt() error {
+	return nil
+}
+
+// eventsPin is a fake pin returning timestamped edges.
+type eventsPin struct {
+	gpiotest.Pin
+	events    []gpio.EdgeEvent
+	overflows int
+}
+
+func (p *eventsPin) WaitForEdgeEvent(timeout time.Duration) (gpio.EdgeEvent, bool) {
+	if len(p.events) == 0 {
+		return gpio.EdgeEvent{}, false
+	}
+	e := p.events[0]
+	p.events = p.events[1:]
+	return e, true
+}
+
+func (p *eventsPin) EdgeOverflows() int {
+	return p.overflows
+}
+
+// edgePin is a fake pin only supporting WaitForEdge(), which advances the
+// clock returned by timeNow to the next edge of segs.
+type edgePin struct {
+	gpio.PinIO
+	segs []segment
+	now  time.Time
+}
+
+func (p *edgePin) WaitForEdge(timeout time.Duration) bool {
+	if len(p.segs) < 2 {
+		return false
+	}
+	p.now = p.now.Add(p.segs[0].d)
+	p.segs = p.segs[1:]
+	return true
+}
+
+func (p *edgePin) Read() gpio.Level {
+	if len(p.segs) == 0 {
+		return gpio.High
+	}
+	return p.segs[0].l
+}
+
+// segment is a duration during which the line stays at a level.
+type segment struct {
+	l gpio.Level
+	d time.Duration
+}
+
+// transmission returns the waveform on the data line, right after the host
+// released it, when the sensor sends b.
+func transmission(b []byte) []segment {
+	s := []segment{
+		{gpio.High, 30 * time.Microsecond},
+		{gpio.Low, 80 * time.Microsecond},
+		{gpio.High, 80 * time.Microsecond},
+	}
+	for i := 0; i < 8*len(b); i++ {
+		d := 25 * time.Microsecond
+		if b[i/8]&(0x80>>uint(i%8)) != 0 {
+			d = 70 * time.Microsecond
+		}
+		s = append(s, segment{gpio.Low, 50 * time.Microsecond}, segment{gpio.High, d})
+	}
+	return append(s, segment{gpio.Low, 50 * time.Microsecond}, segment{gpio.High, 10 * time.Millisecond})
+}
+
+// capture returns the transmission of b as sampled by StreamIn().
+func capture(b []byte) gpiostream.BitStream {
+	out := gpiostream.BitStream{Freq: captureFreq, LSBF: true, Bits: make([]byte, captureLen)}
+	period := captureFreq.Duration()
+	i := 0
+	for _, s := range transmission(b) {
+		for n := s.d / period; n > 0 && i < 8*captureLen; n-- {
+			if s.l {
+				out.Bits[i/8] |= 1 << uint(i%8)
+			}
+			i++
+		}
+	}
+	return out
+}
+
+// events returns the transmission of b as timestamped edges.
+func events(b []byte) []gpio.EdgeEvent {
+	var out []gpio.EdgeEvent
+	t := time.Unix(1, 0)
+	for _, s := range transmission(b)[1:] {
+		out = append(out, gpio.EdgeEvent{T: t, L: s.l})
+		t = t.Add(s.d)
+	}
+	return out
+}
+
+func init() {
+	doSleep = func(time.Duration) {}
+}
@@ -0,0 +1,22 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package dht controls a DHT11, DHT22 or AM2302 temperature and relative
+// humidity sensor over its single wire protocol.
+//
+// The sensor sends 40 bits encoded as the duration of high pulses, which is
+// too fast to be reliably read by bit banging from user space. The pulses are
+// sampled with gpiostream.PinIn when the pin supports it, like on bcm283x,
+// otherwise the edges are timed, preferably with the timestamps returned by
+// gpio.PinEdgeEvents.
+//
+// The sensor must not be read more than once per second for the DHT11 or
+// every 2 seconds for the DHT22.
+//
+// Datasheets
+//
+// https://www.mouser.com/ds/2/758/DHT11-Technical-Data-Sheet-Translated-Version-1143054.pdf
+//
+// https://www.sparkfun.com/datasheets/Sensors/Temperature/DHT22.pdf
+package dht
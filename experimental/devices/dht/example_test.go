@@ -0,0 +1,38 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package dht_test
+
+import (
+	"fmt"
+	"log"
+
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/experimental/devices/dht"
+	"periph.io/x/periph/host"
+)
+
+func Example() {
+	// Make sure periph is initialized.
+	if _, err := host.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	p := gpioreg.ByName("GPIO4")
+	if p == nil {
+		log.Fatal("failed to find GPIO4")
+	}
+	d, err := dht.New(p, &dht.DefaultOpts)
+	if err != nil {
+		log.Fatalf("failed to initialize dht: %v", err)
+	}
+	defer d.Halt()
+
+	e := physic.Env{}
+	if err := d.Sense(&e); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%8s %9s\n", e.Temperature, e.Humidity)
+}
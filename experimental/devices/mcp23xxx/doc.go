@@ -0,0 +1,21 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package mcp23xxx controls a Microchip MCP23017 (I²C) or MCP23S17 (SPI) 16
+// bits I/O expander.
+//
+// The 16 lines are exposed as gpio.PinIO and registered in gpioreg as
+// "<chip>_<hardware address>_<line>", for example "MCP23017_0_GPA3" or
+// "MCP23S17_2_GPB7".
+//
+// When the INT line of the expander is connected to a GPIO, the interrupt on
+// change of the expander is used to implement WaitForEdge() on each line. It
+// also keeps the input values cached, so Read() doesn't require a bus
+// transaction. Read() on an output line always returns the cached output
+// latch.
+//
+// Datasheet
+//
+// http://ww1.microchip.com/downloads/en/DeviceDoc/20001952C.pdf
+package mcp23xxx
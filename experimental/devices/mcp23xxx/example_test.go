@@ -0,0 +1,52 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package mcp23xxx_test
+
+import (
+	"fmt"
+	"log"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/experimental/devices/mcp23xxx"
+	"periph.io/x/periph/host"
+)
+
+func Example() {
+	// Make sure periph is initialized.
+	if _, err := host.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Use i2creg I²C bus registry to find the first available I²C bus.
+	b, err := i2creg.Open("")
+	if err != nil {
+		log.Fatalf("failed to open I²C: %v", err)
+	}
+	defer b.Close()
+
+	// The INTA pin of the expander is wired to GPIO17.
+	opts := mcp23xxx.Opts{Interrupt: gpioreg.ByName("GPIO17")}
+	d, err := mcp23xxx.NewMCP23017(b, &opts)
+	if err != nil {
+		log.Fatalf("failed to initialize mcp23017: %v", err)
+	}
+	defer d.Close()
+
+	// The expander lines are regular GPIO pins.
+	button := gpioreg.ByName("MCP23017_0_GPA0")
+	if err := button.In(gpio.PullUp, gpio.FallingEdge); err != nil {
+		log.Fatal(err)
+	}
+	led := gpioreg.ByName("MCP23017_0_GPB0")
+	for l := gpio.High; ; l = !l {
+		button.WaitForEdge(-1)
+		fmt.Printf("pressed\n")
+		if err := led.Out(l); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
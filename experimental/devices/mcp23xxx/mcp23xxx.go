@@ -0,0 +1,574 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package mcp23xxx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/mmr"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/pin"
+	"periph.io/x/periph/conn/spi"
+)
+
+// Opts holds the configuration options.
+type Opts struct {
+	// HardwareAddress is the address set by the wiring of the A2~A0 pins,
+	// between 0 and 7.
+	HardwareAddress uint8
+	// Interrupt is the optional GPIO connected to the INTA or INTB pin of the
+	// expander. It is required to use edge detection on the expander lines.
+	Interrupt gpio.PinIn
+}
+
+// DefaultOpts is the recommended default options.
+var DefaultOpts = Opts{}
+
+// NewMCP23017 returns an object that communicates over I²C to a MCP23017.
+func NewMCP23017(b i2c.Bus, opts *Opts) (*Dev, error) {
+	if opts.HardwareAddress > 7 {
+		return nil, fmt.Errorf("mcp23xxx: invalid hardware address %d", opts.HardwareAddress)
+	}
+	c := &i2c.Dev{Bus: b, Addr: 0x20 | uint16(opts.HardwareAddress)}
+	return newDev(c, opts, "MCP23017", 0)
+}
+
+// NewMCP23S17 returns an object that communicates over SPI to a MCP23S17.
+//
+// Multiple MCP23S17 with different hardware addresses can share the same
+// chip select.
+func NewMCP23S17(p spi.Port, opts *Opts) (*Dev, error) {
+	if opts.HardwareAddress > 7 {
+		return nil, fmt.Errorf("mcp23xxx: invalid hardware address %d", opts.HardwareAddress)
+	}
+	c, err := p.Connect(10*physic.MegaHertz, spi.Mode0, 8)
+	if err != nil {
+		return nil, fmt.Errorf("mcp23xxx: %v", err)
+	}
+	// IOCON.HAEN is cleared at power on, so the chip only answers to the
+	// hardware address 0 until it is set.
+	s := &spiConn{c: c, opcode: 0x40}
+	if err := s.Tx([]byte{regIOCON, ioconMirror | ioconHAEN}, nil); err != nil {
+		return nil, fmt.Errorf("mcp23xxx: %v", err)
+	}
+	s.opcode |= opts.HardwareAddress << 1
+	return newDev(s, opts, "MCP23S17", ioconHAEN)
+}
+
+// Dev is a handle to a MCP23017 or MCP23S17.
+type Dev struct {
+	c    mmr.Dev8
+	name string
+	intr gpio.PinIn
+	pins [16]Pin
+
+	mu sync.Mutex
+	// Cached registers; bit n is the line n, GPA0 being 0 and GPB7 being 15.
+	iodir   uint16
+	ipol    uint16
+	gpinten uint16
+	gppu    uint16
+	olat    uint16
+	// gpio is the last input value read. It is kept up to date by the
+	// interrupt on change when Opts.Interrupt is set.
+	gpio   uint16
+	closed bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func (d *Dev) String() string {
+	return fmt.Sprintf("%s{%s}", d.name, d.c.Conn)
+}
+
+// Halt implements conn.Resource.
+//
+// It disables edge detection on all the lines.
+func (d *Dev) Halt() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.pins {
+		d.pins[i].edge = gpio.NoEdge
+	}
+	return nil
+}
+
+// Close disables the interrupts and unregisters the lines from gpioreg.
+func (d *Dev) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return errors.New("mcp23xxx: already closed")
+	}
+	d.closed = true
+	d.mu.Unlock()
+	if d.intr != nil {
+		close(d.stop)
+		<-d.done
+	}
+	for i := range d.pins {
+		_ = gpioreg.Unregister(d.pins[i].name)
+	}
+	if err := d.Halt(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.writeCached(regGPINTEN, &d.gpinten, 0)
+}
+
+// Pin returns the line n, between 0 (GPA0) and 15 (GPB7).
+func (d *Dev) Pin(n int) (*Pin, error) {
+	if n < 0 || n >= len(d.pins) {
+		return nil, fmt.Errorf("mcp23xxx: invalid line %d", n)
+	}
+	return &d.pins[n], nil
+}
+
+// Pin is a line of the expander.
+//
+// It implements gpio.PinIO.
+type Pin struct {
+	d     *Dev
+	n     uint
+	name  string
+	edges chan struct{}
+
+	// Protected by d.mu.
+	edge gpio.Edge
+}
+
+// String implements conn.Resource.
+func (p *Pin) String() string {
+	return p.name
+}
+
+// Halt implements conn.Resource.
+//
+// It disables edge detection on the line.
+func (p *Pin) Halt() error {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	p.edge = gpio.NoEdge
+	return nil
+}
+
+// Name implements pin.Pin.
+func (p *Pin) Name() string {
+	return p.name
+}
+
+// Number implements pin.Pin.
+func (p *Pin) Number() int {
+	return int(p.n)
+}
+
+// Function implements pin.Pin.
+func (p *Pin) Function() string {
+	return string(p.Func())
+}
+
+// Func implements pin.PinFunc.
+func (p *Pin) Func() pin.Func {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	switch {
+	case p.d.iodir&p.mask() != 0:
+		return gpio.IN
+	case p.d.olat&p.mask() != 0:
+		return gpio.OUT_HIGH
+	default:
+		return gpio.OUT_LOW
+	}
+}
+
+// SupportedFuncs implements pin.PinFunc.
+func (p *Pin) SupportedFuncs() []pin.Func {
+	return []pin.Func{gpio.IN, gpio.OUT}
+}
+
+// SetFunc implements pin.PinFunc.
+func (p *Pin) SetFunc(f pin.Func) error {
+	switch f {
+	case gpio.IN:
+		return p.In(gpio.PullNoChange, gpio.NoEdge)
+	case gpio.OUT, gpio.OUT_LOW:
+		return p.Out(gpio.Low)
+	case gpio.OUT_HIGH:
+		return p.Out(gpio.High)
+	default:
+		return fmt.Errorf("mcp23xxx: function %s is not supported", f)
+	}
+}
+
+// In implements gpio.PinIn.
+//
+// Only gpio.PullUp, gpio.Float and gpio.PullNoChange are supported. Edge
+// detection requires Opts.Interrupt.
+func (p *Pin) In(pull gpio.Pull, edge gpio.Edge) error {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	if p.d.closed {
+		return errors.New("mcp23xxx: already closed")
+	}
+	if edge != gpio.NoEdge && p.d.intr == nil {
+		return errors.New("mcp23xxx: edge detection requires Opts.Interrupt")
+	}
+	m := p.mask()
+	gppu := p.d.gppu
+	switch pull {
+	case gpio.PullUp:
+		gppu |= m
+	case gpio.Float:
+		gppu &^= m
+	case gpio.PullNoChange:
+	default:
+		return fmt.Errorf("mcp23xxx: pull %s is not supported", pull)
+	}
+	if err := p.d.writeCached(regGPPU, &p.d.gppu, gppu); err != nil {
+		return err
+	}
+	if err := p.d.setIODIR(p.d.iodir | m); err != nil {
+		return err
+	}
+	p.edge = gpio.NoEdge
+	if p.d.intr != nil {
+		// Refresh the cached value, which is the reference value to compare to
+		// for edges. This also acknowledges a pending interrupt.
+		if _, err := p.d.readGPIO(); err != nil {
+			return err
+		}
+	}
+	p.edge = edge
+	// Flush any accumulated edge.
+	select {
+	case <-p.edges:
+	default:
+	}
+	return nil
+}
+
+// Read implements gpio.PinIn.
+//
+// The level of an output line is returned from the cached output latch. The
+// level of an input line is returned from the value cached from the last
+// interrupt when Opts.Interrupt is set; otherwise it is read from the bus.
+func (p *Pin) Read() gpio.Level {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	m := p.mask()
+	if p.d.iodir&m == 0 {
+		return gpio.Level(p.d.olat&m != 0)
+	}
+	v := p.d.gpio
+	if p.d.intr == nil {
+		var err error
+		if v, err = p.d.readGPIO(); err != nil {
+			return gpio.Low
+		}
+	}
+	return gpio.Level(v&m != 0)
+}
+
+// WaitForEdge implements gpio.PinIn.
+func (p *Pin) WaitForEdge(timeout time.Duration) bool {
+	p.d.mu.Lock()
+	edge := p.edge
+	p.d.mu.Unlock()
+	if edge == gpio.NoEdge {
+		return false
+	}
+	if timeout == -1 {
+		<-p.edges
+		return true
+	}
+	select {
+	case <-p.edges:
+		return true
+	default:
+	}
+	select {
+	case <-p.edges:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Pull implements gpio.PinIn.
+func (p *Pin) Pull() gpio.Pull {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	if p.d.gppu&p.mask() != 0 {
+		return gpio.PullUp
+	}
+	return gpio.Float
+}
+
+// DefaultPull implements gpio.PinIn.
+func (p *Pin) DefaultPull() gpio.Pull {
+	return gpio.Float
+}
+
+// Out implements gpio.PinOut.
+func (p *Pin) Out(l gpio.Level) error {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	if p.d.closed {
+		return errors.New("mcp23xxx: already closed")
+	}
+	m := p.mask()
+	olat := p.d.olat &^ m
+	if l {
+		olat |= m
+	}
+	// Set the level before switching to output to not glitch.
+	if err := p.d.writeCached(regOLAT, &p.d.olat, olat); err != nil {
+		return err
+	}
+	p.edge = gpio.NoEdge
+	return p.d.setIODIR(p.d.iodir &^ m)
+}
+
+// PWM implements gpio.PinOut.
+//
+// It is not supported.
+func (p *Pin) PWM(duty gpio.Duty, f physic.Frequency) error {
+	return errors.New("mcp23xxx: PWM is not supported")
+}
+
+// SetPolarity inverts the input value of the line when inverted is true.
+func (p *Pin) SetPolarity(inverted bool) error {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	ipol := p.d.ipol &^ p.mask()
+	if inverted {
+		ipol |= p.mask()
+	}
+	if err := p.d.writeCached(regIPOL, &p.d.ipol, ipol); err != nil {
+		return err
+	}
+	if p.d.intr != nil && p.d.iodir&p.mask() != 0 {
+		// The polarity change inverts the cached value.
+		_, err := p.d.readGPIO()
+		return err
+	}
+	return nil
+}
+
+//
+
+// Registers with IOCON.BANK=0; each is 16 bits wide, port A first.
+const (
+	regIODIR   uint8 = 0x00
+	regIPOL    uint8 = 0x02
+	regGPINTEN uint8 = 0x04
+	regINTCON  uint8 = 0x08
+	regIOCON   uint8 = 0x0A
+	regGPPU    uint8 = 0x0C
+	regGPIO    uint8 = 0x12
+	regOLAT    uint8 = 0x14
+
+	ioconMirror uint8 = 1 << 6 // INTA and INTB are OR'ed
+	ioconHAEN   uint8 = 1 << 3 // Hardware address enable on the MCP23S17
+)
+
+func newDev(c conn.Conn, opts *Opts, chip string, iocon uint8) (*Dev, error) {
+	d := &Dev{
+		c:    mmr.Dev8{Conn: c, Order: binary.LittleEndian},
+		name: chip + "_" + strconv.Itoa(int(opts.HardwareAddress)),
+		intr: opts.Interrupt,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	for i := range d.pins {
+		port := "GPA"
+		if i >= 8 {
+			port = "GPB"
+		}
+		d.pins[i] = Pin{d: d, n: uint(i), name: d.name + "_" + port + strconv.Itoa(i&7), edges: make(chan struct{}, 1)}
+	}
+	if err := d.init(iocon); err != nil {
+		return nil, fmt.Errorf("mcp23xxx: %v", err)
+	}
+	if d.intr != nil {
+		if err := d.intr.In(gpio.PullUp, gpio.FallingEdge); err != nil {
+			return nil, fmt.Errorf("mcp23xxx: %v", err)
+		}
+		// Interrupt on change on every input line keeps d.gpio up to date.
+		if err := d.setIODIR(d.iodir); err != nil {
+			return nil, err
+		}
+		if _, err := d.readGPIO(); err != nil {
+			return nil, err
+		}
+	}
+	for i := range d.pins {
+		if err := gpioreg.Register(&d.pins[i]); err != nil {
+			for j := 0; j < i; j++ {
+				_ = gpioreg.Unregister(d.pins[j].name)
+			}
+			return nil, fmt.Errorf("mcp23xxx: %v", err)
+		}
+	}
+	if d.intr != nil {
+		go d.watch()
+	}
+	return d, nil
+}
+
+// init configures the interrupts and loads the register cache.
+func (d *Dev) init(iocon uint8) error {
+	if err := d.c.WriteUint8(regIOCON, ioconMirror|iocon); err != nil {
+		return err
+	}
+	// Interrupt on change compared to the previous value.
+	if err := d.c.WriteUint16(regGPINTEN, 0); err != nil {
+		return err
+	}
+	if err := d.c.WriteUint16(regINTCON, 0); err != nil {
+		return err
+	}
+	for _, r := range []struct {
+		reg uint8
+		v   *uint16
+	}{{regIODIR, &d.iodir}, {regIPOL, &d.ipol}, {regGPPU, &d.gppu}, {regOLAT, &d.olat}} {
+		v, err := d.c.ReadUint16(r.reg)
+		if err != nil {
+			return err
+		}
+		*r.v = v
+	}
+	return nil
+}
+
+// writeCached writes v to the register reg if it differs from the cached
+// value c.
+//
+// d.mu must be held.
+func (d *Dev) writeCached(reg uint8, c *uint16, v uint16) error {
+	if *c == v {
+		return nil
+	}
+	if err := d.c.WriteUint16(reg, v); err != nil {
+		return fmt.Errorf("mcp23xxx: %v", err)
+	}
+	*c = v
+	return nil
+}
+
+// setIODIR writes the line directions. When Opts.Interrupt is set, the
+// interrupt on change is enabled on all the input lines.
+//
+// d.mu must be held.
+func (d *Dev) setIODIR(iodir uint16) error {
+	if d.intr == nil {
+		return d.writeCached(regIODIR, &d.iodir, iodir)
+	}
+	// Disable the interrupt of lines switching to output first, and enable it
+	// on lines switching to input last.
+	if err := d.writeCached(regGPINTEN, &d.gpinten, d.gpinten&iodir); err != nil {
+		return err
+	}
+	if err := d.writeCached(regIODIR, &d.iodir, iodir); err != nil {
+		return err
+	}
+	return d.writeCached(regGPINTEN, &d.gpinten, iodir)
+}
+
+// readGPIO reads the input value and fans out the edges it implies.
+//
+// d.mu must be held.
+func (d *Dev) readGPIO() (uint16, error) {
+	v, err := d.c.ReadUint16(regGPIO)
+	if err != nil {
+		return 0, fmt.Errorf("mcp23xxx: %v", err)
+	}
+	changed := v ^ d.gpio
+	d.gpio = v
+	for i := range d.pins {
+		p := &d.pins[i]
+		m := p.mask()
+		if changed&m == 0 || p.edge == gpio.NoEdge {
+			continue
+		}
+		if p.edge == gpio.BothEdges || (p.edge == gpio.RisingEdge) == (v&m != 0) {
+			select {
+			case p.edges <- struct{}{}:
+			default:
+			}
+		}
+	}
+	return v, nil
+}
+
+// watch reads the input value on each interrupt until Close() is called.
+func (d *Dev) watch() {
+	defer close(d.done)
+	for {
+		select {
+		case <-d.stop:
+			return
+		default:
+		}
+		// Use a timeout to notice Close().
+		if d.intr.WaitForEdge(100 * time.Millisecond) {
+			d.mu.Lock()
+			_, _ = d.readGPIO()
+			d.mu.Unlock()
+		}
+	}
+}
+
+func (p *Pin) mask() uint16 {
+	return 1 << p.n
+}
+
+// spiConn implements the register access protocol of the MCP23S17 as a
+// half-duplex conn.Conn, so it can be used with mmr.Dev8.
+type spiConn struct {
+	c      spi.Conn
+	opcode byte
+}
+
+func (s *spiConn) String() string {
+	return s.c.String()
+}
+
+func (s *spiConn) Duplex() conn.Duplex {
+	return conn.Half
+}
+
+// Tx writes the register address and data in w, or reads into r from the
+// register address in w.
+func (s *spiConn) Tx(w, r []byte) error {
+	if len(r) == 0 {
+		return s.c.Tx(append([]byte{s.opcode}, w...), nil)
+	}
+	if len(w) != 1 {
+		return errors.New("mcp23xxx: a read requires a single register address")
+	}
+	buf := make([]byte, 2+len(r))
+	buf[0] = s.opcode | 1
+	buf[1] = w[0]
+	out := make([]byte, len(buf))
+	if err := s.c.Tx(buf, out); err != nil {
+		return err
+	}
+	copy(r, out[2:])
+	return nil
+}
+
+var _ gpio.PinIO = &Pin{}
+var _ pin.PinFunc = &Pin{}
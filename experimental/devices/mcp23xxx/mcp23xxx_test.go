@@ -0,0 +1,281 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package mcp23xxx
+
+import (
+	"testing"
+
+	"periph.io/x/periph/conn/conntest"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/spi/spitest"
+)
+
+func TestMCP23017(t *testing.T) {
+	bus := i2ctest.Playback{
+		Ops: append(initI2C(0x20),
+			// GPB7 high.
+			i2ctest.IO{Addr: 0x20, W: []byte{0x14, 0x00, 0x80}},
+			i2ctest.IO{Addr: 0x20, W: []byte{0x00, 0xFF, 0x7F}},
+			// GPA3 pull up.
+			i2ctest.IO{Addr: 0x20, W: []byte{0x0C, 0x08, 0x00}},
+			i2ctest.IO{Addr: 0x20, W: []byte{0x12}, R: []byte{0x08, 0x80}},
+			i2ctest.IO{Addr: 0x20, W: []byte{0x02, 0x08, 0x00}},
+			i2ctest.IO{Addr: 0x20, W: []byte{0x12}, R: []byte{0x00, 0x80}},
+		),
+	}
+	d, err := NewMCP23017(&bus, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := d.String(); s != "MCP23017_0{playback(32)}" {
+		t.Fatal(s)
+	}
+	if _, err := d.Pin(16); err == nil {
+		t.Fatal("invalid line")
+	}
+	gpb7 := gpioreg.ByName("MCP23017_0_GPB7")
+	if gpb7 == nil || gpb7.Number() != 15 {
+		t.Fatal(gpb7)
+	}
+	if f := gpb7.Function(); f != "IN" {
+		t.Fatal(f)
+	}
+	if err := gpb7.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if f := gpb7.Function(); f != "Out/High" {
+		t.Fatal(f)
+	}
+	// The output latch is cached.
+	if l := gpb7.Read(); l != gpio.High {
+		t.Fatal(l)
+	}
+	if err := gpb7.PWM(gpio.DutyHalf, 0); err == nil {
+		t.Fatal("PWM is not supported")
+	}
+	gpa3, err := d.Pin(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := gpa3.String(); s != "MCP23017_0_GPA3" {
+		t.Fatal(s)
+	}
+	if err := gpa3.In(gpio.PullDown, gpio.NoEdge); err == nil {
+		t.Fatal("pull down is not supported")
+	}
+	if err := gpa3.In(gpio.Float, gpio.RisingEdge); err == nil {
+		t.Fatal("edges require Opts.Interrupt")
+	}
+	if err := gpa3.In(gpio.PullUp, gpio.NoEdge); err != nil {
+		t.Fatal(err)
+	}
+	if p := gpa3.Pull(); p != gpio.PullUp {
+		t.Fatal(p)
+	}
+	if p := gpa3.DefaultPull(); p != gpio.Float {
+		t.Fatal(p)
+	}
+	if l := gpa3.Read(); l != gpio.High {
+		t.Fatal(l)
+	}
+	if gpa3.WaitForEdge(0) {
+		t.Fatal("edges are not enabled")
+	}
+	if err := gpa3.SetPolarity(true); err != nil {
+		t.Fatal(err)
+	}
+	if l := gpa3.Read(); l != gpio.Low {
+		t.Fatal(l)
+	}
+	if err := gpa3.SetFunc(gpio.PWM); err == nil {
+		t.Fatal("PWM is not supported")
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gpioreg.ByName("MCP23017_0_GPB7") != nil {
+		t.Fatal("not unregistered")
+	}
+	if err := d.Close(); err == nil {
+		t.Fatal("already closed")
+	}
+	if err := gpa3.Out(gpio.Low); err == nil {
+		t.Fatal("already closed")
+	}
+	if err := gpa3.In(gpio.PullUp, gpio.NoEdge); err == nil {
+		t.Fatal("already closed")
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMCP23017_edges(t *testing.T) {
+	intr := gpiotest.Pin{N: "INT", EdgesChan: make(chan gpio.Level, 1)}
+	bus := i2ctest.Playback{
+		Ops: append(initI2C(0x21),
+			// Interrupt on change on all the inputs.
+			i2ctest.IO{Addr: 0x21, W: []byte{0x04, 0xFF, 0xFF}},
+			i2ctest.IO{Addr: 0x21, W: []byte{0x12}, R: []byte{0x00, 0x00}},
+			// GPA0 both edges, GPB0 rising edge.
+			i2ctest.IO{Addr: 0x21, W: []byte{0x12}, R: []byte{0x00, 0x00}},
+			i2ctest.IO{Addr: 0x21, W: []byte{0x12}, R: []byte{0x00, 0x00}},
+			// Interrupts.
+			i2ctest.IO{Addr: 0x21, W: []byte{0x12}, R: []byte{0x00, 0x01}},
+			i2ctest.IO{Addr: 0x21, W: []byte{0x12}, R: []byte{0x01, 0x00}},
+			// GPB0 output.
+			i2ctest.IO{Addr: 0x21, W: []byte{0x04, 0xFF, 0xFE}},
+			i2ctest.IO{Addr: 0x21, W: []byte{0x00, 0xFF, 0xFE}},
+			// GPB0 input.
+			i2ctest.IO{Addr: 0x21, W: []byte{0x00, 0xFF, 0xFF}},
+			i2ctest.IO{Addr: 0x21, W: []byte{0x04, 0xFF, 0xFF}},
+			i2ctest.IO{Addr: 0x21, W: []byte{0x12}, R: []byte{0x01, 0x01}},
+			// Close.
+			i2ctest.IO{Addr: 0x21, W: []byte{0x04, 0x00, 0x00}},
+		),
+	}
+	d, err := NewMCP23017(&bus, &Opts{HardwareAddress: 1, Interrupt: &intr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if intr.P != gpio.PullUp {
+		t.Fatal(intr.P)
+	}
+	gpa0, err := d.Pin(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gpb0, err := d.Pin(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gpa0.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	if err := gpb0.In(gpio.PullNoChange, gpio.RisingEdge); err != nil {
+		t.Fatal(err)
+	}
+	// The values are cached.
+	if gpa0.Read() != gpio.Low || gpb0.Read() != gpio.Low {
+		t.Fatal("expected low")
+	}
+	intr.EdgesChan <- gpio.Low
+	if !gpb0.WaitForEdge(-1) {
+		t.Fatal("expected rising edge")
+	}
+	if gpb0.Read() != gpio.High {
+		t.Fatal("expected high")
+	}
+	intr.EdgesChan <- gpio.Low
+	if !gpa0.WaitForEdge(-1) {
+		t.Fatal("expected rising edge")
+	}
+	// Falling edges are ignored.
+	if gpb0.WaitForEdge(0) {
+		t.Fatal("unexpected edge")
+	}
+	if gpa0.Read() != gpio.High || gpb0.Read() != gpio.Low {
+		t.Fatal("unexpected values")
+	}
+	if err := gpb0.Out(gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	if gpb0.Read() != gpio.Low {
+		t.Fatal("expected low")
+	}
+	if err := gpb0.In(gpio.PullNoChange, gpio.NoEdge); err != nil {
+		t.Fatal(err)
+	}
+	if gpb0.Read() != gpio.High {
+		t.Fatal("expected high")
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMCP23S17(t *testing.T) {
+	s := spitest.Playback{
+		Playback: conntest.Playback{
+			Ops: []conntest.IO{
+				{W: []byte{0x40, 0x0A, 0x48}},
+				{W: []byte{0x44, 0x0A, 0x48}},
+				{W: []byte{0x44, 0x04, 0x00, 0x00}},
+				{W: []byte{0x44, 0x08, 0x00, 0x00}},
+				{W: []byte{0x45, 0x00, 0x00, 0x00}, R: []byte{0x00, 0x00, 0xFF, 0xFF}},
+				{W: []byte{0x45, 0x02, 0x00, 0x00}, R: []byte{0x00, 0x00, 0x00, 0x00}},
+				{W: []byte{0x45, 0x0C, 0x00, 0x00}, R: []byte{0x00, 0x00, 0x00, 0x00}},
+				{W: []byte{0x45, 0x14, 0x00, 0x00}, R: []byte{0x00, 0x00, 0x00, 0x00}},
+				// GPA0 low.
+				{W: []byte{0x44, 0x00, 0xFE, 0xFF}},
+			},
+		},
+	}
+	d, err := NewMCP23S17(&s, &Opts{HardwareAddress: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := gpioreg.ByName("MCP23S17_2_GPA0")
+	if p == nil {
+		t.Fatal("not registered")
+	}
+	if err := p.Out(gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	if f := p.Function(); f != "Out/Low" {
+		t.Fatal(f)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNew_fail(t *testing.T) {
+	if _, err := NewMCP23017(&i2ctest.Playback{}, &Opts{HardwareAddress: 8}); err == nil {
+		t.Fatal("invalid address")
+	}
+	if _, err := NewMCP23S17(&spitest.Playback{}, &Opts{HardwareAddress: 8}); err == nil {
+		t.Fatal("invalid address")
+	}
+	if _, err := NewMCP23017(&i2ctest.Playback{DontPanic: true}, &DefaultOpts); err == nil {
+		t.Fatal("I/O failure")
+	}
+	bus := i2ctest.Playback{Ops: initI2C(0x20)}
+	d, err := NewMCP23017(&bus, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewMCP23017(&i2ctest.Playback{Ops: initI2C(0x20)}, &DefaultOpts); err == nil {
+		t.Fatal("already registered")
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//
+
+// initI2C returns the expected initialization of a MCP23017 at its power on
+// state.
+func initI2C(addr uint16) []i2ctest.IO {
+	return []i2ctest.IO{
+		{Addr: addr, W: []byte{0x0A, 0x40}},
+		{Addr: addr, W: []byte{0x04, 0x00, 0x00}},
+		{Addr: addr, W: []byte{0x08, 0x00, 0x00}},
+		{Addr: addr, W: []byte{0x00}, R: []byte{0xFF, 0xFF}},
+		{Addr: addr, W: []byte{0x02}, R: []byte{0x00, 0x00}},
+		{Addr: addr, W: []byte{0x0C}, R: []byte{0x00, 0x00}},
+		{Addr: addr, W: []byte{0x14}, R: []byte{0x00, 0x00}},
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package mcp3xxx controls a Microchip MCP3004/MCP3008 (10 bits) or
+// MCP3204/MCP3208 (12 bits) analog-to-digital converter over SPI.
+//
+// Each input, either single-ended or pseudo-differential, is exposed as an
+// analog.PinADC. The samples are scaled against the reference voltage
+// connected to the VREF pin.
+//
+// Datasheets
+//
+// MCP3004/MCP3008:
+// http://ww1.microchip.com/downloads/en/DeviceDoc/21295d.pdf
+//
+// MCP3204/MCP3208:
+// http://ww1.microchip.com/downloads/en/DeviceDoc/21298e.pdf
+package mcp3xxx
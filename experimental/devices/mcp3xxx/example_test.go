@@ -0,0 +1,44 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package mcp3xxx_test
+
+import (
+	"fmt"
+	"log"
+
+	"periph.io/x/periph/conn/spi/spireg"
+	"periph.io/x/periph/experimental/devices/mcp3xxx"
+	"periph.io/x/periph/host"
+)
+
+func Example() {
+	// Make sure periph is initialized.
+	if _, err := host.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Use spireg SPI port registry to find the first available SPI port.
+	p, err := spireg.Open("")
+	if err != nil {
+		log.Fatalf("failed to open SPI: %v", err)
+	}
+	defer p.Close()
+
+	d, err := mcp3xxx.NewMCP3008(p, &mcp3xxx.DefaultOpts)
+	if err != nil {
+		log.Fatalf("failed to initialize mcp3008: %v", err)
+	}
+	for _, c := range []mcp3xxx.Channel{mcp3xxx.Channel0, mcp3xxx.Channel1} {
+		pin, err := d.PinForChannel(c)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s, err := pin.Read()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s: %s\n", pin, s)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package mcp3xxx
+
+import (
+	"errors"
+	"fmt"
+
+	"periph.io/x/periph/conn/analog"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/spi"
+)
+
+// Channel is an input of the multiplexer.
+//
+// The values match the SGL/DIFF bit followed by the D2~D0 bits of the
+// command.
+type Channel uint8
+
+// Valid Channel values.
+//
+// The MCP3004 and MCP3204 only support the first four single-ended and
+// pseudo-differential inputs.
+const (
+	Channel0Minus1 Channel = 0  // Pseudo-differential CH0 - CH1
+	Channel1Minus0 Channel = 1  // Pseudo-differential CH1 - CH0
+	Channel2Minus3 Channel = 2  // Pseudo-differential CH2 - CH3
+	Channel3Minus2 Channel = 3  // Pseudo-differential CH3 - CH2
+	Channel4Minus5 Channel = 4  // Pseudo-differential CH4 - CH5
+	Channel5Minus4 Channel = 5  // Pseudo-differential CH5 - CH4
+	Channel6Minus7 Channel = 6  // Pseudo-differential CH6 - CH7
+	Channel7Minus6 Channel = 7  // Pseudo-differential CH7 - CH6
+	Channel0       Channel = 8  // Single-ended CH0
+	Channel1       Channel = 9  // Single-ended CH1
+	Channel2       Channel = 10 // Single-ended CH2
+	Channel3       Channel = 11 // Single-ended CH3
+	Channel4       Channel = 12 // Single-ended CH4
+	Channel5       Channel = 13 // Single-ended CH5
+	Channel6       Channel = 14 // Single-ended CH6
+	Channel7       Channel = 15 // Single-ended CH7
+)
+
+func (c Channel) String() string {
+	if c > Channel7 {
+		return fmt.Sprintf("Channel(%d)", c)
+	}
+	if c >= Channel0 {
+		return fmt.Sprintf("CH%d", c-Channel0)
+	}
+	return fmt.Sprintf("CH%d-CH%d", c, c^1)
+}
+
+// Opts holds the configuration options.
+type Opts struct {
+	// Vref is the electric potential applied to the VREF pin. It must be
+	// specified.
+	Vref physic.ElectricPotential
+}
+
+// DefaultOpts is the recommended default options.
+var DefaultOpts = Opts{
+	Vref: 3300 * physic.MilliVolt,
+}
+
+// NewMCP3004 returns an object that communicates over SPI to a MCP3004.
+func NewMCP3004(p spi.Port, opts *Opts) (*Dev, error) {
+	return newDev(p, opts, "MCP3004", 4, 10)
+}
+
+// NewMCP3008 returns an object that communicates over SPI to a MCP3008.
+func NewMCP3008(p spi.Port, opts *Opts) (*Dev, error) {
+	return newDev(p, opts, "MCP3008", 8, 10)
+}
+
+// NewMCP3204 returns an object that communicates over SPI to a MCP3204.
+func NewMCP3204(p spi.Port, opts *Opts) (*Dev, error) {
+	return newDev(p, opts, "MCP3204", 4, 12)
+}
+
+// NewMCP3208 returns an object that communicates over SPI to a MCP3208.
+func NewMCP3208(p spi.Port, opts *Opts) (*Dev, error) {
+	return newDev(p, opts, "MCP3208", 8, 12)
+}
+
+// Dev is a handle to a MCP3xxx.
+type Dev struct {
+	c        spi.Conn
+	name     string
+	channels Channel
+	bits     uint
+	vref     physic.ElectricPotential
+}
+
+func (d *Dev) String() string {
+	return fmt.Sprintf("%s{%s}", d.name, d.c)
+}
+
+// Halt implements conn.Resource.
+//
+// It is a noop; the device powers down between conversions.
+func (d *Dev) Halt() error {
+	return nil
+}
+
+// PinForChannel returns a pin reading the input c.
+func (d *Dev) PinForChannel(c Channel) (*Pin, error) {
+	if c&7 >= d.channels || c > Channel7 {
+		return nil, fmt.Errorf("mcp3xxx: invalid channel %s for %s", c, d.name)
+	}
+	return &Pin{d: d, c: c}, nil
+}
+
+// Pin is an input of the ADC.
+//
+// It implements analog.PinADC.
+type Pin struct {
+	d *Dev
+	c Channel
+}
+
+// String implements conn.Resource.
+func (p *Pin) String() string {
+	return p.Name()
+}
+
+// Halt implements conn.Resource.
+func (p *Pin) Halt() error {
+	return nil
+}
+
+// Name implements pin.Pin.
+func (p *Pin) Name() string {
+	return p.d.name + "_" + p.c.String()
+}
+
+// Number implements pin.Pin.
+func (p *Pin) Number() int {
+	return int(p.c)
+}
+
+// Function implements pin.Pin.
+func (p *Pin) Function() string {
+	return "ADC"
+}
+
+// Range implements analog.PinADC.
+//
+// Pseudo-differential inputs read 0 when the negative input is above the
+// positive one.
+func (p *Pin) Range() (analog.Sample, analog.Sample) {
+	max := int32(1) << p.d.bits
+	return analog.FromRaw(0, max, p.d.vref), analog.FromRaw(max-1, max, p.d.vref)
+}
+
+// Read implements analog.PinADC.
+func (p *Pin) Read() (analog.Sample, error) {
+	// The command is right aligned so the result ends at the last bit of the
+	// three bytes transaction; the start bit is preceded by 7 or 5 zeros.
+	var w [3]byte
+	if p.d.bits == 10 {
+		w[0] = 0x01
+		w[1] = byte(p.c) << 4
+	} else {
+		w[0] = 0x04 | byte(p.c)>>2
+		w[1] = byte(p.c) << 6
+	}
+	var r [3]byte
+	if err := p.d.c.Tx(w[:], r[:]); err != nil {
+		return analog.Sample{}, fmt.Errorf("mcp3xxx: %v", err)
+	}
+	mask := uint16(1)<<p.d.bits - 1
+	raw := (uint16(r[1])<<8 | uint16(r[2])) & mask
+	return analog.FromRaw(int32(raw), int32(1)<<p.d.bits, p.d.vref), nil
+}
+
+//
+
+func newDev(p spi.Port, opts *Opts, name string, channels Channel, bits uint) (*Dev, error) {
+	if opts.Vref <= 0 {
+		return nil, errors.New("mcp3xxx: Vref must be specified")
+	}
+	// 1MHz is supported at the lowest supply voltage by all the variants.
+	c, err := p.Connect(physic.MegaHertz, spi.Mode0, 8)
+	if err != nil {
+		return nil, fmt.Errorf("mcp3xxx: %v", err)
+	}
+	return &Dev{c: c, name: name, channels: channels, bits: bits, vref: opts.Vref}, nil
+}
+
+var _ analog.PinADC = &Pin{}
@@ -0,0 +1,196 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package mcp3xxx
+
+import (
+	"errors"
+	"testing"
+
+	"periph.io/x/periph/conn/analog"
+	"periph.io/x/periph/conn/conntest"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/spi"
+	"periph.io/x/periph/conn/spi/spitest"
+)
+
+func TestNewMCP3008_fail(t *testing.T) {
+	if _, err := NewMCP3008(&spitest.Playback{}, &Opts{}); err == nil {
+		t.Fatal("Vref is required")
+	}
+	if _, err := NewMCP3008(&configFail{}, &DefaultOpts); err == nil {
+		t.Fatal("Connect failed")
+	}
+}
+
+func TestMCP3008_Read(t *testing.T) {
+	s := spitest.Playback{
+		Playback: conntest.Playback{
+			Ops: []conntest.IO{
+				{W: []byte{0x01, 0x80, 0x00}, R: []byte{0x00, 0x02, 0x00}},
+				{W: []byte{0x01, 0x70, 0x00}, R: []byte{0xFF, 0xFF, 0xFF}},
+			},
+		},
+	}
+	d, err := NewMCP3008(&s, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if str := d.String(); str != "MCP3008{playback}" {
+		t.Fatal(str)
+	}
+	p, err := d.PinForChannel(Channel0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if str := p.String(); str != "MCP3008_CH0" {
+		t.Fatal(str)
+	}
+	if n := p.Number(); n != 8 {
+		t.Fatal(n)
+	}
+	if f := p.Function(); f != "ADC" {
+		t.Fatal(f)
+	}
+	min, max := p.Range()
+	if min.Raw != 0 || max.Raw != 1023 {
+		t.Fatal(min, max)
+	}
+	v, err := p.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (analog.Sample{V: 1650 * physic.MilliVolt, Raw: 512}); v != expected {
+		t.Fatal(v)
+	}
+	if p, err = d.PinForChannel(Channel7Minus6); err != nil {
+		t.Fatal(err)
+	}
+	if str := p.String(); str != "MCP3008_CH7-CH6" {
+		t.Fatal(str)
+	}
+	if v, err = p.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if v.Raw != 1023 {
+		t.Fatal(v)
+	}
+	if err := p.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMCP3208_Read(t *testing.T) {
+	s := spitest.Playback{
+		Playback: conntest.Playback{
+			Ops: []conntest.IO{
+				{W: []byte{0x07, 0x40, 0x00}, R: []byte{0xFF, 0xEF, 0xFF}},
+				{W: []byte{0x04, 0x40, 0x00}, R: []byte{0x00, 0x08, 0x00}},
+			},
+		},
+	}
+	d, err := NewMCP3208(&s, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := d.PinForChannel(Channel5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, max := p.Range(); max.Raw != 4095 {
+		t.Fatal(max)
+	}
+	v, err := p.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (analog.Sample{V: 3299194335, Raw: 4095}); v != expected {
+		t.Fatal(v)
+	}
+	if p, err = d.PinForChannel(Channel1Minus0); err != nil {
+		t.Fatal(err)
+	}
+	if v, err = p.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if v.Raw != 2048 {
+		t.Fatal(v)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMCP3004_PinForChannel(t *testing.T) {
+	s := spitest.Playback{
+		Playback: conntest.Playback{
+			Ops:       []conntest.IO{{W: []byte{0x01, 0x10, 0x00}, R: []byte{0x00, 0x00, 0x00}}},
+			DontPanic: true,
+		},
+	}
+	d, err := NewMCP3004(&s, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.PinForChannel(Channel4); err == nil {
+		t.Fatal("MCP3004 has 4 channels")
+	}
+	if _, err := d.PinForChannel(Channel4Minus5); err == nil {
+		t.Fatal("MCP3004 has 4 channels")
+	}
+	if _, err := d.PinForChannel(Channel(16)); err == nil {
+		t.Fatal("invalid channel")
+	}
+	p, err := d.PinForChannel(Channel1Minus0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Read(); err == nil {
+		t.Fatal("playback is exhausted")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMCP3204(t *testing.T) {
+	d, err := NewMCP3204(&spitest.Playback{}, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.PinForChannel(Channel3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.PinForChannel(Channel4); err == nil {
+		t.Fatal("MCP3204 has 4 channels")
+	}
+}
+
+func TestChannel_String(t *testing.T) {
+	if s := Channel0Minus1.String(); s != "CH0-CH1" {
+		t.Fatal(s)
+	}
+	if s := Channel(16).String(); s != "Channel(16)" {
+		t.Fatal(s)
+	}
+}
+
+//
+
+type configFail struct {
+	spitest.Record
+}
+
+func (c *configFail) Connect(f physic.Frequency, mode spi.Mode, bits int) (spi.Conn, error) {
+	return nil, errors.New("injected error")
+}
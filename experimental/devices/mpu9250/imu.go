@@ -0,0 +1,405 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package mpu9250
+
+import (
+	"log"
+	"time"
+
+	"periph.io/x/periph/experimental/conn/imu"
+	"periph.io/x/periph/experimental/devices/mpu9250/reg"
+)
+
+// EnableMagnetometer configures the I²C master of the MPU-9250 to read the
+// embedded AK8963 magnetometer on each sample.
+//
+// The magnetometer is set in 16 bits continuous measurement mode at 100Hz
+// and its readings are realigned on the axes of the accelerometer.
+func (m *MPU9250) EnableMagnetometer() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// I²C master at 400kHz.
+	if err := m.transport.writeByte(reg.MPU9250_USER_CTRL, reg.MPU9250_I2C_MST_EN_MASK); err != nil {
+		return wrapf("can't enable I²C master => %v", err)
+	}
+	if err := m.transport.writeByte(reg.MPU9250_I2C_MST_CTRL, 0x0D); err != nil {
+		return wrapf("can't set I²C master clock => %v", err)
+	}
+	id, err := m.magRead(reg.MPU9250_MAG_WIA)
+	if err != nil {
+		return err
+	}
+	if id != reg.MPU9250_WIA_MASK {
+		return wrapf("unexpected AK8963 id %x", id)
+	}
+	// The sensitivity adjustment values are only readable in fuse ROM access
+	// mode, which must be entered from power down mode.
+	if err := m.magWrite(reg.MPU9250_MAG_CNTL, 0x00); err != nil {
+		return err
+	}
+	if err := m.magWrite(reg.MPU9250_MAG_CNTL, 0x0F); err != nil {
+		return err
+	}
+	for i := range m.magAdj {
+		v, err := m.magRead(reg.MPU9250_MAG_ASAX + byte(i))
+		if err != nil {
+			return err
+		}
+		m.magAdj[i] = int64(v) + 128
+	}
+	if err := m.magWrite(reg.MPU9250_MAG_CNTL, 0x00); err != nil {
+		return err
+	}
+	if err := m.magWrite(reg.MPU9250_MAG_CNTL, 0x16); err != nil {
+		return err
+	}
+	// Slave 0 reads the measurement and ST2 into EXT_SENS_DATA_00~06 on each
+	// sample; reading ST2 is required for the AK8963 to update the values.
+	seq := [][]byte{
+		{reg.MPU9250_I2C_SLV0_ADDR, reg.MPU9250_I2C_SLV0_RNW_MASK | reg.MPU9250_MAG_ADDRESS},
+		{reg.MPU9250_I2C_SLV0_REG, reg.MPU9250_MAG_XOUT_L},
+		{reg.MPU9250_I2C_SLV0_CTRL, reg.MPU9250_I2C_SLV0_EN_MASK | magLen},
+	}
+	if err := m.transferBatch(seq, "error configuring slave 0 %d: [%x:%x] => %v"); err != nil {
+		return err
+	}
+	m.magOn = true
+	return nil
+}
+
+// String implements conn.Resource.
+func (m *MPU9250) String() string {
+	return "MPU9250"
+}
+
+// Sense implements imu.IMU.
+//
+// The magnetic field is only returned once EnableMagnetometer() was called.
+func (m *MPU9250) Sense(s *imu.Sample) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.loadScales(); err != nil {
+		return err
+	}
+	// ACCEL_XOUT_H to GYRO_ZOUT_L, followed by EXT_SENS_DATA_00~06.
+	var b [14 + magLen]byte
+	n := 14
+	if m.magOn {
+		n += magLen
+	}
+	if err := m.transport.readBytes(reg.MPU9250_ACCEL_XOUT_H, b[:n]); err != nil {
+		return wrapf("can't read sensors => %v", err)
+	}
+	m.decodeAccel(b[0:6], s)
+	m.decodeGyro(b[8:14], s)
+	if m.magOn {
+		m.decodeMag(b[14:], s)
+	}
+	return nil
+}
+
+// SenseContinuous implements imu.IMU.
+//
+// The samples are timed by the MPU-9250 and accumulated in its FIFO, which is
+// drained in bursts. The interval must be between 1ms and 256ms and is
+// rounded to the millisecond. This requires the digital low pass filter to be
+// enabled, which Init() does.
+//
+// The application must call Halt() to stop the sensing when done.
+func (m *MPU9250) SenseContinuous(interval time.Duration) (<-chan imu.Sample, error) {
+	if interval < time.Millisecond || interval > 256*time.Millisecond {
+		return nil, wrapf("interval must be between 1ms and 256ms")
+	}
+	m.halt()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.loadScales(); err != nil {
+		return nil, err
+	}
+	div := (interval+time.Millisecond/2)/time.Millisecond - 1
+	interval = (div + 1) * time.Millisecond
+	fifo := byte(reg.MPU9250_GYRO_XOUT_MASK | reg.MPU9250_GYRO_YOUT_MASK | reg.MPU9250_GYRO_ZOUT_MASK | reg.MPU9250_ACCEL_MASK)
+	if m.magOn {
+		fifo |= reg.MPU9250_SLV0_MASK
+	}
+	seq := [][]byte{
+		{reg.MPU9250_FIFO_EN, 0},
+		{reg.MPU9250_SMPLRT_DIV, byte(div)},
+		{reg.MPU9250_USER_CTRL, m.userCtrl() | reg.MPU9250_FIFO_RST_MASK},
+		{reg.MPU9250_USER_CTRL, m.userCtrl() | reg.MPU9250_FIFO_EN_MASK},
+		{reg.MPU9250_FIFO_EN, fifo},
+	}
+	if err := m.transferBatch(seq, "error enabling FIFO %d: [%x:%x] => %v"); err != nil {
+		return nil, err
+	}
+	sensing := make(chan imu.Sample)
+	stop := make(chan struct{})
+	m.stop = stop
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(sensing)
+		m.sensingContinuous(interval, sensing, stop)
+	}()
+	return sensing, nil
+}
+
+// Precision implements imu.IMU.
+func (m *MPU9250) Precision(s *imu.Sample) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.loadScales(); err != nil {
+		return
+	}
+	a := m.accelLSB()
+	g := m.gyroLSB()
+	s.Acceleration = [3]imu.Acceleration{a, a, a}
+	s.AngularVelocity = [3]imu.AngularVelocity{g, g, g}
+	if m.magOn {
+		s.MagneticField = [3]imu.MagneticFluxDensity{magLSB, magLSB, magLSB}
+	}
+}
+
+// Halt stops the continuous sensing initiated by SenseContinuous() and
+// disables the FIFO.
+func (m *MPU9250) Halt() error {
+	if !m.halt() {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seq := [][]byte{
+		{reg.MPU9250_FIFO_EN, 0},
+		{reg.MPU9250_USER_CTRL, m.userCtrl()},
+	}
+	return m.transferBatch(seq, "error disabling FIFO %d: [%x:%x] => %v")
+}
+
+//
+
+// magLen is the number of bytes read from the AK8963 on each sample.
+const magLen = 7
+
+// magLSB is the resolution of the AK8963 in 16 bits mode, 4912µT over 32760.
+const magLSB = 150 * imu.NanoTesla
+
+// fifoSize is the size of the FIFO in bytes.
+const fifoSize = 512
+
+var doSleep = time.Sleep
+
+// userCtrl returns the value of USER_CTRL without the FIFO.
+func (m *MPU9250) userCtrl() byte {
+	if m.magOn {
+		return reg.MPU9250_I2C_MST_EN_MASK
+	}
+	return 0
+}
+
+// loadScales reads the full scale ranges if they may have changed.
+//
+// m.mu must be held.
+func (m *MPU9250) loadScales() error {
+	if m.scaled {
+		return nil
+	}
+	a, err := m.transport.readMaskedReg(reg.MPU9250_ACCEL_CONFIG, reg.MPU9250_ACCEL_FS_SEL_MASK)
+	if err != nil {
+		return wrapf("can't read accelerometer range => %v", err)
+	}
+	g, err := m.transport.readMaskedReg(reg.MPU9250_GYRO_CONFIG, reg.MPU9250_GYRO_FS_SEL_MASK)
+	if err != nil {
+		return wrapf("can't read gyroscope range => %v", err)
+	}
+	m.accelFS = a >> 3
+	m.gyroFS = g >> 3
+	m.scaled = true
+	return nil
+}
+
+// accelLSB returns the resolution of the accelerometer; the full scale range
+// is ±2g, ±4g, ±8g or ±16g.
+func (m *MPU9250) accelLSB() imu.Acceleration {
+	return imu.Acceleration(int64(2<<m.accelFS) * int64(imu.EarthGravity) / 32768)
+}
+
+// gyroLSB returns the resolution of the gyroscope; the full scale range is
+// ±250°/s, ±500°/s, ±1000°/s or ±2000°/s.
+func (m *MPU9250) gyroLSB() imu.AngularVelocity {
+	return imu.AngularVelocity(int64(250<<m.gyroFS) * int64(imu.DegreePerSecond) / 32768)
+}
+
+func (m *MPU9250) decodeAccel(b []byte, s *imu.Sample) {
+	for i := range s.Acceleration {
+		v := int64(int16(uint16(b[2*i])<<8 | uint16(b[2*i+1])))
+		s.Acceleration[i] = imu.Acceleration(v * int64(2<<m.accelFS) * int64(imu.EarthGravity) / 32768)
+	}
+}
+
+func (m *MPU9250) decodeGyro(b []byte, s *imu.Sample) {
+	for i := range s.AngularVelocity {
+		v := int64(int16(uint16(b[2*i])<<8 | uint16(b[2*i+1])))
+		s.AngularVelocity[i] = imu.AngularVelocity(v * int64(250<<m.gyroFS) * int64(imu.DegreePerSecond) / 32768)
+	}
+}
+
+// decodeMag decodes the AK8963 measurement, HXL to HZH then ST2.
+//
+// The AK8963 is little endian and its X and Y axes are swapped and its Z axis
+// inverted compared to the accelerometer. On magnetic sensor overflow, the
+// previous value is returned.
+func (m *MPU9250) decodeMag(b []byte, s *imu.Sample) {
+	if b[6]&0x08 == 0 {
+		var v [3]int64
+		for i := range v {
+			raw := int64(int16(uint16(b[2*i+1])<<8 | uint16(b[2*i])))
+			v[i] = raw * m.magAdj[i] * 4912000 / (32760 * 256)
+		}
+		m.lastMag = [3]imu.MagneticFluxDensity{imu.MagneticFluxDensity(v[1]), imu.MagneticFluxDensity(v[0]), imu.MagneticFluxDensity(-v[2])}
+	}
+	s.MagneticField = m.lastMag
+}
+
+// magTransfer does a single byte transfer with the AK8963 through slave 4.
+//
+// m.mu must be held.
+func (m *MPU9250) magTransfer(addr, r, v byte) (byte, error) {
+	seq := [][]byte{
+		{reg.MPU9250_I2C_SLV4_ADDR, addr},
+		{reg.MPU9250_I2C_SLV4_REG, r},
+		{reg.MPU9250_I2C_SLV4_DO, v},
+		{reg.MPU9250_I2C_SLV4_CTRL, reg.MPU9250_I2C_SLV4_EN_MASK},
+	}
+	if err := m.transferBatch(seq, "error accessing AK8963 %d: [%x:%x] => %v"); err != nil {
+		return 0, err
+	}
+	for i := 0; ; i++ {
+		st, err := m.transport.readByte(reg.MPU9250_I2C_MST_STATUS)
+		if err != nil {
+			return 0, wrapf("can't read I²C master status => %v", err)
+		}
+		if st&reg.MPU9250_I2C_SLV4_NACK_MASK != 0 {
+			return 0, wrapf("AK8963 register %x NACK", r)
+		}
+		if st&reg.MPU9250_I2C_SLV4_DONE_MASK != 0 {
+			break
+		}
+		if i == 10 {
+			return 0, wrapf("AK8963 register %x timed out", r)
+		}
+		doSleep(time.Millisecond)
+	}
+	if addr&reg.MPU9250_I2C_SLV4_RNW_MASK == 0 {
+		// Mode changes take up to 100µs.
+		doSleep(time.Millisecond)
+		return 0, nil
+	}
+	return m.transport.readByte(reg.MPU9250_I2C_SLV4_DI)
+}
+
+func (m *MPU9250) magRead(r byte) (byte, error) {
+	return m.magTransfer(reg.MPU9250_I2C_SLV4_RNW_MASK|reg.MPU9250_MAG_ADDRESS, r, 0)
+}
+
+func (m *MPU9250) magWrite(r, v byte) error {
+	_, err := m.magTransfer(reg.MPU9250_MAG_ADDRESS, r, v)
+	return err
+}
+
+// halt stops the continuous sensing and returns true if it was running.
+func (m *MPU9250) halt() bool {
+	m.mu.Lock()
+	stop := m.stop
+	m.stop = nil
+	m.mu.Unlock()
+	if stop == nil {
+		return false
+	}
+	close(stop)
+	m.wg.Wait()
+	return true
+}
+
+func (m *MPU9250) sensingContinuous(interval time.Duration, sensing chan<- imu.Sample, stop <-chan struct{}) {
+	size := 12
+	if m.magOn {
+		size += magLen
+	}
+	// Drain at most every 50ms while keeping the FIFO half empty.
+	n := fifoSize / size / 2
+	if max := int(50 * time.Millisecond / interval); max < n {
+		n = max
+	}
+	if n < 1 {
+		n = 1
+	}
+	t := time.NewTicker(time.Duration(n) * interval)
+	defer t.Stop()
+
+	buf := make([]byte, fifoSize)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		m.mu.Lock()
+		samples, err := m.drainFIFO(buf, size)
+		m.mu.Unlock()
+		if err != nil {
+			log.Printf("%s: failed to sense: %v", m, err)
+			continue
+		}
+		for i := range samples {
+			select {
+			case sensing <- samples[i]:
+			case <-stop:
+				return
+			}
+		}
+	}
+}
+
+// drainFIFO reads all the complete samples accumulated in the FIFO.
+//
+// m.mu must be held.
+func (m *MPU9250) drainFIFO(buf []byte, size int) ([]imu.Sample, error) {
+	st, err := m.transport.readByte(reg.MPU9250_INT_STATUS)
+	if err != nil {
+		return nil, wrapf("can't read interrupt status => %v", err)
+	}
+	if st&reg.MPU9250_FIFO_OFLOW_INT_MASK != 0 {
+		// The FIFO content is not aligned on a sample anymore.
+		if err := m.transport.writeByte(reg.MPU9250_USER_CTRL, m.userCtrl()|reg.MPU9250_FIFO_EN_MASK|reg.MPU9250_FIFO_RST_MASK); err != nil {
+			return nil, wrapf("can't reset FIFO => %v", err)
+		}
+		return nil, wrapf("FIFO overflow")
+	}
+	c, err := m.transport.readUint16(reg.MPU9250_FIFO_COUNTH, reg.MPU9250_FIFO_COUNTL)
+	if err != nil {
+		return nil, wrapf("can't get FIFO => %v", err)
+	}
+	l := int(c) / size * size
+	if l == 0 {
+		return nil, nil
+	}
+	if err := m.transport.readBytes(reg.MPU9250_FIFO_R_W, buf[:l]); err != nil {
+		return nil, wrapf("can't read FIFO => %v", err)
+	}
+	// The order is the one of the registers: accelerometer, gyroscope then
+	// external sensor.
+	samples := make([]imu.Sample, l/size)
+	for i := range samples {
+		b := buf[i*size : (i+1)*size]
+		m.decodeAccel(b[0:6], &samples[i])
+		m.decodeGyro(b[6:12], &samples[i])
+		if m.magOn {
+			m.decodeMag(b[12:], &samples[i])
+		}
+	}
+	return samples, nil
+}
+
+var _ imu.IMU = &MPU9250{}
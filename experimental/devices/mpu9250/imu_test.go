@@ -0,0 +1,296 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package mpu9250
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/experimental/conn/imu"
+	"periph.io/x/periph/experimental/devices/mpu9250/reg"
+)
+
+func TestSense(t *testing.T) {
+	f := newFake()
+	// ±4g and ±500°/s.
+	f.regs[reg.MPU9250_ACCEL_CONFIG] = 0x08
+	f.regs[reg.MPU9250_GYRO_CONFIG] = 0x08
+	copy(f.regs[reg.MPU9250_ACCEL_XOUT_H:], []byte{
+		// 0.5g, -0.25g, 1g.
+		0x10, 0x00, 0xF8, 0x00, 0x20, 0x00,
+		// Temperature.
+		0x00, 0x00,
+		// 500°/s, 0, -250°/s.
+		0x7F, 0xFF, 0x00, 0x00, 0xC0, 0x00,
+	})
+	m, err := New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := m.String(); s != "MPU9250" {
+		t.Fatal(s)
+	}
+	s := imu.Sample{MagneticField: [3]imu.MagneticFluxDensity{1, 2, 3}}
+	if err := m.Sense(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Acceleration != [3]imu.Acceleration{imu.EarthGravity / 2, -imu.EarthGravity / 4, imu.EarthGravity} {
+		t.Fatal(s.Acceleration)
+	}
+	if s.AngularVelocity != [3]imu.AngularVelocity{8726380183, 0, -250 * imu.DegreePerSecond} {
+		t.Fatal(s.AngularVelocity)
+	}
+	if s.MagneticField != [3]imu.MagneticFluxDensity{1, 2, 3} {
+		t.Fatal("magnetometer is not enabled")
+	}
+	s = imu.Sample{}
+	m.Precision(&s)
+	if s.Acceleration[0] != 1197*imu.MicroMetrePerSecond2 || s.AngularVelocity[0] != 266316*imu.NanoRadianPerSecond || s.MagneticField[0] != 0 {
+		t.Fatal(s)
+	}
+	// The range is reloaded.
+	if err := m.SetAccelRange(0x18); err != nil {
+		t.Fatal(err)
+	}
+	m.Precision(&s)
+	if s.Acceleration[0] != 4788*imu.MicroMetrePerSecond2 {
+		t.Fatal(s.Acceleration)
+	}
+	f.err = errors.New("bus failure")
+	if err := m.Sense(&s); err == nil {
+		t.Fatal("bus failure")
+	}
+}
+
+func TestSelfTest_reloadScales(t *testing.T) {
+	f := newFake()
+	// ±4g.
+	f.regs[reg.MPU9250_ACCEL_CONFIG] = 0x08
+	m, err := New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := imu.Sample{}
+	m.Precision(&s)
+	if s.Acceleration[0] != 1197*imu.MicroMetrePerSecond2 {
+		t.Fatal(s.Acceleration)
+	}
+	// The self test leaves the accelerometer at ±2g.
+	if _, err := m.SelfTest(); err != nil {
+		t.Fatal(err)
+	}
+	m.Precision(&s)
+	if s.Acceleration[0] != 598*imu.MicroMetrePerSecond2 {
+		t.Fatal(s.Acceleration)
+	}
+}
+
+func TestEnableMagnetometer(t *testing.T) {
+	f := newFake()
+	m, err := New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.EnableMagnetometer(); err != nil {
+		t.Fatal(err)
+	}
+	if f.mag[reg.MPU9250_MAG_CNTL] != 0x16 {
+		t.Fatalf("%x", f.mag[reg.MPU9250_MAG_CNTL])
+	}
+	if f.regs[reg.MPU9250_I2C_SLV0_ADDR] != 0x8C || f.regs[reg.MPU9250_I2C_SLV0_REG] != 0x03 || f.regs[reg.MPU9250_I2C_SLV0_CTRL] != 0x87 {
+		t.Fatal("slave 0 is not configured")
+	}
+	// X: 100, Y: -200, Z: 300; ST2.
+	copy(f.regs[reg.MPU9250_EXT_SENS_DATA_00:], []byte{0x64, 0x00, 0x38, 0xFF, 0x2C, 0x01, 0x10})
+	s := imu.Sample{}
+	if err := m.Sense(&s); err != nil {
+		t.Fatal(err)
+	}
+	// The axes are realigned and the sensitivity adjusted.
+	if s.MagneticField != [3]imu.MagneticFluxDensity{-14993, 14993, -67296} {
+		t.Fatal(s.MagneticField)
+	}
+	// Overflow.
+	f.regs[reg.MPU9250_EXT_SENS_DATA_06] = 0x18
+	f.regs[reg.MPU9250_EXT_SENS_DATA_00] = 0
+	if err := m.Sense(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s.MagneticField != [3]imu.MagneticFluxDensity{-14993, 14993, -67296} {
+		t.Fatal(s.MagneticField)
+	}
+	m.Precision(&s)
+	if s.MagneticField[2] != 150*imu.NanoTesla {
+		t.Fatal(s.MagneticField)
+	}
+}
+
+func TestEnableMagnetometer_fail(t *testing.T) {
+	f := newFake()
+	f.mag[reg.MPU9250_MAG_WIA] = 0
+	m, _ := New(f)
+	if err := m.EnableMagnetometer(); err == nil {
+		t.Fatal("invalid id")
+	}
+	f.nack = true
+	if err := m.EnableMagnetometer(); err == nil {
+		t.Fatal("nack")
+	}
+}
+
+func TestSenseContinuous(t *testing.T) {
+	f := newFake()
+	m, err := New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SenseContinuous(time.Second); err == nil {
+		t.Fatal("invalid interval")
+	}
+	c, err := m.SenseContinuous(10 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	if f.regs[reg.MPU9250_SMPLRT_DIV] != 9 || f.regs[reg.MPU9250_FIFO_EN] != 0x78 || f.regs[reg.MPU9250_USER_CTRL] != 0x40 {
+		t.Fatal("FIFO is not enabled")
+	}
+	// Two samples and a partial one.
+	f.fifo = []byte{
+		0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x83,
+		0x00, 0x00, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00,
+	}
+	f.mu.Unlock()
+	if s := <-c; s.Acceleration[0] != imu.EarthGravity || s.AngularVelocity[2] != 17443705 {
+		t.Fatal(s)
+	}
+	if s := <-c; s.Acceleration[1] != imu.EarthGravity {
+		t.Fatal(s)
+	}
+	if err := m.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-c; ok {
+		t.Fatal("channel must be closed")
+	}
+	if f.regs[reg.MPU9250_FIFO_EN] != 0 || f.regs[reg.MPU9250_USER_CTRL] != 0 {
+		t.Fatal("FIFO is not disabled")
+	}
+	if err := m.Halt(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDrainFIFO_overflow(t *testing.T) {
+	f := newFake()
+	m, _ := New(f)
+	f.regs[reg.MPU9250_INT_STATUS] = reg.MPU9250_FIFO_OFLOW_INT_MASK
+	if _, err := m.drainFIFO(make([]byte, fifoSize), 12); err == nil {
+		t.Fatal("overflow")
+	}
+	if f.regs[reg.MPU9250_USER_CTRL] != 0x40 {
+		t.Fatal("FIFO is not reset")
+	}
+}
+
+//
+
+// fakeTransport emulates the registers of the MPU-9250 and of the AK8963
+// behind its I²C master.
+type fakeTransport struct {
+	mu   sync.Mutex
+	regs [128]byte
+	mag  [0x13]byte
+	fifo []byte
+	nack bool
+	err  error
+}
+
+func newFake() *fakeTransport {
+	f := &fakeTransport{}
+	f.mag[reg.MPU9250_MAG_WIA] = 0x48
+	f.mag[reg.MPU9250_MAG_ASAX] = 128
+	f.mag[reg.MPU9250_MAG_ASAY] = 0
+	f.mag[reg.MPU9250_MAG_ASAZ] = 255
+	return f
+}
+
+func (f *fakeTransport) writeMaskedReg(address, mask, value byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.regs[address] = f.regs[address]&^mask | value&mask
+	return f.err
+}
+
+func (f *fakeTransport) readMaskedReg(address, mask byte) (byte, error) {
+	b, err := f.readByte(address)
+	return b & mask, err
+}
+
+func (f *fakeTransport) readByte(address byte) (byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v := f.regs[address]
+	if address == reg.MPU9250_I2C_MST_STATUS || address == reg.MPU9250_INT_STATUS {
+		f.regs[address] = 0
+	}
+	return v, f.err
+}
+
+func (f *fakeTransport) writeByte(address, value byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.regs[address] = value
+	if address == reg.MPU9250_USER_CTRL && value&reg.MPU9250_FIFO_RST_MASK != 0 {
+		f.regs[address] &^= reg.MPU9250_FIFO_RST_MASK
+		f.fifo = f.fifo[:0]
+	}
+	if address == reg.MPU9250_I2C_SLV4_CTRL && value&reg.MPU9250_I2C_SLV4_EN_MASK != 0 {
+		if f.nack {
+			f.regs[reg.MPU9250_I2C_MST_STATUS] = reg.MPU9250_I2C_SLV4_NACK_MASK
+			return f.err
+		}
+		r := f.regs[reg.MPU9250_I2C_SLV4_REG]
+		if f.regs[reg.MPU9250_I2C_SLV4_ADDR]&reg.MPU9250_I2C_SLV4_RNW_MASK != 0 {
+			f.regs[reg.MPU9250_I2C_SLV4_DI] = f.mag[r]
+		} else {
+			f.mag[r] = f.regs[reg.MPU9250_I2C_SLV4_DO]
+		}
+		f.regs[reg.MPU9250_I2C_MST_STATUS] = reg.MPU9250_I2C_SLV4_DONE_MASK
+	}
+	return f.err
+}
+
+func (f *fakeTransport) readUint16(address ...byte) (uint16, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if address[0] == reg.MPU9250_FIFO_COUNTH {
+		return uint16(len(f.fifo)), f.err
+	}
+	return uint16(f.regs[address[0]])<<8 | uint16(f.regs[address[1]]), f.err
+}
+
+func (f *fakeTransport) readBytes(address byte, b []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if address == reg.MPU9250_FIFO_R_W {
+		n := copy(b, f.fifo)
+		f.fifo = f.fifo[n:]
+	} else {
+		copy(b, f.regs[address:])
+	}
+	return f.err
+}
+
+func (f *fakeTransport) writeMagReg(address, value byte) error {
+	return f.err
+}
+
+func init() {
+	doSleep = func(time.Duration) {}
+}
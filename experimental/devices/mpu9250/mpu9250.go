@@ -13,8 +13,10 @@ package mpu9250
 import (
 	"fmt"
 	"math"
+	"sync"
 	"time"
 
+	"periph.io/x/periph/experimental/conn/imu"
 	"periph.io/x/periph/experimental/devices/mpu9250/reg"
 )
 
@@ -32,6 +34,7 @@ type (
 		readByte(address byte) (byte, error)
 		writeByte(address byte, value byte) error
 		readUint16(address ...byte) (uint16, error)
+		readBytes(address byte, b []byte) error
 		writeMagReg(address byte, value byte) error
 	}
 
@@ -65,6 +68,19 @@ type (
 	MPU9250 struct {
 		transport Proto
 		debug     func(string, ...interface{})
+
+		mu sync.Mutex
+		// scaled is true when accelFS and gyroFS reflect the current full scale
+		// ranges.
+		scaled  bool
+		accelFS byte
+		gyroFS  byte
+		// magAdj is the sensitivity adjustment of the magnetometer, plus 128.
+		magAdj  [3]int64
+		magOn   bool
+		lastMag [3]imu.MagneticFluxDensity
+		stop    chan struct{}
+		wg      sync.WaitGroup
 	}
 )
 
@@ -82,6 +98,7 @@ func (m *MPU9250) Debug(f DebugF) {
 
 // Init initializes the device
 func (m *MPU9250) Init() error {
+	m.invalidateScales()
 	if err := m.transferBatch(initSequence, "error initializing %d: [%x:%x] => %v"); err != nil {
 		return err
 	}
@@ -90,7 +107,8 @@ func (m *MPU9250) Init() error {
 
 // Calibrate Calibrates the device using maximum precision for both Gyroscope and Accelerometer.
 func (m *MPU9250) Calibrate() error {
-
+	// calibrateSequence resets the full scale ranges.
+	defer m.invalidateScales()
 	if err := m.transferBatch(calibrateSequence, "error calibrating %d: [%x:%x] => %v"); err != nil {
 		return err
 	}
@@ -250,6 +268,8 @@ var selftTestSequence = [][]byte{
 //
 //	returns the accelerator and gyroscope deviations from the factory defaults.
 func (m *MPU9250) SelfTest() (*SelfTestResult, error) {
+	// The full scale ranges are modified by the test.
+	defer m.invalidateScales()
 	if err := m.transferBatch(selftTestSequence, "error initializing self-test sequence %d: [%x:%x] => %v"); err != nil {
 		return nil, err
 	}
@@ -418,6 +438,7 @@ func (m *MPU9250) SetGyroRange(rangeVal byte) error {
 	if rangeVal > 3 {
 		return wrapf("accepted values are in the range 0 .. 3")
 	}
+	m.invalidateScales()
 	return m.transport.writeMaskedReg(reg.MPU9250_GYRO_CONFIG, reg.MPU9250_GYRO_FS_SEL_MASK, rangeVal<<3)
 }
 
@@ -445,6 +466,7 @@ func (m *MPU9250) SetAccelRange(rangeVal byte) error {
 	if (rangeVal >> 3) > 3 {
 		return wrapf("accepted values are in the range 0 .. 3")
 	}
+	m.invalidateScales()
 	return m.transport.writeMaskedReg(reg.MPU9250_ACCEL_CONFIG, reg.MPU9250_ACCEL_FS_SEL_MASK, rangeVal)
 }
 
@@ -1888,6 +1910,16 @@ func boolByte(v bool, mask byte) byte {
 	return 0
 }
 
+// invalidateScales forces the full scale ranges to be read again, after
+// ACCEL_CONFIG or GYRO_CONFIG was written to.
+//
+// m.mu must not be held.
+func (m *MPU9250) invalidateScales() {
+	m.mu.Lock()
+	m.scaled = false
+	m.mu.Unlock()
+}
+
 func (m *MPU9250) transferBatch(seq [][]byte, msg string) error {
 	for i, cmds := range seq {
 		if len(cmds) == 2 {
@@ -117,6 +117,24 @@ func (s *SpiTransport) readUint16(address ...byte) (uint16, error) {
 	return uint16(h)<<8 | uint16(l), nil
 }
 
+func (s *SpiTransport) readBytes(address byte, b []byte) error {
+	s.debug("read %d bytes from register %x", len(b), address)
+	buf := make([]byte, len(b)+1)
+	res := make([]byte, len(b)+1)
+	buf[0] = 0x80 | address
+	if err := s.cs.Out(gpio.Low); err != nil {
+		return err
+	}
+	if err := s.device.Tx(buf, res); err != nil {
+		return err
+	}
+	if err := s.cs.Out(gpio.High); err != nil {
+		return err
+	}
+	copy(b, res[1:])
+	return nil
+}
+
 func (s *SpiTransport) printFunc(msg string, args ...interface{}) {
 	fmt.Printf("SPI: "+msg+"\n", args...)
 }
@@ -0,0 +1,18 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package pca9685 controls a NXP PCA9685 16 channels 12 bits PWM controller
+// over I²C.
+//
+// The 16 channels are exposed as output only gpio.PinIO and registered in
+// gpioreg, so they can be used like any other PWM capable pin. All the
+// channels share the same PWM frequency.
+//
+// Servo drives a hobby servo from any PWM capable pin, including the ones of
+// this device.
+//
+// Datasheet
+//
+// https://www.nxp.com/docs/en/data-sheet/PCA9685.pdf
+package pca9685
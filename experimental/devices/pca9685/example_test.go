@@ -0,0 +1,54 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pca9685_test
+
+import (
+	"log"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/experimental/devices/pca9685"
+	"periph.io/x/periph/host"
+)
+
+func Example() {
+	// Make sure periph is initialized.
+	if _, err := host.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Use i2creg I²C bus registry to find the first available I²C bus.
+	b, err := i2creg.Open("")
+	if err != nil {
+		log.Fatalf("failed to open I²C: %v", err)
+	}
+	defer b.Close()
+
+	d, err := pca9685.NewI2C(b, &pca9685.DefaultOpts)
+	if err != nil {
+		log.Fatalf("failed to initialize pca9685: %v", err)
+	}
+	defer d.Close()
+
+	// The channels are regular GPIO pins; dim a LED on channel 0.
+	if err := gpioreg.ByName("PCA9685_40_0").PWM(gpio.DutyMax/4, 0); err != nil {
+		log.Fatal(err)
+	}
+
+	// Sweep a servo on channel 1.
+	s, err := pca9685.NewServo(gpioreg.ByName("PCA9685_40_1"), time.Millisecond, 2*time.Millisecond, 180*physic.Degree)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for a := physic.Angle(0); a <= 180*physic.Degree; a += 30 * physic.Degree {
+		if err := s.SetAngle(a); err != nil {
+			log.Fatal(err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
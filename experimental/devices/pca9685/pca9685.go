@@ -0,0 +1,411 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pca9685
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/mmr"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/pin"
+)
+
+// Opts holds the configuration options.
+type Opts struct {
+	// I2CAddress is the address on the bus, between 0x40 and 0x7F depending on
+	// the wiring of the A0~A5 pins.
+	I2CAddress uint16
+	// Frequency is the initial PWM frequency, between 24Hz and 1526Hz. The
+	// power on default of 200Hz is used when 0.
+	Frequency physic.Frequency
+	// OpenDrain configures the outputs as open drain instead of totem pole.
+	OpenDrain bool
+}
+
+// DefaultOpts is the recommended default options.
+//
+// 50Hz is the frequency expected by most servos.
+var DefaultOpts = Opts{
+	I2CAddress: 0x40,
+	Frequency:  50 * physic.Hertz,
+}
+
+// NewI2C returns an object that communicates over I²C to a PCA9685.
+//
+// All the channels are turned off and registered in gpioreg as
+// "PCA9685_<address>_<channel>", for example "PCA9685_40_0". Call Close() to
+// unregister them.
+func NewI2C(b i2c.Bus, opts *Opts) (*Dev, error) {
+	if opts.I2CAddress < 0x40 || opts.I2CAddress > 0x7F {
+		return nil, fmt.Errorf("pca9685: invalid I²C address 0x%X; must be between 0x40 and 0x7F", opts.I2CAddress)
+	}
+	f := opts.Frequency
+	if f == 0 {
+		f = 200 * physic.Hertz
+	}
+	d := &Dev{
+		c:    mmr.Dev8{Conn: &i2c.Dev{Bus: b, Addr: opts.I2CAddress}, Order: binary.LittleEndian},
+		name: fmt.Sprintf("PCA9685_%X", opts.I2CAddress),
+	}
+	for i := range d.pins {
+		d.pins[i] = Pin{d: d, n: i, name: d.name + "_" + strconv.Itoa(i)}
+	}
+	// Enable register auto-increment before the multi-byte ALL_LED write, and
+	// keep the oscillator off until the prescaler is set.
+	if err := d.c.WriteUint8(regMode1, mode1AI|mode1AllCall|mode1Sleep); err != nil {
+		return nil, fmt.Errorf("pca9685: %v", err)
+	}
+	mode2 := mode2OutDrv
+	if opts.OpenDrain {
+		mode2 = 0
+	}
+	if err := d.c.WriteUint8(regMode2, mode2); err != nil {
+		return nil, fmt.Errorf("pca9685: %v", err)
+	}
+	if err := d.c.WriteUint32(regAllLEDOnL, ledFull<<16); err != nil {
+		return nil, fmt.Errorf("pca9685: %v", err)
+	}
+	if err := d.setFrequency(f); err != nil {
+		return nil, err
+	}
+	for i := range d.pins {
+		if err := gpioreg.Register(&d.pins[i]); err != nil {
+			for j := 0; j < i; j++ {
+				_ = gpioreg.Unregister(d.pins[j].name)
+			}
+			return nil, fmt.Errorf("pca9685: %v", err)
+		}
+	}
+	return d, nil
+}
+
+// Dev is a handle to a PCA9685.
+type Dev struct {
+	c    mmr.Dev8
+	name string
+
+	mu       sync.Mutex
+	prescale uint8
+	closed   bool
+	pins     [16]Pin
+}
+
+func (d *Dev) String() string {
+	return fmt.Sprintf("PCA9685{%s}", d.c.Conn)
+}
+
+// Halt implements conn.Resource.
+//
+// It turns off all the channels.
+func (d *Dev) Halt() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.c.WriteUint32(regAllLEDOnL, ledFull<<16); err != nil {
+		return err
+	}
+	for i := range d.pins {
+		d.pins[i].duty = 0
+		d.pins[i].pwm = false
+	}
+	return nil
+}
+
+// Close turns off all the channels and unregisters the pins from gpioreg.
+func (d *Dev) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return errors.New("pca9685: already closed")
+	}
+	d.closed = true
+	d.mu.Unlock()
+	for i := range d.pins {
+		_ = gpioreg.Unregister(d.pins[i].name)
+	}
+	return d.Halt()
+}
+
+// Pin returns the channel n, between 0 and 15.
+func (d *Dev) Pin(n int) (*Pin, error) {
+	if n < 0 || n >= len(d.pins) {
+		return nil, fmt.Errorf("pca9685: invalid channel %d", n)
+	}
+	return &d.pins[n], nil
+}
+
+// Frequency returns the actual PWM frequency of all the channels.
+func (d *Dev) Frequency() physic.Frequency {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return prescaleToFrequency(d.prescale)
+}
+
+// SetFrequency sets the PWM frequency of all the channels, between 24Hz and
+// 1526Hz.
+//
+// The duty cycles are kept.
+func (d *Dev) SetFrequency(f physic.Frequency) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.setFrequency(f)
+}
+
+// Pin is a channel of the PCA9685.
+//
+// It implements gpio.PinIO, but fails on input.
+type Pin struct {
+	d    *Dev
+	n    int
+	name string
+
+	// Protected by d.mu.
+	duty gpio.Duty
+	pwm  bool
+}
+
+// String implements conn.Resource.
+func (p *Pin) String() string {
+	return p.name
+}
+
+// Halt implements conn.Resource.
+//
+// It turns off the channel.
+func (p *Pin) Halt() error {
+	return p.Out(gpio.Low)
+}
+
+// Name implements pin.Pin.
+func (p *Pin) Name() string {
+	return p.name
+}
+
+// Number implements pin.Pin.
+func (p *Pin) Number() int {
+	return p.n
+}
+
+// Function implements pin.Pin.
+func (p *Pin) Function() string {
+	return string(p.Func())
+}
+
+// Func implements pin.PinFunc.
+func (p *Pin) Func() pin.Func {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	switch {
+	case p.pwm:
+		return gpio.PWM
+	case p.duty == gpio.DutyMax:
+		return gpio.OUT_HIGH
+	default:
+		return gpio.OUT_LOW
+	}
+}
+
+// SupportedFuncs implements pin.PinFunc.
+func (p *Pin) SupportedFuncs() []pin.Func {
+	return []pin.Func{gpio.OUT, gpio.PWM}
+}
+
+// SetFunc implements pin.PinFunc.
+//
+// Only the output functions are supported. Use PWM() to generate a PWM.
+func (p *Pin) SetFunc(f pin.Func) error {
+	switch f {
+	case gpio.OUT, gpio.OUT_LOW:
+		return p.Out(gpio.Low)
+	case gpio.OUT_HIGH:
+		return p.Out(gpio.High)
+	default:
+		return fmt.Errorf("pca9685: function %s is not supported", f)
+	}
+}
+
+// In implements gpio.PinIn.
+//
+// It always fails since the channels are output only.
+func (p *Pin) In(pull gpio.Pull, edge gpio.Edge) error {
+	return errors.New("pca9685: pins are output only")
+}
+
+// Read implements gpio.PinIn.
+//
+// It always returns gpio.Low.
+func (p *Pin) Read() gpio.Level {
+	return gpio.Low
+}
+
+// WaitForEdge implements gpio.PinIn.
+//
+// It always returns false.
+func (p *Pin) WaitForEdge(timeout time.Duration) bool {
+	return false
+}
+
+// Pull implements gpio.PinIn.
+func (p *Pin) Pull() gpio.Pull {
+	return gpio.PullNoChange
+}
+
+// DefaultPull implements gpio.PinIn.
+func (p *Pin) DefaultPull() gpio.Pull {
+	return gpio.PullNoChange
+}
+
+// Out implements gpio.PinOut.
+//
+// It uses the full on and full off bits of the channel.
+func (p *Pin) Out(l gpio.Level) error {
+	d := gpio.Duty(0)
+	if l {
+		d = gpio.DutyMax
+	}
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	return p.setDuty(d, false)
+}
+
+// PWM implements gpio.PinOut.
+//
+// duty is rounded to the 4096 steps of the channel. A duty of 0 or DutyMax
+// fully turns off or on the channel.
+//
+// f changes the frequency of all the channels, so it fails if it differs
+// from the current frequency while another channel is generating a PWM. Use
+// 0 to keep the current frequency.
+func (p *Pin) PWM(duty gpio.Duty, f physic.Frequency) error {
+	if !duty.Valid() {
+		return fmt.Errorf("pca9685: invalid duty %s", duty)
+	}
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	if f != 0 {
+		ps, err := frequencyToPrescale(f)
+		if err != nil {
+			return err
+		}
+		if ps != p.d.prescale {
+			for i := range p.d.pins {
+				if o := &p.d.pins[i]; o != p && o.pwm {
+					return fmt.Errorf("pca9685: can't change frequency to %s; %s uses %s", f, o.name, prescaleToFrequency(p.d.prescale))
+				}
+			}
+			if err := p.d.setFrequency(f); err != nil {
+				return err
+			}
+		}
+	}
+	return p.setDuty(duty, true)
+}
+
+//
+
+const (
+	regMode1     uint8 = 0x00
+	regMode2     uint8 = 0x01
+	regLED0OnL   uint8 = 0x06 // 4 registers per channel
+	regAllLEDOnL uint8 = 0xFA
+	regPrescale  uint8 = 0xFE
+
+	mode1Restart uint8 = 1 << 7
+	mode1AI      uint8 = 1 << 5 // Register auto-increment
+	mode1Sleep   uint8 = 1 << 4
+	mode1AllCall uint8 = 1 << 0
+	mode2OutDrv  uint8 = 1 << 2 // Totem pole instead of open drain
+
+	// ledFull is the full on or full off bit in the ON and OFF words.
+	ledFull uint32 = 1 << 12
+
+	// oscillator is the internal oscillator frequency.
+	oscillator = 25 * physic.MegaHertz
+)
+
+// frequencyToPrescale returns the PRE_SCALE value as specified in the
+// datasheet section 7.3.5.
+func frequencyToPrescale(f physic.Frequency) (uint8, error) {
+	if f <= 0 {
+		return 0, fmt.Errorf("pca9685: invalid frequency %s", f)
+	}
+	ps := (oscillator+2048*f)/(4096*f) - 1
+	if ps < 3 || ps > 255 {
+		return 0, fmt.Errorf("pca9685: invalid frequency %s; must be between %s and %s", f, prescaleToFrequency(255), prescaleToFrequency(3))
+	}
+	return uint8(ps), nil
+}
+
+func prescaleToFrequency(ps uint8) physic.Frequency {
+	return oscillator / (4096 * (physic.Frequency(ps) + 1))
+}
+
+// setFrequency sets the prescaler, which can only be done while the
+// oscillator is off, then restarts the PWM channels.
+//
+// d.mu must be held.
+func (d *Dev) setFrequency(f physic.Frequency) error {
+	ps, err := frequencyToPrescale(f)
+	if err != nil {
+		return err
+	}
+	if err := d.c.WriteUint8(regMode1, mode1AI|mode1AllCall|mode1Sleep); err != nil {
+		return fmt.Errorf("pca9685: %v", err)
+	}
+	if err := d.c.WriteUint8(regPrescale, ps); err != nil {
+		return fmt.Errorf("pca9685: %v", err)
+	}
+	if err := d.c.WriteUint8(regMode1, mode1AI|mode1AllCall); err != nil {
+		return fmt.Errorf("pca9685: %v", err)
+	}
+	// The oscillator takes up to 500µs to stabilize.
+	doSleep(500 * time.Microsecond)
+	if err := d.c.WriteUint8(regMode1, mode1AI|mode1AllCall|mode1Restart); err != nil {
+		return fmt.Errorf("pca9685: %v", err)
+	}
+	d.prescale = ps
+	return nil
+}
+
+// setDuty writes the ON and OFF words of the channel.
+//
+// d.mu must be held.
+func (p *Pin) setDuty(duty gpio.Duty, pwm bool) error {
+	if p.d.closed {
+		return errors.New("pca9685: already closed")
+	}
+	// ON is always at count 0, so OFF is the number of high counts.
+	var v uint32
+	switch off := uint32((uint64(duty)*4096 + uint64(gpio.DutyMax)/2) / uint64(gpio.DutyMax)); {
+	case off == 0:
+		v = ledFull << 16
+		duty = 0
+		pwm = false
+	case off >= 4096:
+		v = ledFull
+		duty = gpio.DutyMax
+		pwm = false
+	default:
+		v = off << 16
+	}
+	if err := p.d.c.WriteUint32(regLED0OnL+4*uint8(p.n), v); err != nil {
+		return fmt.Errorf("pca9685: %v", err)
+	}
+	p.duty = duty
+	p.pwm = pwm
+	return nil
+}
+
+var doSleep = time.Sleep
+
+var _ gpio.PinIO = &Pin{}
+var _ pin.PinFunc = &Pin{}
@@ -0,0 +1,252 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pca9685
+
+import (
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/physic"
+)
+
+func TestNewI2C(t *testing.T) {
+	bus := i2ctest.Playback{
+		Ops: append(initOps(0x40, 121),
+			// Channel 3 full on.
+			i2ctest.IO{Addr: 0x40, W: []byte{0x12, 0x00, 0x10, 0x00, 0x00}},
+			// Channel 0 at 50%.
+			i2ctest.IO{Addr: 0x40, W: []byte{0x06, 0x00, 0x00, 0x00, 0x08}},
+			// Channel 0 full off.
+			i2ctest.IO{Addr: 0x40, W: []byte{0x06, 0x00, 0x00, 0x00, 0x10}},
+			// Close.
+			i2ctest.IO{Addr: 0x40, W: []byte{0xFA, 0x00, 0x00, 0x00, 0x10}},
+		),
+	}
+	d, err := NewI2C(&bus, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := d.String(); s != "PCA9685{playback(64)}" {
+		t.Fatal(s)
+	}
+	if f := d.Frequency(); f != 50028816*physic.MicroHertz {
+		t.Fatal(f)
+	}
+	if _, err := d.Pin(16); err == nil {
+		t.Fatal("invalid channel")
+	}
+	p := gpioreg.ByName("PCA9685_40_3")
+	if p == nil {
+		t.Fatal("not registered")
+	}
+	if p.Number() != 3 || p.String() != "PCA9685_40_3" {
+		t.Fatal(p)
+	}
+	if err := p.In(gpio.PullUp, gpio.NoEdge); err == nil {
+		t.Fatal("output only")
+	}
+	if p.Read() != gpio.Low || p.WaitForEdge(0) || p.Pull() != gpio.PullNoChange || p.DefaultPull() != gpio.PullNoChange {
+		t.Fatal("unexpected input")
+	}
+	if err := p.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if f := p.Function(); f != "Out/High" {
+		t.Fatal(f)
+	}
+	p0, err := d.Pin(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p0.PWM(gpio.DutyHalf, 0); err != nil {
+		t.Fatal(err)
+	}
+	if f := p0.Func(); f != gpio.PWM {
+		t.Fatal(f)
+	}
+	if err := p0.PWM(-1, 0); err == nil {
+		t.Fatal("invalid duty")
+	}
+	if err := p0.PWM(gpio.DutyHalf, 10*physic.KiloHertz); err == nil {
+		t.Fatal("invalid frequency")
+	}
+	p1, err := d.Pin(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p1.PWM(gpio.DutyHalf, 100*physic.Hertz); err == nil {
+		t.Fatal("channel 0 uses 50Hz")
+	}
+	if err := p0.SetFunc(gpio.IN); err == nil {
+		t.Fatal("output only")
+	}
+	if err := p0.SetFunc(gpio.OUT); err != nil {
+		t.Fatal(err)
+	}
+	if f := p0.Func(); f != gpio.OUT_LOW {
+		t.Fatal(f)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gpioreg.ByName("PCA9685_40_3") != nil {
+		t.Fatal("not unregistered")
+	}
+	if err := d.Close(); err == nil {
+		t.Fatal("already closed")
+	}
+	if err := p0.Out(gpio.High); err == nil {
+		t.Fatal("already closed")
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewI2C_fail(t *testing.T) {
+	if _, err := NewI2C(&i2ctest.Playback{}, &Opts{I2CAddress: 0x20}); err == nil {
+		t.Fatal("invalid address")
+	}
+	bus := i2ctest.Playback{Ops: initOps(0x41, 30)[:3], DontPanic: true}
+	if _, err := NewI2C(&bus, &Opts{I2CAddress: 0x41, Frequency: 10 * physic.Hertz}); err == nil {
+		t.Fatal("invalid frequency")
+	}
+	bus = i2ctest.Playback{Ops: initOps(0x41, 30)}
+	d, err := NewI2C(&bus, &Opts{I2CAddress: 0x41})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewI2C(&i2ctest.Playback{Ops: initOps(0x41, 30)}, &Opts{I2CAddress: 0x41}); err == nil {
+		t.Fatal("pins already registered")
+	}
+	if gpioreg.ByName("PCA9685_41_0") == nil {
+		t.Fatal("the first device pins must stay registered")
+	}
+	bus.Ops = append(bus.Ops, i2ctest.IO{Addr: 0x41, W: []byte{0xFA, 0x00, 0x00, 0x00, 0x10}})
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPin_PWM_frequency(t *testing.T) {
+	bus := i2ctest.Playback{
+		Ops: append(initOps(0x40, 121),
+			// Channel 15 at 100Hz and 25%.
+			i2ctest.IO{Addr: 0x40, W: []byte{0x00, 0x31}},
+			i2ctest.IO{Addr: 0x40, W: []byte{0xFE, 60}},
+			i2ctest.IO{Addr: 0x40, W: []byte{0x00, 0x21}},
+			i2ctest.IO{Addr: 0x40, W: []byte{0x00, 0xA1}},
+			i2ctest.IO{Addr: 0x40, W: []byte{0x42, 0x00, 0x00, 0x00, 0x04}},
+			// Rounded to full on.
+			i2ctest.IO{Addr: 0x40, W: []byte{0x42, 0x00, 0x10, 0x00, 0x00}},
+			i2ctest.IO{Addr: 0x40, W: []byte{0xFA, 0x00, 0x00, 0x00, 0x10}},
+		),
+	}
+	d, err := NewI2C(&bus, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := d.Pin(15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PWM(gpio.DutyMax/4, 100*physic.Hertz); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PWM(gpio.DutyMax-1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if f := p.Func(); f != gpio.OUT_HIGH {
+		t.Fatal(f)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServo(t *testing.T) {
+	p := gpiotest.Pin{N: "GPIO1"}
+	if _, err := NewServo(&p, 2*time.Millisecond, time.Millisecond, 180*physic.Degree); err == nil {
+		t.Fatal("invalid pulse range")
+	}
+	if _, err := NewServo(&p, time.Millisecond, 2*time.Millisecond, 0); err == nil {
+		t.Fatal("invalid travel")
+	}
+	s, err := NewServo(&p, time.Millisecond, 2*time.Millisecond, 180*physic.Degree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if str := s.String(); str != "Servo{GPIO1(0)}" {
+		t.Fatal(str)
+	}
+	if err := s.SetAngle(90 * physic.Degree); err != nil {
+		t.Fatal(err)
+	}
+	if p.F != ServoFrequency || p.D != 1258291 {
+		t.Fatal(p.F, p.D)
+	}
+	if err := s.SetAngle(181 * physic.Degree); err == nil {
+		t.Fatal("invalid angle")
+	}
+	if err := s.SetPulse(500 * time.Microsecond); err == nil {
+		t.Fatal("invalid pulse")
+	}
+	if err := s.Halt(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServo_PCA9685(t *testing.T) {
+	bus := i2ctest.Playback{
+		Ops: append(initOps(0x40, 121),
+			// 1.5ms is 307 counts out of 4096 at 50Hz.
+			i2ctest.IO{Addr: 0x40, W: []byte{0x0A, 0x00, 0x00, 0x33, 0x01}},
+			i2ctest.IO{Addr: 0x40, W: []byte{0xFA, 0x00, 0x00, 0x00, 0x10}},
+		),
+	}
+	d, err := NewI2C(&bus, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServo(gpioreg.ByName("PCA9685_40_1"), time.Millisecond, 2*time.Millisecond, 180*physic.Degree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetAngle(90 * physic.Degree); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//
+
+// initOps returns the expected initialization of the device.
+func initOps(addr uint16, prescale byte) []i2ctest.IO {
+	return []i2ctest.IO{
+		{Addr: addr, W: []byte{0x00, 0x31}},
+		{Addr: addr, W: []byte{0x01, 0x04}},
+		{Addr: addr, W: []byte{0xFA, 0x00, 0x00, 0x00, 0x10}},
+		{Addr: addr, W: []byte{0x00, 0x31}},
+		{Addr: addr, W: []byte{0xFE, prescale}},
+		{Addr: addr, W: []byte{0x00, 0x21}},
+		{Addr: addr, W: []byte{0x00, 0xA1}},
+	}
+}
+
+func init() {
+	doSleep = func(time.Duration) {}
+}
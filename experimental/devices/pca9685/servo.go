@@ -0,0 +1,72 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pca9685
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/physic"
+)
+
+// ServoFrequency is the PWM frequency expected by most hobby servos.
+const ServoFrequency = 50 * physic.Hertz
+
+// Servo drives a hobby servo with a PWM capable pin.
+//
+// The position is encoded as the width of a pulse repeated at
+// ServoFrequency. It is not limited to the pins of a PCA9685.
+type Servo struct {
+	p        gpio.PinOut
+	minPulse time.Duration
+	maxPulse time.Duration
+	travel   physic.Angle
+}
+
+// NewServo returns a Servo driven by the pin p.
+//
+// minPulse is the pulse width at the angle 0 and maxPulse the one at the
+// angle travel. Typical values are 1ms and 2ms for a travel of 180°, but
+// they vary between models.
+func NewServo(p gpio.PinOut, minPulse, maxPulse time.Duration, travel physic.Angle) (*Servo, error) {
+	if minPulse <= 0 || maxPulse <= minPulse || maxPulse >= ServoFrequency.Duration() {
+		return nil, fmt.Errorf("pca9685: invalid servo pulse range [%s, %s]", minPulse, maxPulse)
+	}
+	if travel <= 0 {
+		return nil, errors.New("pca9685: invalid servo travel")
+	}
+	return &Servo{p: p, minPulse: minPulse, maxPulse: maxPulse, travel: travel}, nil
+}
+
+func (s *Servo) String() string {
+	return fmt.Sprintf("Servo{%s}", s.p)
+}
+
+// Halt implements conn.Resource.
+//
+// It stops the pulses, which lets most servos turn freely.
+func (s *Servo) Halt() error {
+	return s.p.Out(gpio.Low)
+}
+
+// SetAngle moves the servo to the angle a, between 0 and the travel.
+func (s *Servo) SetAngle(a physic.Angle) error {
+	if a < 0 || a > s.travel {
+		return fmt.Errorf("pca9685: invalid servo angle %s; must be between 0 and %s", a, s.travel)
+	}
+	pulse := s.minPulse + time.Duration(int64(s.maxPulse-s.minPulse)*int64(a)/int64(s.travel))
+	return s.SetPulse(pulse)
+}
+
+// SetPulse sets the pulse width directly, between the limits of the servo.
+func (s *Servo) SetPulse(pulse time.Duration) error {
+	if pulse < s.minPulse || pulse > s.maxPulse {
+		return fmt.Errorf("pca9685: invalid servo pulse %s; must be between %s and %s", pulse, s.minPulse, s.maxPulse)
+	}
+	duty := gpio.Duty(int64(pulse) * int64(gpio.DutyMax) / int64(ServoFrequency.Duration()))
+	return s.p.PWM(duty, ServoFrequency)
+}
@@ -0,0 +1,23 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package pcf8574 controls a NXP/TI PCF8574 or PCF8574A 8 bits I/O expander
+// over I²C.
+//
+// The 8 lines are exposed as gpio.PinIO and registered in gpioreg as
+// "<chip>_<hardware address>_P<line>", for example "PCF8574_0_P3" or
+// "PCF8574A_7_P0".
+//
+// The lines are quasi-bidirectional: an input is an output set high with a
+// weak pull-up, so an input is always pulled up.
+//
+// When the INT line of the expander is connected to a GPIO, it is used to
+// implement WaitForEdge() on each line. It also keeps the input values
+// cached, so Read() doesn't require a bus transaction. Read() on an output
+// line always returns the cached output latch.
+//
+// Datasheet
+//
+// http://www.ti.com/lit/ds/symlink/pcf8574.pdf
+package pcf8574
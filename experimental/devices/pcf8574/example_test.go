@@ -0,0 +1,42 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pcf8574_test
+
+import (
+	"fmt"
+	"log"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/experimental/devices/pcf8574"
+	"periph.io/x/periph/host"
+)
+
+func Example() {
+	// Make sure periph is initialized.
+	if _, err := host.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Use i2creg I²C bus registry to find the first available I²C bus.
+	b, err := i2creg.Open("")
+	if err != nil {
+		log.Fatalf("failed to open I²C: %v", err)
+	}
+	defer b.Close()
+
+	d, err := pcf8574.NewPCF8574(b, &pcf8574.DefaultOpts)
+	if err != nil {
+		log.Fatalf("failed to initialize pcf8574: %v", err)
+	}
+	defer d.Close()
+
+	// The expander lines are regular GPIO pins.
+	if err := gpioreg.ByName("PCF8574_0_P0").Out(gpio.Low); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("P1: %s\n", gpioreg.ByName("PCF8574_0_P1").Read())
+}
@@ -0,0 +1,433 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pcf8574
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/pin"
+)
+
+// Opts holds the configuration options.
+type Opts struct {
+	// HardwareAddress is the address set by the wiring of the A2~A0 pins,
+	// between 0 and 7.
+	HardwareAddress uint8
+	// Interrupt is the optional GPIO connected to the INT pin of the expander.
+	// It is required to use edge detection on the expander lines.
+	Interrupt gpio.PinIn
+}
+
+// DefaultOpts is the recommended default options.
+var DefaultOpts = Opts{}
+
+// NewPCF8574 returns an object that communicates over I²C to a PCF8574.
+//
+// All the lines are set as input.
+func NewPCF8574(b i2c.Bus, opts *Opts) (*Dev, error) {
+	return newDev(b, opts, "PCF8574", 0x20)
+}
+
+// NewPCF8574A returns an object that communicates over I²C to a PCF8574A,
+// which only differs by its I²C address.
+//
+// All the lines are set as input.
+func NewPCF8574A(b i2c.Bus, opts *Opts) (*Dev, error) {
+	return newDev(b, opts, "PCF8574A", 0x38)
+}
+
+// Dev is a handle to a PCF8574 or PCF8574A.
+type Dev struct {
+	c    i2c.Dev
+	name string
+	intr gpio.PinIn
+	pins [8]Pin
+
+	mu sync.Mutex
+	// latch is the last value written; 1 is an input or an output high.
+	latch byte
+	// outputs is the lines set with Out().
+	outputs byte
+	// polarity is the lines with inverted input.
+	polarity byte
+	// in is the last raw input value read. It is kept up to date by the
+	// interrupt when Opts.Interrupt is set.
+	in     byte
+	closed bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func (d *Dev) String() string {
+	return fmt.Sprintf("%s{%s}", d.name, d.c.Bus)
+}
+
+// Halt implements conn.Resource.
+//
+// It disables edge detection on all the lines.
+func (d *Dev) Halt() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.pins {
+		d.pins[i].edge = gpio.NoEdge
+	}
+	return nil
+}
+
+// Close disables edge detection and unregisters the lines from gpioreg.
+func (d *Dev) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return errors.New("pcf8574: already closed")
+	}
+	d.closed = true
+	d.mu.Unlock()
+	if d.intr != nil {
+		close(d.stop)
+		<-d.done
+	}
+	for i := range d.pins {
+		_ = gpioreg.Unregister(d.pins[i].name)
+	}
+	return d.Halt()
+}
+
+// Pin returns the line n, between 0 and 7.
+func (d *Dev) Pin(n int) (*Pin, error) {
+	if n < 0 || n >= len(d.pins) {
+		return nil, fmt.Errorf("pcf8574: invalid line %d", n)
+	}
+	return &d.pins[n], nil
+}
+
+// Pin is a line of the expander.
+//
+// It implements gpio.PinIO.
+type Pin struct {
+	d     *Dev
+	n     uint
+	name  string
+	edges chan struct{}
+
+	// Protected by d.mu.
+	edge gpio.Edge
+}
+
+// String implements conn.Resource.
+func (p *Pin) String() string {
+	return p.name
+}
+
+// Halt implements conn.Resource.
+//
+// It disables edge detection on the line.
+func (p *Pin) Halt() error {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	p.edge = gpio.NoEdge
+	return nil
+}
+
+// Name implements pin.Pin.
+func (p *Pin) Name() string {
+	return p.name
+}
+
+// Number implements pin.Pin.
+func (p *Pin) Number() int {
+	return int(p.n)
+}
+
+// Function implements pin.Pin.
+func (p *Pin) Function() string {
+	return string(p.Func())
+}
+
+// Func implements pin.PinFunc.
+func (p *Pin) Func() pin.Func {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	switch {
+	case p.d.outputs&p.mask() == 0:
+		return gpio.IN
+	case p.d.latch&p.mask() != 0:
+		return gpio.OUT_HIGH
+	default:
+		return gpio.OUT_LOW
+	}
+}
+
+// SupportedFuncs implements pin.PinFunc.
+func (p *Pin) SupportedFuncs() []pin.Func {
+	return []pin.Func{gpio.IN, gpio.OUT}
+}
+
+// SetFunc implements pin.PinFunc.
+func (p *Pin) SetFunc(f pin.Func) error {
+	switch f {
+	case gpio.IN:
+		return p.In(gpio.PullNoChange, gpio.NoEdge)
+	case gpio.OUT, gpio.OUT_LOW:
+		return p.Out(gpio.Low)
+	case gpio.OUT_HIGH:
+		return p.Out(gpio.High)
+	default:
+		return fmt.Errorf("pcf8574: function %s is not supported", f)
+	}
+}
+
+// In implements gpio.PinIn.
+//
+// Only gpio.PullUp and gpio.PullNoChange are supported since inputs are
+// always weakly pulled up. Edge detection requires Opts.Interrupt.
+func (p *Pin) In(pull gpio.Pull, edge gpio.Edge) error {
+	if pull != gpio.PullUp && pull != gpio.PullNoChange {
+		return fmt.Errorf("pcf8574: pull %s is not supported", pull)
+	}
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	if p.d.closed {
+		return errors.New("pcf8574: already closed")
+	}
+	if edge != gpio.NoEdge && p.d.intr == nil {
+		return errors.New("pcf8574: edge detection requires Opts.Interrupt")
+	}
+	if err := p.d.write(p.d.latch | p.mask()); err != nil {
+		return err
+	}
+	p.d.outputs &^= p.mask()
+	p.edge = gpio.NoEdge
+	if p.d.intr != nil {
+		// Refresh the cached value, which is the reference value to compare to
+		// for edges. This also acknowledges a pending interrupt.
+		if _, err := p.d.read(); err != nil {
+			return err
+		}
+	}
+	p.edge = edge
+	// Flush any accumulated edge.
+	select {
+	case <-p.edges:
+	default:
+	}
+	return nil
+}
+
+// Read implements gpio.PinIn.
+//
+// The level of an output line is returned from the cached latch. The level of
+// an input line is returned from the value cached from the last interrupt
+// when Opts.Interrupt is set; otherwise it is read from the bus.
+func (p *Pin) Read() gpio.Level {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	m := p.mask()
+	if p.d.outputs&m != 0 {
+		return gpio.Level(p.d.latch&m != 0)
+	}
+	v := p.d.in ^ p.d.polarity
+	if p.d.intr == nil {
+		var err error
+		if v, err = p.d.read(); err != nil {
+			return gpio.Low
+		}
+	}
+	return gpio.Level(v&m != 0)
+}
+
+// WaitForEdge implements gpio.PinIn.
+func (p *Pin) WaitForEdge(timeout time.Duration) bool {
+	p.d.mu.Lock()
+	edge := p.edge
+	p.d.mu.Unlock()
+	if edge == gpio.NoEdge {
+		return false
+	}
+	if timeout == -1 {
+		<-p.edges
+		return true
+	}
+	select {
+	case <-p.edges:
+		return true
+	default:
+	}
+	select {
+	case <-p.edges:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Pull implements gpio.PinIn.
+func (p *Pin) Pull() gpio.Pull {
+	return gpio.PullUp
+}
+
+// DefaultPull implements gpio.PinIn.
+func (p *Pin) DefaultPull() gpio.Pull {
+	return gpio.PullUp
+}
+
+// Out implements gpio.PinOut.
+//
+// A high output is only weakly driven.
+func (p *Pin) Out(l gpio.Level) error {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	if p.d.closed {
+		return errors.New("pcf8574: already closed")
+	}
+	latch := p.d.latch &^ p.mask()
+	if l {
+		latch |= p.mask()
+	}
+	if err := p.d.write(latch); err != nil {
+		return err
+	}
+	p.d.outputs |= p.mask()
+	p.edge = gpio.NoEdge
+	return nil
+}
+
+// PWM implements gpio.PinOut.
+//
+// It is not supported.
+func (p *Pin) PWM(duty gpio.Duty, f physic.Frequency) error {
+	return errors.New("pcf8574: PWM is not supported")
+}
+
+// SetPolarity inverts the input value of the line when inverted is true.
+//
+// The chip has no such feature so it is done in software.
+func (p *Pin) SetPolarity(inverted bool) error {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	p.d.polarity &^= p.mask()
+	if inverted {
+		p.d.polarity |= p.mask()
+	}
+	return nil
+}
+
+//
+
+func newDev(b i2c.Bus, opts *Opts, chip string, base uint16) (*Dev, error) {
+	if opts.HardwareAddress > 7 {
+		return nil, fmt.Errorf("pcf8574: invalid hardware address %d", opts.HardwareAddress)
+	}
+	d := &Dev{
+		c:    i2c.Dev{Bus: b, Addr: base | uint16(opts.HardwareAddress)},
+		name: chip + "_" + strconv.Itoa(int(opts.HardwareAddress)),
+		intr: opts.Interrupt,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	for i := range d.pins {
+		d.pins[i] = Pin{d: d, n: uint(i), name: d.name + "_P" + strconv.Itoa(i), edges: make(chan struct{}, 1)}
+	}
+	if err := d.c.Tx([]byte{0xFF}, nil); err != nil {
+		return nil, fmt.Errorf("pcf8574: %v", err)
+	}
+	d.latch = 0xFF
+	if d.intr != nil {
+		if err := d.intr.In(gpio.PullUp, gpio.FallingEdge); err != nil {
+			return nil, fmt.Errorf("pcf8574: %v", err)
+		}
+		// The interrupt on change of any input line keeps d.in up to date.
+		if _, err := d.read(); err != nil {
+			return nil, err
+		}
+	}
+	for i := range d.pins {
+		if err := gpioreg.Register(&d.pins[i]); err != nil {
+			for j := 0; j < i; j++ {
+				_ = gpioreg.Unregister(d.pins[j].name)
+			}
+			return nil, fmt.Errorf("pcf8574: %v", err)
+		}
+	}
+	if d.intr != nil {
+		go d.watch()
+	}
+	return d, nil
+}
+
+// write writes the latch if it differs from the cached value.
+//
+// d.mu must be held.
+func (d *Dev) write(latch byte) error {
+	if latch == d.latch {
+		return nil
+	}
+	if err := d.c.Tx([]byte{latch}, nil); err != nil {
+		return fmt.Errorf("pcf8574: %v", err)
+	}
+	d.latch = latch
+	return nil
+}
+
+// read reads the input value, with the polarity applied, and fans out the
+// edges it implies.
+//
+// d.mu must be held.
+func (d *Dev) read() (byte, error) {
+	var b [1]byte
+	if err := d.c.Tx(nil, b[:]); err != nil {
+		return 0, fmt.Errorf("pcf8574: %v", err)
+	}
+	changed := b[0] ^ d.in
+	d.in = b[0]
+	v := b[0] ^ d.polarity
+	for i := range d.pins {
+		p := &d.pins[i]
+		m := p.mask()
+		if changed&m == 0 || p.edge == gpio.NoEdge {
+			continue
+		}
+		if p.edge == gpio.BothEdges || (p.edge == gpio.RisingEdge) == (v&m != 0) {
+			select {
+			case p.edges <- struct{}{}:
+			default:
+			}
+		}
+	}
+	return v, nil
+}
+
+// watch reads the input value on each interrupt until Close() is called.
+func (d *Dev) watch() {
+	defer close(d.done)
+	for {
+		select {
+		case <-d.stop:
+			return
+		default:
+		}
+		// Use a timeout to notice Close().
+		if d.intr.WaitForEdge(100 * time.Millisecond) {
+			d.mu.Lock()
+			_, _ = d.read()
+			d.mu.Unlock()
+		}
+	}
+}
+
+func (p *Pin) mask() byte {
+	return 1 << p.n
+}
+
+var _ gpio.PinIO = &Pin{}
+var _ pin.PinFunc = &Pin{}
@@ -0,0 +1,190 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pcf8574
+
+import (
+	"testing"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+)
+
+func TestPCF8574(t *testing.T) {
+	bus := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x20, W: []byte{0xFF}},
+			// P2 low.
+			{Addr: 0x20, W: []byte{0xFB}},
+			{Addr: 0x20, R: []byte{0xF3}},
+			{Addr: 0x20, R: []byte{0xF3}},
+			// P2 back to input.
+			{Addr: 0x20, W: []byte{0xFF}},
+		},
+	}
+	d, err := NewPCF8574(&bus, &DefaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := d.String(); s != "PCF8574_0{playback}" {
+		t.Fatal(s)
+	}
+	if _, err := d.Pin(8); err == nil {
+		t.Fatal("invalid line")
+	}
+	if gpioreg.ByName("PCF8574_0_P2") == nil {
+		t.Fatal("not registered")
+	}
+	p2, err := d.Pin(2)
+	if err != nil || p2.Number() != 2 {
+		t.Fatal(p2, err)
+	}
+	if f := p2.Function(); f != "IN" {
+		t.Fatal(f)
+	}
+	if err := p2.Out(gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	if f := p2.Function(); f != "Out/Low" {
+		t.Fatal(f)
+	}
+	// Unchanged latch.
+	if err := p2.Out(gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	if err := p2.PWM(gpio.DutyHalf, 0); err == nil {
+		t.Fatal("PWM is not supported")
+	}
+	p3, err := d.Pin(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p3.Pull() != gpio.PullUp || p3.DefaultPull() != gpio.PullUp {
+		t.Fatal("always pulled up")
+	}
+	if err := p3.In(gpio.PullDown, gpio.NoEdge); err == nil {
+		t.Fatal("pull down is not supported")
+	}
+	if err := p3.In(gpio.PullUp, gpio.BothEdges); err == nil {
+		t.Fatal("edges require Opts.Interrupt")
+	}
+	if err := p3.In(gpio.PullUp, gpio.NoEdge); err != nil {
+		t.Fatal(err)
+	}
+	if l := p3.Read(); l != gpio.Low {
+		t.Fatal(l)
+	}
+	if err := p3.SetPolarity(true); err != nil {
+		t.Fatal(err)
+	}
+	if l := p3.Read(); l != gpio.High {
+		t.Fatal(l)
+	}
+	if p3.WaitForEdge(0) {
+		t.Fatal("edges are not enabled")
+	}
+	if err := p2.SetFunc(gpio.IN); err != nil {
+		t.Fatal(err)
+	}
+	if err := p2.SetFunc(gpio.CLK); err == nil {
+		t.Fatal("CLK is not supported")
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gpioreg.ByName("PCF8574_0_P2") != nil {
+		t.Fatal("not unregistered")
+	}
+	if err := d.Close(); err == nil {
+		t.Fatal("already closed")
+	}
+	if err := p2.Out(gpio.High); err == nil {
+		t.Fatal("already closed")
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPCF8574A_edges(t *testing.T) {
+	intr := gpiotest.Pin{N: "INT", EdgesChan: make(chan gpio.Level, 1)}
+	bus := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x3F, W: []byte{0xFF}},
+			{Addr: 0x3F, R: []byte{0xFF}},
+			// In on P7.
+			{Addr: 0x3F, R: []byte{0xFF}},
+			// Interrupts.
+			{Addr: 0x3F, R: []byte{0x7F}},
+			{Addr: 0x3F, R: []byte{0xFF}},
+			{Addr: 0x3F, R: []byte{0x7F}},
+		},
+	}
+	d, err := NewPCF8574A(&bus, &Opts{HardwareAddress: 7, Interrupt: &intr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if intr.P != gpio.PullUp {
+		t.Fatal(intr.P)
+	}
+	p0, err := d.Pin(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p7, err := d.Pin(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := p7.String(); s != "PCF8574A_7_P7" {
+		t.Fatal(s)
+	}
+	if err := p7.In(gpio.PullUp, gpio.FallingEdge); err != nil {
+		t.Fatal(err)
+	}
+	intr.EdgesChan <- gpio.Low
+	if !p7.WaitForEdge(-1) {
+		t.Fatal("expected falling edge")
+	}
+	// The values are cached.
+	if p0.Read() != gpio.High || p7.Read() != gpio.Low {
+		t.Fatal("unexpected level")
+	}
+	// Rising edges are ignored.
+	intr.EdgesChan <- gpio.Low
+	intr.EdgesChan <- gpio.Low
+	if !p7.WaitForEdge(-1) {
+		t.Fatal("expected falling edge")
+	}
+	if len(p7.edges) != 0 {
+		t.Fatal("unexpected edge")
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNew_fail(t *testing.T) {
+	if _, err := NewPCF8574(&i2ctest.Playback{}, &Opts{HardwareAddress: 8}); err == nil {
+		t.Fatal("invalid address")
+	}
+	if _, err := NewPCF8574(&i2ctest.Playback{DontPanic: true}, &DefaultOpts); err == nil {
+		t.Fatal("I/O failure")
+	}
+	bus := i2ctest.Playback{Ops: []i2ctest.IO{{Addr: 0x21, W: []byte{0xFF}}}}
+	d, err := NewPCF8574(&bus, &Opts{HardwareAddress: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewPCF8574(&i2ctest.Playback{Ops: []i2ctest.IO{{Addr: 0x21, W: []byte{0xFF}}}}, &Opts{HardwareAddress: 1}); err == nil {
+		t.Fatal("already registered")
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
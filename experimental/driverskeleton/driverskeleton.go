@@ -85,6 +85,14 @@ func (d *driver) Init() (bool, error) {
 	return true, errors.New("driverskeleton: not implemented")
 }
 
+// Close implements periph.DriverCloser.
+func (d *driver) Close() error {
+	// FIXME: Release what Init() acquired: memory mappings, open handles, and
+	// the entries registered in gpioreg, i2creg, spireg, pinreg, etc. Delete
+	// this function if Init() doesn't acquire anything.
+	return nil
+}
+
 func init() {
 	// Since isArm is a compile time constant, the compile can strip the
 	// unnecessary code and unused private symbols.
@@ -21,6 +21,13 @@
 //
 // - 4x PRUs on 66ak2g02; http://www.ti.com/product/66ak2g02
 //
+// Only the AM335x found on the BeagleBone is supported. The PRU-ICSS must be
+// enabled in the device tree. Use PRU0 or PRU1 to load a binary program with
+// Load(), start it with Run() and stop it with Halt(). The host and the
+// program exchange messages via Send() and Recv(), which use mailboxes in the
+// PRU shared RAM. This is the building block to offload deterministic bit
+// banging, e.g. a gpiostream.PinOut implementation for NeoPixels.
+//
 // Datasheet
 //
 // Technical Reference Manual starting at page 199:
@@ -5,23 +5,411 @@
 package pru
 
 import (
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
 
 	"periph.io/x/periph"
+	"periph.io/x/periph/host/am335x"
+	"periph.io/x/periph/host/distro"
+	"periph.io/x/periph/host/pmem"
 )
 
 // Present returns true if an Texas Instrument PRU-ICSS processor is detected.
 //
-// TODO(maruel): Implement.
+// The PRU-ICSS must be enabled in the device tree, otherwise its clock is
+// gated and accessing its memory would fault.
 func Present() bool {
 	if isArm {
-		return false
+		if !am335x.Present() {
+			return false
+		}
+		dt, err := distro.DT()
+		if err != nil {
+			return false
+		}
+		for _, c := range pruCompatible {
+			for _, n := range dt.Compatible(c) {
+				if n.Enabled() {
+					return true
+				}
+			}
+		}
 	}
 	return false
 }
 
+// State is the state of a PRU core.
+type State int
+
+// Valid State values.
+const (
+	// Empty means that no program was loaded via Load().
+	Empty State = iota
+	// Halted means that the program is loaded and the core is stopped, either
+	// because Halt() was called or because the program executed HALT.
+	Halted
+	// Running means that the core is executing its program.
+	Running
+)
+
+const stateName = "EmptyHaltedRunning"
+
+var stateIndex = [...]uint8{0, 5, 11, 18}
+
+func (i State) String() string {
+	if i < 0 || i >= State(len(stateIndex)-1) {
+		return "State(" + strconv.Itoa(int(i)) + ")"
+	}
+	return stateName[stateIndex[i]:stateIndex[i+1]]
+}
+
+// Core is one of the PRU cores of the PRU-ICSS.
+//
+// The program is loaded in the 8Kb instruction RAM of the core and its
+// initial data in the 8Kb data RAM of the core. Once running, the program
+// exchanges data with the host via two mailboxes located in the shared RAM.
+// See Send() for the protocol.
+type Core struct {
+	mu     sync.Mutex
+	num    int
+	iram   uint32
+	dram   uint32
+	ctrl   uint32
+	mbox   uint32
+	loaded bool
+}
+
+// String returns "PRU0" or "PRU1".
+func (c *Core) String() string {
+	return "PRU" + strconv.Itoa(c.num)
+}
+
+// Number returns the core number.
+func (c *Core) Number() int {
+	return c.num
+}
+
+// Load halts the core and loads a program in its instruction RAM.
+//
+// The program is the raw binary image of PRU instructions, each 32 bits
+// little endian. It is not started; call Run() to start it.
+func (c *Core) Load(program []byte) error {
+	if len(program) == 0 || len(program)%4 != 0 {
+		return fmt.Errorf("pru: %s: program size must be a non-zero multiple of 4, got %d", c, len(program))
+	}
+	if len(program) > ramSize {
+		return fmt.Errorf("pru: %s: program is %d bytes, max is %d", c, len(program), ramSize)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, err := getMem()
+	if err != nil {
+		return err
+	}
+	if err := c.halt(m); err != nil {
+		return err
+	}
+	// Reset the core so the program counter restarts at 0.
+	m.write32(c.ctrl+ctlControl, 0)
+	writeWords(m, c.iram, program)
+	// Clear the mailboxes so stale messages from a previous program are not
+	// mistaken as new ones.
+	m.write32(c.mbox, 0)
+	m.write32(c.mbox+mboxSize, 0)
+	c.loaded = true
+	return nil
+}
+
+// LoadData writes the initial data of the program in the data RAM of the
+// core, starting at offset 0 as seen by the core.
+//
+// The core must not be running.
+func (c *Core) LoadData(data []byte) error {
+	if len(data)%4 != 0 {
+		return fmt.Errorf("pru: %s: data size must be a multiple of 4, got %d", c, len(data))
+	}
+	if len(data) > ramSize {
+		return fmt.Errorf("pru: %s: data is %d bytes, max is %d", c, len(data), ramSize)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, err := getMem()
+	if err != nil {
+		return err
+	}
+	if m.read32(c.ctrl+ctlControl)&ctlRunState != 0 {
+		return fmt.Errorf("pru: %s: cannot load data while running", c)
+	}
+	writeWords(m, c.dram, data)
+	return nil
+}
+
+// Run starts the program previously loaded via Load().
+//
+// pc is the index of the first instruction to execute, in 32 bits words.
+func (c *Core) Run(pc int) error {
+	if pc < 0 || pc >= ramSize/4 {
+		return fmt.Errorf("pru: %s: invalid program counter %d", c, pc)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, err := getMem()
+	if err != nil {
+		return err
+	}
+	if !c.loaded {
+		return fmt.Errorf("pru: %s: no program loaded", c)
+	}
+	if m.read32(c.ctrl+ctlControl)&ctlRunState != 0 {
+		return fmt.Errorf("pru: %s: already running", c)
+	}
+	// Holding the core in reset loads PCTR_RST_VAL in the program counter.
+	v := uint32(pc) << 16
+	m.write32(c.ctrl+ctlControl, v)
+	m.write32(c.ctrl+ctlControl, v|ctlSoftRstN|ctlEnable)
+	return nil
+}
+
+// Halt stops the core.
+//
+// The program stays loaded and can be restarted with Run().
+func (c *Core) Halt() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, err := getMem()
+	if err != nil {
+		return err
+	}
+	return c.halt(m)
+}
+
+// State returns the current state of the core.
+func (c *Core) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, err := getMem()
+	if err != nil || !c.loaded {
+		return Empty
+	}
+	if m.read32(c.ctrl+ctlControl)&ctlRunState != 0 {
+		return Running
+	}
+	return Halted
+}
+
+// PC returns the current program counter of the core, in 32 bits words.
+func (c *Core) PC() int {
+	m, err := getMem()
+	if err != nil {
+		return 0
+	}
+	return int(m.read32(c.ctrl+ctlStatus) & 0xFFFF)
+}
+
+// Send writes a message in the host to PRU mailbox of the core.
+//
+// Each core has two single-slot mailboxes in the PRU shared RAM, at offset
+// 0x1000*n for the host to PRU direction and 0x1000*n+0x800 for the PRU to
+// host direction, where n is the core number. The first 32 bits word of a
+// mailbox is the length in bytes of the message present, 0 meaning empty,
+// and the payload follows. The writer writes the payload then the length;
+// the reader reads the payload then clears the length.
+//
+// Send waits up to timeout for the PRU to consume the previous message. A
+// timeout of 0 means to not wait.
+func (c *Core) Send(b []byte, timeout time.Duration) error {
+	if len(b) == 0 || len(b) > MaxMessageSize {
+		return fmt.Errorf("pru: %s: message size must be between 1 and %d, got %d", c, MaxMessageSize, len(b))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, err := getMem()
+	if err != nil {
+		return err
+	}
+	if !poll(func() bool { return m.read32(c.mbox) == 0 }, timeout) {
+		return fmt.Errorf("pru: %s: mailbox full", c)
+	}
+	l := len(b) &^ 3
+	writeWords(m, c.mbox+4, b[:l])
+	if l != len(b) {
+		var tail [4]byte
+		copy(tail[:], b[l:])
+		writeWords(m, c.mbox+4+uint32(l), tail[:])
+	}
+	m.write32(c.mbox, uint32(len(b)))
+	return nil
+}
+
+// Recv reads a message from the PRU to host mailbox of the core into b and
+// returns its length.
+//
+// Recv waits up to timeout for a message. A timeout of 0 means to not wait.
+// It returns an error if no message is present or if the message is larger
+// than b; in the later case the message is not consumed.
+func (c *Core) Recv(b []byte, timeout time.Duration) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, err := getMem()
+	if err != nil {
+		return 0, err
+	}
+	box := c.mbox + mboxSize
+	l := 0
+	if !poll(func() bool { l = int(m.read32(box)); return l != 0 }, timeout) {
+		return 0, fmt.Errorf("pru: %s: no message", c)
+	}
+	if l > MaxMessageSize {
+		return 0, fmt.Errorf("pru: %s: corrupted message length %d", c, l)
+	}
+	if l > len(b) {
+		return 0, fmt.Errorf("pru: %s: buffer too small; need %d bytes, got %d", c, l, len(b))
+	}
+	for i := 0; i < l; i += 4 {
+		var w [4]byte
+		binary.LittleEndian.PutUint32(w[:], m.read32(box+4+uint32(i)))
+		copy(b[i:l], w[:])
+	}
+	m.write32(box, 0)
+	return l, nil
+}
+
+// MaxMessageSize is the largest message that can be exchanged via Send() and
+// Recv().
+const MaxMessageSize = mboxSize - 4
+
+// PRU0 and PRU1 are the two cores of the AM335x PRU-ICSS.
+var (
+	PRU0 = &Core{num: 0, iram: pru0IRAM, dram: pru0DRAM, ctrl: pru0Ctrl, mbox: sharedRAM}
+	PRU1 = &Core{num: 1, iram: pru1IRAM, dram: pru1DRAM, ctrl: pru1Ctrl, mbox: sharedRAM + 0x1000}
+)
+
+//
+
+// Offsets relative to the base of the PRU-ICSS. Page 206.
+const (
+	pruICSSBase = 0x4A300000
+	pruICSSSize = 0x3A000
+
+	pru0DRAM  = 0x00000
+	pru1DRAM  = 0x02000
+	sharedRAM = 0x10000 // 12Kb
+	pru0Ctrl  = 0x22000
+	pru1Ctrl  = 0x24000
+	pruCfg    = 0x26000
+	pru0IRAM  = 0x34000
+	pru1IRAM  = 0x38000
+
+	ramSize  = 0x2000
+	mboxSize = 0x800
+)
+
+// PRU control registers. Page 1936.
+const (
+	ctlControl = 0x00
+	ctlStatus  = 0x04
+
+	ctlSoftRstN uint32 = 1 << 0 // 0 resets the core
+	ctlEnable   uint32 = 1 << 1
+	ctlSleeping uint32 = 1 << 2
+	ctlRunState uint32 = 1 << 15 // Read only
+)
+
+// PRU-ICSS CFG registers. Page 272.
+const (
+	cfgRevID  = 0x00
+	cfgSysCfg = 0x04
+
+	sysCfgStandbyInit uint32 = 1 << 4 // Must be cleared to access the L3
+)
+
+// pruCompatible are the device tree compatible strings of the PRU-ICSS node.
+var pruCompatible = []string{"ti,am3356-pruss", "ti,pruss-v2"}
+
+// memory is the access to the PRU-ICSS address space.
+//
+// The offsets are in bytes relative to pruICSSBase and must be 32 bits
+// aligned. It is an interface so the loader can be tested against fake
+// memory.
+type memory interface {
+	read32(off uint32) uint32
+	write32(off, v uint32)
+}
+
+// wordMem implements memory on top of a memory mapped view.
+type wordMem []uint32
+
+func (w wordMem) read32(off uint32) uint32 {
+	return w[off/4]
+}
+
+func (w wordMem) write32(off, v uint32) {
+	w[off/4] = v
+}
+
+// halt clears the enable bit and waits for the core to stop.
+func (c *Core) halt(m memory) error {
+	v := m.read32(c.ctrl + ctlControl)
+	if v&ctlRunState == 0 && v&ctlEnable == 0 {
+		return nil
+	}
+	m.write32(c.ctrl+ctlControl, v&^ctlEnable)
+	// The core stops at the end of the current instruction, which takes a few
+	// nanoseconds, so this should not loop much.
+	for i := 0; i < 1000; i++ {
+		if m.read32(c.ctrl+ctlControl)&ctlRunState == 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("pru: %s: failed to halt", c)
+}
+
+// writeWords copies b, which length must be a multiple of 4, at off as
+// little endian 32 bits words.
+func writeWords(m memory, off uint32, b []byte) {
+	for i := 0; i < len(b); i += 4 {
+		m.write32(off+uint32(i), binary.LittleEndian.Uint32(b[i:]))
+	}
+}
+
+// poll calls f until it returns true or timeout expires.
+func poll(f func() bool, timeout time.Duration) bool {
+	if f() {
+		return true
+	}
+	for end := time.Now().Add(timeout); time.Now().Before(end); {
+		time.Sleep(pollPeriod)
+		if f() {
+			return true
+		}
+	}
+	return false
+}
+
+// pollPeriod is the interval at which the mailboxes are checked.
+const pollPeriod = 100 * time.Microsecond
+
+// getMem returns the PRU-ICSS memory, or an error if the driver is not
+// initialized.
+func getMem() (memory, error) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	if drv.mem == nil {
+		return nil, errors.New("pru: driver is not initialized")
+	}
+	return drv.mem, nil
+}
+
 // driver implements periph.Driver.
 type driver struct {
+	mu   sync.Mutex
+	mem  memory
+	view *pmem.View
 }
 
 func (d *driver) String() string {
@@ -33,16 +421,69 @@ func (d *driver) Prerequisites() []string {
 }
 
 func (d *driver) After() []string {
-	return nil
+	return []string{"am335x"}
 }
 
 func (d *driver) Init() (bool, error) {
 	if !Present() {
 		return false, errors.New("real time TI's PRU side-CPU not detected")
 	}
+	v, err := pmem.Map(pruICSSBase, pruICSSSize)
+	if err != nil {
+		if os.IsPermission(err) {
+			return true, fmt.Errorf("need more access, try as root: %v", err)
+		}
+		return true, err
+	}
+	m := wordMem(v.Uint32())
+	if err := d.setup(m); err != nil {
+		_ = v.Close()
+		return true, err
+	}
+	d.view = v
 	return true, nil
 }
 
+// Close halts the cores and releases the memory mapping.
+func (d *driver) Close() error {
+	// Wait for the pending operations on the cores.
+	for _, c := range []*Core{PRU0, PRU1} {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.mem == nil {
+		return errors.New("pru: already closed")
+	}
+	var err error
+	for _, c := range []*Core{PRU0, PRU1} {
+		if err2 := c.halt(d.mem); err2 != nil && err == nil {
+			err = err2
+		}
+	}
+	d.mem = nil
+	if d.view != nil {
+		if err2 := d.view.Close(); err2 != nil && err == nil {
+			err = err2
+		}
+		d.view = nil
+	}
+	return err
+}
+
+// setup validates the PRU-ICSS and enables its access to the system memory.
+func (d *driver) setup(m memory) error {
+	if m.read32(pruCfg+cfgRevID) == 0 {
+		return errors.New("pru: unexpected PRU-ICSS revision; is the pruss module enabled?")
+	}
+	m.write32(pruCfg+cfgSysCfg, m.read32(pruCfg+cfgSysCfg)&^sysCfgStandbyInit)
+	d.mu.Lock()
+	d.mem = m
+	d.mu.Unlock()
+	return nil
+}
+
 func init() {
 	if isArm {
 		periph.MustRegister(&drv)
@@ -50,3 +491,5 @@ func init() {
 }
 
 var drv driver
+
+var _ periph.DriverCloser = &drv
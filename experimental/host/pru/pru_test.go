@@ -0,0 +1,309 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pru
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestCore_not_initialized(t *testing.T) {
+	defer reset()
+	if PRU0.Load([]byte{1, 2, 3, 4}) == nil {
+		t.Fatal("driver is not initialized")
+	}
+	if PRU0.Halt() == nil {
+		t.Fatal("driver is not initialized")
+	}
+	if s := PRU0.State(); s != Empty {
+		t.Fatal(s)
+	}
+	if PRU0.PC() != 0 {
+		t.Fatal("unexpected PC")
+	}
+}
+
+func TestCore_Load_Run_Halt(t *testing.T) {
+	defer reset()
+	m := setupFake(t)
+	if s := PRU1.State(); s != Empty {
+		t.Fatal(s)
+	}
+	if PRU1.Run(0) == nil {
+		t.Fatal("no program loaded")
+	}
+	if err := PRU1.Load([]byte{1, 2, 3, 4, 5, 6, 7, 8}); err != nil {
+		t.Fatal(err)
+	}
+	if m.read32(pru1IRAM) != 0x04030201 || m.read32(pru1IRAM+4) != 0x08070605 {
+		t.Fatal("program not loaded in PRU1 IRAM")
+	}
+	if m.read32(pru0IRAM) != 0 {
+		t.Fatal("PRU0 IRAM must not be touched")
+	}
+	if err := PRU1.LoadData([]byte{0xAA, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if m.read32(pru1DRAM) != 0xAA {
+		t.Fatal("data not loaded in PRU1 DRAM")
+	}
+	if s := PRU1.State(); s != Halted {
+		t.Fatal(s)
+	}
+	if err := PRU1.Run(2); err != nil {
+		t.Fatal(err)
+	}
+	if s := PRU1.State(); s != Running {
+		t.Fatal(s)
+	}
+	if v := m.read32(pru1Ctrl + ctlControl); v != 2<<16|ctlRunState|ctlSoftRstN|ctlEnable {
+		t.Fatalf("0x%x", v)
+	}
+	if PRU1.PC() != 2 {
+		t.Fatal(PRU1.PC())
+	}
+	if PRU1.Run(0) == nil {
+		t.Fatal("already running")
+	}
+	if PRU1.LoadData([]byte{0, 0, 0, 0}) == nil {
+		t.Fatal("running")
+	}
+	if err := PRU1.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if s := PRU1.State(); s != Halted {
+		t.Fatal(s)
+	}
+	// Loading while running halts first.
+	if err := PRU1.Run(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := PRU1.Load([]byte{9, 9, 9, 9}); err != nil {
+		t.Fatal(err)
+	}
+	if s := PRU1.State(); s != Halted {
+		t.Fatal(s)
+	}
+	if s := PRU0.State(); s != Empty {
+		t.Fatal(s)
+	}
+}
+
+func TestCore_Halt_stuck(t *testing.T) {
+	defer reset()
+	m := setupFake(t)
+	if err := PRU0.Load([]byte{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := PRU0.Run(0); err != nil {
+		t.Fatal(err)
+	}
+	m.stuck = true
+	if PRU0.Halt() == nil {
+		t.Fatal("core doesn't stop")
+	}
+}
+
+func TestCore_errors(t *testing.T) {
+	defer reset()
+	setupFake(t)
+	if PRU0.Load(nil) == nil {
+		t.Fatal("empty program")
+	}
+	if PRU0.Load([]byte{1, 2, 3}) == nil {
+		t.Fatal("not a multiple of 4")
+	}
+	if PRU0.Load(make([]byte, ramSize+4)) == nil {
+		t.Fatal("too large")
+	}
+	if PRU0.LoadData([]byte{1}) == nil {
+		t.Fatal("not a multiple of 4")
+	}
+	if PRU0.LoadData(make([]byte, ramSize+4)) == nil {
+		t.Fatal("too large")
+	}
+	if PRU0.Run(-1) == nil || PRU0.Run(ramSize/4) == nil {
+		t.Fatal("invalid pc")
+	}
+	if PRU0.Send(nil, 0) == nil || PRU0.Send(make([]byte, MaxMessageSize+1), 0) == nil {
+		t.Fatal("invalid message size")
+	}
+}
+
+func TestCore_Send(t *testing.T) {
+	defer reset()
+	m := setupFake(t)
+	if err := PRU0.Send([]byte("hello"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if l := m.read32(sharedRAM); l != 5 {
+		t.Fatal(l)
+	}
+	if m.read32(sharedRAM+4) != 0x6c6c6568 || m.read32(sharedRAM+8) != 0x6f {
+		t.Fatal("unexpected payload")
+	}
+	if PRU0.Send([]byte("again"), time.Millisecond) == nil {
+		t.Fatal("mailbox full")
+	}
+	// PRU1 uses its own mailbox.
+	if err := PRU1.Send([]byte{1}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if l := m.read32(sharedRAM + 0x1000); l != 1 {
+		t.Fatal(l)
+	}
+	// Emulate the PRU consuming the message.
+	m.write32(sharedRAM, 0)
+	if err := PRU0.Send([]byte("again"), 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCore_Recv(t *testing.T) {
+	defer reset()
+	m := setupFake(t)
+	var b [8]byte
+	if _, err := PRU0.Recv(b[:], time.Millisecond); err == nil {
+		t.Fatal("no message")
+	}
+	// Emulate the PRU sending a message.
+	box := uint32(sharedRAM + mboxSize)
+	m.write32(box+4, 0x04030201)
+	m.write32(box+8, 0x0605)
+	m.write32(box, 6)
+	if _, err := PRU0.Recv(b[:4], 0); err == nil {
+		t.Fatal("buffer too small")
+	}
+	n, err := PRU0.Recv(b[:], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b[:n], []byte{1, 2, 3, 4, 5, 6}) {
+		t.Fatal(b[:n])
+	}
+	if m.read32(box) != 0 {
+		t.Fatal("message must have been consumed")
+	}
+	m.write32(box, MaxMessageSize+1)
+	if _, err := PRU0.Recv(b[:], 0); err == nil {
+		t.Fatal("corrupted length")
+	}
+}
+
+func TestDriver(t *testing.T) {
+	defer reset()
+	d := driver{}
+	if d.String() != "pru" || d.Prerequisites() != nil || len(d.After()) != 1 {
+		t.Fatal("unexpected driver")
+	}
+	if ok, err := d.Init(); ok || err == nil {
+		t.Fatal("PRU-ICSS is not present")
+	}
+	m := &fakeMem{}
+	if d.setup(m) == nil {
+		t.Fatal("invalid revision")
+	}
+	m.write32(pruCfg+cfgRevID, 0x47000000)
+	m.write32(pruCfg+cfgSysCfg, sysCfgStandbyInit|1)
+	if err := d.setup(m); err != nil {
+		t.Fatal(err)
+	}
+	if v := m.read32(pruCfg + cfgSysCfg); v != 1 {
+		t.Fatal(v)
+	}
+}
+
+func TestDriver_Close(t *testing.T) {
+	defer reset()
+	m := setupFake(t)
+	for _, c := range []*Core{PRU0, PRU1} {
+		if err := c.Load([]byte{1, 2, 3, 4}); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Run(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := drv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, off := range []uint32{pru0Ctrl, pru1Ctrl} {
+		if m.read32(off+ctlControl)&ctlRunState != 0 {
+			t.Fatal("cores must be halted")
+		}
+	}
+	if PRU0.Run(0) == nil {
+		t.Fatal("driver is closed")
+	}
+	if drv.Close() == nil {
+		t.Fatal("already closed")
+	}
+
+	// A core that doesn't stop is reported.
+	m = setupFake(t)
+	if err := PRU1.Run(0); err != nil {
+		t.Fatal(err)
+	}
+	m.stuck = true
+	if drv.Close() == nil {
+		t.Fatal("core doesn't stop")
+	}
+	if _, err := getMem(); err == nil {
+		t.Fatal("memory must be released anyway")
+	}
+}
+
+func TestState_String(t *testing.T) {
+	if s := Running.String(); s != "Running" {
+		t.Fatal(s)
+	}
+	if s := State(10).String(); s != "State(10)" {
+		t.Fatal(s)
+	}
+}
+
+//
+
+// fakeMem emulates the PRU-ICSS memory, including the RUNSTATE bit of the
+// control registers.
+type fakeMem struct {
+	w     [pruICSSSize / 4]uint32
+	stuck bool
+}
+
+func (f *fakeMem) read32(off uint32) uint32 {
+	return f.w[off/4]
+}
+
+func (f *fakeMem) write32(off, v uint32) {
+	if off == pru0Ctrl+ctlControl || off == pru1Ctrl+ctlControl {
+		// The core runs when enabled and out of reset.
+		v &^= ctlRunState
+		if v&(ctlSoftRstN|ctlEnable) == ctlSoftRstN|ctlEnable || (f.stuck && f.w[off/4]&ctlRunState != 0) {
+			v |= ctlRunState
+		}
+		// STATUS reflects the program counter.
+		f.w[(off+ctlStatus)/4] = v >> 16
+	}
+	f.w[off/4] = v
+}
+
+func setupFake(t *testing.T) *fakeMem {
+	m := &fakeMem{}
+	m.write32(pruCfg+cfgRevID, 0x47000000)
+	if err := drv.setup(m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func reset() {
+	drv.mu.Lock()
+	drv.mem = nil
+	drv.mu.Unlock()
+	PRU0.loaded = false
+	PRU1.loaded = false
+}
@@ -1,9 +0,0 @@
-// Copyright 2018 The Periph Authors. All rights reserved.
-// Use of this source code is governed under the Apache License, Version 2.0
-// that can be found in the LICENSE file.
-
-// +build !windows
-
-package serial
-
-const isWindows = false
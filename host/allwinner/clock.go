@@ -100,10 +100,12 @@ type clockPLL6R8Ctl uint32
 
 // clockMap is the mapping of important registers across CPUs.
 type clockMap struct {
-	reserved0 [0xA0 / 4]uint32 //
-	spi0Clk   clockSPI         // 0x0A0 SPI0_SCLK_CFG_REG SPI0 Clock
-	spi1Clk   clockSPI         // 0x0A4 SPI1_SCLK_CFG_REG SPI1 Clock
-	spi2Clk   clockSPI         // 0x0A8 SPI2_SCLK_CFG_REG SPI2 Clock (Not on A64)
+	reserved0   [0x60 / 4]uint32           //
+	ahbGating0  uint32                     // 0x060 AHB_GATING_REG0 / BUS_CLK_GATING_REG0; SPIn is bit 20+n
+	reserved1   [(0xA0 - 0x64) / 4]uint32  //
+	spiClk      [3]clockSPI                // 0x0A0 SPIn_SCLK_CFG_REG SPIn Clock (SPI2 not on A64)
+	reserved2   [(0x2C0 - 0xAC) / 4]uint32 //
+	busSoftRst0 uint32                     // 0x2C0 BUS_SOFT_RST_REG0 (A64 only); SPIn is bit 20+n
 }
 
 // R8: Page 57-59.
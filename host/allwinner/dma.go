@@ -53,6 +53,19 @@ func (d *dmaMap) getDedicated() int {
 	return -1
 }
 
+// startDedicated starts a transfer on an available dedicated DMA channel.
+func (d *dmaMap) startDedicated(srcAddr, dstAddr, l uint32, srcIO, dstIO bool, cfg ddmaR8Cfg) (*dmaDedicatedGroup, error) {
+	n := d.getDedicated()
+	if n == -1 {
+		return nil, errors.New("no channel available")
+	}
+	d.irqEn &^= 3 << uint(2*n+16)
+	d.irqPendStas = 3 << uint(2*n+16)
+	ch := &d.dedicated[n]
+	ch.set(srcAddr, dstAddr, l, srcIO, dstIO, cfg)
+	return ch, nil
+}
+
 // dmaNormalGroup is the control registers for the first block of 8 DMA
 // controllers.
 //
@@ -343,17 +356,13 @@ func smokeTest() error {
 	}
 
 	copyMem := func(pDst, pSrc uint64) error {
-		n := drvDMA.dmaMemory.getDedicated()
-		if n == -1 {
-			return errors.New("no channel available")
+		ch, err := drvDMA.dmaMemory.startDedicated(uint32(pSrc), uint32(pDst)+holeSize, 4096-2*holeSize, false, false, ddmaDstDrqSDRAM|ddmaSrcDrqSDRAM)
+		if err != nil {
+			return err
 		}
-		drvDMA.dmaMemory.irqEn &^= 3 << uint(2*n+16)
-		drvDMA.dmaMemory.irqPendStas = 3 << uint(2*n+16)
-		ch := &drvDMA.dmaMemory.dedicated[n]
 		defer func() {
 			_ = ch.release()
 		}()
-		ch.set(uint32(pSrc), uint32(pDst)+holeSize, 4096-2*holeSize, false, false, ddmaDstDrqSDRAM|ddmaSrcDrqSDRAM)
 
 		for ch.cfg&ddmaBusy != 0 {
 		}
@@ -365,19 +374,32 @@ func smokeTest() error {
 
 // driverDMA implements periph.Driver.
 //
-// It implements much more than the DMA controller, it also exposes the clocks,
-// the PWM and PCM controllers.
+// It implements much more than the DMA controller, it also exposes the timer.
+// The PWM and SPI controllers are handled by allwinner-pwm and allwinner-spi.
 type driverDMA struct {
 	// dmaMemory is the memory map of the CPU DMA registers.
 	dmaMemory *dmaMap
-	// pwmMemory is the memory map of the CPU PWM registers.
-	pwmMemory *pwmMap
-	// spiMemory is the memory mapping for the spi CPU registers.
-	spiMemory *spiMap
-	// clockMemory is the memory mapping for the clock CPU registers.
-	clockMemory *clockMap
 	// timerMemory is the memory mapping for the timer CPU registers.
 	timerMemory *timerMap
+	// dmaBufAllocator is overriden for unit testing.
+	dmaBufAllocator func(s int) (pmem.Mem, error) // Set to pmem.Alloc
+	// views are the memory mappings done by Init().
+	views []*pmem.View
+}
+
+// Close releases the memory mappings.
+func (d *driverDMA) Close() error {
+	var err error
+	for _, v := range d.views {
+		if err2 := v.Close(); err2 != nil && err == nil {
+			err = err2
+		}
+	}
+	d.views = nil
+	d.dmaMemory = nil
+	d.dmaBufAllocator = nil
+	d.timerMemory = nil
+	return err
 }
 
 func (d *driverDMA) String() string {
@@ -395,72 +417,51 @@ func (d *driverDMA) After() []string {
 func (d *driverDMA) Init() (bool, error) {
 	// dmaBaseAddr is the physical base address of the DMA registers.
 	var dmaBaseAddr uint32
-	// pwmBaseAddr is the physical base address of the PWM registers.
-	var pwmBaseAddr uint32
-	// spiBaseAddr is the physical base address of the clock registers.
-	var spiBaseAddr uint32
-	// clockBaseAddr is the physical base address of the clock registers.
-	var clockBaseAddr uint32
 	// timerBaseAddr is the physical base address of the timer registers.
 	var timerBaseAddr uint32
 	if IsA64() {
 		// Page 198.
 		dmaBaseAddr = 0x1C02000
-		// Page 194.
-		pwmBaseAddr = 0x1C21400
 		// Page 161.
 		timerBaseAddr = 0x1C20C00
-		// Page 81.
-		clockBaseAddr = 0x1C20000
-		// Page Page 545.
-		spiBaseAddr = 0x01C68000
 	} else if IsR8() {
 		// Page 124.
 		dmaBaseAddr = 0x1C02000
-		// Page 83.
-		pwmBaseAddr = 0x1C20C00 + 0x200
 		// Page 85.
 		timerBaseAddr = 0x1C20C00
-		// Page 57.
-		clockBaseAddr = 0x1C20000
-		// Page 151.
-		spiBaseAddr = 0x01C05000
 	} else {
 		// H3
 		// Page 194.
 		//dmaBaseAddr = 0x1C02000
-		// Page 187.
-		//pwmBaseAddr = 0x1C21400
 		// Page 154.
 		//timerBaseAddr = 0x1C20C00
 		return false, errors.New("unsupported CPU architecture")
 	}
 
-	if err := pmem.MapAsPOD(uint64(dmaBaseAddr), &d.dmaMemory); err != nil {
+	if err := d.mapAsPOD(uint64(dmaBaseAddr), &d.dmaMemory); err != nil {
 		if os.IsPermission(err) {
 			return true, fmt.Errorf("need more access, try as root: %v", err)
 		}
 		return true, err
 	}
-
-	if err := pmem.MapAsPOD(uint64(pwmBaseAddr), &d.pwmMemory); err != nil {
-		return true, err
-	}
-	if err := pmem.MapAsPOD(uint64(timerBaseAddr), &d.timerMemory); err != nil {
-		return true, err
-	}
-	if err := pmem.MapAsPOD(uint64(clockBaseAddr), &d.clockMemory); err != nil {
+	if err := d.mapAsPOD(uint64(timerBaseAddr), &d.timerMemory); err != nil {
 		return true, err
 	}
-	if err := pmem.MapAsPOD(uint64(spiBaseAddr), &d.spiMemory); err != nil {
-		return true, err
+	d.dmaBufAllocator = func(s int) (pmem.Mem, error) {
+		return pmem.Alloc(s)
 	}
 
 	return true, smokeTest()
 }
 
-func (d *driverDMA) Close() error {
-	// Stop DMA and PWM controllers.
+// mapAsPOD maps the memory at base as i and keeps track of the mapping to
+// release it in Close().
+func (d *driverDMA) mapAsPOD(base uint64, i interface{}) error {
+	v, err := pmem.MapAsPODView(base, i)
+	if err != nil {
+		return err
+	}
+	d.views = append(d.views, v)
 	return nil
 }
 
@@ -8,6 +8,9 @@
 // This driver implements memory-mapped GPIO pin manipulation and leverages
 // sysfs-gpio for edge detection.
 //
+// It also drives the PWM controller via Pin.PWM() and, on the R8, registers
+// memory-mapped SPI ports in spireg as "allwinner-spiN".
+//
 // If you are looking at the actual implementation, open doc.go for further
 // implementation details.
 //
@@ -61,6 +61,7 @@ type Pin struct {
 
 	// Mutable.
 	usingEdge bool // Set when edge detection is enabled.
+	usingPWM  bool // Set when the PWM controller drives the pin.
 }
 
 // String implements conn.Resource.
@@ -72,7 +73,7 @@ func (p *Pin) String() string {
 
 // Halt implements conn.Resource.
 //
-// It stops edge detection if enabled.
+// It stops edge detection and PWM if enabled.
 func (p *Pin) Halt() error {
 	if p.usingEdge {
 		if err := p.sysfsPin.Halt(); err != nil {
@@ -80,6 +81,12 @@ func (p *Pin) Halt() error {
 		}
 		p.usingEdge = false
 	}
+	if p.usingPWM {
+		if drvPWM.pwmMemory != nil {
+			drvPWM.halt()
+		}
+		p.usingPWM = false
+	}
 	return nil
 }
 
@@ -414,8 +421,51 @@ func (p *Pin) FastOut(l gpio.Level) {
 }
 
 // PWM implements gpio.PinOut.
-func (p *Pin) PWM(gpio.Duty, physic.Frequency) error {
-	return p.wrap(errors.New("not available on this CPU architecture"))
+//
+// It is supported on the pins with the PWM0 function when allwinner-pwm is
+// loaded. The base clock is 24MHz, so the frequency must be at most 12MHz; the
+// resolution is at best 1/65536.
+//
+// Otherwise it falls back to the kernel's sysfs PWM driver, if configured for
+// this pin; see sysfs.PWMByPin().
+func (p *Pin) PWM(d gpio.Duty, f physic.Frequency) error {
+	if drvGPIO.gpioMemory == nil || drvPWM.pwmMemory == nil {
+		if pw := sysfs.PWMByPin(p.name); pw != nil {
+			return pw.PWM(d, f)
+		}
+		return p.wrap(errors.New("subsystem allwinner-pwm not initialized; try running as root?"))
+	}
+	alt := -1
+	for i, m := range p.altFunc {
+		if m == "PWM0" {
+			alt = i
+		}
+	}
+	if alt == -1 {
+		return p.wrap(errors.New("PWM is not supported on this pin"))
+	}
+	if d < 0 || d > gpio.DutyMax {
+		return p.wrap(fmt.Errorf("invalid duty %s", d))
+	}
+	scaler, total, ok := getBestPrescale(f)
+	if !ok {
+		return p.wrap(fmt.Errorf("frequency must be between %s and %s", pwmMinFreq, pwmMaxFreq))
+	}
+	if d == 0 {
+		return p.Out(gpio.Low)
+	} else if d == gpio.DutyMax {
+		return p.Out(gpio.High)
+	}
+	active := (uint64(total)*uint64(d) + uint64(gpio.DutyHalf)) / uint64(gpio.DutyMax)
+	if active > 0xFFFF {
+		active = 0xFFFF
+	}
+	if err := drvPWM.set(scaler, toPeriod(total, uint16(active))); err != nil {
+		return p.wrap(err)
+	}
+	p.usingPWM = true
+	p.setFunction(alt1 + function(alt))
+	return nil
 }
 
 //
@@ -761,6 +811,7 @@ func initPins() error {
 				if err := gpioreg.RegisterAlias(string(f), name); err != nil {
 					return err
 				}
+				drvGPIO.aliases = append(drvGPIO.aliases, string(f))
 			}
 		}
 	}
@@ -776,6 +827,7 @@ func initPins() error {
 					if err := gpioreg.RegisterAlias(string(f), name); err != nil {
 						return err
 					}
+					drvGPIO.aliases = append(drvGPIO.aliases, string(f))
 				}
 			}
 		}
@@ -816,6 +868,38 @@ type gpioMap struct {
 type driverGPIO struct {
 	// gpioMemory is the memory map of the CPU GPIO registers.
 	gpioMemory *gpioMap
+	// gpioView is the mapping of gpioMemory.
+	gpioView *pmem.View
+	// aliases are the function aliases registered by Init().
+	aliases []string
+}
+
+// Close unregisters the pins and their aliases and releases the memory
+// mapping.
+//
+// It doesn't restore the pins registered by sysfs-gpio.
+func (d *driverGPIO) Close() error {
+	for name, p := range cpupins {
+		// Only unregister the pin if it was registered by this driver.
+		if gpioreg.ByName(name) == gpio.PinIO(p) {
+			num := strconv.Itoa(p.Number())
+			_ = gpioreg.Unregister(name)
+			_ = gpioreg.Unregister("GPIO" + num)
+			_ = gpioreg.Unregister(num)
+		}
+		p.sysfsPin = nil
+	}
+	for _, a := range d.aliases {
+		_ = gpioreg.Unregister(a)
+	}
+	d.aliases = nil
+	var err error
+	if d.gpioView != nil {
+		err = d.gpioView.Close()
+		d.gpioView = nil
+	}
+	d.gpioMemory = nil
+	return err
 }
 
 func (d *driverGPIO) String() string {
@@ -855,12 +939,14 @@ func (d *driverGPIO) Init() (bool, error) {
 
 	// gpioBaseAddr is the physical base address of the GPIO registers.
 	gpioBaseAddr := uint32(getBaseAddress())
-	if err := pmem.MapAsPOD(uint64(gpioBaseAddr), &d.gpioMemory); err != nil {
+	m, err := pmem.MapAsPODView(uint64(gpioBaseAddr), &d.gpioMemory)
+	if err != nil {
 		if os.IsPermission(err) {
 			return true, fmt.Errorf("need more access, try as root: %v", err)
 		}
 		return true, err
 	}
+	d.gpioView = m
 
 	return true, initPins()
 }
@@ -461,6 +461,36 @@ type driverGPIOPL struct {
 	// gpioMemoryPL is only the PL group in that case. Note that groups PI, PJ, PK
 	// do not exist.
 	gpioMemoryPL *gpioGroup
+	// gpioView is the mapping of gpioMemoryPL.
+	gpioView *pmem.View
+	// aliases are the function aliases registered by Init().
+	aliases []string
+}
+
+// Close unregisters the pins and their aliases and releases the memory
+// mapping.
+func (d *driverGPIOPL) Close() error {
+	for i := range cpuPinsPL {
+		// Only unregister the pin if it was registered by this driver.
+		if gpioreg.ByName(cpuPinsPL[i].name) == gpio.PinIO(&cpuPinsPL[i]) {
+			num := strconv.Itoa(cpuPinsPL[i].Number())
+			_ = gpioreg.Unregister(cpuPinsPL[i].name)
+			_ = gpioreg.Unregister("GPIO" + num)
+			_ = gpioreg.Unregister(num)
+		}
+		cpuPinsPL[i].sysfsPin = nil
+	}
+	for _, a := range d.aliases {
+		_ = gpioreg.Unregister(a)
+	}
+	d.aliases = nil
+	var err error
+	if d.gpioView != nil {
+		err = d.gpioView.Close()
+		d.gpioView = nil
+	}
+	d.gpioMemoryPL = nil
+	return err
 }
 
 func (d *driverGPIOPL) String() string {
@@ -514,7 +544,9 @@ func (d *driverGPIOPL) Init() (bool, error) {
 				// TODO(maruel): We'd have to clear out the ones from allwinner-gpio
 				// too.
 				functions[f] = struct{}{}
-				_ = gpioreg.RegisterAlias(string(f), name)
+				if gpioreg.RegisterAlias(string(f), name) == nil {
+					d.aliases = append(d.aliases, string(f))
+				}
 			}
 		}
 	}
@@ -529,7 +561,9 @@ func (d *driverGPIOPL) Init() (bool, error) {
 					// TODO(maruel): We'd have to clear out the ones from allwinner-gpio
 					// too.
 					functions[f] = struct{}{}
-					_ = gpioreg.RegisterAlias(string(f), cpuPinsPL[i].name)
+					if gpioreg.RegisterAlias(string(f), cpuPinsPL[i].name) == nil {
+						d.aliases = append(d.aliases, string(f))
+					}
 				}
 			}
 		}
@@ -542,6 +576,7 @@ func (d *driverGPIOPL) Init() (bool, error) {
 		}
 		return true, err
 	}
+	d.gpioView = m
 	if err := m.AsPOD(&d.gpioMemoryPL); err != nil {
 		return true, err
 	}
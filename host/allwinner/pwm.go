@@ -5,14 +5,24 @@
 package allwinner
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"strings"
-	"time"
+
+	"periph.io/x/periph"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/host/pmem"
 )
 
 const pwmClock = 24000000
 const pwmMaxPeriod = 0x10000
 
+const (
+	pwmMaxFreq = pwmClock * physic.Hertz / 2
+	pwmMinFreq = pwmClock * physic.Hertz / 72000 / pwmMaxPeriod
+)
+
 // prescalers is the value for pwm0Prescale*
 var prescalers = []struct {
 	freq   uint32
@@ -165,21 +175,26 @@ func toPeriod(total uint32, active uint16) pwmPeriod {
 	return pwmPeriod(total-1)<<16 | pwmPeriod(active)
 }
 
-// getBestPrescale finds the best prescaler.
+// getBestPrescale returns the prescaler with the best resolution for f, along
+// with the number of cycles in a period.
 //
-// Cycles must be between 2 and 0x10000/2.
-func getBestPrescale(period time.Duration) pwmPrescale {
-	// TODO(maruel): Rewrite this function, it is incorrect.
+// Returns false if f can't be generated.
+func getBestPrescale(f physic.Frequency) (pwmPrescale, uint32, bool) {
+	if f <= 0 || f > pwmMaxFreq {
+		return 0, 0, false
+	}
+	// The prescalers are sorted from the fastest to the slowest.
 	for _, v := range prescalers {
-		p := time.Second / time.Duration(v.freq)
-		smallest := (period / pwmMaxPeriod)
-		largest := (period / 2)
-		if p > smallest && p < largest {
-			return v.scaler
+		// Round to the nearest number of cycles.
+		total := (physic.Frequency(v.freq)*physic.Hertz + f/2) / f
+		if total < 2 {
+			return 0, 0, false
+		}
+		if total <= pwmMaxPeriod {
+			return v.scaler, uint32(total), true
 		}
 	}
-	// Period is longer than 196s.
-	return pwmPrescale72000
+	return 0, 0, false
 }
 
 // pwmMap represents the PWM memory mapped CPU registers.
@@ -195,3 +210,87 @@ type pwmMap struct {
 func (p *pwmMap) String() string {
 	return fmt.Sprintf("pwmMap{%s, %v}", p.ctl, p.period)
 }
+
+// driverPWM implements periph.Driver.
+//
+// It maps the PWM controller used by Pin.PWM().
+type driverPWM struct {
+	// pwmMemory is the memory map of the CPU PWM registers.
+	pwmMemory *pwmMap
+	// pwmView is the mapping of pwmMemory.
+	pwmView *pmem.View
+}
+
+// Close releases the memory mapping.
+func (d *driverPWM) Close() error {
+	var err error
+	if d.pwmView != nil {
+		err = d.pwmView.Close()
+		d.pwmView = nil
+	}
+	d.pwmMemory = nil
+	return err
+}
+
+func (d *driverPWM) String() string {
+	return "allwinner-pwm"
+}
+
+func (d *driverPWM) Prerequisites() []string {
+	return []string{"allwinner-gpio"}
+}
+
+func (d *driverPWM) After() []string {
+	return nil
+}
+
+func (d *driverPWM) Init() (bool, error) {
+	// pwmBaseAddr is the physical base address of the PWM registers.
+	var pwmBaseAddr uint32
+	if IsA64() {
+		// Page 194.
+		pwmBaseAddr = 0x1C21400
+	} else if IsR8() {
+		// Page 83.
+		pwmBaseAddr = 0x1C20C00 + 0x200
+	} else {
+		return false, errors.New("unsupported CPU architecture")
+	}
+	v, err := pmem.MapAsPODView(uint64(pwmBaseAddr), &d.pwmMemory)
+	if err != nil {
+		if os.IsPermission(err) {
+			return true, fmt.Errorf("need more access, try as root: %v", err)
+		}
+		return true, err
+	}
+	d.pwmView = v
+	return true, nil
+}
+
+// set starts PWM0 in cycle mode with an active high output.
+func (d *driverPWM) set(scaler pwmPrescale, period pwmPeriod) error {
+	m := d.pwmMemory
+	// Stop the output while changing the prescaler. PWM1 bits are kept.
+	m.ctl = m.ctl&^pwm0Mask | pwmCtl(scaler) | pwm0Polarity
+	for i := 0; m.ctl&pwmBusy != 0; i++ {
+		if i == 1000000 {
+			return errors.New("period register is busy")
+		}
+	}
+	m.period = period
+	m.ctl |= pwm0SCLK | pwm0Enable
+	return nil
+}
+
+// halt stops PWM0.
+func (d *driverPWM) halt() {
+	d.pwmMemory.ctl &^= pwm0Enable | pwm0SCLK
+}
+
+func init() {
+	if isArm {
+		periph.MustRegister(&drvPWM)
+	}
+}
+
+var drvPWM driverPWM
@@ -0,0 +1,110 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package allwinner
+
+import (
+	"testing"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/physic"
+)
+
+func TestGetBestPrescale(t *testing.T) {
+	data := []struct {
+		f      physic.Frequency
+		scaler pwmPrescale
+		total  uint32
+	}{
+		{12 * physic.MegaHertz, pwmPrescale1, 2},
+		{physic.KiloHertz, pwmPrescale1, 24000},
+		{100 * physic.Hertz, pwmPrescale120, 2000},
+		{physic.Hertz, pwmPrescale480, 50000},
+		{physic.Hertz / 100, pwmPrescale48000, 50000},
+		{physic.Hertz / 150, pwmPrescale72000, 49955},
+	}
+	for i, line := range data {
+		scaler, total, ok := getBestPrescale(line.f)
+		if !ok || scaler != line.scaler || total != line.total {
+			t.Fatalf("#%d: %s: %s %d %t", i, line.f, scaler, total, ok)
+		}
+	}
+	for _, f := range []physic.Frequency{0, -physic.Hertz, 13 * physic.MegaHertz, physic.MilliHertz} {
+		if _, _, ok := getBestPrescale(f); ok {
+			t.Fatal(f)
+		}
+	}
+}
+
+func TestPin_PWM(t *testing.T) {
+	defer reset()
+	if err := mapR8Pins(); err != nil {
+		t.Fatal(err)
+	}
+	drvGPIO.gpioMemory = &gpioMap{}
+	if err := PB2.PWM(gpio.DutyHalf, physic.KiloHertz); err == nil {
+		t.Fatal("allwinner-pwm is not initialized")
+	}
+	drvPWM.pwmMemory = &pwmMap{}
+	if err := PB2.PWM(gpio.DutyHalf, physic.KiloHertz); err != nil {
+		t.Fatal(err)
+	}
+	m := drvPWM.pwmMemory
+	if exp := pwm0Enable | pwm0SCLK | pwm0Polarity | pwmCtl(pwmPrescale1); m.ctl != exp {
+		t.Fatal(m.ctl)
+	}
+	if m.period != toPeriod(24000, 12000) {
+		t.Fatal(m.period)
+	}
+	if f := PB2.Function(); f != "PWM0" {
+		t.Fatal(f)
+	}
+	// PWM1 bits are preserved.
+	m.ctl |= 1 << 15
+	if err := PB2.PWM(gpio.DutyMax/4, 100*physic.Hertz); err != nil {
+		t.Fatal(err)
+	}
+	if exp := 1<<15 | pwm0Enable | pwm0SCLK | pwm0Polarity | pwmCtl(pwmPrescale120); m.ctl != exp {
+		t.Fatal(m.ctl)
+	}
+	if m.period != toPeriod(2000, 500) {
+		t.Fatal(m.period)
+	}
+	if err := PB2.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if m.ctl&(pwm0Enable|pwm0SCLK) != 0 {
+		t.Fatal(m.ctl)
+	}
+	if err := PB2.PWM(gpio.DutyHalf, physic.KiloHertz); err != nil {
+		t.Fatal(err)
+	}
+	// Full duty is a plain output, and it stops the PWM.
+	if err := PB2.PWM(gpio.DutyMax, physic.KiloHertz); err != nil {
+		t.Fatal(err)
+	}
+	if f := PB2.Function(); f != "Out/High" {
+		t.Fatal(f)
+	}
+	if m.ctl&pwm0Enable != 0 {
+		t.Fatal(m.ctl)
+	}
+
+	// Errors.
+	if PB3.PWM(gpio.DutyHalf, physic.KiloHertz) == nil {
+		t.Fatal("PB3 doesn't support PWM")
+	}
+	if PB2.PWM(-1, physic.KiloHertz) == nil {
+		t.Fatal("invalid duty")
+	}
+	if PB2.PWM(gpio.DutyHalf, 20*physic.MegaHertz) == nil {
+		t.Fatal("frequency too high")
+	}
+}
+
+func TestDriverPWM(t *testing.T) {
+	if drvPWM.String() != "allwinner-pwm" || len(drvPWM.Prerequisites()) != 1 || drvPWM.After() != nil {
+		t.Fatal("unexpected driver")
+	}
+}
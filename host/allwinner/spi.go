@@ -7,10 +7,554 @@ package allwinner
 import (
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"os"
+	"sync"
 	"time"
+	"unsafe"
+
+	"periph.io/x/periph"
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/pin"
+	"periph.io/x/periph/conn/spi"
+	"periph.io/x/periph/conn/spi/spireg"
+	"periph.io/x/periph/host/distro"
+	"periph.io/x/periph/host/pmem"
 )
 
+// SPI is a SPI port driven via the memory mapped CPU registers.
+//
+// Use spireg.Open("allwinner-spi2") to open it. Only CS0 is supported.
+//
+// The R8 and A64 register layouts are supported.
+//
+// Controllers enabled in the device tree are left to the kernel driver and are
+// not registered.
+//
+// On the R8, transfers larger than the FIFO use the dedicated DMA controller
+// when allwinner-dma is loaded, otherwise the FIFO is polled. The FIFO is
+// always polled on the A64.
+type SPI struct {
+	conn spiConn
+}
+
+// Close disables the controller.
+//
+// Note that the object is not reusable afterward.
+func (s *SPI) Close() error {
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+	if s.conn.regs == nil {
+		return errors.New("allwinner-spi: already closed")
+	}
+	s.conn.regs.disable()
+	s.conn.regs = nil
+	return nil
+}
+
+func (s *SPI) String() string {
+	return s.conn.String()
+}
+
+// LimitSpeed implements spi.PortCloser.
+func (s *SPI) LimitSpeed(f physic.Frequency) error {
+	if f < spiMinFreq {
+		return fmt.Errorf("allwinner-spi: invalid speed %s; minimum supported clock is %s", f, spiMinFreq)
+	}
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+	s.conn.freqPort = f
+	return nil
+}
+
+// Connect implements spi.Port.
+//
+// It muxes the pins to the controller. Only 8 bits words are supported and
+// spi.HalfDuplex is not supported.
+func (s *SPI) Connect(f physic.Frequency, mode spi.Mode, bits int) (spi.Conn, error) {
+	if f < 0 || (f != 0 && f < spiMinFreq) {
+		return nil, fmt.Errorf("allwinner-spi: invalid speed %s; minimum supported clock is %s", f, spiMinFreq)
+	}
+	if mode&^(spi.Mode3|spi.NoCS|spi.LSBFirst) != 0 {
+		return nil, fmt.Errorf("allwinner-spi: invalid mode %v", mode)
+	}
+	if bits != 8 {
+		return nil, fmt.Errorf("allwinner-spi: invalid bits %d; only 8 is supported", bits)
+	}
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+	if s.conn.regs == nil {
+		return nil, errors.New("allwinner-spi: port is closed")
+	}
+	if s.conn.connected {
+		return nil, errors.New("allwinner-spi: Connect() can only be called exactly once")
+	}
+	pins := map[string]*Pin{"CLK": s.conn.clk, "MOSI": s.conn.mosi, "MISO": s.conn.miso}
+	if mode&spi.NoCS == 0 {
+		pins["CS0"] = s.conn.cs
+	}
+	for name, p := range pins {
+		if err := p.SetFunc(pin.Func(fmt.Sprintf("SPI%d_%s", s.conn.bus, name))); err != nil {
+			return nil, fmt.Errorf("allwinner-spi: %v", err)
+		}
+	}
+	drvSPI.enableClock(s.conn.bus)
+	s.conn.regs.setup(mode)
+	s.conn.connected = true
+	s.conn.freqConn = f
+	s.conn.noCS = mode&spi.NoCS != 0
+	return &s.conn, nil
+}
+
+// MaxTxSize implements conn.Limits.
+func (s *SPI) MaxTxSize() int {
+	return spiMaxTxSize
+}
+
+// CLK implements spi.Pins.
+func (s *SPI) CLK() gpio.PinOut {
+	return s.conn.CLK()
+}
+
+// MISO implements spi.Pins.
+func (s *SPI) MISO() gpio.PinIn {
+	return s.conn.MISO()
+}
+
+// MOSI implements spi.Pins.
+func (s *SPI) MOSI() gpio.PinOut {
+	return s.conn.MOSI()
+}
+
+// CS implements spi.Pins.
+func (s *SPI) CS() gpio.PinOut {
+	return s.conn.CS()
+}
+
+//
+
+const (
+	// spiSourceFreq is the module clock; the controllers are run from OSC24M.
+	spiSourceFreq = 24 * physic.MegaHertz
+	spiMaxFreq    = spiSourceFreq / 2
+	spiMinFreq    = spiSourceFreq / 65536
+	// spiFIFOSize is the depth of each RX and TX FIFO.
+	spiFIFOSize = 64
+	// spiMaxTxSize is limited by the 24 bits burst counter.
+	spiMaxTxSize = 1<<24 - 1
+)
+
+// spiR8BaseAddr are the physical base addresses of the SPI controllers.
+//
+// R8: Page 151.
+var spiR8BaseAddr = [3]uint32{0x01C05000, 0x01C06000, 0x01C17000}
+
+// spiA64BaseAddr are the physical base addresses of the SPI controllers.
+//
+// The A64 has no SPI2.
+var spiA64BaseAddr = [2]uint32{0x01C68000, 0x01C69000}
+
+// spiDTCompatible are the device tree compatible strings of the kernel
+// drivers for the controllers.
+var spiDTCompatible = []string{"allwinner,sun4i-a10-spi", "allwinner,sun6i-a31-spi", "allwinner,sun8i-h3-spi"}
+
+// spiR8DRQ are the dedicated DMA request types of the SPI controllers.
+var spiR8DRQ = [3]struct{ tx, rx ddmaR8Cfg }{
+	{ddmaDstDrqSPI0TX, ddmaSrcDrqSPI0RX},
+	{ddmaDstDrqSPI1TX, ddmaSrcDrqSPI1RX},
+	{ddmaDstDrqSPI2TX, ddmaSrcDrqSPI2RX},
+}
+
+// spiFIFO is the byte wide access to the FIFOs of a controller.
+//
+// It is implemented by *spiR8Group and *spiA64Group.
+type spiFIFO interface {
+	push(b byte)
+	pop() byte
+}
+
+// spiRegs is the register layout of a controller.
+//
+// It is implemented by *spiR8Group and *spiA64Group.
+type spiRegs interface {
+	spiFIFO
+	// setup resets the controller in master mode with the CS line deasserted.
+	setup(mode spi.Mode)
+	// disable disables the controller.
+	disable()
+	// setClock sets the clock rate control.
+	setClock(c spiR8ClockCtl)
+	// setCS asserts or deasserts the CS line.
+	setCS(asserted bool)
+	// start resets the FIFOs and sets the length of the next burst.
+	start(l int)
+	// exchange starts the burst.
+	exchange()
+	// levels returns the number of bytes in the TX and RX FIFOs.
+	levels() (tx, rx int)
+	// done acknowledges the transfer completion.
+	done()
+}
+
+// spiConn implements spi.Conn.
+type spiConn struct {
+	// Immutable
+	name string
+	bus  int
+	clk  *Pin
+	mosi *Pin
+	miso *Pin
+	cs   *Pin
+
+	mu        sync.Mutex
+	regs      spiRegs
+	fifo      spiFIFO
+	freqPort  physic.Frequency // Frequency specified at LimitSpeed()
+	freqConn  physic.Frequency // Frequency specified at Connect()
+	connected bool
+	noCS      bool
+	p         [1]spi.Packet
+}
+
+func (s *spiConn) String() string {
+	return s.name
+}
+
+// Read implements io.Reader.
+func (s *spiConn) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, errors.New("allwinner-spi: Read() with empty buffer")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.p[0] = spi.Packet{R: b}
+	if err := s.txPackets(s.p[:]); err != nil {
+		return 0, fmt.Errorf("allwinner-spi: Read() failed: %v", err)
+	}
+	return len(b), nil
+}
+
+// Write implements io.Writer.
+func (s *spiConn) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, errors.New("allwinner-spi: Write() with empty buffer")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.p[0] = spi.Packet{W: b}
+	if err := s.txPackets(s.p[:]); err != nil {
+		return 0, fmt.Errorf("allwinner-spi: Write() failed: %v", err)
+	}
+	return len(b), nil
+}
+
+// Tx sends and receives data simultaneously.
+//
+// It is OK if both w and r point to the same underlying byte slice.
+func (s *spiConn) Tx(w, r []byte) error {
+	if len(w) == 0 && len(r) == 0 {
+		return errors.New("allwinner-spi: Tx() with empty buffers")
+	}
+	if len(w) != 0 && len(r) != 0 && len(w) != len(r) {
+		return fmt.Errorf("allwinner-spi: Tx(): when both w and r are used, they must be the same size; got %d and %d bytes", len(w), len(r))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.p[0] = spi.Packet{W: w, R: r}
+	if err := s.txPackets(s.p[:]); err != nil {
+		return fmt.Errorf("allwinner-spi: Tx() failed: %v", err)
+	}
+	return nil
+}
+
+// TxPackets sends and receives packets as specified by the user.
+func (s *spiConn) TxPackets(p []spi.Packet) error {
+	total := 0
+	for i := range p {
+		lW := len(p[i].W)
+		lR := len(p[i].R)
+		if lW != lR && lW != 0 && lR != 0 {
+			return fmt.Errorf("allwinner-spi: when both w and r are used, they must be the same size; got %d and %d bytes", lW, lR)
+		}
+		if lW == 0 && lR == 0 {
+			return errors.New("allwinner-spi: empty packet")
+		}
+		if b := p[i].BitsPerWord; b != 0 && b != 8 {
+			return fmt.Errorf("allwinner-spi: invalid bits %d; only 8 is supported", b)
+		}
+		total += lW + lR
+	}
+	if total == 0 {
+		return errors.New("allwinner-spi: empty packets")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.txPackets(p); err != nil {
+		return fmt.Errorf("allwinner-spi: TxPackets() failed: %v", err)
+	}
+	return nil
+}
+
+// Duplex implements conn.Conn.
+func (s *spiConn) Duplex() conn.Duplex {
+	return conn.Full
+}
+
+// MaxTxSize implements conn.Limits.
+func (s *spiConn) MaxTxSize() int {
+	return spiMaxTxSize
+}
+
+// CLK implements spi.Pins.
+func (s *spiConn) CLK() gpio.PinOut {
+	return s.clk
+}
+
+// MISO implements spi.Pins.
+func (s *spiConn) MISO() gpio.PinIn {
+	return s.miso
+}
+
+// MOSI implements spi.Pins.
+func (s *spiConn) MOSI() gpio.PinOut {
+	return s.mosi
+}
+
+// CS implements spi.Pins.
+func (s *spiConn) CS() gpio.PinOut {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.noCS {
+		return gpio.INVALID
+	}
+	return s.cs
+}
+
+// freq returns the lowest of the port and device speeds.
+func (s *spiConn) freq() physic.Frequency {
+	f := s.freqPort
+	if s.freqConn != 0 && (f == 0 || s.freqConn < f) {
+		f = s.freqConn
+	}
+	if f == 0 || f > spiMaxFreq {
+		f = spiMaxFreq
+	}
+	return f
+}
+
+// txPackets runs the packets; CS is asserted manually so it can be kept
+// between packets. s.mu must be held.
+func (s *spiConn) txPackets(p []spi.Packet) error {
+	if s.regs == nil {
+		return errors.New("port is closed")
+	}
+	f := s.freq()
+	c, _ := spiClock(f)
+	s.regs.setClock(c)
+	for i := range p {
+		if !s.noCS {
+			s.regs.setCS(true)
+		}
+		err := s.tx(p[i].W, p[i].R, f)
+		if !s.noCS && (!p[i].KeepCS || err != nil) {
+			s.regs.setCS(false)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tx runs one burst. Zeros are sent when w is empty.
+func (s *spiConn) tx(w, r []byte, f physic.Frequency) error {
+	l := len(w)
+	if l == 0 {
+		l = len(r)
+	}
+	// Leave a lot of room for the scheduler.
+	timeout := 2*time.Duration(8*l)*f.Duration() + 100*time.Millisecond
+	// Only the R8 has a dedicated DMA controller.
+	if g, ok := s.regs.(*spiR8Group); ok && l > spiFIFOSize && drvDMA.dmaMemory != nil {
+		// Large buffers may fail to be allocated due to memory fragmentation;
+		// fall back to polling the FIFO in this case.
+		if buf, err := drvDMA.dmaBufAllocator((2*l + 0xFFF) &^ 0xFFF); err == nil {
+			defer buf.Close()
+			return s.txDMA(g, w, r, buf, timeout)
+		}
+	}
+	return s.txFIFO(w, r, timeout)
+}
+
+// txFIFO runs a burst by polling the FIFOs.
+func (s *spiConn) txFIFO(w, r []byte, timeout time.Duration) error {
+	l := len(w)
+	if l == 0 {
+		l = len(r)
+	}
+	g := s.regs
+	g.start(l)
+	sent := 0
+	for ; sent < l && sent < spiFIFOSize; sent++ {
+		s.fifo.push(byteAt(w, sent))
+	}
+	g.exchange()
+	deadline := time.Now().Add(timeout)
+	for recv := 0; recv < l; {
+		tx, n := g.levels()
+		for i := 0; i < n; i++ {
+			b := s.fifo.pop()
+			if len(r) != 0 {
+				r[recv] = b
+			}
+			recv++
+		}
+		// The TX FIFO level read above can only have decreased since.
+		for i := tx; i < spiFIFOSize && sent < l; i++ {
+			s.fifo.push(byteAt(w, sent))
+			sent++
+		}
+		// Only look at the clock when the controller is not keeping up.
+		if n == 0 && time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %d/%d bytes", recv, l)
+		}
+	}
+	g.done()
+	return nil
+}
+
+// txDMA runs a burst with two dedicated DMA channels, one feeding the TX FIFO
+// and one draining the RX FIFO into buf.
+func (s *spiConn) txDMA(g *spiR8Group, w, r []byte, buf pmem.Mem, timeout time.Duration) error {
+	l := len(w)
+	if l == 0 {
+		l = len(r)
+	}
+	b := buf.Bytes()
+	if len(w) != 0 {
+		copy(b, w)
+	} else {
+		for i := range b[:l] {
+			b[i] = 0
+		}
+	}
+	g.start(l)
+	// Request DMA when the RX FIFO has data and when the TX FIFO is not full,
+	// like the sun4i-spi kernel driver does.
+	g.dmaCtl = spiR8DMARXEmpty | spiR8DMATXByte
+	g.ctl |= spiR8DDMA
+	defer func() {
+		g.ctl &^= spiR8DDMA
+		g.dmaCtl = 0
+	}()
+
+	d := drvDMA.dmaMemory
+	base := spiR8BaseAddr[s.bus]
+	phys := uint32(buf.PhysAddr())
+	rx, err := d.startDedicated(base, phys+uint32(l), uint32(l), true, false, ddmaDstDrqSDRAM|spiR8DRQ[s.bus].rx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rx.release()
+	}()
+	tx, err := d.startDedicated(phys, base+4, uint32(l), false, true, spiR8DRQ[s.bus].tx|ddmaSrcDrqSDRAM)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.release()
+	}()
+	g.exchange()
+	deadline := time.Now().Add(timeout)
+	for rx.cfg&ddmaBusy != 0 {
+		if time.Now().After(deadline) {
+			return errors.New("timed out waiting for DMA")
+		}
+	}
+	if len(r) != 0 {
+		copy(r, b[l:2*l])
+	}
+	g.done()
+	return nil
+}
+
+// byteAt returns b[i] or 0 if b is empty.
+func byteAt(b []byte, i int) byte {
+	if len(b) == 0 {
+		return 0
+	}
+	return b[i]
+}
+
+// spiClock returns the clock control value for the fastest clock not faster
+// than f, along with the resulting frequency.
+func spiClock(f physic.Frequency) (spiR8ClockCtl, physic.Frequency) {
+	if f >= spiMaxFreq {
+		return spiR8DivRateSelect2, spiMaxFreq
+	}
+	// CDR2: source/(2*(n+1)) for n in [0, 255].
+	if n := (spiSourceFreq + 2*f - 1) / (2 * f); n <= 256 {
+		return spiR8DivRateSelect2 | spiR8ClockCtl(n-1), spiSourceFreq / (2 * n)
+	}
+	// CDR1: source/2^(n+1) for n in [0, 15]; only divisors larger than CDR2's
+	// are useful.
+	for n := uint(9); n < 16; n++ {
+		if g := spiSourceFreq >> (n + 1); g <= f {
+			return spiR8ClockCtl(n) << 8, g
+		}
+	}
+	return spiR8Div65536, spiMinFreq
+}
+
+// spiPins returns the pins that can be muxed to a controller, if any.
+func spiPins(bus int) (clk, mosi, miso, cs *Pin) {
+	prefix := fmt.Sprintf("SPI%d_", bus)
+	for _, p := range cpupins {
+		if !p.available {
+			continue
+		}
+		for _, f := range p.altFunc {
+			switch string(f) {
+			case prefix + "CLK":
+				clk = p
+			case prefix + "MOSI":
+				mosi = p
+			case prefix + "MISO":
+				miso = p
+			case prefix + "CS0":
+				cs = p
+			}
+		}
+	}
+	return
+}
+
+func newSPI(bus int) (*SPI, error) {
+	if bus < 0 || bus >= len(drvSPI.spiMemory) || drvSPI.spiMemory[bus] == nil {
+		return nil, fmt.Errorf("allwinner-spi: invalid bus %d", bus)
+	}
+	clk, mosi, miso, cs := spiPins(bus)
+	if clk == nil || mosi == nil || miso == nil || cs == nil {
+		return nil, fmt.Errorf("allwinner-spi: pins for bus %d are not available", bus)
+	}
+	s := &SPI{
+		conn: spiConn{
+			name: fmt.Sprintf("allwinner-spi%d", bus),
+			bus:  bus,
+			clk:  clk,
+			mosi: mosi,
+			miso: miso,
+			cs:   cs,
+			regs: drvSPI.spiMemory[bus],
+		},
+	}
+	s.conn.fifo = s.conn.regs
+	return s, nil
+}
+
 const (
 	// 31:20 reserved
 	// Set this bit to ‘1’ to make the internal read sample point with a delay of
@@ -83,7 +627,7 @@ type spiR8DMACtl uint32
 
 const (
 	// 31:13 reserved
-	spiR8DivRateSelect2 spiR8ClockCtl = 1 << 12 // DRS; Use spiDivXX if set, use mask otherwise
+	spiR8DivRateSelect2 spiR8ClockCtl = 1 << 12 // DRS; Use mask if set, use spiR8DivXX otherwise
 	spiR8Div2           spiR8ClockCtl = 0 << 8  // CDR1; Use divisor 2^(n+1)
 	spiR8Div4           spiR8ClockCtl = 1 << 8  //
 	spiR8Div8           spiR8ClockCtl = 2 << 8  //
@@ -143,65 +687,417 @@ type spiR8Group struct {
 	reserved        [(0x1000 - 0x02C) / 4]uint32
 }
 
-func (s *spiR8Group) setup() {
+func (s *spiR8Group) setup(mode spi.Mode) {
+	ctl := spiR8CSManual | spiR8CSLevel | spiR8CSActiveLow | spiR8TransmitPause | spiR8Master | spiR8Enable
+	if mode&spi.Mode1 != 0 {
+		ctl |= spiR8PHA
+	}
+	if mode&spi.Mode2 != 0 {
+		ctl |= spiR8ClkActiveLow
+	}
+	if mode&spi.LSBFirst != 0 {
+		ctl |= spiR8LSB
+	}
 	s.intCtl = 0
-	s.status = 0
-	//s.dmaCtl = spiR8DMARXByte
+	s.status = spiR8TC
 	s.dmaCtl = 0
-	s.wait = 2
-	s.clockCtl = spiR8DivRateSelect2 | spiR8Div1024
-	// spiR8DDMA
-	s.ctl = spiR8CSManual | spiR8LSB | spiR8Master | spiR8Enable
+	s.wait = 0
+	s.ctl = ctl
 }
 
-// spiMap is the mapping of SPI registers.
-// R8: Page 152-153.
-type spiMap struct {
-	groups [3]spiR8Group
-}
-
-// spi2Write do a write on SPI2_MOSI via polling.
-func spi2Write(w []byte) error {
-	if drvDMA.clockMemory == nil || drvDMA.spiMemory == nil {
-		return errors.New("subsystem not initialized")
-	}
-	// Make sure the source clock is disabled. Set it at 250kHz.
-	//drvDMA.clockMemory.spi2Clk &^= clockSPIEnable
-	drvDMA.clockMemory.spi2Clk |= clockSPIEnable
-	drvDMA.clockMemory.spi2Clk = clockSPIDiv8a | clockSPIDiv12b
-	ch := &drvDMA.spiMemory.groups[2]
-	ch.setup()
-	fmt.Printf("Setup done\n")
-	for i := 0; i < len(w)/4; i++ {
-		// TODO(maruel): Access it in 8bit mode.
-		ch.tx = uint32(w[0])
-		for ch.fifoStatus.tx() == 0 {
-			log.Printf("Waiting for bit %# v\n", ch)
-			time.Sleep(time.Second)
-		}
-	}
-	fmt.Printf("Done\n")
+func (s *spiR8Group) disable() {
+	s.ctl &^= spiR8Enable
+}
+
+func (s *spiR8Group) setClock(c spiR8ClockCtl) {
+	s.clockCtl = c
+}
+
+func (s *spiR8Group) setCS(asserted bool) {
+	if asserted {
+		s.ctl &^= spiR8CSLevel
+	} else {
+		s.ctl |= spiR8CSLevel
+	}
+}
+
+func (s *spiR8Group) start(l int) {
+	s.ctl |= spiR8RXFIFOReset | spiR8TXFIFOReset
+	s.status = spiR8TC
+	s.burstCounter = uint32(l)
+	s.transmitCounter = uint32(l)
+}
+
+func (s *spiR8Group) exchange() {
+	s.ctl |= spiR8ExchangeBurst
+}
+
+func (s *spiR8Group) levels() (int, int) {
+	st := s.fifoStatus
+	return int(st.tx()), int(st.rx())
+}
+
+func (s *spiR8Group) done() {
+	s.status = spiR8TC
+}
+
+// push writes one byte to the TX FIFO.
+//
+// The FIFO registers must be accessed in 8 bits mode, otherwise 4 bytes are
+// queued at once.
+func (s *spiR8Group) push(b byte) {
+	*(*uint8)(unsafe.Pointer(&s.tx)) = b
+}
+
+// pop reads one byte from the RX FIFO.
+func (s *spiR8Group) pop() byte {
+	return *(*uint8)(unsafe.Pointer(&s.rx))
+}
+
+const (
+	spiA64SoftReset     spiA64GlobalCtl = 1 << 31 // SRST; Self clearing
+	spiA64TransmitPause spiA64GlobalCtl = 1 << 7  // TP_EN; Stop when the RX FIFO is full
+	// 6:2 reserved
+	spiA64Master spiA64GlobalCtl = 1 << 1 // MODE; Slave mode if not set
+	spiA64Enable spiA64GlobalCtl = 1 << 0 // EN
+)
+
+// SPI_GCR
+type spiA64GlobalCtl uint32
+
+const (
+	spiA64ExchangeBurst spiA64TransferCtl = 1 << 31 // XCH
+	// 30:14 reserved
+	spiA64DelaySample  spiA64TransferCtl = 1 << 13 // SDM
+	spiA64LSB          spiA64TransferCtl = 1 << 12 // FBS; MSB by default, LSB when set
+	spiA64SampleCtl    spiA64TransferCtl = 1 << 11 // SDC
+	spiA64RapidsRead   spiA64TransferCtl = 1 << 10 // RPSM
+	spiA64DummyBurst   spiA64TransferCtl = 1 << 9  // DDB
+	spiA64DiscardHash  spiA64TransferCtl = 1 << 8  // DHB
+	spiA64CSLevel      spiA64TransferCtl = 1 << 7  // SS_LEVEL; Chip Select level
+	spiA64CSManual     spiA64TransferCtl = 1 << 6  // SS_OWNER; Do not switch CS automatically
+	spiA64CS0          spiA64TransferCtl = 0 << 4  // SS_SEL; Which CS line to use
+	spiA64CS1          spiA64TransferCtl = 1 << 4  //
+	spiA64CS2          spiA64TransferCtl = 2 << 4  //
+	spiA64CS3          spiA64TransferCtl = 3 << 4  //
+	spiA64CSBetween    spiA64TransferCtl = 1 << 3  // SSCTL
+	spiA64CSActiveLow  spiA64TransferCtl = 1 << 2  // SPOL; CS line polarity
+	spiA64ClkActiveLow spiA64TransferCtl = 1 << 1  // CPOL; Clock line polarity
+	spiA64PHA          spiA64TransferCtl = 1 << 0  // CPHA; Phase 1 if set (leading edge for setup data)
+)
+
+// SPI_TCR
+type spiA64TransferCtl uint32
+
+const (
+	// 31:14 reserved
+	spiA64TC spiA64IntStatus = 1 << 12 // TC; Transfer Completed
+)
+
+// SPI_ISR
+type spiA64IntStatus uint32
+
+const (
+	spiA64TXFIFOReset spiA64FIFOCtl = 1 << 31 // TF_RST; Self clearing
+	// 30:16 test mode, DMA request and TX trigger level
+	spiA64RXFIFOReset spiA64FIFOCtl = 1 << 15 // RF_RST; Self clearing
+	// 14:0 test mode, DMA request and RX trigger level
+)
+
+// SPI_FCR
+type spiA64FIFOCtl uint32
+
+// SPI_FSR
+type spiA64FIFOStatus uint32
+
+func (s spiA64FIFOStatus) tx() uint8 {
+	return uint8(uint32(s) >> 16)
+}
+
+func (s spiA64FIFOStatus) rx() uint8 {
+	return uint8(s)
+}
+
+// spiA64Group is the mapping of SPI registers for one SPI controller.
+type spiA64Group struct {
+	reserved0    uint32                      // 0x000
+	globalCtl    spiA64GlobalCtl             // 0x004 SPI_GCR Global Control
+	transferCtl  spiA64TransferCtl           // 0x008 SPI_TCR Transfer Control
+	reserved1    uint32                      // 0x00C
+	intCtl       uint32                      // 0x010 SPI_IER Interrupt Control
+	status       spiA64IntStatus             // 0x014 SPI_ISR Interrupt Status
+	fifoCtl      spiA64FIFOCtl               // 0x018 SPI_FCR FIFO Control
+	fifoStatus   spiA64FIFOStatus            // 0x01C SPI_FSR FIFO Status
+	wait         uint32                      // 0x020 SPI_WCR Wait Clock Counter
+	clockCtl     spiR8ClockCtl               // 0x024 SPI_CCR Clock Rate Control; same as the R8
+	reserved2    [2]uint32                   // 0x028
+	burstCounter uint32                      // 0x030 SPI_MBC Master Burst Counter; 24 bits
+	txCounter    uint32                      // 0x034 SPI_MTC Master Transmit Counter; 24 bits
+	burstCtl     uint32                      // 0x038 SPI_BCC Master Burst Control; STC in 23:0
+	reserved3    [(0x200 - 0x03C) / 4]uint32 //
+	tx           uint32                      // 0x200 SPI_TXD TX Data
+	reserved4    [(0x300 - 0x204) / 4]uint32 //
+	rx           uint32                      // 0x300 SPI_RXD RX Data
+	reserved5    [(0x1000 - 0x304) / 4]uint32
+}
+
+func (s *spiA64Group) setup(mode spi.Mode) {
+	ctl := spiA64CSManual | spiA64CSLevel | spiA64CSActiveLow
+	if mode&spi.Mode1 != 0 {
+		ctl |= spiA64PHA
+	}
+	if mode&spi.Mode2 != 0 {
+		ctl |= spiA64ClkActiveLow
+	}
+	if mode&spi.LSBFirst != 0 {
+		ctl |= spiA64LSB
+	}
+	s.globalCtl = spiA64TransmitPause | spiA64Master | spiA64Enable
+	s.intCtl = 0
+	s.status = spiA64TC
+	s.wait = 0
+	s.transferCtl = ctl
+}
+
+func (s *spiA64Group) disable() {
+	s.globalCtl &^= spiA64Enable
+}
+
+func (s *spiA64Group) setClock(c spiR8ClockCtl) {
+	s.clockCtl = c
+}
+
+func (s *spiA64Group) setCS(asserted bool) {
+	if asserted {
+		s.transferCtl &^= spiA64CSLevel
+	} else {
+		s.transferCtl |= spiA64CSLevel
+	}
+}
+
+func (s *spiA64Group) start(l int) {
+	s.fifoCtl = spiA64RXFIFOReset | spiA64TXFIFOReset
+	s.status = spiA64TC
+	s.burstCounter = uint32(l)
+	s.txCounter = uint32(l)
+	s.burstCtl = uint32(l)
+}
+
+func (s *spiA64Group) exchange() {
+	s.transferCtl |= spiA64ExchangeBurst
+}
+
+func (s *spiA64Group) levels() (int, int) {
+	st := s.fifoStatus
+	return int(st.tx()), int(st.rx())
+}
+
+func (s *spiA64Group) done() {
+	s.status = spiA64TC
+}
+
+// push writes one byte to the TX FIFO.
+//
+// Like on the R8, the FIFO registers must be accessed in 8 bits mode.
+func (s *spiA64Group) push(b byte) {
+	*(*uint8)(unsafe.Pointer(&s.tx)) = b
+}
+
+// pop reads one byte from the RX FIFO.
+func (s *spiA64Group) pop() byte {
+	return *(*uint8)(unsafe.Pointer(&s.rx))
+}
+
+// driverSPI implements periph.Driver.
+type driverSPI struct {
+	// clockMemory is the memory mapping for the clock CPU registers.
+	clockMemory *clockMap
+	// spiMemory are the memory mappings of each SPI controller.
+	spiMemory [3]spiRegs
+	// baseAddr are the physical base addresses of the controllers, to find
+	// them in the device tree.
+	baseAddr []uint32
+	// softReset is set when the controllers must be taken out of reset, which
+	// is the case on the A64.
+	softReset bool
+	// views are the memory mappings done by Init().
+	views []*pmem.View
+	// ports are the ports registered by Init().
+	ports []string
+}
+
+func (d *driverSPI) String() string {
+	return "allwinner-spi"
+}
+
+func (d *driverSPI) Prerequisites() []string {
+	return []string{"allwinner-gpio"}
+}
+
+func (d *driverSPI) After() []string {
+	// The DMA controller is used for large transfers. Let spidev keep the port
+	// numbers.
+	return []string{"allwinner-dma", "sysfs-spi"}
+}
+
+func (d *driverSPI) Init() (bool, error) {
+	if !IsR8() && !IsA64() {
+		return false, errors.New("SPI register layout is only supported on R8 and A64")
+	}
+	// clockBaseAddr is the physical base address of the clock registers.
+	// R8: Page 57. A64: Page 81.
+	const clockBaseAddr = 0x1C20000
+	if err := d.mapAsPOD(clockBaseAddr, &d.clockMemory); err != nil {
+		if os.IsPermission(err) {
+			return true, fmt.Errorf("need more access, try as root: %v", err)
+		}
+		return true, err
+	}
+	if IsR8() {
+		d.baseAddr = spiR8BaseAddr[:]
+		for i, base := range d.baseAddr {
+			var g *spiR8Group
+			if err := d.mapAsPOD(uint64(base), &g); err != nil {
+				return true, err
+			}
+			d.spiMemory[i] = g
+		}
+	} else {
+		d.baseAddr = spiA64BaseAddr[:]
+		d.softReset = true
+		for i, base := range d.baseAddr {
+			var g *spiA64Group
+			if err := d.mapAsPOD(uint64(base), &g); err != nil {
+				return true, err
+			}
+			d.spiMemory[i] = g
+		}
+	}
+	return true, d.register()
+}
+
+// Close unregisters the ports and releases the memory mappings.
+func (d *driverSPI) Close() error {
+	for _, name := range d.ports {
+		_ = spireg.Unregister(name)
+	}
+	d.ports = nil
+	var err error
+	for _, v := range d.views {
+		if err2 := v.Close(); err2 != nil && err == nil {
+			err = err2
+		}
+	}
+	d.views = nil
+	d.clockMemory = nil
+	d.spiMemory = [3]spiRegs{}
+	d.baseAddr = nil
+	d.softReset = false
+	return err
+}
+
+// register registers the controllers which pins are available and which are
+// not owned by the kernel.
+//
+// The bus number is only claimed if no other port has it.
+func (d *driverSPI) register() error {
+	used := map[int]bool{}
+	for _, r := range spireg.All() {
+		used[r.Number] = true
+	}
+	owned := d.kernelOwned()
+	for i := range d.spiMemory {
+		if d.spiMemory[i] == nil || owned[i] {
+			continue
+		}
+		if clk, mosi, miso, cs := spiPins(i); clk == nil || mosi == nil || miso == nil || cs == nil {
+			continue
+		}
+		name := fmt.Sprintf("allwinner-spi%d", i)
+		n := i
+		if used[n] {
+			n = -1
+		}
+		o := openerSPI{i}
+		if err := spireg.Register(name, nil, n, o.Open); err != nil {
+			return err
+		}
+		d.ports = append(d.ports, name)
+	}
 	return nil
 }
 
-// spi2Read do a read on SPI2_MISO via polling.
-func spi2Read(r []byte) error {
-	if drvDMA.clockMemory == nil || drvDMA.spiMemory == nil {
-		return errors.New("subsystem not initialized")
+// kernelOwned returns the controllers which node is enabled in the device
+// tree; spidev owns them.
+func (d *driverSPI) kernelOwned() map[int]bool {
+	out := map[int]bool{}
+	t, err := spiDT()
+	if err != nil {
+		return out
 	}
-	// Make sure the source clock is disabled. Set it at 250kHz.
-	//drvDMA.clockMemory.spi2Clk &^= clockSPIEnable
-	drvDMA.clockMemory.spi2Clk |= clockSPIEnable
-	drvDMA.clockMemory.spi2Clk = clockSPIDiv8a | clockSPIDiv12b
-	ch := &drvDMA.spiMemory.groups[2]
-	ch.setup()
-	for i := 0; i < len(r)/4; i++ {
-		ch.tx = 0
-		for ch.status&spiR8TC == 0 {
+	for _, c := range spiDTCompatible {
+		for _, n := range t.Compatible(c) {
+			reg := n.PropUint32s("reg")
+			if !n.Enabled() || len(reg) == 0 {
+				continue
+			}
+			for i, base := range d.baseAddr {
+				if reg[0] == base {
+					out[i] = true
+				}
+			}
 		}
-		// TODO(maruel): Access it in 8bit mode.
-		r[i] = uint8(ch.rx)
 	}
-	fmt.Printf("Done\n")
+	return out
+}
+
+// enableClock ungates the controller and runs it from OSC24M.
+func (d *driverSPI) enableClock(bus int) {
+	if d.softReset {
+		d.clockMemory.busSoftRst0 |= 1 << uint(20+bus)
+	}
+	d.clockMemory.ahbGating0 |= 1 << uint(20+bus)
+	d.clockMemory.spiClk[bus] = clockSPIEnable | clockSPIOSC24M | clockSPIDiv1a | clockSPIDiv1b
+}
+
+// mapAsPOD maps the memory at base as i and keeps track of the mapping to
+// release it in Close().
+func (d *driverSPI) mapAsPOD(base uint64, i interface{}) error {
+	v, err := pmem.MapAsPODView(base, i)
+	if err != nil {
+		return err
+	}
+	d.views = append(d.views, v)
 	return nil
 }
+
+type openerSPI struct {
+	bus int
+}
+
+func (o *openerSPI) Open() (spi.PortCloser, error) {
+	return newSPI(o.bus)
+}
+
+func init() {
+	if isArm {
+		periph.MustRegister(&drvSPI)
+	}
+}
+
+var drvSPI driverSPI
+
+// spiDT is overridden in unit tests.
+var spiDT = distro.DT
+
+var _ conn.Limits = &SPI{}
+var _ conn.Limits = &spiConn{}
+var _ io.Reader = &spiConn{}
+var _ io.Writer = &spiConn{}
+var _ spi.Conn = &spiConn{}
+var _ spi.Pins = &SPI{}
+var _ spi.Pins = &spiConn{}
+var _ spi.PortCloser = &SPI{}
+var _ spiRegs = &spiR8Group{}
+var _ spiRegs = &spiA64Group{}
+var _ fmt.Stringer = &SPI{}
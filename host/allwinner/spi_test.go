@@ -0,0 +1,507 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package allwinner
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/pin"
+	"periph.io/x/periph/conn/spi"
+	"periph.io/x/periph/conn/spi/spireg"
+	"periph.io/x/periph/host/distro"
+	"periph.io/x/periph/host/pmem"
+)
+
+func TestSPI(t *testing.T) {
+	defer reset()
+	f := setupSPI(t)
+	s, err := newSPI(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.conn.fifo = f
+	if s.String() != "allwinner-spi2" || s.MaxTxSize() != spiMaxTxSize {
+		t.Fatal(s)
+	}
+	if s.LimitSpeed(physic.Hertz) == nil {
+		t.Fatal("speed too low")
+	}
+	if err := s.LimitSpeed(physic.MegaHertz); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Connect(physic.Hertz, spi.Mode0, 8); err == nil {
+		t.Fatal("speed too low")
+	}
+	if _, err := s.Connect(0, spi.HalfDuplex, 8); err == nil {
+		t.Fatal("half duplex is not supported")
+	}
+	if _, err := s.Connect(0, spi.Mode0, 9); err == nil {
+		t.Fatal("only 8 bits is supported")
+	}
+	c, err := s.Connect(10*physic.MegaHertz, spi.Mode3|spi.LSBFirst, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Connect(0, spi.Mode0, 8); err == nil {
+		t.Fatal("Connect() can only be called once")
+	}
+	for p, fn := range map[*Pin]string{PE0: "SPI2_CS0", PE1: "SPI2_CLK", PE2: "SPI2_MOSI", PE3: "SPI2_MISO"} {
+		if p.Function() != fn {
+			t.Fatal(p, p.Function())
+		}
+	}
+	if s.CLK() != PE1 || s.MOSI() != PE2 || s.MISO() != PE3 || s.CS() != PE0 {
+		t.Fatal("unexpected pins")
+	}
+	if drvSPI.clockMemory.ahbGating0 != 1<<22 || drvSPI.clockMemory.spiClk[2] != clockSPIEnable {
+		t.Fatal("clock not enabled")
+	}
+	g := drvSPI.spiMemory[2].(*spiR8Group)
+	if exp := spiR8PHA | spiR8ClkActiveLow | spiR8LSB | spiR8CSManual | spiR8CSLevel | spiR8CSActiveLow | spiR8TransmitPause | spiR8Master | spiR8Enable; g.ctl != exp {
+		t.Fatalf("0x%x", g.ctl)
+	}
+
+	w := []byte{1, 2, 3}
+	r := make([]byte, len(w))
+	if err := c.Tx(w, r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.sent, w) || !bytes.Equal(r, []byte{0xFE, 0xFD, 0xFC}) {
+		t.Fatal(f.sent, r)
+	}
+	// The lowest of both speeds is used.
+	if g.clockCtl != spiR8DivRateSelect2|11 || g.burstCounter != 3 || g.transmitCounter != 3 {
+		t.Fatal(g.clockCtl, g.burstCounter, g.transmitCounter)
+	}
+	if g.ctl&spiR8CSLevel == 0 || f.cs != 3 {
+		t.Fatal("CS must be asserted during the transfer only")
+	}
+
+	// Larger than the FIFO; dummy zeros are sent.
+	f.sent = nil
+	r = make([]byte, 200)
+	if n, err := c.(io.Reader).Read(r); n != len(r) || err != nil {
+		t.Fatal(n, err)
+	}
+	if !bytes.Equal(f.sent, make([]byte, 200)) || !bytes.Equal(r, bytes.Repeat([]byte{0xFF}, 200)) {
+		t.Fatal(f.sent, r)
+	}
+
+	// KeepCS.
+	f.sent = nil
+	if err := c.TxPackets([]spi.Packet{{W: []byte{1}, KeepCS: true}, {W: []byte{2}, KeepCS: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if g.ctl&spiR8CSLevel != 0 || !bytes.Equal(f.sent, []byte{1, 2}) {
+		t.Fatal("CS must be kept asserted")
+	}
+
+	// Errors.
+	if c.Tx(nil, nil) == nil {
+		t.Fatal("empty buffers")
+	}
+	if c.Tx([]byte{1}, make([]byte, 2)) == nil {
+		t.Fatal("different sizes")
+	}
+	if c.TxPackets([]spi.Packet{{W: []byte{1}, BitsPerWord: 16}}) == nil {
+		t.Fatal("only 8 bits is supported")
+	}
+	if c.TxPackets(nil) == nil {
+		t.Fatal("empty packets")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if g.ctl&spiR8Enable != 0 {
+		t.Fatal("controller must be disabled")
+	}
+	if c.Tx([]byte{1}, nil) == nil {
+		t.Fatal("port is closed")
+	}
+	if s.Close() == nil {
+		t.Fatal("already closed")
+	}
+}
+
+func TestSPI_NoCS(t *testing.T) {
+	defer reset()
+	f := setupSPI(t)
+	s, err := newSPI(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.conn.fifo = f
+	c, err := s.Connect(0, spi.NoCS, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.CS() != gpio.INVALID {
+		t.Fatal("CS must not be used")
+	}
+	if PE0.Function() == "SPI2_CS0" {
+		t.Fatal("CS must not be muxed")
+	}
+	if err := c.Tx([]byte{1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if f.cs != 0 {
+		t.Fatal("CS must not be asserted")
+	}
+	if g := drvSPI.spiMemory[2].(*spiR8Group); g.clockCtl != spiR8DivRateSelect2 {
+		t.Fatal(g.clockCtl)
+	}
+}
+
+func TestSPI_DMA(t *testing.T) {
+	defer reset()
+	f := setupSPI(t)
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	drvDMA.dmaMemory = &dmaMap{}
+	var m *fakeMem
+	drvDMA.dmaBufAllocator = func(s int) (pmem.Mem, error) {
+		m = &fakeMem{Slice: make(pmem.Slice, s)}
+		// Emulate the DMA controller filling the RX buffer.
+		for i := range m.Slice[100:200] {
+			m.Slice[100+i] = byte(i)
+		}
+		return m, nil
+	}
+	s, err := newSPI(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.conn.fifo = f
+	c, err := s.Connect(0, spi.Mode0, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bytes.Repeat([]byte{0xAA}, 100)
+	r := make([]byte, 100)
+	if err := c.Tx(w, r); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.sent) != 0 {
+		t.Fatal("FIFO must not be polled")
+	}
+	if !m.closed || len(m.Slice) != 4096 || !bytes.Equal(m.Slice[:100], w) {
+		t.Fatal("unexpected DMA buffer")
+	}
+	for i, b := range r {
+		if b != byte(i) {
+			t.Fatal(r)
+		}
+	}
+	// Both channels were used and released.
+	for _, i := range []int{6, 7} {
+		if ch := drvDMA.dmaMemory.dedicated[i]; ch.cfg != ddmaLoad || ch.srcAddr != 0 {
+			t.Fatal(i, ch)
+		}
+	}
+	if g := drvSPI.spiMemory[2].(*spiR8Group); g.ctl&spiR8DDMA != 0 || g.dmaCtl != 0 {
+		t.Fatal("DMA must be disabled")
+	}
+
+	// Small transfers use the FIFO.
+	if err := c.Tx([]byte{1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.sent, []byte{1}) {
+		t.Fatal(f.sent)
+	}
+
+	// Fall back to the FIFO when the buffer can't be allocated.
+	f.sent = nil
+	drvDMA.dmaBufAllocator = func(s int) (pmem.Mem, error) {
+		return nil, errors.New("oops")
+	}
+	if err := c.Tx(w, r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.sent, w) {
+		t.Fatal(f.sent)
+	}
+}
+
+func TestSPI_timeout(t *testing.T) {
+	defer reset()
+	setupSPI(t)
+	s, err := newSPI(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nothing is ever received.
+	s.conn.fifo = &fakeFIFO{g: &spiR8Group{}}
+	c, err := s.Connect(0, spi.Mode0, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Tx([]byte{1}, nil) == nil {
+		t.Fatal("expected timeout")
+	}
+	if drvSPI.spiMemory[2].(*spiR8Group).ctl&spiR8CSLevel == 0 {
+		t.Fatal("CS must be deasserted on error")
+	}
+}
+
+func TestSPI_A64(t *testing.T) {
+	defer reset()
+	if err := mapA64Pins(); err != nil {
+		t.Fatal(err)
+	}
+	drvGPIO.gpioMemory = &gpioMap{}
+	drvSPI.clockMemory = &clockMap{}
+	drvSPI.baseAddr = spiA64BaseAddr[:]
+	drvSPI.softReset = true
+	g := &spiA64Group{}
+	drvSPI.spiMemory[0] = g
+	drvSPI.spiMemory[1] = &spiA64Group{}
+	// The DMA controller is never used.
+	drvDMA.dmaMemory = &dmaMap{}
+	f := &fakeFIFO{g: g, loopback: true}
+	s, err := newSPI(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.conn.fifo = f
+	c, err := s.Connect(physic.MegaHertz, spi.Mode1, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, fn := range map[*Pin]string{PC0: "SPI0_MOSI", PC1: "SPI0_MISO", PC2: "SPI0_CLK", PC3: "SPI0_CS0"} {
+		if p.Function() != fn {
+			t.Fatal(p, p.Function())
+		}
+	}
+	if m := drvSPI.clockMemory; m.ahbGating0 != 1<<20 || m.busSoftRst0 != 1<<20 || m.spiClk[0] != clockSPIEnable {
+		t.Fatal("clock not enabled")
+	}
+	if g.globalCtl != spiA64TransmitPause|spiA64Master|spiA64Enable {
+		t.Fatalf("0x%x", g.globalCtl)
+	}
+	if exp := spiA64PHA | spiA64CSManual | spiA64CSLevel | spiA64CSActiveLow; g.transferCtl != exp {
+		t.Fatalf("0x%x", g.transferCtl)
+	}
+
+	w := bytes.Repeat([]byte{0x55}, 100)
+	r := make([]byte, len(w))
+	if err := c.Tx(w, r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.sent, w) || !bytes.Equal(r, bytes.Repeat([]byte{0xAA}, 100)) {
+		t.Fatal(f.sent, r)
+	}
+	if g.clockCtl != spiR8DivRateSelect2|11 || g.burstCounter != 100 || g.txCounter != 100 || g.burstCtl != 100 {
+		t.Fatal(g.clockCtl, g.burstCounter, g.txCounter, g.burstCtl)
+	}
+	if g.fifoCtl != spiA64RXFIFOReset|spiA64TXFIFOReset || g.transferCtl&spiA64ExchangeBurst == 0 || g.status != spiA64TC {
+		t.Fatal("unexpected burst")
+	}
+	if g.transferCtl&spiA64CSLevel == 0 || f.cs != 100 {
+		t.Fatal("CS must be asserted during the transfer only")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if g.globalCtl&spiA64Enable != 0 {
+		t.Fatal("controller must be disabled")
+	}
+}
+
+func TestSPIClock(t *testing.T) {
+	data := []struct {
+		f   physic.Frequency
+		ctl spiR8ClockCtl
+		out physic.Frequency
+	}{
+		{100 * physic.MegaHertz, spiR8DivRateSelect2, 12 * physic.MegaHertz},
+		{12 * physic.MegaHertz, spiR8DivRateSelect2, 12 * physic.MegaHertz},
+		{physic.MegaHertz, spiR8DivRateSelect2 | 11, physic.MegaHertz},
+		{900 * physic.KiloHertz, spiR8DivRateSelect2 | 13, 24 * physic.MegaHertz / 28},
+		{10 * physic.KiloHertz, spiR8Div4096, 24 * physic.MegaHertz / 4096},
+		{400 * physic.Hertz, spiR8Div65536, spiMinFreq},
+	}
+	for i, line := range data {
+		ctl, out := spiClock(line.f)
+		if ctl != line.ctl || out != line.out {
+			t.Fatalf("#%d: %s: 0x%x %s", i, line.f, ctl, out)
+		}
+		if out > line.f {
+			t.Fatalf("#%d: %s > %s", i, out, line.f)
+		}
+	}
+}
+
+func TestDriverSPI(t *testing.T) {
+	defer reset()
+	setupSPI(t)
+	if drvSPI.String() != "allwinner-spi" || len(drvSPI.Prerequisites()) != 1 || len(drvSPI.After()) != 2 {
+		t.Fatal("unexpected driver")
+	}
+	// Pretend another port already claimed the number 0.
+	if err := spireg.Register("fake", nil, 0, func() (spi.PortCloser, error) { return nil, errors.New("oops") }); err != nil {
+		t.Fatal(err)
+	}
+	defer spireg.Unregister("fake")
+	// Ports enabled in the device tree are owned by spidev.
+	spiDT = func() (*distro.DeviceTree, error) {
+		return &distro.DeviceTree{Root: &distro.DTNode{
+			Children: []*distro.DTNode{
+				{
+					Name: "spi@1c17000",
+					Properties: map[string][]byte{
+						"compatible": []byte("allwinner,sun4i-a10-spi\x00"),
+						"reg":        {0x01, 0xC1, 0x70, 0x00, 0x00, 0x00, 0x10, 0x00},
+						"status":     []byte("okay\x00"),
+					},
+				},
+				{
+					Name: "spi@1c06000",
+					Properties: map[string][]byte{
+						"compatible": []byte("allwinner,sun4i-a10-spi\x00"),
+						"reg":        {0x01, 0xC0, 0x60, 0x00, 0x00, 0x00, 0x10, 0x00},
+						"status":     []byte("disabled\x00"),
+					},
+				},
+			},
+		}}, nil
+	}
+	if err := drvSPI.register(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := spireg.Open("allwinner-spi2"); err == nil {
+		t.Fatal("spi2 is owned by the kernel")
+	}
+	for _, ref := range spireg.All() {
+		switch ref.Name {
+		case "allwinner-spi0":
+			if ref.Number != -1 {
+				t.Fatal(ref)
+			}
+		case "allwinner-spi1":
+			if ref.Number != 1 {
+				t.Fatal(ref)
+			}
+		}
+	}
+	p, err := spireg.Open("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.String() != "allwinner-spi1" {
+		t.Fatal(p)
+	}
+	if err := drvSPI.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := spireg.Open("allwinner-spi1"); err == nil {
+		t.Fatal("port must have been unregistered")
+	}
+	if _, err := newSPI(1); err == nil {
+		t.Fatal("driver is closed")
+	}
+}
+
+//
+
+// setupSPI emulates an initialized R8 with fake registers.
+func setupSPI(t *testing.T) *fakeFIFO {
+	if err := mapR8Pins(); err != nil {
+		t.Fatal(err)
+	}
+	drvGPIO.gpioMemory = &gpioMap{}
+	drvSPI.clockMemory = &clockMap{}
+	drvSPI.baseAddr = spiR8BaseAddr[:]
+	for i := range drvSPI.spiMemory {
+		drvSPI.spiMemory[i] = &spiR8Group{}
+	}
+	return &fakeFIFO{g: drvSPI.spiMemory[2], loopback: true}
+}
+
+// fakeFIFO emulates a device that replies with the complement of each byte
+// as soon as it is sent.
+type fakeFIFO struct {
+	g        spiRegs
+	loopback bool
+	sent     []byte
+	rx       []byte
+	cs       int // Number of bytes sent while CS was asserted.
+}
+
+func (f *fakeFIFO) push(b byte) {
+	f.sent = append(f.sent, b)
+	switch g := f.g.(type) {
+	case *spiR8Group:
+		if g.ctl&spiR8CSLevel == 0 {
+			f.cs++
+		}
+	case *spiA64Group:
+		if g.transferCtl&spiA64CSLevel == 0 {
+			f.cs++
+		}
+	}
+	if f.loopback {
+		f.rx = append(f.rx, ^b)
+	}
+	f.update()
+}
+
+func (f *fakeFIFO) pop() byte {
+	b := f.rx[0]
+	f.rx = f.rx[1:]
+	f.update()
+	return b
+}
+
+// update sets the RX FIFO level.
+func (f *fakeFIFO) update() {
+	switch g := f.g.(type) {
+	case *spiR8Group:
+		g.fifoStatus = spiR8FIFOStatus(len(f.rx))
+	case *spiA64Group:
+		g.fifoStatus = spiA64FIFOStatus(len(f.rx))
+	}
+}
+
+type fakeMem struct {
+	pmem.Slice
+	closed bool
+}
+
+func (f *fakeMem) PhysAddr() uint64 {
+	return 0x10000
+}
+
+func (f *fakeMem) Close() error {
+	f.closed = true
+	return nil
+}
+
+func reset() {
+	for _, p := range cpupins {
+		p.altFunc = [5]pin.Func{}
+		p.available = false
+		p.sysfsPin = nil
+		p.usingPWM = false
+	}
+	drvGPIO.gpioMemory = nil
+	drvDMA.dmaMemory = nil
+	drvDMA.dmaBufAllocator = nil
+	drvPWM.pwmMemory = nil
+	drvSPI.clockMemory = nil
+	drvSPI.spiMemory = [3]spiRegs{}
+	drvSPI.baseAddr = nil
+	drvSPI.softReset = false
+	drvSPI.ports = nil
+	spiDT = distro.DT
+}
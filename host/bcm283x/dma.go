@@ -535,6 +535,11 @@ func (d *dmaChannel) wait() error {
 	// Spin until the the bit is reset, to release the DMA controller channel.
 	for d.cs&dmaActive != 0 && d.debug&(dmaReadError|dmaFIFOError|dmaReadLastNotSetError) == 0 {
 	}
+	return d.check()
+}
+
+// check returns the error reported by the DMA channel, if any.
+func (d *dmaChannel) check() error {
 	if d.debug&dmaReadError != 0 {
 		return errors.New("DMA read error")
 	}
@@ -1125,10 +1130,24 @@ type driverDMA struct {
 
 	// dmaBufAllocator is overriden for unit testing.
 	dmaBufAllocator func(s int) (*videocore.Mem, error) // Set to videocore.Alloc
+
+	// views are the memory mappings done by Init().
+	views []*pmem.View
 }
 
+// Close stops the DMA driven PWM clock if it was started and releases the
+// memory mappings.
 func (d *driverDMA) Close() error {
-	// TODO(maruel): Stop DMA and PWM controllers.
+	var err error
+	if d.pwmDMACh != nil && d.clockMemory != nil {
+		err = resetPWMClockSource()
+	}
+	for _, v := range d.views {
+		if err2 := v.Close(); err2 != nil && err == nil {
+			err = err2
+		}
+	}
+	d.views = nil
 	d.pcmBaseAddr = 0
 	d.pwmBaseAddr = 0
 	d.dmaMemory = nil
@@ -1137,12 +1156,13 @@ func (d *driverDMA) Close() error {
 	d.clockMemory = nil
 	d.timerMemory = nil
 	d.pwmMemory = nil
+	d.gpioPadMemory = nil
 	d.pwmBaseFreq = 0
 	d.pwmDMAFreq = 0
 	d.pwmDMACh = nil
 	d.pwmDMABuf = nil
 	d.dmaBufAllocator = nil
-	return nil
+	return err
 }
 
 func (d *driverDMA) String() string {
@@ -1162,7 +1182,7 @@ func (d *driverDMA) Init() (bool, error) {
 	d.pwmBaseFreq = 25 * physic.MegaHertz
 	d.pwmDMAFreq = 200 * physic.KiloHertz
 	// baseAddr is initialized by prerequisite driver bcm283x-gpio.
-	if err := pmem.MapAsPOD(uint64(drvGPIO.baseAddr+0x7000), &d.dmaMemory); err != nil {
+	if err := d.mapAsPOD(uint64(drvGPIO.baseAddr+0x7000), &d.dmaMemory); err != nil {
 		if os.IsPermission(err) {
 			return true, fmt.Errorf("need more access, try as root: %v", err)
 		}
@@ -1170,30 +1190,41 @@ func (d *driverDMA) Init() (bool, error) {
 	}
 	// Channel #15 is "physically removed from the other DMA Channels so it has a
 	// different address base".
-	if err := pmem.MapAsPOD(uint64(drvGPIO.baseAddr+0xE05000), &d.dmaChannel15); err != nil {
+	if err := d.mapAsPOD(uint64(drvGPIO.baseAddr+0xE05000), &d.dmaChannel15); err != nil {
 		return true, err
 	}
 	d.pcmBaseAddr = drvGPIO.baseAddr + 0x203000
-	if err := pmem.MapAsPOD(uint64(d.pcmBaseAddr), &d.pcmMemory); err != nil {
+	if err := d.mapAsPOD(uint64(d.pcmBaseAddr), &d.pcmMemory); err != nil {
 		return true, err
 	}
 	d.pwmBaseAddr = drvGPIO.baseAddr + 0x20C000
-	if err := pmem.MapAsPOD(uint64(d.pwmBaseAddr), &d.pwmMemory); err != nil {
+	if err := d.mapAsPOD(uint64(d.pwmBaseAddr), &d.pwmMemory); err != nil {
 		return true, err
 	}
-	if err := pmem.MapAsPOD(uint64(drvGPIO.baseAddr+0x101000), &d.clockMemory); err != nil {
+	if err := d.mapAsPOD(uint64(drvGPIO.baseAddr+0x101000), &d.clockMemory); err != nil {
 		return true, err
 	}
-	if err := pmem.MapAsPOD(uint64(drvGPIO.baseAddr+0x3000), &d.timerMemory); err != nil {
+	if err := d.mapAsPOD(uint64(drvGPIO.baseAddr+0x3000), &d.timerMemory); err != nil {
 		return true, err
 	}
-	if err := pmem.MapAsPOD(uint64(drvGPIO.baseAddr+0x100000), &d.gpioPadMemory); err != nil {
+	if err := d.mapAsPOD(uint64(drvGPIO.baseAddr+0x100000), &d.gpioPadMemory); err != nil {
 		return true, err
 	}
 	// Do not run smokeTest() unless it's clear it is not dangerous.
 	return true, nil
 }
 
+// mapAsPOD maps the memory at base as i and keeps track of the mapping to
+// release it in Close().
+func (d *driverDMA) mapAsPOD(base uint64, i interface{}) error {
+	v, err := pmem.MapAsPODView(base, i)
+	if err != nil {
+		return err
+	}
+	d.views = append(d.views, v)
+	return nil
+}
+
 func debugDMA() {
 	for i, ch := range drvDMA.dmaMemory.channels {
 		log.Println(i, ch.cs.String())
@@ -555,10 +555,19 @@ func (p *Pin) FastOut(l gpio.Level) {
 // Furthermore, these can only be used if the drive "bcm283x-dma" was loaded.
 // It can only be loaded if the process has root level access.
 //
+// Without access to /dev/gpiomem, the kernel's PWM driver is used if it is
+// configured for this pin; see sysfs.PWMByPin().
+//
 // The user must call either Halt(), In(), Out(), PWM(0,..) or
 // PWM(gpio.DutyMax,..) to stop the clock source and DMA engine before exiting
 // the program.
 func (p *Pin) PWM(duty gpio.Duty, freq physic.Frequency) error {
+	if drvGPIO.gpioMemory == nil || drvDMA.pwmMemory == nil || drvDMA.clockMemory == nil {
+		// Fallback to the kernel's PWM driver, if configured for this pin.
+		if pw := sysfs.PWMByPin(p.name); pw != nil {
+			return pw.PWM(duty, freq)
+		}
+	}
 	if duty == 0 {
 		return p.Out(gpio.Low)
 	} else if duty == gpio.DutyMax {
@@ -1188,13 +1197,46 @@ type driverGPIO struct {
 	gpioMemory *gpioMap
 	// gpioBaseAddr is needed for DMA transfers.
 	gpioBaseAddr uint32
+	// gpioView is the mapping of gpioMemory when done via /dev/mem. The mapping
+	// via /dev/gpiomem is cached by pmem so it is never closed.
+	gpioView *pmem.View
+	// aliases are the function aliases registered by Init().
+	aliases []string
+	// hooked is true when the I²C speed hook was set.
+	hooked bool
 }
 
-func (d *driverGPIO) Close() {
+// Close unregisters the pins and their aliases and releases the memory
+// mapping.
+//
+// It doesn't restore the pins registered by sysfs-gpio.
+func (d *driverGPIO) Close() error {
+	for i := range cpuPins {
+		// Only unregister the pin if it was registered by this driver.
+		if gpioreg.ByName(cpuPins[i].name) == gpio.PinIO(&cpuPins[i]) {
+			_ = gpioreg.Unregister(cpuPins[i].name)
+			_ = gpioreg.Unregister(strconv.Itoa(cpuPins[i].number))
+		}
+		cpuPins[i].sysfsPin = nil
+	}
+	for _, a := range d.aliases {
+		_ = gpioreg.Unregister(a)
+	}
+	d.aliases = nil
+	if d.hooked {
+		sysfs.I2CClearSpeedHook()
+		d.hooked = false
+	}
+	var err error
+	if d.gpioView != nil {
+		err = d.gpioView.Close()
+		d.gpioView = nil
+	}
 	d.baseAddr = 0
 	d.dramBus = 0
 	d.gpioMemory = nil
 	d.gpioBaseAddr = 0
+	return err
 }
 
 func (d *driverGPIO) String() string {
@@ -1265,6 +1307,7 @@ func (d *driverGPIO) Init() (bool, error) {
 				if err := gpioreg.RegisterAlias(string(f), name); err != nil {
 					return true, err
 				}
+				d.aliases = append(d.aliases, string(f))
 			}
 		}
 	}
@@ -1280,6 +1323,7 @@ func (d *driverGPIO) Init() (bool, error) {
 					if err := gpioreg.RegisterAlias(string(f), cpuPins[i].name); err != nil {
 						return true, err
 					}
+					d.aliases = append(d.aliases, string(f))
 				}
 			}
 		}
@@ -1298,6 +1342,7 @@ func (d *driverGPIO) Init() (bool, error) {
 		if err := gpioreg.RegisterAlias(a[0], a[1]); err != nil {
 			return true, err
 		}
+		d.aliases = append(d.aliases, a[0])
 	}
 
 	m, err := pmem.MapGPIO()
@@ -1320,12 +1365,17 @@ func (d *driverGPIO) Init() (bool, error) {
 			}
 			return true, err
 		}
+		d.gpioView = m
 	}
 	if err := m.AsPOD(&d.gpioMemory); err != nil {
 		return true, err
 	}
 
-	return true, sysfs.I2CSetSpeedHook(setSpeed)
+	if err := sysfs.I2CSetSpeedHook(setSpeed); err != nil {
+		return true, err
+	}
+	d.hooked = true
+	return true, nil
 }
 
 func setSpeed(f physic.Frequency) error {
@@ -0,0 +1,598 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package bcm283x
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/i2s"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/pin"
+	"periph.io/x/periph/host/videocore"
+)
+
+// NewI2S returns an handle to the CPU's PCM block exposed as an I²S port.
+//
+// The port uses GPIO18 (SCK), GPIO19 (WS), GPIO20 (DIN) and GPIO21 (DOUT).
+// The samples are streamed via DMA so this requires the bcm283x-dma driver to
+// be initialized, which generally requires running as root.
+//
+// Only one handle can be opened at a time. It also conflicts with the sound
+// driver of the kernel and with StreamOut() on GPIO21.
+func NewI2S() (*I2S, error) {
+	if drvDMA.pcmMemory == nil || drvDMA.dmaMemory == nil || drvDMA.clockMemory == nil {
+		return nil, errors.New("bcm283x-i2s: bcm283x-dma not initialized; try again as root?")
+	}
+	i2sMu.Lock()
+	defer i2sMu.Unlock()
+	if i2sOpened {
+		return nil, errors.New("bcm283x-i2s: already opened")
+	}
+	i2sOpened = true
+	return &I2S{}, nil
+}
+
+// I2S is the PCM block of the CPU used as an I²S port.
+//
+// It implements i2s.PortCloser.
+type I2S struct {
+	mu        sync.Mutex
+	closed    bool
+	connected bool
+	f         physic.Frequency
+	bits      int
+	channels  int
+	mode      i2s.Mode
+	stream    i2sStream
+}
+
+func (i *I2S) String() string {
+	return "I2S0"
+}
+
+// Close implements i2s.PortCloser.
+//
+// It waits for the samples queued by TxSamples() to be sent, stops the PCM
+// block and its clock, and releases the handle.
+func (i *I2S) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.closed {
+		return errors.New("bcm283x-i2s: already closed")
+	}
+	i.closed = true
+	err := i.stream.drain(i.timeout())
+	if err2 := i.stream.close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		err = fmt.Errorf("bcm283x-i2s: %v", err)
+	}
+	if drvDMA.pcmMemory != nil {
+		drvDMA.pcmMemory.reset()
+	}
+	if i.connected && i.mode == i2s.Master && drvDMA.clockMemory != nil {
+		if _, _, err2 := drvDMA.clockMemory.pcm.set(0, 1); err == nil {
+			err = err2
+		}
+	}
+	i2sMu.Lock()
+	i2sOpened = false
+	i2sMu.Unlock()
+	return err
+}
+
+// Connect implements i2s.Port.
+//
+// bits must be between 8 and 32. In Master mode, the bit clock f*bits*2 must
+// be attainable exactly from one of the clock sources.
+func (i *I2S) Connect(f physic.Frequency, bits, channels int, mode i2s.Mode) (i2s.Conn, error) {
+	if bits < 8 || bits > 32 {
+		return nil, fmt.Errorf("bcm283x-i2s: invalid bits %d; must be between 8 and 32", bits)
+	}
+	if channels != 1 && channels != 2 {
+		return nil, fmt.Errorf("bcm283x-i2s: invalid channels %d; must be 1 or 2", channels)
+	}
+	if mode != i2s.Master && mode != i2s.Slave {
+		return nil, fmt.Errorf("bcm283x-i2s: invalid mode %s", mode)
+	}
+	if f <= 0 {
+		return nil, fmt.Errorf("bcm283x-i2s: invalid sample rate %s", f)
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.closed {
+		return nil, errors.New("bcm283x-i2s: already closed")
+	}
+	if i.connected {
+		return nil, errors.New("bcm283x-i2s: already connected")
+	}
+	drvDMA.pcmMemory.reset()
+	if mode == i2s.Master {
+		if err := setI2SClock(f * physic.Frequency(2*bits)); err != nil {
+			return nil, fmt.Errorf("bcm283x-i2s: %v", err)
+		}
+	}
+	if err := setI2SPins(); err != nil {
+		return nil, err
+	}
+	drvDMA.pcmMemory.setI2S(bits, channels, mode == i2s.Slave)
+	i.connected = true
+	i.f = f
+	i.bits = bits
+	i.channels = channels
+	i.mode = mode
+	return i, nil
+}
+
+// Duplex implements conn.Conn.
+func (i *I2S) Duplex() conn.Duplex {
+	return conn.Full
+}
+
+// Tx implements conn.Conn.
+//
+// The samples are encoded as described in i2s.Encode().
+func (i *I2S) Tx(w, r []byte) error {
+	i.mu.Lock()
+	bits := i.bits
+	i.mu.Unlock()
+	n := (bits + 7) / 8
+	if n == 0 {
+		return errors.New("bcm283x-i2s: not connected")
+	}
+	if len(w)%n != 0 || len(r)%n != 0 {
+		return fmt.Errorf("bcm283x-i2s: buffers must be a multiple of %d bytes", n)
+	}
+	var ws, rs []int32
+	if len(w) != 0 {
+		ws = make([]int32, len(w)/n)
+		i2s.Decode(ws, w, bits)
+	}
+	if len(r) != 0 {
+		rs = make([]int32, len(r)/n)
+	}
+	if err := i.TxSamples(ws, rs); err != nil {
+		return err
+	}
+	if len(r) != 0 {
+		i2s.Encode(r, rs, bits)
+	}
+	return nil
+}
+
+// TxSamples implements i2s.Conn.
+//
+// The PCM block keeps running between calls. The samples in w are queued in
+// DMA buffers and TxSamples() returns as soon as the last ones are queued,
+// while the samples received are buffered until the next call. The stream
+// continues without gap as long as TxSamples() is called again before the
+// buffers, which hold about 20ms at 48kHz stereo, are exhausted.
+func (i *I2S) TxSamples(w, r []int32) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.closed {
+		return errors.New("bcm283x-i2s: already closed")
+	}
+	if !i.connected {
+		return errors.New("bcm283x-i2s: not connected")
+	}
+	if len(w) != 0 && len(r) != 0 && len(w) != len(r) {
+		return errors.New("bcm283x-i2s: w and r must have the same length")
+	}
+	l := len(w)
+	if l == 0 {
+		l = len(r)
+	}
+	if l == 0 {
+		return nil
+	}
+	if l%i.channels != 0 {
+		return fmt.Errorf("bcm283x-i2s: the number of samples must be a multiple of %d", i.channels)
+	}
+	if err := i.stream.tx(w, r, i.timeout()); err != nil {
+		return fmt.Errorf("bcm283x-i2s: %v", err)
+	}
+	return nil
+}
+
+// SCK implements i2s.Pins.
+func (i *I2S) SCK() gpio.PinIO {
+	return GPIO18
+}
+
+// WS implements i2s.Pins.
+func (i *I2S) WS() gpio.PinIO {
+	return GPIO19
+}
+
+// IN implements i2s.Pins.
+func (i *I2S) IN() gpio.PinIn {
+	return GPIO20
+}
+
+// OUT implements i2s.Pins.
+func (i *I2S) OUT() gpio.PinOut {
+	return GPIO21
+}
+
+//
+
+var (
+	i2sMu     sync.Mutex
+	i2sOpened bool
+)
+
+// setI2SClock sets the PCM clock to the closest frequency to f attainable
+// with an integer divisor.
+//
+// Unlike setPCMClockSource(), it doesn't oversample since the bit clock must
+// be an exact multiple of the sample rate. Common sample rates like 44.1kHz
+// can't be generated exactly so the closest one within 1% is accepted.
+func setI2SClock(f physic.Frequency) error {
+	if drvDMA.clockMemory == nil {
+		return errors.New("subsystem Clock not initialized")
+	}
+	var ctl clockCtl
+	var div uint32
+	var diff physic.Frequency = -1
+	for _, s := range []struct {
+		f   physic.Frequency
+		ctl clockCtl
+	}{{clk19dot2MHz, clockSrc19dot2MHz}, {clk500MHz, clockSrcPLLD}} {
+		d := uint32((s.f + f/2) / f)
+		if d < 1 || d > clockDiviMax {
+			continue
+		}
+		delta := s.f/physic.Frequency(d) - f
+		if delta < 0 {
+			delta = -delta
+		}
+		if diff == -1 || delta < diff {
+			ctl, div, diff = s.ctl, d, delta
+		}
+	}
+	if diff == -1 || diff*100 > f {
+		return fmt.Errorf("can't generate bit clock %s", f)
+	}
+	return drvDMA.clockMemory.pcm.setRaw(ctl, div)
+}
+
+// setI2SPins sets GPIO18~GPIO21 to their I²S function.
+func setI2SPins() error {
+	pins := [...]*Pin{GPIO18, GPIO19, GPIO20, GPIO21}
+	funcs := [...]pin.Func{i2s.SCK, i2s.WS, i2s.IN, i2s.OUT}
+	for j, p := range pins {
+		if err := p.SetFunc(funcs[j]); err != nil {
+			return fmt.Errorf("bcm283x-i2s: %v", err)
+		}
+	}
+	return nil
+}
+
+// timeout returns the maximum time to wait for a DMA buffer to be
+// transferred.
+//
+// The lock must be held.
+func (i *I2S) timeout() time.Duration {
+	if i.f == 0 {
+		return 0
+	}
+	return i2sSlots*i2sSlotSamples*i.f.Duration()/time.Duration(i.channels) + 100*time.Millisecond
+}
+
+const (
+	// i2sSlotSamples is the number of samples in each DMA buffer.
+	i2sSlotSamples = 1024
+	// i2sSlots is the number of DMA buffers per direction, so that one is
+	// filled or read while the other one is transferred.
+	i2sSlots = 2
+)
+
+// i2sStream is the continuous transfer to and from the PCM FIFO.
+//
+// Each direction has its own DMA channel, whose control blocks are chained as
+// the buffers are queued.
+type i2sStream struct {
+	cbMem   *videocore.Mem
+	cb      []controlBlock // i2sSlots for TX followed by i2sSlots for RX
+	w       i2sQueue
+	r       i2sQueue
+	started bool // PCM block started
+}
+
+// tx queues w and reads r, waiting up to timeout for each buffer.
+func (s *i2sStream) tx(w, r []int32, timeout time.Duration) error {
+	if s.cbMem == nil {
+		var err error
+		if s.cb, s.cbMem, err = allocateCB(4096); err != nil {
+			return err
+		}
+	}
+	if len(w) != 0 && s.w.ch == nil {
+		if err := s.w.init(s.cb[:i2sSlots], uint32(s.cbMem.PhysAddr()), true, &s.r); err != nil {
+			return err
+		}
+	}
+	if len(r) != 0 && s.r.ch == nil {
+		if err := s.r.init(s.cb[i2sSlots:2*i2sSlots], uint32(s.cbMem.PhysAddr())+32*i2sSlots, false, &s.w); err != nil {
+			return err
+		}
+		// Keep all the buffers queued to receive continuously. The RX DMA
+		// channel must be running before the PCM starts to not miss the first
+		// samples.
+		for s.r.count < i2sSlots {
+			if err := s.r.push(nil); err != nil {
+				return err
+			}
+		}
+		s.start(false, true)
+	}
+	// Interleave both directions since they run at the same rate.
+	for len(w) != 0 || len(r) != 0 {
+		if len(w) != 0 {
+			n := len(w)
+			if n > i2sSlotSamples {
+				n = i2sSlotSamples
+			}
+			if err := s.w.wait(func() bool { return s.w.count < i2sSlots }, timeout); err != nil {
+				return err
+			}
+			// The TX DMA channel prefills the FIFO before the PCM starts.
+			if err := s.w.push(w[:n]); err != nil {
+				return err
+			}
+			s.start(true, false)
+			w = w[n:]
+		}
+		if len(r) != 0 {
+			n, err := s.r.pop(r, timeout)
+			if err != nil {
+				return err
+			}
+			r = r[n:]
+		}
+	}
+	return nil
+}
+
+// start enables the PCM block for the direction that was just queued.
+func (s *i2sStream) start(tx, rx bool) {
+	if !s.started {
+		drvDMA.pcmMemory.start(tx, rx)
+		s.started = true
+		return
+	}
+	if tx {
+		drvDMA.pcmMemory.cs |= pcmTXEnable
+	}
+	if rx {
+		drvDMA.pcmMemory.cs |= pcmRXEnable
+	}
+}
+
+// drain waits for the queued samples to be sent.
+func (s *i2sStream) drain(timeout time.Duration) error {
+	if s.w.ch == nil {
+		return nil
+	}
+	if err := s.w.wait(func() bool { return s.w.count == 0 }, timeout); err != nil {
+		return err
+	}
+	// Wait for the PCM FIFO to be drained even after the DMA is done.
+	for start := time.Now(); drvDMA.pcmMemory.cs&pcmTXEmpty == 0; {
+		if time.Since(start) > timeout {
+			return errors.New("timed out waiting for the PCM FIFO to be drained")
+		}
+		Nanospin(10 * time.Microsecond)
+	}
+	return nil
+}
+
+// close stops the DMA channels and releases the buffers.
+func (s *i2sStream) close() error {
+	if drvDMA.pcmMemory != nil {
+		drvDMA.pcmMemory.cs &^= pcmTXEnable | pcmRXEnable | pcmDMAEnable
+	}
+	err := s.w.close()
+	if err2 := s.r.close(); err == nil {
+		err = err2
+	}
+	if s.cbMem != nil {
+		if err2 := s.cbMem.Close(); err == nil {
+			err = err2
+		}
+		s.cbMem = nil
+		s.cb = nil
+	}
+	s.started = false
+	return err
+}
+
+// i2sQueue is the ring of DMA buffers of one direction.
+//
+// Starting at head, the first done buffers were transferred and the next
+// count ones are queued in the DMA channel. A TX buffer is reused as soon as
+// it is transferred, while a RX one is kept until it is entirely read.
+type i2sQueue struct {
+	ch    *dmaChannel
+	n     int // DMA channel number.
+	buf   *videocore.Mem
+	cb    []controlBlock
+	pCB   uint32 // Physical address of cb[0].
+	tx    bool
+	head  int
+	done  int
+	count int
+	off   int // Number of samples already read from the head buffer.
+}
+
+// init allocates the buffers and picks a DMA channel other than the one of
+// other.
+func (q *i2sQueue) init(cb []controlBlock, pCB uint32, tx bool, other *i2sQueue) error {
+	var err error
+	if q.buf, err = drvDMA.dmaBufAllocator(i2sSlots * i2sSlotSamples * 4); err != nil {
+		return err
+	}
+	var blacklist []int
+	if other.ch != nil {
+		blacklist = []int{other.n}
+	}
+	if q.n, q.ch = pickChannel(blacklist...); q.ch == nil {
+		_ = q.buf.Close()
+		q.buf = nil
+		return errors.New("bcm283x-dma: no channel available")
+	}
+	q.cb = cb
+	q.pCB = pCB
+	q.tx = tx
+	return nil
+}
+
+// push queues the next buffer and chains it in the DMA channel.
+//
+// For TX, the buffer is filled with w. For RX, it is filled by the DMA.
+func (q *i2sQueue) push(w []int32) error {
+	slot := (q.head + q.done + q.count) % i2sSlots
+	u := q.buf.Uint32()[slot*i2sSlotSamples : (slot+1)*i2sSlotSamples]
+	addr := uint32(q.buf.PhysAddr()) + uint32(4*slot*i2sSlotSamples)
+	fifo := drvDMA.pcmBaseAddr + 0x4 // pcmMap.fifo
+	var err error
+	if q.tx {
+		for j, v := range w {
+			u[j] = uint32(v)
+		}
+		err = q.cb[slot].initBlock(addr, fifo, uint32(4*len(w)), false, true, true, false, dmaPCMTX)
+	} else {
+		err = q.cb[slot].initBlock(fifo, addr, uint32(4*len(u)), true, false, false, true, dmaPCMRX)
+	}
+	if err != nil {
+		return err
+	}
+	cbAddr := q.pCB + uint32(32*slot)
+	q.count++
+	if q.count > 1 {
+		// In case the channel didn't load the previous control block yet.
+		prev := (slot + i2sSlots - 1) % i2sSlots
+		q.cb[prev].nextCB = cbAddr
+		if q.ch.cs&dmaActive != 0 {
+			// The channel copies nextCB when it loads a control block, and it
+			// can only be modified while the channel is paused.
+			q.ch.cs &^= dmaActive
+			if q.ch.cbAddr == q.pCB+uint32(32*prev) {
+				q.ch.nextCB = cbAddr
+			}
+			if q.ch.cbAddr != 0 {
+				q.ch.cs |= dmaActive
+				return nil
+			}
+		}
+	}
+	// The channel is idle.
+	q.ch.reset()
+	q.ch.startIO(cbAddr)
+	return nil
+}
+
+// pop reads the received samples into r and queues back the buffer once it
+// is entirely read.
+//
+// It returns the number of samples read.
+func (q *i2sQueue) pop(r []int32, timeout time.Duration) (int, error) {
+	if err := q.wait(func() bool { return q.done != 0 }, timeout); err != nil {
+		return 0, err
+	}
+	u := q.buf.Uint32()[q.head*i2sSlotSamples : (q.head+1)*i2sSlotSamples]
+	n := copy32(r, u[q.off:])
+	q.off += n
+	if q.off == len(u) {
+		q.head = (q.head + 1) % i2sSlots
+		q.done--
+		q.off = 0
+		if err := q.push(nil); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// wait waits for cond to be true, updating the state of the queue from the
+// DMA channel.
+func (q *i2sQueue) wait(cond func() bool, timeout time.Duration) error {
+	start := time.Now()
+	for {
+		if err := q.update(); err != nil {
+			return err
+		}
+		if cond() {
+			return nil
+		}
+		if time.Since(start) > timeout {
+			return errors.New("timed out waiting for DMA")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// update retires the queued buffers that the DMA channel is done with.
+func (q *i2sQueue) update() error {
+	if err := q.ch.check(); err != nil {
+		return err
+	}
+	first := q.head + q.done
+	n := q.count
+	for j := 0; j < q.count; j++ {
+		if q.ch.cbAddr == q.pCB+uint32(32*((first+j)%i2sSlots)) {
+			n = j
+			break
+		}
+	}
+	q.count -= n
+	if q.tx {
+		q.head = (q.head + n) % i2sSlots
+	} else {
+		q.done += n
+	}
+	return nil
+}
+
+// close stops the DMA channel and releases the buffers.
+func (q *i2sQueue) close() error {
+	if q.ch != nil {
+		q.ch.reset()
+		q.ch = nil
+	}
+	var err error
+	if q.buf != nil {
+		err = q.buf.Close()
+		q.buf = nil
+	}
+	q.head = 0
+	q.done = 0
+	q.count = 0
+	q.off = 0
+	return err
+}
+
+// copy32 copies the samples received in src to dst.
+func copy32(dst []int32, src []uint32) int {
+	n := len(src)
+	if len(dst) < n {
+		n = len(dst)
+	}
+	for j := 0; j < n; j++ {
+		dst[j] = int32(src[j])
+	}
+	return n
+}
+
+var _ i2s.Conn = &I2S{}
+var _ i2s.Pins = &I2S{}
+var _ i2s.PortCloser = &I2S{}
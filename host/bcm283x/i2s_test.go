@@ -0,0 +1,218 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package bcm283x
+
+import (
+	"testing"
+
+	"periph.io/x/periph/conn/i2s"
+	"periph.io/x/periph/conn/physic"
+)
+
+func TestNewI2S(t *testing.T) {
+	defer reset()
+	if _, err := NewI2S(); err == nil || err.Error() != "bcm283x-i2s: bcm283x-dma not initialized; try again as root?" {
+		t.Fatal(err)
+	}
+	setI2SMemory()
+	p, err := NewI2S()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewI2S(); err == nil {
+		t.Fatal("already opened")
+	}
+	if s := p.String(); s != "I2S0" {
+		t.Fatal(s)
+	}
+	if p.SCK() != GPIO18 || p.WS() != GPIO19 || p.IN() != GPIO20 || p.OUT() != GPIO21 {
+		t.Fatal("unexpected pins")
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err == nil {
+		t.Fatal("already closed")
+	}
+	if _, err := p.Connect(48*physic.KiloHertz, 16, 2, i2s.Master); err == nil {
+		t.Fatal("already closed")
+	}
+	if p, err = NewI2S(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestI2S_Connect(t *testing.T) {
+	defer reset()
+	oldErrClockRegister := errClockRegister
+	errClockRegister = nil
+	defer func() {
+		errClockRegister = oldErrClockRegister
+	}()
+	setI2SMemory()
+	p, err := NewI2S()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := p.TxSamples(make([]int32, 2), nil); err == nil {
+		t.Fatal("not connected")
+	}
+	if err := p.Tx(make([]byte, 2), nil); err == nil {
+		t.Fatal("not connected")
+	}
+	c, err := p.Connect(48*physic.KiloHertz, 24, 2, i2s.Master)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Connect(48*physic.KiloHertz, 24, 2, i2s.Master); err == nil {
+		t.Fatal("already connected")
+	}
+	m := drvDMA.pcmMemory
+	if expected := pcmClockInverted | pcmFSInverted | 47<<pcmFrameLengthShift | 24; m.mode != expected {
+		t.Fatalf("0x%x != 0x%x", m.mode, expected)
+	}
+	// CH1: enabled, pos 1, 24 bits; CH2: enabled, pos 25, 24 bits.
+	if expected := pcmTX(0xC0100000 | 0xC190); m.txc != expected {
+		t.Fatalf("0x%x != 0x%x", m.txc, expected)
+	}
+	if uint32(m.rxc) != uint32(m.txc) {
+		t.Fatalf("0x%x != 0x%x", m.rxc, m.txc)
+	}
+	// 2.304MHz is 500MHz/217 within 0.01%.
+	if d := drvDMA.clockMemory.pcm.div & clockDiviMask; d != 217<<clockDiviShift {
+		t.Fatal(d)
+	}
+	if err := c.TxSamples(make([]int32, 2), make([]int32, 4)); err == nil {
+		t.Fatal("different lengths")
+	}
+	if err := c.TxSamples(make([]int32, 3), nil); err == nil {
+		t.Fatal("not a multiple of channels")
+	}
+	if err := c.TxSamples(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Tx(make([]byte, 4), nil); err == nil {
+		t.Fatal("not a multiple of 3 bytes")
+	}
+	if d := c.Duplex(); d.String() != "Full" {
+		t.Fatal(d)
+	}
+}
+
+func TestI2S_Connect_Slave(t *testing.T) {
+	defer reset()
+	setI2SMemory()
+	p, err := NewI2S()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if _, err := p.Connect(48*physic.KiloHertz, 32, 1, i2s.Slave); err != nil {
+		t.Fatal(err)
+	}
+	m := drvDMA.pcmMemory
+	if m.mode&(pcmClockSlave|pcmFSSlave) != pcmClockSlave|pcmFSSlave {
+		t.Fatalf("0x%x", m.mode)
+	}
+	// CH1 only: enabled, pos 1, 32 bits.
+	if expected := pcmRX(0xC0180000); m.rxc != expected {
+		t.Fatalf("0x%x != 0x%x", m.rxc, expected)
+	}
+}
+
+func TestI2S_Connect_Err(t *testing.T) {
+	defer reset()
+	setI2SMemory()
+	p, err := NewI2S()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	data := []struct {
+		f        physic.Frequency
+		bits     int
+		channels int
+		mode     i2s.Mode
+	}{
+		{48 * physic.KiloHertz, 7, 2, i2s.Master},
+		{48 * physic.KiloHertz, 33, 2, i2s.Master},
+		{48 * physic.KiloHertz, 16, 3, i2s.Master},
+		{48 * physic.KiloHertz, 16, 2, i2s.Mode(3)},
+		{0, 16, 2, i2s.Master},
+		// Too slow for the clock divisor.
+		{physic.Hertz, 16, 2, i2s.Master},
+	}
+	for i, line := range data {
+		if _, err := p.Connect(line.f, line.bits, line.channels, line.mode); err == nil {
+			t.Fatalf("#%d: expected failure", i)
+		}
+	}
+}
+
+func TestI2S_TxSamples(t *testing.T) {
+	defer reset()
+	setI2SMemory()
+	p, err := NewI2S()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Connect(48*physic.KiloHertz, 16, 2, i2s.Slave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both buffers are queued and chained without waiting.
+	w := make([]int32, 2*i2sSlotSamples)
+	w[i2sSlotSamples] = 42
+	if err := c.TxSamples(w, nil); err != nil {
+		t.Fatal(err)
+	}
+	q := &p.stream.w
+	if q.count != 2 || q.cb[0].txLen != 4*i2sSlotSamples || q.cb[0].nextCB != q.pCB+32 {
+		t.Fatal(q.count, q.cb[0].txLen, q.cb[0].nextCB)
+	}
+	if q.buf.Uint32()[i2sSlotSamples] != 42 {
+		t.Fatal("buffer was not filled")
+	}
+	if m := drvDMA.pcmMemory; m.cs&(pcmTXEnable|pcmRXEnable) != pcmTXEnable {
+		t.Fatalf("0x%x", m.cs)
+	}
+	// The fake DMA channel never completes.
+	if err := c.TxSamples(nil, make([]int32, 2)); err == nil {
+		t.Fatal("timed out")
+	}
+	if r := &p.stream.r; r.count != 2 || r.ch == q.ch {
+		t.Fatal(r.count)
+	}
+	if err := p.Close(); err == nil {
+		t.Fatal("timed out draining")
+	}
+	if p.stream.w.ch != nil || p.stream.cbMem != nil {
+		t.Fatal("stream must be released")
+	}
+}
+
+func TestPCMChannel(t *testing.T) {
+	if v := pcmChannel(1, 8); v != 0x4010 {
+		t.Fatalf("0x%x", v)
+	}
+	if v := pcmChannel(17, 16); v != 0x4118 {
+		t.Fatalf("0x%x", v)
+	}
+	if v := pcmChannel(33, 32); v != 0xC218 {
+		t.Fatalf("0x%x", v)
+	}
+}
+
+//
+
+func setI2SMemory() {
+	drvDMA.pcmMemory = &pcmMap{}
+	drvDMA.dmaMemory = &dmaMap{}
+	drvDMA.clockMemory = &clockMap{}
+}
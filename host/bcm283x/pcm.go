@@ -213,6 +213,59 @@ func (p *pcmMap) set() {
 	p.cs |= pcmTXEnable
 }
 
+// setI2S configures the PCM block for I²S framing.
+//
+// Each frame is two slots of bits width; the left channel is sent while WS is
+// low and data is delayed by one clock after the WS edge. Only the first
+// channel is enabled when channels is 1.
+func (p *pcmMap) setI2S(bits, channels int, slave bool) {
+	ch1 := pcmChannel(1, bits)
+	ch2 := uint32(0)
+	if channels == 2 {
+		ch2 = pcmChannel(bits+1, bits)
+	}
+	p.rxc = pcmRX(ch1<<16 | ch2)
+	p.txc = pcmTX(ch1<<16 | ch2)
+	// Data changes on the falling edge of SCK, so that it is stable on the
+	// rising edge.
+	m := pcmClockInverted | pcmFSInverted | pcmMode(2*bits-1)<<pcmFrameLengthShift | pcmMode(bits)
+	if slave {
+		m |= pcmClockSlave | pcmFSSlave
+	}
+	p.mode = m
+}
+
+// start clears the FIFOs and starts the transmission via DMA requests.
+func (p *pcmMap) start(tx, rx bool) {
+	p.cs = pcmEnable | pcmRXSignExtend
+	p.cs |= pcmTXClear | pcmRXClear
+	// In theory need to wait the equivalent of 2 PCM clocks.
+	// TODO(maruel): Use pcmSync busy loop to synchronize.
+	Nanospin(time.Microsecond)
+	p.dreq = 0x10<<pcmDreqTXPanicShift | 0x30<<pcmDreqTXLevelShift | 0x30<<pcmDreqRXPanicShift | 0x20<<pcmDreqRXLevelShift
+	p.cs |= pcmDMAEnable
+	if rx {
+		p.cs |= pcmRXEnable
+	}
+	if tx {
+		p.cs |= pcmTXEnable
+	}
+}
+
+// pcmChannel returns the 16 bits channel configuration for one channel as
+// used in both RXC_A and TXC_A.
+//
+// pos is the clock delay since the frame start and bits the channel width,
+// between 8 and 32.
+func pcmChannel(pos, bits int) uint32 {
+	w := uint32(bits - 8)
+	v := 1<<14 | uint32(pos)<<4 | w&0xF // CHxEN | CHxPOS | CHxWID
+	if w >= 16 {
+		v |= 1 << 15 // CHxWEX
+	}
+	return v
+}
+
 // setPCMClockSource sets the PCM clock.
 //
 // It may select an higher frequency than the one requested.
@@ -155,6 +155,8 @@ func Present() bool {
 
 // driver implements periph.Driver.
 type driver struct {
+	// headers are the headers registered by Init().
+	headers []string
 }
 
 func (d *driver) String() string {
@@ -247,7 +249,7 @@ func (d *driver) Init() (bool, error) {
 	P9_42 = sysfs.Pins[7]
 
 	hdr := [][]pin.Pin{{J1_1}, {J1_2}, {J1_3}, {J1_4}, {J1_5}, {J1_6}}
-	if err := pinreg.Register("J1", hdr); err != nil {
+	if err := d.registerHeader("J1", hdr); err != nil {
 		return true, err
 	}
 
@@ -276,7 +278,7 @@ func (d *driver) Init() (bool, error) {
 		{P8_43, P8_44},
 		{P8_45, P8_46},
 	}
-	if err := pinreg.Register("P8", hdr); err != nil {
+	if err := d.registerHeader("P8", hdr); err != nil {
 		return true, err
 	}
 
@@ -305,10 +307,29 @@ func (d *driver) Init() (bool, error) {
 		{P9_43, P9_44},
 		{P9_45, P9_46},
 	}
-	err := pinreg.Register("P9", hdr)
+	err := d.registerHeader("P9", hdr)
 	return true, err
 }
 
+// registerHeader registers a header and keeps track of it to unregister it
+// in Close().
+func (d *driver) registerHeader(name string, allPins [][]pin.Pin) error {
+	if err := pinreg.Register(name, allPins); err != nil {
+		return err
+	}
+	d.headers = append(d.headers, name)
+	return nil
+}
+
+// Close unregisters the headers.
+func (d *driver) Close() error {
+	for _, name := range d.headers {
+		_ = pinreg.Unregister(name)
+	}
+	d.headers = nil
+	return nil
+}
+
 func init() {
 	if isArm {
 		periph.MustRegister(&drv)
@@ -50,6 +50,8 @@ func Present() bool {
 
 // driver implements periph.Driver.
 type driver struct {
+	// headers are the headers registered by Init().
+	headers []string
 }
 
 func (d *driver) String() string {
@@ -72,20 +74,39 @@ func (d *driver) Init() (bool, error) {
 	I2C_SDA = sysfs.Pins[12]
 	I2C_SCL = sysfs.Pins[13]
 	hdr := [][]pin.Pin{{pin.GROUND}, {pin.V3_3}, {I2C_SDA}, {I2C_SCL}}
-	if err := pinreg.Register("I2C", hdr); err != nil {
+	if err := d.registerHeader("I2C", hdr); err != nil {
 		return true, err
 	}
 
 	UART_TX = sysfs.Pins[3]
 	UART_RX = sysfs.Pins[2]
 	hdr = [][]pin.Pin{{pin.GROUND}, {pin.V3_3}, {UART_TX}, {UART_RX}}
-	if err := pinreg.Register("UART", hdr); err != nil {
+	if err := d.registerHeader("UART", hdr); err != nil {
 		return true, err
 	}
 
 	return true, nil
 }
 
+// registerHeader registers a header and keeps track of it to unregister it
+// in Close().
+func (d *driver) registerHeader(name string, allPins [][]pin.Pin) error {
+	if err := pinreg.Register(name, allPins); err != nil {
+		return err
+	}
+	d.headers = append(d.headers, name)
+	return nil
+}
+
+// Close unregisters the headers.
+func (d *driver) Close() error {
+	for _, name := range d.headers {
+		_ = pinreg.Unregister(name)
+	}
+	d.headers = nil
+	return nil
+}
+
 func init() {
 	if isArm {
 		periph.MustRegister(&drv)
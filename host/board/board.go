@@ -0,0 +1,308 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package board
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"periph.io/x/periph"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/pin"
+	"periph.io/x/periph/conn/pin/pinreg"
+	"periph.io/x/periph/host/distro"
+)
+
+// Description describes the headers of a board.
+type Description struct {
+	// Name is the name of the board, e.g. "ODROID-C1".
+	Name string `json:"name"`
+	// Match is used to detect the board.
+	Match Match `json:"match"`
+	// Headers are the headers on the board.
+	Headers []Header `json:"headers"`
+	// Aliases maps aliases to GPIO names in gpioreg, e.g. "I2C0_SDA": "74".
+	Aliases map[string]string `json:"aliases"`
+}
+
+// Match is the criteria to detect a board. Any match is sufficient.
+type Match struct {
+	// Compatible is compared to the device tree compatible strings.
+	Compatible []string `json:"compatible"`
+	// Model is compared to the prefix of the device tree model.
+	Model []string `json:"model"`
+	// Hardware is compared to the "Hardware" field in /proc/cpuinfo.
+	Hardware []string `json:"hardware"`
+}
+
+// Header is a header on a board.
+type Header struct {
+	// Name is the header name as registered in pinreg, e.g. "P1".
+	Name string `json:"name"`
+	// Pins are the rows of pins, each a GPIO name or a well known pin name.
+	Pins [][]string `json:"pins"`
+}
+
+// Parse reads a JSON description and validates it.
+func Parse(r io.Reader) (*Description, error) {
+	d := &Description{}
+	if err := json.NewDecoder(r).Decode(d); err != nil {
+		return nil, fmt.Errorf("board: %v", err)
+	}
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ParseFile reads a JSON description from a file.
+func ParseFile(path string) (*Description, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return d, nil
+}
+
+// Matches returns true if the description matches the host.
+func (d *Description) Matches() bool {
+	m := &d.Match
+	if len(m.Compatible) == 0 && len(m.Model) == 0 && len(m.Hardware) == 0 {
+		return true
+	}
+	for _, c := range distro.DTCompatible() {
+		for _, e := range m.Compatible {
+			if c == e {
+				return true
+			}
+		}
+	}
+	model := distro.DTModel()
+	for _, e := range m.Model {
+		if strings.HasPrefix(model, e) {
+			return true
+		}
+	}
+	hw := distro.CPUInfo()["Hardware"]
+	for _, e := range m.Hardware {
+		if hw == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Register registers the headers in pinreg and the aliases in gpioreg.
+//
+// The GPIOs must already be registered in gpioreg.
+func (d *Description) Register() error {
+	for i, h := range d.Headers {
+		pins, err := resolve(h.Pins)
+		if err == nil {
+			err = pinreg.Register(h.Name, pins)
+		}
+		if err != nil {
+			for _, r := range d.Headers[:i] {
+				_ = pinreg.Unregister(r.Name)
+			}
+			return err
+		}
+	}
+	var done []string
+	for alias, dest := range d.Aliases {
+		if err := gpioreg.RegisterAlias(alias, dest); err != nil {
+			for _, a := range done {
+				_ = gpioreg.Unregister(a)
+			}
+			for _, h := range d.Headers {
+				_ = pinreg.Unregister(h.Name)
+			}
+			return err
+		}
+		done = append(done, alias)
+	}
+	return nil
+}
+
+// Unregister reverts what Register did.
+func (d *Description) Unregister() error {
+	var err error
+	for _, h := range d.Headers {
+		if err1 := pinreg.Unregister(h.Name); err == nil {
+			err = err1
+		}
+	}
+	for alias := range d.Aliases {
+		if err1 := gpioreg.Unregister(alias); err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+// Add adds a description for the "board" driver to consider.
+//
+// It must be called before host.Init().
+func Add(d *Description) error {
+	if err := d.validate(); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, e := range descriptions {
+		if e.Name == d.Name {
+			return fmt.Errorf("board: description %q was already added", d.Name)
+		}
+	}
+	descriptions = append(descriptions, d)
+	return nil
+}
+
+// AddFile adds a JSON description file for the "board" driver to consider.
+//
+// It must be called before host.Init().
+func AddFile(path string) error {
+	d, err := ParseFile(path)
+	if err != nil {
+		return err
+	}
+	return Add(d)
+}
+
+//
+
+var (
+	mu           sync.Mutex
+	descriptions []*Description
+)
+
+// wellKnown are the pins that are not GPIOs.
+var wellKnown = map[string]pin.Pin{}
+
+func init() {
+	for _, p := range []*pin.BasicPin{pin.INVALID, pin.GROUND, pin.V1_8, pin.V2_8, pin.V3_3, pin.V5, pin.DC_IN, pin.BAT_PLUS} {
+		wellKnown[p.Name()] = p
+	}
+	periph.MustRegister(&drv)
+}
+
+func (d *Description) validate() error {
+	if d.Name == "" {
+		return errors.New("board: missing name")
+	}
+	if len(d.Headers) == 0 {
+		return fmt.Errorf("board: %s: no header", d.Name)
+	}
+	seen := map[string]bool{}
+	for _, h := range d.Headers {
+		if h.Name == "" {
+			return fmt.Errorf("board: %s: header without a name", d.Name)
+		}
+		if seen[h.Name] {
+			return fmt.Errorf("board: %s: header %q is duplicated", d.Name, h.Name)
+		}
+		seen[h.Name] = true
+		if len(h.Pins) == 0 {
+			return fmt.Errorf("board: %s: header %q has no pin", d.Name, h.Name)
+		}
+		for i, row := range h.Pins {
+			for j, p := range row {
+				if p == "" {
+					return fmt.Errorf("board: %s: invalid pin on header %s[%d][%d]", d.Name, h.Name, i+1, j+1)
+				}
+			}
+		}
+	}
+	for alias, dest := range d.Aliases {
+		if alias == "" || dest == "" {
+			return fmt.Errorf("board: %s: invalid alias %q -> %q", d.Name, alias, dest)
+		}
+	}
+	return nil
+}
+
+// resolve converts the pin names to pins.
+func resolve(rows [][]string) ([][]pin.Pin, error) {
+	out := make([][]pin.Pin, len(rows))
+	for i, row := range rows {
+		out[i] = make([]pin.Pin, len(row))
+		for j, name := range row {
+			if p, ok := wellKnown[name]; ok {
+				out[i][j] = p
+			} else if p := gpioreg.ByName(name); p != nil {
+				out[i][j] = p
+			} else {
+				return nil, fmt.Errorf("unknown pin %q", name)
+			}
+		}
+	}
+	return out, nil
+}
+
+// driver registers the descriptions matching the host.
+type driver struct {
+	registered []*Description
+}
+
+func (d *driver) String() string {
+	return "board"
+}
+
+func (d *driver) Prerequisites() []string {
+	return nil
+}
+
+func (d *driver) After() []string {
+	// The GPIO drivers must be loaded first, and the board drivers so that
+	// their headers take precedence.
+	return []string{
+		"allwinner-gpio", "allwinner-gpio-pl", "bcm283x-gpio", "sysfs-gpio",
+		"sysfs-gpiochip", "beaglebone", "beaglebone-green", "chip", "odroid-c1", "pine64", "rpi",
+	}
+}
+
+func (d *driver) Init() (bool, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(descriptions) == 0 {
+		return false, errors.New("no board description was added")
+	}
+	var matched []*Description
+	for _, desc := range descriptions {
+		if desc.Matches() {
+			matched = append(matched, desc)
+		}
+	}
+	if len(matched) == 0 {
+		return false, errors.New("no board description matches this host")
+	}
+	for _, desc := range matched {
+		if err := desc.Register(); err != nil {
+			return true, fmt.Errorf("%s: %v", desc.Name, err)
+		}
+		d.registered = append(d.registered, desc)
+	}
+	return true, nil
+}
+
+func (d *driver) Close() error {
+	for _, desc := range d.registered {
+		_ = desc.Unregister()
+	}
+	d.registered = nil
+	return nil
+}
+
+var drv driver
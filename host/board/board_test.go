@@ -0,0 +1,187 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package board
+
+import (
+	"strings"
+	"testing"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/pin"
+	"periph.io/x/periph/conn/pin/pinreg"
+)
+
+func TestParseFile(t *testing.T) {
+	d, err := ParseFile("testdata/odroid-c1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name != "ODROID-C1" || len(d.Headers) != 1 || d.Headers[0].Name != "J2" {
+		t.Fatal(d)
+	}
+	if l := len(d.Headers[0].Pins); l != 20 {
+		t.Fatal(l)
+	}
+	if a := d.Aliases["I2C0_SDA"]; a != "74" {
+		t.Fatal(a)
+	}
+	if _, err := ParseFile("testdata/inexistent.json"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestParse_Err(t *testing.T) {
+	data := []string{
+		`{`,
+		`{"headers": [{"name": "P1", "pins": [["GROUND"]]}]}`,
+		`{"name": "foo"}`,
+		`{"name": "foo", "headers": [{"pins": [["GROUND"]]}]}`,
+		`{"name": "foo", "headers": [{"name": "P1"}]}`,
+		`{"name": "foo", "headers": [{"name": "P1", "pins": [["GROUND", ""]]}]}`,
+		`{"name": "foo", "headers": [{"name": "P1", "pins": [["GROUND"]]}, {"name": "P1", "pins": [["GROUND"]]}]}`,
+		`{"name": "foo", "headers": [{"name": "P1", "pins": [["GROUND"]]}], "aliases": {"FOO": ""}}`,
+	}
+	for i, line := range data {
+		if _, err := Parse(strings.NewReader(line)); err == nil {
+			t.Fatalf("#%d: expected error", i)
+		}
+	}
+}
+
+func TestDescription_Register(t *testing.T) {
+	p := &gpiotest.Pin{N: "GPIO1000", Num: 1000}
+	if err := gpioreg.Register(p); err != nil {
+		t.Fatal(err)
+	}
+	defer gpioreg.Unregister(p.Name())
+	d := &Description{
+		Name: "test",
+		Headers: []Header{
+			{Name: "TEST", Pins: [][]string{{"3.3V", "GPIO1000"}, {"GROUND", "INVALID"}}},
+		},
+		Aliases: map[string]string{"TEST_FOO": "GPIO1000"},
+	}
+	if err := d.Register(); err != nil {
+		t.Fatal(err)
+	}
+	h := pinreg.All()["TEST"]
+	if len(h) != 2 || h[0][0] != pin.V3_3 || h[0][1] != p || h[1][0] != pin.GROUND || h[1][1] != pin.INVALID {
+		t.Fatal(h)
+	}
+	if name, pos := pinreg.Position(p); name != "TEST" || pos != 2 {
+		t.Fatal(name, pos)
+	}
+	if a := gpioreg.ByName("TEST_FOO"); a == nil || a.(gpio.RealPin).Real() != p {
+		t.Fatal(a)
+	}
+	// The header is already registered.
+	if err := d.Register(); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := d.Unregister(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pinreg.All()["TEST"]; ok {
+		t.Fatal("header must have been unregistered")
+	}
+	if gpioreg.ByName("TEST_FOO") != nil {
+		t.Fatal("alias must have been unregistered")
+	}
+	if err := d.Unregister(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDescription_Register_rollback(t *testing.T) {
+	p := &gpiotest.Pin{N: "GPIO1002", Num: 1002}
+	if err := gpioreg.Register(p); err != nil {
+		t.Fatal(err)
+	}
+	defer gpioreg.Unregister(p.Name())
+	// The alias conflicts with a pin.
+	d := &Description{
+		Name:    "test",
+		Headers: []Header{{Name: "TEST", Pins: [][]string{{"GROUND"}}}},
+		Aliases: map[string]string{"GPIO1002": "GPIO1000"},
+	}
+	if err := d.Register(); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, ok := pinreg.All()["TEST"]; ok {
+		t.Fatal("header must have been unregistered")
+	}
+	// The second header refers to a GPIO that is not registered.
+	d = &Description{
+		Name: "test",
+		Headers: []Header{
+			{Name: "TEST", Pins: [][]string{{"GPIO1002"}}},
+			{Name: "TEST2", Pins: [][]string{{"GPIO1003"}}},
+		},
+	}
+	if err := d.Register(); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, ok := pinreg.All()["TEST"]; ok {
+		t.Fatal("header must have been unregistered")
+	}
+}
+
+func TestDescription_Matches(t *testing.T) {
+	d := &Description{}
+	if !d.Matches() {
+		t.Fatal("no criteria must always match")
+	}
+	d.Match.Compatible = []string{"periph,inexistent"}
+	d.Match.Model = []string{"Inexistent board"}
+	d.Match.Hardware = []string{"INEXISTENT"}
+	if d.Matches() {
+		t.Fatal("must not match")
+	}
+}
+
+func TestDriver(t *testing.T) {
+	defer func() {
+		descriptions = nil
+	}()
+	d := driver{}
+	if d.String() != "board" || d.Prerequisites() != nil || len(d.After()) == 0 {
+		t.Fatal("unexpected driver")
+	}
+	if ok, err := d.Init(); ok || err == nil {
+		t.Fatal("must skip without description")
+	}
+	if err := AddFile("testdata/odroid-c1.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddFile("testdata/odroid-c1.json"); err == nil {
+		t.Fatal("must not be added twice")
+	}
+	if err := AddFile("testdata/inexistent.json"); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := Add(&Description{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if ok, err := d.Init(); ok || err == nil {
+		t.Fatal("must skip when no description matches")
+	}
+	if err := Add(&Description{Name: "any", Headers: []Header{{Name: "ANY", Pins: [][]string{{"5V", "GROUND"}}}}}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := d.Init(); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	if _, ok := pinreg.All()["ANY"]; !ok {
+		t.Fatal("header must have been registered")
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pinreg.All()["ANY"]; ok {
+		t.Fatal("header must have been unregistered")
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package board registers board headers from description files instead of
+// Go code.
+//
+// A description lists the headers of a board, the position of each pin on
+// them and aliases for the GPIOs. The GPIOs are referred to by their name in
+// gpioreg, so a description works with any GPIO driver, including
+// sysfs-gpio. This is useful to support a new single board computer or an
+// in-house carrier board without writing a host package.
+//
+// Descriptions are added with Add() or AddFile() before calling host.Init().
+// The "board" driver then registers the headers of the descriptions matching
+// the host in pinreg, and the aliases in gpioreg.
+//
+// Format
+//
+// A description is a JSON document:
+//
+//   {
+//     "name": "ODROID-C1",
+//     "match": {
+//       "compatible": ["hardkernel,odroid-c1"],
+//       "hardware": ["ODROIDC"]
+//     },
+//     "headers": [
+//       {
+//         "name": "J2",
+//         "pins": [
+//           ["3.3V", "5V"],
+//           ["74", "5V"],
+//           ["75", "GROUND"]
+//         ]
+//       }
+//     ],
+//     "aliases": {
+//       "I2C0_SDA": "74"
+//     }
+//   }
+//
+// "match" is used to detect the board: "compatible" is compared to the device
+// tree compatible strings, "model" is a prefix of the device tree model and
+// "hardware" is compared to the "Hardware" field of /proc/cpuinfo. Any match
+// is sufficient. A description without any criteria always matches.
+//
+// Each header is a list of rows of pins. A pin is either the name of a GPIO in
+// gpioreg, or the name of one of the well known pins in package pin: "GROUND",
+// "1.8V", "2.8V", "3.3V", "5V", "DC_IN", "BAT+" and "INVALID" for a pin not
+// connected. Registering a description fails if one of its GPIOs is not
+// registered, for example because the kernel doesn't expose it.
+package board
@@ -0,0 +1,31 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package board_test
+
+import (
+	"fmt"
+	"log"
+
+	"periph.io/x/periph/conn/pin/pinreg"
+	"periph.io/x/periph/host"
+	"periph.io/x/periph/host/board"
+)
+
+func ExampleAddFile() {
+	// Describe an in-house carrier board.
+	if err := board.AddFile("/etc/periph/carrier.json"); err != nil {
+		log.Fatal(err)
+	}
+	// The headers are registered as part of the initialization.
+	if _, err := host.Init(); err != nil {
+		log.Fatal(err)
+	}
+	for name, rows := range pinreg.All() {
+		fmt.Printf("%s:\n", name)
+		for _, row := range rows {
+			fmt.Printf("  %s\n", row)
+		}
+	}
+}
@@ -243,6 +243,8 @@ func findXIOBase() int {
 
 // driver implements drivers.Driver.
 type driver struct {
+	// headers are the headers registered by Init().
+	headers []string
 }
 
 func (d *driver) String() string {
@@ -319,7 +321,7 @@ func (d *driver) Init() (bool, error) {
 		{gpioreg.ByName("LCD-VSYNC"), gpioreg.ByName("LCD-HSYNC")},
 		{U13_39, gpioreg.ByName("LCD-DE")},
 	}
-	if err := pinreg.Register("U13", U13); err != nil {
+	if err := d.registerHeader("U13", U13); err != nil {
 		return true, err
 	}
 
@@ -346,7 +348,29 @@ func (d *driver) Init() (bool, error) {
 		{gpioreg.ByName("CSID6"), gpioreg.ByName("CSID7")},
 		{U14_39, U14_40},
 	}
-	return true, pinreg.Register("U14", U14)
+	return true, d.registerHeader("U14", U14)
+}
+
+// registerHeader registers a header and keeps track of it to unregister it
+// in Close().
+func (d *driver) registerHeader(name string, allPins [][]pin.Pin) error {
+	if err := pinreg.Register(name, allPins); err != nil {
+		return err
+	}
+	d.headers = append(d.headers, name)
+	return nil
+}
+
+// Close unregisters the headers and the pin aliases.
+func (d *driver) Close() error {
+	for _, name := range d.headers {
+		_ = pinreg.Unregister(name)
+	}
+	d.headers = nil
+	for alias := range aliases {
+		_ = gpioreg.Unregister(alias)
+	}
+	return nil
 }
 
 func init() {
@@ -4,6 +4,13 @@
 
 package distro
 
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
 // DTModel returns platform model info from the Linux device tree (/proc/device-tree/model), and
 // returns "unknown" on non-linux systems or if the file is missing.
 func DTModel() string {
@@ -35,13 +42,421 @@ func DTCompatible() []string {
 	return dtCompatible
 }
 
+// DT returns the device tree the kernel booted with.
+//
+// It is read from /sys/firmware/fdt when accessible, which usually requires
+// root, otherwise from /proc/device-tree. The result is cached.
+func DT() (*DeviceTree, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if dt == nil && dtErr == nil {
+		if isLinux {
+			dt, dtErr = makeDTLinux()
+		} else {
+			dtErr = errors.New("distro: device tree is only supported on linux")
+		}
+	}
+	return dt, dtErr
+}
+
+// DeviceTree is a parsed device tree.
+//
+// Use it to find which buses are enabled and which pins they are muxed to.
+type DeviceTree struct {
+	// Root is the root node "/".
+	Root *DTNode
+
+	phandles map[uint32]*DTNode
+}
+
+// Node returns the node at path, or nil if not found.
+//
+// path is either absolute like "/soc/i2c@7e804000", or starts with an alias
+// or a label like "i2c1" or "i2c1/child". The unit address may be omitted
+// when there is no ambiguity.
+func (t *DeviceTree) Node(path string) *DTNode {
+	n := t.Root
+	if !strings.HasPrefix(path, "/") {
+		parts := strings.SplitN(path, "/", 2)
+		target := ""
+		if a := t.Root.Child("aliases"); a != nil {
+			target = a.PropString(parts[0])
+		}
+		if target == "" {
+			if s := t.Root.Child("__symbols__"); s != nil {
+				target = s.PropString(parts[0])
+			}
+		}
+		if !strings.HasPrefix(target, "/") {
+			return nil
+		}
+		if n = t.Node(target); n == nil {
+			return nil
+		}
+		if len(parts) == 1 {
+			return n
+		}
+		path = parts[1]
+	}
+	for _, p := range strings.Split(path, "/") {
+		if p == "" {
+			continue
+		}
+		if n = n.Child(p); n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// ByPhandle returns the node with this phandle, or nil if not found.
+func (t *DeviceTree) ByPhandle(phandle uint32) *DTNode {
+	return t.phandles[phandle]
+}
+
+// Compatible returns all the nodes compatible with c, in tree order.
+//
+// Disabled nodes are included; use DTNode.Enabled() to filter them.
+func (t *DeviceTree) Compatible(c string) []*DTNode {
+	var out []*DTNode
+	t.Root.Walk(func(n *DTNode) bool {
+		if n.IsCompatible(c) {
+			out = append(out, n)
+		}
+		return true
+	})
+	return out
+}
+
+// Aliases returns the content of the /aliases node, e.g. "i2c1" ->
+// "/soc/i2c@7e804000".
+func (t *DeviceTree) Aliases() map[string]string {
+	out := map[string]string{}
+	if a := t.Root.Child("aliases"); a != nil {
+		for k, v := range a.Properties {
+			out[k] = propString(v)
+		}
+	}
+	return out
+}
+
+// DTPhandleArgs is a reference to a node along its arguments, like a GPIO
+// specifier.
+type DTPhandleArgs struct {
+	Node *DTNode
+	Args []uint32
+}
+
+func (d DTPhandleArgs) String() string {
+	return fmt.Sprintf("%s%v", d.Node.Path(), d.Args)
+}
+
+// PhandleArgs decodes the property prop of n as a list of phandles each
+// followed by a number of arguments specified by the property cells of the
+// referenced node, like "#gpio-cells" or "#pwm-cells".
+//
+// An empty phandle (0) is returned with a nil Node.
+func (t *DeviceTree) PhandleArgs(n *DTNode, prop, cells string) ([]DTPhandleArgs, error) {
+	v := n.PropUint32s(prop)
+	var out []DTPhandleArgs
+	for i := 0; i < len(v); {
+		if v[i] == 0 {
+			out = append(out, DTPhandleArgs{})
+			i++
+			continue
+		}
+		ref := t.ByPhandle(v[i])
+		if ref == nil {
+			return nil, fmt.Errorf("distro: %s: %s refers to unknown phandle %d", n.Path(), prop, v[i])
+		}
+		c, ok := ref.PropUint32(cells)
+		if !ok {
+			return nil, fmt.Errorf("distro: %s: %s refers to %s which has no %s", n.Path(), prop, ref.Path(), cells)
+		}
+		i++
+		if i+int(c) > len(v) {
+			return nil, fmt.Errorf("distro: %s: %s is truncated", n.Path(), prop)
+		}
+		out = append(out, DTPhandleArgs{ref, v[i : i+int(c)]})
+		i += int(c)
+	}
+	return out, nil
+}
+
+// GPIOs decodes a GPIO property of n like "gpios", "cs-gpios" or
+// "reset-gpios".
+//
+// The first argument is usually the line number on the GPIO controller and
+// the second one the flags.
+func (t *DeviceTree) GPIOs(n *DTNode, prop string) ([]DTPhandleArgs, error) {
+	return t.PhandleArgs(n, prop, "#gpio-cells")
+}
+
+// Pinctrl returns the pin configuration nodes of n for the state, usually
+// "default".
+//
+// Use DTNode.Pins() and DTNode.PinFunction() on the returned nodes.
+func (t *DeviceTree) Pinctrl(n *DTNode, state string) ([]*DTNode, error) {
+	idx := -1
+	for i, s := range n.PropStrings("pinctrl-names") {
+		if s == state {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return nil, nil
+	}
+	prop := fmt.Sprintf("pinctrl-%d", idx)
+	var out []*DTNode
+	for _, p := range n.PropUint32s(prop) {
+		c := t.ByPhandle(p)
+		if c == nil {
+			return nil, fmt.Errorf("distro: %s: %s refers to unknown phandle %d", n.Path(), prop, p)
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// DTNode is a node in a device tree.
+type DTNode struct {
+	// Name is the node name including the unit address, e.g.
+	// "i2c@7e804000". It is empty for the root node.
+	Name       string
+	Parent     *DTNode
+	Children   []*DTNode
+	Properties map[string][]byte
+}
+
+// Path returns the absolute path of the node.
+func (n *DTNode) Path() string {
+	if n.Parent == nil {
+		return "/"
+	}
+	if n.Parent.Parent == nil {
+		return "/" + n.Name
+	}
+	return n.Parent.Path() + "/" + n.Name
+}
+
+func (n *DTNode) String() string {
+	return n.Path()
+}
+
+// Child returns the child node named name, or nil if not found.
+//
+// The unit address may be omitted when a single child matches.
+func (n *DTNode) Child(name string) *DTNode {
+	var found *DTNode
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+		if i := strings.IndexByte(c.Name, '@'); i != -1 && c.Name[:i] == name {
+			if found != nil {
+				// Ambiguous.
+				return nil
+			}
+			found = c
+		}
+	}
+	return found
+}
+
+// Walk calls f on n and all its descendants, depth first. It stops descending
+// a node when f returns false.
+func (n *DTNode) Walk(f func(n *DTNode) bool) {
+	if f(n) {
+		for _, c := range n.Children {
+			c.Walk(f)
+		}
+	}
+}
+
+// Prop returns the raw value of a property.
+func (n *DTNode) Prop(name string) ([]byte, bool) {
+	v, ok := n.Properties[name]
+	return v, ok
+}
+
+// PropString returns the first string of a property, or "" if not found.
+func (n *DTNode) PropString(name string) string {
+	return propString(n.Properties[name])
+}
+
+// PropStrings returns a property as a list of strings.
+func (n *DTNode) PropStrings(name string) []string {
+	return propStrings(n.Properties[name])
+}
+
+// PropUint32s returns a property as a list of cells.
+func (n *DTNode) PropUint32s(name string) []uint32 {
+	return propUint32s(n.Properties[name])
+}
+
+// PropUint32 returns a property that is a single cell.
+func (n *DTNode) PropUint32(name string) (uint32, bool) {
+	v, ok := n.Properties[name]
+	if !ok || len(v) != 4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(v), true
+}
+
+// Compatible returns the "compatible" strings, from the most specific to the
+// most generic.
+func (n *DTNode) Compatible() []string {
+	return n.PropStrings("compatible")
+}
+
+// IsCompatible returns true if the node is compatible with c.
+func (n *DTNode) IsCompatible(c string) bool {
+	for _, s := range n.Compatible() {
+		if s == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns the "status" property; it is "okay" when not specified.
+func (n *DTNode) Status() string {
+	if _, ok := n.Properties["status"]; !ok {
+		return "okay"
+	}
+	return n.PropString("status")
+}
+
+// Enabled returns true if the node's status is "okay".
+func (n *DTNode) Enabled() bool {
+	s := n.Status()
+	return s == "okay" || s == "ok"
+}
+
+// Phandle returns the node's phandle, or 0 if it has none.
+func (n *DTNode) Phandle() uint32 {
+	if p, ok := n.PropUint32("phandle"); ok {
+		return p
+	}
+	p, _ := n.PropUint32("linux,phandle")
+	return p
+}
+
+// Pins returns the pins of a pin configuration node, as returned by
+// DeviceTree.Pinctrl().
+//
+// The format depends on the pin controller: "GPIO2" on Broadcom, "PB0" on
+// Allwinner and the register offset like "0x154" for pinctrl-single, as used
+// on the TI AM335x.
+func (n *DTNode) Pins() []string {
+	if v := n.PropUint32s("brcm,pins"); len(v) != 0 {
+		out := make([]string, len(v))
+		for i, p := range v {
+			out[i] = fmt.Sprintf("GPIO%d", p)
+		}
+		return out
+	}
+	if v := n.PropStrings("pins"); len(v) != 0 {
+		return v
+	}
+	if v := n.PropStrings("allwinner,pins"); len(v) != 0 {
+		return v
+	}
+	if v := n.PropUint32s("pinctrl-single,pins"); len(v) != 0 {
+		stride := 2
+		if n.Parent != nil {
+			if c, ok := n.Parent.PropUint32("#pinctrl-cells"); ok {
+				stride = int(c) + 1
+			}
+		}
+		var out []string
+		for i := 0; i+stride <= len(v); i += stride {
+			out = append(out, fmt.Sprintf("0x%x", v[i]))
+		}
+		return out
+	}
+	return nil
+}
+
+// PinFunction returns the function the pins of a pin configuration node are
+// muxed to.
+//
+// On Broadcom it is one of "in", "out" or "alt0" to "alt5". On Allwinner it
+// is the function name like "i2c1". It is "" when unknown.
+func (n *DTNode) PinFunction() string {
+	if f, ok := n.PropUint32("brcm,function"); ok {
+		if int(f) < len(brcmFunctions) {
+			return brcmFunctions[f]
+		}
+		return ""
+	}
+	if f := n.PropString("function"); f != "" {
+		return f
+	}
+	return n.PropString("allwinner,function")
+}
+
 //
 
 var (
-	dtModel      string   // cached /proc/device-tree/model
-	dtCompatible []string // cached /proc/device-tree/compatible
+	dtModel      string      // cached /proc/device-tree/model
+	dtCompatible []string    // cached /proc/device-tree/compatible
+	dt           *DeviceTree // cached DT()
+	dtErr        error       // cached DT() error
 )
 
+// brcmFunctions maps the values of "brcm,function" to the names used in the
+// BCM2835 datasheet.
+var brcmFunctions = []string{"in", "out", "alt5", "alt4", "alt0", "alt1", "alt2", "alt3"}
+
+func makeDTLinux() (*DeviceTree, error) {
+	if b, err := readFile("/sys/firmware/fdt"); err == nil {
+		return ParseDTB(b)
+	}
+	return ReadDTDir("/proc/device-tree")
+}
+
+func newDeviceTree(root *DTNode) *DeviceTree {
+	t := &DeviceTree{Root: root}
+	t.index()
+	return t
+}
+
+// index rebuilds the phandle index.
+func (t *DeviceTree) index() {
+	t.phandles = map[uint32]*DTNode{}
+	t.Root.Walk(func(n *DTNode) bool {
+		if p := n.Phandle(); p != 0 && p != 0xFFFFFFFF {
+			t.phandles[p] = n
+		}
+		return true
+	})
+}
+
+func propString(v []byte) string {
+	if i := indexNull(v); i >= 0 {
+		v = v[:i]
+	}
+	return string(v)
+}
+
+func propStrings(v []byte) []string {
+	if len(v) == 0 {
+		return nil
+	}
+	return splitNull(v)
+}
+
+func propUint32s(v []byte) []uint32 {
+	out := make([]uint32, len(v)/4)
+	for i := range out {
+		out[i] = binary.BigEndian.Uint32(v[4*i:])
+	}
+	return out
+}
+
 func makeDTModelLinux() string {
 	// Read model from device tree.
 	if bytes, err := readFile("/proc/device-tree/model"); err == nil {
@@ -148,6 +148,8 @@ func reset() {
 	cpuInfo = nil
 	dtCompatible = nil
 	dtModel = ""
+	dt = nil
+	dtErr = nil
 	osRelease = nil
 	readFile = func(filename string) ([]byte, error) {
 		return nil, errors.New("no file can be opened in unit tests")
@@ -0,0 +1,394 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package distro
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ParseDTB parses a flattened device tree blob, as found in /boot/*.dtb,
+// /boot/overlays/*.dtbo or /sys/firmware/fdt.
+//
+// The format is described at https://www.devicetree.org/specifications/
+func ParseDTB(b []byte) (*DeviceTree, error) {
+	if len(b) < fdtHeaderSize {
+		return nil, errors.New("distro: dtb is too short")
+	}
+	h := fdtHeader{}
+	for i, p := range []*uint32{&h.magic, &h.totalSize, &h.offStruct, &h.offStrings, &h.offMemRsvmap, &h.version, &h.lastCompVersion, &h.bootCPUID, &h.sizeStrings, &h.sizeStruct} {
+		*p = binary.BigEndian.Uint32(b[4*i:])
+	}
+	if h.magic != fdtMagic {
+		return nil, fmt.Errorf("distro: invalid dtb magic 0x%08x", h.magic)
+	}
+	if h.lastCompVersion > 17 {
+		return nil, fmt.Errorf("distro: unsupported dtb version %d", h.lastCompVersion)
+	}
+	if int(h.totalSize) > len(b) {
+		return nil, errors.New("distro: dtb is truncated")
+	}
+	if h.version < 17 {
+		// size_dt_struct was added in version 17.
+		h.sizeStruct = h.offStrings - h.offStruct
+	}
+	if uint64(h.offStruct)+uint64(h.sizeStruct) > uint64(h.totalSize) || uint64(h.offStrings)+uint64(h.sizeStrings) > uint64(h.totalSize) {
+		return nil, errors.New("distro: dtb is truncated")
+	}
+	p := fdtParser{
+		s:       b[h.offStruct : h.offStruct+h.sizeStruct],
+		strings: b[h.offStrings : h.offStrings+h.sizeStrings],
+	}
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return newDeviceTree(root), nil
+}
+
+// ReadDTDir reads a device tree exposed as a directory hierarchy, like
+// /proc/device-tree.
+//
+// Each directory is a node and each file is a property.
+func ReadDTDir(dir string) (*DeviceTree, error) {
+	root := &DTNode{Properties: map[string][]byte{}}
+	if err := readDTDir(dir, root); err != nil {
+		return nil, err
+	}
+	return newDeviceTree(root), nil
+}
+
+// ApplyOverlay merges a device tree overlay, as found in
+// /boot/overlays/*.dtbo, into t.
+//
+// The overlay must have been compiled with symbols (dtc -@) and t must have a
+// __symbols__ node if the overlay refers to labels in the base tree.
+func (t *DeviceTree) ApplyOverlay(o *DeviceTree) error {
+	// Renumber the overlay's phandles so they do not collide with the base.
+	delta := t.maxPhandle()
+	if err := o.relocate(delta); err != nil {
+		return err
+	}
+	if err := o.resolveFixups(t); err != nil {
+		return err
+	}
+	symbols := o.Root.Child("__symbols__")
+	for _, f := range o.Root.Children {
+		ov := f.Child("__overlay__")
+		if ov == nil {
+			continue
+		}
+		target, err := t.overlayTarget(f)
+		if err != nil {
+			return err
+		}
+		mergeNode(target, ov)
+		// Translate the labels defined in the fragment.
+		if symbols != nil {
+			prefix := f.Path() + "/__overlay__"
+			for name, v := range symbols.Properties {
+				p := propString(v)
+				if p != prefix && !strings.HasPrefix(p, prefix+"/") {
+					continue
+				}
+				s := t.Root.Child("__symbols__")
+				if s == nil {
+					s = &DTNode{Name: "__symbols__", Parent: t.Root, Properties: map[string][]byte{}}
+					t.Root.Children = append(t.Root.Children, s)
+				}
+				s.Properties[name] = append([]byte(target.Path()+p[len(prefix):]), 0)
+			}
+		}
+	}
+	t.index()
+	return nil
+}
+
+//
+
+const (
+	fdtMagic      = 0xd00dfeed
+	fdtHeaderSize = 40
+
+	fdtBeginNode = 1
+	fdtEndNode   = 2
+	fdtProp      = 3
+	fdtNop       = 4
+	fdtEnd       = 9
+)
+
+type fdtHeader struct {
+	magic           uint32
+	totalSize       uint32
+	offStruct       uint32
+	offStrings      uint32
+	offMemRsvmap    uint32
+	version         uint32
+	lastCompVersion uint32
+	bootCPUID       uint32
+	sizeStrings     uint32
+	sizeStruct      uint32
+}
+
+// fdtParser parses the structure block of a dtb.
+type fdtParser struct {
+	s       []byte
+	strings []byte
+	off     int
+}
+
+func (p *fdtParser) parse() (*DTNode, error) {
+	var root, cur *DTNode
+	for {
+		tok, err := p.uint32()
+		if err != nil {
+			return nil, err
+		}
+		switch tok {
+		case fdtBeginNode:
+			name, err := p.cstring()
+			if err != nil {
+				return nil, err
+			}
+			n := &DTNode{Name: name, Parent: cur, Properties: map[string][]byte{}}
+			if cur == nil {
+				if root != nil {
+					return nil, errors.New("distro: invalid dtb: multiple root nodes")
+				}
+				root = n
+			} else {
+				cur.Children = append(cur.Children, n)
+			}
+			cur = n
+		case fdtEndNode:
+			if cur == nil {
+				return nil, errors.New("distro: invalid dtb: unbalanced node")
+			}
+			cur = cur.Parent
+		case fdtProp:
+			if cur == nil {
+				return nil, errors.New("distro: invalid dtb: property outside a node")
+			}
+			l, err := p.uint32()
+			if err != nil {
+				return nil, err
+			}
+			nameOff, err := p.uint32()
+			if err != nil {
+				return nil, err
+			}
+			if int(l) > len(p.s)-p.off {
+				return nil, errors.New("distro: invalid dtb: truncated property")
+			}
+			if int(nameOff) >= len(p.strings) {
+				return nil, errors.New("distro: invalid dtb: invalid property name")
+			}
+			name := p.strings[nameOff:]
+			if i := indexNull(name); i >= 0 {
+				name = name[:i]
+			}
+			v := make([]byte, l)
+			copy(v, p.s[p.off:])
+			cur.Properties[string(name)] = v
+			p.off = align4(p.off + int(l))
+		case fdtNop:
+		case fdtEnd:
+			if root == nil || cur != nil {
+				return nil, errors.New("distro: invalid dtb: unbalanced node")
+			}
+			return root, nil
+		default:
+			return nil, fmt.Errorf("distro: invalid dtb: unknown token %d", tok)
+		}
+	}
+}
+
+func (p *fdtParser) uint32() (uint32, error) {
+	if p.off+4 > len(p.s) {
+		return 0, errors.New("distro: invalid dtb: truncated structure block")
+	}
+	v := binary.BigEndian.Uint32(p.s[p.off:])
+	p.off += 4
+	return v, nil
+}
+
+func (p *fdtParser) cstring() (string, error) {
+	i := indexNull(p.s[p.off:])
+	if i < 0 {
+		return "", errors.New("distro: invalid dtb: unterminated node name")
+	}
+	s := string(p.s[p.off : p.off+i])
+	p.off = align4(p.off + i + 1)
+	return s, nil
+}
+
+func indexNull(b []byte) int {
+	for i, c := range b {
+		if c == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+func align4(i int) int {
+	return (i + 3) &^ 3
+}
+
+func readDTDir(dir string, n *DTNode) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		if e.IsDir() {
+			c := &DTNode{Name: e.Name(), Parent: n, Properties: map[string][]byte{}}
+			if err := readDTDir(p, c); err != nil {
+				return err
+			}
+			n.Children = append(n.Children, c)
+			continue
+		}
+		if n.Parent != nil && e.Name() == "name" {
+			// Linux synthesizes this property for every node but the root; it is
+			// not part of the flattened tree.
+			continue
+		}
+		v, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		n.Properties[e.Name()] = v
+	}
+	return nil
+}
+
+// maxPhandle returns the highest phandle in use.
+func (t *DeviceTree) maxPhandle() uint32 {
+	var m uint32
+	for p := range t.phandles {
+		if p > m {
+			m = p
+		}
+	}
+	return m
+}
+
+// relocate adds delta to all the phandles defined in the overlay and to the
+// references listed in __local_fixups__.
+func (t *DeviceTree) relocate(delta uint32) error {
+	t.Root.Walk(func(n *DTNode) bool {
+		for _, name := range []string{"phandle", "linux,phandle"} {
+			if v, ok := n.Properties[name]; ok && len(v) == 4 {
+				binary.BigEndian.PutUint32(v, binary.BigEndian.Uint32(v)+delta)
+			}
+		}
+		return true
+	})
+	if l := t.Root.Child("__local_fixups__"); l != nil {
+		return relocateLocal(t.Root, l, delta)
+	}
+	return nil
+}
+
+func relocateLocal(n, fixups *DTNode, delta uint32) error {
+	for prop, v := range fixups.Properties {
+		data := n.Properties[prop]
+		for _, off := range propUint32s(v) {
+			if int(off)+4 > len(data) {
+				return fmt.Errorf("distro: invalid local fixup %s:%s:%d", n.Path(), prop, off)
+			}
+			binary.BigEndian.PutUint32(data[off:], binary.BigEndian.Uint32(data[off:])+delta)
+		}
+	}
+	for _, f := range fixups.Children {
+		c := n.Child(f.Name)
+		if c == nil {
+			return fmt.Errorf("distro: invalid local fixup %s/%s", n.Path(), f.Name)
+		}
+		if err := relocateLocal(c, f, delta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveFixups patches the references to labels in base, as listed in
+// __fixups__.
+func (t *DeviceTree) resolveFixups(base *DeviceTree) error {
+	fixups := t.Root.Child("__fixups__")
+	if fixups == nil {
+		return nil
+	}
+	for label, v := range fixups.Properties {
+		target := base.Node(label)
+		if target == nil {
+			return fmt.Errorf("distro: overlay refers to unknown label %q", label)
+		}
+		phandle := target.Phandle()
+		if phandle == 0 {
+			return fmt.Errorf("distro: overlay refers to %q which has no phandle", label)
+		}
+		for _, f := range propStrings(v) {
+			// Format is "path:property:offset".
+			parts := strings.Split(f, ":")
+			if len(parts) != 3 {
+				return fmt.Errorf("distro: invalid fixup %q", f)
+			}
+			off, err := strconv.Atoi(parts[2])
+			if err != nil {
+				return fmt.Errorf("distro: invalid fixup %q", f)
+			}
+			n := t.Node(parts[0])
+			if n == nil {
+				return fmt.Errorf("distro: invalid fixup %q", f)
+			}
+			data := n.Properties[parts[1]]
+			if off < 0 || off+4 > len(data) {
+				return fmt.Errorf("distro: invalid fixup %q", f)
+			}
+			binary.BigEndian.PutUint32(data[off:], phandle)
+		}
+	}
+	return nil
+}
+
+// overlayTarget returns the node in t targeted by the overlay fragment f.
+func (t *DeviceTree) overlayTarget(f *DTNode) (*DTNode, error) {
+	if p, ok := f.PropUint32("target"); ok {
+		if n := t.ByPhandle(p); n != nil {
+			return n, nil
+		}
+		return nil, fmt.Errorf("distro: %s: unknown target phandle %d", f.Name, p)
+	}
+	if p := f.PropString("target-path"); p != "" {
+		if n := t.Node(p); n != nil {
+			return n, nil
+		}
+		return nil, fmt.Errorf("distro: %s: unknown target path %q", f.Name, p)
+	}
+	return nil, fmt.Errorf("distro: %s: missing target", f.Name)
+}
+
+// mergeNode copies the properties and the children of src into dst.
+func mergeNode(dst, src *DTNode) {
+	for k, v := range src.Properties {
+		dst.Properties[k] = v
+	}
+	for _, c := range src.Children {
+		d := dst.Child(c.Name)
+		if d == nil || d.Name != c.Name {
+			d = &DTNode{Name: c.Name, Parent: dst, Properties: map[string][]byte{}}
+			dst.Children = append(dst.Children, d)
+		}
+		mergeNode(d, c)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package distro
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDTB(t *testing.T) {
+	d := loadDTB(t, "rpi3.dtb")
+	if s := d.Root.PropString("model"); s != "Raspberry Pi 3 Model B Rev 1.2" {
+		t.Fatal(s)
+	}
+	if c := d.Root.Compatible(); !reflect.DeepEqual(c, []string{"raspberrypi,3-model-b", "brcm,bcm2837"}) {
+		t.Fatal(c)
+	}
+	if p := d.Root.Path(); p != "/" {
+		t.Fatal(p)
+	}
+	n := d.Node("/soc/i2c@7e804000")
+	if n == nil {
+		t.Fatal("i2c1 not found")
+	}
+	if p := n.Path(); p != "/soc/i2c@7e804000" {
+		t.Fatal(p)
+	}
+	if v, ok := n.PropUint32("clock-frequency"); !ok || v != 100000 {
+		t.Fatal(v)
+	}
+	if v := n.PropUint32s("reg"); !reflect.DeepEqual(v, []uint32{0x7e804000, 0x1000}) {
+		t.Fatal(v)
+	}
+	if _, ok := d.Node("/soc/gpio").Prop("gpio-controller"); !ok {
+		t.Fatal("gpio-controller")
+	}
+	// Aliases and labels.
+	if d.Node("i2c1") != n || d.Node("i2c0") != d.Node("/soc/i2c@7e205000") {
+		t.Fatal("alias or label not resolved")
+	}
+	if d.Node("gpio/uart0_pins") != d.ByPhandle(4) {
+		t.Fatal("relative path not resolved")
+	}
+	if d.Node("/soc/i2c") != nil || d.Node("inexistent") != nil || d.Node("/soc/inexistent") != nil {
+		t.Fatal("unexpected node")
+	}
+	if a := d.Aliases(); a["serial0"] != "/soc/serial@7e201000" || len(a) != 4 {
+		t.Fatal(a)
+	}
+}
+
+func TestDeviceTree_Compatible(t *testing.T) {
+	d := loadDTB(t, "rpi3.dtb")
+	var enabled []string
+	for _, n := range d.Compatible("brcm,bcm2835-i2c") {
+		if n.Enabled() {
+			enabled = append(enabled, n.Path())
+		}
+	}
+	if !reflect.DeepEqual(enabled, []string{"/soc/i2c@7e804000"}) {
+		t.Fatal(enabled)
+	}
+	if s := d.Node("i2c0").Status(); s != "disabled" {
+		t.Fatal(s)
+	}
+	// No status means enabled.
+	if n := d.Compatible("gpio-leds"); len(n) != 1 || !n[0].Enabled() {
+		t.Fatal(n)
+	}
+}
+
+func TestDeviceTree_Pinctrl(t *testing.T) {
+	d := loadDTB(t, "rpi3.dtb")
+	p, err := d.Pinctrl(d.Node("serial0"), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p) != 1 || p[0].Name != "uart0_pins" {
+		t.Fatal(p)
+	}
+	if pins := p[0].Pins(); !reflect.DeepEqual(pins, []string{"GPIO14", "GPIO15"}) {
+		t.Fatal(pins)
+	}
+	if f := p[0].PinFunction(); f != "alt0" {
+		t.Fatal(f)
+	}
+	if p, err := d.Pinctrl(d.Node("serial0"), "sleep"); p != nil || err != nil {
+		t.Fatal(p, err)
+	}
+}
+
+func TestDeviceTree_GPIOs(t *testing.T) {
+	d := loadDTB(t, "rpi3.dtb")
+	g, err := d.GPIOs(d.Node("spi0"), "cs-gpios")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gpio := d.Node("gpio")
+	expected := []DTPhandleArgs{{gpio, []uint32{8, 1}}, {gpio, []uint32{7, 1}}}
+	if !reflect.DeepEqual(g, expected) {
+		t.Fatal(g)
+	}
+	if s := g[0].String(); s != "/soc/gpio@7e200000[8 1]" {
+		t.Fatal(s)
+	}
+	if g, err := d.GPIOs(d.Node("/soc/leds/act"), "gpios"); err != nil || len(g) != 1 || g[0].Args[0] != 47 {
+		t.Fatal(g, err)
+	}
+	// Not a GPIO controller.
+	n := &DTNode{Properties: map[string][]byte{"gpios": cells(6, 1)}}
+	if _, err := d.GPIOs(n, "gpios"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDeviceTree_ApplyOverlay(t *testing.T) {
+	d := loadDTB(t, "rpi3.dtb")
+	if err := d.ApplyOverlay(loadDTB(t, "i2c-rtc.dtbo")); err != nil {
+		t.Fatal(err)
+	}
+	i2c0 := d.Node("i2c0")
+	if !i2c0.Enabled() {
+		t.Fatal("overlay not applied")
+	}
+	if rtc := i2c0.Child("rtc"); rtc == nil || !rtc.IsCompatible("dallas,ds3231") {
+		t.Fatal("rtc not found")
+	}
+	p, err := d.Pinctrl(i2c0, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The overlay's phandle was renumbered.
+	if len(p) != 1 || p[0].Path() != "/soc/gpio@7e200000/i2c0" || p[0].Phandle() != 11 {
+		t.Fatal(p)
+	}
+	if pins := p[0].Pins(); !reflect.DeepEqual(pins, []string{"GPIO0", "GPIO1"}) {
+		t.Fatal(pins)
+	}
+	// The overlay's labels are usable.
+	if d.Node("i2c0_pins") != p[0] {
+		t.Fatal("label not translated")
+	}
+	// The base is untouched.
+	if d.Node("i2c1_pins").Phandle() != 2 {
+		t.Fatal("base modified")
+	}
+}
+
+func TestDeviceTree_ApplyOverlay_Err(t *testing.T) {
+	d := loadDTB(t, "rpi3.dtb")
+	// Remove the symbols so the labels cannot be resolved.
+	d.Root.Children = d.Root.Children[:len(d.Root.Children)-1]
+	d.Root.Properties = map[string][]byte{}
+	if err := d.ApplyOverlay(loadDTB(t, "i2c-rtc.dtbo")); err == nil {
+		t.Fatal("expected error")
+	}
+	o := loadDTB(t, "i2c-rtc.dtbo")
+	delete(o.Root.Child("fragment@1").Properties, "target")
+	if err := loadDTB(t, "rpi3.dtb").ApplyOverlay(o); err == nil {
+		t.Fatal("expected missing target")
+	}
+}
+
+func TestParseDTB_Err(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/rpi3.dtb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseDTB(b[:10]); err == nil {
+		t.Fatal("too short")
+	}
+	if _, err := ParseDTB(b[:len(b)-1]); err == nil {
+		t.Fatal("truncated")
+	}
+	c := append([]byte{}, b...)
+	c[0] = 0
+	if _, err := ParseDTB(c); err == nil {
+		t.Fatal("magic")
+	}
+	// Corrupt the first token.
+	c = append([]byte{}, b...)
+	binary.BigEndian.PutUint32(c[binary.BigEndian.Uint32(c[8:]):], 42)
+	if _, err := ParseDTB(c); err == nil {
+		t.Fatal("token")
+	}
+}
+
+func TestReadDTDir(t *testing.T) {
+	// Recreate /proc/device-tree from the dtb.
+	d := loadDTB(t, "rpi3.dtb")
+	dir, err := ioutil.TempDir("", "periph_distro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := writeDTDir(dir, d.Root); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := ReadDTDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(flatten(actual.Root), flatten(d.Root)) {
+		t.Fatal("mismatch")
+	}
+	if actual.ByPhandle(2) != actual.Node("i2c1_pins") {
+		t.Fatal("phandles not indexed")
+	}
+	if _, err := ReadDTDir(filepath.Join(dir, "inexistent")); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDT(t *testing.T) {
+	defer reset()
+	b, err := ioutil.ReadFile("testdata/rpi3.dtb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readFile = func(filename string) ([]byte, error) {
+		if filename != "/sys/firmware/fdt" {
+			t.Fatal(filename)
+		}
+		return b, nil
+	}
+	if !isLinux {
+		if _, err := DT(); err == nil {
+			t.Fatal("expected error")
+		}
+		return
+	}
+	d, err := DT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d2, _ := DT(); d2 != d {
+		t.Fatal("not cached")
+	}
+}
+
+//
+
+func loadDTB(t *testing.T, name string) *DeviceTree {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ParseDTB(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func cells(v ...uint32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, c := range v {
+		binary.BigEndian.PutUint32(b[4*i:], c)
+	}
+	return b
+}
+
+func writeDTDir(dir string, n *DTNode) error {
+	for k, v := range n.Properties {
+		if err := ioutil.WriteFile(filepath.Join(dir, k), v, 0600); err != nil {
+			return err
+		}
+	}
+	if n.Parent != nil {
+		// Linux adds it; it must be ignored.
+		if err := ioutil.WriteFile(filepath.Join(dir, "name"), []byte(n.Name+"\x00"), 0600); err != nil {
+			return err
+		}
+	}
+	for _, c := range n.Children {
+		p := filepath.Join(dir, c.Name)
+		if err := os.Mkdir(p, 0700); err != nil {
+			return err
+		}
+		if err := writeDTDir(p, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flatten returns all the properties keyed by their path.
+func flatten(root *DTNode) map[string]string {
+	out := map[string]string{}
+	root.Walk(func(n *DTNode) bool {
+		out[n.Path()] = ""
+		for k, v := range n.Properties {
+			out[n.Path()+":"+k] = string(v)
+		}
+		return true
+	})
+	return out
+}
@@ -92,11 +92,28 @@ func (e *Event) MakeEvent(fd uintptr) error {
 	return e.event.makeEvent(fd)
 }
 
+// MakeReadEvent initializes an epoll *level* triggered event on linux that is
+// signaled as long as data is available to be read on the file descriptor.
+//
+// This is the kind of event needed for character devices that queue records
+// to be read, like a gpiochip line request.
+func (e *Event) MakeReadEvent(fd uintptr) error {
+	return e.event.makeReadEvent(fd)
+}
+
 // Wait waits for an event or the specified amount of time.
 func (e *Event) Wait(timeoutms int) (int, error) {
 	return e.event.wait(timeoutms)
 }
 
+// Close releases the resources used by the event.
+//
+// It doesn't close the file descriptor that was passed to MakeEvent or
+// MakeReadEvent.
+func (e *Event) Close() error {
+	return e.event.close()
+}
+
 //
 
 var (
@@ -17,6 +17,7 @@ func ioctl(f uintptr, op uint, arg uintptr) error {
 
 const (
 	epollET     = 1 << 31
+	epollIN     = 1
 	epollPRI    = 2
 	epollCTLAdd = 1
 	epollCTLDel = 2
@@ -51,6 +52,29 @@ func (e *event) makeEvent(fd uintptr) error {
 	return syscall.EpollCtl(e.epollFd, epollCTLAdd, e.fd, &e.event[0])
 }
 
+// makeReadEvent creates an epoll *level* triggered event signaled when data
+// can be read.
+func (e *event) makeReadEvent(fd uintptr) error {
+	epollFd, err := syscall.EpollCreate(1)
+	if err != nil {
+		return err
+	}
+	e.epollFd = epollFd
+	e.fd = int(fd)
+	e.event[0].Events = epollIN
+	e.event[0].Fd = int32(e.fd)
+	return syscall.EpollCtl(e.epollFd, epollCTLAdd, e.fd, &e.event[0])
+}
+
+func (e *event) close() error {
+	if e.epollFd == 0 {
+		return nil
+	}
+	err := syscall.Close(e.epollFd)
+	e.epollFd = 0
+	return err
+}
+
 func (e *event) wait(timeoutms int) (int, error) {
 	// http://man7.org/linux/man-pages/man2/epoll_wait.2.html
 	return syscall.EpollWait(e.epollFd, e.event[:], timeoutms)
@@ -20,6 +20,14 @@ func (e *event) makeEvent(f uintptr) error {
 	return errors.New("fs: unreachable code")
 }
 
+func (e *event) makeReadEvent(f uintptr) error {
+	return errors.New("fs: unreachable code")
+}
+
+func (e *event) close() error {
+	return nil
+}
+
 func (e *event) wait(timeoutms int) (int, error) {
 	return 0, errors.New("fs: unreachable code")
 }
@@ -13,3 +13,11 @@ import "periph.io/x/periph"
 func Init() (*periph.State, error) {
 	return periph.Init()
 }
+
+// InitWithOpts calls periph.InitWithOpts() and returns it as-is.
+//
+// Like Init(), it guarantees that all the drivers implemented in this library
+// are registered, so they can be selected by name in opts.
+func InitWithOpts(opts *periph.Opts) (*periph.State, error) {
+	return periph.InitWithOpts(opts)
+}
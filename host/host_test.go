@@ -6,6 +6,8 @@ package host
 
 import (
 	"testing"
+
+	"periph.io/x/periph"
 )
 
 func TestInit(t *testing.T) {
@@ -13,3 +15,21 @@ func TestInit(t *testing.T) {
 		t.Fatalf("failed to initialize periph: %v", err)
 	}
 }
+
+func TestShutdown(t *testing.T) {
+	state, err := Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := periph.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	// All the host drivers must be able to initialize again.
+	state2, err := Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state2.Loaded) != len(state.Loaded) || len(state2.Failed) != len(state.Failed) {
+		t.Fatalf("%v != %v", state2, state)
+	}
+}
@@ -108,6 +108,8 @@ func sysfsPin(n int) gpio.PinIO {
 
 // driver implements drivers.Driver.
 type driver struct {
+	// headers are the headers registered by Init().
+	headers []string
 }
 
 func (d *driver) String() string {
@@ -175,7 +177,7 @@ func (d *driver) Init() (bool, error) {
 		{J2_37, J2_38},
 		{J2_39, J2_40},
 	}
-	if err := pinreg.Register("J2", J2); err != nil {
+	if err := d.registerHeader("J2", J2); err != nil {
 		return true, err
 	}
 	for alias, number := range aliases {
@@ -186,6 +188,28 @@ func (d *driver) Init() (bool, error) {
 	return true, nil
 }
 
+// registerHeader registers a header and keeps track of it to unregister it
+// in Close().
+func (d *driver) registerHeader(name string, allPins [][]pin.Pin) error {
+	if err := pinreg.Register(name, allPins); err != nil {
+		return err
+	}
+	d.headers = append(d.headers, name)
+	return nil
+}
+
+// Close unregisters the headers and the pin aliases.
+func (d *driver) Close() error {
+	for _, name := range d.headers {
+		_ = pinreg.Unregister(name)
+	}
+	d.headers = nil
+	for alias := range aliases {
+		_ = gpioreg.Unregister(alias)
+	}
+	return nil
+}
+
 func init() {
 	if isArm {
 		periph.MustRegister(&drv)
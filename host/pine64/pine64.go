@@ -161,6 +161,8 @@ var (
 
 // driver implements periph.Driver.
 type driver struct {
+	// headers are the headers registered by Init().
+	headers []string
 }
 
 func (d *driver) String() string {
@@ -179,7 +181,7 @@ func (d *driver) Init() (bool, error) {
 	if !Present() {
 		return false, errors.New("pine64 board not detected")
 	}
-	if err := pinreg.Register("P1", [][]pin.Pin{
+	if err := d.registerHeader("P1", [][]pin.Pin{
 		{P1_1, P1_2},
 		{P1_3, P1_4},
 		{P1_5, P1_6},
@@ -203,7 +205,7 @@ func (d *driver) Init() (bool, error) {
 	}); err != nil {
 		return true, err
 	}
-	if err := pinreg.Register("EULER", [][]pin.Pin{
+	if err := d.registerHeader("EULER", [][]pin.Pin{
 		{EULER_1, EULER_2},
 		{EULER_3, EULER_4},
 		{EULER_5, EULER_6},
@@ -225,7 +227,7 @@ func (d *driver) Init() (bool, error) {
 		return true, err
 	}
 
-	if err := pinreg.Register("EXP", [][]pin.Pin{
+	if err := d.registerHeader("EXP", [][]pin.Pin{
 		{EXP_1, EXP_2},
 		{EXP_3, EXP_4},
 		{EXP_5, EXP_6},
@@ -235,7 +237,7 @@ func (d *driver) Init() (bool, error) {
 		return true, err
 	}
 
-	if err := pinreg.Register("WIFI_BT", [][]pin.Pin{
+	if err := d.registerHeader("WIFI_BT", [][]pin.Pin{
 		{WIFI_BT_1, WIFI_BT_2},
 		{WIFI_BT_3, WIFI_BT_4},
 		{WIFI_BT_5, WIFI_BT_6},
@@ -253,7 +255,7 @@ func (d *driver) Init() (bool, error) {
 		return true, err
 	}
 
-	if err := pinreg.Register("AUDIO", [][]pin.Pin{
+	if err := d.registerHeader("AUDIO", [][]pin.Pin{
 		{AUDIO_LEFT},
 		{AUDIO_RIGHT},
 	}); err != nil {
@@ -263,6 +265,25 @@ func (d *driver) Init() (bool, error) {
 	return true, nil
 }
 
+// registerHeader registers a header and keeps track of it to unregister it
+// in Close().
+func (d *driver) registerHeader(name string, allPins [][]pin.Pin) error {
+	if err := pinreg.Register(name, allPins); err != nil {
+		return err
+	}
+	d.headers = append(d.headers, name)
+	return nil
+}
+
+// Close unregisters the headers.
+func (d *driver) Close() error {
+	for _, name := range d.headers {
+		_ = pinreg.Unregister(name)
+	}
+	d.headers = nil
+	return nil
+}
+
 func init() {
 	if isArm {
 		periph.MustRegister(&drv)
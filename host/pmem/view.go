@@ -122,26 +122,39 @@ func Map(base uint64, size int) (*View, error) {
 
 // MapAsPOD is a leaky shorthand of calling Map(base, sizeof(v)) then AsPOD(v).
 //
-// There is no way to reclaim the memory map.
+// There is no way to reclaim the memory map. Use MapAsPODView() to be able to.
 //
 // A slice cannot be used, as it does not have inherent size. Use an aray
 // instead.
 func MapAsPOD(base uint64, i interface{}) error {
+	_, err := MapAsPODView(base, i)
+	return err
+}
+
+// MapAsPODView is a shorthand of calling Map(base, sizeof(v)) then AsPOD(v).
+//
+// The memory map can be reclaimed by calling Close() on the View returned.
+// Once closed, the memory pointed to by i must not be accessed anymore.
+func MapAsPODView(base uint64, i interface{}) (*View, error) {
 	// Automatically determine the necessary size. Because of this, slice of
 	// unspecified length cannot be used here.
 	if i == nil {
-		return wrapf("require Ptr, got nil")
+		return nil, wrapf("require Ptr, got nil")
 	}
 	v := reflect.ValueOf(i)
 	size, err := isPP(v)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	m, err := Map(base, size)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := m.AsPOD(i); err != nil {
+		_ = m.Close()
+		return nil, err
 	}
-	return m.AsPOD(i)
+	return m, nil
 }
 
 //
@@ -189,6 +189,17 @@ func TestMapAsPOD(t *testing.T) {
 	}
 }
 
+func TestMapAsPODView(t *testing.T) {
+	defer reset()
+	if m, err := MapAsPODView(0, nil); m != nil || err == nil {
+		t.Fatal("0 size")
+	}
+	var v *simpleStruct
+	if m, err := MapAsPODView(0, &v); m != nil || err == nil {
+		t.Fatal("file I/O is inhibited; otherwise it would have worked")
+	}
+}
+
 func TestView(t *testing.T) {
 	defer reset()
 	v := View{}
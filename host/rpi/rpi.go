@@ -11,9 +11,11 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"periph.io/x/periph"
 	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
 	"periph.io/x/periph/conn/pin"
 	"periph.io/x/periph/conn/pin/pinreg"
 	"periph.io/x/periph/host/bcm283x"
@@ -313,6 +315,8 @@ var (
 
 // driver implements periph.Driver.
 type driver struct {
+	// headers are the headers registered by Init().
+	headers []string
 }
 
 func (d *driver) String() string {
@@ -332,72 +336,22 @@ func (d *driver) Init() (bool, error) {
 		return false, errors.New("Raspberry Pi board not detected")
 	}
 
-	// Setup headers based on board revision.
-	//
-	// This code is not futureproof, it will error out on a Raspberry Pi 4
-	// whenever it comes out.
-	// Revision codes from: http://elinux.org/RPi_HardwareHistory
-	has26PinP1Header := false
-	has40PinP1Header := false
-	hasP5Header := false
-	hasAudio := false
-	hasNewAudio := false
-	hasHDMI := false
-	hasSODimm := false
-	rev := distro.CPUInfo()["Revision"]
-	if i, err := strconv.ParseInt(rev, 16, 32); err == nil {
-		// Ignore the overclock bit.
-		i &= 0xFFFFFF
-		switch i {
-		case 0x0002, 0x0003: // B v1.0
-			has26PinP1Header = true
-			hasAudio = true
-		case 0x0004, 0x0005, 0x0006, // B v2.0
-			0x0007, 0x0008, 0x0009, // A v2.0
-			0x000d, 0x000e, 0x000f: // B v2.0
-			has26PinP1Header = true
-			// Only the v2 PCB has the P5 header.
-			hasP5Header = true
-			hasAudio = true
-			hasHDMI = true
-		case 0x0010, // B+ v1.0
-			0x0012,             // A+ v1.1
-			0x0013,             // B+ v1.2
-			0x0015,             // A+ v1.1
-			0x90021,            // A+ v1.1
-			0x90032,            // B+ v1.2
-			0xa01040,           // 2 Model B v1.0
-			0xa01041, 0xa21041, // 2 Model B v1.1
-			0xa22042: // 2 Model B v1.2
-			has40PinP1Header = true
-			hasAudio = true
-			hasHDMI = true
-		case 0x900092, // Zero v1.2
-			0x900093, // Zero v1.3
-			0x920093, // Zero v1.3
-			0x9000c1: // Zero W v1.1
-			has40PinP1Header = true
-			hasHDMI = true
-		case 0x0011, // Compute Module 1
-			0x0014: // Compute Module 1
-			// SODIMM not defined
-		case 0xa020a0: // Compute Module 3 v1.0
-			hasSODimm = true
-			// tell CM3 and CM3-Lite apart, if possible
-		case 0xa02082, 0xa22082, 0xa32082, 0xa020d3: // 3 Model B v1.2, B+
-			has40PinP1Header = true
-			hasAudio = true
-			hasNewAudio = true
-			hasHDMI = true
-		default:
-			return true, fmt.Errorf("rpi: unknown hardware version: 0x%x", i)
+	// Prefer the device tree, which describes what is enabled on this board,
+	// and fall back to the board revision.
+	var f features
+	ok := false
+	if t, err := distro.DT(); err == nil {
+		f, ok = featuresFromDT(t)
+	}
+	if !ok {
+		var err error
+		if f, err = featuresFromRevision(distro.CPUInfo()["Revision"]); err != nil {
+			return true, err
 		}
-	} else {
-		return true, fmt.Errorf("rpi: failed to read cpu_info: %v", err)
 	}
 
-	if has26PinP1Header {
-		if err := pinreg.Register("P1", [][]pin.Pin{
+	if f.has26PinP1Header {
+		if err := d.registerHeader("P1", [][]pin.Pin{
 			{P1_1, P1_2},
 			{P1_3, P1_4},
 			{P1_5, P1_6},
@@ -432,8 +386,8 @@ func (d *driver) Init() (bool, error) {
 		P1_38 = gpio.INVALID
 		P1_39 = pin.INVALID
 		P1_40 = gpio.INVALID
-	} else if has40PinP1Header {
-		if err := pinreg.Register("P1", [][]pin.Pin{
+	} else if f.has40PinP1Header {
+		if err := d.registerHeader("P1", [][]pin.Pin{
 			{P1_1, P1_2},
 			{P1_3, P1_4},
 			{P1_5, P1_6},
@@ -501,8 +455,8 @@ func (d *driver) Init() (bool, error) {
 	}
 
 	// Only the A and B v2 PCB has the P5 header.
-	if hasP5Header {
-		if err := pinreg.Register("P5", [][]pin.Pin{
+	if f.hasP5Header {
+		if err := d.registerHeader("P5", [][]pin.Pin{
 			{P5_1, P5_2},
 			{P5_3, P5_4},
 			{P5_5, P5_6},
@@ -521,8 +475,8 @@ func (d *driver) Init() (bool, error) {
 		P5_8 = pin.INVALID
 	}
 
-	if hasSODimm {
-		if err := pinreg.Register("SO", [][]pin.Pin{
+	if f.hasSODimm {
+		if err := d.registerHeader("SO", [][]pin.Pin{
 			{SO_1, SO_2},
 			{SO_3, SO_4},
 			{SO_5, SO_6},
@@ -628,11 +582,14 @@ func (d *driver) Init() (bool, error) {
 		}
 	}
 
-	if hasAudio {
-		if !hasNewAudio {
-			AUDIO_LEFT = bcm283x.GPIO45 // PWM1
+	if len(f.audio) == 2 {
+		if p := bcmPin(f.audio[0]); p != nil {
+			AUDIO_RIGHT = p
 		}
-		if err := pinreg.Register("AUDIO", [][]pin.Pin{
+		if p := bcmPin(f.audio[1]); p != nil {
+			AUDIO_LEFT = p
+		}
+		if err := d.registerHeader("AUDIO", [][]pin.Pin{
 			{AUDIO_LEFT},
 			{AUDIO_RIGHT},
 		}); err != nil {
@@ -640,14 +597,181 @@ func (d *driver) Init() (bool, error) {
 		}
 	}
 
-	if hasHDMI {
-		if err := pinreg.Register("HDMI", [][]pin.Pin{{HDMI_HOTPLUG_DETECT}}); err != nil {
+	if f.hdmiHotplug != "" {
+		if p := bcmPin(f.hdmiHotplug); p != nil {
+			HDMI_HOTPLUG_DETECT = p
+		}
+		if err := d.registerHeader("HDMI", [][]pin.Pin{{HDMI_HOTPLUG_DETECT}}); err != nil {
 			return true, err
 		}
 	}
 	return true, nil
 }
 
+// features is the hardware present on a board.
+type features struct {
+	has26PinP1Header bool
+	has40PinP1Header bool
+	hasP5Header      bool
+	hasSODimm        bool
+	// audio is the name of the right and left PWM audio pins, e.g. "GPIO40"
+	// and "GPIO41", if the audio output is enabled.
+	audio []string
+	// hdmiHotplug is the name of the HDMI hotplug detection pin, e.g.
+	// "GPIO46", if it is connected to the SoC.
+	hdmiHotplug string
+}
+
+// featuresFromDT returns the features as described by the device tree.
+//
+// It returns false if the device tree is not for a Raspberry Pi.
+func featuresFromDT(t *distro.DeviceTree) (features, bool) {
+	var f features
+	model := ""
+	for _, c := range t.Root.Compatible() {
+		if strings.HasPrefix(c, "raspberrypi,") {
+			model = c[len("raspberrypi,"):]
+			break
+		}
+	}
+	switch model {
+	case "", "model-a", "model-b", "model-b-rev2":
+		// Some device trees use "model-b" for both PCB revisions, so the P5
+		// header can't be detected; the revision code is used instead.
+		return f, false
+	case "compute-module":
+		// SODIMM not defined
+	case "3-compute-module":
+		f.hasSODimm = true
+	default:
+		// All the boards since the B+ have the 40 pins header.
+		f.has40PinP1Header = true
+	}
+
+	// The PWM audio is muxed to different pins depending on the board and on
+	// overlays like audremap.
+	for _, n := range t.Compatible("brcm,bcm2835-audio") {
+		if !n.Enabled() {
+			continue
+		}
+		pins, err := t.Pinctrl(n, "default")
+		if err != nil {
+			continue
+		}
+		var audio []string
+		for _, p := range pins {
+			audio = append(audio, p.Pins()...)
+		}
+		if len(audio) == 2 {
+			f.audio = audio
+		}
+	}
+
+	// On the Raspberry Pi 3 and later, the hotplug detection is connected to
+	// the firmware's GPIO expander, not to the SoC.
+	for _, n := range t.Compatible("brcm,bcm2835-hdmi") {
+		if !n.Enabled() {
+			continue
+		}
+		gpios, err := t.GPIOs(n, "hpd-gpios")
+		if err != nil || len(gpios) != 1 || gpios[0].Node == nil || len(gpios[0].Args) == 0 {
+			continue
+		}
+		if gpios[0].Node.IsCompatible("brcm,bcm2835-gpio") {
+			f.hdmiHotplug = "GPIO" + strconv.Itoa(int(gpios[0].Args[0]))
+		}
+	}
+	return f, true
+}
+
+// featuresFromRevision returns the features as determined by the board
+// revision code from /proc/cpuinfo.
+//
+// This code is not futureproof, it will error out on a Raspberry Pi 4
+// whenever it comes out.
+// Revision codes from: http://elinux.org/RPi_HardwareHistory
+func featuresFromRevision(rev string) (features, error) {
+	var f features
+	i, err := strconv.ParseInt(rev, 16, 32)
+	if err != nil {
+		return f, fmt.Errorf("rpi: failed to read cpu_info: %v", err)
+	}
+	audio := []string{"GPIO40", "GPIO41"}
+	oldAudio := []string{"GPIO40", "GPIO45"}
+	// Ignore the overclock bit.
+	i &= 0xFFFFFF
+	switch i {
+	case 0x0002, 0x0003: // B v1.0
+		f.has26PinP1Header = true
+		f.audio = oldAudio
+	case 0x0004, 0x0005, 0x0006, // B v2.0
+		0x0007, 0x0008, 0x0009, // A v2.0
+		0x000d, 0x000e, 0x000f: // B v2.0
+		f.has26PinP1Header = true
+		// Only the v2 PCB has the P5 header.
+		f.hasP5Header = true
+		f.audio = oldAudio
+		f.hdmiHotplug = "GPIO46"
+	case 0x0010, // B+ v1.0
+		0x0012,             // A+ v1.1
+		0x0013,             // B+ v1.2
+		0x0015,             // A+ v1.1
+		0x90021,            // A+ v1.1
+		0x90032,            // B+ v1.2
+		0xa01040,           // 2 Model B v1.0
+		0xa01041, 0xa21041, // 2 Model B v1.1
+		0xa22042: // 2 Model B v1.2
+		f.has40PinP1Header = true
+		f.audio = oldAudio
+		f.hdmiHotplug = "GPIO46"
+	case 0x900092, // Zero v1.2
+		0x900093, // Zero v1.3
+		0x920093, // Zero v1.3
+		0x9000c1: // Zero W v1.1
+		f.has40PinP1Header = true
+		f.hdmiHotplug = "GPIO46"
+	case 0x0011, // Compute Module 1
+		0x0014: // Compute Module 1
+		// SODIMM not defined
+	case 0xa020a0: // Compute Module 3 v1.0
+		f.hasSODimm = true
+		// tell CM3 and CM3-Lite apart, if possible
+	case 0xa02082, 0xa22082, 0xa32082, 0xa020d3: // 3 Model B v1.2, B+
+		f.has40PinP1Header = true
+		f.audio = audio
+		f.hdmiHotplug = "GPIO46"
+	default:
+		return f, fmt.Errorf("rpi: unknown hardware version: 0x%x", i)
+	}
+	return f, nil
+}
+
+// bcmPin returns the bcm283x pin registered under name, or nil if the
+// bcm283x-gpio driver is not loaded.
+func bcmPin(name string) *bcm283x.Pin {
+	p, _ := gpioreg.ByName(name).(*bcm283x.Pin)
+	return p
+}
+
+// registerHeader registers a header and keeps track of it to unregister it
+// in Close().
+func (d *driver) registerHeader(name string, allPins [][]pin.Pin) error {
+	if err := pinreg.Register(name, allPins); err != nil {
+		return err
+	}
+	d.headers = append(d.headers, name)
+	return nil
+}
+
+// Close unregisters the headers.
+func (d *driver) Close() error {
+	for _, name := range d.headers {
+		_ = pinreg.Unregister(name)
+	}
+	d.headers = nil
+	return nil
+}
+
 func init() {
 	if isArm {
 		periph.MustRegister(&drv)
@@ -0,0 +1,81 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package rpi
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"periph.io/x/periph/host/distro"
+)
+
+func TestFeaturesFromDT(t *testing.T) {
+	d := loadDTB(t)
+	f, ok := featuresFromDT(d)
+	if !ok {
+		t.Fatal("expected a Raspberry Pi")
+	}
+	// The hotplug detection is on the firmware GPIO expander.
+	expected := features{has40PinP1Header: true, audio: []string{"GPIO40", "GPIO41"}}
+	if !reflect.DeepEqual(f, expected) {
+		t.Fatalf("%#v", f)
+	}
+
+	// Disabled audio.
+	d.Node("audio").Properties["status"] = []byte("disabled\x00")
+	if f, _ := featuresFromDT(d); f.audio != nil {
+		t.Fatal(f.audio)
+	}
+
+	// The revision code is used for the older boards.
+	d.Root.Properties["compatible"] = []byte("raspberrypi,model-b\x00brcm,bcm2835\x00")
+	if _, ok := featuresFromDT(d); ok {
+		t.Fatal("model-b must fall back to the revision code")
+	}
+	d.Root.Properties["compatible"] = []byte("raspberrypi,3-compute-module\x00brcm,bcm2837\x00")
+	if f, ok := featuresFromDT(d); !ok || !f.hasSODimm || f.has40PinP1Header {
+		t.Fatalf("%#v", f)
+	}
+	d.Root.Properties["compatible"] = []byte("pine64,pine64\x00allwinner,sun50i-a64\x00")
+	if _, ok := featuresFromDT(d); ok {
+		t.Fatal("not a Raspberry Pi")
+	}
+}
+
+func TestFeaturesFromRevision(t *testing.T) {
+	f, err := featuresFromRevision("000e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := features{has26PinP1Header: true, hasP5Header: true, audio: []string{"GPIO40", "GPIO45"}, hdmiHotplug: "GPIO46"}
+	if !reflect.DeepEqual(f, expected) {
+		t.Fatalf("%#v", f)
+	}
+	// The overclock bit is ignored.
+	if f, err := featuresFromRevision("1a02082"); err != nil || !f.has40PinP1Header {
+		t.Fatal(f, err)
+	}
+	if _, err := featuresFromRevision("c03111"); err == nil {
+		t.Fatal("unknown revision")
+	}
+	if _, err := featuresFromRevision(""); err == nil {
+		t.Fatal("invalid revision")
+	}
+}
+
+//
+
+func loadDTB(t *testing.T) *distro.DeviceTree {
+	b, err := ioutil.ReadFile("../distro/testdata/rpi3.dtb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := distro.ParseDTB(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"periph.io/x/periph"
@@ -30,7 +31,7 @@ import (
 // have gaps in the pin numbering.
 //
 // This global variable is initialized once at driver initialization and isn't
-// mutated afterward. Do not modify it.
+// mutated afterward, until periph.Shutdown() is called. Do not modify it.
 var Pins map[int]*Pin
 
 // Pin represents one GPIO pin as found by sysfs.
@@ -40,14 +41,22 @@ type Pin struct {
 	root   string // Something like /sys/class/gpio/gpio%d/
 
 	mu         sync.Mutex
-	err        error     // If open() failed
-	direction  direction // Cache of the last known direction
-	edge       gpio.Edge // Cache of the last edge used.
-	fDirection fileIO    // handle to /sys/class/gpio/gpio*/direction; never closed
-	fEdge      fileIO    // handle to /sys/class/gpio/gpio*/edge; never closed
-	fValue     fileIO    // handle to /sys/class/gpio/gpio*/value; never closed
-	event      fs.Event  // Initialized once
-	buf        [4]byte   // scratch buffer for Function(), Read() and Out()
+	err        error      // If open() failed
+	direction  direction  // Cache of the last known direction
+	edge       gpio.Edge  // Cache of the last edge used.
+	fDirection fileIO     // handle to /sys/class/gpio/gpio*/direction; closed by close()
+	fEdge      fileIO     // handle to /sys/class/gpio/gpio*/edge; closed by close()
+	fValue     fileIO     // handle to /sys/class/gpio/gpio*/value; closed by close()
+	event      fs.Event   // Initialized once
+	edges      *edgeQueue // Initialized by WaitForEdgeEvent(); reset by In()
+	buf        [4]byte    // scratch buffer for Function()
+
+	// valueMu serializes the accesses to fValue, since each seeks the file.
+	//
+	// It is separate from mu so the edge goroutine can read the level while
+	// stopEdges() waits for it with mu held. When both are taken, mu is taken
+	// first.
+	valueMu sync.Mutex
 }
 
 // String implements conn.Resource.
@@ -136,6 +145,7 @@ func (p *Pin) In(pull gpio.Pull, edge gpio.Edge) error {
 	}
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.stopEdges()
 	if p.direction != dIn {
 		if err := p.open(); err != nil {
 			return p.wrap(err)
@@ -191,57 +201,69 @@ func (p *Pin) In(pull gpio.Pull, edge gpio.Edge) error {
 	// CPU. In this case, the loop below is not sufficient, since the interrupt
 	// will happen afterward "out of the blue".
 	if edge != gpio.NoEdge {
-		p.WaitForEdge(0)
+		p.waitForEvent(0)
 	}
 	return nil
 }
 
 // Read implements gpio.PinIn.
 func (p *Pin) Read() gpio.Level {
-	// There's no lock here.
-	if p.fValue == nil {
-		return gpio.Low
-	}
-	if _, err := seekRead(p.fValue, p.buf[:]); err != nil {
-		// Error.
-		return gpio.Low
-	}
-	if p.buf[0] == '0' {
-		return gpio.Low
-	}
-	if p.buf[0] == '1' {
-		return gpio.High
-	}
-	// Error.
-	return gpio.Low
+	// Only valueMu is taken, so reading doesn't contend with a blocked call.
+	p.valueMu.Lock()
+	defer p.valueMu.Unlock()
+	return p.read()
 }
 
 // WaitForEdge implements gpio.PinIn.
 func (p *Pin) WaitForEdge(timeout time.Duration) bool {
-	// Run lockless, as the normal use is to call in a busy loop.
-	var ms int
-	if timeout == -1 {
-		ms = -1
-	} else {
-		ms = int(timeout / time.Millisecond)
+	// Only hold the lock to load the queue, as the normal use is to call in a
+	// busy loop.
+	p.mu.Lock()
+	q := p.edges
+	p.mu.Unlock()
+	if q != nil {
+		_, ok := q.pop(timeout)
+		return ok
 	}
-	start := time.Now()
-	for {
-		if nr, err := p.event.Wait(ms); err != nil {
-			return false
-		} else if nr == 1 {
-			// TODO(maruel): According to pigpio, the correct way to consume the
-			// interrupt is to call Seek().
-			return true
-		}
-		// A signal occurred.
-		if timeout != -1 {
-			ms = int((timeout - time.Since(start)) / time.Millisecond)
-		}
-		if ms <= 0 {
-			return false
+	return p.waitForEvent(timeout)
+}
+
+// WaitForEdgeEvent implements gpio.PinEdgeEvents.
+//
+// The first call starts a goroutine that timestamps the edges as soon as the
+// kernel signals them and reads the pin level. This goroutine is stopped by
+// In(), Out() and Halt(). Since the kernel coalesces edges that are not
+// processed in time, edges occurring in quick succession may still be
+// silently merged.
+//
+// The timestamp is taken in userspace when the goroutine wakes up, not by the
+// kernel, so it lags the edge by the interrupt and scheduling latency, which
+// is typically tens of µs and can reach milliseconds on a busy system. Use
+// LinePin for kernel timestamps.
+func (p *Pin) WaitForEdgeEvent(timeout time.Duration) (gpio.EdgeEvent, bool) {
+	p.mu.Lock()
+	q := p.edges
+	if q == nil {
+		if p.edge == gpio.NoEdge {
+			p.mu.Unlock()
+			return gpio.EdgeEvent{}, false
 		}
+		q = newEdgeQueue()
+		p.edges = q
+		go p.runEdges(q)
 	}
+	p.mu.Unlock()
+	return q.pop(timeout)
+}
+
+// EdgeOverflows implements gpio.PinEdgeEvents.
+func (p *Pin) EdgeOverflows() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.edges == nil {
+		return 0
+	}
+	return p.edges.overflows()
 }
 
 // Pull implements gpio.PinIn.
@@ -285,12 +307,14 @@ func (p *Pin) Out(l gpio.Level) error {
 		p.direction = dOut
 		return nil
 	}
-	if l == gpio.Low {
-		p.buf[0] = '0'
-	} else {
-		p.buf[0] = '1'
+	b := bZero
+	if l == gpio.High {
+		b = bOne
 	}
-	if err := seekWrite(p.fValue, p.buf[:1]); err != nil {
+	p.valueMu.Lock()
+	err := seekWrite(p.fValue, b)
+	p.valueMu.Unlock()
+	if err != nil {
 		return p.wrap(err)
 	}
 	return nil
@@ -298,8 +322,12 @@ func (p *Pin) Out(l gpio.Level) error {
 
 // PWM implements gpio.PinOut.
 //
-// This is not supported on sysfs.
-func (p *Pin) PWM(gpio.Duty, physic.Frequency) error {
+// This is only supported when a sysfs PWM channel is known to drive this pin;
+// see PWMByPin().
+func (p *Pin) PWM(d gpio.Duty, f physic.Frequency) error {
+	if pw := PWMByPin(p.name); pw != nil {
+		return pw.PWM(d, f)
+	}
 	return p.wrap(errors.New("pwm is not supported via sysfs"))
 }
 
@@ -331,8 +359,9 @@ func (p *Pin) open() error {
 	// It's simpler to just loop a little as if /export is accessible, it doesn't
 	// make sense that gpioN/value doesn't become accessible eventually.
 	timeout := 5 * time.Second
+	var f fileIO
 	for start := time.Now(); time.Since(start) < timeout; {
-		p.fValue, err = fileIOOpen(p.root+"value", os.O_RDWR)
+		f, err = fileIOOpen(p.root+"value", os.O_RDWR)
 		// The virtual file creation is synchronous when writing to /export for
 		// udev rule execution is asynchronous.
 		if err == nil {
@@ -349,29 +378,235 @@ func (p *Pin) open() error {
 	p.fDirection, err = fileIOOpen(p.root+"direction", os.O_RDWR)
 	if err != nil {
 		p.err = err
-		_ = p.fValue.Close()
-		p.fValue = nil
+		_ = f.Close()
+		return p.err
 	}
-	return p.err
+	p.valueMu.Lock()
+	p.fValue = f
+	p.valueMu.Unlock()
+	return nil
+}
+
+// close stops edge detection and closes the handles.
+//
+// It returns true if the pin was exported by open().
+func (p *Pin) close() (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopEdges()
+	exported := p.fDirection != nil
+	err := p.event.Close()
+	p.valueMu.Lock()
+	for _, f := range []*fileIO{&p.fEdge, &p.fDirection, &p.fValue} {
+		if *f != nil {
+			if err2 := (*f).Close(); err2 != nil && err == nil {
+				err = err2
+			}
+			*f = nil
+		}
+	}
+	p.valueMu.Unlock()
+	p.err = nil
+	p.direction = dUnknown
+	p.edge = gpio.NoEdge
+	if err != nil {
+		return exported, p.wrap(err)
+	}
+	return exported, nil
 }
 
 // haltEdge stops any on-going edge detection.
 func (p *Pin) haltEdge() error {
+	p.stopEdges()
 	if p.edge != gpio.NoEdge {
 		if err := seekWrite(p.fEdge, bNone); err != nil {
 			return p.wrap(err)
 		}
 		p.edge = gpio.NoEdge
 		// This is still important to remove an accumulated edge.
-		p.WaitForEdge(0)
+		p.waitForEvent(0)
 	}
 	return nil
 }
 
+// runEdges pushes the edges into q until it is stopped.
+func (p *Pin) runEdges(q *edgeQueue) {
+	defer close(q.stopped)
+	for {
+		select {
+		case <-q.done:
+			return
+		default:
+		}
+		// Use a timeout to poll q.done regularly.
+		nr, err := p.event.Wait(edgePollMs)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			return
+		}
+		if nr == 1 {
+			// Timestamp and sample the level right at wakeup, as close as possible
+			// to the edge. Reading also acknowledges the edge.
+			t := time.Now()
+			p.valueMu.Lock()
+			l := p.read()
+			p.valueMu.Unlock()
+			q.push(gpio.EdgeEvent{T: t, L: l})
+		}
+	}
+}
+
+// read returns the current level.
+//
+// valueMu must be held.
+func (p *Pin) read() gpio.Level {
+	if p.fValue == nil {
+		return gpio.Low
+	}
+	var b [4]byte
+	if _, err := seekRead(p.fValue, b[:]); err != nil {
+		// Error.
+		return gpio.Low
+	}
+	if b[0] == '1' {
+		return gpio.High
+	}
+	// '0' or error.
+	return gpio.Low
+}
+
+// waitForEvent waits for the kernel to signal an edge on the value file.
+//
+// It is used when WaitForEdgeEvent() is not running, and by In() and
+// haltEdge() with the lock held to flush accumulated edges.
+func (p *Pin) waitForEvent(timeout time.Duration) bool {
+	var ms int
+	if timeout == -1 {
+		ms = -1
+	} else {
+		ms = int(timeout / time.Millisecond)
+	}
+	start := time.Now()
+	for {
+		if nr, err := p.event.Wait(ms); err != nil {
+			return false
+		} else if nr == 1 {
+			// TODO(maruel): According to pigpio, the correct way to consume the
+			// interrupt is to call Seek().
+			return true
+		}
+		// A signal occurred.
+		if timeout != -1 {
+			ms = int((timeout - time.Since(start)) / time.Millisecond)
+		}
+		if ms <= 0 {
+			return false
+		}
+	}
+}
+
+// stopEdges stops the goroutine started by WaitForEdgeEvent(), if any.
+//
+// lock must be held.
+func (p *Pin) stopEdges() {
+	if p.edges != nil {
+		p.edges.stop()
+		p.edges = nil
+	}
+}
+
 func (p *Pin) wrap(err error) error {
 	return fmt.Errorf("sysfs-gpio (%s): %v", p, err)
 }
 
+// edgeQueueSize is the number of edges that can be accumulated by
+// WaitForEdgeEvent() before the older ones are discarded.
+const edgeQueueSize = 64
+
+// edgePollMs is the interval at which the edge goroutine checks if it has to
+// stop.
+const edgePollMs = 100
+
+// edgeQueue is a bounded queue of edges.
+type edgeQueue struct {
+	done    chan struct{} // closed to stop the goroutine
+	stopped chan struct{} // closed by the goroutine when it exits
+	wake    chan struct{} // signaled when an edge is pushed
+
+	mu       sync.Mutex
+	events   [edgeQueueSize]gpio.EdgeEvent
+	first    int // index of the oldest edge
+	count    int // number of edges queued
+	overflow int // number of edges discarded
+}
+
+func newEdgeQueue() *edgeQueue {
+	return &edgeQueue{
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// push adds an edge, discarding the oldest one if the queue is full.
+func (q *edgeQueue) push(e gpio.EdgeEvent) {
+	q.mu.Lock()
+	if q.count == len(q.events) {
+		q.first = (q.first + 1) % len(q.events)
+		q.count--
+		q.overflow++
+	}
+	q.events[(q.first+q.count)%len(q.events)] = e
+	q.count++
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pop returns the oldest edge, waiting up to timeout for one to be pushed.
+func (q *edgeQueue) pop(timeout time.Duration) (gpio.EdgeEvent, bool) {
+	var after <-chan time.Time
+	if timeout != -1 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		after = t.C
+	}
+	for {
+		q.mu.Lock()
+		if q.count != 0 {
+			e := q.events[q.first]
+			q.first = (q.first + 1) % len(q.events)
+			q.count--
+			q.mu.Unlock()
+			return e, true
+		}
+		q.mu.Unlock()
+		select {
+		case <-q.wake:
+		case <-q.done:
+			return gpio.EdgeEvent{}, false
+		case <-after:
+			return gpio.EdgeEvent{}, false
+		}
+	}
+}
+
+func (q *edgeQueue) overflows() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.overflow
+}
+
+// stop stops the goroutine and waits for it to exit.
+func (q *edgeQueue) stop() {
+	close(q.done)
+	<-q.stopped
+}
+
 //
 
 type direction int
@@ -390,6 +625,8 @@ var (
 	bRising  = []byte("rising")
 	bFalling = []byte("falling")
 	bBoth    = []byte("both")
+	bZero    = []byte("0")
+	bOne     = []byte("1")
 )
 
 // readInt reads a pseudo-file (sysfs) that is known to contain an integer and
@@ -463,6 +700,47 @@ func (d *driverGPIO) Init() (bool, error) {
 	return true, err
 }
 
+// Close unexports the pins that were exported, closes their handles and
+// unregisters them.
+func (d *driverGPIO) Close() error {
+	var err error
+	var unexport fileIO
+	for _, p := range Pins {
+		exported, err2 := p.close()
+		if exported && err2 == nil {
+			if unexport == nil {
+				unexport, err2 = fileIOOpen("/sys/class/gpio/unexport", os.O_WRONLY)
+			}
+			if err2 == nil {
+				if _, err2 = unexport.Write([]byte(strconv.Itoa(p.number))); err2 != nil {
+					err2 = p.wrap(err2)
+				}
+			}
+		}
+		if err2 != nil && err == nil {
+			err = err2
+		}
+		// Only unregister the pin if it wasn't superseded by another driver.
+		if gpioreg.ByName(p.name) == gpio.PinIO(p) {
+			_ = gpioreg.Unregister(p.name)
+			_ = gpioreg.Unregister(strconv.Itoa(p.number))
+		}
+	}
+	if unexport != nil {
+		if err2 := unexport.Close(); err2 != nil && err == nil {
+			err = err2
+		}
+	}
+	if c, ok := d.exportHandle.(io.Closer); ok {
+		if err2 := c.Close(); err2 != nil && err == nil {
+			err = err2
+		}
+	}
+	d.exportHandle = nil
+	Pins = nil
+	return err
+}
+
 func (d *driverGPIO) parseGPIOChip(path string) error {
 	base, err := readInt(path + "base")
 	if err != nil {
@@ -508,4 +786,5 @@ var _ conn.Resource = &Pin{}
 var _ gpio.PinIn = &Pin{}
 var _ gpio.PinOut = &Pin{}
 var _ gpio.PinIO = &Pin{}
+var _ gpio.PinEdgeEvents = &Pin{}
 var _ pin.PinFunc = &Pin{}
@@ -7,8 +7,10 @@ package sysfs
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
 	"periph.io/x/periph/conn/physic"
 )
 
@@ -138,6 +140,66 @@ func TestPin_WaitForEdges(t *testing.T) {
 	}
 }
 
+func TestPin_WaitForEdgeEvent(t *testing.T) {
+	p := Pin{number: 42, name: "foo", root: "/tmp/gpio/priv/"}
+	if _, ok := p.WaitForEdgeEvent(-1); ok {
+		t.Fatal("edge detection is not enabled")
+	}
+	if o := p.EdgeOverflows(); o != 0 {
+		t.Fatal(o)
+	}
+	// The goroutine exits immediately since the event is not initialized.
+	p.edge = gpio.RisingEdge
+	if _, ok := p.WaitForEdgeEvent(0); ok {
+		t.Fatal("broken pin doesn't have edge triggered")
+	}
+	if p.edges == nil {
+		t.Fatal("expected queue")
+	}
+	p.edges.push(gpio.EdgeEvent{L: gpio.High})
+	if !p.WaitForEdge(0) {
+		t.Fatal("expected queued edge")
+	}
+	p.mu.Lock()
+	p.stopEdges()
+	p.mu.Unlock()
+	if p.edges != nil {
+		t.Fatal("expected queue to be reset")
+	}
+}
+
+func TestEdgeQueue(t *testing.T) {
+	q := newEdgeQueue()
+	start := time.Now()
+	for i := 0; i < edgeQueueSize+2; i++ {
+		q.push(gpio.EdgeEvent{T: start.Add(time.Duration(i)), L: i&1 != 0})
+	}
+	if o := q.overflows(); o != 2 {
+		t.Fatal(o)
+	}
+	for i := 2; i < edgeQueueSize+2; i++ {
+		e, ok := q.pop(0)
+		if !ok || !e.T.Equal(start.Add(time.Duration(i))) || e.L != (i&1 != 0) {
+			t.Fatal(i, e, ok)
+		}
+	}
+	if _, ok := q.pop(time.Millisecond); ok {
+		t.Fatal("queue is empty")
+	}
+	go func() {
+		time.Sleep(time.Millisecond)
+		q.push(gpio.EdgeEvent{L: gpio.High})
+	}()
+	if e, ok := q.pop(-1); !ok || e.L != gpio.High {
+		t.Fatal(e, ok)
+	}
+	close(q.stopped)
+	q.stop()
+	if _, ok := q.pop(-1); ok {
+		t.Fatal("queue is stopped")
+	}
+}
+
 func TestPin_Pull(t *testing.T) {
 	p := Pin{number: 42, name: "foo", root: "/tmp/gpio/priv/"}
 	if pull := p.Pull(); pull != gpio.PullNoChange {
@@ -208,8 +270,50 @@ func TestGPIODriver(t *testing.T) {
 	}
 }
 
+func TestGPIODriver_Close(t *testing.T) {
+	defer reset()
+	var unexported []string
+	fileIOOpen = func(path string, flag int) (fileIO, error) {
+		if path != "/sys/class/gpio/unexport" {
+			t.Fatal(path)
+		}
+		return &fakeUnexportFile{writes: &unexported}, nil
+	}
+	exported := &Pin{number: 42, name: "GPIO42", direction: dOut, fDirection: &fakeGPIOFile{}, fValue: &fakeGPIOFile{}}
+	idle := &Pin{number: 43, name: "GPIO43"}
+	Pins = map[int]*Pin{42: exported, 43: idle}
+	for _, p := range Pins {
+		if err := gpioreg.Register(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := driverGPIO{exportHandle: &fakeGPIOFile{}}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(unexported) != 1 || unexported[0] != "42" {
+		t.Fatal(unexported)
+	}
+	if exported.fDirection != nil || exported.fValue != nil || exported.direction != dUnknown {
+		t.Fatal("handles were not closed")
+	}
+	if gpioreg.ByName("GPIO42") != nil || gpioreg.ByName("GPIO43") != nil || Pins != nil || d.exportHandle != nil {
+		t.Fatal("pins were not unregistered")
+	}
+}
+
 //
 
+type fakeUnexportFile struct {
+	fakeGPIOFile
+	writes *[]string
+}
+
+func (f *fakeUnexportFile) Write(b []byte) (int, error) {
+	*f.writes = append(*f.writes, string(b))
+	return len(b), nil
+}
+
 type fakeGPIOFile struct {
 	data []byte
 }
@@ -0,0 +1,970 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unsafe"
+
+	"periph.io/x/periph"
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/pin"
+	"periph.io/x/periph/host/fs"
+)
+
+// LinePins is all the GPIO lines exported by the gpiochip character devices.
+//
+// The lines are numbered with the base of their chip as exposed by GPIO sysfs
+// in /sys/class/gpio/gpiochipN/base, so the numbers match the ones used by
+// sysfs-gpio. A chip without a sysfs base is numbered after the highest number
+// in use; its lines do not supersede the sysfs pins.
+//
+// This global variable is initialized once at driver initialization and isn't
+// mutated afterward, until periph.Shutdown() is called. Do not modify it.
+var LinePins map[int]*LinePin
+
+// Drive specifies how an output line is driven.
+type Drive uint8
+
+// Acceptable drive values.
+const (
+	PushPull   Drive = 0 // Drive both low and high
+	OpenDrain  Drive = 1 // Drive low, float high
+	OpenSource Drive = 2 // Drive high, float low
+)
+
+const driveName = "PushPullOpenDrainOpenSource"
+
+var driveIndex = [...]uint8{0, 8, 17, 27}
+
+func (i Drive) String() string {
+	if i >= Drive(len(driveIndex)-1) {
+		return "Drive(" + strconv.Itoa(int(i)) + ")"
+	}
+	return driveName[driveIndex[i]:driveIndex[i+1]]
+}
+
+// LineEvent is an edge detected by the kernel on a GPIO line.
+type LineEvent struct {
+	// T is the kernel timestamp of the edge, based on CLOCK_MONOTONIC.
+	T time.Duration
+	// Edge is either RisingEdge or FallingEdge.
+	Edge gpio.Edge
+	// Seq is the sequence number of this event for this line. A gap in the
+	// sequence means that the kernel dropped events.
+	Seq uint32
+}
+
+// LinePin represents one GPIO line as found via a gpiochip character device
+// /dev/gpiochipN.
+//
+// Contrary to Pin, it supports the pull resistor, open drain and open source
+// outputs and returns edges with the kernel timestamp. It implements
+// gpio.PinEdgeEvents.
+type LinePin struct {
+	number int
+	name   string
+	chip   *gpioChip
+	offset uint32
+	label  string // Name of the line as reported by the kernel, if any
+
+	mu        sync.Mutex
+	h         gpioLineIO // handle of the line request; nil when not requested
+	direction direction  // Cache of the last known direction
+	edge      gpio.Edge  // Cache of the last edge used
+	pull      gpio.Pull  // Cache of the last pull used
+	drive     Drive      // Drive to use on the next Out() call
+	events    [16]gpioV2LineEvent
+	pending   []gpioV2LineEvent // events read but not yet returned
+	lastSeq   uint32            // line_seqno of the last event read
+	overflows int               // events dropped by the kernel since In()
+}
+
+// String implements conn.Resource.
+func (p *LinePin) String() string {
+	return p.name
+}
+
+// Halt implements conn.Resource.
+//
+// It releases the line so it can be used by another process.
+func (p *LinePin) Halt() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.release()
+}
+
+// Name implements pin.Pin.
+func (p *LinePin) Name() string {
+	return p.name
+}
+
+// Number implements pin.Pin.
+func (p *LinePin) Number() int {
+	return p.number
+}
+
+// Function implements pin.Pin.
+func (p *LinePin) Function() string {
+	return string(p.Func())
+}
+
+// Func implements pin.PinFunc.
+func (p *LinePin) Func() pin.Func {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.h == nil {
+		// The line is not requested by this process, ask the kernel.
+		var info gpioV2LineInfo
+		if err := p.chip.lineInfo(p.offset, &info); err != nil {
+			return pin.Func("ERR")
+		}
+		if info.flags&lineFlagOutput != 0 {
+			return gpio.OUT
+		}
+		return gpio.IN
+	}
+	l, err := p.read()
+	if err != nil {
+		return pin.Func("ERR")
+	}
+	if p.direction == dIn {
+		if l {
+			return gpio.IN_HIGH
+		}
+		return gpio.IN_LOW
+	}
+	if l {
+		return gpio.OUT_HIGH
+	}
+	return gpio.OUT_LOW
+}
+
+// SupportedFuncs implements pin.PinFunc.
+func (p *LinePin) SupportedFuncs() []pin.Func {
+	return []pin.Func{gpio.IN, gpio.OUT, gpio.OUT_OC}
+}
+
+// SetFunc implements pin.PinFunc.
+//
+// gpio.OUT_OC sets the line as an open drain output set high, which lets the
+// line float.
+func (p *LinePin) SetFunc(f pin.Func) error {
+	switch f {
+	case gpio.IN:
+		return p.In(gpio.PullNoChange, gpio.NoEdge)
+	case gpio.OUT_HIGH:
+		return p.Out(gpio.High)
+	case gpio.OUT, gpio.OUT_LOW:
+		return p.Out(gpio.Low)
+	case gpio.OUT_OC, gpio.FLOAT:
+		if err := p.SetDrive(OpenDrain); err != nil {
+			return err
+		}
+		return p.Out(gpio.High)
+	default:
+		return p.wrap(errors.New("unsupported function"))
+	}
+}
+
+// In implements gpio.PinIn.
+func (p *LinePin) In(pull gpio.Pull, edge gpio.Edge) error {
+	if pull > gpio.PullUp {
+		return p.wrap(errors.New("invalid pull"))
+	}
+	if edge > gpio.BothEdges {
+		return p.wrap(errors.New("invalid edge"))
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pull == gpio.PullNoChange {
+		pull = p.pull
+	}
+	flags := lineFlagInput | pullFlags(pull)
+	if edge == gpio.RisingEdge || edge == gpio.BothEdges {
+		flags |= lineFlagEdgeRising
+	}
+	if edge == gpio.FallingEdge || edge == gpio.BothEdges {
+		flags |= lineFlagEdgeFalling
+	}
+	if err := p.configure(flags, gpio.Low); err != nil {
+		return p.wrap(err)
+	}
+	p.direction = dIn
+	p.edge = edge
+	p.pull = pull
+	// Flush the accumulated edges. Contrary to sysfs, the kernel queues the
+	// events so this is reliable.
+	p.pending = nil
+	p.overflows = 0
+	for {
+		if nr, err := p.h.Wait(0); err != nil || nr == 0 {
+			break
+		}
+		n, err := p.h.Read(p.eventsBuf())
+		if err != nil || n < eventSize {
+			break
+		}
+		p.lastSeq = p.events[n/eventSize-1].lineSeqno
+	}
+	return nil
+}
+
+// Read implements gpio.PinIn.
+func (p *LinePin) Read() gpio.Level {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.h == nil {
+		return gpio.Low
+	}
+	l, err := p.read()
+	if err != nil {
+		return gpio.Low
+	}
+	return l
+}
+
+// WaitForEdge implements gpio.PinIn.
+func (p *LinePin) WaitForEdge(timeout time.Duration) bool {
+	_, ok := p.WaitForEvent(timeout)
+	return ok
+}
+
+// WaitForEvent waits for the next edge or immediately return if an edge
+// occurred since the last call and returns it with its kernel timestamp.
+//
+// The kernel queues the edges so multiple edges occurring between two calls
+// are not coalesced, up to the queue size.
+//
+// Specify -1 to effectively disable timeout.
+func (p *LinePin) WaitForEvent(timeout time.Duration) (LineEvent, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.waitForEvent(timeout)
+	if !ok {
+		return LineEvent{}, false
+	}
+	out := LineEvent{T: time.Duration(e.timestampNs), Seq: e.lineSeqno, Edge: gpio.RisingEdge}
+	if e.id == lineEventFallingEdge {
+		out.Edge = gpio.FallingEdge
+	}
+	return out, true
+}
+
+// WaitForEdgeEvent implements gpio.PinEdgeEvents.
+//
+// The timestamp is taken by the kernel when the edge occurred and converted
+// from CLOCK_MONOTONIC to wall time.
+func (p *LinePin) WaitForEdgeEvent(timeout time.Duration) (gpio.EdgeEvent, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.waitForEvent(timeout)
+	if !ok {
+		return gpio.EdgeEvent{}, false
+	}
+	out := gpio.EdgeEvent{
+		T: time.Now().Add(time.Duration(e.timestampNs) - monotonicNow()),
+		L: e.id == lineEventRisingEdge,
+	}
+	return out, true
+}
+
+// EdgeOverflows implements gpio.PinEdgeEvents.
+//
+// It returns the number of edges dropped by the kernel because its queue was
+// full, as detected by gaps in the events sequence numbers.
+func (p *LinePin) EdgeOverflows() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.overflows
+}
+
+// Pull implements gpio.PinIn.
+func (p *LinePin) Pull() gpio.Pull {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pull != gpio.PullNoChange {
+		return p.pull
+	}
+	var info gpioV2LineInfo
+	if err := p.chip.lineInfo(p.offset, &info); err != nil {
+		return gpio.PullNoChange
+	}
+	switch {
+	case info.flags&lineFlagBiasPullUp != 0:
+		return gpio.PullUp
+	case info.flags&lineFlagBiasPullDown != 0:
+		return gpio.PullDown
+	case info.flags&lineFlagBiasDisabled != 0:
+		return gpio.Float
+	default:
+		return gpio.PullNoChange
+	}
+}
+
+// DefaultPull implements gpio.PinIn.
+//
+// It returns gpio.PullNoChange since the kernel doesn't expose this
+// information.
+func (p *LinePin) DefaultPull() gpio.Pull {
+	return gpio.PullNoChange
+}
+
+// Out implements gpio.PinOut.
+func (p *LinePin) Out(l gpio.Level) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.h != nil && p.direction == dOut {
+		v := gpioV2LineValues{mask: 1}
+		if l {
+			v.bits = 1
+		}
+		if err := p.h.setValues(&v); err != nil {
+			return p.wrap(err)
+		}
+		return nil
+	}
+	if err := p.configure(lineFlagOutput|driveFlags(p.drive), l); err != nil {
+		return p.wrap(err)
+	}
+	p.direction = dOut
+	p.edge = gpio.NoEdge
+	p.pending = nil
+	return nil
+}
+
+// PWM implements gpio.PinOut.
+//
+// This is not supported on gpiochip.
+func (p *LinePin) PWM(gpio.Duty, physic.Frequency) error {
+	return p.wrap(errors.New("pwm is not supported via gpiochip"))
+}
+
+// SetDrive sets how the line is driven when set as an output.
+//
+// If the line is currently an output, it is immediately reconfigured,
+// otherwise the drive takes effect on the next Out() call.
+func (p *LinePin) SetDrive(d Drive) error {
+	if d > OpenSource {
+		return p.wrap(errors.New("invalid drive"))
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.drive == d {
+		return nil
+	}
+	if p.h != nil && p.direction == dOut {
+		l, err := p.read()
+		if err != nil {
+			return p.wrap(err)
+		}
+		if err := p.configure(lineFlagOutput|driveFlags(d), l); err != nil {
+			return p.wrap(err)
+		}
+	}
+	p.drive = d
+	return nil
+}
+
+// Label returns the name of the line as reported by the kernel, if any.
+func (p *LinePin) Label() string {
+	return p.label
+}
+
+//
+
+// configure requests the line if needed and sets its configuration.
+//
+// lock must be held.
+func (p *LinePin) configure(flags uint64, l gpio.Level) error {
+	var cfg gpioV2LineConfig
+	cfg.flags = flags
+	if flags&lineFlagOutput != 0 {
+		cfg.numAttrs = 1
+		cfg.attrs[0].attr.id = lineAttrIDOutputValues
+		cfg.attrs[0].mask = 1
+		if l {
+			cfg.attrs[0].attr.value = 1
+		}
+	}
+	if p.h != nil {
+		return p.h.setConfig(&cfg)
+	}
+	req := gpioV2LineRequest{config: cfg, numLines: 1}
+	req.offsets[0] = p.offset
+	copy(req.consumer[:], "periph")
+	if err := p.chip.f.getLine(&req); err != nil {
+		if os.IsPermission(err) {
+			return fmt.Errorf("need more access, try as root or setup udev rules: %v", err)
+		}
+		return err
+	}
+	h, err := gpioLineOpen(uintptr(req.fd), p.name)
+	if err != nil {
+		return err
+	}
+	p.h = h
+	return nil
+}
+
+// read returns the current level of the line.
+//
+// lock must be held and the line must be requested.
+func (p *LinePin) read() (gpio.Level, error) {
+	v := gpioV2LineValues{mask: 1}
+	if err := p.h.getValues(&v); err != nil {
+		return gpio.Low, err
+	}
+	return v.bits&1 != 0, nil
+}
+
+// waitForEvent returns the next event queued by the kernel, waiting up to
+// timeout for one.
+//
+// lock must be held.
+func (p *LinePin) waitForEvent(timeout time.Duration) (gpioV2LineEvent, bool) {
+	if len(p.pending) == 0 {
+		if p.h == nil {
+			return gpioV2LineEvent{}, false
+		}
+		var ms int
+		if timeout == -1 {
+			ms = -1
+		} else {
+			ms = int(timeout / time.Millisecond)
+		}
+		start := time.Now()
+		for {
+			nr, err := p.h.Wait(ms)
+			if err != nil {
+				return gpioV2LineEvent{}, false
+			}
+			if nr == 1 {
+				break
+			}
+			// A signal occurred.
+			if timeout != -1 {
+				ms = int((timeout - time.Since(start)) / time.Millisecond)
+			}
+			if ms <= 0 {
+				return gpioV2LineEvent{}, false
+			}
+		}
+		n, err := p.h.Read(p.eventsBuf())
+		if err != nil || n < eventSize {
+			return gpioV2LineEvent{}, false
+		}
+		p.pending = p.events[:n/eventSize]
+	}
+	e := p.pending[0]
+	p.pending = p.pending[1:]
+	if p.lastSeq != 0 && e.lineSeqno > p.lastSeq+1 {
+		p.overflows += int(e.lineSeqno - p.lastSeq - 1)
+	}
+	p.lastSeq = e.lineSeqno
+	return e, true
+}
+
+// release releases the line request, if any.
+//
+// lock must be held.
+func (p *LinePin) release() error {
+	if p.h == nil {
+		return nil
+	}
+	err := p.h.Close()
+	p.h = nil
+	p.direction = dUnknown
+	p.edge = gpio.NoEdge
+	p.pending = nil
+	p.lastSeq = 0
+	if err != nil {
+		return p.wrap(err)
+	}
+	return nil
+}
+
+func (p *LinePin) eventsBuf() []byte {
+	return (*[len(p.events) * eventSize]byte)(unsafe.Pointer(&p.events[0]))[:]
+}
+
+func (p *LinePin) wrap(err error) error {
+	return fmt.Errorf("sysfs-gpiochip (%s): %v", p, err)
+}
+
+func pullFlags(pull gpio.Pull) uint64 {
+	switch pull {
+	case gpio.Float:
+		return lineFlagBiasDisabled
+	case gpio.PullDown:
+		return lineFlagBiasPullDown
+	case gpio.PullUp:
+		return lineFlagBiasPullUp
+	default:
+		return 0
+	}
+}
+
+func driveFlags(d Drive) uint64 {
+	switch d {
+	case OpenDrain:
+		return lineFlagOpenDrain
+	case OpenSource:
+		return lineFlagOpenSource
+	default:
+		return 0
+	}
+}
+
+// gpioChip is an open handle to /dev/gpiochipN.
+type gpioChip struct {
+	name  string
+	label string
+	f     gpioChipIO
+	lines uint32
+}
+
+func newGPIOChip(path string) (*gpioChip, error) {
+	f, err := gpioChipOpen(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &gpioChip{f: f}
+	var info gpiochipInfo
+	if err := f.chipInfo(&info); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	c.name = cString(info.name[:])
+	c.label = cString(info.label[:])
+	c.lines = info.lines
+	return c, nil
+}
+
+func (c *gpioChip) lineInfo(offset uint32, info *gpioV2LineInfo) error {
+	info.offset = offset
+	return c.f.lineInfo(info)
+}
+
+// gpioChipIO is the handle to /dev/gpiochipN.
+//
+// Each method issues the IOCTL for its request structure.
+type gpioChipIO interface {
+	io.Closer
+	chipInfo(info *gpiochipInfo) error
+	lineInfo(info *gpioV2LineInfo) error
+	getLine(req *gpioV2LineRequest) error
+}
+
+var gpioChipOpen = gpioChipOpenDefault
+
+func gpioChipOpenDefault(path string) (gpioChipIO, error) {
+	f, err := ioctlOpen(path, os.O_RDWR)
+	if err != nil {
+		return nil, err
+	}
+	return &gpioChipFile{f}, nil
+}
+
+// gpioChipFile implements gpioChipIO.
+type gpioChipFile struct {
+	ioctlCloser
+}
+
+func (c *gpioChipFile) chipInfo(info *gpiochipInfo) error {
+	return c.Ioctl(ioctlGetChipInfo, uintptr(unsafe.Pointer(info)))
+}
+
+func (c *gpioChipFile) lineInfo(info *gpioV2LineInfo) error {
+	return c.Ioctl(ioctlGetLineInfo, uintptr(unsafe.Pointer(info)))
+}
+
+func (c *gpioChipFile) getLine(req *gpioV2LineRequest) error {
+	return c.Ioctl(ioctlGetLine, uintptr(unsafe.Pointer(req)))
+}
+
+// gpioLineIO is the handle returned by the kernel for a line request.
+type gpioLineIO interface {
+	io.Closer
+	io.Reader
+	// Wait waits for an edge event to be available to be read.
+	Wait(timeoutms int) (int, error)
+	setConfig(cfg *gpioV2LineConfig) error
+	getValues(v *gpioV2LineValues) error
+	setValues(v *gpioV2LineValues) error
+}
+
+var gpioLineOpen = gpioLineOpenDefault
+
+// monotonicNow returns the current CLOCK_MONOTONIC time, to convert the events
+// timestamps to wall time.
+var monotonicNow = monotonicNowDefault
+
+func gpioLineOpenDefault(fd uintptr, name string) (gpioLineIO, error) {
+	l := &gpioLine{File: fs.File{File: os.NewFile(fd, name)}}
+	if err := l.event.MakeReadEvent(fd); err != nil {
+		_ = l.File.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// gpioLine implements gpioLineIO.
+type gpioLine struct {
+	fs.File
+	event fs.Event
+}
+
+func (l *gpioLine) Wait(timeoutms int) (int, error) {
+	return l.event.Wait(timeoutms)
+}
+
+func (l *gpioLine) setConfig(cfg *gpioV2LineConfig) error {
+	return l.Ioctl(ioctlLineSetConfig, uintptr(unsafe.Pointer(cfg)))
+}
+
+func (l *gpioLine) getValues(v *gpioV2LineValues) error {
+	return l.Ioctl(ioctlLineGetValues, uintptr(unsafe.Pointer(v)))
+}
+
+func (l *gpioLine) setValues(v *gpioV2LineValues) error {
+	return l.Ioctl(ioctlLineSetValues, uintptr(unsafe.Pointer(v)))
+}
+
+func (l *gpioLine) Close() error {
+	err := l.event.Close()
+	if err2 := l.File.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+// gpiochip character device IOCTL control codes.
+//
+// Constants and structure definition can be found at
+// /usr/include/linux/gpio.h.
+const (
+	ioctlGetChipInfo   = 0x8044B401 // GPIO_GET_CHIPINFO_IOCTL
+	ioctlGetLineInfo   = 0xC100B405 // GPIO_V2_GET_LINEINFO_IOCTL
+	ioctlGetLine       = 0xC250B407 // GPIO_V2_GET_LINE_IOCTL
+	ioctlLineSetConfig = 0xC110B40D // GPIO_V2_LINE_SET_CONFIG_IOCTL
+	ioctlLineGetValues = 0xC010B40E // GPIO_V2_LINE_GET_VALUES_IOCTL
+	ioctlLineSetValues = 0xC010B40F // GPIO_V2_LINE_SET_VALUES_IOCTL
+)
+
+// gpio_v2_line_flag
+const (
+	lineFlagUsed         = 1 << 0
+	lineFlagActiveLow    = 1 << 1
+	lineFlagInput        = 1 << 2
+	lineFlagOutput       = 1 << 3
+	lineFlagEdgeRising   = 1 << 4
+	lineFlagEdgeFalling  = 1 << 5
+	lineFlagOpenDrain    = 1 << 6
+	lineFlagOpenSource   = 1 << 7
+	lineFlagBiasPullUp   = 1 << 8
+	lineFlagBiasPullDown = 1 << 9
+	lineFlagBiasDisabled = 1 << 10
+)
+
+// gpio_v2_line_attr_id
+const (
+	lineAttrIDFlags        = 1
+	lineAttrIDOutputValues = 2
+	lineAttrIDDebounce     = 3
+)
+
+// gpio_v2_line_event_id
+const (
+	lineEventRisingEdge  = 1
+	lineEventFallingEdge = 2
+)
+
+const eventSize = int(unsafe.Sizeof(gpioV2LineEvent{}))
+
+type gpiochipInfo struct {
+	name  [32]byte
+	label [32]byte
+	lines uint32
+}
+
+type gpioV2LineAttribute struct {
+	id      uint32
+	padding uint32
+	value   uint64 // flags, values or debounce_period_us depending on id
+}
+
+type gpioV2LineConfigAttribute struct {
+	attr gpioV2LineAttribute
+	mask uint64
+}
+
+type gpioV2LineConfig struct {
+	flags    uint64
+	numAttrs uint32
+	padding  [5]uint32
+	attrs    [10]gpioV2LineConfigAttribute
+}
+
+type gpioV2LineRequest struct {
+	offsets         [64]uint32
+	consumer        [32]byte
+	config          gpioV2LineConfig
+	numLines        uint32
+	eventBufferSize uint32
+	padding         [5]uint32
+	fd              int32
+}
+
+type gpioV2LineInfo struct {
+	name     [32]byte
+	consumer [32]byte
+	offset   uint32
+	numAttrs uint32
+	flags    uint64
+	attrs    [10]gpioV2LineAttribute
+	padding  [4]uint32
+}
+
+type gpioV2LineValues struct {
+	bits uint64
+	mask uint64
+}
+
+type gpioV2LineEvent struct {
+	timestampNs uint64
+	id          uint32
+	offset      uint32
+	seqno       uint32
+	lineSeqno   uint32
+	padding     [6]uint32
+}
+
+// driverGPIOChip implements periph.Driver.
+type driverGPIOChip struct {
+	chips []*gpioChip
+}
+
+func (d *driverGPIOChip) String() string {
+	return "sysfs-gpiochip"
+}
+
+func (d *driverGPIOChip) Prerequisites() []string {
+	return nil
+}
+
+func (d *driverGPIOChip) After() []string {
+	// Supersedes the pins registered by sysfs-gpio.
+	return []string{"sysfs-gpio"}
+}
+
+// Init initializes GPIO character device handling code.
+//
+// Uses the v2 uAPI as described at
+// https://www.kernel.org/doc/html/latest/userspace-api/gpio/chardev.html
+//
+// Contrary to GPIO sysfs, the character device supports pull resistors, open
+// drain and open source outputs, and queues the edges with a timestamp.
+func (d *driverGPIOChip) Init() (bool, error) {
+	items, err := filepath.Glob("/dev/gpiochip*")
+	if err != nil {
+		return true, err
+	}
+	if len(items) == 0 {
+		return false, errors.New("no GPIO chip found")
+	}
+	// Make sure they are processed in numerical order, so the chips without a
+	// sysfs base are numbered deterministically.
+	sort.Sort(gpioChipPaths(items))
+	LinePins = map[int]*LinePin{}
+	var bases []int
+	next := 0
+	for _, item := range items {
+		c, err := newGPIOChip(item)
+		if err != nil {
+			if os.IsPermission(err) {
+				return true, fmt.Errorf("need more access, try as root or setup udev rules: %v", err)
+			}
+			return true, err
+		}
+		d.chips = append(d.chips, c)
+		base, err := sysfsChipBase(filepath.Base(item), c)
+		if err != nil {
+			base = -1
+		} else if base+int(c.lines) > next {
+			next = base + int(c.lines)
+		}
+		bases = append(bases, base)
+	}
+	for i, c := range d.chips {
+		if bases[i] != -1 {
+			if err := d.registerChip(c, bases[i], true); err != nil {
+				return true, err
+			}
+		}
+	}
+	for i, c := range d.chips {
+		if bases[i] == -1 {
+			if err := d.registerChip(c, next, false); err != nil {
+				return true, err
+			}
+			next += int(c.lines)
+		}
+	}
+	return true, nil
+}
+
+// Close releases the lines, unregisters the pins and closes the chips.
+func (d *driverGPIOChip) Close() error {
+	var err error
+	for _, p := range LinePins {
+		if err2 := p.Halt(); err2 != nil && err == nil {
+			err = err2
+		}
+		// Only unregister the pin if it wasn't superseded by another driver.
+		if gpioreg.ByName(p.name) == gpio.PinIO(p) {
+			_ = gpioreg.Unregister(p.name)
+			_ = gpioreg.Unregister(strconv.Itoa(p.number))
+		}
+	}
+	for _, c := range d.chips {
+		if err2 := c.f.Close(); err2 != nil && err == nil {
+			err = err2
+		}
+	}
+	d.chips = nil
+	LinePins = nil
+	return err
+}
+
+// registerChip registers the lines of the chip numbered from base.
+//
+// When supersede is true, the pins registered by sysfs-gpio with the same
+// number are replaced. It must only be set when base is the chip's sysfs base,
+// otherwise the names would be bound to different lines.
+func (d *driverGPIOChip) registerChip(c *gpioChip, base int, supersede bool) error {
+	for i := uint32(0); i < c.lines; i++ {
+		n := base + int(i)
+		p := &LinePin{
+			number: n,
+			name:   fmt.Sprintf("GPIO%d", n),
+			chip:   c,
+			offset: i,
+		}
+		var info gpioV2LineInfo
+		if err := c.lineInfo(i, &info); err == nil {
+			p.label = cString(info.name[:])
+		}
+		LinePins[n] = p
+		num := strconv.Itoa(n)
+		if r := gpioreg.ByName(p.name); r != nil {
+			if _, ok := r.(*Pin); !ok || !supersede {
+				// A CPU memory mapped driver already registered this pin. Keep it.
+				continue
+			}
+			// Supersede the pin registered by sysfs-gpio.
+			if err := gpioreg.Unregister(p.name); err != nil {
+				return err
+			}
+			_ = gpioreg.Unregister(num)
+		}
+		if err := gpioreg.Register(p); err != nil {
+			return err
+		}
+		if err := gpioreg.RegisterAlias(num, p.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gpioSysfsRoot is the root of sysfs; it is overridden in unit tests.
+var gpioSysfsRoot = "/sys"
+
+// sysfsChipBase returns the number of the first line of the chip /dev/name as
+// exposed by GPIO sysfs.
+//
+// The character device /sys/bus/gpio/devices/gpiochipN and the sysfs class
+// device gpio/gpiochipB are siblings under the GPIO controller device. The
+// bases are not necessarily contiguous, so they have to be read.
+func sysfsChipBase(name string, c *gpioChip) (int, error) {
+	dev, err := filepath.EvalSymlinks(gpioSysfsRoot + "/bus/gpio/devices/" + name)
+	if err != nil {
+		return 0, err
+	}
+	items, err := filepath.Glob(filepath.Dir(dev) + "/gpio/gpiochip*")
+	if err != nil {
+		return 0, err
+	}
+	base := -1
+	for _, item := range items {
+		// A controller can expose multiple chips, so match on the label and
+		// the number of lines too.
+		if l, err := readString(item + "/label"); err != nil || l != c.label {
+			continue
+		}
+		if n, err := readInt(item + "/ngpio"); err != nil || n != int(c.lines) {
+			continue
+		}
+		b, err := readInt(item + "/base")
+		if err != nil {
+			return 0, err
+		}
+		if base != -1 {
+			return 0, errors.New("ambiguous sysfs base")
+		}
+		base = b
+	}
+	if base == -1 {
+		return 0, errors.New("sysfs base not found")
+	}
+	return base, nil
+}
+
+func init() {
+	if isLinux {
+		periph.MustRegister(&drvGPIOChip)
+	}
+}
+
+// gpioChipPaths sorts /dev/gpiochipN paths by N.
+type gpioChipPaths []string
+
+func (g gpioChipPaths) Len() int      { return len(g) }
+func (g gpioChipPaths) Swap(i, j int) { g[i], g[j] = g[j], g[i] }
+func (g gpioChipPaths) Less(i, j int) bool {
+	a, _ := strconv.Atoi(g[i][len("/dev/gpiochip"):])
+	b, _ := strconv.Atoi(g[j][len("/dev/gpiochip"):])
+	return a < b
+}
+
+var drvGPIOChip driverGPIOChip
+
+var _ conn.Resource = &LinePin{}
+var _ gpio.PinIn = &LinePin{}
+var _ gpio.PinOut = &LinePin{}
+var _ gpio.PinIO = &LinePin{}
+var _ pin.PinFunc = &LinePin{}
+var _ gpio.PinEdgeEvents = &LinePin{}
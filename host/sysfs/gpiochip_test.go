@@ -0,0 +1,725 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+	"unsafe"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/physic"
+)
+
+func TestGPIOChip_structSize(t *testing.T) {
+	// The sizes are encoded in the ioctl op codes.
+	data := []struct {
+		name     string
+		actual   uintptr
+		expected uintptr
+	}{
+		{"gpiochip_info", unsafe.Sizeof(gpiochipInfo{}), 68},
+		{"gpio_v2_line_config", unsafe.Sizeof(gpioV2LineConfig{}), 272},
+		{"gpio_v2_line_request", unsafe.Sizeof(gpioV2LineRequest{}), 592},
+		{"gpio_v2_line_info", unsafe.Sizeof(gpioV2LineInfo{}), 256},
+		{"gpio_v2_line_values", unsafe.Sizeof(gpioV2LineValues{}), 16},
+		{"gpio_v2_line_event", unsafe.Sizeof(gpioV2LineEvent{}), 48},
+	}
+	for _, line := range data {
+		if line.actual != line.expected {
+			t.Fatalf("%s: expected %d, got %d", line.name, line.expected, line.actual)
+		}
+	}
+}
+
+func TestNewGPIOChip(t *testing.T) {
+	defer reset()
+	if _, err := newGPIOChip("/dev/gpiochip0"); err == nil {
+		t.Fatal("file I/O is inhibited")
+	}
+	ioctlOpen = func(path string, flag int) (ioctlCloser, error) {
+		return &ioctlClose{ioctlErr: errors.New("injected")}, nil
+	}
+	if _, err := newGPIOChip("/dev/gpiochip0"); err == nil {
+		t.Fatal("chip info failed")
+	}
+	f := &fakeGPIOChip{lines: 4}
+	gpioChipOpen = func(path string) (gpioChipIO, error) {
+		return f, nil
+	}
+	c, err := newGPIOChip("/dev/gpiochip0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.name != "gpiochip0" || c.label != "fake" || c.lines != 4 {
+		t.Fatalf("%#v", c)
+	}
+}
+
+func TestGPIOChip_register(t *testing.T) {
+	defer func() {
+		LinePins = nil
+	}()
+	LinePins = map[int]*LinePin{}
+	c := &gpioChip{name: "gpiochip1", f: &fakeGPIOChip{lines: 2}, lines: 2}
+	d := driverGPIOChip{}
+	if err := d.registerChip(c, 1000, true); err != nil {
+		t.Fatal(err)
+	}
+	if p := LinePins[1001]; p == nil || p.Name() != "GPIO1001" || p.Label() != "L1" {
+		t.Fatal(p)
+	}
+	if gpioreg.ByName("1000") == nil {
+		t.Fatal("alias is not registered")
+	}
+	d.chips = []*gpioChip{c}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []string{"GPIO1000", "GPIO1001", "1000", "1001"} {
+		if gpioreg.ByName(n) != nil {
+			t.Fatal(n)
+		}
+	}
+	if LinePins != nil || d.chips != nil {
+		t.Fatal("Close() must reset the state")
+	}
+}
+
+func TestGPIOChip_register_supersede(t *testing.T) {
+	defer func() {
+		LinePins = nil
+	}()
+	LinePins = map[int]*LinePin{}
+	s := &Pin{number: 1000, name: "GPIO1000"}
+	if err := gpioreg.Register(s); err != nil {
+		t.Fatal(err)
+	}
+	defer gpioreg.Unregister("GPIO1000")
+	c := &gpioChip{name: "gpiochip1", f: &fakeGPIOChip{lines: 1}, lines: 1}
+	d := driverGPIOChip{}
+	// The base is not the sysfs one, the sysfs pin is kept.
+	if err := d.registerChip(c, 1000, false); err != nil {
+		t.Fatal(err)
+	}
+	if gpioreg.ByName("GPIO1000") != gpio.PinIO(s) {
+		t.Fatal("sysfs pin must not be superseded")
+	}
+	if err := d.registerChip(c, 1000, true); err != nil {
+		t.Fatal(err)
+	}
+	if gpioreg.ByName("GPIO1000") != gpio.PinIO(LinePins[1000]) {
+		t.Fatal("sysfs pin must be superseded")
+	}
+}
+
+func TestSysfsChipBase(t *testing.T) {
+	defer reset()
+	root := fakeTree(t, map[string]string{
+		"devices/soc/gpio@0/gpio/gpiochip0/label":   "pinctrl\n",
+		"devices/soc/gpio@0/gpio/gpiochip0/ngpio":   "54\n",
+		"devices/soc/gpio@0/gpio/gpiochip0/base":    "0\n",
+		"devices/soc/gpio@1/gpio/gpiochip504/label": "expander\n",
+		"devices/soc/gpio@1/gpio/gpiochip504/ngpio": "8\n",
+		"devices/soc/gpio@1/gpio/gpiochip504/base":  "504\n",
+		"devices/soc/gpio@1/gpio/gpiochip496/label": "other\n",
+		"devices/soc/gpio@1/gpio/gpiochip496/ngpio": "8\n",
+		"devices/soc/gpio@1/gpio/gpiochip496/base":  "496\n",
+		"devices/soc/gpio@2/gpio/gpiochip480/label": "dup\n",
+		"devices/soc/gpio@2/gpio/gpiochip480/ngpio": "8\n",
+		"devices/soc/gpio@2/gpio/gpiochip480/base":  "480\n",
+		"devices/soc/gpio@2/gpio/gpiochip488/label": "dup\n",
+		"devices/soc/gpio@2/gpio/gpiochip488/ngpio": "8\n",
+		"devices/soc/gpio@2/gpio/gpiochip488/base":  "488\n",
+		"devices/soc/gpio@0/gpiochip0/dev":          "254:0\n",
+		"devices/soc/gpio@1/gpiochip1/dev":          "254:1\n",
+		"devices/soc/gpio@2/gpiochip2/dev":          "254:2\n",
+		"devices/soc/gpio@2/gpiochip3/dev":          "254:3\n",
+	})
+	defer os.RemoveAll(root)
+	gpioSysfsRoot = root
+	if err := os.MkdirAll(root+"/bus/gpio/devices", 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []string{"gpiochip0", "gpiochip1", "gpiochip2"} {
+		if err := os.Symlink(root+"/devices/soc/gpio@"+n[len("gpiochip"):]+"/"+n, root+"/bus/gpio/devices/"+n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := []struct {
+		name  string
+		label string
+		lines uint32
+		base  int
+	}{
+		{"gpiochip0", "pinctrl", 54, 0},
+		// Not contiguous; the controller exposes two chips.
+		{"gpiochip1", "expander", 8, 504},
+		// Ambiguous.
+		{"gpiochip2", "dup", 8, -1},
+		// No matching sysfs chip.
+		{"gpiochip1", "expander", 16, -1},
+		// No character device in sysfs.
+		{"gpiochip3", "dup", 8, -1},
+	}
+	for i, line := range data {
+		base, err := sysfsChipBase(line.name, &gpioChip{label: line.label, lines: line.lines})
+		if line.base == -1 {
+			if err == nil {
+				t.Fatalf("#%d: expected error", i)
+			}
+		} else if err != nil || base != line.base {
+			t.Fatalf("#%d: %d %v", i, base, err)
+		}
+	}
+}
+
+func TestGPIOChipDriver(t *testing.T) {
+	d := driverGPIOChip{}
+	if s := d.String(); s != "sysfs-gpiochip" {
+		t.Fatal(s)
+	}
+	if len(d.Prerequisites()) != 0 {
+		t.Fatal("unexpected prerequisites")
+	}
+	if a := d.After(); len(a) != 1 || a[0] != "sysfs-gpio" {
+		t.Fatal(a)
+	}
+}
+
+func TestLinePin_String(t *testing.T) {
+	p := newFakeLinePin()
+	if s := p.String(); s != "GPIO2" {
+		t.Fatal(s)
+	}
+	if s := p.Name(); s != "GPIO2" {
+		t.Fatal(s)
+	}
+	if n := p.Number(); n != 2 {
+		t.Fatal(n)
+	}
+	if s := Drive(10).String(); s != "Drive(10)" {
+		t.Fatal(s)
+	}
+	if s := OpenSource.String(); s != "OpenSource" {
+		t.Fatal(s)
+	}
+}
+
+func TestLinePin_Function(t *testing.T) {
+	defer reset()
+	p := newFakeLinePin()
+	f := p.chip.f.(*fakeGPIOChip)
+	if s := p.Function(); s != string(gpio.IN) {
+		t.Fatal(s)
+	}
+	f.flags = lineFlagOutput
+	if s := p.Function(); s != string(gpio.OUT) {
+		t.Fatal(s)
+	}
+	f.err = errors.New("injected")
+	if s := p.Function(); s != "ERR" {
+		t.Fatal(s)
+	}
+	f.err = nil
+	if err := p.In(gpio.PullNoChange, gpio.NoEdge); err != nil {
+		t.Fatal(err)
+	}
+	if s := p.Function(); s != string(gpio.IN_LOW) {
+		t.Fatal(s)
+	}
+	f.line.level = true
+	if s := p.Function(); s != string(gpio.IN_HIGH) {
+		t.Fatal(s)
+	}
+	if err := p.Out(gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	if s := p.Function(); s != string(gpio.OUT_LOW) {
+		t.Fatal(s)
+	}
+	if err := p.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if s := p.Function(); s != string(gpio.OUT_HIGH) {
+		t.Fatal(s)
+	}
+	f.line.err = errors.New("injected")
+	if s := p.Function(); s != "ERR" {
+		t.Fatal(s)
+	}
+}
+
+func TestLinePin_In(t *testing.T) {
+	defer reset()
+	p := newFakeLinePin()
+	f := p.chip.f.(*fakeGPIOChip)
+	if p.In(gpio.Pull(10), gpio.NoEdge) == nil {
+		t.Fatal("invalid pull")
+	}
+	if p.In(gpio.PullNoChange, gpio.Edge(10)) == nil {
+		t.Fatal("invalid edge")
+	}
+	f.err = errors.New("injected")
+	if p.In(gpio.PullUp, gpio.NoEdge) == nil {
+		t.Fatal("line request failed")
+	}
+	f.err = nil
+	if err := p.In(gpio.PullUp, gpio.RisingEdge); err != nil {
+		t.Fatal(err)
+	}
+	if f.requests != 1 {
+		t.Fatal(f.requests)
+	}
+	if fl := f.line.flags; fl != lineFlagInput|lineFlagBiasPullUp|lineFlagEdgeRising {
+		t.Fatalf("%#x", fl)
+	}
+	if pull := p.Pull(); pull != gpio.PullUp {
+		t.Fatal(pull)
+	}
+	// Reconfigure without requesting the line again; the pull is kept.
+	if err := p.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	if f.requests != 1 {
+		t.Fatal(f.requests)
+	}
+	if fl := f.line.flags; fl != lineFlagInput|lineFlagBiasPullUp|lineFlagEdgeRising|lineFlagEdgeFalling {
+		t.Fatalf("%#x", fl)
+	}
+	if err := p.In(gpio.Float, gpio.FallingEdge); err != nil {
+		t.Fatal(err)
+	}
+	if fl := f.line.flags; fl != lineFlagInput|lineFlagBiasDisabled|lineFlagEdgeFalling {
+		t.Fatalf("%#x", fl)
+	}
+	if err := p.In(gpio.PullDown, gpio.NoEdge); err != nil {
+		t.Fatal(err)
+	}
+	if fl := f.line.flags; fl != lineFlagInput|lineFlagBiasPullDown {
+		t.Fatalf("%#x", fl)
+	}
+	f.line.err = errors.New("injected")
+	if p.In(gpio.PullDown, gpio.NoEdge) == nil {
+		t.Fatal("set config failed")
+	}
+}
+
+func TestLinePin_In_flush(t *testing.T) {
+	defer reset()
+	p := newFakeLinePin()
+	f := p.chip.f.(*fakeGPIOChip)
+	f.initial = []gpioV2LineEvent{{timestampNs: 1, id: lineEventRisingEdge}}
+	if err := p.In(gpio.PullNoChange, gpio.RisingEdge); err != nil {
+		t.Fatal(err)
+	}
+	if p.WaitForEdge(0) {
+		t.Fatal("accumulated edge should have been flushed")
+	}
+}
+
+func TestLinePin_Read(t *testing.T) {
+	defer reset()
+	p := newFakeLinePin()
+	f := p.chip.f.(*fakeGPIOChip)
+	if l := p.Read(); l != gpio.Low {
+		t.Fatal("line not requested is always low")
+	}
+	if err := p.In(gpio.PullNoChange, gpio.NoEdge); err != nil {
+		t.Fatal(err)
+	}
+	f.line.level = true
+	if l := p.Read(); l != gpio.High {
+		t.Fatal("line is high")
+	}
+	f.line.err = errors.New("injected")
+	if l := p.Read(); l != gpio.Low {
+		t.Fatal("broken line is always low")
+	}
+}
+
+func TestLinePin_WaitForEvent(t *testing.T) {
+	defer reset()
+	p := newFakeLinePin()
+	f := p.chip.f.(*fakeGPIOChip)
+	if p.WaitForEdge(-1) {
+		t.Fatal("line not requested doesn't have edge triggered")
+	}
+	if err := p.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	if p.WaitForEdge(0) {
+		t.Fatal("no edge")
+	}
+	if p.WaitForEdge(time.Millisecond) {
+		t.Fatal("no edge")
+	}
+	f.line.events = []gpioV2LineEvent{
+		{timestampNs: 1000, id: lineEventRisingEdge, lineSeqno: 1},
+		{timestampNs: 2000, id: lineEventFallingEdge, lineSeqno: 2},
+	}
+	e, ok := p.WaitForEvent(-1)
+	if !ok || e != (LineEvent{T: time.Microsecond, Edge: gpio.RisingEdge, Seq: 1}) {
+		t.Fatal(e, ok)
+	}
+	e, ok = p.WaitForEvent(0)
+	if !ok || e != (LineEvent{T: 2 * time.Microsecond, Edge: gpio.FallingEdge, Seq: 2}) {
+		t.Fatal(e, ok)
+	}
+	if p.WaitForEdge(0) {
+		t.Fatal("no more edge")
+	}
+	f.line.err = errors.New("injected")
+	if p.WaitForEdge(0) {
+		t.Fatal("broken line")
+	}
+}
+
+func TestLinePin_WaitForEdgeEvent(t *testing.T) {
+	defer reset()
+	p := newFakeLinePin()
+	f := p.chip.f.(*fakeGPIOChip)
+	if _, ok := p.WaitForEdgeEvent(0); ok {
+		t.Fatal("line not requested doesn't have edge triggered")
+	}
+	f.initial = []gpioV2LineEvent{{timestampNs: 10, id: lineEventRisingEdge, lineSeqno: 1}}
+	if err := p.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	// The monotonic clock is 1s ahead of the first event.
+	monotonicNow = func() time.Duration {
+		return time.Second + time.Millisecond
+	}
+	f.line.events = []gpioV2LineEvent{
+		{timestampNs: uint64(time.Millisecond), id: lineEventRisingEdge, lineSeqno: 2},
+		{timestampNs: uint64(2 * time.Millisecond), id: lineEventFallingEdge, lineSeqno: 5},
+	}
+	start := time.Now()
+	e, ok := p.WaitForEdgeEvent(-1)
+	end := time.Now()
+	if !ok || e.L != gpio.High || e.T.Before(start.Add(-time.Second)) || e.T.After(end.Add(-time.Second)) {
+		t.Fatal(e, ok)
+	}
+	if n := p.EdgeOverflows(); n != 0 {
+		t.Fatal(n)
+	}
+	e2, ok := p.WaitForEdgeEvent(0)
+	if !ok || e2.L != gpio.Low || e2.T.Sub(e.T) < time.Millisecond-100*time.Microsecond {
+		t.Fatal(e2, ok)
+	}
+	// Events 3 and 4 were dropped by the kernel.
+	if n := p.EdgeOverflows(); n != 2 {
+		t.Fatal(n)
+	}
+	if _, ok := p.WaitForEdgeEvent(0); ok {
+		t.Fatal("no more edge")
+	}
+	// In() resets the count.
+	if err := p.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	if n := p.EdgeOverflows(); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestLinePin_Out(t *testing.T) {
+	defer reset()
+	p := newFakeLinePin()
+	f := p.chip.f.(*fakeGPIOChip)
+	f.err = errors.New("injected")
+	if p.Out(gpio.High) == nil {
+		t.Fatal("line request failed")
+	}
+	f.err = nil
+	if err := p.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if !f.line.level || f.line.flags != lineFlagOutput {
+		t.Fatalf("%t %#x", f.line.level, f.line.flags)
+	}
+	if err := p.Out(gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	if f.line.level || f.requests != 1 {
+		t.Fatalf("%t %d", f.line.level, f.requests)
+	}
+	f.line.err = errors.New("injected")
+	if p.Out(gpio.High) == nil {
+		t.Fatal("set values failed")
+	}
+}
+
+func TestLinePin_SetDrive(t *testing.T) {
+	defer reset()
+	p := newFakeLinePin()
+	f := p.chip.f.(*fakeGPIOChip)
+	if p.SetDrive(Drive(10)) == nil {
+		t.Fatal("invalid drive")
+	}
+	if err := p.SetDrive(OpenSource); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if f.line.flags != lineFlagOutput|lineFlagOpenSource {
+		t.Fatalf("%#x", f.line.flags)
+	}
+	// Reconfigured immediately, keeping the level.
+	if err := p.SetDrive(OpenDrain); err != nil {
+		t.Fatal(err)
+	}
+	if f.line.flags != lineFlagOutput|lineFlagOpenDrain || !f.line.level {
+		t.Fatalf("%t %#x", f.line.level, f.line.flags)
+	}
+	if err := p.SetDrive(OpenDrain); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetFunc(gpio.OUT_OC); err != nil {
+		t.Fatal(err)
+	}
+	f.line.err = errors.New("injected")
+	if p.SetDrive(PushPull) == nil {
+		t.Fatal("set config failed")
+	}
+}
+
+func TestLinePin_SetFunc(t *testing.T) {
+	defer reset()
+	p := newFakeLinePin()
+	f := p.chip.f.(*fakeGPIOChip)
+	if err := p.SetFunc(gpio.IN); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetFunc(gpio.OUT_HIGH); err != nil {
+		t.Fatal(err)
+	}
+	if !f.line.level {
+		t.Fatal("expected high")
+	}
+	if err := p.SetFunc(gpio.OUT); err != nil {
+		t.Fatal(err)
+	}
+	if f.line.level {
+		t.Fatal("expected low")
+	}
+	if p.SetFunc(gpio.PWM) == nil {
+		t.Fatal("unsupported")
+	}
+	if len(p.SupportedFuncs()) != 3 {
+		t.Fatal(p.SupportedFuncs())
+	}
+}
+
+func TestLinePin_Pull(t *testing.T) {
+	p := newFakeLinePin()
+	f := p.chip.f.(*fakeGPIOChip)
+	data := []struct {
+		flags    uint64
+		expected gpio.Pull
+	}{
+		{0, gpio.PullNoChange},
+		{lineFlagBiasPullUp, gpio.PullUp},
+		{lineFlagBiasPullDown, gpio.PullDown},
+		{lineFlagBiasDisabled, gpio.Float},
+	}
+	for i, line := range data {
+		f.flags = line.flags
+		if pull := p.Pull(); pull != line.expected {
+			t.Fatalf("#%d: %s", i, pull)
+		}
+	}
+	f.err = errors.New("injected")
+	if pull := p.Pull(); pull != gpio.PullNoChange {
+		t.Fatal(pull)
+	}
+	if pull := p.DefaultPull(); pull != gpio.PullNoChange {
+		t.Fatal(pull)
+	}
+}
+
+func TestLinePin_Halt(t *testing.T) {
+	defer reset()
+	p := newFakeLinePin()
+	f := p.chip.f.(*fakeGPIOChip)
+	if err := p.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.In(gpio.PullNoChange, gpio.NoEdge); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if !f.line.closed {
+		t.Fatal("line should have been released")
+	}
+	if err := p.Out(gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	if f.requests != 2 {
+		t.Fatal(f.requests)
+	}
+	f.line.closeErr = errors.New("injected")
+	if p.Halt() == nil {
+		t.Fatal("close failed")
+	}
+}
+
+func TestLinePin_PWM(t *testing.T) {
+	p := newFakeLinePin()
+	if p.PWM(gpio.DutyHalf, physic.KiloHertz) == nil {
+		t.Fatal("sysfs-gpiochip doesn't support PWM")
+	}
+}
+
+//
+
+func newFakeLinePin() *LinePin {
+	f := &fakeGPIOChip{lines: 4}
+	gpioLineOpen = func(fd uintptr, name string) (gpioLineIO, error) {
+		f.line.events = f.initial
+		return f.line, nil
+	}
+	return &LinePin{
+		number: 2,
+		name:   "GPIO2",
+		chip:   &gpioChip{name: "gpiochip0", label: "fake", f: f, lines: 4},
+		offset: 2,
+	}
+}
+
+// fakeGPIOChip implements gpioChipIO.
+type fakeGPIOChip struct {
+	lines    uint32
+	flags    uint64 // flags returned by GPIO_V2_GET_LINEINFO_IOCTL
+	err      error
+	requests int
+	initial  []gpioV2LineEvent // events queued when the line is requested
+	line     *fakeGPIOLine
+}
+
+func (f *fakeGPIOChip) Close() error {
+	return nil
+}
+
+func (f *fakeGPIOChip) chipInfo(i *gpiochipInfo) error {
+	if f.err != nil {
+		return f.err
+	}
+	copy(i.name[:], "gpiochip0")
+	copy(i.label[:], "fake")
+	i.lines = f.lines
+	return nil
+}
+
+func (f *fakeGPIOChip) lineInfo(i *gpioV2LineInfo) error {
+	if f.err != nil {
+		return f.err
+	}
+	if i.offset >= f.lines {
+		return errors.New("invalid offset")
+	}
+	copy(i.name[:], "L"+string('0'+byte(i.offset)))
+	i.flags = f.flags
+	return nil
+}
+
+func (f *fakeGPIOChip) getLine(r *gpioV2LineRequest) error {
+	if f.err != nil {
+		return f.err
+	}
+	if r.numLines != 1 || r.offsets[0] >= f.lines {
+		return errors.New("invalid request")
+	}
+	f.requests++
+	f.line = &fakeGPIOLine{}
+	f.line.apply(&r.config)
+	r.fd = 42
+	return nil
+}
+
+// fakeGPIOLine implements gpioLineIO.
+type fakeGPIOLine struct {
+	flags    uint64
+	level    bool
+	events   []gpioV2LineEvent
+	err      error
+	closeErr error
+	closed   bool
+}
+
+func (f *fakeGPIOLine) apply(c *gpioV2LineConfig) {
+	f.flags = c.flags
+	for i := uint32(0); i < c.numAttrs; i++ {
+		if c.attrs[i].attr.id == lineAttrIDOutputValues && c.attrs[i].mask&1 != 0 {
+			f.level = c.attrs[i].attr.value&1 != 0
+		}
+	}
+}
+
+func (f *fakeGPIOLine) setConfig(c *gpioV2LineConfig) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.apply(c)
+	return nil
+}
+
+func (f *fakeGPIOLine) getValues(v *gpioV2LineValues) error {
+	if f.err != nil {
+		return f.err
+	}
+	v.bits = 0
+	if f.level {
+		v.bits = v.mask & 1
+	}
+	return nil
+}
+
+func (f *fakeGPIOLine) setValues(v *gpioV2LineValues) error {
+	if f.err != nil {
+		return f.err
+	}
+	if v.mask&1 != 0 {
+		f.level = v.bits&1 != 0
+	}
+	return nil
+}
+
+func (f *fakeGPIOLine) Close() error {
+	f.closed = true
+	return f.closeErr
+}
+
+func (f *fakeGPIOLine) Read(b []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	n := 0
+	for len(f.events) != 0 && len(b[n:]) >= eventSize {
+		*(*gpioV2LineEvent)(unsafe.Pointer(&b[n])) = f.events[0]
+		f.events = f.events[1:]
+		n += eventSize
+	}
+	return n, nil
+}
+
+func (f *fakeGPIOLine) Wait(timeoutms int) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	if len(f.events) != 0 {
+		return 1, nil
+	}
+	if timeoutms > 0 {
+		time.Sleep(time.Duration(timeoutms) * time.Millisecond)
+	}
+	return 0, nil
+}
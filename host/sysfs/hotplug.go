@@ -0,0 +1,158 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"periph.io/x/periph/host/fs"
+)
+
+// Rescan registers the I²C buses, SPI ports and UART ports that appeared and
+// unregisters the ones that disappeared since the drivers were initialized.
+//
+// Use i2creg.Subscribe(), spireg.Subscribe() and uartreg.Subscribe() to be
+// notified of the changes. Operations on an open handle to a device that was
+// removed return a *conn.RemovedError.
+//
+// It is meant to be called after host.Init() for devices that can be plugged
+// at runtime, like USB serial adapters or USB to I²C bridges. The drivers are
+// rescanned even if they were skipped by Init() because no device was present
+// yet; the ones that were left out by periph.Opts or closed by
+// periph.Shutdown() are ignored.
+func Rescan() error {
+	scanMu.Lock()
+	defer scanMu.Unlock()
+	var err error
+	if drvI2C.initialized {
+		if _, err1 := drvI2C.scan(); err1 != nil {
+			err = err1
+		}
+	}
+	if drvSPI.initialized {
+		if _, err1 := drvSPI.scan(); err1 != nil && err == nil {
+			err = err1
+		}
+	}
+	if drvUART.initialized {
+		if _, err1 := drvUART.scan(); err1 != nil && err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+// HotplugWatcher calls Rescan() whenever a device node for an I²C bus, a SPI
+// port or an UART port is created or deleted in /dev.
+type HotplugWatcher struct {
+	fd   int
+	ev   fs.Event
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// WatchHotplug starts watching /dev via inotify.
+//
+// It is only supported on linux.
+func WatchHotplug() (*HotplugWatcher, error) {
+	fd, err := inotifyOpen(devDir)
+	if err != nil {
+		return nil, err
+	}
+	h := &HotplugWatcher{fd: fd, done: make(chan struct{})}
+	if err := h.ev.MakeReadEvent(uintptr(fd)); err != nil {
+		_ = inotifyClose(fd)
+		return nil, err
+	}
+	h.wg.Add(1)
+	go h.run()
+	return h, nil
+}
+
+// Close stops watching.
+//
+// The devices registered are left as-is.
+func (h *HotplugWatcher) Close() error {
+	err := errors.New("sysfs: hotplug watcher already closed")
+	h.once.Do(func() {
+		close(h.done)
+		h.wg.Wait()
+		err = h.ev.Close()
+		if err1 := inotifyClose(h.fd); err == nil {
+			err = err1
+		}
+	})
+	return err
+}
+
+//
+
+// hotplugPrefixes are the device node names that trigger a rescan.
+var hotplugPrefixes = []string{"i2c-", "spidev", "ttyAMA", "ttyS", "ttyUSB", "ttyACM"}
+
+// scanMu serializes the scans done by the drivers' Init(), Close() and
+// Rescan().
+var scanMu sync.Mutex
+
+func (h *HotplugWatcher) run() {
+	defer h.wg.Done()
+	for {
+		select {
+		case <-h.done:
+			return
+		default:
+		}
+		// Use a timeout to be able to stop.
+		if n, err := h.ev.Wait(100); err != nil || n == 0 {
+			continue
+		}
+		names, err := inotifyRead(h.fd)
+		if err != nil {
+			continue
+		}
+		if isHotplugName(names) {
+			_ = Rescan()
+		}
+	}
+}
+
+// isHotplugName returns true if any of the names is a device node scanned by
+// Rescan().
+func isHotplugName(names []string) bool {
+	for _, n := range names {
+		for _, p := range hotplugPrefixes {
+			if strings.HasPrefix(n, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// forget unregisters the names that are not in found and returns the ones
+// left.
+func forget(names []string, found map[string]struct{}, unregister func(name string) error) []string {
+	out := names[:0]
+	for _, n := range names {
+		if _, ok := found[n]; ok {
+			out = append(out, n)
+		} else {
+			_ = unregister(n)
+		}
+	}
+	return out
+}
+
+func contains(l []string, s string) bool {
+	for _, i := range l {
+		if i == s {
+			return true
+		}
+	}
+	return false
+}
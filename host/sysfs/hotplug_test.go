@@ -0,0 +1,334 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/spi/spireg"
+	"periph.io/x/periph/conn/uart/uartreg"
+)
+
+func TestRescan(t *testing.T) {
+	dir := fakeDevDir(t)
+	defer os.RemoveAll(dir)
+	defer reset()
+	defer Rescan()
+
+	var mu sync.Mutex
+	var events []string
+	defer i2creg.Subscribe(func(e i2creg.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, hotplugEvent(e.Ref.Name, e.Removed))
+	})()
+	defer uartreg.Subscribe(func(e uartreg.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, hotplugEvent(e.Ref.Name, e.Removed))
+	})()
+
+	touch(t, dir, "i2c-1001", "spidev1001.0", "spidev1001.1", "ttyAMA1001", "ttyS1001", "ttyUSB1001", "null")
+	drvI2C.initialized = true
+	drvSPI.initialized = true
+	drvUART.initialized = true
+	if err := Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	if r := i2creg.All(); !hasI2C(r, dir+"/i2c-1001", 1001) {
+		t.Fatal(r)
+	}
+	if r := spireg.All(); !hasSPI(r, dir+"/spidev1001.0", 1001) || !hasSPI(r, dir+"/spidev1001.1", -1) {
+		t.Fatal(r)
+	}
+	if r := uartreg.All(); !hasUART(r, dir+"/ttyAMA1001", 1001) || !hasUART(r, dir+"/ttyS1001", -1) || !hasUART(r, dir+"/ttyUSB1001", -1) {
+		t.Fatal(r)
+	}
+
+	// Rescanning without change is a no-op.
+	if err := Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if len(events) != 4 {
+		t.Fatal(events)
+	}
+	events = nil
+	mu.Unlock()
+
+	// Unplug and plug devices.
+	rm(t, dir, "i2c-1001", "spidev1001.1", "ttyAMA1001")
+	touch(t, dir, "ttyACM1001")
+	if err := Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	if r := i2creg.All(); hasI2C(r, dir+"/i2c-1001", 1001) {
+		t.Fatal(r)
+	}
+	if r := spireg.All(); !hasSPI(r, dir+"/spidev1001.0", 1001) || hasSPI(r, dir+"/spidev1001.1", -1) {
+		t.Fatal(r)
+	}
+	if r := uartreg.All(); hasUART(r, dir+"/ttyAMA1001", 1001) || !hasUART(r, dir+"/ttyS1001", -1) || !hasUART(r, dir+"/ttyACM1001", -1) {
+		t.Fatal(r)
+	}
+	mu.Lock()
+	expected := []string{"-" + dir + "/i2c-1001", "-" + dir + "/ttyAMA1001", "+" + dir + "/ttyACM1001"}
+	if len(events) != len(expected) {
+		t.Fatal(events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatal(events)
+		}
+	}
+	mu.Unlock()
+
+	// The number freed is reused.
+	touch(t, dir, "ttyAMA1001")
+	if err := Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	if r := uartreg.All(); !hasUART(r, dir+"/ttyAMA1001", 1001) {
+		t.Fatal(r)
+	}
+
+	// Everything is unregistered once the directory is empty.
+	rm(t, dir, "spidev1001.0", "ttyAMA1001", "ttyS1001", "ttyUSB1001", "ttyACM1001")
+	if err := Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	if len(drvI2C.buses) != 0 || len(drvSPI.ports) != 0 || len(drvUART.ports) != 0 {
+		t.Fatal("all devices must have been unregistered")
+	}
+}
+
+func TestRescan_emptyAtInit(t *testing.T) {
+	dir := fakeDevDir(t)
+	defer os.RemoveAll(dir)
+	defer reset()
+	defer Rescan()
+
+	// Nothing is plugged at boot; the drivers are skipped.
+	if ok, err := drvI2C.Init(); ok || err == nil {
+		t.Fatal(ok, err)
+	}
+	if ok, err := drvSPI.Init(); ok || err == nil {
+		t.Fatal(ok, err)
+	}
+	if ok, err := drvUART.Init(); ok || err == nil {
+		t.Fatal(ok, err)
+	}
+
+	var mu sync.Mutex
+	var events []string
+	defer i2creg.Subscribe(func(e i2creg.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, hotplugEvent(e.Ref.Name, e.Removed))
+	})()
+	defer spireg.Subscribe(func(e spireg.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, hotplugEvent(e.Ref.Name, e.Removed))
+	})()
+	defer uartreg.Subscribe(func(e uartreg.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, hotplugEvent(e.Ref.Name, e.Removed))
+	})()
+
+	// USB adapters are plugged afterward.
+	touch(t, dir, "i2c-1001", "spidev1001.0", "ttyUSB1001")
+	if err := Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	if !hasI2C(i2creg.All(), dir+"/i2c-1001", 1001) || !hasSPI(spireg.All(), dir+"/spidev1001.0", 1001) || !hasUART(uartreg.All(), dir+"/ttyUSB1001", -1) {
+		t.Fatal("devices must have been registered")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{"+" + dir + "/i2c-1001", "+" + dir + "/spidev1001.0", "+" + dir + "/ttyUSB1001"}
+	if len(events) != len(expected) {
+		t.Fatal(events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatal(events)
+		}
+	}
+}
+
+func TestRescan_closed(t *testing.T) {
+	dir := fakeDevDir(t)
+	defer os.RemoveAll(dir)
+	defer reset()
+
+	// The drivers were not initialized, e.g. they were excluded.
+	touch(t, dir, "i2c-1001", "spidev1001.0", "ttyUSB1001")
+	if err := Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	if hasI2C(i2creg.All(), dir+"/i2c-1001", 1001) || hasSPI(spireg.All(), dir+"/spidev1001.0", 1001) || hasUART(uartreg.All(), dir+"/ttyUSB1001", -1) {
+		t.Fatal("drivers not initialized must not register devices")
+	}
+
+	// A driver closed by periph.Shutdown() is not rescanned.
+	drvI2C.initialized = true
+	if err := drvI2C.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	if hasI2C(i2creg.All(), dir+"/i2c-1001", 1001) {
+		t.Fatal("closed driver must not register devices")
+	}
+}
+
+func TestWatchHotplug(t *testing.T) {
+	dir := fakeDevDir(t)
+	defer os.RemoveAll(dir)
+	defer reset()
+	defer Rescan()
+
+	c := make(chan i2creg.Event, 2)
+	defer i2creg.Subscribe(func(e i2creg.Event) {
+		c <- e
+	})()
+	drvI2C.initialized = true
+	h, err := WatchHotplug()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer func() {
+		if err := h.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Close(); err == nil {
+			t.Fatal("already closed")
+		}
+	}()
+	// Ignored.
+	touch(t, dir, "foo")
+	touch(t, dir, "i2c-1002")
+	select {
+	case e := <-c:
+		if e.Removed || e.Ref.Name != dir+"/i2c-1002" {
+			t.Fatal(e)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out")
+	}
+	rm(t, dir, "i2c-1002")
+	select {
+	case e := <-c:
+		if !e.Removed || e.Ref.Name != dir+"/i2c-1002" {
+			t.Fatal(e)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out")
+	}
+}
+
+func TestRemovedError(t *testing.T) {
+	if err := removedError("/dev/foo", errors.New("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if !isLinux {
+		return
+	}
+	err := removedError("/dev/foo", &os.PathError{Op: "read", Path: "/dev/foo", Err: syscall.ENODEV})
+	if e, ok := err.(*conn.RemovedError); !ok || e.Name != "/dev/foo" {
+		t.Fatal(err)
+	}
+	// EIO is only a removal if the device node is gone.
+	if err := removedError("/dev/null", syscall.EIO); err != nil {
+		t.Fatal(err)
+	}
+	if err := removedError("/dev/inexistent", syscall.EIO); err == nil {
+		t.Fatal("expected *conn.RemovedError")
+	}
+}
+
+func TestI2C_Removed(t *testing.T) {
+	if !isLinux {
+		t.Skip("not supported on this OS")
+	}
+	bus := I2C{f: &ioctlClose{ioctlErr: syscall.ENODEV}, busNumber: 1}
+	if _, ok := bus.Tx(0x01, []byte{0}, nil).(*conn.RemovedError); !ok {
+		t.Fatal("expected *conn.RemovedError")
+	}
+}
+
+//
+
+// fakeDevDir creates a temporary directory to replace /dev.
+func fakeDevDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "periph_sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	devDir = dir
+	return dir
+}
+
+func touch(t *testing.T, dir string, names ...string) {
+	for _, n := range names {
+		if err := ioutil.WriteFile(filepath.Join(dir, n), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func rm(t *testing.T, dir string, names ...string) {
+	for _, n := range names {
+		if err := os.Remove(filepath.Join(dir, n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func hotplugEvent(name string, removed bool) string {
+	if removed {
+		return "-" + name
+	}
+	return "+" + name
+}
+
+func hasI2C(refs []*i2creg.Ref, name string, number int) bool {
+	for _, r := range refs {
+		if r.Name == name {
+			return r.Number == number
+		}
+	}
+	return false
+}
+
+func hasSPI(refs []*spireg.Ref, name string, number int) bool {
+	for _, r := range refs {
+		if r.Name == name {
+			return r.Number == number
+		}
+	}
+	return false
+}
+
+func hasUART(refs []*uartreg.Ref, name string, number int) bool {
+	for _, r := range refs {
+		if r.Name == name {
+			return r.Number == number
+		}
+	}
+	return false
+}
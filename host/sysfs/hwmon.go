@@ -0,0 +1,360 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"periph.io/x/periph"
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/physic/sampler"
+)
+
+// HwmonSensors is all the hardware monitoring sensors discovered on this host
+// via sysfs.
+var HwmonSensors []*HwmonSensor
+
+// HwmonSensorByName returns a *HwmonSensor for the sensor name, if any.
+//
+// The name is the chip name and the sensor label separated with a slash, e.g.
+// "ina3221/VDD_CPU" or "coretemp/Core 0". When the driver doesn't export a
+// label, the attribute name is used, e.g. "nct6775/fan2".
+//
+// When several chips share a name, e.g. "coretemp" on a multi-socket system,
+// the chip name of the second one is suffixed with "#1", the third with "#2"
+// and so on, in hwmon device order, e.g. "coretemp#1/Core 0". When a chip
+// exports the same label for several sensors, the first one is returned.
+func HwmonSensorByName(name string) (*HwmonSensor, error) {
+	for _, h := range HwmonSensors {
+		if h.name == name {
+			return h, nil
+		}
+	}
+	return nil, errors.New("sysfs-hwmon: invalid sensor name")
+}
+
+// HwmonKind is the kind of value measured by a HwmonSensor.
+type HwmonKind int
+
+// Valid HwmonKind values.
+const (
+	// HwmonTemperature is read via Temperature() or Sense().
+	HwmonTemperature HwmonKind = iota
+	// HwmonFan is read via FanSpeed().
+	HwmonFan
+	// HwmonVoltage is read via Voltage().
+	HwmonVoltage
+	// HwmonCurrent is read via Current().
+	HwmonCurrent
+)
+
+const hwmonKindName = "TemperatureFanVoltageCurrent"
+
+var hwmonKindIndex = [...]uint8{0, 11, 14, 21, 28}
+
+func (i HwmonKind) String() string {
+	if i < 0 || i >= HwmonKind(len(hwmonKindIndex)-1) {
+		return "HwmonKind(" + strconv.Itoa(int(i)) + ")"
+	}
+	return hwmonKindName[hwmonKindIndex[i]:hwmonKindIndex[i+1]]
+}
+
+// HwmonSensor represents one input of a hardware monitoring chip, as exposed
+// in /sys/class/hwmon.
+//
+// Temperature sensors implement physic.SenseEnv so they can be used like any
+// other environmental sensor. The other kinds are read with the method
+// matching their kind.
+type HwmonSensor struct {
+	name  string
+	chip  string
+	label string
+	kind  HwmonKind
+	path  string
+
+	mu sync.Mutex
+	f  fileIO
+	c  sampler.Continuous
+}
+
+func (h *HwmonSensor) String() string {
+	return h.name
+}
+
+// Halt implements conn.Resource.
+//
+// It stops the continuous sensing initiated by SenseContinuous().
+func (h *HwmonSensor) Halt() error {
+	h.c.Stop()
+	return nil
+}
+
+// Chip returns the name of the chip as exported by the driver, e.g.
+// "coretemp".
+func (h *HwmonSensor) Chip() string {
+	return h.chip
+}
+
+// Label returns the label of the sensor, e.g. "VDD_CPU", or the attribute
+// name, e.g. "in1", when the driver doesn't export one.
+func (h *HwmonSensor) Label() string {
+	return h.label
+}
+
+// Kind returns the kind of value measured.
+func (h *HwmonSensor) Kind() HwmonKind {
+	return h.kind
+}
+
+// Temperature returns the temperature measured by a HwmonTemperature sensor.
+func (h *HwmonSensor) Temperature() (physic.Temperature, error) {
+	v, err := h.read(HwmonTemperature)
+	// Reported in millidegree Celsius.
+	return physic.Temperature(v)*physic.MilliKelvin + physic.ZeroCelsius, err
+}
+
+// FanSpeed returns the rotation speed measured by a HwmonFan sensor.
+func (h *HwmonSensor) FanSpeed() (physic.Frequency, error) {
+	v, err := h.read(HwmonFan)
+	// Reported in revolutions per minute.
+	return physic.Frequency(v) * physic.Hertz / 60, err
+}
+
+// Voltage returns the electric potential measured by a HwmonVoltage sensor.
+func (h *HwmonSensor) Voltage() (physic.ElectricPotential, error) {
+	v, err := h.read(HwmonVoltage)
+	// Reported in millivolt.
+	return physic.ElectricPotential(v) * physic.MilliVolt, err
+}
+
+// Current returns the electric current measured by a HwmonCurrent sensor.
+func (h *HwmonSensor) Current() (physic.ElectricCurrent, error) {
+	v, err := h.read(HwmonCurrent)
+	// Reported in milliampere.
+	return physic.ElectricCurrent(v) * physic.MilliAmpere, err
+}
+
+// Sense implements physic.SenseEnv.
+//
+// Only HwmonTemperature sensors are supported.
+func (h *HwmonSensor) Sense(e *physic.Env) error {
+	t, err := h.Temperature()
+	if err != nil {
+		return err
+	}
+	e.Temperature = t
+	return nil
+}
+
+// SenseContinuous implements physic.SenseEnv.
+//
+// Only HwmonTemperature sensors are supported.
+func (h *HwmonSensor) SenseContinuous(interval time.Duration) (<-chan physic.Env, error) {
+	if h.kind != HwmonTemperature {
+		return nil, fmt.Errorf("sysfs-hwmon (%s): continuous sensing is only supported on temperature sensors", h)
+	}
+	return h.c.Start(h, interval)
+}
+
+// Precision implements physic.SenseEnv.
+func (h *HwmonSensor) Precision(e *physic.Env) {
+	if h.kind == HwmonTemperature {
+		e.Temperature = physic.MilliKelvin
+	}
+}
+
+//
+
+// hwmonRoot is where the hwmon devices are found. It is overridden in unit
+// tests.
+var hwmonRoot = "/sys/class/hwmon/"
+
+// hwmonKinds maps the sysfs attribute prefix to the kind of sensor.
+var hwmonKinds = map[string]HwmonKind{
+	"temp": HwmonTemperature,
+	"fan":  HwmonFan,
+	"in":   HwmonVoltage,
+	"curr": HwmonCurrent,
+}
+
+// read returns the raw value of the sensor.
+func (h *HwmonSensor) read(k HwmonKind) (int, error) {
+	if h.kind != k {
+		return 0, fmt.Errorf("sysfs-hwmon (%s): is a %s sensor, not %s", h, h.kind, k)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.f == nil {
+		f, err := fileIOOpen(h.path, os.O_RDONLY)
+		if err != nil {
+			return 0, fmt.Errorf("sysfs-hwmon (%s): %v", h, err)
+		}
+		h.f = f
+	}
+	var buf [24]byte
+	n, err := seekRead(h.f, buf[:])
+	if err != nil {
+		return 0, fmt.Errorf("sysfs-hwmon (%s): %v", h, err)
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(buf[:n])))
+	if err != nil {
+		return 0, fmt.Errorf("sysfs-hwmon (%s): %v", h, err)
+	}
+	return v, nil
+}
+
+// hwmonSensors returns the sensors of one hwmon device.
+//
+// Old drivers expose the attributes in the device directory instead of the
+// hwmon directory.
+func hwmonSensors(dir string) []*HwmonSensor {
+	chip, err := readString(dir + "/name")
+	if err != nil {
+		if chip, err = readString(dir + "/device/name"); err != nil {
+			return nil
+		}
+		dir += "/device"
+	}
+	items, err := filepath.Glob(dir + "/*_input")
+	if err != nil {
+		return nil
+	}
+	var out []*HwmonSensor
+	for _, item := range items {
+		attr := strings.TrimSuffix(filepath.Base(item), "_input")
+		i := strings.IndexAny(attr, "0123456789")
+		if i <= 0 {
+			continue
+		}
+		kind, ok := hwmonKinds[attr[:i]]
+		if !ok {
+			continue
+		}
+		label, err := readString(dir + "/" + attr + "_label")
+		if err != nil || label == "" {
+			label = attr
+		}
+		out = append(out, &HwmonSensor{
+			name:  chip + "/" + label,
+			chip:  chip,
+			label: label,
+			kind:  kind,
+			path:  item,
+		})
+	}
+	sort.Sort(byKind(out))
+	return out
+}
+
+// byKind sorts the sensors of a chip by kind then by attribute number.
+type byKind []*HwmonSensor
+
+func (b byKind) Len() int {
+	return len(b)
+}
+
+func (b byKind) Less(i, j int) bool {
+	if b[i].kind != b[j].kind {
+		return b[i].kind < b[j].kind
+	}
+	return attrNumber(b[i].path) < attrNumber(b[j].path)
+}
+
+func (b byKind) Swap(i, j int) {
+	b[i], b[j] = b[j], b[i]
+}
+
+// attrNumber returns the number in an attribute path like ".../in12_input".
+func attrNumber(path string) int {
+	s := strings.TrimSuffix(filepath.Base(path), "_input")
+	n, _ := strconv.Atoi(strings.TrimLeft(s, "abcdefghijklmnopqrstuvwxyz"))
+	return n
+}
+
+// driverHwmon implements periph.Driver.
+type driverHwmon struct {
+}
+
+func (d *driverHwmon) String() string {
+	return "sysfs-hwmon"
+}
+
+func (d *driverHwmon) Prerequisites() []string {
+	return nil
+}
+
+func (d *driverHwmon) After() []string {
+	return nil
+}
+
+// Init initializes the hwmon sysfs handling code.
+//
+// Uses hwmon sysfs as described at
+// https://www.kernel.org/doc/Documentation/hwmon/sysfs-interface
+func (d *driverHwmon) Init() (bool, error) {
+	items, err := filepath.Glob(hwmonRoot + "hwmon*")
+	if err != nil {
+		return true, err
+	}
+	sortNumerically(items, "hwmon")
+	// Number of hwmon devices seen per chip name.
+	chips := map[string]int{}
+	for _, item := range items {
+		s := hwmonSensors(item)
+		if len(s) == 0 {
+			continue
+		}
+		if n := chips[s[0].chip]; n != 0 {
+			for _, h := range s {
+				h.name = h.chip + "#" + strconv.Itoa(n) + "/" + h.label
+			}
+		}
+		chips[s[0].chip]++
+		HwmonSensors = append(HwmonSensors, s...)
+	}
+	if len(HwmonSensors) == 0 {
+		return false, errors.New("sysfs-hwmon: no sensor found")
+	}
+	return true, nil
+}
+
+// Close stops the continuous sensing, closes the handles and forgets about the
+// sensors found by Init().
+func (d *driverHwmon) Close() error {
+	var err error
+	for _, h := range HwmonSensors {
+		h.c.Stop()
+		h.mu.Lock()
+		if h.f != nil {
+			if err2 := h.f.Close(); err == nil {
+				err = err2
+			}
+			h.f = nil
+		}
+		h.mu.Unlock()
+	}
+	HwmonSensors = nil
+	return err
+}
+
+func init() {
+	if isLinux {
+		periph.MustRegister(&drvHwmon)
+	}
+}
+
+var drvHwmon driverHwmon
+
+var _ conn.Resource = &HwmonSensor{}
+var _ physic.SenseEnv = &HwmonSensor{}
@@ -0,0 +1,226 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+)
+
+func TestHwmonDriver(t *testing.T) {
+	root := fakeHwmonTree(t)
+	defer os.RemoveAll(root)
+	defer resetHwmon()
+	d := driverHwmon{}
+	if d.String() != "sysfs-hwmon" || d.Prerequisites() != nil || d.After() != nil {
+		t.Fatal("unexpected driver")
+	}
+	if ok, err := d.Init(); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	// Sorted by device number, then by kind and attribute number.
+	exp := []struct {
+		name string
+		kind HwmonKind
+	}{
+		{"coretemp/Package id 0", HwmonTemperature},
+		{"coretemp/Core 0", HwmonTemperature},
+		{"ina3221/VDD_IN", HwmonVoltage},
+		{"ina3221/in2", HwmonVoltage},
+		{"ina3221/in10", HwmonVoltage},
+		{"ina3221/VDD_IN", HwmonCurrent},
+		{"nct6775/fan2", HwmonFan},
+		{"coretemp#1/Core 0", HwmonTemperature},
+		{"legacy/temp1", HwmonTemperature},
+	}
+	if len(HwmonSensors) != len(exp) {
+		t.Fatal(HwmonSensors)
+	}
+	for i, e := range exp {
+		if h := HwmonSensors[i]; h.String() != e.name || h.Kind() != e.kind {
+			t.Fatal(i, h, h.Kind())
+		}
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if HwmonSensors != nil {
+		t.Fatal("must have been forgotten")
+	}
+}
+
+func TestHwmonDriver_none(t *testing.T) {
+	defer resetHwmon()
+	root, err := ioutil.TempDir("", "periph_sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	hwmonRoot = root + "/"
+	if ok, err := (&driverHwmon{}).Init(); ok || err == nil {
+		t.Fatal("must skip")
+	}
+}
+
+func TestHwmonSensor(t *testing.T) {
+	root := fakeHwmonTree(t)
+	defer os.RemoveAll(root)
+	defer resetHwmon()
+	d := driverHwmon{}
+	if _, err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	h, err := HwmonSensorByName("coretemp/Core 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Chip() != "coretemp" || h.Label() != "Core 0" {
+		t.Fatal(h.Chip(), h.Label())
+	}
+	if err := h.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	e := physic.Env{}
+	if err := h.Sense(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Temperature != 42500*physic.MilliKelvin+physic.ZeroCelsius {
+		t.Fatal(e.Temperature)
+	}
+	h.Precision(&e)
+	if e.Temperature != physic.MilliKelvin {
+		t.Fatal(e.Temperature)
+	}
+	c, err := h.SenseContinuous(time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := <-c; e.Temperature != 42500*physic.MilliKelvin+physic.ZeroCelsius {
+		t.Fatal(e.Temperature)
+	}
+	if _, err := h.Voltage(); err == nil {
+		t.Fatal("not a voltage sensor")
+	}
+
+	h, err = HwmonSensorByName("ina3221/in2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Voltage(); v != 3300*physic.MilliVolt || err != nil {
+		t.Fatal(v, err)
+	}
+	if _, err := h.SenseContinuous(time.Second); err == nil {
+		t.Fatal("only supported on temperature sensors")
+	}
+	e = physic.Env{}
+	if err := h.Sense(&e); err == nil || e.Temperature != 0 {
+		t.Fatal("only supported on temperature sensors")
+	}
+	h.Precision(&e)
+	if e.Temperature != 0 {
+		t.Fatal(e.Temperature)
+	}
+
+	// The first match is returned.
+	h, err = HwmonSensorByName("ina3221/VDD_IN")
+	if err != nil || h.Kind() != HwmonVoltage {
+		t.Fatal(h, err)
+	}
+	if v, err := HwmonSensors[5].Current(); v != 1250*physic.MilliAmpere || err != nil {
+		t.Fatal(v, err)
+	}
+
+	h, err = HwmonSensorByName("nct6775/fan2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.FanSpeed(); v != 40*physic.Hertz || err != nil {
+		t.Fatal(v, err)
+	}
+
+	// The second coretemp chip is disambiguated.
+	h, err = HwmonSensorByName("coretemp#1/Core 0")
+	if err != nil || h.Chip() != "coretemp" || h.Label() != "Core 0" {
+		t.Fatal(h, err)
+	}
+	if v, err := h.Temperature(); v != 45000*physic.MilliKelvin+physic.ZeroCelsius || err != nil {
+		t.Fatal(v, err)
+	}
+
+	if _, err := HwmonSensorByName("inexistent"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestHwmonSensor_fail(t *testing.T) {
+	defer resetHwmon()
+	h := HwmonSensor{name: "chip/temp1", kind: HwmonTemperature, path: "//\000/temp1_input"}
+	if _, err := h.Temperature(); err == nil || err.Error() != "sysfs-hwmon (chip/temp1): file I/O is inhibited" {
+		t.Fatal(err)
+	}
+	if err := h.Sense(&physic.Env{}); err == nil {
+		t.Fatal("expected error")
+	}
+	h.f = &fileRead{t: t, ops: [][]byte{[]byte("abc\n")}}
+	if _, err := h.Temperature(); err == nil {
+		t.Fatal("invalid value")
+	}
+}
+
+func TestHwmonKind_String(t *testing.T) {
+	if s := HwmonCurrent.String(); s != "Current" {
+		t.Fatal(s)
+	}
+	if s := HwmonKind(10).String(); s != "HwmonKind(10)" {
+		t.Fatal(s)
+	}
+}
+
+//
+
+func resetHwmon() {
+	HwmonSensors = nil
+	hwmonRoot = "/sys/class/hwmon/"
+	reset()
+}
+
+// fakeHwmonTree creates a fake /sys/class/hwmon with six devices. hwmon3 has
+// the same chip name as hwmon0, hwmon10 uses the legacy layout and hwmon4 has
+// no name.
+func fakeHwmonTree(t *testing.T) string {
+	root := fakeTree(t, map[string]string{
+		"hwmon0/name":                "coretemp\n",
+		"hwmon0/temp1_input":         "40000\n",
+		"hwmon0/temp1_label":         "Package id 0\n",
+		"hwmon0/temp2_input":         "42500\n",
+		"hwmon0/temp2_label":         "Core 0\n",
+		"hwmon0/temp2_crit":          "100000\n",
+		"hwmon1/name":                "ina3221\n",
+		"hwmon1/in1_input":           "5000\n",
+		"hwmon1/in1_label":           "VDD_IN\n",
+		"hwmon1/in2_input":           "3300\n",
+		"hwmon1/in10_input":          "1800\n",
+		"hwmon1/curr1_input":         "1250\n",
+		"hwmon1/curr1_label":         "VDD_IN\n",
+		"hwmon1/power1_input":        "6250000\n",
+		"hwmon2/name":                "nct6775\n",
+		"hwmon2/fan2_input":          "2400\n",
+		"hwmon2/fan2_min":            "0\n",
+		"hwmon3/name":                "coretemp\n",
+		"hwmon3/temp2_input":         "45000\n",
+		"hwmon3/temp2_label":         "Core 0\n",
+		"hwmon10/device/name":        "legacy\n",
+		"hwmon10/device/temp1_input": "30000\n",
+		"hwmon4/temp1_input":         "30000\n",
+	})
+	hwmonRoot = root + "/"
+	return root
+}
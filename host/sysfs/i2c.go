@@ -20,6 +20,7 @@ import (
 	"periph.io/x/periph/conn/gpio/gpioreg"
 	"periph.io/x/periph/conn/i2c"
 	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/i2c/smbus"
 	"periph.io/x/periph/conn/physic"
 )
 
@@ -38,6 +39,15 @@ func I2CSetSpeedHook(h func(f physic.Frequency) error) error {
 	return nil
 }
 
+// I2CClearSpeedHook removes the hook set with I2CSetSpeedHook().
+//
+// It is meant to be called by the driver that set the hook when it is closed.
+func I2CClearSpeedHook() {
+	drvI2C.mu.Lock()
+	defer drvI2C.mu.Unlock()
+	drvI2C.setSpeed = nil
+}
+
 // NewI2C opens an I²C bus via its sysfs interface as described at
 // https://www.kernel.org/doc/Documentation/i2c/dev-interface.
 //
@@ -122,7 +132,7 @@ func (i *I2C) Tx(addr uint16, w, r []byte) error {
 	i.mu.Lock()
 	defer i.mu.Unlock()
 	if err := i.f.Ioctl(ioctlRdwr, pp); err != nil {
-		return fmt.Errorf("sysfs-i2c: %v", err)
+		return i.wrap(err)
 	}
 	return nil
 }
@@ -143,6 +153,43 @@ func (i *I2C) SetSpeed(f physic.Frequency) error {
 	return errors.New("sysfs-i2c: not supported")
 }
 
+// SMBusFunc implements smbus.Native.
+//
+// It returns the SMBus functions advertised by the kernel driver.
+func (i *I2C) SMBusFunc() smbus.Func {
+	return smbus.Func(i.fn & funcSMBusMask)
+}
+
+// SMBusXfer implements smbus.Native.
+//
+// It uses the I2C_SMBUS ioctl. Only 7 bits addresses are supported. It fails
+// if a kernel driver is bound to the device at addr.
+func (i *I2C) SMBusXfer(addr uint16, read bool, cmd byte, p smbus.Protocol, pec bool, data *[smbus.BlockMax + 2]byte) error {
+	if addr >= 0x80 {
+		return errors.New("sysfs-i2c: invalid address")
+	}
+	d := smbusIoctlData{command: cmd, size: uint32(p), data: uintptr(unsafe.Pointer(data))}
+	if read {
+		d.readWrite = 1
+	}
+	var v uintptr
+	if pec {
+		v = 1
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if err := i.f.Ioctl(ioctlSlave, uintptr(addr)); err != nil {
+		return i.wrap(err)
+	}
+	if err := i.f.Ioctl(ioctlPEC, v); err != nil {
+		return i.wrap(err)
+	}
+	if err := i.f.Ioctl(ioctlSMBus, uintptr(unsafe.Pointer(&d))); err != nil {
+		return i.wrap(err)
+	}
+	return nil
+}
+
 // SCL implements i2c.Pins.
 func (i *I2C) SCL() gpio.PinIO {
 	i.initPins()
@@ -159,7 +206,7 @@ func (i *I2C) SDA() gpio.PinIO {
 
 func newI2C(busNumber int) (*I2C, error) {
 	// Use the devfs path for now instead of sysfs path.
-	f, err := ioctlOpen(fmt.Sprintf("/dev/i2c-%d", busNumber), os.O_RDWR)
+	f, err := ioctlOpen(i2cPath(busNumber), os.O_RDWR)
 	if err != nil {
 		// Try to be helpful here. There are generally two cases:
 		// - /dev/i2c-X doesn't exist. In this case, /boot/config.txt has to be
@@ -184,6 +231,19 @@ func newI2C(busNumber int) (*I2C, error) {
 	return i, nil
 }
 
+// wrap returns a *conn.RemovedError if the bus was removed, otherwise err
+// prefixed with the driver name.
+func (i *I2C) wrap(err error) error {
+	if r := removedError(i2cPath(i.busNumber), err); r != nil {
+		return r
+	}
+	return fmt.Errorf("sysfs-i2c: %v", err)
+}
+
+func i2cPath(busNumber int) string {
+	return fmt.Sprintf("%s/i2c-%d", devDir, busNumber)
+}
+
 func (i *I2C) initPins() {
 	i.mu.Lock()
 	if i.scl == nil {
@@ -208,6 +268,8 @@ const (
 	ioctlTenBits = 0x704 // TODO(maruel): Expose this but the header says it's broken (!?)
 	ioctlFuncs   = 0x705
 	ioctlRdwr    = 0x707
+	ioctlPEC     = 0x708
+	ioctlSMBus   = 0x720
 )
 
 // flags
@@ -244,6 +306,9 @@ const (
 	funcSMBusWriteBlockData = 0x02000000
 	funcSMBusReadI2CBlock   = 0x04000000 // I2C-like block xfer
 	funcSMBusWriteI2CBlock  = 0x08000000 // w/ 1-byte reg. addr.
+
+	// funcSMBusMask are the functions usable via smbus.Native.
+	funcSMBusMask = funcSMBusPEC | funcSMBusBlockProcCall | funcSMBusQuick | funcSMBusReadByte | funcSMBusWriteByte | funcSMBusReadByteData | funcSMBusWriteByteData | funcSMBusReadWordData | funcSMBusWriteWordData | funcSMBusProcCall | funcSMBusReadBlockData | funcSMBusWriteBlockData
 )
 
 func (f functionality) String() string {
@@ -310,6 +375,14 @@ type rdwrIoctlData struct {
 	nmsgs uint32
 }
 
+// smbusIoctlData is struct i2c_smbus_ioctl_data.
+type smbusIoctlData struct {
+	readWrite uint8
+	command   uint8
+	size      uint32
+	data      uintptr // Pointer to union i2c_smbus_data
+}
+
 type i2cMsg struct {
 	addr   uint16 // Address to communicate with
 	flags  uint16 // 1 for read, see i2c.h for more details
@@ -322,8 +395,11 @@ type i2cMsg struct {
 // driverI2C implements periph.Driver.
 type driverI2C struct {
 	mu       sync.Mutex
-	buses    []string
 	setSpeed func(f physic.Frequency) error
+
+	// Protected by scanMu.
+	initialized bool // Set by Init() even if nothing was found, cleared by Close(); see Rescan()
+	buses       []string
 }
 
 func (d *driverI2C) String() string {
@@ -339,31 +415,70 @@ func (d *driverI2C) After() []string {
 }
 
 func (d *driverI2C) Init() (bool, error) {
-	// Do not use "/sys/bus/i2c/devices/i2c-" as Raspbian's provided udev rules
-	// only modify the ACL of /dev/i2c-* but not the ones in /sys/bus/...
-	prefix := "/dev/i2c-"
-	items, err := filepath.Glob(prefix + "*")
+	scanMu.Lock()
+	defer scanMu.Unlock()
+	d.initialized = true
+	n, err := d.scan()
 	if err != nil {
 		return true, err
 	}
-	if len(items) == 0 {
+	if n == 0 {
 		return false, errors.New("no I²C bus found")
 	}
+	return true, nil
+}
+
+// scan registers the I²C buses that appeared and unregisters the ones that
+// disappeared since the last scan.
+//
+// It returns the number of buses found. scanMu must be held.
+func (d *driverI2C) scan() (int, error) {
+	// Do not use "/sys/bus/i2c/devices/i2c-" as Raspbian's provided udev rules
+	// only modify the ACL of /dev/i2c-* but not the ones in /sys/bus/...
+	prefix := devDir + "/i2c-"
+	items, err := filepath.Glob(prefix + "*")
+	if err != nil {
+		return 0, err
+	}
 	// Make sure they are registered in order.
 	sort.Strings(items)
+	var buses []int
+	found := map[string]struct{}{}
 	for _, item := range items {
 		bus, err := strconv.Atoi(item[len(prefix):])
 		if err != nil {
 			continue
 		}
-		name := fmt.Sprintf("/dev/i2c-%d", bus)
-		d.buses = append(d.buses, name)
+		buses = append(buses, bus)
+		found[i2cPath(bus)] = struct{}{}
+	}
+	d.buses = forget(d.buses, found, i2creg.Unregister)
+	for _, bus := range buses {
+		name := i2cPath(bus)
+		if contains(d.buses, name) {
+			continue
+		}
 		aliases := []string{fmt.Sprintf("I2C%d", bus)}
 		if err := i2creg.Register(name, aliases, bus, openerI2C(bus).Open); err != nil {
-			return true, err
+			return len(buses), err
 		}
+		d.buses = append(d.buses, name)
 	}
-	return true, nil
+	return len(buses), nil
+}
+
+// Close unregisters the I²C buses.
+//
+// The speed hook is kept, as it is owned by the driver that set it.
+func (d *driverI2C) Close() error {
+	scanMu.Lock()
+	defer scanMu.Unlock()
+	d.initialized = false
+	for _, name := range d.buses {
+		_ = i2creg.Unregister(name)
+	}
+	d.buses = nil
+	return nil
 }
 
 type openerI2C int
@@ -386,3 +501,4 @@ var drvI2C driverI2C
 
 var _ i2c.Bus = &I2C{}
 var _ i2c.BusCloser = &I2C{}
+var _ smbus.Native = &I2C{}
@@ -0,0 +1,209 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/i2c"
+)
+
+// ShareImage implements i2c.RegisterTarget.
+//
+// It instantiates the kernel's I²C slave EEPROM backend at addr, as described
+// at https://www.kernel.org/doc/Documentation/i2c/slave-eeprom-backend. This
+// requires a kernel built with CONFIG_I2C_SLAVE_EEPROM and a bus driver that
+// supports the target mode, and generally requires running as root.
+//
+// The kernel answers the controller by itself from a 256 bytes memory, which
+// holds the register image. The kernel doesn't
+// notify userspace of the transactions, so the memory is polled every 10ms.
+// As such, the controller reads are not observed; h.Image() is called once
+// at start and after each h.OnChange() call to refresh the memory.
+//
+// Only the bytes modified by h.Image() are written back, so a controller
+// write to other registers that occurs in the meantime is not lost. A write to
+// one of the modified registers at the same time is overwritten. Only 7 bits
+// addresses are supported.
+func (i *I2C) ShareImage(addr uint16, h i2c.RegisterImage) (io.Closer, error) {
+	if addr >= 0x80 {
+		return nil, errors.New("sysfs-i2c: invalid target address")
+	}
+	t := &i2cTarget{
+		root: fmt.Sprintf("/sys/bus/i2c/devices/i2c-%d/", i.busNumber),
+		addr: addr | i2cTargetFlag,
+		h:    h,
+	}
+	if err := sysfsWrite(t.root+"new_device", fmt.Sprintf("slave-24c02 0x%04x", t.addr)); err != nil {
+		return nil, fmt.Errorf("sysfs-i2c: failed to create target: %v", err)
+	}
+	var err error
+	t.mem, err = fileIOOpen(fmt.Sprintf("/sys/bus/i2c/devices/%d-%04x/slave-eeprom", i.busNumber, t.addr), os.O_RDWR)
+	if err == nil {
+		err = t.load()
+	}
+	if err != nil {
+		if t.mem != nil {
+			_ = t.mem.Close()
+		}
+		_ = t.deleteDevice()
+		return nil, fmt.Errorf("sysfs-i2c: %v", err)
+	}
+	t.stop = make(chan struct{})
+	t.done = make(chan struct{})
+	go t.run(10 * time.Millisecond)
+	return t, nil
+}
+
+//
+
+// i2cTargetFlag is I2C_CLIENT_SLAVE as used when instantiating a device.
+const i2cTargetFlag = 0x1000
+
+// i2cTargetSize is the size of the slave-24c02 memory.
+const i2cTargetSize = 256
+
+// i2cTarget is an I²C slave EEPROM backend instance.
+type i2cTarget struct {
+	root string
+	addr uint16
+	h    i2c.RegisterImage
+	mem  fileIO
+	stop chan struct{}
+	done chan struct{}
+
+	mu   sync.Mutex
+	last [i2cTargetSize]byte // Expected content of the memory.
+	err  error               // First error that occurred in run().
+}
+
+// Close stops responding and deletes the device.
+//
+// It returns the first error that occurred while polling, if any.
+func (t *i2cTarget) Close() error {
+	close(t.stop)
+	<-t.done
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	err := t.err
+	if err2 := t.mem.Close(); err == nil {
+		err = err2
+	}
+	if err2 := t.deleteDevice(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return fmt.Errorf("sysfs-i2c: %v", err)
+	}
+	return nil
+}
+
+func (t *i2cTarget) run(interval time.Duration) {
+	defer close(t.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			t.mu.Lock()
+			if err := t.check(); err != nil && t.err == nil {
+				t.err = err
+			}
+			t.mu.Unlock()
+		}
+	}
+}
+
+// check reads the memory and reports the modified ranges.
+func (t *i2cTarget) check() error {
+	var cur [i2cTargetSize]byte
+	if _, err := seekRead(t.mem, cur[:]); err != nil {
+		return err
+	}
+	if bytes.Equal(cur[:], t.last[:]) {
+		return nil
+	}
+	for i := 0; i < len(cur); {
+		if cur[i] == t.last[i] {
+			i++
+			continue
+		}
+		j := i + 1
+		for ; j < len(cur) && cur[j] != t.last[j]; j++ {
+		}
+		w := make([]byte, 0, j-i+1)
+		w = append(w, byte(i))
+		t.h.OnChange(append(w, cur[i:j]...))
+		i = j
+	}
+	return t.refresh(&cur)
+}
+
+// load initializes the memory with the data returned by Image().
+func (t *i2cTarget) load() error {
+	var cur [i2cTargetSize]byte
+	if _, err := seekRead(t.mem, cur[:]); err != nil {
+		return err
+	}
+	return t.refresh(&cur)
+}
+
+// refresh writes the bytes returned by Image() that differ from cur, the
+// content of the memory as last read.
+func (t *i2cTarget) refresh(cur *[i2cTargetSize]byte) error {
+	var want [i2cTargetSize]byte
+	for i := range want {
+		want[i] = 0xFF
+	}
+	copy(want[:], t.h.Image())
+	// last tracks the content of the memory, so a failed write doesn't cause
+	// spurious OnChange() calls.
+	t.last = *cur
+	for i := 0; i < len(want); {
+		if want[i] == cur[i] {
+			i++
+			continue
+		}
+		j := i + 1
+		for ; j < len(want) && want[j] != cur[j]; j++ {
+		}
+		if _, err := t.mem.Seek(int64(i), io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := t.mem.Write(want[i:j]); err != nil {
+			return err
+		}
+		copy(t.last[i:j], want[i:j])
+		i = j
+	}
+	return nil
+}
+
+func (t *i2cTarget) deleteDevice() error {
+	return sysfsWrite(t.root+"delete_device", fmt.Sprintf("0x%04x", t.addr))
+}
+
+// sysfsWrite writes s to the file at path.
+func sysfsWrite(path, s string) error {
+	f, err := fileIOOpen(path, os.O_WRONLY)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write([]byte(s))
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+var _ i2c.RegisterTarget = &I2C{}
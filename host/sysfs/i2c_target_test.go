@@ -0,0 +1,186 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestI2C_ShareImage(t *testing.T) {
+	defer reset()
+	files := map[string]*memFile{}
+	fileIOOpen = func(path string, flag int) (fileIO, error) {
+		if path == "/sys/bus/i2c/devices/1-1042/slave-eeprom" && files["new_device"] == nil {
+			return nil, os.ErrNotExist
+		}
+		f := &memFile{}
+		switch path {
+		case "/sys/bus/i2c/devices/i2c-1/new_device":
+			files["new_device"] = f
+		case "/sys/bus/i2c/devices/i2c-1/delete_device":
+			files["delete_device"] = f
+		case "/sys/bus/i2c/devices/1-1042/slave-eeprom":
+			f.data = make([]byte, 256)
+			files["eeprom"] = f
+		default:
+			t.Fatalf("unexpected path %q", path)
+		}
+		return f, nil
+	}
+	bus := I2C{f: &ioctlClose{}, busNumber: 1}
+	h := &fakeRegisterImage{regs: []byte{1, 2, 3}}
+	if _, err := bus.ShareImage(0x80, h); err == nil {
+		t.Fatal("invalid address")
+	}
+	l, err := bus.ShareImage(0x42, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(files["new_device"].data); s != "slave-24c02 0x1042" {
+		t.Fatal(s)
+	}
+	m := files["eeprom"]
+	if !bytes.Equal(m.data[:4], []byte{1, 2, 3, 0xFF}) {
+		t.Fatal(m.data[:4])
+	}
+
+	// Simulate a controller writing 2 registers at address 1 and one at 10.
+	tg := l.(*i2cTarget)
+	tg.mu.Lock()
+	m.data[1] = 20
+	m.data[2] = 30
+	m.data[10] = 100
+	err = tg.check()
+	tg.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.writes) != 2 || !bytes.Equal(h.writes[0], []byte{1, 20, 30}) || !bytes.Equal(h.writes[1], []byte{10, 100}) {
+		t.Fatal(h.writes)
+	}
+	// The memory was refreshed from Image().
+	if !bytes.Equal(m.data[:4], []byte{1, 20, 30, 0xFF}) || m.data[10] != 0xFF {
+		t.Fatal(m.data[:11])
+	}
+
+	// A controller write to another register while the handler runs is not
+	// overwritten and is reported on the next check.
+	h.writes = nil
+	h.onChange = func() {
+		m.data[50] = 7
+	}
+	tg.mu.Lock()
+	m.data[0] = 9
+	err = tg.check()
+	tg.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.data[0] != 9 || m.data[50] != 7 {
+		t.Fatal(m.data[:51])
+	}
+	h.onChange = nil
+	tg.mu.Lock()
+	err = tg.check()
+	tg.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.writes) != 2 || !bytes.Equal(h.writes[0], []byte{0, 9}) || !bytes.Equal(h.writes[1], []byte{50, 7}) {
+		t.Fatal(h.writes)
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s := string(files["delete_device"].data); s != "0x1042" {
+		t.Fatal(s)
+	}
+}
+
+func TestI2C_ShareImage_Err(t *testing.T) {
+	defer reset()
+	fileIOOpen = func(path string, flag int) (fileIO, error) {
+		return nil, errors.New("injected")
+	}
+	bus := I2C{f: &ioctlClose{}, busNumber: 1}
+	if _, err := bus.ShareImage(0x42, &fakeRegisterImage{}); err == nil {
+		t.Fatal("new_device failed")
+	}
+	var deleted bool
+	fileIOOpen = func(path string, flag int) (fileIO, error) {
+		if path == "/sys/bus/i2c/devices/1-1042/slave-eeprom" {
+			return nil, os.ErrPermission
+		}
+		if path == "/sys/bus/i2c/devices/i2c-1/delete_device" {
+			deleted = true
+		}
+		return &memFile{}, nil
+	}
+	if _, err := bus.ShareImage(0x42, &fakeRegisterImage{}); err == nil {
+		t.Fatal("slave-eeprom failed")
+	}
+	if !deleted {
+		t.Fatal("device must be deleted on failure")
+	}
+}
+
+//
+
+// memFile is a fileIO backed by memory. Write() replaces the content unless
+// it was preallocated, in which case it is overwritten in place at the
+// current offset.
+type memFile struct {
+	file
+	data []byte
+	off  int
+}
+
+func (m *memFile) Read(p []byte) (int, error) {
+	n := copy(p, m.data[m.off:])
+	m.off += n
+	return n, nil
+}
+
+func (m *memFile) Seek(offset int64, whence int) (int64, error) {
+	if whence != 0 || offset < 0 || int(offset) > len(m.data) {
+		return 0, errors.New("invalid seek")
+	}
+	m.off = int(offset)
+	return offset, nil
+}
+
+func (m *memFile) Write(p []byte) (int, error) {
+	if m.data == nil {
+		m.data = append([]byte{}, p...)
+		return len(p), nil
+	}
+	n := copy(m.data[m.off:], p)
+	m.off += n
+	return n, nil
+}
+
+type fakeRegisterImage struct {
+	regs     []byte
+	writes   [][]byte
+	onChange func() // called from OnChange() to emulate a concurrent write
+}
+
+func (f *fakeRegisterImage) OnChange(w []byte) {
+	f.writes = append(f.writes, w)
+	if f.onChange != nil {
+		f.onChange()
+	}
+	if int(w[0]) < len(f.regs) {
+		copy(f.regs[w[0]:], w[1:])
+	}
+}
+
+func (f *fakeRegisterImage) Image() []byte {
+	return f.regs
+}
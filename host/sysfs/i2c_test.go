@@ -5,9 +5,11 @@
 package sysfs
 
 import (
+	"errors"
 	"testing"
 
 	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/i2c/smbus"
 	"periph.io/x/periph/conn/physic"
 )
 
@@ -100,6 +102,26 @@ func TestDriver_Init(t *testing.T) {
 	if I2CSetSpeedHook(func(f physic.Frequency) error { return nil }) == nil {
 		t.Fatal("second I2CSetSpeedHook must fail")
 	}
+	I2CClearSpeedHook()
+	if err := I2CSetSpeedHook(func(f physic.Frequency) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDriverI2C_Close(t *testing.T) {
+	d := driverI2C{buses: []string{"/dev/i2c-1000"}}
+	if err := i2creg.Register("/dev/i2c-1000", []string{"I2C1000"}, 1000, openerI2C(1000).Open); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := i2creg.Unregister("/dev/i2c-1000"); err == nil {
+		t.Fatal("bus must have been unregistered")
+	}
+	if d.buses != nil {
+		t.Fatal(d.buses)
+	}
 }
 
 func BenchmarkI2C(b *testing.B) {
@@ -114,3 +136,21 @@ func BenchmarkI2C(b *testing.B) {
 		}
 	}
 }
+
+func TestI2C_SMBus(t *testing.T) {
+	bus := I2C{f: &ioctlClose{}, busNumber: 24, fn: 0xFFFFFFFF}
+	if f := bus.SMBusFunc(); f.String() != "PEC|BlockProcCall|Quick|ReadByte|WriteByte|ReadByteData|WriteByteData|ReadWordData|WriteWordData|ProcCall|ReadBlockData|WriteBlockData" {
+		t.Fatal(f)
+	}
+	var data [smbus.BlockMax + 2]byte
+	if err := bus.SMBusXfer(0x80, true, 1, smbus.ByteData, false, &data); err == nil {
+		t.Fatal("invalid address")
+	}
+	if err := bus.SMBusXfer(0x10, true, 1, smbus.ByteData, true, &data); err != nil {
+		t.Fatal(err)
+	}
+	bus.f = &ioctlClose{ioctlErr: errors.New("injected")}
+	if err := bus.SMBusXfer(0x10, false, 1, smbus.ByteData, false, &data); err == nil {
+		t.Fatal("ioctl failed")
+	}
+}
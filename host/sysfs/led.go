@@ -252,6 +252,12 @@ func (d *driverLED) Init() (bool, error) {
 	return true, nil
 }
 
+// Close forgets about the LEDs found by Init().
+func (d *driverLED) Close() error {
+	LEDs = nil
+	return nil
+}
+
 func init() {
 	if isLinux {
 		periph.MustRegister(&drvLED)
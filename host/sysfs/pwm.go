@@ -0,0 +1,504 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"periph.io/x/periph"
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/pin"
+	"periph.io/x/periph/host/distro"
+)
+
+// PWMs is all the PWM channels discovered on this host via sysfs.
+var PWMs []*PWM
+
+// PWMByName returns a *PWM for the channel name, e.g. "pwmchip0/pwm1".
+func PWMByName(name string) (*PWM, error) {
+	for _, p := range PWMs {
+		if p.name == name {
+			return p, nil
+		}
+	}
+	return nil, errors.New("sysfs-pwm: invalid PWM name")
+}
+
+// PWMByPin returns the *PWM driving the GPIO pin name as known by gpioreg,
+// e.g. "GPIO18" or "PB0", or nil if none.
+//
+// The association is deduced from the device tree pinctrl configuration of
+// the PWM controller, where the Nth pin is assumed to be driven by the Nth
+// channel.
+func PWMByPin(name string) *PWM {
+	return pwmByPin[name]
+}
+
+// PWM represents one PWM channel exposed by the kernel in /sys/class/pwm.
+//
+// Its output is controlled via PWM(). Out() sets a 0% or 100% duty cycle.
+type PWM struct {
+	number  int
+	name    string
+	channel int
+	root    string // /sys/class/pwm/pwmchipN/
+	pin     string // The GPIO driven by this channel, if known.
+
+	mu       sync.Mutex
+	err      error  // If open() failed.
+	exported bool   // If it was exported by open().
+	fPeriod  fileIO // handle to pwmN/period
+	fDuty    fileIO // handle to pwmN/duty_cycle
+	fEnable  fileIO // handle to pwmN/enable
+	period   int64  // in ns
+	duty     int64  // in ns
+	enabled  bool
+}
+
+// String implements conn.Resource.
+func (p *PWM) String() string {
+	return fmt.Sprintf("%s(%d)", p.name, p.number)
+}
+
+// Halt implements conn.Resource.
+//
+// It disables the output.
+func (p *PWM) Halt() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fEnable == nil || !p.enabled {
+		return nil
+	}
+	if err := seekWrite(p.fEnable, []byte("0")); err != nil {
+		return p.wrap(err)
+	}
+	p.enabled = false
+	return nil
+}
+
+// Name implements pin.Pin.
+func (p *PWM) Name() string {
+	return p.name
+}
+
+// Number implements pin.Pin.
+func (p *PWM) Number() int {
+	return p.number
+}
+
+// Function implements pin.Pin.
+func (p *PWM) Function() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.enabled || p.period == 0 {
+		return "PWM/Off"
+	}
+	return "PWM/" + (gpio.Duty(p.duty * int64(gpio.DutyMax) / p.period)).String()
+}
+
+// Pin returns the name of the GPIO driven by this channel, or "" if unknown.
+func (p *PWM) Pin() string {
+	return p.pin
+}
+
+// Out implements gpio.PinOut.
+//
+// It sets a duty cycle of 0% or 100%. If no period was set, it uses 1ms.
+func (p *PWM) Out(l gpio.Level) error {
+	d := gpio.Duty(0)
+	if l {
+		d = gpio.DutyMax
+	}
+	return p.PWM(d, 0)
+}
+
+// PWM implements gpio.PinOut.
+//
+// The frequency is converted to a period in nanoseconds. When f is 0, the
+// current period is kept.
+func (p *PWM) PWM(d gpio.Duty, f physic.Frequency) error {
+	if !d.Valid() {
+		return p.wrap(errors.New("invalid duty cycle"))
+	}
+	if f < 0 {
+		return p.wrap(errors.New("invalid frequency"))
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.open(); err != nil {
+		return p.wrap(err)
+	}
+	period := p.period
+	if f != 0 {
+		if period = int64(f.Duration()); period <= 0 {
+			return p.wrap(fmt.Errorf("frequency %s is too high", f))
+		}
+	} else if period == 0 {
+		period = int64(time.Millisecond)
+	}
+	duty := period * int64(d) / int64(gpio.DutyMax)
+	// The kernel rejects a duty cycle longer than the period so the order
+	// matters.
+	if period < p.duty {
+		if err := p.setDuty(duty); err != nil {
+			return p.wrap(err)
+		}
+		if err := p.setPeriod(period); err != nil {
+			return p.wrap(err)
+		}
+	} else {
+		if err := p.setPeriod(period); err != nil {
+			return p.wrap(err)
+		}
+		if err := p.setDuty(duty); err != nil {
+			return p.wrap(err)
+		}
+	}
+	if !p.enabled {
+		if err := seekWrite(p.fEnable, []byte("1")); err != nil {
+			return p.wrap(err)
+		}
+		p.enabled = true
+	}
+	return nil
+}
+
+// SetPolarity sets the polarity of the output; when inversed the output is
+// low during the duty cycle.
+//
+// The output is briefly disabled as the kernel only permits changing the
+// polarity when disabled.
+func (p *PWM) SetPolarity(inversed bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.open(); err != nil {
+		return p.wrap(err)
+	}
+	if p.enabled {
+		if err := seekWrite(p.fEnable, []byte("0")); err != nil {
+			return p.wrap(err)
+		}
+	}
+	v := "normal"
+	if inversed {
+		v = "inversed"
+	}
+	err := writeFile(p.root+"polarity", v)
+	if p.enabled {
+		if err2 := seekWrite(p.fEnable, []byte("1")); err == nil {
+			err = err2
+		}
+	}
+	return p.wrap(err)
+}
+
+//
+
+// pwmRoot is where the PWM chips are found. It is overridden in unit tests.
+var pwmRoot = "/sys/class/pwm/"
+
+// pwmByPin maps the GPIO names to the PWM channel driving it.
+var pwmByPin map[string]*PWM
+
+// pwmPins returns the GPIO name driven by each channel of a pwmchip, keyed by
+// channel.
+//
+// It is overridden in unit tests.
+var pwmPins = pwmPinsDefault
+
+// pwmPinsDefault looks up the device tree node of the pwmchip and maps the
+// pins listed in its default pinctrl configuration to their channel.
+//
+// The channel is deduced from the function the pin is muxed to, as an overlay
+// may only configure some of the channels.
+func pwmPinsDefault(chip string) map[int]string {
+	const base = "/devicetree/base"
+	target, err := filepath.EvalSymlinks(chip + "/device/of_node")
+	if err != nil {
+		return nil
+	}
+	i := strings.Index(target, base+"/")
+	if i == -1 {
+		return nil
+	}
+	t, err := distro.DT()
+	if err != nil {
+		return nil
+	}
+	n := t.Node(target[i+len(base):])
+	if n == nil {
+		return nil
+	}
+	cfg, err := t.Pinctrl(n, "default")
+	if err != nil {
+		return nil
+	}
+	out := map[int]string{}
+	for _, c := range cfg {
+		f := c.PinFunction()
+		for _, p := range c.Pins() {
+			if ch := pwmChannel(p, f); ch != -1 {
+				out[ch] = p
+			}
+		}
+	}
+	return out
+}
+
+// pwmChannel returns the PWM channel driving the pin when muxed to the
+// function f as returned by distro.DTNode.PinFunction(), or -1 if unknown.
+func pwmChannel(pin, f string) int {
+	// Allwinner and others name the function after the channel.
+	if f == "pwm" {
+		return 0
+	}
+	if strings.HasPrefix(f, "pwm") {
+		if n, err := strconv.Atoi(f[3:]); err == nil {
+			return n
+		}
+		return -1
+	}
+	// Broadcom uses the alternate functions.
+	switch pin + "/" + f {
+	case "GPIO12/alt0", "GPIO18/alt5", "GPIO40/alt0":
+		return 0
+	case "GPIO13/alt0", "GPIO19/alt5", "GPIO41/alt0", "GPIO45/alt0":
+		return 1
+	default:
+		return -1
+	}
+}
+
+// open exports the channel if needed and opens the handles.
+//
+// lock must be held.
+func (p *PWM) open() error {
+	if p.fEnable != nil || p.err != nil {
+		return p.err
+	}
+	chip := filepath.Dir(filepath.Clean(p.root)) + "/"
+	if _, err := os.Stat(p.root); os.IsNotExist(err) {
+		if p.err = writeFile(chip+"export", strconv.Itoa(p.channel)); p.err != nil {
+			if os.IsPermission(p.err) {
+				return fmt.Errorf("need more access, try as root or setup udev rules: %v", p.err)
+			}
+			return p.err
+		}
+		p.exported = true
+	}
+	// Like for GPIOs, udev may still be updating the permissions.
+	var err error
+	timeout := 5 * time.Second
+	for start := time.Now(); time.Since(start) < timeout; {
+		p.fPeriod, err = fileIOOpen(p.root+"period", os.O_RDWR)
+		if err == nil || !os.IsPermission(err) {
+			break
+		}
+	}
+	if err != nil {
+		p.err = err
+		return err
+	}
+	if p.fDuty, err = fileIOOpen(p.root+"duty_cycle", os.O_RDWR); err != nil {
+		p.err = err
+		p.closeFiles()
+		return err
+	}
+	if p.fEnable, err = fileIOOpen(p.root+"enable", os.O_RDWR); err != nil {
+		p.err = err
+		p.closeFiles()
+		return err
+	}
+	// Read the current state.
+	v, err := readInt(p.root + "period")
+	if err == nil {
+		p.period = int64(v)
+		if v, err = readInt(p.root + "duty_cycle"); err == nil {
+			p.duty = int64(v)
+			if v, err = readInt(p.root + "enable"); err == nil {
+				p.enabled = v != 0
+			}
+		}
+	}
+	if err != nil {
+		p.err = err
+		p.closeFiles()
+	}
+	return err
+}
+
+// close disables the channel if it was exported by open() and closes the
+// handles.
+func (p *PWM) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var err error
+	if p.exported {
+		if p.enabled {
+			err = seekWrite(p.fEnable, []byte("0"))
+		}
+		p.closeFiles()
+		chip := filepath.Dir(filepath.Clean(p.root)) + "/"
+		if err2 := writeFile(chip+"unexport", strconv.Itoa(p.channel)); err == nil {
+			err = err2
+		}
+		p.exported = false
+	} else {
+		p.closeFiles()
+	}
+	p.err = nil
+	p.enabled = false
+	return p.wrap(err)
+}
+
+func (p *PWM) closeFiles() {
+	for _, f := range []*fileIO{&p.fPeriod, &p.fDuty, &p.fEnable} {
+		if *f != nil {
+			_ = (*f).Close()
+			*f = nil
+		}
+	}
+}
+
+func (p *PWM) setPeriod(v int64) error {
+	if v == p.period {
+		return nil
+	}
+	if err := seekWrite(p.fPeriod, []byte(strconv.FormatInt(v, 10))); err != nil {
+		return err
+	}
+	p.period = v
+	return nil
+}
+
+func (p *PWM) setDuty(v int64) error {
+	if v == p.duty {
+		return nil
+	}
+	if err := seekWrite(p.fDuty, []byte(strconv.FormatInt(v, 10))); err != nil {
+		return err
+	}
+	p.duty = v
+	return nil
+}
+
+func (p *PWM) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("sysfs-pwm (%s): %v", p, err)
+}
+
+// writeFile writes a sysfs pseudo-file.
+func writeFile(path, v string) error {
+	f, err := fileIOOpen(path, os.O_WRONLY)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write([]byte(v))
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// driverPWM implements periph.Driver.
+type driverPWM struct {
+}
+
+func (d *driverPWM) String() string {
+	return "sysfs-pwm"
+}
+
+func (d *driverPWM) Prerequisites() []string {
+	return nil
+}
+
+func (d *driverPWM) After() []string {
+	return nil
+}
+
+// Init initializes the PWM sysfs handling code.
+//
+// Uses pwm sysfs as described at
+// https://www.kernel.org/doc/Documentation/pwm.txt
+func (d *driverPWM) Init() (bool, error) {
+	items, err := filepath.Glob(pwmRoot + "pwmchip*")
+	if err != nil {
+		return true, err
+	}
+	if len(items) == 0 {
+		return false, errors.New("no PWM found")
+	}
+	// Make sure they are registered in numerical order.
+	var chips []int
+	for _, item := range items {
+		n, err := strconv.Atoi(filepath.Base(item)[len("pwmchip"):])
+		if err != nil {
+			continue
+		}
+		chips = append(chips, n)
+	}
+	sort.Ints(chips)
+	pwmByPin = map[string]*PWM{}
+	for _, c := range chips {
+		chip := fmt.Sprintf("%spwmchip%d", pwmRoot, c)
+		npwm, err := readInt(chip + "/npwm")
+		if err != nil {
+			return true, err
+		}
+		pins := pwmPins(chip)
+		for i := 0; i < npwm; i++ {
+			p := &PWM{
+				number:  len(PWMs),
+				name:    fmt.Sprintf("pwmchip%d/pwm%d", c, i),
+				channel: i,
+				root:    fmt.Sprintf("%s/pwm%d/", chip, i),
+			}
+			if pin, ok := pins[i]; ok {
+				p.pin = pin
+				pwmByPin[p.pin] = p
+			}
+			PWMs = append(PWMs, p)
+		}
+	}
+	return true, nil
+}
+
+// Close disables and unexports the channels that were exported, and forgets
+// about the channels found by Init().
+func (d *driverPWM) Close() error {
+	var err error
+	for _, p := range PWMs {
+		if err2 := p.close(); err == nil {
+			err = err2
+		}
+	}
+	PWMs = nil
+	pwmByPin = nil
+	return err
+}
+
+func init() {
+	if isLinux {
+		periph.MustRegister(&drvPWM)
+	}
+}
+
+var drvPWM driverPWM
+
+var _ conn.Resource = &PWM{}
+var _ gpio.PinOut = &PWM{}
+var _ pin.Pin = &PWM{}
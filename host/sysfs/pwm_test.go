@@ -0,0 +1,302 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/host/fs"
+)
+
+func TestPWMDriver(t *testing.T) {
+	root := fakePWMTree(t)
+	defer os.RemoveAll(root)
+	defer reset()
+	d := driverPWM{}
+	if d.String() != "sysfs-pwm" || d.Prerequisites() != nil || d.After() != nil {
+		t.Fatal("unexpected driver")
+	}
+	if ok, err := d.Init(); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	defer d.Close()
+	if len(PWMs) != 3 {
+		t.Fatal(PWMs)
+	}
+	// Sorted numerically.
+	for i, name := range []string{"pwmchip2/pwm0", "pwmchip2/pwm1", "pwmchip10/pwm0"} {
+		if PWMs[i].Name() != name || PWMs[i].Number() != i {
+			t.Fatal(PWMs[i])
+		}
+	}
+	if p, err := PWMByName("pwmchip2/pwm1"); err != nil || p != PWMs[1] || p.Pin() != "GPIO19" {
+		t.Fatal(p, err)
+	}
+	if _, err := PWMByName("inexistent"); err == nil {
+		t.Fatal("expected error")
+	}
+	if PWMByPin("GPIO18") != PWMs[0] || PWMByPin("GPIO12") != nil {
+		t.Fatal("unexpected pin mapping")
+	}
+	if s := PWMs[0].String(); s != "pwmchip2/pwm0(0)" {
+		t.Fatal(s)
+	}
+}
+
+func TestPWMDriver_none(t *testing.T) {
+	defer reset()
+	root, err := ioutil.TempDir("", "periph_sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	pwmRoot = root + "/"
+	if ok, err := (&driverPWM{}).Init(); ok || err == nil {
+		t.Fatal("must skip")
+	}
+}
+
+func TestPWM_PWM(t *testing.T) {
+	root := fakePWMTree(t)
+	defer os.RemoveAll(root)
+	defer reset()
+	d := driverPWM{}
+	if _, err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+	p := PWMs[0]
+	if f := p.Function(); f != "PWM/Off" {
+		t.Fatal(f)
+	}
+	if err := p.PWM(gpio.DutyHalf, 50*physic.Hertz); err != nil {
+		t.Fatal(err)
+	}
+	dir := root + "/pwmchip2/pwm0/"
+	if !p.exported || readFake(t, root+"/pwmchip2/export") != "0" {
+		t.Fatal("channel must have been exported")
+	}
+	if v := readFake(t, dir+"period"); v != "20000000" {
+		t.Fatal(v)
+	}
+	if v := readFake(t, dir+"duty_cycle"); v != "10000000" {
+		t.Fatal(v)
+	}
+	if v := readFake(t, dir+"enable"); v != "1" {
+		t.Fatal(v)
+	}
+	if f := p.Function(); f != "PWM/50%" {
+		t.Fatal(f)
+	}
+	// Shorter period; the duty cycle must be written first.
+	if err := p.PWM(gpio.DutyMax/4, 1000*physic.Hertz); err != nil {
+		t.Fatal(err)
+	}
+	if v := readFake(t, dir+"period"); v != "1000000" {
+		t.Fatal(v)
+	}
+	if v := readFake(t, dir+"duty_cycle"); v != "250000" {
+		t.Fatal(v)
+	}
+	// The period is kept.
+	if err := p.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if v := readFake(t, dir+"duty_cycle"); v != "1000000" {
+		t.Fatal(v)
+	}
+	if err := p.SetPolarity(true); err != nil {
+		t.Fatal(err)
+	}
+	if v := readFake(t, dir+"polarity"); v != "inversed" {
+		t.Fatal(v)
+	}
+	if v := readFake(t, dir+"enable"); v != "1" {
+		t.Fatal(v)
+	}
+	if err := p.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if v := readFake(t, dir+"enable"); v != "0" {
+		t.Fatal(v)
+	}
+	// Errors.
+	if p.PWM(-1, 0) == nil {
+		t.Fatal("invalid duty")
+	}
+	if p.PWM(0, -1) == nil {
+		t.Fatal("invalid frequency")
+	}
+	if p.PWM(0, 10*physic.GigaHertz) == nil {
+		t.Fatal("frequency too high")
+	}
+	if err := p.PWM(gpio.DutyHalf, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if readFake(t, root+"/pwmchip2/unexport") != "0" {
+		t.Fatal("channel must have been unexported")
+	}
+	if v := readFake(t, dir+"enable"); v != "0" {
+		t.Fatal(v)
+	}
+	if PWMs != nil || PWMByPin("GPIO18") != nil {
+		t.Fatal("must have been forgotten")
+	}
+}
+
+func TestPWM_Out_default(t *testing.T) {
+	root := fakePWMTree(t)
+	defer os.RemoveAll(root)
+	defer reset()
+	d := driverPWM{}
+	if _, err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	// Already exported.
+	p := PWMs[2]
+	if err := p.Out(gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	if p.exported {
+		t.Fatal("was already exported")
+	}
+	dir := root + "/pwmchip10/pwm0/"
+	if v := readFake(t, dir+"period"); v != "1000000" {
+		t.Fatal(v)
+	}
+	if v := readFake(t, dir+"duty_cycle"); v != "0" {
+		t.Fatal(v)
+	}
+}
+
+func TestPin_PWM_sysfsPWM(t *testing.T) {
+	root := fakePWMTree(t)
+	defer os.RemoveAll(root)
+	defer reset()
+	d := driverPWM{}
+	if _, err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	p := Pin{number: 18, name: "GPIO18", root: "/tmp/gpio/priv/"}
+	if err := p.PWM(gpio.DutyHalf, physic.KiloHertz); err != nil {
+		t.Fatal(err)
+	}
+	if v := readFake(t, root+"/pwmchip2/pwm0/duty_cycle"); v != "500000" {
+		t.Fatal(v)
+	}
+	p = Pin{number: 12, name: "GPIO12", root: "/tmp/gpio/priv/"}
+	if p.PWM(gpio.DutyHalf, physic.KiloHertz) == nil {
+		t.Fatal("pin has no PWM")
+	}
+}
+
+func TestPWMChannel(t *testing.T) {
+	data := []struct {
+		pin, f string
+		ch     int
+	}{
+		{"GPIO18", "alt5", 0},
+		// A single pin overlay on the second channel.
+		{"GPIO19", "alt5", 1},
+		{"GPIO45", "alt0", 1},
+		{"GPIO18", "alt0", -1},
+		{"PB2", "pwm", 0},
+		{"PH9", "pwm1", 1},
+		{"PH9", "pwmfoo", -1},
+		{"PA0", "uart2", -1},
+	}
+	for i, line := range data {
+		if ch := pwmChannel(line.pin, line.f); ch != line.ch {
+			t.Fatalf("#%d: pwmChannel(%q, %q) = %d; expected %d", i, line.pin, line.f, ch, line.ch)
+		}
+	}
+}
+
+//
+
+// fakePWMTree creates a fake /sys/class/pwm with two chips; pwmchip10/pwm0 is
+// already exported.
+func fakePWMTree(t *testing.T) string {
+	root := fakeTree(t, map[string]string{
+		"pwmchip2/npwm":             "2\n",
+		"pwmchip2/export":           "",
+		"pwmchip2/unexport":         "",
+		"pwmchip10/npwm":            "1\n",
+		"pwmchip10/export":          "",
+		"pwmchip10/unexport":        "",
+		"pwmchip10/pwm0/period":     "0\n",
+		"pwmchip10/pwm0/duty_cycle": "0\n",
+		"pwmchip10/pwm0/enable":     "0\n",
+		"pwmchip10/pwm0/polarity":   "normal\n",
+	})
+	pwmRoot = root + "/"
+	pwmPins = func(chip string) map[int]string {
+		if strings.HasSuffix(chip, "/pwmchip2") {
+			return map[int]string{0: "GPIO18", 1: "GPIO19"}
+		}
+		return nil
+	}
+	open := fileIOOpen
+	fileIOOpen = func(path string, flag int) (fileIO, error) {
+		if strings.HasSuffix(path, "/export") {
+			// Emulate the kernel creating the channel.
+			return &fakeExportFile{root: filepath.Dir(path)}, nil
+		}
+		return open(path, flag)
+	}
+	return root
+}
+
+// fakeSysfsFile replaces the content on each write, like a sysfs attribute.
+type fakeSysfsFile struct {
+	fs.File
+}
+
+func (f *fakeSysfsFile) Write(b []byte) (int, error) {
+	if err := f.File.Truncate(0); err != nil {
+		return 0, err
+	}
+	return f.File.WriteAt(append(b, '\n'), 0)
+}
+
+// fakeExportFile creates the channel directory when written to.
+type fakeExportFile struct {
+	file
+	root string
+}
+
+func (f *fakeExportFile) Write(b []byte) (int, error) {
+	dir := filepath.Join(f.root, "pwm"+string(b))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return 0, err
+	}
+	for name, content := range map[string]string{"period": "0\n", "duty_cycle": "0\n", "enable": "0\n", "polarity": "normal\n"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			return 0, err
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(f.root, "export"), b, 0600); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func readFake(t *testing.T, path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(b))
+}
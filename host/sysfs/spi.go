@@ -173,7 +173,7 @@ func newSPI(busNumber, chipSelect int) (*SPI, error) {
 		return nil, fmt.Errorf("sysfs-spi: invalid chip select %d", chipSelect)
 	}
 	// Use the devfs path for now.
-	f, err := ioctlOpen(fmt.Sprintf("/dev/spidev%d.%d", busNumber, chipSelect), os.O_RDWR)
+	f, err := ioctlOpen(spiPath(busNumber, chipSelect), os.O_RDWR)
 	if err != nil {
 		return nil, fmt.Errorf("sysfs-spi: %v", err)
 	}
@@ -235,7 +235,7 @@ func (s *spiConn) Read(b []byte) (int, error) {
 	s.p[0].W = nil
 	s.p[0].R = b
 	if err := s.txPackets(s.p[:1]); err != nil {
-		return 0, fmt.Errorf("sysfs-spi: Read() failed: %v", err)
+		return 0, s.wrap("Read", err)
 	}
 	return len(b), nil
 }
@@ -253,7 +253,7 @@ func (s *spiConn) Write(b []byte) (int, error) {
 	s.p[0].W = b
 	s.p[0].R = nil
 	if err := s.txPackets(s.p[:1]); err != nil {
-		return 0, fmt.Errorf("sysfs-spi: Write() failed: %v", err)
+		return 0, s.wrap("Write", err)
 	}
 	return len(b), nil
 }
@@ -293,7 +293,7 @@ func (s *spiConn) Tx(w, r []byte) error {
 		p = s.p[:2]
 	}
 	if err := s.txPackets(p); err != nil {
-		return fmt.Errorf("sysfs-spi: Tx() failed: %v", err)
+		return s.wrap("Tx", err)
 	}
 	return nil
 }
@@ -334,7 +334,7 @@ func (s *spiConn) TxPackets(p []spi.Packet) error {
 		}
 	}
 	if err := s.txPackets(p); err != nil {
-		return fmt.Errorf("sysfs-spi: TxPackets() failed: %v", err)
+		return s.wrap("TxPackets", err)
 	}
 	return nil
 }
@@ -403,6 +403,19 @@ func (s *spiConn) txPackets(p []spi.Packet) error {
 	return s.f.Ioctl(spiIOCTx(len(m)), uintptr(unsafe.Pointer(&m[0])))
 }
 
+// wrap returns a *conn.RemovedError if the port was removed, otherwise err
+// prefixed with the driver name and op.
+func (s *spiConn) wrap(op string, err error) error {
+	if r := removedError(spiPath(s.busNumber, s.chipSelect), err); r != nil {
+		return r
+	}
+	return fmt.Errorf("sysfs-spi: %s() failed: %v", op, err)
+}
+
+func spiPath(busNumber, chipSelect int) string {
+	return fmt.Sprintf("%s/spidev%d.%d", devDir, busNumber, chipSelect)
+}
+
 func (s *spiConn) setFlag(op uint, arg uint64) error {
 	if err := s.f.Ioctl(op|0x40000000, uintptr(unsafe.Pointer(&arg))); err != nil {
 		return err
@@ -541,6 +554,10 @@ func (s *spiIOCTransfer) reset(w, r []byte, f physic.Frequency, bitsPerWord uint
 type driverSPI struct {
 	// bufSize is the maximum number of bytes allowed per I/O on the SPI port.
 	bufSize int
+
+	// Protected by scanMu.
+	initialized bool // Set by Init() even if nothing was found, cleared by Close(); see Rescan()
+	ports       []string
 }
 
 func (d *driverSPI) String() string {
@@ -559,17 +576,57 @@ func (d *driverSPI) Init() (bool, error) {
 	// This driver is only registered on linux, so there is no legitimate time to
 	// skip it.
 
-	// Do not use "/sys/bus/spi/devices/spi" as Raspbian's provided udev rules
-	// only modify the ACL of /dev/spidev* but not the ones in /sys/bus/...
-	prefix := "/dev/spidev"
-	items, err := filepath.Glob(prefix + "*")
+	scanMu.Lock()
+	d.initialized = true
+	n, err := d.scan()
+	scanMu.Unlock()
 	if err != nil {
 		return true, err
 	}
-	if len(items) == 0 {
+	if n == 0 {
 		return false, errors.New("no SPI port found")
 	}
+	f, err := fs.Open("/sys/module/spidev/parameters/bufsiz", os.O_RDONLY)
+	if err != nil {
+		return true, err
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return true, err
+	}
+	// Update the global value.
+	drvSPI.bufSize, err = strconv.Atoi(strings.TrimSpace(string(b)))
+	return true, err
+}
+
+// Close unregisters the SPI ports.
+func (d *driverSPI) Close() error {
+	scanMu.Lock()
+	defer scanMu.Unlock()
+	d.initialized = false
+	for _, name := range d.ports {
+		_ = spireg.Unregister(name)
+	}
+	d.ports = nil
+	return nil
+}
+
+// scan registers the SPI ports that appeared and unregisters the ones that
+// disappeared since the last scan.
+//
+// It returns the number of ports found. scanMu must be held.
+func (d *driverSPI) scan() (int, error) {
+	// Do not use "/sys/bus/spi/devices/spi" as Raspbian's provided udev rules
+	// only modify the ACL of /dev/spidev* but not the ones in /sys/bus/...
+	prefix := devDir + "/spidev"
+	items, err := filepath.Glob(prefix + "*")
+	if err != nil {
+		return 0, err
+	}
 	sort.Strings(items)
+	var ports []openerSPI
+	found := map[string]struct{}{}
 	for _, item := range items {
 		parts := strings.Split(item[len(prefix):], ".")
 		if len(parts) != 2 {
@@ -583,28 +640,26 @@ func (d *driverSPI) Init() (bool, error) {
 		if err != nil {
 			continue
 		}
-		name := fmt.Sprintf("/dev/spidev%d.%d", bus, cs)
-		aliases := []string{fmt.Sprintf("SPI%d.%d", bus, cs)}
-		n := bus
-		if cs != 0 {
+		ports = append(ports, openerSPI{bus, cs})
+		found[spiPath(bus, cs)] = struct{}{}
+	}
+	d.ports = forget(d.ports, found, spireg.Unregister)
+	for i := range ports {
+		name := spiPath(ports[i].bus, ports[i].cs)
+		if contains(d.ports, name) {
+			continue
+		}
+		aliases := []string{fmt.Sprintf("SPI%d.%d", ports[i].bus, ports[i].cs)}
+		n := ports[i].bus
+		if ports[i].cs != 0 {
 			n = -1
 		}
-		if err := spireg.Register(name, aliases, n, (&openerSPI{bus, cs}).Open); err != nil {
-			return true, err
+		if err := spireg.Register(name, aliases, n, ports[i].Open); err != nil {
+			return len(ports), err
 		}
+		d.ports = append(d.ports, name)
 	}
-	f, err := fs.Open("/sys/module/spidev/parameters/bufsiz", os.O_RDONLY)
-	if err != nil {
-		return true, err
-	}
-	defer f.Close()
-	b, err := ioutil.ReadAll(f)
-	if err != nil {
-		return true, err
-	}
-	// Update the global value.
-	drvSPI.bufSize, err = strconv.Atoi(strings.TrimSpace(string(b)))
-	return true, err
+	return len(ports), nil
 }
 
 type openerSPI struct {
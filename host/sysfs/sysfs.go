@@ -7,6 +7,7 @@ package sysfs
 import (
 	"io"
 
+	"periph.io/x/periph/conn"
 	"periph.io/x/periph/host/fs"
 )
 
@@ -22,6 +23,18 @@ func ioctlOpenDefault(path string, flag int) (ioctlCloser, error) {
 
 var fileIOOpen = fileIOOpenDefault
 
+// devDir is where the device nodes are found. It is overridden in unit tests.
+var devDir = "/dev"
+
+// removedError returns a *conn.RemovedError if err was caused by the removal
+// of the device at path, nil otherwise.
+func removedError(path string, err error) error {
+	if isErrRemoved(path, err) {
+		return &conn.RemovedError{Name: path}
+	}
+	return nil
+}
+
 func fileIOOpenDefault(path string, flag int) (fileIO, error) {
 	f, err := fs.Open(path, flag)
 	if err != nil {
@@ -7,6 +7,8 @@ package sysfs
 import (
 	"os"
 	"syscall"
+	"time"
+	"unsafe"
 )
 
 const isLinux = true
@@ -15,3 +17,96 @@ func isErrBusy(err error) bool {
 	e, ok := err.(*os.PathError)
 	return ok && e.Err == syscall.EBUSY
 }
+
+// isErrRemoved returns true if err is one of the errors returned by the
+// kernel when using the device at path after it was removed.
+func isErrRemoved(path string, err error) bool {
+	if e, ok := err.(*os.PathError); ok {
+		err = e.Err
+	}
+	switch err {
+	case syscall.ENODEV, syscall.ENXIO, syscall.ESHUTDOWN:
+		return true
+	case syscall.EIO:
+		// A tty returns EIO once hung up; check if the device node is gone.
+		_, err := os.Stat(path)
+		return os.IsNotExist(err)
+	default:
+		return false
+	}
+}
+
+// inotifyOpen returns a non-blocking inotify file descriptor watching for
+// files being created or deleted in dir.
+func inotifyOpen(dir string) (int, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return 0, err
+	}
+	const mask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+	if _, err := syscall.InotifyAddWatch(fd, dir, mask); err != nil {
+		_ = syscall.Close(fd)
+		return 0, err
+	}
+	return fd, nil
+}
+
+// inotifyRead returns the file names of the pending inotify events.
+func inotifyRead(fd int) ([]string, error) {
+	var buf [4096]byte
+	n, err := syscall.Read(fd, buf[:])
+	if err != nil {
+		if err == syscall.EAGAIN {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for i := 0; i+syscall.SizeofInotifyEvent <= n; {
+		e := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[i]))
+		name := buf[i+syscall.SizeofInotifyEvent : i+syscall.SizeofInotifyEvent+int(e.Len)]
+		// The name is padded with NULs.
+		for j, c := range name {
+			if c == 0 {
+				name = name[:j]
+				break
+			}
+		}
+		names = append(names, string(name))
+		i += syscall.SizeofInotifyEvent + int(e.Len)
+	}
+	return names, nil
+}
+
+func inotifyClose(fd int) error {
+	return syscall.Close(fd)
+}
+
+// monotonicNowDefault returns the current time of CLOCK_MONOTONIC, which is
+// the clock used by the kernel to timestamp the GPIO line events.
+func monotonicNowDefault() time.Duration {
+	var ts syscall.Timespec
+	if _, _, errno := syscall.Syscall(syscall.SYS_CLOCK_GETTIME, clockMonotonic, uintptr(unsafe.Pointer(&ts)), 0); errno != 0 {
+		return 0
+	}
+	return time.Duration(ts.Nano())
+}
+
+// clockMonotonic is CLOCK_MONOTONIC from <linux/time.h>.
+const clockMonotonic = 1
+
+// ioctlRaw issues the IOCTL op on fd as is.
+//
+// Contrary to fs.File.Ioctl(), op is not translated on MIPS, so it can be used
+// with the control codes that do not use the _IOC encoding.
+func ioctlRaw(fd uintptr, op uint, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(op), arg); errno != 0 {
+		return syscall.Errno(errno)
+	}
+	return nil
+}
+
+// setBlocking clears O_NONBLOCK on fd.
+func setBlocking(fd uintptr) error {
+	return syscall.SetNonblock(int(fd), false)
+}
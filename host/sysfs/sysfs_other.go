@@ -6,9 +6,44 @@
 
 package sysfs
 
+import (
+	"errors"
+	"time"
+)
+
 const isLinux = false
 
 func isErrBusy(err error) bool {
 	// This function is not used on non-linux.
 	return false
 }
+
+func isErrRemoved(path string, err error) bool {
+	// This function is not used on non-linux.
+	return false
+}
+
+func inotifyOpen(dir string) (int, error) {
+	return 0, errors.New("sysfs: inotify is not supported on this OS")
+}
+
+func inotifyRead(fd int) ([]string, error) {
+	return nil, errors.New("sysfs: inotify is not supported on this OS")
+}
+
+func inotifyClose(fd int) error {
+	return errors.New("sysfs: inotify is not supported on this OS")
+}
+
+func monotonicNowDefault() time.Duration {
+	// This function is not used on non-linux.
+	return 0
+}
+
+func ioctlRaw(fd uintptr, op uint, arg uintptr) error {
+	return errors.New("sysfs: ioctl is not supported on this OS")
+}
+
+func setBlocking(fd uintptr) error {
+	return errors.New("sysfs: setBlocking is not supported on this OS")
+}
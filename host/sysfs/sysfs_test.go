@@ -18,6 +18,17 @@ func init() {
 func reset() {
 	fileIOOpen = fileIOOpenDefault
 	ioctlOpen = ioctlOpenDefault
+	gpioChipOpen = gpioChipOpenDefault
+	gpioLineOpen = gpioLineOpenDefault
+	gpioSysfsRoot = "/sys"
+	monotonicNow = monotonicNowDefault
+	uartOpen = uartOpenDefault
+	devDir = "/dev"
+	pwmRoot = "/sys/class/pwm/"
+	pwmPins = pwmPinsDefault
+	drvI2C.initialized = false
+	drvSPI.initialized = false
+	drvUART.initialized = false
 	// Soon.
 	//fileIOOpen = fileIOOpenPanic
 	//ioctlOpen = ioctlOpenPanic
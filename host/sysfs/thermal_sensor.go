@@ -11,12 +11,14 @@ import (
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"periph.io/x/periph"
 	"periph.io/x/periph/conn"
 	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/physic/sampler"
 )
 
 // ThermalSensors is all the sensors discovered on this host via sysfs.
@@ -37,6 +39,40 @@ func ThermalSensorByName(name string) (*ThermalSensor, error) {
 	return nil, errors.New("sysfs-thermal: invalid sensor name")
 }
 
+// CoolingDevices is all the cooling devices discovered on this host via sysfs.
+var CoolingDevices []*CoolingDevice
+
+// CoolingDeviceByName returns a *CoolingDevice for the device name, e.g.
+// "cooling_device0", if any.
+func CoolingDeviceByName(name string) (*CoolingDevice, error) {
+	for _, c := range CoolingDevices {
+		if c.name == name {
+			return c, nil
+		}
+	}
+	return nil, errors.New("sysfs-thermal: invalid cooling device name")
+}
+
+// ThermalTrip is a trip point of a thermal zone.
+//
+// When the temperature of the zone crosses a trip point, the kernel acts
+// according to the trip type, e.g. by throttling the CPU or by spinning a
+// fan.
+type ThermalTrip struct {
+	// Type is the trip type as exported by sysfs, e.g. "active", "passive",
+	// "hot" or "critical".
+	Type string
+	// Temperature is the temperature at which the trip point is crossed.
+	Temperature physic.Temperature
+	// Hysteresis is the temperature drop needed to deactivate the trip point.
+	// It is 0 when not exported by the driver.
+	Hysteresis physic.Temperature
+}
+
+func (t *ThermalTrip) String() string {
+	return fmt.Sprintf("%s@%s", t.Type, t.Temperature)
+}
+
 // ThermalSensor represents one thermal sensor on the system.
 type ThermalSensor struct {
 	name string
@@ -46,14 +82,18 @@ type ThermalSensor struct {
 	nameType  string
 	f         fileIO
 	precision physic.Temperature
+	c         sampler.Continuous
 }
 
 func (t *ThermalSensor) String() string {
 	return t.name
 }
 
-// Halt implements conn.Resource. It is a noop.
+// Halt implements conn.Resource.
+//
+// It stops the continuous sensing initiated by SenseContinuous().
 func (t *ThermalSensor) Halt() error {
+	t.c.Stop()
 	return nil
 }
 
@@ -110,10 +150,46 @@ func (t *ThermalSensor) Sense(e *physic.Env) error {
 	return nil
 }
 
+// Trips returns the trip points of the thermal zone, in the order exported by
+// sysfs.
+//
+// Most zones have at least a critical trip point.
+func (t *ThermalSensor) Trips() ([]ThermalTrip, error) {
+	items, err := filepath.Glob(t.root + "trip_point_*_temp")
+	if err != nil {
+		return nil, fmt.Errorf("sysfs-thermal: %v", err)
+	}
+	var indexes []int
+	for _, item := range items {
+		s := strings.TrimSuffix(filepath.Base(item), "_temp")
+		if i, err := strconv.Atoi(s[len("trip_point_"):]); err == nil {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Ints(indexes)
+	out := make([]ThermalTrip, 0, len(indexes))
+	for _, i := range indexes {
+		base := fmt.Sprintf("%strip_point_%d_", t.root, i)
+		v, err := readInt(base + "temp")
+		if err != nil {
+			return nil, fmt.Errorf("sysfs-thermal: %v", err)
+		}
+		trip := ThermalTrip{Temperature: physic.Temperature(v)*physic.MilliKelvin + physic.ZeroCelsius}
+		if trip.Type, err = readString(base + "type"); err != nil {
+			return nil, fmt.Errorf("sysfs-thermal: %v", err)
+		}
+		// The hysteresis is optional.
+		if h, err := readInt(base + "hyst"); err == nil {
+			trip.Hysteresis = physic.Temperature(h) * physic.MilliKelvin
+		}
+		out = append(out, trip)
+	}
+	return out, nil
+}
+
 // SenseContinuous implements physic.SenseEnv.
 func (t *ThermalSensor) SenseContinuous(interval time.Duration) (<-chan physic.Env, error) {
-	// TODO(maruel): Manually poll in a loop via time.NewTicker.
-	return nil, errors.New("sysfs-thermal: not implemented")
+	return t.c.Start(t, interval)
 }
 
 // Precision implements physic.SenseEnv.
@@ -128,7 +204,119 @@ func (t *ThermalSensor) Precision(e *physic.Env) {
 	e.Temperature = t.precision
 }
 
+// CoolingDevice represents one cooling device on the system, e.g. a fan or
+// the CPU frequency throttling.
+//
+// A cooling device exposes a state between 0, which means no cooling, and
+// MaxState().
+type CoolingDevice struct {
+	name string
+	root string
+}
+
+func (c *CoolingDevice) String() string {
+	return c.name
+}
+
+// Halt implements conn.Resource. It is a noop.
+func (c *CoolingDevice) Halt() error {
+	return nil
+}
+
+// Type returns the type of cooling device as exported by sysfs, e.g. "Fan"
+// or "cpufreq-cpu0".
+func (c *CoolingDevice) Type() (string, error) {
+	s, err := readString(c.root + "type")
+	if err != nil {
+		return "", fmt.Errorf("sysfs-thermal: %v", err)
+	}
+	return s, nil
+}
+
+// MaxState returns the maximum cooling state supported by the device.
+func (c *CoolingDevice) MaxState() (int, error) {
+	v, err := readInt(c.root + "max_state")
+	if err != nil {
+		return 0, fmt.Errorf("sysfs-thermal: %v", err)
+	}
+	return v, nil
+}
+
+// State returns the current cooling state.
+func (c *CoolingDevice) State() (int, error) {
+	v, err := readInt(c.root + "cur_state")
+	if err != nil {
+		return 0, fmt.Errorf("sysfs-thermal: %v", err)
+	}
+	return v, nil
+}
+
+// SetState sets the cooling state, between 0 and MaxState().
 //
+// The kernel may override it at the next thermal zone evaluation, unless the
+// governor of the zone is "user_space".
+func (c *CoolingDevice) SetState(s int) error {
+	max, err := c.MaxState()
+	if err != nil {
+		return err
+	}
+	if s < 0 || s > max {
+		return fmt.Errorf("sysfs-thermal: state %d is out of range [0, %d]", s, max)
+	}
+	if err := writeFile(c.root+"cur_state", strconv.Itoa(s)); err != nil {
+		return fmt.Errorf("sysfs-thermal: %v", err)
+	}
+	return nil
+}
+
+//
+
+// thermalRoot is where the thermal zones and cooling devices are found. It is
+// overridden in unit tests.
+var thermalRoot = "/sys/class/thermal/"
+
+// readString reads a sysfs pseudo-file containing a single line.
+func readString(path string) (string, error) {
+	f, err := fileIOOpen(path, os.O_RDONLY)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var buf [256]byte
+	n, err := f.Read(buf[:])
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(buf[:n])), nil
+}
+
+// sortNumerically sorts directories named prefix followed by a number, e.g.
+// "cooling_device10" sorts after "cooling_device2".
+func sortNumerically(dirs []string, prefix string) {
+	sort.Sort(&byNumber{dirs, prefix})
+}
+
+type byNumber struct {
+	dirs   []string
+	prefix string
+}
+
+func (b *byNumber) Len() int {
+	return len(b.dirs)
+}
+
+func (b *byNumber) Less(i, j int) bool {
+	return b.number(i) < b.number(j)
+}
+
+func (b *byNumber) Swap(i, j int) {
+	b.dirs[i], b.dirs[j] = b.dirs[j], b.dirs[i]
+}
+
+func (b *byNumber) number(i int) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(filepath.Base(b.dirs[i]), b.prefix))
+	return n
+}
 
 func (t *ThermalSensor) open() error {
 	t.mu.Lock()
@@ -169,11 +357,15 @@ func (d *driverThermalSensor) After() []string {
 func (d *driverThermalSensor) Init() (bool, error) {
 	// This driver is only registered on linux, so there is no legitimate time to
 	// skip it.
-	items, err := filepath.Glob("/sys/class/thermal/*/temp")
+	items, err := filepath.Glob(thermalRoot + "*/temp")
+	if err != nil {
+		return true, err
+	}
+	devices, err := filepath.Glob(thermalRoot + "cooling_device*/cur_state")
 	if err != nil {
 		return true, err
 	}
-	if len(items) == 0 {
+	if len(items) == 0 && len(devices) == 0 {
 		return false, errors.New("sysfs-thermal: no sensor found")
 	}
 	sort.Strings(items)
@@ -184,9 +376,30 @@ func (d *driverThermalSensor) Init() (bool, error) {
 			root: base + "/",
 		})
 	}
+	for i := range devices {
+		devices[i] = filepath.Dir(devices[i])
+	}
+	sortNumerically(devices, "cooling_device")
+	for _, base := range devices {
+		CoolingDevices = append(CoolingDevices, &CoolingDevice{
+			name: filepath.Base(base),
+			root: base + "/",
+		})
+	}
 	return true, nil
 }
 
+// Close stops the continuous sensing and forgets about the sensors and
+// cooling devices found by Init().
+func (d *driverThermalSensor) Close() error {
+	for _, t := range ThermalSensors {
+		t.c.Stop()
+	}
+	ThermalSensors = nil
+	CoolingDevices = nil
+	return nil
+}
+
 func init() {
 	if isLinux {
 		periph.MustRegister(&drvThermalSensor)
@@ -197,3 +410,5 @@ var drvThermalSensor driverThermalSensor
 
 var _ conn.Resource = &ThermalSensor{}
 var _ physic.SenseEnv = &ThermalSensor{}
+var _ conn.Resource = &CoolingDevice{}
+var _ fmt.Stringer = &ThermalTrip{}
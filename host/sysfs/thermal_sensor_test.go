@@ -6,12 +6,15 @@ package sysfs
 
 import (
 	"errors"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/host/fs"
 )
 
 func TestThermalSensorByName_not_present(t *testing.T) {
@@ -56,9 +59,47 @@ func TestThermalSensor_fail(t *testing.T) {
 	if err := d.Sense(&e); err == nil || err.Error() != "sysfs-thermal: file I/O is inhibited" {
 		t.Fatal("should have failed")
 	}
-	if _, err := d.SenseContinuous(time.Second); err == nil || err.Error() != "sysfs-thermal: not implemented" {
+	// The failed reads are skipped.
+	c, err := d.SenseContinuous(time.Millisecond)
+	if err != nil {
 		t.Fatal(err)
 	}
+	select {
+	case <-c:
+		t.Fatal("should have failed")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if err := d.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-c; ok {
+		t.Fatal("channel must be closed")
+	}
+}
+
+func TestThermalSensor_SenseContinuous(t *testing.T) {
+	root := fakeThermalTree(t)
+	defer os.RemoveAll(root)
+	defer resetThermal()
+	d := ThermalSensor{name: "thermal_zone0", root: root + "/thermal_zone0/"}
+	c, err := d.SenseContinuous(time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if e := <-c; e.Temperature != 45*physic.Celsius+physic.ZeroCelsius {
+			t.Fatal(e.Temperature)
+		}
+	}
+	if err := d.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-c; ok {
+		t.Fatal("channel must be closed")
+	}
+	if _, err := d.SenseContinuous(0); err == nil {
+		t.Fatal("invalid interval")
+	}
 }
 
 func TestThermalSensor_Type_success(t *testing.T) {
@@ -272,13 +313,184 @@ func TestThermalSensorDriver(t *testing.T) {
 	_, _ = d.Init()
 }
 
+func TestThermalSensor_Trips(t *testing.T) {
+	root := fakeThermalTree(t)
+	defer os.RemoveAll(root)
+	defer resetThermal()
+	d := ThermalSensor{name: "thermal_zone0", root: root + "/thermal_zone0/"}
+	trips, err := d.Trips()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trips) != 3 {
+		t.Fatal(trips)
+	}
+	exp := []ThermalTrip{
+		{Type: "active", Temperature: 60*physic.Celsius + physic.ZeroCelsius, Hysteresis: 2 * physic.Celsius},
+		{Type: "passive", Temperature: 80*physic.Celsius + physic.ZeroCelsius},
+		{Type: "critical", Temperature: 105*physic.Celsius + physic.ZeroCelsius},
+	}
+	for i := range exp {
+		if trips[i] != exp[i] {
+			t.Fatal(i, trips[i])
+		}
+	}
+	if s := trips[2].String(); s != "critical@105°C" {
+		t.Fatal(s)
+	}
+	d = ThermalSensor{name: "thermal_zone1", root: root + "/thermal_zone1/"}
+	if trips, err := d.Trips(); err != nil || len(trips) != 0 {
+		t.Fatal(trips, err)
+	}
+	d = ThermalSensor{name: "thermal_zone2", root: root + "/thermal_zone2/"}
+	if _, err := d.Trips(); err == nil {
+		t.Fatal("missing type")
+	}
+}
+
+func TestCoolingDevice(t *testing.T) {
+	root := fakeThermalTree(t)
+	defer os.RemoveAll(root)
+	defer resetThermal()
+	d := driverThermalSensor{}
+	if ok, err := d.Init(); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	if len(ThermalSensors) != 3 {
+		t.Fatal(ThermalSensors)
+	}
+	// Sorted numerically.
+	if len(CoolingDevices) != 2 || CoolingDevices[0].String() != "cooling_device2" || CoolingDevices[1].String() != "cooling_device10" {
+		t.Fatal(CoolingDevices)
+	}
+	c, err := CoolingDeviceByName("cooling_device2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CoolingDeviceByName("cooling_device0"); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := c.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := c.Type(); s != "Fan" || err != nil {
+		t.Fatal(s, err)
+	}
+	if v, err := c.MaxState(); v != 3 || err != nil {
+		t.Fatal(v, err)
+	}
+	if v, err := c.State(); v != 0 || err != nil {
+		t.Fatal(v, err)
+	}
+	if err := c.SetState(2); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.State(); v != 2 || err != nil {
+		t.Fatal(v, err)
+	}
+	if c.SetState(4) == nil || c.SetState(-1) == nil {
+		t.Fatal("out of range")
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ThermalSensors != nil || CoolingDevices != nil {
+		t.Fatal("must have been forgotten")
+	}
+}
+
+func TestCoolingDevice_fail(t *testing.T) {
+	defer resetThermal()
+	c := CoolingDevice{name: "cooling_device0", root: "//\000/"}
+	if _, err := c.Type(); err == nil || err.Error() != "sysfs-thermal: file I/O is inhibited" {
+		t.Fatal(err)
+	}
+	if _, err := c.MaxState(); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := c.State(); err == nil {
+		t.Fatal("expected error")
+	}
+	if c.SetState(0) == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestThermalSensorDriver_none(t *testing.T) {
+	defer resetThermal()
+	root, err := ioutil.TempDir("", "periph_sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	thermalRoot = root + "/"
+	if ok, err := (&driverThermalSensor{}).Init(); ok || err == nil {
+		t.Fatal("must skip")
+	}
+}
+
 //
 
 func resetThermal() {
 	ThermalSensors = nil
+	CoolingDevices = nil
+	thermalRoot = "/sys/class/thermal/"
 	reset()
 }
 
+// fakeThermalTree creates a fake /sys/class/thermal with three zones and two
+// cooling devices.
+func fakeThermalTree(t *testing.T) string {
+	root := fakeTree(t, map[string]string{
+		"thermal_zone0/temp":              "45000\n",
+		"thermal_zone0/trip_point_0_temp": "60000\n",
+		"thermal_zone0/trip_point_0_type": "active\n",
+		"thermal_zone0/trip_point_0_hyst": "2000\n",
+		"thermal_zone0/trip_point_1_temp": "80000\n",
+		"thermal_zone0/trip_point_1_type": "passive\n",
+		// Sorted numerically.
+		"thermal_zone0/trip_point_10_temp": "105000\n",
+		"thermal_zone0/trip_point_10_type": "critical\n",
+		"thermal_zone1/temp":               "45000\n",
+		"thermal_zone2/temp":               "45000\n",
+		"thermal_zone2/trip_point_0_temp":  "60000\n",
+		"cooling_device2/type":             "Fan\n",
+		"cooling_device2/max_state":        "3\n",
+		"cooling_device2/cur_state":        "0\n",
+		"cooling_device10/type":            "cpufreq-cpu0\n",
+		"cooling_device10/max_state":       "5\n",
+		"cooling_device10/cur_state":       "0\n",
+	})
+	thermalRoot = root + "/"
+	return root
+}
+
+// fakeTree creates a tree of sysfs pseudo-files in a temporary directory and
+// redirects fileIOOpen to it.
+func fakeTree(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "periph_sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fileIOOpen = func(path string, flag int) (fileIO, error) {
+		f, err := os.OpenFile(path, flag, 0600)
+		if err != nil {
+			return nil, err
+		}
+		return &fakeSysfsFile{File: fs.File{File: f}}, nil
+	}
+	return root
+}
+
 type fileRead struct {
 	file
 	t   *testing.T
@@ -0,0 +1,749 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"periph.io/x/periph"
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/uart"
+	"periph.io/x/periph/conn/uart/uartreg"
+	"periph.io/x/periph/host/fs"
+)
+
+// NewUART opens an UART port via its devfs interface, for example
+// "/dev/ttyS0", "/dev/ttyAMA0" or "/dev/ttyUSB0".
+//
+// The port is configured in raw mode via termios(3) upon Connect().
+//
+// The resulting object is safe for concurrent use; Read() and Write() can be
+// used concurrently.
+//
+// It is recommended to use https://periph.io/x/periph/conn/uart/uartreg#Open
+// instead of using NewUART() directly as the package sysfs is providing a
+// Linux-specific implementation. periph.io works on many OSes! This permits
+// it to work on all operating systems, or devices like UART over USB.
+func NewUART(path string) (*UART, error) {
+	if isLinux {
+		return newUART(path)
+	}
+	return nil, errors.New("sysfs-uart: not implemented on non-linux OSes")
+}
+
+// UART is an open UART port.
+type UART struct {
+	conn uartConn
+}
+
+// Close closes the handle to the UART port. It is not a requirement to close
+// before process termination.
+//
+// Note that the object is not reusable afterward.
+func (u *UART) Close() error {
+	u.conn.mu.Lock()
+	defer u.conn.mu.Unlock()
+	if u.conn.f == nil {
+		return errors.New("sysfs-uart: already closed")
+	}
+	if err := u.conn.f.Close(); err != nil {
+		return fmt.Errorf("sysfs-uart: %v", err)
+	}
+	u.conn.f = nil
+	return nil
+}
+
+func (u *UART) String() string {
+	return u.conn.String()
+}
+
+// LimitSpeed implements uart.PortCloser.
+func (u *UART) LimitSpeed(f physic.Frequency) error {
+	if err := checkUARTSpeed(f); err != nil {
+		return err
+	}
+	u.conn.mu.Lock()
+	defer u.conn.mu.Unlock()
+	u.conn.freqPort = f
+	return nil
+}
+
+// Connect implements uart.Port.
+//
+// OneHalf stop bit is not supported.
+func (u *UART) Connect(f physic.Frequency, stopBit uart.Stop, parity uart.Parity, flow uart.Flow, bits int) (uart.Conn, error) {
+	if err := checkUARTSpeed(f); err != nil {
+		return nil, err
+	}
+	if bits < 5 || bits > 8 {
+		return nil, fmt.Errorf("sysfs-uart: invalid bits %d; must be between 5 and 8", bits)
+	}
+	var t termios2
+	// Equivalent of cfmakeraw(3).
+	t.cflag = cREAD | cLOCAL | bOTHER | uint32(bits-5)<<4
+	t.cc[vMIN] = 1
+	t.cc[vTIME] = 0
+	switch stopBit {
+	case uart.One:
+	case uart.Two:
+		t.cflag |= cSTOPB
+	default:
+		return nil, fmt.Errorf("sysfs-uart: unsupported stop bit %d", stopBit)
+	}
+	switch parity {
+	case uart.NoParity:
+	case uart.Odd:
+		t.cflag |= pARENB | pARODD
+		t.iflag |= iNPCK
+	case uart.Even:
+		t.cflag |= pARENB
+		t.iflag |= iNPCK
+	case uart.Mark:
+		t.cflag |= pARENB | pARODD | cMSPAR
+		t.iflag |= iNPCK
+	case uart.Space:
+		t.cflag |= pARENB | cMSPAR
+		t.iflag |= iNPCK
+	default:
+		return nil, fmt.Errorf("sysfs-uart: invalid parity %q", byte(parity))
+	}
+	switch {
+	case flow == uart.NoFlow:
+	case flow == uart.RTSCTS:
+		t.cflag |= cRTSCTS
+	case flow&0xFFFF0000 == uart.XOnXOff:
+		t.iflag |= iXON | iXOFF
+		t.cc[vSTART] = byte(flow >> 8)
+		t.cc[vSTOP] = byte(flow)
+	default:
+		return nil, fmt.Errorf("sysfs-uart: invalid flow %s", flow)
+	}
+
+	u.conn.mu.Lock()
+	defer u.conn.mu.Unlock()
+	if u.conn.f == nil {
+		return nil, errors.New("sysfs-uart: already closed")
+	}
+	if u.conn.connected {
+		return nil, errors.New("sysfs-uart: already connected")
+	}
+	u.conn.freqConn = f
+	if u.conn.freqPort != 0 && u.conn.freqPort < f {
+		f = u.conn.freqPort
+	}
+	baud := uint32((f + 500*physic.MilliHertz) / physic.Hertz)
+	t.ispeed = baud
+	t.ospeed = baud
+	if err := u.conn.f.setTermios(&t); err != nil {
+		return nil, fmt.Errorf("sysfs-uart: %v", err)
+	}
+	// Discard anything received or pending transmission with the previous
+	// settings.
+	if err := u.conn.f.flush(); err != nil {
+		return nil, fmt.Errorf("sysfs-uart: %v", err)
+	}
+	u.conn.connected = true
+	if flow != uart.RTSCTS {
+		u.conn.muPins.Lock()
+		u.conn.rts = gpio.INVALID
+		u.conn.cts = gpio.INVALID
+		u.conn.muPins.Unlock()
+	}
+	return &u.conn, nil
+}
+
+// RX implements uart.Pins.
+func (u *UART) RX() gpio.PinIn {
+	return u.conn.RX()
+}
+
+// TX implements uart.Pins.
+func (u *UART) TX() gpio.PinOut {
+	return u.conn.TX()
+}
+
+// RTS implements uart.Pins.
+func (u *UART) RTS() gpio.PinOut {
+	return u.conn.RTS()
+}
+
+// CTS implements uart.Pins.
+func (u *UART) CTS() gpio.PinIn {
+	return u.conn.CTS()
+}
+
+// Private details.
+
+func newUART(path string) (*UART, error) {
+	f, err := uartOpen(path)
+	if err != nil {
+		return nil, fmt.Errorf("sysfs-uart: %v", err)
+	}
+	// Prevent other processes from opening the port.
+	if err := f.setExclusive(); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("sysfs-uart: %v", err)
+	}
+	return &UART{uartConn{name: path, f: f, portNumber: uartPortNumber(path)}}, nil
+}
+
+// uartPortNumber returns the port number for the on-chip UARTs, -1 otherwise.
+func uartPortNumber(path string) int {
+	for _, prefix := range []string{devDir + "/ttyAMA", devDir + "/ttyS"} {
+		if len(path) > len(prefix) && path[:len(prefix)] == prefix {
+			if i, err := strconv.Atoi(path[len(prefix):]); err == nil {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func checkUARTSpeed(f physic.Frequency) error {
+	if f > physic.GigaHertz {
+		return fmt.Errorf("sysfs-uart: invalid speed %s; maximum supported clock is 1GHz", f)
+	}
+	if f < 50*physic.Hertz {
+		return fmt.Errorf("sysfs-uart: invalid speed %s; minimum supported clock is 50Hz; did you forget to multiply by physic.Hertz?", f)
+	}
+	return nil
+}
+
+//
+
+// uartConn implements uart.Conn.
+type uartConn struct {
+	// Immutable
+	name       string
+	portNumber int
+
+	mu        sync.Mutex
+	f         uartIO
+	freqPort  physic.Frequency // Frequency specified at LimitSpeed()
+	freqConn  physic.Frequency // Frequency specified at Connect()
+	connected bool
+	timeout   time.Duration
+
+	// Use a separate lock for the pins, so that they can be queried while a
+	// transaction is happening.
+	muPins sync.Mutex
+	rx     gpio.PinIn
+	tx     gpio.PinOut
+	rts    gpio.PinOut
+	cts    gpio.PinIn
+}
+
+func (u *uartConn) String() string {
+	return u.name
+}
+
+// Duplex implements conn.Conn.
+func (u *uartConn) Duplex() conn.Duplex {
+	return conn.Full
+}
+
+// Read implements io.Reader.
+//
+// It returns as soon as at least one byte is available, or 0 bytes if the
+// read timeout expired.
+func (u *uartConn) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	f, timeout, err := u.handle()
+	if err != nil {
+		return 0, err
+	}
+	if timeout != 0 {
+		ms := int((timeout + time.Millisecond - 1) / time.Millisecond)
+		for {
+			n, err := f.Wait(ms)
+			if err == syscall.EINTR {
+				continue
+			}
+			if err != nil {
+				return 0, u.wrap(err)
+			}
+			if n == 0 {
+				return 0, nil
+			}
+			break
+		}
+	}
+	n, err := f.Read(b)
+	if err != nil {
+		return n, u.wrap(err)
+	}
+	return n, nil
+}
+
+// Write implements io.Writer.
+func (u *uartConn) Write(b []byte) (int, error) {
+	f, _, err := u.handle()
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.Write(b)
+	if err != nil {
+		return n, u.wrap(err)
+	}
+	return n, nil
+}
+
+// Tx implements conn.Conn.
+//
+// It writes w then reads until r is filled.
+func (u *uartConn) Tx(w, r []byte) error {
+	if len(w) != 0 {
+		if _, err := u.Write(w); err != nil {
+			return err
+		}
+	}
+	for len(r) != 0 {
+		n, err := u.Read(r)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errors.New("sysfs-uart: read timeout")
+		}
+		r = r[n:]
+	}
+	return nil
+}
+
+// SetReadTimeout implements uart.Conn.
+func (u *uartConn) SetReadTimeout(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("sysfs-uart: invalid timeout %s", d)
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.timeout = d
+	return nil
+}
+
+// SendBreak implements uart.Conn.
+func (u *uartConn) SendBreak(d time.Duration) error {
+	f, _, err := u.handle()
+	if err != nil {
+		return err
+	}
+	if err := f.setBreak(true); err != nil {
+		return fmt.Errorf("sysfs-uart: %v", err)
+	}
+	time.Sleep(d)
+	if err := f.setBreak(false); err != nil {
+		return fmt.Errorf("sysfs-uart: %v", err)
+	}
+	return nil
+}
+
+// ModemLines implements uart.Conn.
+func (u *uartConn) ModemLines() (uart.ModemLine, error) {
+	f, _, err := u.handle()
+	if err != nil {
+		return 0, err
+	}
+	var v uint32
+	if err := f.modemBits(ioctlTIOCMGET, &v); err != nil {
+		return 0, fmt.Errorf("sysfs-uart: %v", err)
+	}
+	var l uart.ModemLine
+	for i, b := range tiocmBits {
+		if v&b != 0 {
+			l |= 1 << uint(i)
+		}
+	}
+	return l, nil
+}
+
+// SetModemLines implements uart.Conn.
+func (u *uartConn) SetModemLines(l uart.ModemLine) error {
+	if l&^uart.LineOutputs != 0 {
+		return fmt.Errorf("sysfs-uart: can't set input lines %s", l&^uart.LineOutputs)
+	}
+	f, _, err := u.handle()
+	if err != nil {
+		return err
+	}
+	var set, clr uint32
+	for i, b := range tiocmBits {
+		if m := uart.ModemLine(1 << uint(i)); m&uart.LineOutputs != 0 {
+			if l&m != 0 {
+				set |= b
+			} else {
+				clr |= b
+			}
+		}
+	}
+	if set != 0 {
+		if err := f.modemBits(ioctlTIOCMBIS, &set); err != nil {
+			return fmt.Errorf("sysfs-uart: %v", err)
+		}
+	}
+	if clr != 0 {
+		if err := f.modemBits(ioctlTIOCMBIC, &clr); err != nil {
+			return fmt.Errorf("sysfs-uart: %v", err)
+		}
+	}
+	return nil
+}
+
+// RX implements uart.Pins.
+func (u *uartConn) RX() gpio.PinIn {
+	u.initPins()
+	return u.rx
+}
+
+// TX implements uart.Pins.
+func (u *uartConn) TX() gpio.PinOut {
+	u.initPins()
+	return u.tx
+}
+
+// RTS implements uart.Pins.
+func (u *uartConn) RTS() gpio.PinOut {
+	u.initPins()
+	return u.rts
+}
+
+// CTS implements uart.Pins.
+func (u *uartConn) CTS() gpio.PinIn {
+	u.initPins()
+	return u.cts
+}
+
+// handle returns the file handle and the read timeout.
+//
+// The lock is not held during I/O so that Read() and Write() can be used
+// concurrently.
+func (u *uartConn) handle() (uartIO, time.Duration, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.f == nil {
+		return nil, 0, errors.New("sysfs-uart: already closed")
+	}
+	if !u.connected {
+		return nil, 0, errors.New("sysfs-uart: not connected")
+	}
+	return u.f, u.timeout, nil
+}
+
+// wrap returns a *conn.RemovedError if the port was removed, otherwise err
+// prefixed with the driver name.
+func (u *uartConn) wrap(err error) error {
+	if r := removedError(u.name, err); r != nil {
+		return r
+	}
+	return fmt.Errorf("sysfs-uart: %v", err)
+}
+
+func (u *uartConn) initPins() {
+	u.muPins.Lock()
+	defer u.muPins.Unlock()
+	if u.rx != nil {
+		return
+	}
+	if u.portNumber == -1 {
+		u.rx = gpio.INVALID
+		u.tx = gpio.INVALID
+		u.rts = gpio.INVALID
+		u.cts = gpio.INVALID
+		return
+	}
+	if u.rx = gpioreg.ByName(fmt.Sprintf("UART%d_RX", u.portNumber)); u.rx == nil {
+		u.rx = gpio.INVALID
+	}
+	if u.tx = gpioreg.ByName(fmt.Sprintf("UART%d_TX", u.portNumber)); u.tx == nil {
+		u.tx = gpio.INVALID
+	}
+	// u.rts is set to INVALID if no hardware RTS/CTS flow control is used.
+	if u.rts == nil {
+		if u.rts = gpioreg.ByName(fmt.Sprintf("UART%d_RTS", u.portNumber)); u.rts == nil {
+			u.rts = gpio.INVALID
+		}
+		if u.cts = gpioreg.ByName(fmt.Sprintf("UART%d_CTS", u.portNumber)); u.cts == nil {
+			u.cts = gpio.INVALID
+		}
+	}
+}
+
+// uartIO is the handle to a tty device.
+type uartIO interface {
+	io.Closer
+	io.Reader
+	io.Writer
+	// Wait waits for data to be available to be read.
+	Wait(timeoutms int) (int, error)
+	// setTermios issues TCSETS2.
+	setTermios(t *termios2) error
+	// flush issues TCFLSH to discard both the input and output queues.
+	flush() error
+	// setExclusive issues TIOCEXCL.
+	setExclusive() error
+	// setBreak issues TIOCSBRK or TIOCCBRK.
+	setBreak(on bool) error
+	// modemBits issues TIOCMGET, TIOCMBIS or TIOCMBIC.
+	modemBits(op uint, v *uint32) error
+}
+
+var uartOpen = uartOpenDefault
+
+func uartOpenDefault(path string) (uartIO, error) {
+	// O_NONBLOCK is needed to not block on DCD when opening the port; the file
+	// descriptor is switched back to blocking mode by newUARTFile.
+	f, err := fs.Open(path, os.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	return newUARTFile(f)
+}
+
+func newUARTFile(f *fs.File) (uartIO, error) {
+	u := &uartFile{File: *f}
+	// Read() and Write() must block; the read timeout is implemented with
+	// Wait().
+	if err := setBlocking(f.Fd()); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if err := u.event.MakeReadEvent(f.Fd()); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return u, nil
+}
+
+// uartFile implements uartIO.
+type uartFile struct {
+	fs.File
+	event fs.Event
+}
+
+func (u *uartFile) Wait(timeoutms int) (int, error) {
+	return u.event.Wait(timeoutms)
+}
+
+// The tty IOCTLs are issued with ioctlRaw() since most of them don't use the
+// _IOC encoding that fs.File.Ioctl() translates on MIPS; the per architecture
+// values are defined in uart_ioctl_*.go.
+
+func (u *uartFile) setTermios(t *termios2) error {
+	return ioctlRaw(u.Fd(), ioctlTCSETS2, uintptr(unsafe.Pointer(t)))
+}
+
+func (u *uartFile) flush() error {
+	return ioctlRaw(u.Fd(), ioctlTCFLSH, tcIOFLUSH)
+}
+
+func (u *uartFile) setExclusive() error {
+	return ioctlRaw(u.Fd(), ioctlTIOCEXCL, 0)
+}
+
+func (u *uartFile) setBreak(on bool) error {
+	if on {
+		return ioctlRaw(u.Fd(), ioctlTIOCSBRK, 0)
+	}
+	return ioctlRaw(u.Fd(), ioctlTIOCCBRK, 0)
+}
+
+func (u *uartFile) modemBits(op uint, v *uint32) error {
+	return ioctlRaw(u.Fd(), op, uintptr(unsafe.Pointer(v)))
+}
+
+func (u *uartFile) Close() error {
+	err := u.event.Close()
+	if err2 := u.File.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// termios2 is struct termios2 in asm/termbits.h.
+type termios2 struct {
+	iflag  uint32
+	oflag  uint32
+	cflag  uint32
+	lflag  uint32
+	line   uint8
+	cc     [nCCS]uint8
+	ispeed uint32
+	ospeed uint32
+}
+
+// termios flags, from asm-generic/termbits.h. The control characters indices
+// that differ on MIPS are in uart_ioctl_*.go.
+const (
+	iNPCK = 0x10
+	iXON  = 0x400
+	iXOFF = 0x1000
+
+	cSTOPB  = 0x40
+	cREAD   = 0x80
+	pARENB  = 0x100
+	pARODD  = 0x200
+	cLOCAL  = 0x800
+	bOTHER  = 0x1000
+	cMSPAR  = 0x40000000
+	cRTSCTS = 0x80000000
+
+	vTIME  = 5
+	vSTART = 8
+	vSTOP  = 9
+
+	tcIOFLUSH = 2
+)
+
+// tiocmBits are the TIOCM_* bits in the same order as uart.ModemLine.
+var tiocmBits = [...]uint32{
+	0x002, // TIOCM_DTR
+	0x004, // TIOCM_RTS
+	0x020, // TIOCM_CTS
+	0x100, // TIOCM_DSR
+	0x040, // TIOCM_CAR
+	0x080, // TIOCM_RNG
+}
+
+//
+
+// driverUART implements periph.Driver.
+type driverUART struct {
+	// Protected by scanMu.
+	initialized bool // Set by Init() even if nothing was found, cleared by Close(); see Rescan()
+	ports       []string
+	// numbers is the port number of each registered port, if any.
+	numbers map[string]int
+}
+
+func (d *driverUART) String() string {
+	return "sysfs-uart"
+}
+
+func (d *driverUART) Prerequisites() []string {
+	return nil
+}
+
+func (d *driverUART) After() []string {
+	return nil
+}
+
+func (d *driverUART) Init() (bool, error) {
+	// This driver is only registered on linux, so there is no legitimate time to
+	// skip it.
+	scanMu.Lock()
+	defer scanMu.Unlock()
+	d.initialized = true
+	n, err := d.scan()
+	if err != nil {
+		return true, err
+	}
+	if n == 0 {
+		return false, errors.New("no UART port found")
+	}
+	return true, nil
+}
+
+// scan registers the UART ports that appeared and unregisters the ones that
+// disappeared since the last scan.
+//
+// It returns the number of ports found. scanMu must be held.
+func (d *driverUART) scan() (int, error) {
+	// Do not use "/sys/class/tty/" as it lists all the virtual consoles.
+	var items []string
+	for _, pattern := range []string{"/ttyAMA*", "/ttyS*", "/ttyUSB*", "/ttyACM*"} {
+		i, err := filepath.Glob(devDir + pattern)
+		if err != nil {
+			return 0, err
+		}
+		sort.Strings(i)
+		items = append(items, i...)
+	}
+	found := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		found[item] = struct{}{}
+	}
+	d.ports = forget(d.ports, found, uartreg.Unregister)
+	if d.numbers == nil {
+		d.numbers = map[string]int{}
+	}
+	numbers := map[int]bool{}
+	for name, n := range d.numbers {
+		if _, ok := found[name]; ok {
+			numbers[n] = true
+		} else {
+			delete(d.numbers, name)
+		}
+	}
+	for _, item := range items {
+		if contains(d.ports, item) {
+			continue
+		}
+		// On the Raspberry Pi, ttyAMA0 and ttyS0 may both exist; only the first
+		// one gets the number.
+		n := uartPortNumber(item)
+		if numbers[n] {
+			n = -1
+		} else if n != -1 {
+			numbers[n] = true
+			d.numbers[item] = n
+		}
+		aliases := []string{filepath.Base(item)}
+		if err := uartreg.Register(item, aliases, n, openerUART(item).Open); err != nil {
+			return len(items), err
+		}
+		d.ports = append(d.ports, item)
+	}
+	return len(items), nil
+}
+
+// Close unregisters the UART ports.
+func (d *driverUART) Close() error {
+	scanMu.Lock()
+	defer scanMu.Unlock()
+	d.initialized = false
+	for _, name := range d.ports {
+		_ = uartreg.Unregister(name)
+	}
+	d.ports = nil
+	d.numbers = nil
+	return nil
+}
+
+type openerUART string
+
+func (o openerUART) Open() (uart.PortCloser, error) {
+	return NewUART(string(o))
+}
+
+func init() {
+	if isLinux {
+		periph.MustRegister(&drvUART)
+	}
+}
+
+var drvUART driverUART
+
+var _ io.Reader = &uartConn{}
+var _ io.Writer = &uartConn{}
+var _ uart.Conn = &uartConn{}
+var _ uart.Pins = &UART{}
+var _ uart.Pins = &uartConn{}
+var _ uart.Port = &UART{}
+var _ uart.PortCloser = &UART{}
+var _ fmt.Stringer = &UART{}
@@ -0,0 +1,28 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// +build mips mipsle mips64 mips64le
+
+package sysfs
+
+// tty IOCTL control codes.
+//
+// Constants can be found at arch/mips/include/uapi/asm/ioctls.h. TCSETS2 uses
+// the MIPS _IOC encoding, where write is 4<<29.
+const (
+	ioctlTCSETS2  = 0x8030542B // TCSETS2
+	ioctlTCFLSH   = 0x5407     // TCFLSH
+	ioctlTIOCEXCL = 0x740D     // TIOCEXCL
+	ioctlTIOCMGET = 0x741D     // TIOCMGET
+	ioctlTIOCMBIS = 0x741B     // TIOCMBIS
+	ioctlTIOCMBIC = 0x741C     // TIOCMBIC
+	ioctlTIOCSBRK = 0x5427     // TIOCSBRK
+	ioctlTIOCCBRK = 0x5428     // TIOCCBRK
+)
+
+// From arch/mips/include/uapi/asm/termbits.h.
+const (
+	nCCS = 23
+	vMIN = 4
+)
@@ -0,0 +1,27 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// +build !mips,!mipsle,!mips64,!mips64le
+
+package sysfs
+
+// tty IOCTL control codes.
+//
+// Constants can be found at /usr/include/asm-generic/ioctls.h.
+const (
+	ioctlTCSETS2  = 0x402C542B // TCSETS2
+	ioctlTCFLSH   = 0x540B     // TCFLSH
+	ioctlTIOCEXCL = 0x540C     // TIOCEXCL
+	ioctlTIOCMGET = 0x5415     // TIOCMGET
+	ioctlTIOCMBIS = 0x5416     // TIOCMBIS
+	ioctlTIOCMBIC = 0x5417     // TIOCMBIC
+	ioctlTIOCSBRK = 0x5427     // TIOCSBRK
+	ioctlTIOCCBRK = 0x5428     // TIOCCBRK
+)
+
+// From asm-generic/termbits.h.
+const (
+	nCCS = 19
+	vMIN = 6
+)
@@ -0,0 +1,395 @@
+// Copyright 2018 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/uart"
+	"periph.io/x/periph/host/fs"
+)
+
+func TestNewUART(t *testing.T) {
+	defer reset()
+	uartOpen = func(path string) (uartIO, error) {
+		return nil, errors.New("foo")
+	}
+	if _, err := newUART("/dev/ttyS0"); err == nil || err.Error() != "sysfs-uart: foo" {
+		t.Fatal(err)
+	}
+	f := &fakeUART{ioctlErr: errors.New("bar")}
+	uartOpen = func(path string) (uartIO, error) {
+		return f, nil
+	}
+	if _, err := newUART("/dev/ttyS0"); err == nil || err.Error() != "sysfs-uart: bar" {
+		t.Fatal(err)
+	}
+	if !f.closed {
+		t.Fatal("handle must be closed on failure")
+	}
+}
+
+func TestTermios2_size(t *testing.T) {
+	// The size is encoded in TCSETS2; it is 44 bytes, 48 on MIPS.
+	if s := uint(unsafe.Sizeof(termios2{})); s != (ioctlTCSETS2>>16)&0x1FFF {
+		t.Fatal(s)
+	}
+}
+
+func TestUARTPortNumber(t *testing.T) {
+	data := []struct {
+		path     string
+		expected int
+	}{
+		{"/dev/ttyS1", 1},
+		{"/dev/ttyAMA0", 0},
+		{"/dev/ttyUSB0", -1},
+		{"/dev/ttyS", -1},
+		{"/dev/ttySx", -1},
+	}
+	for i, line := range data {
+		if n := uartPortNumber(line.path); n != line.expected {
+			t.Fatalf("#%d: %d != %d", i, n, line.expected)
+		}
+	}
+}
+
+func TestUART_Connect(t *testing.T) {
+	f := &fakeUART{}
+	u := UART{uartConn{name: "/dev/ttyUSB0", f: f, portNumber: -1}}
+	if err := u.LimitSpeed(9600 * physic.Hertz); err != nil {
+		t.Fatal(err)
+	}
+	c, err := u.Connect(115200*physic.Hertz, uart.Two, uart.Even, uart.RTSCTS, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := c.String(); s != "/dev/ttyUSB0" {
+		t.Fatal(s)
+	}
+	if d := c.Duplex(); d != conn.Full {
+		t.Fatal(d)
+	}
+	if f.t.ispeed != 9600 || f.t.ospeed != 9600 {
+		t.Fatal(f.t.ispeed, f.t.ospeed)
+	}
+	if expected := uint32(cREAD | cLOCAL | bOTHER | 0x20 | cSTOPB | pARENB | cRTSCTS); f.t.cflag != expected {
+		t.Fatalf("0x%x != 0x%x", f.t.cflag, expected)
+	}
+	if f.t.iflag != iNPCK || f.t.lflag != 0 || f.t.oflag != 0 || f.t.cc[vMIN] != 1 {
+		t.Fatal(f.t)
+	}
+	if _, err := u.Connect(115200*physic.Hertz, uart.One, uart.NoParity, uart.NoFlow, 8); err == nil {
+		t.Fatal("already connected")
+	}
+	if err := u.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Close(); err == nil {
+		t.Fatal("already closed")
+	}
+	if _, err := u.Connect(115200*physic.Hertz, uart.One, uart.NoParity, uart.NoFlow, 8); err == nil {
+		t.Fatal("already closed")
+	}
+	if _, err := c.Write([]byte{1}); err == nil {
+		t.Fatal("already closed")
+	}
+}
+
+func TestUART_Connect_XOnXOff(t *testing.T) {
+	f := &fakeUART{}
+	u := UART{uartConn{f: f}}
+	if _, err := u.Connect(115200*physic.Hertz, uart.One, uart.Mark, uart.MakeXOnXOffFlow(1, 2), 8); err != nil {
+		t.Fatal(err)
+	}
+	if f.t.iflag != iNPCK|iXON|iXOFF || f.t.cc[vSTART] != 1 || f.t.cc[vSTOP] != 2 {
+		t.Fatal(f.t)
+	}
+	if f.t.cflag&(pARENB|pARODD|cMSPAR) != pARENB|pARODD|cMSPAR {
+		t.Fatal(f.t)
+	}
+	if f.t.ospeed != 115200 {
+		t.Fatal(f.t.ospeed)
+	}
+}
+
+func TestUART_Connect_Err(t *testing.T) {
+	u := UART{uartConn{f: &fakeUART{}}}
+	if _, err := u.Connect(physic.Hertz, uart.One, uart.NoParity, uart.NoFlow, 8); err == nil {
+		t.Fatal("speed too low")
+	}
+	if _, err := u.Connect(2*physic.GigaHertz, uart.One, uart.NoParity, uart.NoFlow, 8); err == nil {
+		t.Fatal("speed too high")
+	}
+	if _, err := u.Connect(9600*physic.Hertz, uart.One, uart.NoParity, uart.NoFlow, 9); err == nil {
+		t.Fatal("invalid bits")
+	}
+	if _, err := u.Connect(9600*physic.Hertz, uart.OneHalf, uart.NoParity, uart.NoFlow, 8); err == nil {
+		t.Fatal("unsupported stop bit")
+	}
+	if _, err := u.Connect(9600*physic.Hertz, uart.One, uart.Parity('X'), uart.NoFlow, 8); err == nil {
+		t.Fatal("invalid parity")
+	}
+	if _, err := u.Connect(9600*physic.Hertz, uart.One, uart.NoParity, uart.Flow(0), 8); err == nil {
+		t.Fatal("invalid flow")
+	}
+	if err := u.LimitSpeed(0); err == nil {
+		t.Fatal("invalid speed")
+	}
+	u = UART{uartConn{f: &fakeUART{ioctlErr: errors.New("foo")}}}
+	if _, err := u.Connect(9600*physic.Hertz, uart.One, uart.NoParity, uart.NoFlow, 8); err == nil || err.Error() != "sysfs-uart: foo" {
+		t.Fatal(err)
+	}
+	u = UART{uartConn{f: &fakeUART{closeErr: errors.New("foo")}}}
+	if err := u.Close(); err == nil || err.Error() != "sysfs-uart: foo" {
+		t.Fatal(err)
+	}
+}
+
+func TestUART_ModemLines(t *testing.T) {
+	f := &fakeUART{tiocm: 0x002 | 0x020 | 0x080}
+	u := UART{uartConn{f: f}}
+	c, err := u.Connect(9600*physic.Hertz, uart.One, uart.NoParity, uart.NoFlow, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := c.ModemLines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l != uart.LineDTR|uart.LineCTS|uart.LineRI {
+		t.Fatal(l)
+	}
+	if err := c.SetModemLines(uart.LineRTS); err != nil {
+		t.Fatal(err)
+	}
+	if f.tiocm != 0x004|0x020|0x080 {
+		t.Fatalf("0x%x", f.tiocm)
+	}
+	if err := c.SetModemLines(uart.LineOutputs); err != nil {
+		t.Fatal(err)
+	}
+	if f.tiocm != 0x002|0x004|0x020|0x080 {
+		t.Fatalf("0x%x", f.tiocm)
+	}
+	if err := c.SetModemLines(uart.LineCTS); err == nil {
+		t.Fatal("CTS is an input")
+	}
+	f.ioctlErr = errors.New("foo")
+	if _, err := c.ModemLines(); err == nil {
+		t.Fatal("ioctl failed")
+	}
+	if err := c.SetModemLines(0); err == nil {
+		t.Fatal("ioctl failed")
+	}
+	if err := c.SendBreak(0); err == nil {
+		t.Fatal("ioctl failed")
+	}
+}
+
+func TestUART_Pins(t *testing.T) {
+	u := UART{uartConn{f: &fakeUART{}, portNumber: -1}}
+	if p := u.RX(); p != gpio.INVALID {
+		t.Fatal(p)
+	}
+	if p := u.TX(); p != gpio.INVALID {
+		t.Fatal(p)
+	}
+	if p := u.RTS(); p != gpio.INVALID {
+		t.Fatal(p)
+	}
+	if p := u.CTS(); p != gpio.INVALID {
+		t.Fatal(p)
+	}
+	u = UART{uartConn{f: &fakeUART{}, portNumber: 42}}
+	if p := u.RX(); p != gpio.INVALID {
+		t.Fatal(p)
+	}
+}
+
+func TestUART_NotConnected(t *testing.T) {
+	u := UART{uartConn{f: &fakeUART{}}}
+	if _, err := u.conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("not connected")
+	}
+	if err := u.conn.SetReadTimeout(-1); err == nil {
+		t.Fatal("negative timeout")
+	}
+}
+
+func TestUART_pty(t *testing.T) {
+	defer reset()
+	master, slave := openPTY(t)
+	defer master.Close()
+	uartOpen = func(path string) (uartIO, error) {
+		f, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+		if err != nil {
+			return nil, err
+		}
+		return newUARTFile(&fs.File{File: f})
+	}
+	u, err := newUART(slave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+	if s := u.String(); s != slave {
+		t.Fatal(s)
+	}
+	c, err := u.Connect(115200*physic.Hertz, uart.One, uart.NoParity, uart.NoFlow, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write.
+	if n, err := c.Write([]byte("hello\n")); n != 6 || err != nil {
+		t.Fatal(n, err)
+	}
+	b := make([]byte, 16)
+	if n, err := master.Read(b); string(b[:n]) != "hello\n" || err != nil {
+		t.Fatalf("%q %v", b[:n], err)
+	}
+
+	// Read; there is no line discipline so no newline is needed.
+	if _, err := master.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetReadTimeout(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.Read(b); string(b[:n]) != "abc" || err != nil {
+		t.Fatalf("%q %v", b[:n], err)
+	}
+
+	// Timeout.
+	if err := c.SetReadTimeout(10 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.Read(b); n != 0 || err != nil {
+		t.Fatal(n, err)
+	}
+
+	// Tx.
+	if _, err := master.Write([]byte("xyz")); err != nil {
+		t.Fatal(err)
+	}
+	r := make([]byte, 3)
+	if err := c.Tx([]byte("q"), r); err != nil || string(r) != "xyz" {
+		t.Fatalf("%q %v", r, err)
+	}
+	if n, err := master.Read(b); string(b[:n]) != "q" || err != nil {
+		t.Fatalf("%q %v", b[:n], err)
+	}
+	if err := c.Tx(nil, r); err == nil || err.Error() != "sysfs-uart: read timeout" {
+		t.Fatal(err)
+	}
+
+	// A pty silently ignores breaks.
+	if err := c.SendBreak(time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUARTDriver(t *testing.T) {
+	if len((&driverUART{}).Prerequisites()) != 0 {
+		t.Fatal("unexpected UART prerequisites")
+	}
+	if len((&driverUART{}).After()) != 0 {
+		t.Fatal("unexpected UART after")
+	}
+}
+
+//
+
+// openPTY opens a pseudo-terminal pair and returns the master and the path to
+// the slave.
+func openPTY(t *testing.T) (*os.File, string) {
+	m, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("pseudo-terminals are not available: %v", err)
+	}
+	f := fs.File{File: m}
+	var unlock int32
+	if err := f.Ioctl(0x40045431, uintptr(unsafe.Pointer(&unlock))); err != nil { // TIOCSPTLCK
+		m.Close()
+		t.Skipf("pseudo-terminals are not available: %v", err)
+	}
+	var n uint32
+	if err := f.Ioctl(0x80045430, uintptr(unsafe.Pointer(&n))); err != nil { // TIOCGPTN
+		m.Close()
+		t.Skipf("pseudo-terminals are not available: %v", err)
+	}
+	return m, "/dev/pts/" + strconv.Itoa(int(n))
+}
+
+// fakeUART implements uartIO.
+type fakeUART struct {
+	ioctlErr error
+	closeErr error
+	closed   bool
+	t        termios2
+	tiocm    uint32
+}
+
+func (f *fakeUART) setTermios(t *termios2) error {
+	if f.ioctlErr != nil {
+		return f.ioctlErr
+	}
+	f.t = *t
+	return nil
+}
+
+func (f *fakeUART) flush() error {
+	return f.ioctlErr
+}
+
+func (f *fakeUART) setExclusive() error {
+	return f.ioctlErr
+}
+
+func (f *fakeUART) setBreak(on bool) error {
+	return f.ioctlErr
+}
+
+func (f *fakeUART) modemBits(op uint, v *uint32) error {
+	if f.ioctlErr != nil {
+		return f.ioctlErr
+	}
+	switch op {
+	case ioctlTIOCMGET:
+		*v = f.tiocm
+	case ioctlTIOCMBIS:
+		f.tiocm |= *v
+	case ioctlTIOCMBIC:
+		f.tiocm &^= *v
+	}
+	return nil
+}
+
+func (f *fakeUART) Close() error {
+	f.closed = true
+	return f.closeErr
+}
+
+func (f *fakeUART) Read(b []byte) (int, error) {
+	return 0, errors.New("not implemented")
+}
+
+func (f *fakeUART) Write(b []byte) (int, error) {
+	return 0, errors.New("not implemented")
+}
+
+func (f *fakeUART) Wait(timeoutms int) (int, error) {
+	return 0, nil
+}
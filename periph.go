@@ -14,7 +14,8 @@
 // calling periph.MustRegister().
 //
 // User shall call either host.Init() or hostextra.Init() on startup to
-// initialize all the registered drivers.
+// initialize all the registered drivers. periph.Shutdown() releases the
+// resources held by the drivers, after which they can be initialized again.
 //
 // Cmd
 //
@@ -61,6 +62,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Driver is an implementation for a protocol.
@@ -98,6 +100,22 @@ type Driver interface {
 	Init() (bool, error)
 }
 
+// DriverCloser is a Driver that can release the resources it acquired in
+// Init().
+//
+// Implementing it is optional but is required for the driver to be
+// initialized again after Shutdown().
+type DriverCloser interface {
+	Driver
+	// Close releases the resources acquired by Init(): memory mappings, open
+	// file handles, exported pins, DMA channels, and unregisters what was
+	// registered in gpioreg, i2creg, spireg, onewirereg, pinreg, etc.
+	//
+	// It is called even if Init() failed, so it must handle a partially
+	// initialized driver.
+	Close() error
+}
+
 // DriverFailure is a driver that wasn't loaded, either because it was skipped
 // or because it failed to load.
 type DriverFailure struct {
@@ -122,6 +140,40 @@ type State struct {
 	Loaded  []Driver
 	Skipped []DriverFailure
 	Failed  []DriverFailure
+	// Stages is the order in which the drivers are initialized. The drivers in
+	// a stage are initialized concurrently, once all the drivers of the
+	// previous stages were processed. Each stage is sorted by the driver name.
+	//
+	// Drivers that were left out by Opts are not listed.
+	Stages [][]string
+	// Durations is the time each driver's Init() took, by driver name.
+	//
+	// Drivers skipped because of a missing dependency or left out by Opts are
+	// not listed since their Init() function is not called.
+	Durations map[string]time.Duration
+	// Duration is the total time it took to initialize the drivers.
+	Duration time.Duration
+}
+
+// Opts specifies the drivers to initialize in InitWithOpts().
+//
+// Drivers are specified by their name, as returned by Driver.String().
+type Opts struct {
+	// Include, if not empty, is the list of drivers to initialize. Their
+	// prerequisites, as returned by Driver.Prerequisites(), are implicitly
+	// included.
+	//
+	// When empty, all the registered drivers are initialized.
+	Include []string
+	// Exclude is the list of drivers to never initialize. It has precedence
+	// over Include, so a driver that has an excluded driver as a prerequisite
+	// is skipped.
+	Exclude []string
+	// DryRun computes State.Stages and the drivers that are left out, without
+	// initializing any driver.
+	//
+	// Init() can still be called after a dry run.
+	DryRun bool
 }
 
 // Init initialises all the relevant drivers.
@@ -129,21 +181,61 @@ type State struct {
 // Drivers are started concurrently.
 //
 // It is safe to call this function multiple times, the previous state is
-// returned on later calls.
+// returned on later calls. Call Shutdown() to initialize the drivers again.
 //
 // Users will want to use host.Init(), which guarantees a baseline of included
 // host drivers.
 func Init() (*State, error) {
+	return InitWithOpts(nil)
+}
+
+// InitWithOpts initialises the drivers selected by opts.
+//
+// opts can be nil, in which case it is the same as Init(). The drivers that
+// are left out are listed in State.Skipped.
+//
+// Unless opts.DryRun is set, it is safe to call this function multiple times,
+// the previous state is returned on later calls even if opts differ.
+func InitWithOpts(opts *Opts) (*State, error) {
+	if opts == nil {
+		opts = &Opts{}
+	}
 	mu.Lock()
 	defer mu.Unlock()
-	if state != nil {
+	if state != nil && !opts.DryRun {
 		return state, nil
 	}
-	state = &State{}
 	// At this point, byName is guaranteed to be immutable.
+	selected, left, err := selectDrivers(opts)
+	if err != nil {
+		return nil, err
+	}
+	s := &State{Durations: map[string]time.Duration{}}
+	for _, f := range left {
+		s.Skipped = insertDriverFailure(s.Skipped, f)
+	}
+	stages, err := explodeStages(selected)
+	if err == nil {
+		for _, st := range stages {
+			var names []string
+			for name := range st.drvs {
+				names = insertString(names, name)
+			}
+			s.Stages = append(s.Stages, names)
+		}
+	}
+	if opts.DryRun {
+		return s, err
+	}
+	state = s
+	if err != nil {
+		return state, err
+	}
+
 	cD := make(chan Driver)
 	cS := make(chan DriverFailure)
 	cE := make(chan DriverFailure)
+	cT := make(chan timing)
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
@@ -166,27 +258,77 @@ func Init() (*State, error) {
 			state.Failed = insertDriverFailure(state.Failed, f)
 		}
 	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for t := range cT {
+			state.Durations[t.name] = t.d
+		}
+	}()
 
-	stages, err := explodeStages()
-	if err != nil {
-		return state, err
-	}
-	loaded := make(map[string]struct{}, len(byName))
-	for _, s := range stages {
-		s.load(loaded, cD, cS, cE)
+	start := time.Now()
+	loaded := make(map[string]struct{}, len(selected))
+	for _, st := range stages {
+		st.load(loaded, cD, cS, cE, cT)
 	}
+	state.Duration = time.Since(start)
 	close(cD)
 	close(cS)
 	close(cE)
+	close(cT)
 	wg.Wait()
 	return state, nil
 }
 
+// Shutdown closes the drivers that were initialized by Init().
+//
+// Drivers are closed one at a time in the reverse order of initialization, so
+// a driver is closed before its prerequisites. Only the drivers implementing
+// DriverCloser that were loaded or that failed to load are closed.
+//
+// Once done, Register() can be called again and the next call to Init()
+// initializes the drivers again. Drivers not implementing DriverCloser may
+// then fail to initialize.
+//
+// It returns the first error returned by a driver's Close(), but all the
+// drivers are closed.
+func Shutdown() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if state == nil {
+		return nil
+	}
+	initialized := make(map[string]struct{}, len(state.Loaded)+len(state.Failed))
+	for _, d := range state.Loaded {
+		initialized[d.String()] = struct{}{}
+	}
+	for _, f := range state.Failed {
+		initialized[f.D.String()] = struct{}{}
+	}
+	var err error
+	for i := len(state.Stages) - 1; i >= 0; i-- {
+		s := state.Stages[i]
+		for j := len(s) - 1; j >= 0; j-- {
+			if _, ok := initialized[s[j]]; !ok {
+				continue
+			}
+			if c, ok := byName[s[j]].(DriverCloser); ok {
+				if err2 := c.Close(); err2 != nil && err == nil {
+					err = errors.New("periph: failed to close " + strconv.Quote(s[j]) + ": " + err2.Error())
+				}
+			}
+		}
+	}
+	state = nil
+	return err
+}
+
 // Register registers a driver to be initialized automatically on Init().
 //
 // The d.String() value must be unique across all registered drivers.
 //
-// It is an error to call Register() after Init() was called.
+// It is an error to call Register() after Init() was called, unless
+// Shutdown() was called since.
 func Register(d Driver) error {
 	mu.Lock()
 	defer mu.Unlock()
@@ -216,15 +358,77 @@ func MustRegister(d Driver) {
 var (
 	// mu guards byName and state.
 	// - byName is only mutated by Register().
-	// - state is only mutated by Init().
+	// - state is only mutated by Init() and Shutdown().
 	//
 	// Once Init() is called, Register() refuses registering more drivers, thus
-	// byName is immutable once Init() started.
+	// byName is immutable once Init() started, until Shutdown() is called.
 	mu     sync.Mutex
 	byName = map[string]Driver{}
 	state  *State
 )
 
+// timing is the time it took for a driver's Init() to return.
+type timing struct {
+	name string
+	d    time.Duration
+}
+
+// selectDrivers returns the subset of byName to initialize according to opts,
+// and the drivers that are left out.
+func selectDrivers(opts *Opts) (map[string]Driver, []DriverFailure, error) {
+	for _, l := range [][]string{opts.Include, opts.Exclude} {
+		for _, n := range l {
+			if _, ok := byName[n]; !ok {
+				return nil, nil, errors.New("periph: unknown driver " + strconv.Quote(n))
+			}
+		}
+	}
+	excluded := make(map[string]struct{}, len(opts.Exclude))
+	for _, n := range opts.Exclude {
+		excluded[n] = struct{}{}
+	}
+	selected := map[string]Driver{}
+	if len(opts.Include) == 0 {
+		for n, d := range byName {
+			if _, ok := excluded[n]; !ok {
+				selected[n] = d
+			}
+		}
+	} else {
+		// Walk the prerequisites to include them implicitly.
+		todo := append([]string{}, opts.Include...)
+		for len(todo) != 0 {
+			n := todo[len(todo)-1]
+			todo = todo[:len(todo)-1]
+			if _, ok := selected[n]; ok {
+				continue
+			}
+			if _, ok := excluded[n]; ok {
+				continue
+			}
+			d, ok := byName[n]
+			if !ok {
+				// A missing prerequisite is reported by explodeStages().
+				continue
+			}
+			selected[n] = d
+			todo = append(todo, d.Prerequisites()...)
+		}
+	}
+	var left []DriverFailure
+	for n, d := range byName {
+		if _, ok := selected[n]; ok {
+			continue
+		}
+		if _, ok := excluded[n]; ok {
+			left = append(left, DriverFailure{d, errors.New("excluded")})
+		} else {
+			left = append(left, DriverFailure{d, errors.New("not included")})
+		}
+	}
+	return selected, left, nil
+}
+
 // stage is a set of drivers that can be loaded in parallel.
 type stage struct {
 	// Subset of byName drivers, for the ones in this stage.
@@ -234,7 +438,7 @@ type stage struct {
 // load loads all the drivers for this stage in parallel.
 //
 // Updates loaded in a safe way.
-func (s *stage) load(loaded map[string]struct{}, cD chan<- Driver, cS, cE chan<- DriverFailure) {
+func (s *stage) load(loaded map[string]struct{}, cD chan<- Driver, cS, cE chan<- DriverFailure, cT chan<- timing) {
 	success := make(chan string)
 	go func() {
 		defer close(success)
@@ -253,7 +457,10 @@ func (s *stage) load(loaded map[string]struct{}, cD chan<- Driver, cS, cE chan<-
 			wg.Add(1)
 			go func(n string, d Driver) {
 				defer wg.Done()
-				if ok, err := d.Init(); ok {
+				start := time.Now()
+				ok, err := d.Init()
+				cT <- timing{n, time.Since(start)}
+				if ok {
 					if err == nil {
 						cD <- d
 						success <- n
@@ -267,34 +474,46 @@ func (s *stage) load(loaded map[string]struct{}, cD chan<- Driver, cS, cE chan<-
 		}
 		wg.Wait()
 	}()
+	// loaded is read by the goroutine above, only update it once it is done.
+	var names []string
 	for s := range success {
-		loaded[s] = struct{}{}
+		names = append(names, s)
+	}
+	for _, n := range names {
+		loaded[n] = struct{}{}
 	}
 }
 
-// explodeStages creates one or multiple stages by processing byName.
+// explodeStages creates one or multiple stages by processing drivers, a subset
+// of byName.
 //
 // It searches if there's any driver than has dependency on another driver and
 // create stages from this DAG.
 //
 // It also verifies that there is not cycle in the DAG.
 //
+// Prerequisites are verified against byName; drivers outside of drivers are
+// ignored when ordering, the stage load will skip the drivers depending on
+// them.
+//
 // When this function starts, allDriver and byName are guaranteed to be
 // immutable. state must not be touched by this function.
-func explodeStages() ([]*stage, error) {
+func explodeStages(drivers map[string]Driver) ([]*stage, error) {
 	// First, create the DAG.
 	dag := map[string]map[string]struct{}{}
-	for name, d := range byName {
+	for name, d := range drivers {
 		m := map[string]struct{}{}
 		for _, p := range d.Prerequisites() {
 			if _, ok := byName[p]; !ok {
 				return nil, errors.New("periph: unsatisfied dependency " + strconv.Quote(name) + "->" + strconv.Quote(p) + "; it is missing; skipping")
 			}
-			m[p] = struct{}{}
+			if _, ok := drivers[p]; ok {
+				m[p] = struct{}{}
+			}
 		}
 		for _, p := range d.After() {
 			// Skip undefined drivers silently, unlike Prerequisites().
-			if _, ok := byName[p]; ok {
+			if _, ok := drivers[p]; ok {
 				m[p] = struct{}{}
 			}
 		}
@@ -308,7 +527,7 @@ func explodeStages() ([]*stage, error) {
 		for name, deps := range dag {
 			// This driver has no dependency, add it to the current stage.
 			if len(deps) == 0 {
-				s.drvs[name] = byName[name]
+				s.drvs[name] = drivers[name]
 				delete(dag, name)
 			}
 		}
@@ -158,6 +158,142 @@ func TestDependencySkipped(t *testing.T) {
 	}
 }
 
+func TestInitWithOptsInclude(t *testing.T) {
+	defer reset()
+	reset()
+	registerDrivers([]Driver{
+		&driver{name: "CPU", ok: true},
+		&driver{name: "Board", prereqs: []string{"CPU"}, ok: true},
+		&driver{name: "Other", ok: true},
+	})
+	state, err := InitWithOpts(&Opts{Include: []string{"Board"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Loaded) != 2 || state.Loaded[0].String() != "Board" || state.Loaded[1].String() != "CPU" {
+		t.Fatal(state.Loaded)
+	}
+	if len(state.Skipped) != 1 || state.Skipped[0].String() != "Other: not included" {
+		t.Fatal(state.Skipped)
+	}
+	if len(state.Stages) != 2 || state.Stages[0][0] != "CPU" || state.Stages[1][0] != "Board" {
+		t.Fatal(state.Stages)
+	}
+	if len(state.Durations) != 2 {
+		t.Fatal(state.Durations)
+	}
+	if _, ok := state.Durations["Board"]; !ok {
+		t.Fatal(state.Durations)
+	}
+	// The state is cached, whatever the options.
+	if state2, err := InitWithOpts(nil); state2 != state || err != nil {
+		t.Fatal(state2, err)
+	}
+}
+
+func TestInitWithOptsExclude(t *testing.T) {
+	defer reset()
+	reset()
+	registerDrivers([]Driver{
+		&driver{name: "CPU", ok: true},
+		&driver{name: "Board", prereqs: []string{"CPU"}, ok: true},
+		&driver{name: "Other", after: []string{"CPU"}, ok: true},
+	})
+	state, err := InitWithOpts(&Opts{Include: []string{"Board", "Other"}, Exclude: []string{"CPU"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Loaded) != 1 || state.Loaded[0].String() != "Other" {
+		t.Fatal(state.Loaded)
+	}
+	if len(state.Skipped) != 2 || state.Skipped[0].String() != "Board: dependency not loaded: \"CPU\"" || state.Skipped[1].String() != "CPU: excluded" {
+		t.Fatal(state.Skipped)
+	}
+	if len(state.Stages) != 1 || len(state.Stages[0]) != 2 {
+		t.Fatal(state.Stages)
+	}
+	if _, ok := state.Durations["Board"]; ok {
+		t.Fatal("Board.Init() must not be called")
+	}
+}
+
+func TestInitWithOptsDryRun(t *testing.T) {
+	defer reset()
+	reset()
+	registerDrivers([]Driver{
+		&driver{name: "CPU", ok: true},
+		&driver{name: "Board", prereqs: []string{"CPU"}, ok: true},
+		&driver{name: "Other", ok: true},
+	})
+	state, err := InitWithOpts(&Opts{Exclude: []string{"Other"}, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Loaded) != 0 || len(state.Durations) != 0 || len(state.Skipped) != 1 {
+		t.Fatal(state)
+	}
+	if len(state.Stages) != 2 || state.Stages[0][0] != "CPU" || state.Stages[1][0] != "Board" {
+		t.Fatal(state.Stages)
+	}
+	// The real initialization is still done.
+	state, err = Init()
+	if err != nil || len(state.Loaded) != 3 || len(state.Skipped) != 0 {
+		t.Fatal(state, err)
+	}
+	if len(state.Stages) != 2 || len(state.Stages[0]) != 2 {
+		t.Fatal(state.Stages)
+	}
+}
+
+func TestInitWithOptsUnknown(t *testing.T) {
+	defer reset()
+	reset()
+	registerDrivers([]Driver{&driver{name: "CPU", ok: true}})
+	if _, err := InitWithOpts(&Opts{Exclude: []string{"GPU"}}); err == nil {
+		t.Fatal("unknown driver")
+	}
+	if _, err := InitWithOpts(&Opts{Include: []string{"GPU"}}); err == nil {
+		t.Fatal("unknown driver")
+	}
+	if state != nil {
+		t.Fatal("state must not be saved")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	defer reset()
+	reset()
+	var closed []string
+	registerDrivers([]Driver{
+		&closerDriver{driver{name: "CPU", ok: true}, &closed, nil},
+		&closerDriver{driver{name: "Board", prereqs: []string{"CPU"}, ok: true}, &closed, nil},
+		&closerDriver{driver{name: "Failed", ok: true, err: errors.New("oops")}, &closed, errors.New("close")},
+		&closerDriver{driver{name: "Skipped", ok: false, err: errors.New("skip")}, &closed, nil},
+		&driver{name: "NoClose", after: []string{"Board"}, ok: true},
+	})
+	if _, err := Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Shutdown(); err == nil || err.Error() != "periph: failed to close \"Failed\": close" {
+		t.Fatal(err)
+	}
+	// Closed in reverse order, the skipped driver is not closed.
+	if len(closed) != 3 || closed[0] != "Board" || closed[1] != "Failed" || closed[2] != "CPU" {
+		t.Fatal(closed)
+	}
+	if err := Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	// Registration and initialization can be done again.
+	if err := Register(&driver{name: "Late", ok: true}); err != nil {
+		t.Fatal(err)
+	}
+	state, err := Init()
+	if err != nil || len(state.Loaded) != 4 {
+		t.Fatal(state, err)
+	}
+}
+
 func TestRegisterLate(t *testing.T) {
 	defer reset()
 	reset()
@@ -228,7 +364,7 @@ func TestPrerequisitesExplodeStagesSimple(t *testing.T) {
 		},
 	}
 	registerDrivers(d)
-	actual, err := explodeStages()
+	actual, err := explodeStages(byName)
 	if len(actual) != 1 || len(actual[0].drvs) != 1 {
 		t.Fatal(actual)
 	}
@@ -256,7 +392,7 @@ func TestPrerequisitesExplodeStages1Dep(t *testing.T) {
 		},
 	}
 	registerDrivers(d)
-	actual, err := explodeStages()
+	actual, err := explodeStages(byName)
 	if len(actual) != 2 || len(actual[0].drvs) != 1 || actual[0].drvs["CPU-generic"] != d[1] || len(actual[1].drvs) != 1 || actual[1].drvs["CPU-specialized"] != d[0] || err != nil {
 		t.Fatal(actual, err)
 	}
@@ -286,7 +422,7 @@ func TestPrerequisitesExplodeStagesCycle(t *testing.T) {
 		},
 	}
 	registerDrivers(d)
-	actual, err := explodeStages()
+	actual, err := explodeStages(byName)
 	if len(actual) != 0 {
 		t.Fatal(actual)
 	}
@@ -326,7 +462,7 @@ func TestPrerequisitesExplodeStages3Dep(t *testing.T) {
 		},
 	}
 	registerDrivers(d)
-	actual, err := explodeStages()
+	actual, err := explodeStages(byName)
 	if len(actual) != 3 || len(actual[0].drvs) != 1 || len(actual[1].drvs) != 2 || len(actual[2].drvs) != 1 {
 		t.Fatal(actual)
 	}
@@ -366,7 +502,7 @@ func TestAfterExplodeStages3Dep(t *testing.T) {
 		},
 	}
 	registerDrivers(d)
-	actual, err := explodeStages()
+	actual, err := explodeStages(byName)
 	if len(actual) != 3 || len(actual[0].drvs) != 1 || len(actual[1].drvs) != 2 || len(actual[2].drvs) != 1 {
 		t.Fatal(actual)
 	}
@@ -437,3 +573,14 @@ func (d *driver) After() []string {
 func (d *driver) Init() (bool, error) {
 	return d.ok, d.err
 }
+
+type closerDriver struct {
+	driver
+	closed *[]string
+	err    error
+}
+
+func (c *closerDriver) Close() error {
+	*c.closed = append(*c.closed, c.name)
+	return c.err
+}